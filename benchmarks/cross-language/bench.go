@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -75,6 +76,14 @@ func stringConcat(n int) int {
 	return len(result)
 }
 
+func stringBuilder(n int) int {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString("x")
+	}
+	return len(sb.String())
+}
+
 // ============================================================================
 // 6. NESTED LOOPS - Tests loop optimization
 // ============================================================================
@@ -125,89 +134,59 @@ func objectCreation(n int) int {
 // BENCHMARK RUNNER
 // ============================================================================
 
-func main() {
-	fmt.Println("=== GO BENCHMARK SUITE ===")
-	fmt.Println()
+// report prints a benchmark's time and result as the KEY=value lines that
+// `ruff bench-suite` reads.
+func report(id string, elapsed time.Duration, result int) {
+	fmt.Printf("%s_MS=%.3f\n", id, float64(elapsed.Nanoseconds())/1e6)
+	fmt.Printf("%s_RESULT=%d\n", id, result)
+}
 
+func main() {
 	// Warm up
 	fibRecursive(10)
 	fibIterative(100)
 
-	// 1. Fibonacci Recursive
-	fmt.Println("1. Fibonacci Recursive (n=30)...")
 	start := time.Now()
 	result := fibRecursive(30)
-	elapsed := time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("FIB_RECURSIVE", time.Since(start), result)
 
-	// 2. Fibonacci Iterative
-	fmt.Println("2. Fibonacci Iterative (n=100000)...")
 	start = time.Now()
 	result = fibIterative(100000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("FIB_ITERATIVE", time.Since(start), result)
 
-	// 3. Array Sum
-	fmt.Println("3. Array Sum (1M elements)...")
 	arr := make([]int, 1000000)
 	for i := range arr {
 		arr[i] = i
 	}
 	start = time.Now()
-	sum := arraySum(arr)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d\n", sum)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
-
-	// 4. Hash Map Operations
-	fmt.Println("4. Hash Map Operations (100k items)...")
+	result = arraySum(arr)
+	report("ARRAY_SUM", time.Since(start), result)
+
 	start = time.Now()
 	result = hashMapOps(100000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("HASH_MAP", time.Since(start), result)
 
-	// 5. String Concatenation
-	fmt.Println("5. String Concatenation (10k chars)...")
 	start = time.Now()
 	result = stringConcat(10000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d chars\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("STRING_CONCAT", time.Since(start), result)
+
+	start = time.Now()
+	result = stringConcat(100000)
+	report("STRING_CONCAT_100K", time.Since(start), result)
+
+	start = time.Now()
+	result = stringBuilder(100000)
+	report("STRING_BUILDER_100K", time.Since(start), result)
 
-	// 6. Nested Loops
-	fmt.Println("6. Nested Loops (1000x1000)...")
 	start = time.Now()
 	result = nestedLoops(1000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("NESTED_LOOPS", time.Since(start), result)
 
-	// 7. Array Building
-	fmt.Println("7. Array Building (100k elements)...")
 	start = time.Now()
 	result = buildArray(100000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d elements\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
+	report("ARRAY_BUILD", time.Since(start), result)
 
-	// 8. Object Creation
-	fmt.Println("8. Object Creation (100k objects)...")
 	start = time.Now()
 	result = objectCreation(100000)
-	elapsed = time.Since(start).Milliseconds()
-	fmt.Printf("   Result: %d objects\n", result)
-	fmt.Printf("   Time: %dms\n", elapsed)
-	fmt.Println()
-
-	fmt.Println("=== BENCHMARK COMPLETE ===")
+	report("OBJECT_CREATION", time.Since(start), result)
 }
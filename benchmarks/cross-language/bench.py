@@ -64,6 +64,12 @@ def string_concat(n):
         result += "x"
     return len(result)
 
+def string_builder(n):
+    parts = []
+    for i in range(n):
+        parts.append("x")
+    return len("".join(parts))
+
 # ============================================================================
 # 6. NESTED LOOPS - Tests loop optimization
 # ============================================================================
@@ -97,88 +103,60 @@ def object_creation(n):
 # BENCHMARK RUNNER
 # ============================================================================
 
+def report(bench_id, elapsed_ms, result):
+    """Print a benchmark's time and result as the KEY=value lines that `ruff bench-suite` reads."""
+    print(f"{bench_id}_MS={elapsed_ms:.3f}")
+    # Wrap to a signed 64-bit integer, as Go and Ruff integers overflow
+    print(f"{bench_id}_RESULT={(result + 2**63) % 2**64 - 2**63}")
+
+def elapsed_since(start):
+    return (time.perf_counter() - start) * 1000
+
 def run_benchmarks():
-    print("=== PYTHON BENCHMARK SUITE ===")
-    print()
-    
     # Warm up
     fib_recursive(10)
     fib_iterative(100)
-    
-    # 1. Fibonacci Recursive
-    print("1. Fibonacci Recursive (n=30)...")
-    start = time.time()
+
+    start = time.perf_counter()
     result = fib_recursive(30)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result}")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 2. Fibonacci Iterative
-    print("2. Fibonacci Iterative (n=100000)...")
-    start = time.time()
+    report("FIB_RECURSIVE", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = fib_iterative(100000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result}")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 3. Array Sum
-    print("3. Array Sum (1M elements)...")
+    report("FIB_ITERATIVE", elapsed_since(start), result)
+
     arr = list(range(1000000))
-    start = time.time()
+    start = time.perf_counter()
     result = array_sum(arr)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result}")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 4. Hash Map Operations
-    print("4. Hash Map Operations (100k items)...")
-    start = time.time()
+    report("ARRAY_SUM", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = hash_map_ops(100000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result}")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 5. String Concatenation
-    print("5. String Concatenation (10k chars)...")
-    start = time.time()
+    report("HASH_MAP", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = string_concat(10000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result} chars")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 6. Nested Loops
-    print("6. Nested Loops (1000x1000)...")
-    start = time.time()
+    report("STRING_CONCAT", elapsed_since(start), result)
+
+    start = time.perf_counter()
+    result = string_concat(100000)
+    report("STRING_CONCAT_100K", elapsed_since(start), result)
+
+    start = time.perf_counter()
+    result = string_builder(100000)
+    report("STRING_BUILDER_100K", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = nested_loops(1000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result}")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 7. Array Building
-    print("7. Array Building (100k elements)...")
-    start = time.time()
+    report("NESTED_LOOPS", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = build_array(100000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result} elements")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    # 8. Object Creation
-    print("8. Object Creation (100k objects)...")
-    start = time.time()
+    report("ARRAY_BUILD", elapsed_since(start), result)
+
+    start = time.perf_counter()
     result = object_creation(100000)
-    elapsed = (time.time() - start) * 1000
-    print(f"   Result: {result} objects")
-    print(f"   Time: {elapsed:.2f}ms")
-    print()
-    
-    print("=== BENCHMARK COMPLETE ===")
+    report("OBJECT_CREATION", elapsed_since(start), result)
 
 if __name__ == "__main__":
     run_benchmarks()
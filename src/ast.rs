@@ -8,6 +8,7 @@
 // represent actions and control flow.
 
 use crate::errors::{SourceLocation, SourceSpan};
+use serde::{Deserialize, Serialize};
 
 /// Shared AST span type used across parser, runtime diagnostics, and LSP diagnostics.
 pub type AstSpan = SourceSpan;
@@ -34,6 +35,35 @@ pub mod operator_methods {
     pub const NEG: &str = "op_neg";
     pub const NOT: &str = "op_not";
 
+    // Index access: `value[key]`
+    pub const INDEX: &str = "op_index";
+
+    // String representation hook used by print, interpolation and to_string()
+    pub const TO_STRING: &str = "to_string";
+
+    /// Maps a Python-style special method name such as `__add__` to the hook it
+    /// defines; a struct method with that name also registers under the hook name
+    pub fn special_method_hook(name: &str) -> Option<&'static str> {
+        match name {
+            "__add__" => Some(ADD),
+            "__sub__" => Some(SUB),
+            "__mul__" => Some(MUL),
+            "__div__" => Some(DIV),
+            "__mod__" => Some(MOD),
+            "__eq__" => Some(EQ),
+            "__ne__" => Some(NE),
+            "__lt__" => Some(LT),
+            "__gt__" => Some(GT),
+            "__le__" => Some(LE),
+            "__ge__" => Some(GE),
+            "__neg__" => Some(NEG),
+            "__not__" => Some(NOT),
+            "__index__" => Some(INDEX),
+            "__str__" => Some(TO_STRING),
+            _ => None,
+        }
+    }
+
     /// Maps binary operators to their corresponding method names
     pub fn binary_op_method(op: &str) -> Option<&'static str> {
         match op {
@@ -63,7 +93,7 @@ pub mod operator_methods {
 }
 
 /// Represents parts of an interpolated string in the AST
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub enum InterpolatedStringPart {
     Text(String),    // Plain text
     Expr(Box<Expr>), // Expression to evaluate
@@ -71,7 +101,7 @@ pub enum InterpolatedStringPart {
 
 /// Destructuring patterns for variable binding
 /// Supports array and dict destructuring with rest elements
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub enum Pattern {
     /// Simple identifier: x
     Identifier(String),
@@ -89,8 +119,130 @@ pub enum Pattern {
     Ignore,
 }
 
+/// Pattern tested by a `match` case
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub enum MatchPattern {
+    /// `_`: matches anything and binds nothing
+    Wildcard,
+    /// `name`: matches anything and binds it
+    Binding(String),
+    /// `1`, `-2.5`, `"ok"`, `true`, `null`: matches values equal under `==`
+    Literal(MatchLiteral),
+    /// `Ok(p)`, `None`, `Status::Failure(err)`: matches the tag, then the payload against `p`
+    Tag { tag: String, payload: Option<Box<MatchPattern>> },
+    /// `[first, second, ...rest]`: exact length unless a rest name is given
+    Array { elements: Vec<MatchPattern>, rest: Option<String> },
+    /// `{"status": 200, body, ...rest}`: listed keys must exist; a bare key binds its value
+    Dict { entries: Vec<(String, MatchPattern)>, rest: Option<String> },
+}
+
+/// Constant allowed in a literal `match` pattern
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub enum MatchLiteral {
+    Int(i64),
+    Float(f64),
+    Str(String),
+    Bool(bool),
+    Null,
+}
+
+impl MatchPattern {
+    /// Whether the pattern matches every value, so later cases are unreachable.
+    pub fn is_irrefutable(&self) -> bool {
+        matches!(self, MatchPattern::Wildcard | MatchPattern::Binding(_))
+    }
+
+    /// Names the pattern binds when it matches, in source order.
+    pub fn binding_names(&self) -> Vec<String> {
+        match self {
+            MatchPattern::Wildcard | MatchPattern::Literal(_) => Vec::new(),
+            MatchPattern::Binding(name) => vec![name.clone()],
+            MatchPattern::Tag { payload, .. } => {
+                payload.as_ref().map(|payload| payload.binding_names()).unwrap_or_default()
+            }
+            MatchPattern::Array { elements, rest } => elements
+                .iter()
+                .flat_map(MatchPattern::binding_names)
+                .chain(rest.iter().cloned())
+                .collect(),
+            MatchPattern::Dict { entries, rest } => entries
+                .iter()
+                .flat_map(|(_, pattern)| pattern.binding_names())
+                .chain(rest.iter().cloned())
+                .collect(),
+        }
+    }
+}
+
+impl std::fmt::Display for MatchPattern {
+    /// Renders the pattern in source form, as the formatter prints it.
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            MatchPattern::Wildcard => write!(f, "_"),
+            MatchPattern::Binding(name) => write!(f, "{}", name),
+            MatchPattern::Literal(literal) => match literal {
+                MatchLiteral::Int(value) => write!(f, "{}", value),
+                MatchLiteral::Float(value) if value.fract() == 0.0 && value.is_finite() => {
+                    write!(f, "{:.1}", value)
+                }
+                MatchLiteral::Float(value) => write!(f, "{}", value),
+                MatchLiteral::Str(text) => write!(f, "{:?}", text),
+                MatchLiteral::Bool(value) => write!(f, "{}", value),
+                MatchLiteral::Null => write!(f, "null"),
+            },
+            MatchPattern::Tag { tag, payload: Some(payload) } => write!(f, "{}({})", tag, payload),
+            MatchPattern::Tag { tag, payload: None } => write!(f, "{}", tag),
+            MatchPattern::Array { elements, rest } => {
+                let mut parts: Vec<String> = elements.iter().map(ToString::to_string).collect();
+                if let Some(rest) = rest {
+                    parts.push(format!("...{}", rest));
+                }
+                write!(f, "[{}]", parts.join(", "))
+            }
+            MatchPattern::Dict { entries, rest } => {
+                let mut parts: Vec<String> = entries
+                    .iter()
+                    .map(|(key, pattern)| match pattern {
+                        MatchPattern::Binding(name) if name == key => key.clone(),
+                        _ => format!("{:?}: {}", key, pattern),
+                    })
+                    .collect();
+                if let Some(rest) = rest {
+                    parts.push(format!("...{}", rest));
+                }
+                write!(f, "{{{}}}", parts.join(", "))
+            }
+        }
+    }
+}
+
+/// One `case pattern [if guard]: body` arm of a `match`
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct MatchCase {
+    pub pattern: MatchPattern,
+    pub guard: Option<Expr>,
+    pub body: Vec<Stmt>,
+}
+
+/// A method signature required by an `interface`, such as `func scale(self, factor)`
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct InterfaceMethod {
+    pub name: String,
+    pub params: Vec<String>,
+    pub param_types: Vec<Option<TypeAnnotation>>,
+    pub return_type: Option<TypeAnnotation>,
+}
+
+impl InterfaceMethod {
+    /// Number of call-site arguments, not counting a leading `self`
+    pub fn arity(&self) -> usize {
+        let has_self = self.params.first().map(|param| param == "self").unwrap_or(false);
+        self.params.len() - usize::from(has_self)
+    }
+}
+
 /// Type annotations for variables and functions
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[allow(dead_code)]
 pub enum TypeAnnotation {
     Int,
@@ -141,8 +293,37 @@ impl TypeAnnotation {
     }
 }
 
+/// Renders the annotation in source syntax (`int`, `Result<int, string>`), as used by the
+/// formatter and editor hovers.
+impl std::fmt::Display for TypeAnnotation {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        fn join(types: &[TypeAnnotation], separator: &str) -> String {
+            types.iter().map(ToString::to_string).collect::<Vec<_>>().join(separator)
+        }
+
+        match self {
+            TypeAnnotation::Int => write!(f, "int"),
+            TypeAnnotation::Float => write!(f, "float"),
+            TypeAnnotation::String => write!(f, "string"),
+            TypeAnnotation::Bool => write!(f, "bool"),
+            TypeAnnotation::Array(inner) => write!(f, "[{}]", inner),
+            TypeAnnotation::Dict { key, value } => write!(f, "{{{}: {}}}", key, value),
+            TypeAnnotation::Function { params, return_type } => {
+                write!(f, "func({}) -> {}", join(params, ", "), return_type)
+            }
+            TypeAnnotation::Enum(name) => write!(f, "{}", name),
+            TypeAnnotation::Union(types) => write!(f, "{}", join(types, " | ")),
+            TypeAnnotation::Any => write!(f, "any"),
+            TypeAnnotation::Result { ok_type, err_type } => {
+                write!(f, "Result<{}, {}>", ok_type, err_type)
+            }
+            TypeAnnotation::Option { inner_type } => write!(f, "Option<{}>", inner_type),
+        }
+    }
+}
+
 /// Represents an expression in Ruff - something that evaluates to a value
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub enum Expr {
     Identifier(String),
     Int(i64),   // Integer literal like 42
@@ -153,6 +334,9 @@ pub enum Expr {
     Function {
         params: Vec<String>,
         param_types: Vec<Option<TypeAnnotation>>,
+        /// Literal default per parameter; empty when no parameter has one
+        param_defaults: Vec<Option<Expr>>,
+        is_variadic: bool, // true if the last parameter is `...rest`
         return_type: Option<TypeAnnotation>,
         body: Vec<Stmt>,
         is_generator: bool, // true if func* syntax
@@ -190,13 +374,24 @@ pub enum Expr {
         object: Box<Expr>,
         index: Box<Expr>,
     },
-    /// Spread expression: ...expr
-    /// NOTE: This variant exists in the AST for completeness but is NEVER constructed
-    /// as a standalone expression. Spread is only valid within ArrayElement::Spread
-    /// and DictElement::Spread contexts. The warning is suppressed because this design
-    /// is intentional - spread semantics depend on container context.
-    #[allow(dead_code)]
+    /// Slice access: `value[start:end]`. A missing bound runs to that end of the
+    /// value, and negative bounds count back from the end.
+    Slice {
+        object: Box<Expr>,
+        start: Option<Box<Expr>>,
+        end: Option<Box<Expr>>,
+    },
+    /// Spread argument: f(...args)
+    /// Only constructed as a call argument, where it passes an array's elements as
+    /// positional arguments. Array and dict literals use ArrayElement::Spread and
+    /// DictElement::Spread instead.
     Spread(Box<Expr>),
+    /// Named argument: greet(name: "Bob")
+    /// Only constructed as a call argument, after any positional arguments.
+    NamedArg {
+        name: String,
+        value: Box<Expr>,
+    },
     /// Result type constructors
     Ok(Box<Expr>), // Ok(value)
     Err(Box<Expr>), // Err(error)
@@ -205,6 +400,13 @@ pub enum Expr {
     None,           // None
     /// Try operator for error propagation: expr?
     Try(Box<Expr>),
+    /// Conditional expression: condition ? then_expr : else_expr
+    /// Only the selected branch is evaluated.
+    Ternary {
+        condition: Box<Expr>,
+        then_expr: Box<Expr>,
+        else_expr: Box<Expr>,
+    },
     /// Yield expression: yield value
     /// Used in generator functions to yield values
     Yield(Option<Box<Expr>>),
@@ -218,17 +420,24 @@ pub enum Expr {
         method: String,
         args: Vec<Expr>,
     },
+    /// Match expression: let label := match code { case 200: "ok" default: "error" }
+    /// Evaluates to the last expression statement of the chosen arm, or null.
+    Match {
+        value: Box<Expr>,
+        cases: Vec<MatchCase>,
+        default: Option<Vec<Stmt>>,
+    },
 }
 
 /// Array element can be a regular expression or a spread
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub enum ArrayElement {
     Single(Expr),
     Spread(Expr),
 }
 
 /// Dict element can be a key-value pair or a spread
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub enum DictElement {
     Pair(Expr, Expr),
     Spread(Expr),
@@ -249,10 +458,31 @@ impl Expr {
     pub fn location(&self) -> SourceLocation {
         self.span().start
     }
+
+    /// Whether this expression may be a parameter default: a number, string, bool,
+    /// `null`, or an array or dict built only from those.
+    pub fn is_literal_default(&self) -> bool {
+        match self {
+            Expr::Int(_) | Expr::Float(_) | Expr::String(_) | Expr::Bool(_) => true,
+            Expr::Identifier(name) => name == "null",
+            Expr::UnaryOp { op, operand } => {
+                op == "-" && matches!(operand.as_ref(), Expr::Int(_) | Expr::Float(_))
+            }
+            Expr::ArrayLiteral(elements) => elements.iter().all(|element| match element {
+                ArrayElement::Single(expr) => expr.is_literal_default(),
+                ArrayElement::Spread(_) => false,
+            }),
+            Expr::DictLiteral(elements) => elements.iter().all(|element| match element {
+                DictElement::Pair(Expr::String(_), value) => value.is_literal_default(),
+                _ => false,
+            }),
+            _ => false,
+        }
+    }
 }
 
 /// Represents a statement in Ruff - an action or declaration
-#[derive(Debug, Clone)]
+#[derive(Debug, Clone, Serialize, Deserialize)]
 pub enum Stmt {
     Let {
         pattern: Pattern, // Changed from 'name: String' to support destructuring
@@ -271,14 +501,26 @@ pub enum Stmt {
         target: Expr, // Can be Identifier or IndexAccess
         value: Expr,
     },
+    /// Multiple assignment: a, b = b, a + b
+    /// Every value is evaluated before any target is assigned, so swaps work.
+    /// A single value with several targets unpacks an array: `q, r := divmod(a, b)`.
+    MultiAssign {
+        targets: Vec<Expr>,
+        values: Vec<Expr>,
+    },
     FuncDef {
         name: String,
         params: Vec<String>,
         param_types: Vec<Option<TypeAnnotation>>,
-        is_async: bool, // true if async func syntax
+        /// Literal default per parameter; empty when no parameter has one
+        param_defaults: Vec<Option<Expr>>,
+        is_variadic: bool, // true if the last parameter is `...rest`
+        is_async: bool,    // true if async func syntax
         return_type: Option<TypeAnnotation>,
         body: Vec<Stmt>,
         is_generator: bool, // true if func* syntax
+        /// `@decorator` expressions above the definition, top one first
+        decorators: Vec<Expr>,
     },
     EnumDef {
         name: String,
@@ -286,7 +528,7 @@ pub enum Stmt {
     },
     Match {
         value: Expr,
-        cases: Vec<(String, Vec<Stmt>)>,
+        cases: Vec<MatchCase>,
         default: Option<Vec<Stmt>>,
     },
     #[allow(clippy::enum_variant_names)]
@@ -311,12 +553,22 @@ pub enum Stmt {
         condition: Expr,
         body: Vec<Stmt>,
     },
-    Break,
-    Continue,
+    /// Loop prefixed with a label (`outer: for ...`); `loop_stmt` is always a Loop, For, or While
+    LabeledLoop {
+        label: String,
+        loop_stmt: Box<Stmt>,
+    },
+    /// `break` or `break label`
+    Break(Option<String>),
+    /// `continue` or `continue label`
+    Continue(Option<String>),
+    /// `try { } except err { }`, also written `catch (err)`, with an optional
+    /// `finally { }` block that runs however the statement is left
     TryExcept {
         try_block: Vec<Stmt>,
         except_var: String,
         except_block: Vec<Stmt>,
+        finally_block: Option<Vec<Stmt>>,
     },
     #[allow(dead_code)]
     Block(Vec<Stmt>),
@@ -325,6 +577,11 @@ pub enum Stmt {
         module: String,
         symbols: Option<Vec<String>>, // None means import whole module, Some means specific symbols
     },
+    /// Namespace import: `import "lib/strings"` binds the module's exports to `strings`
+    ImportPath {
+        path: String,
+        namespace: String,
+    },
     /// Export statement: marks a statement as exported from a module
     Export {
         stmt: Box<Stmt>,
@@ -332,7 +589,13 @@ pub enum Stmt {
     StructDef {
         name: String,
         fields: Vec<(String, Option<TypeAnnotation>)>,
-        methods: Vec<Stmt>, // FuncDef statements
+        methods: Vec<Stmt>,      // FuncDef statements
+        implements: Vec<String>, // Interfaces checked when the struct is defined
+    },
+    /// `interface Name { func method(self, arg) ... }`: methods a struct must define
+    InterfaceDef {
+        name: String,
+        methods: Vec<InterfaceMethod>,
     },
     /// Spawn statement: run a block of code in a background thread
     Spawn {
@@ -357,6 +620,13 @@ pub enum Stmt {
         name: String,
         tests: Vec<Stmt>, // Should contain Test statements
     },
+    /// Position of the statement that follows in the same block. Only emitted when the
+    /// parser runs `with_source_positions`, so runtimes can locate errors; evaluating it
+    /// does nothing.
+    SourcePos {
+        line: usize,
+        column: usize,
+    },
 }
 
 impl Stmt {
@@ -374,4 +644,21 @@ impl Stmt {
     pub fn location(&self) -> SourceLocation {
         self.span().start
     }
+
+    /// For a decorated `func name`, the `name = dec(name)` both runtimes run right after
+    /// defining it. Stacked decorators apply bottom-up, and because the rebinding replaces
+    /// `name` itself, recursive calls inside the body reach the decorated function.
+    pub fn decorator_rebinding(&self) -> Option<Stmt> {
+        let Stmt::FuncDef { name, decorators, .. } = self else {
+            return None;
+        };
+        if decorators.is_empty() {
+            return None;
+        }
+        let decorated =
+            decorators.iter().rev().fold(Expr::Identifier(name.clone()), |function, decorator| {
+                Expr::Call { function: Box::new(decorator.clone()), args: vec![function] }
+            });
+        Some(Stmt::Assign { target: Expr::Identifier(name.clone()), value: decorated })
+    }
 }
@@ -10,19 +10,24 @@
 //   bench.run_all();
 
 pub mod cross_language;
+pub mod pprof;
 pub mod profiler;
 pub mod reporter;
 pub mod runner;
+pub mod sampling;
 pub mod ssg;
 pub mod stats;
+pub mod suite;
 pub mod timer;
 
 pub use cross_language::run_process_pool_comparison;
 pub use profiler::{print_profile_report, ProfileConfig, Profiler};
 pub use reporter::Reporter;
 pub use runner::BenchmarkRunner;
+pub use sampling::SamplingProfiler;
 pub use ssg::{aggregate_ssg_results, run_ssg_benchmark_series};
 pub use stats::Statistics;
+pub use suite::run_benchmark_suite;
 pub use timer::Timer;
 
 /// Execution mode for benchmarking
@@ -0,0 +1,239 @@
+// pprof profile encoding
+//
+// Writes the `profile.proto` message understood by `go tool pprof` and other pprof
+// viewers. The encoder is hand-rolled because only a handful of message types are
+// needed; output is uncompressed protobuf, which pprof accepts as-is.
+
+use std::collections::HashMap;
+
+/// One aggregated stack: frames are `(function, line)` pairs, outermost first
+pub struct PprofSample {
+    pub frames: Vec<(String, usize)>,
+    pub values: Vec<i64>,
+    pub labels: Vec<(String, String)>,
+}
+
+/// Builds a pprof profile from aggregated samples
+pub struct PprofBuilder {
+    strings: Vec<String>,
+    string_ids: HashMap<String, i64>,
+    functions: HashMap<String, u64>,
+    locations: HashMap<(u64, usize), u64>,
+    sample_types: Vec<(i64, i64)>,
+    period: Option<((i64, i64), i64)>,
+    samples: Vec<(Vec<u64>, Vec<i64>, Vec<(i64, i64)>)>,
+    filename: String,
+    time_nanos: i64,
+    duration_nanos: i64,
+}
+
+impl PprofBuilder {
+    /// `sample_types` are `(type, unit)` pairs such as `("cpu", "nanoseconds")`;
+    /// every sample carries one value per type, in the same order.
+    pub fn new(filename: &str, sample_types: &[(&str, &str)]) -> Self {
+        let mut builder = Self {
+            strings: Vec::new(),
+            string_ids: HashMap::new(),
+            functions: HashMap::new(),
+            locations: HashMap::new(),
+            sample_types: Vec::new(),
+            period: None,
+            samples: Vec::new(),
+            filename: filename.to_string(),
+            time_nanos: 0,
+            duration_nanos: 0,
+        };
+        // The string table must start with the empty string
+        builder.string_id("");
+        builder.string_id(filename);
+        let sample_types = sample_types
+            .iter()
+            .map(|(kind, unit)| (builder.string_id(kind), builder.string_id(unit)))
+            .collect();
+        builder.sample_types = sample_types;
+        builder
+    }
+
+    /// Records the sampling period, e.g. `("cpu", "nanoseconds")` every 1ms
+    pub fn with_period(mut self, kind: &str, unit: &str, period: i64) -> Self {
+        self.period = Some(((self.string_id(kind), self.string_id(unit)), period));
+        self
+    }
+
+    /// Wall-clock start of the profile and how long it ran, in nanoseconds
+    pub fn with_timing(mut self, time_nanos: i64, duration_nanos: i64) -> Self {
+        self.time_nanos = time_nanos;
+        self.duration_nanos = duration_nanos;
+        self
+    }
+
+    pub fn add_sample(&mut self, sample: &PprofSample) {
+        // pprof lists locations leaf first
+        let location_ids: Vec<u64> = sample
+            .frames
+            .iter()
+            .rev()
+            .map(|(function, line)| self.location_id(function, *line))
+            .collect();
+        let labels = sample
+            .labels
+            .iter()
+            .map(|(key, value)| (self.string_id(key), self.string_id(value)))
+            .collect();
+        self.samples.push((location_ids, sample.values.clone(), labels));
+    }
+
+    pub fn encode(&self) -> Vec<u8> {
+        let mut out = Vec::new();
+        for (kind, unit) in &self.sample_types {
+            write_message(&mut out, 1, &encode_value_type(*kind, *unit));
+        }
+        for (location_ids, values, labels) in &self.samples {
+            let mut sample = Vec::new();
+            write_packed(&mut sample, 1, location_ids.iter().copied());
+            write_packed(&mut sample, 2, values.iter().map(|value| *value as u64));
+            for (key, value) in labels {
+                let mut label = Vec::new();
+                write_varint_field(&mut label, 1, *key as u64);
+                write_varint_field(&mut label, 2, *value as u64);
+                write_message(&mut sample, 3, &label);
+            }
+            write_message(&mut out, 2, &sample);
+        }
+
+        let mut locations: Vec<_> = self.locations.iter().collect();
+        locations.sort_by_key(|(_, id)| **id);
+        for ((function_id, line), id) in locations {
+            let mut line_message = Vec::new();
+            write_varint_field(&mut line_message, 1, *function_id);
+            write_varint_field(&mut line_message, 2, *line as u64);
+            let mut location = Vec::new();
+            write_varint_field(&mut location, 1, *id);
+            write_message(&mut location, 4, &line_message);
+            write_message(&mut out, 4, &location);
+        }
+
+        let filename = self.string_ids[&self.filename];
+        let mut functions: Vec<_> = self.functions.iter().collect();
+        functions.sort_by_key(|(_, id)| **id);
+        for (name, id) in functions {
+            let name = self.string_ids[name];
+            let mut function = Vec::new();
+            write_varint_field(&mut function, 1, *id);
+            write_varint_field(&mut function, 2, name as u64);
+            write_varint_field(&mut function, 3, name as u64);
+            write_varint_field(&mut function, 4, filename as u64);
+            write_message(&mut out, 5, &function);
+        }
+
+        for string in &self.strings {
+            write_bytes_field(&mut out, 6, string.as_bytes());
+        }
+        write_varint_field(&mut out, 9, self.time_nanos as u64);
+        write_varint_field(&mut out, 10, self.duration_nanos as u64);
+        if let Some(((kind, unit), period)) = self.period {
+            write_message(&mut out, 11, &encode_value_type(kind, unit));
+            write_varint_field(&mut out, 12, period as u64);
+        }
+        out
+    }
+
+    fn string_id(&mut self, value: &str) -> i64 {
+        if let Some(id) = self.string_ids.get(value) {
+            return *id;
+        }
+        let id = self.strings.len() as i64;
+        self.strings.push(value.to_string());
+        self.string_ids.insert(value.to_string(), id);
+        id
+    }
+
+    fn location_id(&mut self, function: &str, line: usize) -> u64 {
+        // Viewers strip `<...>` as template arguments, which would blank out `<main>`
+        let function = function.trim_start_matches('<').trim_end_matches('>');
+        if !self.functions.contains_key(function) {
+            self.string_id(function);
+            let id = self.functions.len() as u64 + 1;
+            self.functions.insert(function.to_string(), id);
+        }
+        let function_id = self.functions[function];
+        let next_id = self.locations.len() as u64 + 1;
+        *self.locations.entry((function_id, line)).or_insert(next_id)
+    }
+}
+
+fn encode_value_type(kind: i64, unit: i64) -> Vec<u8> {
+    let mut message = Vec::new();
+    write_varint_field(&mut message, 1, kind as u64);
+    write_varint_field(&mut message, 2, unit as u64);
+    message
+}
+
+fn write_varint(out: &mut Vec<u8>, mut value: u64) {
+    while value >= 0x80 {
+        out.push((value as u8) | 0x80);
+        value >>= 7;
+    }
+    out.push(value as u8);
+}
+
+fn write_varint_field(out: &mut Vec<u8>, field: u64, value: u64) {
+    write_varint(out, field << 3);
+    write_varint(out, value);
+}
+
+fn write_bytes_field(out: &mut Vec<u8>, field: u64, bytes: &[u8]) {
+    write_varint(out, (field << 3) | 2);
+    write_varint(out, bytes.len() as u64);
+    out.extend_from_slice(bytes);
+}
+
+fn write_message(out: &mut Vec<u8>, field: u64, message: &[u8]) {
+    write_bytes_field(out, field, message);
+}
+
+fn write_packed(out: &mut Vec<u8>, field: u64, values: impl Iterator<Item = u64>) {
+    let mut packed = Vec::new();
+    for value in values {
+        write_varint(&mut packed, value);
+    }
+    write_bytes_field(out, field, &packed);
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn varints_use_little_endian_base_128() {
+        let mut out = Vec::new();
+        write_varint(&mut out, 1);
+        write_varint(&mut out, 300);
+        assert_eq!(out, vec![0x01, 0xAC, 0x02]);
+    }
+
+    #[test]
+    fn encode_interns_strings_and_shares_locations() {
+        let mut builder = PprofBuilder::new("main.ruff", &[("samples", "count")]);
+        let frames = vec![("<main>".to_string(), 3), ("work".to_string(), 7)];
+        builder.add_sample(&PprofSample {
+            frames: frames.clone(),
+            values: vec![2],
+            labels: vec![],
+        });
+        builder.add_sample(&PprofSample { frames, values: vec![1], labels: vec![] });
+
+        assert_eq!(builder.strings[0], "");
+        assert_eq!(builder.functions.len(), 2);
+        assert_eq!(builder.locations.len(), 2);
+        // Locations are listed leaf first, and `<main>` is written as `main`
+        let main_id = builder.locations[&(builder.functions["main"], 3)];
+        let work_id = builder.locations[&(builder.functions["work"], 7)];
+        assert_eq!(builder.samples[0].0, vec![work_id, main_id]);
+        assert_eq!(builder.samples[1].0, builder.samples[0].0);
+
+        let encoded = builder.encode();
+        let needle = b"work";
+        assert!(encoded.windows(needle.len()).any(|window| window == needle));
+    }
+}
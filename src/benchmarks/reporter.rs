@@ -58,6 +58,7 @@ impl Reporter {
             println!("  Min:    {}", Statistics::format_duration(stats.min).green());
             println!("  Max:    {}", Statistics::format_duration(stats.max).red());
             println!("  StdDev: {}", Statistics::format_duration(stats.stddev).blue());
+            println!("  Ops/sec: {:.2}", stats.ops_per_sec());
             println!("  Samples: {}", stats.samples);
         }
     }
@@ -114,6 +115,36 @@ impl Reporter {
         print!("{}", Self::render_comparison_table_text(results));
     }
 
+    /// Machine-readable report with one entry per benchmark and execution mode.
+    /// Durations are nanoseconds so results diff cleanly against other baselines.
+    pub fn render_json(results: &[(String, Vec<BenchmarkResult>)]) -> serde_json::Value {
+        let entries: Vec<serde_json::Value> = results
+            .iter()
+            .flat_map(|(name, bench_results)| {
+                bench_results.iter().map(move |result| {
+                    let stats = Statistics::from_samples(&result.samples);
+                    let nanos = |pick: fn(&Statistics) -> Duration| {
+                        stats.as_ref().map(|stats| pick(stats).as_nanos() as u64)
+                    };
+                    serde_json::json!({
+                        "benchmark": name,
+                        "mode": result.mode.name(),
+                        "success": result.success,
+                        "error": result.error,
+                        "samples": result.samples.len(),
+                        "mean_ns": nanos(|stats| stats.mean),
+                        "median_ns": nanos(|stats| stats.median),
+                        "stddev_ns": nanos(|stats| stats.stddev),
+                        "min_ns": nanos(|stats| stats.min),
+                        "max_ns": nanos(|stats| stats.max),
+                        "ops_per_sec": stats.as_ref().map(Statistics::ops_per_sec),
+                    })
+                })
+            })
+            .collect();
+        serde_json::json!({ "benchmarks": entries })
+    }
+
     pub fn render_summary_text(results: &[(String, Vec<BenchmarkResult>)]) -> Option<String> {
         let mut total_interp = Duration::ZERO;
         let mut total_vm = Duration::ZERO;
@@ -198,6 +229,28 @@ mod tests {
         assert!(output.contains("5.00x"));
     }
 
+    #[test]
+    fn render_json_reports_nanosecond_statistics_per_mode() {
+        let mut failed = BenchmarkResult::new("json-parse".to_string(), ExecutionMode::JIT);
+        failed.set_error("Execution failed: boom".to_string());
+        let rows = vec![(
+            "json-parse".to_string(),
+            vec![result_with_mean("json-parse", ExecutionMode::VM, 5), failed],
+        )];
+
+        let report = Reporter::render_json(&rows);
+        let entries = report["benchmarks"].as_array().expect("benchmarks should be an array");
+        assert_eq!(entries.len(), 2);
+        assert_eq!(entries[0]["benchmark"], "json-parse");
+        assert_eq!(entries[0]["mode"], "VM");
+        assert_eq!(entries[0]["mean_ns"], 5_000_000);
+        assert_eq!(entries[0]["median_ns"], 5_000_000);
+        assert_eq!(entries[0]["ops_per_sec"], 200.0);
+        assert_eq!(entries[1]["success"], false);
+        assert_eq!(entries[1]["error"], "Execution failed: boom");
+        assert!(entries[1]["mean_ns"].is_null());
+    }
+
     #[test]
     fn render_summary_text_includes_totals_and_speedups() {
         let rows = vec![(
@@ -11,11 +11,12 @@ use std::sync::{Arc, Mutex};
 pub struct BenchmarkRunner {
     iterations: usize,
     warmup_runs: usize,
+    quiet: bool,
 }
 
 impl BenchmarkRunner {
     pub fn new() -> Self {
-        Self { iterations: 10, warmup_runs: 2 }
+        Self { iterations: 10, warmup_runs: 2, quiet: false }
     }
 
     pub fn with_iterations(mut self, iterations: usize) -> Self {
@@ -28,6 +29,16 @@ impl BenchmarkRunner {
         self
     }
 
+    /// Discard what benchmarked scripts print, keeping stdout for the report
+    pub fn with_quiet_output(mut self, quiet: bool) -> Self {
+        self.quiet = quiet;
+        self
+    }
+
+    fn discarded_output(&self) -> Option<Arc<Mutex<Vec<u8>>>> {
+        self.quiet.then(|| Arc::new(Mutex::new(Vec::new())))
+    }
+
     /// Run a benchmark with the given code in all execution modes
     pub fn run_benchmark(&self, name: &str, code: &str) -> Vec<BenchmarkResult> {
         vec![self.run_interpreter(name, code), self.run_vm(name, code), self.run_jit(name, code)]
@@ -132,6 +143,9 @@ impl BenchmarkRunner {
         let ast = parser.parse();
 
         let mut interpreter = Interpreter::new();
+        if let Some(output) = self.discarded_output() {
+            interpreter.set_output(output);
+        }
         interpreter.eval_stmts(&ast);
 
         Ok(())
@@ -157,6 +171,9 @@ impl BenchmarkRunner {
         let mut vm = VM::new();
         self.configure_vm_globals(&mut vm);
         vm.set_jit_enabled(false);
+        if let Some(output) = self.discarded_output() {
+            vm.set_output(output);
+        }
 
         vm.execute(chunk).map_err(|e| format!("VM error: {:?}", e))?;
 
@@ -183,6 +200,9 @@ impl BenchmarkRunner {
         let mut vm = VM::new();
         self.configure_vm_globals(&mut vm);
         vm.set_jit_enabled(true);
+        if let Some(output) = self.discarded_output() {
+            vm.set_output(output);
+        }
 
         vm.execute(chunk).map_err(|e| format!("JIT error: {:?}", e))?;
 
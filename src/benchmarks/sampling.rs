@@ -0,0 +1,185 @@
+// Sampling profiler for Ruff programs on the bytecode VM
+//
+// A ticker thread counts elapsed sampling periods; the VM dispatch loop drains that
+// count and charges it to the Ruff call stack it is executing, so profiles show Ruff
+// functions and lines rather than host frames. Object-constructing instructions are
+// attributed to the same stacks for the allocation profile. Both export as pprof.
+
+use crate::benchmarks::pprof::{PprofBuilder, PprofSample};
+use crate::errors::StackFrame;
+use std::collections::HashMap;
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::Arc;
+use std::thread::JoinHandle;
+use std::time::{Duration, Instant, SystemTime, UNIX_EPOCH};
+
+/// Default CPU sampling period
+pub const DEFAULT_SAMPLE_INTERVAL: Duration = Duration::from_millis(1);
+
+type StackKey = Vec<(String, usize)>;
+
+/// Collects CPU samples and allocation counts keyed by Ruff call stack
+pub struct SamplingProfiler {
+    interval: Duration,
+    pending_ticks: Arc<AtomicU64>,
+    stop: Arc<AtomicBool>,
+    ticker: Option<JoinHandle<()>>,
+    cpu_samples: Option<HashMap<StackKey, u64>>,
+    allocations: Option<HashMap<(StackKey, &'static str), u64>>,
+    started_at: SystemTime,
+    started: Instant,
+    duration: Option<Duration>,
+}
+
+impl SamplingProfiler {
+    /// Starts profiling. CPU sampling spawns the ticker thread; allocation tracking
+    /// only counts the events the VM reports.
+    pub fn start(cpu: bool, allocations: bool, interval: Duration) -> Self {
+        let pending_ticks = Arc::new(AtomicU64::new(0));
+        let stop = Arc::new(AtomicBool::new(false));
+        let ticker = cpu.then(|| {
+            let pending_ticks = Arc::clone(&pending_ticks);
+            let stop = Arc::clone(&stop);
+            std::thread::Builder::new()
+                .name("ruff-profiler".to_string())
+                .spawn(move || {
+                    while !stop.load(Ordering::Relaxed) {
+                        std::thread::sleep(interval);
+                        pending_ticks.fetch_add(1, Ordering::Relaxed);
+                    }
+                })
+                .ok()
+        });
+
+        Self {
+            interval,
+            pending_ticks,
+            stop,
+            ticker: ticker.flatten(),
+            cpu_samples: cpu.then(HashMap::new),
+            allocations: allocations.then(HashMap::new),
+            started_at: SystemTime::now(),
+            started: Instant::now(),
+            duration: None,
+        }
+    }
+
+    /// Whether at least one sampling period elapsed since the last drain
+    pub fn samples_due(&self) -> bool {
+        self.pending_ticks.load(Ordering::Relaxed) > 0
+    }
+
+    pub fn tracks_allocations(&self) -> bool {
+        self.allocations.is_some()
+    }
+
+    /// Charges every elapsed sampling period to `stack`
+    pub fn record_cpu_samples(&mut self, stack: &[StackFrame]) {
+        let ticks = self.pending_ticks.swap(0, Ordering::Relaxed);
+        if let Some(samples) = self.cpu_samples.as_mut() {
+            if ticks > 0 {
+                *samples.entry(stack_key(stack)).or_insert(0) += ticks;
+            }
+        }
+    }
+
+    /// Counts one `kind` object (e.g. `"array"`) constructed at `stack`
+    pub fn record_allocation(&mut self, stack: &[StackFrame], kind: &'static str) {
+        if let Some(allocations) = self.allocations.as_mut() {
+            *allocations.entry((stack_key(stack), kind)).or_insert(0) += 1;
+        }
+    }
+
+    /// Stops the ticker; later samples are ignored
+    pub fn finish(&mut self) {
+        self.stop.store(true, Ordering::Relaxed);
+        if let Some(ticker) = self.ticker.take() {
+            let _ = ticker.join();
+        }
+        self.duration.get_or_insert_with(|| self.started.elapsed());
+    }
+
+    /// CPU profile with `samples/count` and `cpu/nanoseconds` values per stack
+    pub fn cpu_pprof(&self, filename: &str) -> Option<Vec<u8>> {
+        let samples = self.cpu_samples.as_ref()?;
+        let period = self.interval.as_nanos() as i64;
+        let mut builder = self
+            .builder(filename, &[("samples", "count"), ("cpu", "nanoseconds")])
+            .with_period("cpu", "nanoseconds", period);
+        for (frames, count) in sorted(samples) {
+            let count = *count as i64;
+            builder.add_sample(&PprofSample {
+                frames: frames.clone(),
+                values: vec![count, count * period],
+                labels: Vec::new(),
+            });
+        }
+        Some(builder.encode())
+    }
+
+    /// Allocation profile with `alloc_objects/count` per stack, labelled by object kind
+    pub fn alloc_pprof(&self, filename: &str) -> Option<Vec<u8>> {
+        let allocations = self.allocations.as_ref()?;
+        let mut builder = self.builder(filename, &[("alloc_objects", "count")]);
+        for ((frames, kind), count) in sorted(allocations) {
+            builder.add_sample(&PprofSample {
+                frames: frames.clone(),
+                values: vec![*count as i64],
+                labels: vec![("object".to_string(), kind.to_string())],
+            });
+        }
+        Some(builder.encode())
+    }
+
+    fn builder(&self, filename: &str, sample_types: &[(&str, &str)]) -> PprofBuilder {
+        let time_nanos =
+            self.started_at.duration_since(UNIX_EPOCH).map(|since| since.as_nanos()).unwrap_or(0);
+        let duration = self.duration.unwrap_or_else(|| self.started.elapsed());
+        PprofBuilder::new(filename, sample_types)
+            .with_timing(time_nanos as i64, duration.as_nanos() as i64)
+    }
+}
+
+impl Drop for SamplingProfiler {
+    fn drop(&mut self) {
+        self.finish();
+    }
+}
+
+fn stack_key(stack: &[StackFrame]) -> StackKey {
+    stack
+        .iter()
+        .map(|frame| (frame.function.clone(), frame.position.map(|(line, _)| line).unwrap_or(0)))
+        .collect()
+}
+
+/// Entries ordered by stack so written profiles are reproducible
+fn sorted<K: Ord, V>(entries: &HashMap<K, V>) -> Vec<(&K, &V)> {
+    let mut entries: Vec<_> = entries.iter().collect();
+    entries.sort_by(|left, right| left.0.cmp(right.0));
+    entries
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn cpu_samples_drain_pending_ticks_into_the_current_stack() {
+        let mut profiler = SamplingProfiler::start(false, true, DEFAULT_SAMPLE_INTERVAL);
+        profiler.cpu_samples = Some(HashMap::new());
+        profiler.pending_ticks.store(3, Ordering::Relaxed);
+        assert!(profiler.samples_due());
+
+        let stack = vec![StackFrame::new("<main>", Some((4, 1))), StackFrame::new("work", None)];
+        profiler.record_cpu_samples(&stack);
+        profiler.record_allocation(&stack, "array");
+        profiler.record_allocation(&stack, "array");
+
+        assert!(!profiler.samples_due());
+        let key = vec![("<main>".to_string(), 4), ("work".to_string(), 0)];
+        assert_eq!(profiler.cpu_samples.as_ref().unwrap()[&key], 3);
+        assert_eq!(profiler.allocations.as_ref().unwrap()[&(key, "array")], 2);
+        assert!(profiler.alloc_pprof("main.ruff").is_some());
+    }
+}
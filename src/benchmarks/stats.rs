@@ -27,6 +27,15 @@ impl Statistics {
         Some(Self { mean, median, min, max, stddev, samples: samples.len() })
     }
 
+    /// Calls per second implied by the mean sample time
+    pub fn ops_per_sec(&self) -> f64 {
+        let mean_nanos = self.mean.as_nanos() as f64;
+        if mean_nanos == 0.0 {
+            return 0.0;
+        }
+        1_000_000_000.0 / mean_nanos
+    }
+
     pub fn format_duration(duration: Duration) -> String {
         let nanos = duration.as_nanos();
         if nanos < 1_000 {
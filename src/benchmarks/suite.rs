@@ -0,0 +1,572 @@
+// Cross-language benchmark suite: `ruff bench-suite`.
+//
+// Runs the `bench.*` programs in benchmarks/cross-language once per language and run.
+// Each program prints `<ID>_MS=<milliseconds>` and `<ID>_RESULT=<value>` for every
+// benchmark it times, so adding a benchmark needs no change here. Ruff is required; Go,
+// Python, and Node are skipped when their toolchain or script is missing. The report
+// holds per-language statistics, Ruff's time relative to each other language, results
+// that disagree between languages, and Ruff slowdowns against a baseline report.
+
+use super::stats::Statistics;
+use std::io;
+use std::path::{Path, PathBuf};
+use std::process::Command;
+use std::time::Duration;
+
+/// Version of the JSON report; bumped when a field is removed or changes meaning.
+pub const SUITE_SCHEMA_VERSION: u32 = 1;
+
+const RUFF: &str = "ruff";
+
+const LANGUAGES: [(&str, &str); 4] =
+    [(RUFF, "bench.ruff"), ("go", "bench.go"), ("python", "bench.py"), ("node", "bench.js")];
+
+pub struct SuiteOptions<'a> {
+    /// Directory holding the `bench.*` programs
+    pub dir: &'a Path,
+    pub ruff_binary: &'a Path,
+    pub python: &'a str,
+    pub runs: usize,
+    pub warmup_runs: usize,
+}
+
+#[derive(Debug, Clone)]
+pub struct SuiteLanguage {
+    pub name: String,
+    pub script: String,
+    /// Why the language did not run, such as a missing toolchain
+    pub skipped: Option<String>,
+}
+
+#[derive(Debug, Clone)]
+pub struct SuiteBenchmark {
+    pub id: String,
+    /// Millisecond samples per language, in language order
+    pub samples: Vec<(String, Vec<f64>)>,
+    /// Last reported result per language
+    pub results: Vec<(String, String)>,
+}
+
+impl SuiteBenchmark {
+    pub fn statistics(&self, language: &str) -> Option<Statistics> {
+        let (_, samples) = self.samples.iter().find(|(name, _)| name == language)?;
+        let durations: Vec<Duration> =
+            samples.iter().map(|ms| Duration::from_secs_f64(ms.max(0.0) / 1000.0)).collect();
+        Statistics::from_samples(&durations)
+    }
+
+    pub fn median_ms(&self, language: &str) -> Option<f64> {
+        self.statistics(language).map(|stats| millis(stats.median))
+    }
+
+    /// Ruff's median time divided by `language`'s; below 1.0 means Ruff is faster.
+    pub fn ruff_ratio(&self, language: &str) -> Option<f64> {
+        let other = self.median_ms(language)?;
+        if other <= 0.0 {
+            return None;
+        }
+        self.median_ms(RUFF).map(|ruff| ruff / other)
+    }
+
+    pub fn results_match(&self) -> bool {
+        self.results.windows(2).all(|pair| pair[0].1 == pair[1].1)
+    }
+}
+
+#[derive(Debug, Clone)]
+pub struct SuiteRegression {
+    pub benchmark: String,
+    pub baseline_median_ms: f64,
+    pub median_ms: f64,
+    pub change_percent: f64,
+}
+
+#[derive(Debug, Clone)]
+pub struct SuiteReport {
+    pub runs: usize,
+    pub warmup_runs: usize,
+    pub languages: Vec<SuiteLanguage>,
+    pub benchmarks: Vec<SuiteBenchmark>,
+    /// Set once the report is compared against a baseline
+    pub max_regression_percent: Option<f64>,
+    pub regressions: Vec<SuiteRegression>,
+}
+
+/// Run every available language's suite `warmup_runs + runs` times, keeping the last
+/// `runs` measurements.
+pub fn run_benchmark_suite(options: &SuiteOptions) -> Result<SuiteReport, String> {
+    let mut report = SuiteReport {
+        runs: options.runs,
+        warmup_runs: options.warmup_runs,
+        languages: Vec::new(),
+        benchmarks: Vec::new(),
+        max_regression_percent: None,
+        regressions: Vec::new(),
+    };
+
+    for (name, script) in LANGUAGES {
+        let mut language =
+            SuiteLanguage { name: name.to_string(), script: script.to_string(), skipped: None };
+        if !options.dir.join(script).exists() {
+            if name == RUFF {
+                return Err(format!("{} not found in {}", script, options.dir.display()));
+            }
+            language.skipped = Some(format!("{} not found", script));
+            report.languages.push(language);
+            continue;
+        }
+
+        let Some(mut command) = language_command(name, script, options)? else {
+            language.skipped = Some(format!("{} toolchain not found", name));
+            report.languages.push(language);
+            continue;
+        };
+
+        for run in 0..options.warmup_runs + options.runs {
+            let Some(output) = capture(&mut command)? else {
+                language.skipped = Some(format!("{} toolchain not found", name));
+                break;
+            };
+            if run >= options.warmup_runs {
+                report.record(name, &output)?;
+            }
+        }
+        if name == "go" {
+            let _ = std::fs::remove_file(go_binary_path());
+        }
+        if language.skipped.is_some() && name == RUFF {
+            return Err(format!("Failed to run '{}'", options.ruff_binary.display()));
+        }
+        report.languages.push(language);
+    }
+
+    Ok(report)
+}
+
+/// The command that runs one language's suite, or `None` when its toolchain is missing.
+/// Go's program is compiled first so that build time stays out of the measurements.
+fn language_command(
+    name: &str,
+    script: &str,
+    options: &SuiteOptions,
+) -> Result<Option<Command>, String> {
+    let mut command = match name {
+        RUFF => {
+            let mut command = Command::new(options.ruff_binary);
+            command.arg("run").arg(script);
+            command
+        }
+        "go" => {
+            let binary = go_binary_path();
+            let mut build = Command::new("go");
+            build.arg("build").arg("-o").arg(&binary).arg(script).current_dir(options.dir);
+            if capture(&mut build)?.is_none() {
+                return Ok(None);
+            }
+            Command::new(binary)
+        }
+        "python" => {
+            let mut command = Command::new(options.python);
+            command.arg(script);
+            command
+        }
+        _ => {
+            let mut command = Command::new(name);
+            command.arg(script);
+            command
+        }
+    };
+    command.current_dir(options.dir);
+    Ok(Some(command))
+}
+
+fn go_binary_path() -> PathBuf {
+    std::env::temp_dir().join(format!("ruff-bench-suite-go-{}", std::process::id()))
+}
+
+/// Stdout of `command`, or `None` when its program does not exist.
+fn capture(command: &mut Command) -> Result<Option<String>, String> {
+    let program = command.get_program().to_string_lossy().to_string();
+    let output = match command.output() {
+        Ok(output) => output,
+        Err(e) if e.kind() == io::ErrorKind::NotFound => return Ok(None),
+        Err(e) => return Err(format!("Failed to run '{}': {}", program, e)),
+    };
+
+    if !output.status.success() {
+        return Err(format!(
+            "Command '{}' failed with status {:?}:\n{}{}",
+            program,
+            output.status.code(),
+            String::from_utf8_lossy(&output.stdout),
+            String::from_utf8_lossy(&output.stderr)
+        ));
+    }
+    Ok(Some(String::from_utf8_lossy(&output.stdout).to_string()))
+}
+
+/// `<ID>_MS` timings and `<ID>_RESULT` values printed by a suite program, with ids
+/// lowercased, in output order.
+fn parse_suite_output(output: &str) -> Result<(Vec<(String, f64)>, Vec<(String, String)>), String> {
+    let mut timings = Vec::new();
+    let mut results = Vec::new();
+    for line in output.lines() {
+        let Some((key, value)) = line.trim().split_once('=') else {
+            continue;
+        };
+        let key = key.trim();
+        if let Some(id) = key.strip_suffix("_MS") {
+            let ms = value.trim().parse::<f64>().map_err(|e| {
+                format!("Metric '{}' had invalid numeric value '{}': {}", key, value, e)
+            })?;
+            timings.push((id.to_ascii_lowercase(), ms));
+        } else if let Some(id) = key.strip_suffix("_RESULT") {
+            results.push((id.to_ascii_lowercase(), value.trim().to_string()));
+        }
+    }
+    Ok((timings, results))
+}
+
+fn millis(duration: Duration) -> f64 {
+    duration.as_nanos() as f64 / 1_000_000.0
+}
+
+impl SuiteReport {
+    fn record(&mut self, language: &str, output: &str) -> Result<(), String> {
+        let (timings, results) = parse_suite_output(output)?;
+        if timings.is_empty() {
+            return Err(format!("The {} suite printed no <ID>_MS timings", language));
+        }
+        for (id, ms) in timings {
+            let benchmark = self.benchmark_mut(&id);
+            match benchmark.samples.iter_mut().find(|(name, _)| name == language) {
+                Some((_, samples)) => samples.push(ms),
+                None => benchmark.samples.push((language.to_string(), vec![ms])),
+            }
+        }
+        for (id, value) in results {
+            let benchmark = self.benchmark_mut(&id);
+            match benchmark.results.iter_mut().find(|(name, _)| name == language) {
+                Some((_, result)) => *result = value,
+                None => benchmark.results.push((language.to_string(), value)),
+            }
+        }
+        Ok(())
+    }
+
+    fn benchmark_mut(&mut self, id: &str) -> &mut SuiteBenchmark {
+        let index = match self.benchmarks.iter().position(|benchmark| benchmark.id == id) {
+            Some(index) => index,
+            None => {
+                self.benchmarks.push(SuiteBenchmark {
+                    id: id.to_string(),
+                    samples: Vec::new(),
+                    results: Vec::new(),
+                });
+                self.benchmarks.len() - 1
+            }
+        };
+        &mut self.benchmarks[index]
+    }
+
+    fn ran(&self) -> impl Iterator<Item = &SuiteLanguage> {
+        self.languages.iter().filter(|language| language.skipped.is_none())
+    }
+
+    /// Record the benchmarks whose Ruff median grew by more than `max_regression_percent`
+    /// over the Ruff median in `baseline`, a JSON report from an earlier run.
+    pub fn compare_with_baseline(
+        &mut self,
+        baseline: &serde_json::Value,
+        max_regression_percent: f64,
+    ) -> Result<(), String> {
+        if !max_regression_percent.is_finite() || max_regression_percent < 0.0 {
+            return Err(format!(
+                "Regression threshold must be finite and >= 0.0%, got {}",
+                max_regression_percent
+            ));
+        }
+        let entries = baseline["benchmarks"]
+            .as_array()
+            .ok_or_else(|| "Baseline report has no 'benchmarks' array".to_string())?;
+
+        self.max_regression_percent = Some(max_regression_percent);
+        self.regressions.clear();
+        for benchmark in &self.benchmarks {
+            let Some(median_ms) = benchmark.median_ms(RUFF) else {
+                continue;
+            };
+            let baseline_median_ms = entries
+                .iter()
+                .find(|entry| entry["id"] == benchmark.id.as_str())
+                .and_then(|entry| entry["timings"][RUFF]["median_ms"].as_f64());
+            let Some(baseline_median_ms) = baseline_median_ms.filter(|ms| *ms > 0.0) else {
+                continue;
+            };
+            let change_percent = (median_ms - baseline_median_ms) / baseline_median_ms * 100.0;
+            if change_percent > max_regression_percent {
+                self.regressions.push(SuiteRegression {
+                    benchmark: benchmark.id.clone(),
+                    baseline_median_ms,
+                    median_ms,
+                    change_percent,
+                });
+            }
+        }
+        Ok(())
+    }
+
+    pub fn render_json(&self) -> serde_json::Value {
+        let languages: Vec<serde_json::Value> = self
+            .languages
+            .iter()
+            .map(|language| {
+                serde_json::json!({
+                    "name": language.name,
+                    "script": language.script,
+                    "status": if language.skipped.is_some() { "skipped" } else { "ran" },
+                    "skipped_reason": language.skipped,
+                })
+            })
+            .collect();
+
+        let benchmarks: Vec<serde_json::Value> = self
+            .benchmarks
+            .iter()
+            .map(|benchmark| {
+                let mut timings = serde_json::Map::new();
+                let mut ruff_ratio = serde_json::Map::new();
+                for (language, samples) in &benchmark.samples {
+                    let Some(stats) = benchmark.statistics(language) else {
+                        continue;
+                    };
+                    timings.insert(
+                        language.clone(),
+                        serde_json::json!({
+                            "mean_ms": millis(stats.mean),
+                            "median_ms": millis(stats.median),
+                            "min_ms": millis(stats.min),
+                            "max_ms": millis(stats.max),
+                            "stddev_ms": millis(stats.stddev),
+                            "samples_ms": samples,
+                        }),
+                    );
+                    if language != RUFF {
+                        if let Some(ratio) = benchmark.ruff_ratio(language) {
+                            ruff_ratio.insert(language.clone(), serde_json::json!(ratio));
+                        }
+                    }
+                }
+                let results: serde_json::Map<String, serde_json::Value> = benchmark
+                    .results
+                    .iter()
+                    .map(|(language, value)| (language.clone(), serde_json::json!(value)))
+                    .collect();
+                serde_json::json!({
+                    "id": benchmark.id,
+                    "timings": timings,
+                    "ruff_ratio": ruff_ratio,
+                    "results": results,
+                    "results_match": benchmark.results_match(),
+                })
+            })
+            .collect();
+
+        let regressions: Vec<serde_json::Value> = self
+            .regressions
+            .iter()
+            .map(|regression| {
+                serde_json::json!({
+                    "benchmark": regression.benchmark,
+                    "baseline_median_ms": regression.baseline_median_ms,
+                    "median_ms": regression.median_ms,
+                    "change_percent": regression.change_percent,
+                })
+            })
+            .collect();
+
+        serde_json::json!({
+            "schema_version": SUITE_SCHEMA_VERSION,
+            "runs": self.runs,
+            "warmup_runs": self.warmup_runs,
+            "languages": languages,
+            "benchmarks": benchmarks,
+            "max_regression_percent": self.max_regression_percent,
+            "regressions": regressions,
+        })
+    }
+
+    pub fn render_markdown(&self) -> String {
+        let languages: Vec<&str> = self.ran().map(|language| language.name.as_str()).collect();
+        let others: Vec<&str> =
+            languages.iter().copied().filter(|language| *language != RUFF).collect();
+
+        let mut out = String::from("# Cross-language benchmark suite\n\n");
+        out.push_str(&format!(
+            "Median of {} run{} per language, in milliseconds (± standard deviation). \
+             `ruff / X` is Ruff's median divided by X's; below 1.00x Ruff is faster.\n\n",
+            self.runs,
+            if self.runs == 1 { "" } else { "s" }
+        ));
+
+        let mut header = vec!["Benchmark".to_string()];
+        header.extend(languages.iter().map(|language| language.to_string()));
+        header.extend(others.iter().map(|language| format!("ruff / {}", language)));
+        out.push_str(&format!("| {} |\n", header.join(" | ")));
+        out.push_str(&format!("| --- |{}\n", " ---: |".repeat(header.len() - 1)));
+
+        for benchmark in &self.benchmarks {
+            let mut row = vec![benchmark.id.clone()];
+            for language in &languages {
+                row.push(match benchmark.statistics(language) {
+                    Some(stats) => {
+                        format!("{:.2} ± {:.2}", millis(stats.median), millis(stats.stddev))
+                    }
+                    None => "-".to_string(),
+                });
+            }
+            for language in &others {
+                row.push(match benchmark.ruff_ratio(language) {
+                    Some(ratio) => format!("{:.2}x", ratio),
+                    None => "-".to_string(),
+                });
+            }
+            out.push_str(&format!("| {} |\n", row.join(" | ")));
+        }
+
+        let skipped: Vec<String> = self
+            .languages
+            .iter()
+            .filter_map(|language| {
+                language.skipped.as_ref().map(|reason| format!("{} ({})", language.name, reason))
+            })
+            .collect();
+        if !skipped.is_empty() {
+            out.push_str(&format!("\nSkipped: {}\n", skipped.join(", ")));
+        }
+
+        let mismatches: Vec<&SuiteBenchmark> =
+            self.benchmarks.iter().filter(|benchmark| !benchmark.results_match()).collect();
+        if !mismatches.is_empty() {
+            out.push_str("\nResults differ between languages:\n\n");
+            for benchmark in mismatches {
+                let results: Vec<String> = benchmark
+                    .results
+                    .iter()
+                    .map(|(language, value)| format!("{}={}", language, value))
+                    .collect();
+                out.push_str(&format!("- {}: {}\n", benchmark.id, results.join(", ")));
+            }
+        }
+
+        if let Some(threshold) = self.max_regression_percent {
+            if self.regressions.is_empty() {
+                out.push_str(&format!(
+                    "\nNo Ruff regressions above {:.1}% against the baseline.\n",
+                    threshold
+                ));
+            } else {
+                out.push_str(&format!(
+                    "\nRuff regressions above {:.1}% against the baseline:\n\n",
+                    threshold
+                ));
+                for regression in &self.regressions {
+                    out.push_str(&format!(
+                        "- {}: {:.2} ms -> {:.2} ms ({:+.1}%)\n",
+                        regression.benchmark,
+                        regression.baseline_median_ms,
+                        regression.median_ms,
+                        regression.change_percent
+                    ));
+                }
+            }
+        }
+        out
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn report_from(outputs: &[(&str, &str)]) -> SuiteReport {
+        let mut report = SuiteReport {
+            runs: 2,
+            warmup_runs: 0,
+            languages: Vec::new(),
+            benchmarks: Vec::new(),
+            max_regression_percent: None,
+            regressions: Vec::new(),
+        };
+        for (language, output) in outputs {
+            if !report.languages.iter().any(|known| known.name == *language) {
+                report.languages.push(SuiteLanguage {
+                    name: language.to_string(),
+                    script: format!("bench.{}", language),
+                    skipped: None,
+                });
+            }
+            report.record(language, output).expect("suite output should parse");
+        }
+        report
+    }
+
+    #[test]
+    fn parse_suite_output_collects_timings_and_results_in_order() {
+        let output = "noise\nFIB_MS=12.5\nFIB_RESULT=832040\nLOOPS_MS = 3\nOTHER=1\n";
+        let (timings, results) = parse_suite_output(output).unwrap();
+        assert_eq!(timings, vec![("fib".to_string(), 12.5), ("loops".to_string(), 3.0)]);
+        assert_eq!(results, vec![("fib".to_string(), "832040".to_string())]);
+        assert!(parse_suite_output("FIB_MS=fast").is_err());
+    }
+
+    #[test]
+    fn report_computes_ratios_and_flags_result_mismatches() {
+        let report = report_from(&[
+            ("ruff", "FIB_MS=30\nFIB_RESULT=55\nSUM_MS=8\nSUM_RESULT=10"),
+            ("ruff", "FIB_MS=10\nFIB_RESULT=55\nSUM_MS=4\nSUM_RESULT=10"),
+            ("go", "FIB_MS=4\nFIB_RESULT=55\nSUM_MS=2\nSUM_RESULT=11"),
+            ("go", "FIB_MS=6\nFIB_RESULT=55\nSUM_MS=2\nSUM_RESULT=11"),
+        ]);
+
+        let fib = &report.benchmarks[0];
+        assert_eq!(fib.id, "fib");
+        assert!((fib.median_ms("ruff").unwrap() - 20.0).abs() < 1e-6);
+        assert!((fib.ruff_ratio("go").unwrap() - 4.0).abs() < 1e-6);
+        assert!(fib.results_match());
+        assert!(!report.benchmarks[1].results_match());
+
+        let json = report.render_json();
+        assert_eq!(json["schema_version"], SUITE_SCHEMA_VERSION);
+        assert_eq!(
+            json["benchmarks"][0]["timings"]["go"]["samples_ms"],
+            serde_json::json!([4.0, 6.0])
+        );
+        assert_eq!(json["benchmarks"][1]["results_match"], false);
+
+        let markdown = report.render_markdown();
+        assert!(markdown.contains("| Benchmark | ruff | go | ruff / go |"));
+        assert!(markdown.contains("| fib | 20.00 ± 10.00 | 5.00 ± 1.00 | 4.00x |"));
+        assert!(markdown.contains("- sum: ruff=10, go=11"));
+    }
+
+    #[test]
+    fn compare_with_baseline_reports_ruff_slowdowns_over_the_threshold() {
+        let mut report = report_from(&[("ruff", "FIB_MS=12\nSUM_MS=10.5\nNEW_MS=1")]);
+        let baseline = serde_json::json!({
+            "benchmarks": [
+                { "id": "fib", "timings": { "ruff": { "median_ms": 10.0 } } },
+                { "id": "sum", "timings": { "ruff": { "median_ms": 10.0 } } },
+            ]
+        });
+
+        report.compare_with_baseline(&baseline, 10.0).unwrap();
+        assert_eq!(report.regressions.len(), 1);
+        assert_eq!(report.regressions[0].benchmark, "fib");
+        assert!((report.regressions[0].change_percent - 20.0).abs() < 1e-6);
+        assert!(report.render_markdown().contains("- fib: 10.00 ms -> 12.00 ms (+20.0%)"));
+
+        assert!(report.compare_with_baseline(&serde_json::json!({}), 10.0).is_err());
+        assert!(report.compare_with_baseline(&baseline, -1.0).is_err());
+    }
+}
@@ -0,0 +1,436 @@
+// File: src/bigint.rs
+//
+// Arbitrary-precision signed integers used when Ruff `int` arithmetic
+// overflows 64 bits. Magnitudes are little-endian base-2^32 limbs.
+
+use std::cmp::Ordering;
+use std::fmt;
+
+const DECIMAL_CHUNK: u32 = 1_000_000_000;
+const DECIMAL_CHUNK_DIGITS: usize = 9;
+
+/// Signed integer of unbounded size.
+///
+/// The magnitude never carries high zero limbs and zero is never negative,
+/// so derived equality and hashing compare values rather than layouts.
+#[derive(Clone, Debug, PartialEq, Eq, Hash)]
+pub struct BigInt {
+    negative: bool,
+    magnitude: Vec<u32>,
+}
+
+impl BigInt {
+    fn from_parts(negative: bool, mut magnitude: Vec<u32>) -> Self {
+        trim(&mut magnitude);
+        let negative = negative && !magnitude.is_empty();
+        BigInt { negative, magnitude }
+    }
+
+    pub fn from_i64(value: i64) -> Self {
+        let abs = value.unsigned_abs();
+        Self::from_parts(value < 0, vec![abs as u32, (abs >> 32) as u32])
+    }
+
+    /// Returns the value as `i64` when it fits.
+    pub fn to_i64(&self) -> Option<i64> {
+        if self.magnitude.len() > 2 {
+            return None;
+        }
+        let abs =
+            self.magnitude.iter().rev().fold(0u64, |acc, limb| (acc << 32) | u64::from(*limb));
+        if self.negative {
+            if abs <= i64::MIN.unsigned_abs() {
+                Some((abs as i64).wrapping_neg())
+            } else {
+                None
+            }
+        } else {
+            i64::try_from(abs).ok()
+        }
+    }
+
+    /// Nearest `f64`, saturating to infinity for very large magnitudes.
+    pub fn to_f64(&self) -> f64 {
+        let abs = self
+            .magnitude
+            .iter()
+            .rev()
+            .fold(0.0f64, |acc, limb| acc * 4_294_967_296.0 + f64::from(*limb));
+        if self.negative {
+            -abs
+        } else {
+            abs
+        }
+    }
+
+    /// Parses an optionally signed string of ASCII decimal digits.
+    pub fn parse(text: &str) -> Option<Self> {
+        let (negative, digits) = match text.as_bytes().first() {
+            Some(b'-') => (true, &text[1..]),
+            Some(b'+') => (false, &text[1..]),
+            _ => (false, text),
+        };
+        if digits.is_empty() || !digits.bytes().all(|byte| byte.is_ascii_digit()) {
+            return None;
+        }
+
+        let mut magnitude = Vec::new();
+        let head = digits.len() % DECIMAL_CHUNK_DIGITS;
+        let mut start = 0;
+        let mut end = if head == 0 { DECIMAL_CHUNK_DIGITS } else { head };
+        while start < digits.len() {
+            let chunk = &digits[start..end];
+            let value: u32 = chunk.parse().ok()?;
+            mul_small_add(&mut magnitude, 10u32.pow(chunk.len() as u32), value);
+            start = end;
+            end += DECIMAL_CHUNK_DIGITS;
+        }
+        Some(Self::from_parts(negative, magnitude))
+    }
+
+    pub fn is_negative(&self) -> bool {
+        self.negative
+    }
+
+    pub fn neg(&self) -> Self {
+        Self::from_parts(!self.negative, self.magnitude.clone())
+    }
+
+    pub fn add(&self, other: &Self) -> Self {
+        if self.negative == other.negative {
+            return Self::from_parts(
+                self.negative,
+                add_magnitudes(&self.magnitude, &other.magnitude),
+            );
+        }
+        match compare_magnitudes(&self.magnitude, &other.magnitude) {
+            Ordering::Equal => Self::from_parts(false, Vec::new()),
+            Ordering::Greater => {
+                Self::from_parts(self.negative, sub_magnitudes(&self.magnitude, &other.magnitude))
+            }
+            Ordering::Less => {
+                Self::from_parts(other.negative, sub_magnitudes(&other.magnitude, &self.magnitude))
+            }
+        }
+    }
+
+    pub fn sub(&self, other: &Self) -> Self {
+        self.add(&other.neg())
+    }
+
+    pub fn mul(&self, other: &Self) -> Self {
+        Self::from_parts(
+            self.negative != other.negative,
+            mul_magnitudes(&self.magnitude, &other.magnitude),
+        )
+    }
+
+    /// Truncating division matching `i64` `/` and `%`: the quotient rounds
+    /// toward zero and the remainder takes the dividend's sign. Returns
+    /// `None` when `other` is zero.
+    pub fn div_rem(&self, other: &Self) -> Option<(Self, Self)> {
+        if other.magnitude.is_empty() {
+            return None;
+        }
+        let (quotient, remainder) = div_rem_magnitudes(&self.magnitude, &other.magnitude);
+        Some((
+            Self::from_parts(self.negative != other.negative, quotient),
+            Self::from_parts(self.negative, remainder),
+        ))
+    }
+}
+
+impl Ord for BigInt {
+    fn cmp(&self, other: &Self) -> Ordering {
+        match (self.negative, other.negative) {
+            (false, true) => Ordering::Greater,
+            (true, false) => Ordering::Less,
+            (false, false) => compare_magnitudes(&self.magnitude, &other.magnitude),
+            (true, true) => compare_magnitudes(&other.magnitude, &self.magnitude),
+        }
+    }
+}
+
+impl PartialOrd for BigInt {
+    fn partial_cmp(&self, other: &Self) -> Option<Ordering> {
+        Some(self.cmp(other))
+    }
+}
+
+impl fmt::Display for BigInt {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        if self.magnitude.is_empty() {
+            return write!(f, "0");
+        }
+        let mut chunks = Vec::new();
+        let mut rest = self.magnitude.clone();
+        while !rest.is_empty() {
+            let (quotient, remainder) = div_rem_small(&rest, DECIMAL_CHUNK);
+            chunks.push(remainder);
+            rest = quotient;
+        }
+
+        let mut text = String::with_capacity(chunks.len() * DECIMAL_CHUNK_DIGITS + 1);
+        if self.negative {
+            text.push('-');
+        }
+        let mut chunks = chunks.iter().rev();
+        if let Some(first) = chunks.next() {
+            text.push_str(&first.to_string());
+        }
+        for chunk in chunks {
+            text.push_str(&format!("{:09}", chunk));
+        }
+        f.write_str(&text)
+    }
+}
+
+fn trim(magnitude: &mut Vec<u32>) {
+    while magnitude.last() == Some(&0) {
+        magnitude.pop();
+    }
+}
+
+fn compare_magnitudes(left: &[u32], right: &[u32]) -> Ordering {
+    left.len().cmp(&right.len()).then_with(|| left.iter().rev().cmp(right.iter().rev()))
+}
+
+fn add_magnitudes(left: &[u32], right: &[u32]) -> Vec<u32> {
+    let (long, short) = if left.len() >= right.len() { (left, right) } else { (right, left) };
+    let mut result = Vec::with_capacity(long.len() + 1);
+    let mut carry = 0u64;
+    for (index, limb) in long.iter().enumerate() {
+        let sum = u64::from(*limb) + u64::from(short.get(index).copied().unwrap_or(0)) + carry;
+        result.push(sum as u32);
+        carry = sum >> 32;
+    }
+    if carry != 0 {
+        result.push(carry as u32);
+    }
+    result
+}
+
+/// `left - right` for magnitudes with `left >= right`.
+fn sub_magnitudes(left: &[u32], right: &[u32]) -> Vec<u32> {
+    let mut result = Vec::with_capacity(left.len());
+    let mut borrow = 0i64;
+    for (index, limb) in left.iter().enumerate() {
+        let diff = i64::from(*limb) - i64::from(right.get(index).copied().unwrap_or(0)) - borrow;
+        result.push(diff as u32);
+        borrow = i64::from(diff < 0);
+    }
+    trim(&mut result);
+    result
+}
+
+fn mul_magnitudes(left: &[u32], right: &[u32]) -> Vec<u32> {
+    if left.is_empty() || right.is_empty() {
+        return Vec::new();
+    }
+    let mut result = vec![0u32; left.len() + right.len()];
+    for (i, a) in left.iter().enumerate() {
+        let mut carry = 0u64;
+        for (j, b) in right.iter().enumerate() {
+            let product = u64::from(*a) * u64::from(*b) + u64::from(result[i + j]) + carry;
+            result[i + j] = product as u32;
+            carry = product >> 32;
+        }
+        result[i + right.len()] = carry as u32;
+    }
+    trim(&mut result);
+    result
+}
+
+fn mul_small_add(magnitude: &mut Vec<u32>, factor: u32, addend: u32) {
+    let mut carry = u64::from(addend);
+    for limb in magnitude.iter_mut() {
+        let product = u64::from(*limb) * u64::from(factor) + carry;
+        *limb = product as u32;
+        carry = product >> 32;
+    }
+    if carry != 0 {
+        magnitude.push(carry as u32);
+    }
+}
+
+fn div_rem_small(magnitude: &[u32], divisor: u32) -> (Vec<u32>, u32) {
+    let mut quotient = vec![0u32; magnitude.len()];
+    let mut remainder = 0u64;
+    for (index, limb) in magnitude.iter().enumerate().rev() {
+        let current = (remainder << 32) | u64::from(*limb);
+        quotient[index] = (current / u64::from(divisor)) as u32;
+        remainder = current % u64::from(divisor);
+    }
+    trim(&mut quotient);
+    (quotient, remainder as u32)
+}
+
+fn shift_left(magnitude: &[u32], shift: u32) -> Vec<u32> {
+    let mut result = Vec::with_capacity(magnitude.len() + 1);
+    let mut carry = 0u32;
+    for limb in magnitude {
+        if shift == 0 {
+            result.push(*limb);
+        } else {
+            result.push((limb << shift) | carry);
+            carry = limb >> (32 - shift);
+        }
+    }
+    result.push(carry);
+    result
+}
+
+fn shift_right(magnitude: &[u32], shift: u32) -> Vec<u32> {
+    let mut result = magnitude.to_vec();
+    if shift != 0 {
+        for index in 0..result.len() {
+            let high = result.get(index + 1).copied().unwrap_or(0);
+            result[index] = (result[index] >> shift) | (high << (32 - shift));
+        }
+    }
+    trim(&mut result);
+    result
+}
+
+/// Long division of magnitudes (Knuth, TAOCP vol. 2, algorithm D).
+fn div_rem_magnitudes(dividend: &[u32], divisor: &[u32]) -> (Vec<u32>, Vec<u32>) {
+    if compare_magnitudes(dividend, divisor) == Ordering::Less {
+        return (Vec::new(), dividend.to_vec());
+    }
+    if divisor.len() == 1 {
+        let (quotient, remainder) = div_rem_small(dividend, divisor[0]);
+        let remainder = if remainder == 0 { Vec::new() } else { vec![remainder] };
+        return (quotient, remainder);
+    }
+
+    // Normalize so the divisor's top limb has its high bit set; this keeps
+    // each quotient-digit estimate at most two too large.
+    let shift = divisor[divisor.len() - 1].leading_zeros();
+    let mut divisor = shift_left(divisor, shift);
+    divisor.pop();
+    let mut remainder = shift_left(dividend, shift);
+    let n = divisor.len();
+    let m = dividend.len() - n;
+    let base = 1u64 << 32;
+    let top = u64::from(divisor[n - 1]);
+    let next = u64::from(divisor[n - 2]);
+    let mut quotient = vec![0u32; m + 1];
+
+    for j in (0..=m).rev() {
+        let numerator = (u64::from(remainder[j + n]) << 32) | u64::from(remainder[j + n - 1]);
+        let mut estimate = numerator / top;
+        let mut estimate_rem = numerator % top;
+        while estimate >= base
+            || estimate * next > ((estimate_rem << 32) | u64::from(remainder[j + n - 2]))
+        {
+            estimate -= 1;
+            estimate_rem += top;
+            if estimate_rem >= base {
+                break;
+            }
+        }
+
+        let mut borrow = 0i64;
+        let mut carry = 0u64;
+        for i in 0..n {
+            let product = estimate * u64::from(divisor[i]) + carry;
+            carry = product >> 32;
+            let diff = i64::from(remainder[i + j]) - borrow - (product & 0xffff_ffff) as i64;
+            remainder[i + j] = diff as u32;
+            borrow = i64::from(diff < 0);
+        }
+        let diff = i64::from(remainder[j + n]) - borrow - carry as i64;
+        remainder[j + n] = diff as u32;
+
+        if diff < 0 {
+            // The estimate was one too large; add the divisor back.
+            estimate -= 1;
+            let mut carry = 0u64;
+            for i in 0..n {
+                let sum = u64::from(remainder[i + j]) + u64::from(divisor[i]) + carry;
+                remainder[i + j] = sum as u32;
+                carry = sum >> 32;
+            }
+            remainder[j + n] = remainder[j + n].wrapping_add(carry as u32);
+        }
+        quotient[j] = estimate as u32;
+    }
+
+    trim(&mut quotient);
+    remainder.truncate(n);
+    (quotient, shift_right(&remainder, shift))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn big(text: &str) -> BigInt {
+        BigInt::parse(text).expect("valid integer literal")
+    }
+
+    #[test]
+    fn round_trips_i64_boundaries() {
+        for value in [0, 1, -1, i64::MAX, i64::MIN, 4_294_967_296, -4_294_967_297] {
+            let converted = BigInt::from_i64(value);
+            assert_eq!(converted.to_i64(), Some(value));
+            assert_eq!(converted.to_string(), value.to_string());
+        }
+        assert_eq!(big("9223372036854775808").to_i64(), None);
+        assert_eq!(big("-9223372036854775809").to_i64(), None);
+        assert_eq!(big("-0").to_string(), "0");
+        assert!(BigInt::parse("12a").is_none());
+        assert!(BigInt::parse("-").is_none());
+    }
+
+    #[test]
+    fn arithmetic_matches_known_values() {
+        let max = BigInt::from_i64(i64::MAX);
+        assert_eq!(max.add(&BigInt::from_i64(1)).to_string(), "9223372036854775808");
+        assert_eq!(
+            BigInt::from_i64(i64::MIN).sub(&BigInt::from_i64(1)).to_string(),
+            "-9223372036854775809"
+        );
+        assert_eq!(max.mul(&max).to_string(), "85070591730234615847396907784232501249");
+
+        let mut factorial = BigInt::from_i64(1);
+        for n in 2..=30 {
+            factorial = factorial.mul(&BigInt::from_i64(n));
+        }
+        assert_eq!(factorial.to_string(), "265252859812191058636308480000000");
+        assert_eq!(big("123456789").sub(&big("123456789")).to_string(), "0");
+    }
+
+    #[test]
+    fn division_truncates_toward_zero() {
+        let dividend = big("85070591730234615847396907784232501250");
+        let divisor = BigInt::from_i64(i64::MAX);
+        let (quotient, remainder) = dividend.div_rem(&divisor).unwrap();
+        assert_eq!(quotient.to_string(), "9223372036854775807");
+        assert_eq!(remainder.to_string(), "1");
+
+        let (quotient, remainder) = dividend.neg().div_rem(&big("1000000000000000000000")).unwrap();
+        assert_eq!(quotient.to_string(), "-85070591730234615");
+        assert_eq!(remainder.to_string(), "-847396907784232501250");
+
+        let (quotient, remainder) = big("-7").div_rem(&big("2")).unwrap();
+        assert_eq!((quotient.to_string(), remainder.to_string()), ("-3".into(), "-1".into()));
+        assert!(dividend.div_rem(&BigInt::from_i64(0)).is_none());
+
+        // Multi-limb divisor with an exact quotient.
+        let (quotient, remainder) = big("340282366920938463463374607431768211455")
+            .div_rem(&big("18446744073709551617"))
+            .unwrap();
+        assert_eq!(quotient.to_string(), "18446744073709551615");
+        assert_eq!(remainder.to_string(), "0");
+    }
+
+    #[test]
+    fn ordering_accounts_for_sign_and_length() {
+        let values = ["-100000000000000000000", "-5", "0", "7", "100000000000000000000"];
+        for window in values.windows(2) {
+            assert!(big(window[0]) < big(window[1]));
+        }
+        assert_eq!(big("18446744073709551616").to_f64(), 18_446_744_073_709_551_616.0);
+    }
+}
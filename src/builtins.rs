@@ -4,10 +4,11 @@
 // These are implemented in Rust for performance and provide
 // core functionality for math, strings, arrays, I/O operations, and JSON.
 
+use crate::bigint::BigInt;
 use crate::interpreter::{DictMap, Value};
 use crate::network_policy;
 use base64::{engine::general_purpose, Engine as _};
-use chrono::{DateTime, NaiveDate, TimeZone, Utc};
+use chrono::{DateTime, FixedOffset, NaiveDate, NaiveDateTime, TimeZone, Utc};
 use jsonwebtoken::{decode, encode, Algorithm, DecodingKey, EncodingKey, Header, Validation};
 use rand::rngs::StdRng;
 use rand::{Rng, SeedableRng};
@@ -39,9 +40,244 @@ pub fn get_builtins() -> HashMap<String, Value> {
     builtins.insert("PI".to_string(), Value::Float(std::f64::consts::PI));
     builtins.insert("E".to_string(), Value::Float(std::f64::consts::E));
 
+    // Namespaces
+    builtins.insert("http".to_string(), http_module_value());
+    builtins.insert("json".to_string(), json_module_value());
+    builtins.insert("fs".to_string(), fs_module_value());
+    builtins.insert("regex".to_string(), regex_module_value());
+    builtins.insert("strings".to_string(), strings_module_value());
+    builtins.insert("iter".to_string(), iter_module_value());
+    builtins.insert("ffi".to_string(), ffi_module_value());
+    builtins.insert("proc".to_string(), proc_module_value());
+    builtins.insert("net".to_string(), net_module_value());
+    builtins.insert("os".to_string(), os_module_value());
+    builtins.insert("flags".to_string(), flags_module_value());
+    builtins.insert("sqlite".to_string(), sqlite_module_value());
+    builtins.insert("sync".to_string(), sync_module_value());
+    builtins.insert("fmt".to_string(), fmt_module_value());
+    builtins.insert("time".to_string(), time_module_value());
+    builtins.insert("math".to_string(), math_module_value());
+    builtins.insert("io".to_string(), io_module_value());
+
     builtins
 }
 
+/// Methods of the built-in `http` namespace; each export is the native `http.<method>`.
+pub const HTTP_MODULE_METHODS: [&str; 10] = [
+    "get",
+    "post",
+    "put",
+    "patch",
+    "delete",
+    "request",
+    "get_json",
+    "post_json",
+    "serve",
+    "router",
+];
+
+/// Methods of the built-in `json` namespace; each export is the native `json.<method>`.
+pub const JSON_MODULE_METHODS: [&str; 2] = ["parse", "stringify"];
+
+/// Methods of the built-in `fs` namespace; each export is the native `fs.<method>`.
+pub const FS_MODULE_METHODS: [&str; 8] =
+    ["read", "write", "append", "exists", "mkdir_all", "walk", "glob", "stat"];
+
+/// Methods of the built-in `regex` namespace; each export is the native `regex.<method>`.
+pub const REGEX_MODULE_METHODS: [&str; 9] = [
+    "match",
+    "find",
+    "find_all",
+    "captures",
+    "captures_all",
+    "named_captures",
+    "replace",
+    "split",
+    "escape",
+];
+
+/// Methods of the built-in `strings` namespace; each export is the native `strings.<method>`.
+pub const STRINGS_MODULE_METHODS: [&str; 1] = ["builder"];
+
+/// Methods of the built-in `iter` namespace; each export is the native `iter.<method>`.
+pub const ITER_MODULE_METHODS: [&str; 9] =
+    ["range", "from", "map", "filter", "take", "zip", "enumerate", "reduce", "collect"];
+
+/// Methods of the built-in `ffi` namespace; each export is the native `ffi.<method>`.
+pub const FFI_MODULE_METHODS: [&str; 5] = ["load", "alloc", "free", "read_pointer", "read_string"];
+
+/// Methods of the built-in `proc` namespace; each export is the native `proc.<method>`.
+pub const PROC_MODULE_METHODS: [&str; 3] = ["run", "spawn", "pipeline"];
+
+/// Methods of the built-in `net` namespace; each export is the native `net.<method>`.
+pub const NET_MODULE_METHODS: [&str; 2] = ["dial", "listen"];
+
+/// Methods of the built-in `os` namespace; each export is the native `os.<method>`.
+pub const OS_MODULE_METHODS: [&str; 2] = ["env", "exit"];
+
+/// Methods of the built-in `flags` namespace; each export is the native `flags.<method>`.
+pub const FLAGS_MODULE_METHODS: [&str; 2] = ["parse", "help"];
+
+/// Methods of the built-in `sqlite` namespace; each export is the native `sqlite.<method>`.
+pub const SQLITE_MODULE_METHODS: [&str; 1] = ["open"];
+
+/// Methods of the built-in `sync` namespace; each export is the native `sync.<method>`.
+pub const SYNC_MODULE_METHODS: [&str; 6] =
+    ["mutex", "atomic", "atomic_add", "atomic_load", "atomic_store", "map"];
+
+/// Methods of the built-in `fmt` namespace; `fmt.sprintf` runs `format` and `fmt.printf`
+/// runs `print_f`.
+pub const FMT_MODULE_METHODS: [&str; 2] = ["sprintf", "printf"];
+
+/// Methods of the built-in `time` namespace; `time.<method>` runs the flat `time_<method>`.
+pub const TIME_MODULE_METHODS: [&str; 9] =
+    ["now", "monotonic", "format", "parse", "parts", "add_days", "add_months", "diff", "duration"];
+
+/// Methods of the built-in `math` namespace; `math.<method>` runs the flat native of the same
+/// name, except `math.seed`, which runs `set_random_seed`.
+pub const MATH_MODULE_METHODS: [&str; 15] = [
+    "sqrt", "pow", "abs", "floor", "ceil", "round", "min", "max", "sin", "cos", "tan", "log",
+    "exp", "random", "seed",
+];
+
+/// Methods of the built-in `io` namespace. `io.read_file`, `io.write_file`, `io.append_file`,
+/// and `io.read_lines` run the flat natives; the others run `io_<method>`.
+pub const IO_MODULE_METHODS: [&str; 14] = [
+    "read_file",
+    "write_file",
+    "append_file",
+    "read_lines",
+    "open",
+    "read_bytes",
+    "write_bytes",
+    "append_bytes",
+    "read_at",
+    "write_at",
+    "seek_read",
+    "file_metadata",
+    "truncate",
+    "copy_range",
+];
+
+fn native_namespace(name: &str, methods: &[&str]) -> Value {
+    Value::Module { name: name.to_string(), exports: Arc::new(namespace_exports(name, methods)) }
+}
+
+fn namespace_exports(name: &str, methods: &[&str]) -> HashMap<String, Value> {
+    methods
+        .iter()
+        .map(|method| (method.to_string(), Value::NativeFunction(format!("{}.{}", name, method))))
+        .collect()
+}
+
+/// The value bound to the global `http` name.
+pub fn http_module_value() -> Value {
+    native_namespace("http", &HTTP_MODULE_METHODS)
+}
+
+/// The value bound to the global `json` name.
+pub fn json_module_value() -> Value {
+    native_namespace("json", &JSON_MODULE_METHODS)
+}
+
+/// The value bound to the global `fs` name.
+pub fn fs_module_value() -> Value {
+    native_namespace("fs", &FS_MODULE_METHODS)
+}
+
+/// The value bound to the global `regex` name.
+pub fn regex_module_value() -> Value {
+    native_namespace("regex", &REGEX_MODULE_METHODS)
+}
+
+/// The value bound to the global `strings` name.
+pub fn strings_module_value() -> Value {
+    native_namespace("strings", &STRINGS_MODULE_METHODS)
+}
+
+/// The value bound to the global `iter` name.
+pub fn iter_module_value() -> Value {
+    native_namespace("iter", &ITER_MODULE_METHODS)
+}
+
+/// The value bound to the global `ffi` name.
+pub fn ffi_module_value() -> Value {
+    native_namespace("ffi", &FFI_MODULE_METHODS)
+}
+
+/// The value bound to the global `proc` name.
+pub fn proc_module_value() -> Value {
+    native_namespace("proc", &PROC_MODULE_METHODS)
+}
+
+/// The value bound to the global `net` name.
+pub fn net_module_value() -> Value {
+    native_namespace("net", &NET_MODULE_METHODS)
+}
+
+/// The value bound to the global `os` name. Besides its methods it exports `args`, the
+/// script's arguments as an array of strings.
+pub fn os_module_value() -> Value {
+    let mut exports = namespace_exports("os", &OS_MODULE_METHODS);
+    let args = get_args().into_iter().map(|arg| Value::Str(Arc::new(arg))).collect();
+    exports.insert("args".to_string(), Value::Array(Arc::new(args)));
+    Value::Module { name: "os".to_string(), exports: Arc::new(exports) }
+}
+
+/// The value bound to the global `flags` name.
+pub fn flags_module_value() -> Value {
+    native_namespace("flags", &FLAGS_MODULE_METHODS)
+}
+
+/// The value bound to the global `sqlite` name.
+pub fn sqlite_module_value() -> Value {
+    native_namespace("sqlite", &SQLITE_MODULE_METHODS)
+}
+
+/// The value bound to the global `sync` name.
+pub fn sync_module_value() -> Value {
+    native_namespace("sync", &SYNC_MODULE_METHODS)
+}
+
+/// The value bound to the global `fmt` name.
+pub fn fmt_module_value() -> Value {
+    native_namespace("fmt", &FMT_MODULE_METHODS)
+}
+
+/// The value bound to the global `time` name. Calling it runs `current_timestamp`, because
+/// `time()` predates the namespace; see `callable_namespace`.
+pub fn time_module_value() -> Value {
+    native_namespace("time", &TIME_MODULE_METHODS)
+}
+
+/// The value bound to the global `math` name. Besides its methods it exports the constants
+/// `PI` and `E`, which keep their values when the global `PI`/`E` bindings are shadowed.
+pub fn math_module_value() -> Value {
+    let mut exports = namespace_exports("math", &MATH_MODULE_METHODS);
+    exports.insert("PI".to_string(), Value::Float(std::f64::consts::PI));
+    exports.insert("E".to_string(), Value::Float(std::f64::consts::E));
+    Value::Module { name: "math".to_string(), exports: Arc::new(exports) }
+}
+
+/// The value bound to the global `io` name.
+pub fn io_module_value() -> Value {
+    native_namespace("io", &IO_MODULE_METHODS)
+}
+
+/// The value a call of `callee` runs: the built-in `time` module becomes the native
+/// `current_timestamp`, and anything else is returned unchanged.
+pub fn callable_namespace(callee: Value) -> Value {
+    match &callee {
+        Value::Module { name, exports }
+            if name == "time"
+                && matches!(exports.get("now"), Some(Value::NativeFunction(native)) if native == "time.now") =>
+        {
+            Value::NativeFunction("current_timestamp".to_string())
+        }
+        _ => callee,
+    }
+}
+
 /// Math functions
 pub fn abs(x: f64) -> f64 {
     x.abs()
@@ -489,88 +725,244 @@ pub fn str_to_kebab_case(s: &str) -> String {
 }
 
 /// String formatting function
-/// Format a string with sprintf-style placeholders
-/// Supports: %s (string), %d (integer), %f (float)
+/// Format a string with printf-style directives: `%[flags][width][.precision]verb`
+/// Flags: `-` left-justify, `+` always sign, `0` zero-pad, `#` prefix `0x`/`0o`/`0b`
+/// Verbs: %s and %v (display form), %q (quoted), %d, %f, %e, %x, %X, %o, %b, %c, %t, %%
+/// A `%` that does not start a directive, as in `"50% off"`, is copied as written.
 pub fn format_string(template: &str, args: &[Value]) -> Result<String, String> {
+    let chars: Vec<char> = template.chars().collect();
     let mut result = String::new();
     let mut arg_index = 0;
-    let mut chars = template.chars().peekable();
-
-    while let Some(ch) = chars.next() {
-        if ch == '%' {
-            if let Some(&next_ch) = chars.peek() {
-                if next_ch == '%' {
-                    // Escaped %%
-                    result.push('%');
-                    chars.next();
-                } else if next_ch == 's' || next_ch == 'd' || next_ch == 'f' {
-                    chars.next();
-
-                    if arg_index >= args.len() {
+    let mut index = 0;
+
+    while index < chars.len() {
+        let ch = chars[index];
+        if ch != '%' {
+            result.push(ch);
+            index += 1;
+            continue;
+        }
+        if chars.get(index + 1) == Some(&'%') {
+            // Escaped %%
+            result.push('%');
+            index += 2;
+            continue;
+        }
+        let Some((spec, next)) = FormatSpec::parse(&chars, index + 1) else {
+            result.push('%');
+            index += 1;
+            continue;
+        };
+        if spec.width.max(spec.precision.unwrap_or(0)) > MAX_FORMAT_WIDTH {
+            return Err(format!(
+                "format() width and precision are limited to {}",
+                MAX_FORMAT_WIDTH
+            ));
+        }
+        let Some(arg) = args.get(arg_index) else {
+            return Err(format!("format() missing argument for placeholder %{}", spec.verb));
+        };
+        result.push_str(&spec.render(arg)?);
+        arg_index += 1;
+        index = next;
+    }
+
+    Ok(result)
+}
+
+const MAX_FORMAT_WIDTH: usize = 4096;
+
+/// One parsed `%` directive of `format_string`.
+struct FormatSpec {
+    left_align: bool,
+    plus_sign: bool,
+    zero_pad: bool,
+    alternate: bool,
+    width: usize,
+    precision: Option<usize>,
+    verb: char,
+}
+
+impl FormatSpec {
+    /// Parses the directive after a `%`; returns it with the index just past its verb.
+    fn parse(chars: &[char], mut index: usize) -> Option<(Self, usize)> {
+        let mut spec = FormatSpec {
+            left_align: false,
+            plus_sign: false,
+            zero_pad: false,
+            alternate: false,
+            width: 0,
+            precision: None,
+            verb: 's',
+        };
+        while let Some(flag) = chars.get(index) {
+            match flag {
+                '-' => spec.left_align = true,
+                '+' => spec.plus_sign = true,
+                '0' => spec.zero_pad = true,
+                '#' => spec.alternate = true,
+                _ => break,
+            }
+            index += 1;
+        }
+        let read_number = |index: &mut usize| {
+            let mut number = 0usize;
+            while let Some(digit) = chars.get(*index).and_then(|ch| ch.to_digit(10)) {
+                number = number.saturating_mul(10).saturating_add(digit as usize);
+                *index += 1;
+            }
+            number
+        };
+        spec.width = read_number(&mut index);
+        if chars.get(index) == Some(&'.') {
+            index += 1;
+            spec.precision = Some(read_number(&mut index));
+        }
+        let verb = *chars.get(index)?;
+        if !matches!(verb, 's' | 'v' | 'q' | 'd' | 'f' | 'e' | 'x' | 'X' | 'o' | 'b' | 'c' | 't') {
+            return None;
+        }
+        spec.verb = verb;
+        Some((spec, index + 1))
+    }
+
+    fn render(&self, arg: &Value) -> Result<String, String> {
+        let text = match self.verb {
+            's' | 'v' => {
+                let text = Self::display(arg);
+                match self.precision {
+                    Some(limit) => text.chars().take(limit).collect(),
+                    None => text,
+                }
+            }
+            'q' => serde_json::to_string(&Self::display(arg))
+                .map_err(|e| format!("format() %q failed: {}", e))?,
+            't' => match arg {
+                Value::Bool(b) => b.to_string(),
+                _ => return Err(self.type_error("bool", arg)),
+            },
+            'c' => match arg {
+                Value::Int(code) => u32::try_from(*code)
+                    .ok()
+                    .and_then(char::from_u32)
+                    .map(String::from)
+                    .ok_or_else(|| format!("format() %c got invalid code point {}", code))?,
+                Value::Str(s) if s.chars().count() == 1 => s.as_ref().clone(),
+                _ => return Err(self.type_error("int code point", arg)),
+            },
+            'd' => match arg {
+                Value::Int(n) => return Ok(self.pad_number(*n < 0, n.unsigned_abs().to_string())),
+                Value::BigInt(n) => {
+                    let digits = n.to_string().trim_start_matches('-').to_string();
+                    return Ok(self.pad_number(n.is_negative(), digits));
+                }
+                Value::Float(f) => {
+                    let n = *f as i64;
+                    return Ok(self.pad_number(n < 0, n.unsigned_abs().to_string()));
+                }
+                Value::Bool(b) => return Ok(self.pad_number(false, u8::from(*b).to_string())),
+                _ => return Err(format!("format() %d requires numeric argument, got {:?}", arg)),
+            },
+            'x' | 'X' | 'o' | 'b' => {
+                let Value::Int(n) = arg else {
+                    return Err(self.type_error("int", arg));
+                };
+                let magnitude = n.unsigned_abs();
+                let digits = match self.verb {
+                    'x' => format!("{:x}", magnitude),
+                    'X' => format!("{:X}", magnitude),
+                    'o' => format!("{:o}", magnitude),
+                    _ => format!("{:b}", magnitude),
+                };
+                return Ok(self.pad_number(*n < 0, digits));
+            }
+            'f' | 'e' => {
+                let value = match arg {
+                    Value::Float(f) => *f,
+                    Value::Int(n) => *n as f64,
+                    Value::BigInt(n) => n.to_f64(),
+                    _ => {
                         return Err(format!(
-                            "format() missing argument for placeholder %{}",
-                            next_ch
-                        ));
+                            "format() %{} requires numeric argument, got {:?}",
+                            self.verb, arg
+                        ))
                     }
+                };
+                if !value.is_finite() {
+                    return Ok(self.pad(value.to_string()));
+                }
+                let digits = match (self.verb, self.precision) {
+                    // Without a precision %f keeps the shortest exact form
+                    ('f', None) => value.abs().to_string(),
+                    ('f', Some(precision)) => format!("{:.*}", precision, value.abs()),
+                    (_, precision) => {
+                        let text = format!("{:.*e}", precision.unwrap_or(6), value.abs());
+                        let (mantissa, exponent) = text.split_once('e').unwrap_or((&text, "0"));
+                        let exponent: i32 = exponent.parse().unwrap_or(0);
+                        let sign = if exponent < 0 { '-' } else { '+' };
+                        format!("{}e{}{:02}", mantissa, sign, exponent.unsigned_abs())
+                    }
+                };
+                return Ok(self.pad_number(value.is_sign_negative() && value != 0.0, digits));
+            }
+            _ => unreachable!("FormatSpec::parse only accepts known verbs"),
+        };
+        Ok(self.pad(text))
+    }
 
-                    let formatted = match next_ch {
-                        's' => {
-                            // %s - string
-                            match &args[arg_index] {
-                                Value::Str(s) => s.as_ref().clone(),
-                                Value::Int(n) => n.to_string(),
-                                Value::Float(f) => f.to_string(),
-                                Value::Bool(b) => b.to_string(),
-                                Value::Null => "null".to_string(),
-                                Value::Array(_) => "[Array]".to_string(),
-                                Value::Dict(_) => "{Dict}".to_string(),
-                                _ => format!("{:?}", args[arg_index]),
-                            }
-                        }
-                        'd' => {
-                            // %d - integer
-                            match &args[arg_index] {
-                                Value::Int(n) => n.to_string(),
-                                Value::Float(f) => (*f as i64).to_string(),
-                                Value::Bool(b) => if *b { "1" } else { "0" }.to_string(),
-                                _ => {
-                                    return Err(format!(
-                                        "format() %d requires numeric argument, got {:?}",
-                                        args[arg_index]
-                                    ))
-                                }
-                            }
-                        }
-                        'f' => {
-                            // %f - float
-                            match &args[arg_index] {
-                                Value::Float(f) => f.to_string(),
-                                Value::Int(n) => (*n as f64).to_string(),
-                                _ => {
-                                    return Err(format!(
-                                        "format() %f requires numeric argument, got {:?}",
-                                        args[arg_index]
-                                    ))
-                                }
-                            }
-                        }
-                        _ => unreachable!(),
-                    };
+    fn display(arg: &Value) -> String {
+        match arg {
+            Value::Str(s) => s.as_ref().clone(),
+            other => crate::interpreter::Interpreter::stringify_value(other),
+        }
+    }
 
-                    result.push_str(&formatted);
-                    arg_index += 1;
-                } else {
-                    result.push(ch);
-                }
-            } else {
-                result.push(ch);
+    fn type_error(&self, expected: &str, arg: &Value) -> String {
+        format!("format() %{} requires a {} argument, got {:?}", self.verb, expected, arg)
+    }
+
+    /// Applies sign, `#` prefix, integer precision, and width to the digits of a number.
+    fn pad_number(&self, negative: bool, mut digits: String) -> String {
+        if matches!(self.verb, 'd' | 'x' | 'X' | 'o' | 'b') {
+            if let Some(min_digits) = self.precision {
+                let missing = min_digits.saturating_sub(digits.len());
+                digits.insert_str(0, &"0".repeat(missing));
             }
-        } else {
-            result.push(ch);
         }
+        let mut prefix = String::new();
+        if negative {
+            prefix.push('-');
+        } else if self.plus_sign {
+            prefix.push('+');
+        }
+        if self.alternate {
+            prefix.push_str(match self.verb {
+                'x' => "0x",
+                'X' => "0X",
+                'o' => "0o",
+                'b' => "0b",
+                _ => "",
+            });
+        }
+        let length = prefix.len() + digits.chars().count();
+        if self.zero_pad && !self.left_align && self.width > length {
+            prefix.push_str(&"0".repeat(self.width - length));
+        }
+        self.pad(prefix + &digits)
     }
 
-    Ok(result)
+    fn pad(&self, text: String) -> String {
+        let length = text.chars().count();
+        if length >= self.width {
+            return text;
+        }
+        let fill = " ".repeat(self.width - length);
+        if self.left_align {
+            text + &fill
+        } else {
+            fill + &text
+        }
+    }
 }
 
 /// JSON functions
@@ -601,7 +993,7 @@ pub fn parse_json(json_str: &str) -> Result<Value, String> {
 /// Infrastructure for json.stringify() builtin
 #[allow(dead_code)]
 pub fn to_json(value: &Value) -> Result<String, String> {
-    let json_value = ruff_value_to_json(value)?;
+    let json_value = ordered_json(value, 0)?;
     match serde_json::to_string(&json_value) {
         Ok(s) => Ok(s),
         Err(e) => Err(format!("JSON serialization error: {}", e)),
@@ -611,15 +1003,103 @@ pub fn to_json(value: &Value) -> Result<String, String> {
 /// Convert a Ruff value to a pretty-printed JSON string
 #[allow(dead_code)]
 pub fn to_json_pretty(value: &Value) -> Result<String, String> {
-    let json_value = ruff_value_to_json(value)?;
+    let json_value = ordered_json(value, 0)?;
     match serde_json::to_string_pretty(&json_value) {
         Ok(s) => Ok(s),
         Err(e) => Err(format!("JSON serialization error: {}", e)),
     }
 }
 
+/// Convert a Ruff value to JSON, indenting nested levels with `indent` (compact when empty)
+pub fn to_json_indented(value: &Value, indent: &str) -> Result<String, String> {
+    if indent.is_empty() {
+        return to_json(value);
+    }
+
+    let json_value = ordered_json(value, 0)?;
+    let mut output = Vec::new();
+    let formatter = serde_json::ser::PrettyFormatter::with_indent(indent.as_bytes());
+    let mut serializer = serde_json::Serializer::with_formatter(&mut output, formatter);
+    serde::Serialize::serialize(&json_value, &mut serializer)
+        .map_err(|e| format!("JSON serialization error: {}", e))?;
+    String::from_utf8(output).map_err(|e| format!("JSON serialization error: {}", e))
+}
+
+/// JSON document with object members in output order.
+///
+/// `serde_json::Map` sorts its keys, which is the documented output for dicts but
+/// would lose the insertion order of an `ordmap`, so serialization goes through this.
+enum OrderedJson {
+    Leaf(serde_json::Value),
+    Array(Vec<OrderedJson>),
+    Object(Vec<(String, OrderedJson)>),
+}
+
+impl Serialize for OrderedJson {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        use serde::ser::{SerializeMap, SerializeSeq};
+        match self {
+            OrderedJson::Leaf(value) => value.serialize(serializer),
+            OrderedJson::Array(items) => {
+                let mut seq = serializer.serialize_seq(Some(items.len()))?;
+                for item in items {
+                    seq.serialize_element(item)?;
+                }
+                seq.end()
+            }
+            OrderedJson::Object(entries) => {
+                let mut map = serializer.serialize_map(Some(entries.len()))?;
+                for (key, value) in entries {
+                    map.serialize_entry(key, value)?;
+                }
+                map.end()
+            }
+        }
+    }
+}
+
+fn ordered_json(value: &Value, depth: usize) -> Result<OrderedJson, String> {
+    if depth > MAX_JSON_NESTING_DEPTH {
+        return Err(format!(
+            "JSON value exceeds maximum nesting depth of {}",
+            MAX_JSON_NESTING_DEPTH
+        ));
+    }
+
+    let mut entries: Vec<(String, &Value)> = match value {
+        Value::Array(arr) => {
+            let items = arr.iter().map(|item| ordered_json(item, depth + 1));
+            return Ok(OrderedJson::Array(items.collect::<Result<_, _>>()?));
+        }
+        Value::OrderedDict(map) => {
+            let mut members = Vec::with_capacity(map.len());
+            for (key, val) in map.iter() {
+                members.push((key.to_string(), ordered_json(val, depth + 1)?));
+            }
+            return Ok(OrderedJson::Object(members));
+        }
+        Value::Dict(dict) => dict.iter().map(|(key, val)| (key.to_string(), val)).collect(),
+        Value::FixedDict { keys, values } => {
+            keys.iter().zip(values.iter()).map(|(key, val)| (key.to_string(), val)).collect()
+        }
+        Value::IntDict(dict) => dict.iter().map(|(key, val)| (key.to_string(), val)).collect(),
+        Value::DenseIntDict(values) => {
+            values.iter().enumerate().map(|(index, val)| (index.to_string(), val)).collect()
+        }
+        _ => return ruff_value_to_json_with_depth(value, depth).map(OrderedJson::Leaf),
+    };
+
+    // Same member order as `serde_json::Map`
+    entries.sort_by(|(left, _), (right, _)| left.cmp(right));
+    let mut members = Vec::with_capacity(entries.len());
+    for (key, val) in entries {
+        members.push((key, ordered_json(val, depth + 1)?));
+    }
+    Ok(OrderedJson::Object(members))
+}
+
 /// Convert serde_json::Value to Ruff Value
-fn json_to_ruff_value(json: serde_json::Value) -> Value {
+pub(crate) fn json_to_ruff_value(json: serde_json::Value) -> Value {
     match json {
         serde_json::Value::Null => Value::Null, // null -> Null
         serde_json::Value::Bool(b) => Value::Bool(b),
@@ -627,12 +1107,10 @@ fn json_to_ruff_value(json: serde_json::Value) -> Value {
             // Preserve integer vs float distinction
             if let Some(i) = n.as_i64() {
                 Value::Int(i)
-            } else if let Some(u) = n.as_u64() {
-                if let Ok(i) = i64::try_from(u) {
-                    Value::Int(i)
-                } else {
-                    Value::Float(u as f64)
-                }
+            } else if let Some(big) = BigInt::parse(&n.to_string()) {
+                // Integers beyond i64 keep every digit thanks to serde_json's
+                // `arbitrary_precision` feature.
+                Value::from_big_int(big)
             } else if let Some(f) = n.as_f64() {
                 Value::Float(f)
             } else {
@@ -655,7 +1133,7 @@ fn json_to_ruff_value(json: serde_json::Value) -> Value {
 }
 
 /// Convert Ruff Value to serde_json::Value
-fn ruff_value_to_json(value: &Value) -> Result<serde_json::Value, String> {
+pub(crate) fn ruff_value_to_json(value: &Value) -> Result<serde_json::Value, String> {
     ruff_value_to_json_with_depth(value, 0)
 }
 
@@ -670,6 +1148,11 @@ fn ruff_value_to_json_with_depth(value: &Value, depth: usize) -> Result<serde_js
     match value {
         Value::Null => Ok(serde_json::Value::Null),
         Value::Int(n) => Ok(serde_json::Value::Number(serde_json::Number::from(*n))),
+        Value::BigInt(n) => n
+            .to_string()
+            .parse::<serde_json::Number>()
+            .map(serde_json::Value::Number)
+            .map_err(|e| format!("JSON serialization error: {}", e)),
         Value::Float(n) => {
             if !n.is_finite() {
                 return Err(format!("Cannot convert non-finite float {} to JSON", n));
@@ -720,6 +1203,13 @@ fn ruff_value_to_json_with_depth(value: &Value, depth: usize) -> Result<serde_js
             }
             Ok(serde_json::Value::Object(json_obj))
         }
+        Value::OrderedDict(map) => {
+            let mut json_obj = serde_json::Map::new();
+            for (key, val) in map.iter() {
+                json_obj.insert(key.to_string(), ruff_value_to_json_with_depth(val, depth + 1)?);
+            }
+            Ok(serde_json::Value::Object(json_obj))
+        }
         _ => Err(format!("Cannot convert {:?} to JSON", value)),
     }
 }
@@ -1189,6 +1679,283 @@ pub fn parse_date(date_str: &str, format: &str) -> Result<f64, String> {
     Ok(dt.timestamp() as f64)
 }
 
+/// Time zone accepted by the `time` functions: UTC, the host's local zone, or a fixed offset.
+/// There is no time zone database, so named zones such as `"Europe/Paris"` are rejected.
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum TimeZoneSpec {
+    Utc,
+    Local,
+    Fixed(FixedOffset),
+}
+
+/// Parses `"UTC"`, `"local"`, or an offset such as `"+05:30"`, `"-0800"`, or `"+02"`.
+pub fn parse_time_zone(name: &str) -> Result<TimeZoneSpec, String> {
+    match name {
+        "UTC" | "utc" | "Z" => return Ok(TimeZoneSpec::Utc),
+        "local" | "Local" => return Ok(TimeZoneSpec::Local),
+        _ => {}
+    }
+    let unsupported = || {
+        format!(
+            "time zone '{}' is not supported (use \"UTC\", \"local\", or an offset like \"+05:30\")",
+            name
+        )
+    };
+    let (sign, rest) = match name.split_at_checked(1) {
+        Some(("+", rest)) => (1, rest),
+        Some(("-", rest)) => (-1, rest),
+        _ => return Err(unsupported()),
+    };
+    let digits: String = rest.chars().filter(|ch| *ch != ':').collect();
+    if !digits.chars().all(|ch| ch.is_ascii_digit()) || !matches!(digits.len(), 2 | 4) {
+        return Err(unsupported());
+    }
+    let hours: i32 = digits[..2].parse().map_err(|_| unsupported())?;
+    let minutes: i32 = digits
+        .get(2..)
+        .filter(|m| !m.is_empty())
+        .map_or(Ok(0), str::parse)
+        .map_err(|_| unsupported())?;
+    if minutes >= 60 {
+        return Err(unsupported());
+    }
+    FixedOffset::east_opt(sign * (hours * 3600 + minutes * 60))
+        .map(TimeZoneSpec::Fixed)
+        .ok_or_else(unsupported)
+}
+
+/// Current Unix time in seconds, with sub-second precision.
+pub fn time_now() -> f64 {
+    safe_duration_since_unix_epoch(SystemTime::now()).as_secs_f64()
+}
+
+/// Seconds since an arbitrary fixed point in this process; never goes backwards.
+pub fn time_monotonic() -> f64 {
+    use std::sync::OnceLock;
+    static START: OnceLock<Instant> = OnceLock::new();
+    START.get_or_init(Instant::now).elapsed().as_secs_f64()
+}
+
+/// The wall-clock date and time of a Unix timestamp (in seconds) in `zone`.
+pub fn time_in_zone(timestamp: f64, zone: TimeZoneSpec) -> Result<DateTime<FixedOffset>, String> {
+    if !timestamp.is_finite() {
+        return Err("timestamp must be a finite number".to_string());
+    }
+    let seconds = timestamp.floor();
+    let nanos = (((timestamp - seconds) * 1e9).round() as u32).min(999_999_999);
+    let utc = (seconds >= i64::MIN as f64 && seconds <= i64::MAX as f64)
+        .then(|| Utc.timestamp_opt(seconds as i64, nanos).single())
+        .flatten()
+        .ok_or_else(|| "timestamp is out of the supported range".to_string())?;
+    Ok(match zone {
+        TimeZoneSpec::Utc => utc.fixed_offset(),
+        TimeZoneSpec::Local => utc.with_timezone(&chrono::Local).fixed_offset(),
+        TimeZoneSpec::Fixed(offset) => utc.with_timezone(&offset),
+    })
+}
+
+fn timestamp_seconds<Tz: TimeZone>(time: &DateTime<Tz>) -> f64 {
+    time.timestamp() as f64 + f64::from(time.timestamp_subsec_nanos()) / 1e9
+}
+
+/// The Unix timestamp of a wall-clock time in `zone`. An ambiguous local time (when clocks
+/// go back) resolves to the earlier instant; a skipped one (when clocks go forward) is an error.
+fn wall_clock_timestamp(naive: NaiveDateTime, zone: TimeZoneSpec) -> Result<f64, String> {
+    let resolved = match zone {
+        TimeZoneSpec::Utc => Some(naive.and_utc().fixed_offset()),
+        TimeZoneSpec::Local => {
+            chrono::Local.from_local_datetime(&naive).earliest().map(|time| time.fixed_offset())
+        }
+        TimeZoneSpec::Fixed(offset) => offset.from_local_datetime(&naive).single(),
+    };
+    resolved
+        .map(|time| timestamp_seconds(&time))
+        .ok_or_else(|| format!("{} does not exist in the local time zone", naive))
+}
+
+/// A `time` layout translated to chrono's strftime syntax.
+struct TimeLayout {
+    strftime: String,
+    has_time: bool,
+    has_offset: bool,
+}
+
+/// Layout tokens, longest first so `MMMM` wins over `MM`.
+const TIME_LAYOUT_TOKENS: &[(&str, &str)] = &[
+    ("YYYY", "%Y"),
+    ("MMMM", "%B"),
+    ("dddd", "%A"),
+    ("MMM", "%b"),
+    ("ddd", "%a"),
+    ("SSS", "%3f"),
+    ("YY", "%y"),
+    ("MM", "%m"),
+    ("DD", "%d"),
+    ("HH", "%H"),
+    ("hh", "%I"),
+    ("mm", "%M"),
+    ("ss", "%S"),
+    ("A", "%p"),
+    ("Z", "%:z"),
+];
+
+impl TimeLayout {
+    /// Translates a layout such as `"YYYY-MM-DD HH:mm:ss"`. Text in `[...]` is copied as
+    /// written, and any character that does not start a token is literal.
+    fn parse(layout: &str) -> Result<Self, String> {
+        let mut translated =
+            TimeLayout { strftime: String::new(), has_time: false, has_offset: false };
+        let mut rest = layout;
+        while let Some(ch) = rest.chars().next() {
+            if ch == '[' {
+                let end = rest
+                    .find(']')
+                    .ok_or_else(|| format!("layout '{}' has an unclosed '['", layout))?;
+                translated.strftime.push_str(&rest[1..end].replace('%', "%%"));
+                rest = &rest[end + 1..];
+                continue;
+            }
+            if let Some((token, spec)) =
+                TIME_LAYOUT_TOKENS.iter().find(|(token, _)| rest.starts_with(token))
+            {
+                translated.strftime.push_str(spec);
+                translated.has_time |= matches!(*token, "HH" | "hh" | "mm" | "ss" | "SSS");
+                translated.has_offset |= *token == "Z";
+                rest = &rest[token.len()..];
+                continue;
+            }
+            if ch == '%' {
+                translated.strftime.push_str("%%");
+            } else {
+                translated.strftime.push(ch);
+            }
+            rest = &rest[ch.len_utf8()..];
+        }
+        Ok(translated)
+    }
+}
+
+/// Formats a Unix timestamp with a layout, or as RFC 3339 when `layout` is `None`.
+pub fn time_format(
+    timestamp: f64,
+    layout: Option<&str>,
+    zone: TimeZoneSpec,
+) -> Result<String, String> {
+    let time = time_in_zone(timestamp, zone)?;
+    let Some(layout) = layout else {
+        return Ok(time.to_rfc3339_opts(chrono::SecondsFormat::AutoSi, true));
+    };
+    let layout = TimeLayout::parse(layout)?;
+    Ok(time.format(&layout.strftime).to_string())
+}
+
+/// Parses text written in a layout (RFC 3339 when `layout` is `None`) into a Unix timestamp.
+/// Text without an offset is read as wall-clock time in `zone`; a layout without a time of
+/// day parses to midnight.
+pub fn time_parse(text: &str, layout: Option<&str>, zone: TimeZoneSpec) -> Result<f64, String> {
+    let Some(layout_text) = layout else {
+        return DateTime::parse_from_rfc3339(text)
+            .map(|time| timestamp_seconds(&time))
+            .map_err(|error| format!("'{}' is not an RFC 3339 time ({})", text, error));
+    };
+    let layout = TimeLayout::parse(layout_text)?;
+    let mismatch = |error: chrono::ParseError| {
+        format!("'{}' does not match layout '{}' ({})", text, layout_text, error)
+    };
+    if layout.has_offset {
+        return DateTime::parse_from_str(text, &layout.strftime)
+            .map(|time| timestamp_seconds(&time))
+            .map_err(mismatch);
+    }
+    let naive = if layout.has_time {
+        NaiveDateTime::parse_from_str(text, &layout.strftime).map_err(mismatch)?
+    } else {
+        NaiveDate::parse_from_str(text, &layout.strftime)
+            .map_err(mismatch)?
+            .and_time(chrono::NaiveTime::MIN)
+    };
+    wall_clock_timestamp(naive, zone)
+}
+
+/// Moves a timestamp by whole calendar days or months, keeping its wall-clock time in `zone`.
+/// Adding months clamps to the end of shorter months, so Jan 31 plus one month is Feb 28/29.
+pub fn time_add_calendar(
+    timestamp: f64,
+    days: i64,
+    months: i64,
+    zone: TimeZoneSpec,
+) -> Result<f64, String> {
+    let time = time_in_zone(timestamp, zone)?;
+    let naive = time.naive_local();
+    let magnitude = |n: i64| u32::try_from(n.unsigned_abs()).ok();
+    let shifted = match (days, months) {
+        (0, 0) => Some(naive),
+        (days, 0) => magnitude(days).map(|n| chrono::Days::new(u64::from(n))).and_then(|n| {
+            if days < 0 {
+                naive.checked_sub_days(n)
+            } else {
+                naive.checked_add_days(n)
+            }
+        }),
+        (_, months) => magnitude(months).map(chrono::Months::new).and_then(|n| {
+            if months < 0 {
+                naive.checked_sub_months(n)
+            } else {
+                naive.checked_add_months(n)
+            }
+        }),
+    }
+    .ok_or_else(|| "result is out of the supported range".to_string())?;
+    wall_clock_timestamp(shifted, zone)
+}
+
+/// Seconds in one `time.diff` unit.
+pub fn time_unit_seconds(unit: &str) -> Option<f64> {
+    Some(match unit {
+        "milliseconds" | "ms" => 0.001,
+        "seconds" | "s" => 1.0,
+        "minutes" | "m" => 60.0,
+        "hours" | "h" => 3600.0,
+        "days" | "d" => 86_400.0,
+        "weeks" | "w" => 604_800.0,
+        _ => return None,
+    })
+}
+
+/// Parses a duration such as `"1h30m"`, `"1.5s"`, `"250ms"`, or `"-2d"` into seconds.
+pub fn time_duration(text: &str) -> Result<f64, String> {
+    let invalid = || format!("invalid duration '{}' (expected e.g. \"1h30m\" or \"250ms\")", text);
+    let (sign, mut rest) = match text.strip_prefix('-') {
+        Some(rest) => (-1.0, rest),
+        None => (1.0, text.strip_prefix('+').unwrap_or(text)),
+    };
+    if rest.is_empty() {
+        return Err(invalid());
+    }
+    let mut total = 0.0;
+    while !rest.is_empty() {
+        let number_end =
+            rest.find(|ch: char| !(ch.is_ascii_digit() || ch == '.')).ok_or_else(invalid)?;
+        let amount: f64 = rest[..number_end].parse().map_err(|_| invalid())?;
+        rest = &rest[number_end..];
+        let unit_end = rest.find(|ch: char| ch.is_ascii_digit() || ch == '.').unwrap_or(rest.len());
+        let unit = match &rest[..unit_end] {
+            "ns" => 1e-9,
+            "us" | "µs" | "μs" => 1e-6,
+            "ms" => 1e-3,
+            "s" => 1.0,
+            "m" => 60.0,
+            "h" => 3600.0,
+            "d" => 86_400.0,
+            "w" => 604_800.0,
+            _ => return Err(invalid()),
+        };
+        total += amount * unit;
+        rest = &rest[unit_end..];
+    }
+    Ok(sign * total)
+}
+
 fn kv_store_path() -> Result<PathBuf, String> {
     if let Ok(path) = env::var("RUFF_KV_PATH") {
         let trimmed = path.trim();
@@ -1443,12 +2210,36 @@ pub fn path_exists(path_str: &str) -> bool {
     Path::new(path_str).exists()
 }
 
+/// Compiled patterns kept by `compile_regex`. Cleared once it reaches this many entries so
+/// programs that build patterns dynamically cannot grow it without bound.
+const REGEX_CACHE_CAPACITY: usize = 256;
+
+static REGEX_CACHE: Mutex<Option<HashMap<String, Arc<Regex>>>> = Mutex::new(None);
+
+/// Compile `pattern`, reusing an earlier compilation of the same pattern text.
+///
+/// Every regex builtin goes through here, so matching inside a loop compiles once.
+pub fn compile_regex(pattern: &str) -> Result<Arc<Regex>, regex::Error> {
+    let mut cache = REGEX_CACHE.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+    let cache = cache.get_or_insert_with(HashMap::new);
+    if let Some(compiled) = cache.get(pattern) {
+        return Ok(Arc::clone(compiled));
+    }
+
+    let compiled = Arc::new(Regex::new(pattern)?);
+    if cache.len() >= REGEX_CACHE_CAPACITY {
+        cache.clear();
+    }
+    cache.insert(pattern.to_string(), Arc::clone(&compiled));
+    Ok(compiled)
+}
+
 /// Regular expression functions
 /// Check if string matches regex pattern
 /// Infrastructure for regex.match() builtin
 #[allow(dead_code)]
 pub fn regex_match(text: &str, pattern: &str) -> bool {
-    match Regex::new(pattern) {
+    match compile_regex(pattern) {
         Ok(re) => re.is_match(text),
         Err(_) => false, // Invalid regex returns false
     }
@@ -1458,7 +2249,7 @@ pub fn regex_match(text: &str, pattern: &str) -> bool {
 /// Infrastructure for regex.findAll() builtin
 #[allow(dead_code)]
 pub fn regex_find_all(text: &str, pattern: &str) -> Vec<String> {
-    match Regex::new(pattern) {
+    match compile_regex(pattern) {
         Ok(re) => re.find_iter(text).map(|m| m.as_str().to_string()).collect(),
         Err(_) => vec![], // Invalid regex returns empty array
     }
@@ -1468,7 +2259,7 @@ pub fn regex_find_all(text: &str, pattern: &str) -> Vec<String> {
 /// Infrastructure for regex.replace() builtin
 #[allow(dead_code)]
 pub fn regex_replace(text: &str, pattern: &str, replacement: &str) -> String {
-    match Regex::new(pattern) {
+    match compile_regex(pattern) {
         Ok(re) => re.replace_all(text, replacement).to_string(),
         Err(_) => text.to_string(), // Invalid regex returns original text
     }
@@ -1478,7 +2269,7 @@ pub fn regex_replace(text: &str, pattern: &str, replacement: &str) -> String {
 /// Infrastructure for regex.split() builtin
 #[allow(dead_code)]
 pub fn regex_split(text: &str, pattern: &str) -> Vec<String> {
-    match Regex::new(pattern) {
+    match compile_regex(pattern) {
         Ok(re) => re.split(text).map(|s| s.to_string()).collect(),
         Err(_) => vec![text.to_string()], // Invalid regex returns original text as single element
     }
@@ -1505,15 +2296,7 @@ pub fn array_insert(arr: Vec<Value>, index: i64, item: Value) -> Result<Vec<Valu
 /// Remove the first occurrence of an item
 pub fn array_remove(arr: Vec<Value>, item: &Value) -> Vec<Value> {
     let mut new_arr = arr;
-    // Find position manually since Value doesn't implement PartialEq
-    let pos = new_arr.iter().position(|x| match (x, item) {
-        (Value::Int(a), Value::Int(b)) => a == b,
-        (Value::Float(a), Value::Float(b)) => a == b,
-        (Value::Str(a), Value::Str(b)) => a.as_ref() == b.as_ref(),
-        (Value::Bool(a), Value::Bool(b)) => a == b,
-        (Value::Null, Value::Null) => true,
-        _ => false,
-    });
+    let pos = new_arr.iter().position(|x| Value::equals(x, item));
 
     if let Some(idx) = pos {
         new_arr.remove(idx);
@@ -1545,28 +2328,14 @@ pub fn array_clear() -> Vec<Value> {
 
 /// Find the index of the first occurrence of an item
 pub fn array_index_of(arr: &[Value], item: &Value) -> i64 {
-    let pos = arr.iter().position(|x| match (x, item) {
-        (Value::Int(a), Value::Int(b)) => a == b,
-        (Value::Float(a), Value::Float(b)) => a == b,
-        (Value::Str(a), Value::Str(b)) => a == b,
-        (Value::Bool(a), Value::Bool(b)) => a == b,
-        (Value::Null, Value::Null) => true,
-        _ => false,
-    });
+    let pos = arr.iter().position(|x| Value::equals(x, item));
 
     pos.map(|i| i as i64).unwrap_or(-1)
 }
 
 /// Check if an array contains an item
 pub fn array_contains(arr: &[Value], item: &Value) -> bool {
-    arr.iter().any(|x| match (x, item) {
-        (Value::Int(a), Value::Int(b)) => a == b,
-        (Value::Float(a), Value::Float(b)) => a == b,
-        (Value::Str(a), Value::Str(b)) => a == b,
-        (Value::Bool(a), Value::Bool(b)) => a == b,
-        (Value::Null, Value::Null) => true,
-        _ => false,
-    })
+    arr.iter().any(|x| Value::equals(x, item))
 }
 
 /// Advanced array methods
@@ -1963,6 +2732,7 @@ pub fn assert_condition(condition: bool, message: Option<&str>) -> Result<(), St
 pub fn format_debug_value(value: &Value) -> String {
     match value {
         Value::Int(n) => format!("Int({})", n),
+        Value::BigInt(n) => format!("BigInt({})", n),
         Value::Float(n) => format!("Float({})", n),
         Value::Str(s) => format!("String(\"{}\")", s.as_ref()),
         Value::Bool(b) => format!("Bool({})", b),
@@ -2023,6 +2793,11 @@ pub fn format_debug_value(value: &Value) -> String {
                 .collect();
             format!("Dict{{{}}}", items.join(", "))
         }
+        Value::OrderedDict(map) => {
+            let items: Vec<String> =
+                map.iter().map(|(k, v)| format!("{}: {}", k, format_debug_value(v))).collect();
+            format!("OrderedDict{{{}}}", items.join(", "))
+        }
         Value::Function(_, _, _) => "Function".to_string(),
         Value::AsyncFunction(_, _, _) => "AsyncFunction".to_string(),
         Value::NativeFunction(name) => format!("NativeFunction({})", name),
@@ -2037,6 +2812,8 @@ pub fn format_debug_value(value: &Value) -> String {
         Value::ArrayMarker => "ArrayMarker".to_string(),
         Value::Struct { name, .. } => format!("Struct({})", name),
         Value::StructDef { name, .. } => format!("StructDef({})", name),
+        Value::Interface { name, .. } => format!("Interface({})", name),
+        Value::Module { name, .. } => format!("Module({})", name),
         Value::Tagged { tag, fields } => {
             let items: Vec<String> =
                 fields.iter().map(|(k, v)| format!("{}: {}", k, format_debug_value(v))).collect();
@@ -2060,6 +2837,22 @@ pub fn format_debug_value(value: &Value) -> String {
         Value::ErrorObject { message, .. } => format!("ErrorObject(\"{}\")", message),
         Value::Enum(name) => format!("Enum({})", name),
         Value::Channel(_) => "Channel".to_string(),
+        Value::WaitGroup(_) => "WaitGroup".to_string(),
+        Value::Mutex(_) => "Mutex".to_string(),
+        Value::Atomic(counter) => {
+            format!("Atomic({})", counter.load(std::sync::atomic::Ordering::SeqCst))
+        }
+        Value::SyncMap(map) => format!("SyncMap({} keys)", map.len()),
+        Value::Decorated(decorated) => format!("Decorated({})", decorated.name()),
+        Value::Router(router) => format!("Router({} routes)", router.route_count()),
+        Value::StringBuilder(buffer) => format!(
+            "StringBuilder({} bytes)",
+            buffer.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).len()
+        ),
+        Value::NativeLibrary(library) => format!("NativeLibrary({})", library.path()),
+        Value::Process(process) => format!("Process({})", process.label()),
+        Value::Socket(socket) => format!("Socket({})", socket.label()),
+        Value::Sequence(_) => "Sequence".to_string(),
         Value::HttpServer { host, port, .. } => {
             format!("HttpServer(host: {}, port: {})", host, port)
         }
@@ -2068,6 +2861,9 @@ pub fn format_debug_value(value: &Value) -> String {
         Value::DatabasePool { .. } => "DatabasePool".to_string(),
         Value::Image { format, .. } => format!("Image(format: {})", format),
         Value::ZipArchive { path, .. } => format!("ZipArchive(path: {})", path),
+        Value::FileHandle { path, mode, .. } => {
+            format!("FileHandle(path: {}, mode: {})", path, mode)
+        }
         Value::TcpListener { addr, .. } => format!("TcpListener(addr: {})", addr),
         Value::TcpStream { peer_addr, .. } => format!("TcpStream(peer: {})", peer_addr),
         Value::UdpSocket { addr, .. } => format!("UdpSocket(addr: {})", addr),
@@ -2086,7 +2882,9 @@ pub fn format_debug_value(value: &Value) -> String {
             }
         }
         Value::GeneratorDef(params, _) => format!("GeneratorDef({:?})", params),
-        Value::Generator { params, is_exhausted, .. } => {
+        Value::Generator { params, state, .. } => {
+            let is_exhausted =
+                state.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).is_exhausted;
             format!("Generator({:?}, exhausted: {})", params, is_exhausted)
         }
         Value::Iterator { source, .. } => format!("Iterator(source: {:?})", source),
@@ -2134,82 +2932,81 @@ pub fn parse_arguments(arg_defs: &[ArgumentDef], args: &[String]) -> Result<Dict
     while i < args.len() {
         let arg = &args[i];
 
-        if arg.starts_with("--") || arg.starts_with('-') {
-            // This is a flag or option
-            let mut found = false;
+        if arg == "--" {
+            // Everything after a bare `--` is positional, even if it looks like a flag
+            positional_args.extend(args[i + 1..].iter().cloned());
+            break;
+        }
 
-            for def in arg_defs {
-                let matches_long = arg == &def.long_name;
-                let matches_short = def.short_name.as_ref().map_or(false, |s| arg == s);
+        if arg.starts_with('-') {
+            // This is a flag or option, possibly written as `--name=value`
+            let (flag, inline_value) = match arg.split_once('=') {
+                Some((flag, value)) if arg.starts_with("--") => (flag, Some(value.to_string())),
+                _ => (arg.as_str(), None),
+            };
 
-                if matches_long || matches_short {
-                    found = true;
-                    let key = def.long_name.trim_start_matches("--").to_string();
-                    found_args.insert(key.clone());
+            let Some(def) = arg_defs
+                .iter()
+                .find(|def| flag == def.long_name || def.short_name.as_deref() == Some(flag))
+            else {
+                return Err(format!("Unknown argument: {}", arg));
+            };
 
-                    match def.arg_type.as_str() {
-                        "bool" => {
-                            // Flags don't consume next argument
-                            result.insert(key.into(), Value::Bool(true));
-                        }
-                        "string" => {
-                            // Consume next argument as string value
-                            if i + 1 < args.len() {
-                                i += 1;
-                                result.insert(key.into(), Value::Str(Arc::new(args[i].clone())));
-                            } else {
-                                return Err(format!("Argument {} requires a value", def.long_name));
-                            }
-                        }
-                        "int" => {
-                            // Consume next argument and parse as int
-                            if i + 1 < args.len() {
-                                i += 1;
-                                match args[i].parse::<i64>() {
-                                    Ok(val) => {
-                                        result.insert(key.into(), Value::Int(val));
-                                    }
-                                    Err(_) => {
-                                        return Err(format!(
-                                            "Argument {} requires an integer value, got: {}",
-                                            def.long_name, args[i]
-                                        ));
-                                    }
-                                }
-                            } else {
-                                return Err(format!("Argument {} requires a value", def.long_name));
-                            }
-                        }
-                        "float" => {
-                            // Consume next argument and parse as float
-                            if i + 1 < args.len() {
-                                i += 1;
-                                match args[i].parse::<f64>() {
-                                    Ok(val) => {
-                                        result.insert(key.into(), Value::Float(val));
-                                    }
-                                    Err(_) => {
-                                        return Err(format!(
-                                            "Argument {} requires a float value, got: {}",
-                                            def.long_name, args[i]
-                                        ));
-                                    }
-                                }
-                            } else {
-                                return Err(format!("Argument {} requires a value", def.long_name));
-                            }
-                        }
-                        _ => {
-                            return Err(format!("Unknown argument type: {}", def.arg_type));
-                        }
+            let key = def.long_name.trim_start_matches("--").to_string();
+            found_args.insert(key.clone());
+
+            if def.arg_type == "bool" {
+                // Flags don't consume the next argument; `--flag=false` turns one off
+                let enabled = match inline_value.as_deref() {
+                    None | Some("true") => true,
+                    Some("false") => false,
+                    Some(other) => {
+                        return Err(format!(
+                            "Argument {} requires true or false, got: {}",
+                            def.long_name, other
+                        ));
                     }
-                    break;
-                }
+                };
+                result.insert(key.into(), Value::Bool(enabled));
+                i += 1;
+                continue;
             }
 
-            if !found {
-                return Err(format!("Unknown argument: {}", arg));
-            }
+            // Every other type consumes a value: the inline one or the next argument
+            let raw = match inline_value {
+                Some(value) => value,
+                None if i + 1 < args.len() => {
+                    i += 1;
+                    args[i].clone()
+                }
+                None => return Err(format!("Argument {} requires a value", def.long_name)),
+            };
+
+            let value = match def.arg_type.as_str() {
+                "string" => Value::Str(Arc::new(raw)),
+                "int" => match raw.parse::<i64>() {
+                    Ok(val) => Value::Int(val),
+                    Err(_) => {
+                        return Err(format!(
+                            "Argument {} requires an integer value, got: {}",
+                            def.long_name, raw
+                        ));
+                    }
+                },
+                "float" => match raw.parse::<f64>() {
+                    Ok(val) => Value::Float(val),
+                    Err(_) => {
+                        return Err(format!(
+                            "Argument {} requires a float value, got: {}",
+                            def.long_name, raw
+                        ));
+                    }
+                },
+                _ => {
+                    return Err(format!("Unknown argument type: {}", def.arg_type));
+                }
+            };
+            result.insert(key.into(), value);
         } else {
             // Positional argument
             positional_args.push(arg.clone());
@@ -2261,7 +3058,11 @@ pub fn parse_arguments(arg_defs: &[ArgumentDef], args: &[String]) -> Result<Dict
 
 /// Generate help text from argument definitions
 pub fn generate_help(arg_defs: &[ArgumentDef], app_name: &str, description: &str) -> String {
-    let mut help = format!("{}\n\n{}\n\nOptions:\n", app_name, description);
+    let mut help = format!("{}\n\n", app_name);
+    if !description.is_empty() {
+        help.push_str(&format!("{}\n\n", description));
+    }
+    help.push_str("Options:\n");
 
     for def in arg_defs {
         let mut line = String::new();
@@ -2339,6 +3140,15 @@ mod tests {
         assert_eq!(join(&["a".to_string(), "b".to_string(), "c".to_string()], ","), "a,b,c");
     }
 
+    #[test]
+    fn test_compile_regex_reuses_cached_pattern() {
+        let first = compile_regex("^cache-test-[0-9]+$").unwrap();
+        let second = compile_regex("^cache-test-[0-9]+$").unwrap();
+        assert!(Arc::ptr_eq(&first, &second));
+        assert!(second.is_match("cache-test-42"));
+        assert!(compile_regex("(").is_err());
+    }
+
     #[test]
     fn test_to_json_fixed_dict_serializes_successfully() {
         let value = Value::FixedDict {
@@ -2397,6 +3207,57 @@ mod tests {
         assert_eq!(items[1]["id"], serde_json::Value::Number(2.into()));
     }
 
+    #[test]
+    fn test_format_string_directives() {
+        let str_value = |s: &str| Value::Str(Arc::new(s.to_string()));
+        let cases: Vec<(&str, Vec<Value>, &str)> = vec![
+            ("%-6s|%6.2f", vec![str_value("tea"), Value::Float(3.14159)], "tea   |  3.14"),
+            (
+                "%05d %+d %x %#X %#o %b",
+                vec![
+                    Value::Int(-42),
+                    Value::Int(7),
+                    Value::Int(255),
+                    Value::Int(255),
+                    Value::Int(8),
+                    Value::Int(5),
+                ],
+                "-0042 +7 ff 0XFF 0o10 101",
+            ),
+            (
+                "%.3d|%e|%.2e",
+                vec![Value::Int(7), Value::Float(1234.5), Value::Float(-0.000123)],
+                "007|1.234500e+03|-1.23e-04",
+            ),
+            (
+                "%v %v %.2s",
+                vec![
+                    Value::Array(Arc::new(vec![Value::Int(1), Value::Null])),
+                    Value::Bool(true),
+                    str_value("hello"),
+                ],
+                "[1, null] true he",
+            ),
+            (
+                "%q %c%c %t",
+                vec![str_value("a\"b"), Value::Int(82), str_value("x"), Value::Bool(false)],
+                "\"a\\\"b\" Rx false",
+            ),
+            ("%d %f %s%%", vec![Value::Float(2.9), Value::Int(2), Value::Int(50)], "2 2 50%"),
+            ("50% off %y", vec![], "50% off %y"),
+        ];
+        for (template, args, expected) in cases {
+            assert_eq!(format_string(template, &args).as_deref(), Ok(expected), "{}", template);
+        }
+
+        assert_eq!(
+            format_string("%s and %s", &[str_value("one")]),
+            Err("format() missing argument for placeholder %s".to_string())
+        );
+        assert!(format_string("%x", &[Value::Float(1.5)]).unwrap_err().contains("%x requires"));
+        assert!(format_string("%99999d", &[Value::Int(1)]).unwrap_err().contains("limited"));
+    }
+
     #[test]
     fn test_format_date_rejects_non_finite_timestamp_without_panicking() {
         let formatted = format_date(f64::NAN, "YYYY-MM-DD");
@@ -0,0 +1,401 @@
+//! Standalone executables for `ruff build`.
+//!
+//! A bundle is a copy of the `ruff` executable with a payload appended: a JSON
+//! manifest carrying the entry script and every module it imports, followed by the
+//! manifest length and a magic trailer. At startup `ruff` checks its own executable
+//! for the trailer. When one is present it unpacks the sources into a per-bundle
+//! cache directory and runs the entry script the way `ruff run` would, passing every
+//! command-line argument through to the script.
+
+use crate::ast::Stmt;
+use crate::lexer::tokenize_with_file;
+use crate::module::ModuleLoader;
+use crate::package_workflow;
+use crate::parser::Parser;
+use crate::path_security;
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::{BTreeMap, HashSet};
+use std::ffi::OsString;
+use std::fs;
+use std::io::{Read, Seek, SeekFrom};
+use std::path::{Path, PathBuf};
+use std::sync::OnceLock;
+
+/// Last bytes of every bundled executable.
+const BUNDLE_MAGIC: &[u8; 8] = b"RUFFBNDL";
+const BUNDLE_FORMAT_VERSION: u32 = 1;
+/// Extracted bundles live under `~/.ruff/bundles/<payload hash>`.
+const USER_BUNDLE_CACHE_DIR: &str = ".ruff/bundles";
+
+#[derive(Debug, Serialize, Deserialize)]
+struct BundleManifest {
+    format: u32,
+    /// Entry script, relative to the bundle root.
+    entry: String,
+    /// Package roots the modules were resolved from, relative to the bundle root.
+    search_roots: Vec<String>,
+    /// Run on the tree-walking interpreter instead of the bytecode VM.
+    #[serde(default)]
+    interpreter: bool,
+    /// Every bundled source keyed by its `/`-separated path relative to the bundle root.
+    files: BTreeMap<String, String>,
+}
+
+/// What `ruff build` wrote.
+#[derive(Debug)]
+pub struct BuildSummary {
+    pub output: PathBuf,
+    pub modules: usize,
+}
+
+static BUNDLE_SEARCH_ROOTS: OnceLock<Vec<PathBuf>> = OnceLock::new();
+
+/// Extra module search roots of the running bundle, empty outside one. The entry
+/// script's own roots cover most imports; these cover modules that `ruff build`
+/// found through the working directory or `RUFF_PATH`.
+pub fn search_roots() -> &'static [PathBuf] {
+    BUNDLE_SEARCH_ROOTS.get().map(Vec::as_slice).unwrap_or(&[])
+}
+
+/// Bundles `entry` and the modules it imports, resolved through `loader`, into a
+/// copy of the running executable at `output`.
+pub fn build(
+    entry: &Path,
+    output: &Path,
+    loader: &ModuleLoader,
+    interpreter: bool,
+) -> Result<BuildSummary, String> {
+    let entry = fs::canonicalize(entry)
+        .map_err(|error| format!("Cannot read '{}': {}", entry.display(), error))?;
+    let (sources, package_roots) = collect_sources(&entry, loader)?;
+    let modules = sources.len() - 1;
+
+    let mut bundled: Vec<(PathBuf, String)> = sources;
+    let entry_dir = entry.parent().unwrap_or(Path::new("/"));
+    if let Some(manifest_path) = package_workflow::find_manifest(entry_dir) {
+        // Locked dependencies resolve through the project's lockfile at run time
+        for project_file in
+            [package_workflow::default_lockfile_path(&manifest_path), manifest_path.clone()]
+        {
+            if let Ok(content) = fs::read_to_string(&project_file) {
+                bundled.push((project_file, content));
+            }
+        }
+    }
+
+    let root = common_root(
+        bundled
+            .iter()
+            .map(|(path, _)| path.parent().unwrap_or(path))
+            .chain(package_roots.iter().map(PathBuf::as_path)),
+    );
+    let relative = |path: &Path| {
+        let relative = path.strip_prefix(&root).unwrap_or(path);
+        let parts: Vec<String> = relative
+            .components()
+            .map(|component| component.as_os_str().to_string_lossy().into_owned())
+            .collect();
+        if parts.is_empty() {
+            ".".to_string()
+        } else {
+            parts.join("/")
+        }
+    };
+
+    let manifest = BundleManifest {
+        format: BUNDLE_FORMAT_VERSION,
+        entry: relative(&entry),
+        search_roots: package_roots.iter().map(|root| relative(root)).collect(),
+        interpreter,
+        files: bundled.iter().map(|(path, source)| (relative(path), source.clone())).collect(),
+    };
+    let payload = serde_json::to_vec(&manifest)
+        .map_err(|error| format!("Failed to encode bundle: {}", error))?;
+
+    let runtime = std::env::current_exe()
+        .map_err(|error| format!("Cannot locate the ruff executable: {}", error))?;
+    let mut image = fs::read(&runtime)
+        .map_err(|error| format!("Cannot read '{}': {}", runtime.display(), error))?;
+    // Rebundling from a bundle replaces its payload rather than stacking another
+    if let Some(range) = payload_range(&image) {
+        image.truncate(range.start);
+    }
+    image.extend_from_slice(&payload);
+    image.extend_from_slice(&(payload.len() as u64).to_le_bytes());
+    image.extend_from_slice(BUNDLE_MAGIC);
+
+    fs::write(output, &image)
+        .map_err(|error| format!("Cannot write '{}': {}", output.display(), error))?;
+    #[cfg(unix)]
+    {
+        use std::os::unix::fs::PermissionsExt;
+        fs::set_permissions(output, fs::Permissions::from_mode(0o755))
+            .map_err(|error| format!("Cannot make '{}' executable: {}", output.display(), error))?;
+    }
+
+    Ok(BuildSummary { output: output.to_path_buf(), modules })
+}
+
+/// Command line that runs the bundle appended to the current executable, or `None`
+/// when this is a plain `ruff` binary.
+pub fn embedded_run_args() -> Result<Option<Vec<OsString>>, String> {
+    let Ok(executable) = std::env::current_exe() else {
+        return Ok(None);
+    };
+    let Some(payload) = read_payload(&executable)? else {
+        return Ok(None);
+    };
+    let manifest: BundleManifest = serde_json::from_slice(&payload)
+        .map_err(|error| format!("Corrupt bundle in '{}': {}", executable.display(), error))?;
+    if manifest.format != BUNDLE_FORMAT_VERSION {
+        return Err(format!(
+            "Bundle in '{}' uses format {}, this runtime reads format {}",
+            executable.display(),
+            manifest.format,
+            BUNDLE_FORMAT_VERSION
+        ));
+    }
+
+    let root = extract(&manifest, &payload)?;
+    let search_roots = manifest
+        .search_roots
+        .iter()
+        .map(|relative| bundle_path(&root, relative))
+        .collect::<Result<Vec<_>, _>>()?;
+    let _ = BUNDLE_SEARCH_ROOTS.set(search_roots);
+
+    let mut args: Vec<OsString> = std::env::args_os().take(1).collect();
+    args.push("run".into());
+    if manifest.interpreter {
+        args.push("--interpreter".into());
+    }
+    args.push(bundle_path(&root, &manifest.entry)?.into_os_string());
+    // Everything after `--` reaches the script verbatim, including its own flags
+    args.push("--".into());
+    args.extend(std::env::args_os().skip(1));
+    Ok(Some(args))
+}
+
+/// Parses `entry` and every module it reaches, returning each file's source plus
+/// the package roots the modules were found in.
+fn collect_sources(
+    entry: &Path,
+    loader: &ModuleLoader,
+) -> Result<(Vec<(PathBuf, String)>, Vec<PathBuf>), String> {
+    let mut sources = Vec::new();
+    let mut package_roots: Vec<PathBuf> = Vec::new();
+    let mut seen = HashSet::new();
+    let mut pending = vec![(entry.to_path_buf(), None::<PathBuf>)];
+
+    while let Some((file, package_root)) = pending.pop() {
+        if !seen.insert(file.clone()) {
+            continue;
+        }
+        let source = fs::read_to_string(&file)
+            .map_err(|error| format!("Cannot read '{}': {}", file.display(), error))?;
+        let stmts = parse_source(&file, &source)?;
+
+        let mut imports = Vec::new();
+        collect_imports(&stmts, &mut imports);
+        for import in imports {
+            let resolved = match import {
+                Stmt::Import { module, .. } => {
+                    loader.resolve_module_file(module, package_root.as_deref())
+                }
+                Stmt::ImportPath { path, .. } => loader.resolve_path_import_file(path, &file),
+                _ => continue,
+            }
+            .map_err(|error| format!("{}: {}", file.display(), error.message))?;
+            let Some((module_path, module_root)) = resolved else {
+                let name = match import {
+                    Stmt::ImportPath { path, .. } => path,
+                    Stmt::Import { module, .. } => module,
+                    _ => unreachable!(),
+                };
+                return Err(format!("{}: module not found: {}", file.display(), name));
+            };
+            if !package_roots.contains(&module_root) {
+                package_roots.push(module_root.clone());
+            }
+            pending.push((module_path, Some(module_root)));
+        }
+        sources.push((file, source));
+    }
+
+    // The entry script comes first and the rest sort for a stable payload
+    sources[1..].sort_by(|a, b| a.0.cmp(&b.0));
+    Ok((sources, package_roots))
+}
+
+fn parse_source(file: &Path, source: &str) -> Result<Vec<Stmt>, String> {
+    let filename = file.to_string_lossy();
+    let tokens = tokenize_with_file(source, Some(&filename)).map_err(|diagnostics| {
+        let first = diagnostics
+            .first()
+            .map(|diagnostic| {
+                format!("{}:{}: {}", diagnostic.line, diagnostic.column, diagnostic.message)
+            })
+            .unwrap_or_else(|| "unknown lexer error".to_string());
+        format!("{}:{}", filename, first)
+    })?;
+    let parse_output = Parser::new(tokens).parse_with_diagnostics();
+    if let Some(diagnostic) = parse_output.diagnostics.first() {
+        return Err(format!(
+            "{}:{}:{}: {}",
+            filename, diagnostic.line, diagnostic.column, diagnostic.message
+        ));
+    }
+    Ok(parse_output.stmts)
+}
+
+/// Import statements at module level, where imports are resolved.
+fn collect_imports<'a>(stmts: &'a [Stmt], imports: &mut Vec<&'a Stmt>) {
+    for stmt in stmts {
+        match stmt {
+            Stmt::Import { .. } | Stmt::ImportPath { .. } => imports.push(stmt),
+            Stmt::Export { stmt } => collect_imports(std::slice::from_ref(stmt.as_ref()), imports),
+            Stmt::Block(block) => collect_imports(block, imports),
+            _ => {}
+        }
+    }
+}
+
+fn common_root<'a>(mut dirs: impl Iterator<Item = &'a Path>) -> PathBuf {
+    let Some(first) = dirs.next() else {
+        return PathBuf::from("/");
+    };
+    let mut root = first.to_path_buf();
+    for dir in dirs {
+        while !dir.starts_with(&root) {
+            if !root.pop() {
+                break;
+            }
+        }
+    }
+    root
+}
+
+/// Byte range of the payload when `image` ends with a bundle trailer.
+fn payload_range(image: &[u8]) -> Option<std::ops::Range<usize>> {
+    let trailer_start = image.len().checked_sub(16)?;
+    let (length, magic) = image[trailer_start..].split_at(8);
+    if magic != BUNDLE_MAGIC {
+        return None;
+    }
+    let length = u64::from_le_bytes(length.try_into().ok()?) as usize;
+    Some(trailer_start.checked_sub(length)?..trailer_start)
+}
+
+fn read_payload(executable: &Path) -> Result<Option<Vec<u8>>, String> {
+    let read_error =
+        |error: std::io::Error| format!("Cannot read '{}': {}", executable.display(), error);
+    let Ok(mut file) = fs::File::open(executable) else {
+        return Ok(None);
+    };
+    let size = file.metadata().map_err(read_error)?.len();
+    if size < 16 {
+        return Ok(None);
+    }
+    let mut trailer = [0u8; 16];
+    file.seek(SeekFrom::Start(size - 16)).map_err(read_error)?;
+    file.read_exact(&mut trailer).map_err(read_error)?;
+    if &trailer[8..] != BUNDLE_MAGIC {
+        return Ok(None);
+    }
+
+    let length = u64::from_le_bytes(trailer[..8].try_into().expect("8-byte length"));
+    if length > size - 16 {
+        return Err(format!(
+            "Corrupt bundle in '{}': payload length exceeds file size",
+            executable.display()
+        ));
+    }
+    let mut payload = vec![0u8; length as usize];
+    file.seek(SeekFrom::Start(size - 16 - length)).map_err(read_error)?;
+    file.read_exact(&mut payload).map_err(read_error)?;
+    Ok(Some(payload))
+}
+
+/// Unpacks the bundle into its cache directory, reusing an earlier extraction whose
+/// files still match the manifest.
+fn extract(manifest: &BundleManifest, payload: &[u8]) -> Result<PathBuf, String> {
+    let digest = Sha256::digest(payload);
+    let name: String = digest[..8].iter().map(|byte| format!("{:02x}", byte)).collect();
+    let cache_dir = std::env::var_os("HOME")
+        .map(|home| PathBuf::from(home).join(USER_BUNDLE_CACHE_DIR))
+        .unwrap_or_else(|| std::env::temp_dir().join("ruff-bundles"));
+    let root = cache_dir.join(&name);
+
+    if matches_manifest(&root, manifest) {
+        return Ok(root);
+    }
+
+    let staging = cache_dir.join(format!("{}.tmp-{}", name, std::process::id()));
+    let write_error = |error: std::io::Error| {
+        format!("Cannot extract bundle into '{}': {}", cache_dir.display(), error)
+    };
+    let _ = fs::remove_dir_all(&staging);
+    for (relative, source) in &manifest.files {
+        let path = bundle_path(&staging, relative)?;
+        if let Some(parent) = path.parent() {
+            fs::create_dir_all(parent).map_err(write_error)?;
+        }
+        fs::write(&path, source).map_err(write_error)?;
+    }
+
+    // A stale or modified extraction is replaced as a whole
+    let _ = fs::remove_dir_all(&root);
+    if let Err(error) = fs::rename(&staging, &root) {
+        let _ = fs::remove_dir_all(&staging);
+        // Another process extracted the same bundle first
+        if !matches_manifest(&root, manifest) {
+            return Err(write_error(error));
+        }
+    }
+    Ok(root)
+}
+
+fn matches_manifest(root: &Path, manifest: &BundleManifest) -> bool {
+    manifest.files.iter().all(|(relative, source)| {
+        bundle_path(root, relative)
+            .ok()
+            .and_then(|path| fs::read_to_string(path).ok())
+            .is_some_and(|existing| existing == *source)
+    })
+}
+
+fn bundle_path(root: &Path, relative: &str) -> Result<PathBuf, String> {
+    if relative == "." {
+        return Ok(root.to_path_buf());
+    }
+    path_security::sanitize_relative_path(relative, "bundle path").map(|path| root.join(path))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn payload_range_finds_only_complete_trailers() {
+        let mut image = b"runtime".to_vec();
+        assert_eq!(payload_range(&image), None);
+
+        image.extend_from_slice(b"{}");
+        image.extend_from_slice(&2u64.to_le_bytes());
+        image.extend_from_slice(BUNDLE_MAGIC);
+        assert_eq!(payload_range(&image), Some(7..9));
+
+        let mut truncated = BUNDLE_MAGIC.to_vec();
+        truncated.splice(0..0, 99u64.to_le_bytes());
+        assert_eq!(payload_range(&truncated), None);
+    }
+
+    #[test]
+    fn common_root_is_the_deepest_shared_directory() {
+        let dirs =
+            [Path::new("/work/app/src"), Path::new("/work/app/lib/x"), Path::new("/work/app")];
+        assert_eq!(common_root(dirs.into_iter()), PathBuf::from("/work/app"));
+        assert_eq!(common_root([Path::new("/a/b")].into_iter()), PathBuf::from("/a/b"));
+    }
+}
@@ -3,10 +3,11 @@
 // Bytecode instruction definitions and structures for the Ruff VM.
 // Defines OpCode enum representing all bytecode instructions and supporting types.
 
+use serde::{Deserialize, Serialize};
 use std::collections::HashMap;
 use std::sync::Arc;
 
-#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
 pub enum BytecodeBindingKind {
     Mutable,
     LetImmutable,
@@ -15,7 +16,7 @@ pub enum BytecodeBindingKind {
 
 /// Bytecode instruction opcodes for the Ruff VM
 /// Stack-based virtual machine with separate value and call stacks
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[allow(dead_code)] // Many opcodes not yet used - VM is work in progress
 pub enum OpCode {
     // === Stack Operations ===
@@ -51,6 +52,12 @@ pub enum OpCode {
     /// Operand: variable name and binding kind.
     DefineGlobal(String, BytecodeBindingKind),
 
+    /// Define a named frame local with explicit mutability metadata.
+    /// Used for locals captured by nested closures, which live in shared cells
+    /// instead of slots once a closure captures them.
+    /// Operand: variable name and binding kind.
+    DefineLocal(String, BytecodeBindingKind),
+
     /// Ensure a global binding allows in-place mutation.
     /// Operand: variable name.
     EnsureMutableGlobalForMutation(String),
@@ -65,6 +72,10 @@ pub enum OpCode {
     /// Pop two values, add them, push result
     Add,
 
+    /// Concatenate the display forms of the top `count` values, as in `"${a} and ${b}"`
+    /// Stack: [part0, part1, ...] -> [string]
+    BuildString(usize),
+
     /// Add to variable in-place (avoids LoadVar/StoreVar)
     /// Operand: local slot index
     /// Stack: [rhs] -> [result]
@@ -162,6 +173,11 @@ pub enum OpCode {
     /// Operand: number of arguments
     Call(usize),
 
+    /// Call a function with spread or named arguments
+    /// Stack: [positional array, named values..., function]
+    /// Operand: the names of the named values, in order
+    CallNamed(Vec<String>),
+
     /// Return from function with value on stack
     Return,
 
@@ -229,6 +245,11 @@ pub enum OpCode {
     /// Pops one collection, pushes all its elements
     SpreadArray,
 
+    /// Unpack the array on the right of `a, b := pair` into one value per target
+    /// Stack: [array] -> [first, ..., last]
+    /// Raises a catchable runtime error for a non-array or an element count mismatch
+    UnpackArray(usize),
+
     /// Spread an array as function arguments
     SpreadArgs,
 
@@ -242,11 +263,18 @@ pub enum OpCode {
     /// Operand: pattern index in constant pool and binding kind for introduced names
     MatchPattern(usize, BytecodeBindingKind),
 
-    /// Match a value against a `match case` pattern string, with optional binding support
-    /// Stack: [value] -> [success: bool]
-    /// If match succeeds and the case has a binding (e.g. `Result::Ok(v)`), bind it
-    /// Operand: match-case pattern string
-    MatchCasePattern(String),
+    /// Bind a `let` destructuring pattern against a value
+    /// Stack: [value] -> [value]
+    /// Raises a catchable runtime error when the value does not fit the pattern
+    /// (wrong container type, or an array length mismatch for patterns without `...rest`)
+    /// Operand: pattern index in constant pool and binding kind for introduced names
+    DestructurePattern(usize, BytecodeBindingKind),
+
+    /// Match a value against a `match case` pattern
+    /// Stack: [value] -> [value, success: bool]
+    /// If match succeeds, the names the pattern binds (e.g. `v` in `Ok(v)`) are bound
+    /// Operand: match-case pattern index in constant pool
+    MatchCasePattern(usize),
 
     /// Start a new match case branch
     /// Used for organizing match statement bytecode
@@ -272,6 +300,11 @@ pub enum OpCode {
     /// Stack: [] -> [Option::None]
     MakeNone,
 
+    /// Create an enum variant value such as `Status::Failure(reason)`
+    /// Stack: [arg0, arg1, ...] -> [Tagged], args stored as fields `$0`, `$1`, ...
+    /// Operand: (variant tag, argument count)
+    MakeTagged(String, usize),
+
     /// Try operator: propagate errors or unwrap success
     /// If Result::Err or Option::None, early return with that value
     /// Otherwise, unwrap the inner value
@@ -283,6 +316,16 @@ pub enum OpCode {
     /// Operand: (struct_name, field_names)
     MakeStruct(String, Vec<String>),
 
+    /// Push a struct definition, which is callable as a positional constructor; its
+    /// methods are read from the `Name.method` globals compiled just before it
+    /// Operand: (struct_name, declared field_names, method_names)
+    MakeStructDef(String, Vec<String>, Vec<String>),
+
+    /// Check that the struct definition on top of the stack implements each named
+    /// interface, raising a runtime error on the first missing method
+    /// Stack: [StructDef] -> [StructDef]
+    CheckImplements(Vec<String>),
+
     // === Environment Management ===
     /// Push a new scope (for blocks, functions)
     PushScope,
@@ -395,6 +438,10 @@ pub enum OpCode {
     /// Stack: [channel] -> [value]
     ChannelRecv,
 
+    /// Run a zero-argument closure on a new OS thread (the body of `spawn`)
+    /// Stack: [closure] -> []
+    SpawnThread,
+
     // === Debugging ===
     /// Print current stack state (for debugging)
     #[allow(dead_code)]
@@ -410,7 +457,7 @@ pub enum OpCode {
 }
 
 /// A compiled bytecode chunk containing instructions and constants
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub struct BytecodeChunk {
     /// The bytecode instructions
     pub instructions: Vec<OpCode>,
@@ -428,6 +475,12 @@ pub struct BytecodeChunk {
     /// Parameter names for functions
     pub params: Vec<String>,
 
+    /// Literal default per parameter; empty when no parameter has one
+    pub param_defaults: Vec<Option<Constant>>,
+
+    /// Whether the last parameter collects extra positional arguments (`...rest`)
+    pub is_variadic: bool,
+
     /// Local variable names by slot index
     pub local_names: Vec<String>,
 
@@ -443,6 +496,9 @@ pub struct BytecodeChunk {
     /// Upvalue names for closures (variables captured from outer scope)
     pub upvalues: Vec<String>,
 
+    /// Locals this chunk declares by name (`DefineLocal`) because a closure captures them
+    pub captured_local_kinds: HashMap<String, BytecodeBindingKind>,
+
     /// Whether this is a generator function
     pub is_generator: bool,
 
@@ -459,11 +515,14 @@ impl BytecodeChunk {
             source_map: HashMap::new(),
             name: None,
             params: Vec::new(),
+            param_defaults: Vec::new(),
+            is_variadic: false,
             local_names: Vec::new(),
             local_binding_kinds: Vec::new(),
             local_count: 0,
             exception_handlers: Vec::new(),
             upvalues: Vec::new(),
+            captured_local_kinds: HashMap::new(),
             is_generator: false,
             is_async: false,
         }
@@ -488,6 +547,20 @@ impl BytecodeChunk {
         index
     }
 
+    /// Attribute the instructions emitted from here on to the statement at `line:column`.
+    pub fn mark_source_position(&mut self, line: usize, column: usize) {
+        self.source_map.insert(self.instructions.len(), (line, column));
+    }
+
+    /// Position of the statement that emitted the instruction at `ip`, if it was marked.
+    pub fn source_position_at(&self, ip: usize) -> Option<(usize, usize)> {
+        self.source_map
+            .iter()
+            .filter(|(index, _)| **index <= ip)
+            .max_by_key(|(index, _)| **index)
+            .map(|(_, position)| *position)
+    }
+
     /// Patch a jump instruction at the given index with the current position
     pub fn patch_jump(&mut self, jump_index: usize) {
         let target = self.instructions.len();
@@ -517,7 +590,7 @@ impl BytecodeChunk {
 }
 
 /// Constants that can be stored in the constant pool
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 #[allow(dead_code)] // Not all variants used yet - VM is work in progress
 pub enum Constant {
     Int(i64),
@@ -526,9 +599,13 @@ pub enum Constant {
     Bool(bool),
     None,
     /// A compiled function (stored as bytecode chunk)
-    Function(Box<BytecodeChunk>),
+    Function(Arc<BytecodeChunk>),
     /// Pattern for matching (stored AST pattern)
     Pattern(crate::ast::Pattern),
+    /// Pattern tested by a `match` case
+    MatchCase(crate::ast::MatchPattern),
+    /// Interface name and the method signatures it requires
+    Interface(String, Vec<crate::ast::InterfaceMethod>),
     /// Type annotation for runtime type checking
     Type(crate::ast::TypeAnnotation),
     /// Array of constants (for nested structures)
@@ -550,7 +627,7 @@ pub struct CompiledFunction {
 }
 
 /// Exception handler entry for try/catch
-#[derive(Debug, Clone, PartialEq)]
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
 pub struct ExceptionHandler {
     /// Start of try block (instruction index)
     pub try_start: usize,
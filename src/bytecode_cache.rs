@@ -0,0 +1,192 @@
+// File: src/bytecode_cache.rs
+//
+// On-disk cache of compiled Ruff programs (`.ruffc` files).
+//
+// `ruff run` stores the bytecode it compiled for the entry script, and the module
+// loader stores the parsed program of each imported module, so later runs skip
+// lexing, parsing, and compilation for unchanged files. Entries are keyed by a
+// SHA-256 of the source text, its path, and the running executable: editing a file
+// or installing another build of Ruff misses the old entry instead of reading it.
+// `ruff run --no-cache` or `RUFF_NO_CACHE=1` turns the cache off.
+
+use crate::ast::Stmt;
+use crate::bytecode::BytecodeChunk;
+use serde::de::DeserializeOwned;
+use serde::Serialize;
+use sha2::{Digest, Sha256};
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::OnceLock;
+
+/// File extension of cache entries.
+pub const CACHE_FILE_EXTENSION: &str = "ruffc";
+/// Bumped whenever the serialized shape of chunks or statements changes.
+const CACHE_FORMAT_VERSION: u32 = 4;
+/// Default cache location under the user's home directory.
+const USER_CACHE_DIR: &str = ".ruff/cache/bytecode";
+
+static CACHE_DISABLED: AtomicBool = AtomicBool::new(false);
+
+/// Turns the cache off for the rest of the process (`ruff run --no-cache`).
+pub fn disable() {
+    CACHE_DISABLED.store(true, Ordering::Relaxed);
+}
+
+fn enabled() -> bool {
+    if CACHE_DISABLED.load(Ordering::Relaxed) {
+        return false;
+    }
+    !matches!(std::env::var("RUFF_NO_CACHE"), Ok(value) if !value.is_empty() && value != "0")
+}
+
+/// Directory holding cache entries: `RUFF_CACHE_DIR`, else `~/.ruff/cache/bytecode`.
+pub fn cache_dir() -> PathBuf {
+    if let Some(dir) = std::env::var_os("RUFF_CACHE_DIR").filter(|dir| !dir.is_empty()) {
+        return PathBuf::from(dir);
+    }
+    std::env::var_os("HOME")
+        .map(|home| PathBuf::from(home).join(USER_CACHE_DIR))
+        .unwrap_or_else(|| std::env::temp_dir().join("ruff-bytecode-cache"))
+}
+
+/// Compiled bytecode for the entry script at `path`, if `source` was compiled before.
+pub fn load_chunk(path: &Path, source: &str) -> Option<BytecodeChunk> {
+    load(&cache_dir(), "chunk", path, source)
+}
+
+pub fn store_chunk(path: &Path, source: &str, chunk: &BytecodeChunk) {
+    store(&cache_dir(), "chunk", path, source, chunk);
+}
+
+/// Parsed statements for the module at `path`, if `source` was parsed before.
+pub fn load_module_program(path: &Path, source: &str) -> Option<Vec<Stmt>> {
+    load(&cache_dir(), "module", path, source)
+}
+
+pub fn store_module_program(path: &Path, source: &str, program: &[Stmt]) {
+    store(&cache_dir(), "module", path, source, &program);
+}
+
+fn load<T: DeserializeOwned>(dir: &Path, kind: &str, path: &Path, source: &str) -> Option<T> {
+    if !enabled() {
+        return None;
+    }
+    let bytes = fs::read(entry_path(dir, kind, path, source)).ok()?;
+    // An unreadable entry is a miss; the caller recompiles and overwrites it
+    serde_json::from_slice(&bytes).ok()
+}
+
+/// Best effort: a cache that cannot be written only costs the next run its speedup.
+fn store<T: Serialize + ?Sized>(dir: &Path, kind: &str, path: &Path, source: &str, value: &T) {
+    if !enabled() || fs::create_dir_all(dir).is_err() {
+        return;
+    }
+    let Ok(bytes) = serde_json::to_vec(value) else {
+        return;
+    };
+    let entry = entry_path(dir, kind, path, source);
+    let staging =
+        entry.with_extension(format!("{}.tmp-{}", CACHE_FILE_EXTENSION, std::process::id()));
+    if fs::write(&staging, bytes).is_ok() && fs::rename(&staging, &entry).is_err() {
+        let _ = fs::remove_file(&staging);
+    }
+}
+
+fn entry_path(dir: &Path, kind: &str, path: &Path, source: &str) -> PathBuf {
+    let mut hasher = Sha256::new();
+    hasher.update(CACHE_FORMAT_VERSION.to_le_bytes());
+    hasher.update(runtime_identity().as_bytes());
+    hasher.update(kind.as_bytes());
+    hasher.update([0]);
+    hasher.update(
+        fs::canonicalize(path).unwrap_or_else(|_| path.to_path_buf()).to_string_lossy().as_bytes(),
+    );
+    hasher.update([0]);
+    hasher.update(source.as_bytes());
+    let digest: String = hasher.finalize().iter().map(|byte| format!("{:02x}", byte)).collect();
+    dir.join(format!("{}.{}", digest, CACHE_FILE_EXTENSION))
+}
+
+/// Identifies the running build, so a rebuilt compiler never reads older bytecode.
+fn runtime_identity() -> &'static str {
+    static IDENTITY: OnceLock<String> = OnceLock::new();
+    IDENTITY.get_or_init(|| {
+        let executable = std::env::current_exe()
+            .ok()
+            .and_then(|path| fs::metadata(path).ok())
+            .map(|metadata| {
+                let modified = metadata
+                    .modified()
+                    .ok()
+                    .and_then(|time| time.duration_since(std::time::UNIX_EPOCH).ok())
+                    .map(|elapsed| elapsed.as_nanos())
+                    .unwrap_or_default();
+                format!("{}:{}", metadata.len(), modified)
+            })
+            .unwrap_or_default();
+        format!("{}:{}", env!("CARGO_PKG_VERSION"), executable)
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::Compiler;
+    use crate::lexer::tokenize;
+    use crate::parser::Parser;
+
+    fn parse(source: &str) -> Vec<Stmt> {
+        Parser::new(tokenize(source).expect("source should tokenize")).parse()
+    }
+
+    fn temp_cache_dir(name: &str) -> PathBuf {
+        let dir = std::env::temp_dir().join(format!(
+            "ruff_bytecode_cache_{}_{}",
+            name,
+            std::process::id()
+        ));
+        let _ = fs::remove_dir_all(&dir);
+        dir
+    }
+
+    #[test]
+    fn chunks_round_trip_and_miss_when_the_source_changes() {
+        let dir = temp_cache_dir("round_trip");
+        let source =
+            "func add(a, b) { return a + b }\nmatch add(1, 2) { case 3: { print(\"three\") } }\n";
+        let chunk = Compiler::new().compile(&parse(source)).expect("source should compile");
+        let path = Path::new("/virtual/app.ruff");
+
+        assert!(load::<BytecodeChunk>(&dir, "chunk", path, source).is_none());
+        store(&dir, "chunk", path, source, &chunk);
+        let cached: BytecodeChunk =
+            load(&dir, "chunk", path, source).expect("entry should be cached");
+        assert_eq!(cached, chunk);
+
+        let edited = source.replace("a + b", "a - b");
+        assert!(load::<BytecodeChunk>(&dir, "chunk", path, &edited).is_none());
+        assert!(load::<BytecodeChunk>(&dir, "chunk", Path::new("/virtual/other.ruff"), source)
+            .is_none());
+        assert!(load::<Vec<Stmt>>(&dir, "module", path, source).is_none());
+
+        let _ = fs::remove_dir_all(dir);
+    }
+
+    #[test]
+    fn corrupt_entries_are_misses() {
+        let dir = temp_cache_dir("corrupt");
+        let path = Path::new("/virtual/lib.ruff");
+        let program = parse("export func twice(x) { return x * 2 }");
+        store(&dir, "module", path, "src", program.as_slice());
+        assert_eq!(
+            load::<Vec<Stmt>>(&dir, "module", path, "src").map(|stmts| stmts.len()),
+            Some(program.len())
+        );
+
+        fs::write(entry_path(&dir, "module", path, "src"), b"{not json").unwrap();
+        assert!(load::<Vec<Stmt>>(&dir, "module", path, "src").is_none());
+
+        let _ = fs::remove_dir_all(dir);
+    }
+}
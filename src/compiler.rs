@@ -3,11 +3,12 @@
 // Bytecode compiler for the Ruff programming language.
 // Compiles AST nodes into bytecode instructions for the VM.
 
-use crate::ast::{ArrayElement, DictElement, Expr, Pattern, Stmt};
+use crate::ast::{ArrayElement, DictElement, Expr, MatchCase, Pattern, Stmt};
 use crate::bytecode::{BytecodeBindingKind, BytecodeChunk, Constant, OpCode};
-use crate::errors::unsupported_struct_generator_method_message;
+use crate::errors::{unknown_struct_field_message, unsupported_struct_generator_method_message};
 use crate::optimizer::Optimizer;
-use std::collections::HashSet;
+use std::borrow::Cow;
+use std::collections::{HashMap, HashSet};
 use std::sync::Arc;
 
 /// Compiler state for generating bytecode from AST
@@ -16,11 +17,14 @@ pub struct Compiler {
     /// Current bytecode chunk being compiled
     chunk: BytecodeChunk,
 
-    /// Stack of loop start positions for break/continue
-    loop_starts: Vec<usize>,
+    /// Enclosing loops for break/continue, innermost last
+    loops: Vec<LoopContext>,
 
-    /// Stack of loop end jump indices to patch for break statements
-    loop_ends: Vec<Vec<usize>>,
+    /// Enclosing `try` statements that early exits must close, innermost last
+    try_regions: Vec<TryRegion>,
+
+    /// Label from `LabeledLoop`, consumed by the loop statement it wraps
+    pending_loop_label: Option<String>,
 
     /// Current scope depth (0 = global)
     scope_depth: usize,
@@ -37,6 +41,11 @@ pub struct Compiler {
     /// Variables that are read in this compiler scope
     used_locals: HashSet<String>,
 
+    /// Locals captured by closures nested in this function body. They never get
+    /// slots: they are accessed by name so the VM can share one cell between the
+    /// enclosing frame and every closure that captures them.
+    captured_locals: HashSet<String>,
+
     /// Stack of local counts for nested lexical scopes.
     scope_markers: Vec<usize>,
 
@@ -58,6 +67,42 @@ pub struct Compiler {
     /// Whether this compiler instance can use local slots (function/method/lambda bodies).
     /// The root script compiler keeps declarations in the runtime environment instead.
     uses_local_slots: bool,
+
+    /// Number of runtime environment scopes (`PushScope`) currently open in this chunk.
+    runtime_scope_depth: usize,
+
+    /// Declared field names of each struct visible to this chunk, used to reject
+    /// struct literals that name an undeclared field.
+    struct_fields: HashMap<String, Vec<String>>,
+}
+
+/// Break/continue bookkeeping for one enclosing loop.
+#[derive(Debug, Clone)]
+struct LoopContext {
+    label: Option<String>,
+    /// Backward `continue` target, or `None` when it is compiled after the body (`for`
+    /// loops continue at their index increment) and `continue_jumps` are patched instead.
+    continue_target: Option<usize>,
+    /// Runtime scope depth at loop entry; break/continue unwind back to it.
+    runtime_scope_depth: usize,
+    /// Number of enclosing `try` regions at loop entry; break/continue close the rest.
+    try_depth: usize,
+    break_jumps: Vec<usize>,
+    continue_jumps: Vec<usize>,
+}
+
+/// A `try` statement whose body is being compiled.
+///
+/// `return`, `break`, and `continue` jump out of it without reaching its `EndTry` or
+/// `finally` code, so they emit both themselves.
+#[derive(Debug, Clone)]
+struct TryRegion {
+    /// Whether a `BeginTry` handler is live for the code being compiled: always in the
+    /// `try` body, and in the `except` body only when a `finally` block must run after it.
+    handler_active: bool,
+    finally_block: Option<Vec<Stmt>>,
+    /// Runtime scope depth where the `try` statement starts.
+    runtime_scope_depth: usize,
 }
 
 #[derive(Debug, Clone)]
@@ -71,9 +116,10 @@ struct Local {
 
 #[allow(dead_code)] // Compiler not yet integrated into execution path
 impl Compiler {
+    /// Decorated functions are not hoisted: their decorators run in program order.
     fn is_hoistable_top_level_function(stmt: &Stmt) -> bool {
         match stmt {
-            Stmt::FuncDef { .. } => true,
+            Stmt::FuncDef { decorators, .. } => decorators.is_empty(),
             Stmt::Export { stmt } => matches!(stmt.as_ref(), Stmt::FuncDef { .. }),
             _ => false,
         }
@@ -82,19 +128,23 @@ impl Compiler {
     pub fn new() -> Self {
         Self {
             chunk: BytecodeChunk::new(),
-            loop_starts: Vec::new(),
-            loop_ends: Vec::new(),
+            loops: Vec::new(),
+            try_regions: Vec::new(),
+            pending_loop_label: None,
             scope_depth: 0,
             locals: Vec::new(),
             next_local_slot: 0,
             upvalue_names: HashSet::new(),
             used_locals: HashSet::new(),
+            captured_locals: HashSet::new(),
             scope_markers: Vec::new(),
             parent: None,
             has_logical_short_circuit: false,
             has_exception_flow: false,
             has_method_call_flow: false,
             uses_local_slots: false,
+            runtime_scope_depth: 0,
+            struct_fields: HashMap::new(),
         }
     }
 
@@ -111,6 +161,14 @@ impl Compiler {
     ) -> Result<BytecodeChunk, String> {
         self.used_locals = Self::collect_used_variables(statements);
 
+        // Record top-level struct fields first so hoisted functions can check literals.
+        for stmt in statements {
+            if let Stmt::StructDef { name, fields, .. } = stmt {
+                self.struct_fields
+                    .insert(name.clone(), fields.iter().map(|(field, _)| field.clone()).collect());
+            }
+        }
+
         // Hoist top-level function declarations so calls can appear earlier in the file.
         for stmt in statements {
             if Self::is_hoistable_top_level_function(stmt) {
@@ -149,11 +207,13 @@ impl Compiler {
             // Log optimization stats in debug mode
             if cfg!(debug_assertions) {
                 if optimizer.stats.constants_folded > 0
+                    || optimizer.stats.branches_eliminated > 0
                     || optimizer.stats.dead_instructions_removed > 0
                     || optimizer.stats.peephole_optimizations > 0
                 {
-                    eprintln!("Compiler optimization: {} constants folded, {} dead instructions removed, {} peephole optimizations",
+                    eprintln!("Compiler optimization: {} constants folded, {} branches eliminated, {} dead instructions removed, {} peephole optimizations",
                         optimizer.stats.constants_folded,
+                        optimizer.stats.branches_eliminated,
                         optimizer.stats.dead_instructions_removed,
                         optimizer.stats.peephole_optimizations);
                 }
@@ -184,7 +244,7 @@ impl Compiler {
     }
 
     fn resolve_local_slot(&self, name: &str) -> Option<usize> {
-        if !self.uses_local_slots {
+        if !self.uses_local_slots || self.captured_locals.contains(name) {
             return None;
         }
 
@@ -199,8 +259,50 @@ impl Compiler {
         self.locals.iter().rev().any(|local| local.depth == self.scope_depth && local.name == name)
     }
 
-    fn is_inside_loop(&self) -> bool {
-        !self.loop_starts.is_empty()
+    fn begin_loop(&mut self, continue_target: Option<usize>) {
+        self.loops.push(LoopContext {
+            label: self.pending_loop_label.take(),
+            continue_target,
+            runtime_scope_depth: self.runtime_scope_depth,
+            try_depth: self.try_regions.len(),
+            break_jumps: Vec::new(),
+            continue_jumps: Vec::new(),
+        });
+    }
+
+    /// Point pending forward `continue` jumps of the innermost loop at the current position.
+    fn patch_loop_continues(&mut self) {
+        if let Some(context) = self.loops.last_mut() {
+            for continue_jump in std::mem::take(&mut context.continue_jumps) {
+                self.chunk.patch_jump(continue_jump);
+            }
+        }
+    }
+
+    /// Pop the innermost loop and point its `break` jumps at the current position.
+    fn end_loop(&mut self) {
+        if let Some(context) = self.loops.pop() {
+            for break_jump in context.break_jumps {
+                self.chunk.patch_jump(break_jump);
+            }
+        }
+    }
+
+    /// Index into `loops` of the loop targeted by `break`/`continue` with an optional label.
+    fn resolve_loop_target(&self, keyword: &str, label: Option<&String>) -> Result<usize, String> {
+        if self.loops.is_empty() {
+            return Err(format!("{} can only be used inside a loop", keyword));
+        }
+        match label {
+            None => Ok(self.loops.len() - 1),
+            Some(label) => self
+                .loops
+                .iter()
+                .rposition(|context| context.label.as_ref() == Some(label))
+                .ok_or_else(|| {
+                    format!("{} label '{}' does not name an enclosing loop", keyword, label)
+                }),
+        }
     }
 
     fn declare_local(
@@ -215,6 +317,19 @@ impl Compiler {
         Ok(self.add_local(name, self.scope_depth, binding_kind))
     }
 
+    /// Declare a closure-captured local by name instead of in a slot.
+    fn declare_captured_local(
+        &mut self,
+        name: &str,
+        binding_kind: BytecodeBindingKind,
+    ) -> Result<(), String> {
+        // Still track the declaration so duplicate checks and shadowing behave as for slots.
+        self.declare_local(name, binding_kind)?;
+        self.chunk.captured_local_kinds.entry(name.to_string()).or_insert(binding_kind);
+        self.chunk.emit(OpCode::DefineLocal(name.to_string(), binding_kind));
+        Ok(())
+    }
+
     fn enter_scope(&mut self) {
         self.scope_markers.push(self.locals.len());
         self.scope_depth += 1;
@@ -227,6 +342,55 @@ impl Compiler {
         self.scope_depth = self.scope_depth.saturating_sub(1);
     }
 
+    fn push_runtime_scope(&mut self) {
+        self.chunk.emit(OpCode::PushScope);
+        self.runtime_scope_depth += 1;
+    }
+
+    fn pop_runtime_scope(&mut self) {
+        self.chunk.emit(OpCode::PopScope);
+        self.runtime_scope_depth = self.runtime_scope_depth.saturating_sub(1);
+    }
+
+    /// Emit `PopScope` for every runtime scope opened since `target_depth`.
+    ///
+    /// A `return` inside a block jumps past the `PopScope` that closes it, so it must
+    /// unwind those scopes itself or the environment's scope stack grows on every call.
+    fn emit_runtime_scope_unwind(&mut self, target_depth: usize) {
+        for _ in target_depth..self.runtime_scope_depth {
+            self.chunk.emit(OpCode::PopScope);
+        }
+    }
+
+    /// Emit the cleanup for a jump out to runtime scope `scope_depth` that leaves every
+    /// `try` region from `try_depth` on: each region's open scopes are popped, then its
+    /// handler, and then its `finally` block runs, innermost region first.
+    fn emit_early_exit(&mut self, try_depth: usize, scope_depth: usize) -> Result<(), String> {
+        let exit_scope_depth = self.runtime_scope_depth;
+        let mut closed_regions = Vec::new();
+        let mut result = Ok(());
+        while result.is_ok() && self.try_regions.len() > try_depth {
+            let Some(region) = self.try_regions.pop() else { break };
+            // Scopes opened inside this region close before its finally block runs
+            self.emit_runtime_scope_unwind(region.runtime_scope_depth);
+            self.runtime_scope_depth = region.runtime_scope_depth;
+            if region.handler_active {
+                self.chunk.emit(OpCode::EndTry);
+            }
+            // With the region popped, exits inside its finally block only close outer ones
+            if let Some(finally_block) = &region.finally_block {
+                result = self.compile_stmt(&Stmt::Block(finally_block.clone()));
+            }
+            closed_regions.push(region);
+        }
+        if result.is_ok() {
+            self.emit_runtime_scope_unwind(scope_depth);
+        }
+        self.runtime_scope_depth = exit_scope_depth;
+        self.try_regions.extend(closed_regions.into_iter().rev());
+        result
+    }
+
     fn is_upvalue(&self, name: &str) -> bool {
         self.upvalue_names.contains(name)
     }
@@ -259,6 +423,21 @@ impl Compiler {
                 Ok(())
             }
 
+            Stmt::MultiAssign { targets, values } => {
+                // Evaluate every value first, then store from the top of the stack down
+                for value in values {
+                    self.compile_expr(value)?;
+                }
+                if values.len() == 1 && targets.len() > 1 {
+                    self.chunk.emit(OpCode::UnpackArray(targets.len()));
+                }
+                for target in targets.iter().rev() {
+                    self.compile_assignment(target)?;
+                    self.chunk.emit(OpCode::Pop);
+                }
+                Ok(())
+            }
+
             Stmt::Assign { target, value } => {
                 if let (Expr::Identifier(target_name), Expr::BinaryOp { left, op, right }) =
                     (target, value)
@@ -320,13 +499,13 @@ impl Compiler {
                 self.chunk.emit(OpCode::Pop); // Pop condition
 
                 // Compile then block
-                self.chunk.emit(OpCode::PushScope);
+                self.push_runtime_scope();
                 self.enter_scope();
                 for stmt in then_branch {
                     self.compile_stmt(stmt)?;
                 }
                 self.exit_scope();
-                self.chunk.emit(OpCode::PopScope);
+                self.pop_runtime_scope();
 
                 // Jump over else block
                 let end_jump = self.chunk.emit(OpCode::Jump(0));
@@ -337,13 +516,13 @@ impl Compiler {
 
                 // Compile else block if present
                 if let Some(else_stmts) = else_branch {
-                    self.chunk.emit(OpCode::PushScope);
+                    self.push_runtime_scope();
                     self.enter_scope();
                     for stmt in else_stmts {
                         self.compile_stmt(stmt)?;
                     }
                     self.exit_scope();
-                    self.chunk.emit(OpCode::PopScope);
+                    self.pop_runtime_scope();
                 }
 
                 // Patch end jump
@@ -353,8 +532,9 @@ impl Compiler {
             }
 
             Stmt::While { condition, body, .. } => {
+                let pattern_body = Self::without_source_positions(body);
                 if let Some((target_slot, index_slot, limit_slot, append_char)) =
-                    self.match_append_char_until_local_pattern(condition, body)
+                    self.match_append_char_until_local_pattern(condition, &pattern_body)
                 {
                     self.chunk.emit(OpCode::AppendConstCharUntilLocalInPlace(
                         target_slot,
@@ -365,8 +545,8 @@ impl Compiler {
                     return Ok(());
                 }
 
-                if let Some((map_slot, index_slot, limit_slot)) =
-                    self.match_fill_int_map_with_double_until_local_pattern(condition, body)
+                if let Some((map_slot, index_slot, limit_slot)) = self
+                    .match_fill_int_map_with_double_until_local_pattern(condition, &pattern_body)
                 {
                     self.chunk.emit(OpCode::FillIntMapWithDoubleUntilLocalInPlace(
                         map_slot, index_slot, limit_slot,
@@ -375,7 +555,7 @@ impl Compiler {
                 }
 
                 if let Some((map_slot, sum_slot, index_slot, limit_slot)) =
-                    self.match_sum_int_map_until_local_pattern(condition, body)
+                    self.match_sum_int_map_until_local_pattern(condition, &pattern_body)
                 {
                     self.chunk.emit(OpCode::SumIntMapUntilLocalInPlace(
                         map_slot, sum_slot, index_slot, limit_slot,
@@ -384,8 +564,7 @@ impl Compiler {
                 }
 
                 let loop_start = self.chunk.instructions.len();
-                self.loop_starts.push(loop_start);
-                self.loop_ends.push(Vec::new());
+                self.begin_loop(Some(loop_start));
 
                 // Compile condition
                 self.compile_expr(condition)?;
@@ -409,12 +588,7 @@ impl Compiler {
                 self.chunk.emit(OpCode::Pop); // Pop condition
 
                 // Patch all break statements
-                if let Some(breaks) = self.loop_ends.pop() {
-                    for break_jump in breaks {
-                        self.chunk.patch_jump(break_jump);
-                    }
-                }
-                self.loop_starts.pop();
+                self.end_loop();
 
                 Ok(())
             }
@@ -424,7 +598,10 @@ impl Compiler {
                 // This is a simplified implementation
                 self.enter_scope();
 
-                let loop_var_slot = if self.uses_local_slots && !self.is_upvalue(var) {
+                let loop_var_slot = if self.uses_local_slots
+                    && !self.is_upvalue(var)
+                    && !self.captured_locals.contains(var)
+                {
                     Some(self.declare_local(var, BytecodeBindingKind::Mutable)?)
                 } else {
                     None
@@ -467,8 +644,7 @@ impl Compiler {
                 }
 
                 let loop_start = self.chunk.instructions.len();
-                self.loop_starts.push(loop_start);
-                self.loop_ends.push(Vec::new());
+                self.begin_loop(None);
 
                 // Load iterator and index
                 if let Some(slot) = iter_slot {
@@ -521,6 +697,9 @@ impl Compiler {
                     self.compile_stmt(stmt)?;
                 }
 
+                // `continue` skips the rest of the body but must still advance the index
+                self.patch_loop_continues();
+
                 // Increment index
                 if let Some(slot) = index_slot {
                     self.chunk.emit(OpCode::LoadLocal(slot));
@@ -544,12 +723,7 @@ impl Compiler {
                 self.chunk.emit(OpCode::Pop);
 
                 // Patch all break statements
-                if let Some(breaks) = self.loop_ends.pop() {
-                    for break_jump in breaks {
-                        self.chunk.patch_jump(break_jump);
-                    }
-                }
-                self.loop_starts.pop();
+                self.end_loop();
                 self.exit_scope();
 
                 Ok(())
@@ -558,44 +732,68 @@ impl Compiler {
             Stmt::Return(value) => {
                 if let Some(expr) = value {
                     self.compile_expr(expr)?;
+                    self.emit_early_exit(0, 0)?;
                     self.chunk.emit(OpCode::Return);
                 } else {
+                    self.emit_early_exit(0, 0)?;
                     self.chunk.emit(OpCode::ReturnNone);
                 }
                 Ok(())
             }
 
-            Stmt::Break => {
-                if !self.is_inside_loop() {
-                    return Err("break can only be used inside a loop".to_string());
-                }
+            Stmt::LabeledLoop { label, loop_stmt } => {
+                self.pending_loop_label = Some(label.clone());
+                self.compile_stmt(loop_stmt)
+            }
+
+            Stmt::Break(label) => {
+                let target = self.resolve_loop_target("break", label.as_ref())?;
 
-                // Add a jump that will be patched later
+                // Close block scopes and try regions opened inside the loop, then jump past
+                // it (patched later)
+                let LoopContext { try_depth, runtime_scope_depth, .. } = self.loops[target];
+                self.emit_early_exit(try_depth, runtime_scope_depth)?;
                 let jump_index = self.chunk.emit(OpCode::Jump(0));
-                if let Some(breaks) = self.loop_ends.last_mut() {
-                    breaks.push(jump_index);
-                }
+                self.loops[target].break_jumps.push(jump_index);
                 Ok(())
             }
 
-            Stmt::Continue => {
-                if !self.is_inside_loop() {
-                    return Err("continue can only be used inside a loop".to_string());
-                }
+            Stmt::Continue(label) => {
+                let target = self.resolve_loop_target("continue", label.as_ref())?;
 
-                // Jump back to loop start
-                if let Some(&loop_start) = self.loop_starts.last() {
-                    self.chunk.emit(OpCode::JumpBack(loop_start));
+                let LoopContext { try_depth, runtime_scope_depth, .. } = self.loops[target];
+                self.emit_early_exit(try_depth, runtime_scope_depth)?;
+                match self.loops[target].continue_target {
+                    Some(loop_start) => {
+                        self.chunk.emit(OpCode::JumpBack(loop_start));
+                    }
+                    None => {
+                        let jump_index = self.chunk.emit(OpCode::Jump(0));
+                        self.loops[target].continue_jumps.push(jump_index);
+                    }
                 }
                 Ok(())
             }
 
-            Stmt::FuncDef { name, params, body, is_async, is_generator, .. } => {
+            Stmt::FuncDef {
+                name,
+                params,
+                param_defaults,
+                is_variadic,
+                body,
+                is_async,
+                is_generator,
+                ..
+            } => {
                 // Create a new compiler for the function body
                 let mut func_compiler = Compiler::new();
+                func_compiler.struct_fields = self.struct_fields.clone();
                 func_compiler.used_locals = Self::collect_used_variables(body);
+                func_compiler.captured_locals = Self::find_captured_locals(body);
                 func_compiler.chunk.name = Some(name.clone());
                 func_compiler.chunk.params = params.clone();
+                func_compiler.chunk.param_defaults = Self::param_default_constants(param_defaults)?;
+                func_compiler.chunk.is_variadic = *is_variadic;
                 func_compiler.chunk.is_async = *is_async;
                 func_compiler.chunk.is_generator = *is_generator;
                 func_compiler.scope_depth = 1; // Functions create a new scope (not global)
@@ -628,21 +826,32 @@ impl Compiler {
 
                 // Add function as constant
                 let func_index =
-                    self.chunk.add_constant(Constant::Function(Box::new(func_compiler.chunk)));
+                    self.chunk.add_constant(Constant::Function(Arc::new(func_compiler.chunk)));
 
                 // Create closure and store in variable
                 self.chunk.emit(OpCode::MakeClosure(func_index));
                 self.chunk.emit(OpCode::StoreGlobal(name.clone()));
 
+                if let Some(rebinding) = stmt.decorator_rebinding() {
+                    self.compile_stmt(&rebinding)?;
+                }
+
                 Ok(())
             }
 
-            Stmt::StructDef { name, fields: _, methods } => {
+            Stmt::StructDef { name, fields, methods, implements } => {
+                let field_names: Vec<String> =
+                    fields.iter().map(|(field, _)| field.clone()).collect();
+                self.struct_fields.insert(name.clone(), field_names.clone());
+                let mut method_names = Vec::new();
+
                 // Compile struct methods into global bytecode functions
                 for method_stmt in methods {
                     if let Stmt::FuncDef {
                         name: method_name,
                         params,
+                        param_defaults,
+                        is_variadic,
                         body,
                         is_async,
                         is_generator,
@@ -657,9 +866,14 @@ impl Compiler {
                         }
 
                         let mut func_compiler = Compiler::new();
+                        func_compiler.struct_fields = self.struct_fields.clone();
                         func_compiler.used_locals = Self::collect_used_variables(body);
+                        func_compiler.captured_locals = Self::find_captured_locals(body);
                         func_compiler.chunk.name = Some(format!("{}.{}", name, method_name));
                         func_compiler.chunk.params = params.clone();
+                        func_compiler.chunk.param_defaults =
+                            Self::param_default_constants(param_defaults)?;
+                        func_compiler.chunk.is_variadic = *is_variadic;
                         func_compiler.chunk.is_async = *is_async;
                         func_compiler.chunk.is_generator = *is_generator;
                         func_compiler.scope_depth = 1;
@@ -689,81 +903,54 @@ impl Compiler {
                         func_compiler.chunk.local_count = func_compiler.next_local_slot;
                         let func_index = self
                             .chunk
-                            .add_constant(Constant::Function(Box::new(func_compiler.chunk)));
+                            .add_constant(Constant::Function(Arc::new(func_compiler.chunk)));
 
                         let global_name = format!("{}.{}", name, method_name);
                         self.chunk.emit(OpCode::MakeClosure(func_index));
                         self.chunk.emit(OpCode::StoreGlobal(global_name));
-                    }
-                }
+                        method_names.push(method_name.clone());
 
-                Ok(())
-            }
-
-            Stmt::Match { value, cases, default } => {
-                // Compile the value to match
-                self.compile_expr(value)?;
-
-                let mut case_jumps = Vec::new();
-                let mut end_jumps = Vec::new();
-
-                for (pattern_name, body) in cases {
-                    self.chunk.emit(OpCode::BeginCase);
-
-                    // Duplicate the value for matching
-                    self.chunk.emit(OpCode::Dup);
-
-                    // Match with case-pattern aware semantics (including tag-style bindings).
-                    self.chunk.emit(OpCode::MatchCasePattern(pattern_name.clone()));
-
-                    // If match fails, jump to next case
-                    let next_case_jump = self.chunk.emit(OpCode::JumpIfFalse(0));
-                    self.chunk.emit(OpCode::Pop); // Pop match result
-
-                    case_jumps.push(next_case_jump);
-
-                    // Pop the original value since we matched
-                    self.chunk.emit(OpCode::Pop);
-
-                    // Compile case body
-                    for stmt in body {
-                        self.compile_stmt(stmt)?;
+                        // `__add__` and friends also answer to the operator hook they
+                        // define, unless the struct spells the hook out itself
+                        if let Some(hook) =
+                            crate::ast::operator_methods::special_method_hook(method_name)
+                        {
+                            let defines_hook = methods.iter().any(|method| {
+                                matches!(method, Stmt::FuncDef { name, .. } if name == hook)
+                            });
+                            if !defines_hook {
+                                self.chunk.emit(OpCode::StoreGlobal(format!("{}.{}", name, hook)));
+                                method_names.push(hook.to_string());
+                            }
+                        }
                     }
-
-                    // Jump to end of match
-                    let end_jump = self.chunk.emit(OpCode::Jump(0));
-                    end_jumps.push(end_jump);
-
-                    // Patch the jump to next case
-                    self.chunk.patch_jump(next_case_jump);
-                    self.chunk.emit(OpCode::Pop); // Pop match result
-
-                    self.chunk.emit(OpCode::EndCase);
                 }
 
-                // Compile default case if present
-                if let Some(default_body) = default {
-                    self.chunk.emit(OpCode::Pop); // Pop the value
-                    for stmt in default_body {
-                        self.compile_stmt(stmt)?;
-                    }
-                } else {
-                    // Pop the original value if no case matched
-                    self.chunk.emit(OpCode::Pop);
+                // Bind the name to the definition so Point(3, 4) can construct instances
+                self.chunk.emit(OpCode::MakeStructDef(name.clone(), field_names, method_names));
+                if !implements.is_empty() {
+                    self.chunk.emit(OpCode::CheckImplements(implements.clone()));
                 }
+                self.chunk.emit(OpCode::StoreGlobal(name.clone()));
 
-                // Patch all end jumps
-                for end_jump in end_jumps {
-                    self.chunk.patch_jump(end_jump);
-                }
+                Ok(())
+            }
 
+            Stmt::InterfaceDef { name, methods } => {
+                let interface_index =
+                    self.chunk.add_constant(Constant::Interface(name.clone(), methods.clone()));
+                self.chunk.emit(OpCode::LoadConst(interface_index));
+                self.chunk.emit(OpCode::StoreGlobal(name.clone()));
                 Ok(())
             }
 
+            Stmt::Match { value, cases, default } => {
+                self.compile_match(value, cases, default.as_deref(), false)
+            }
+
             Stmt::Loop { condition, body } => {
                 let loop_start = self.chunk.instructions.len();
-                self.loop_starts.push(loop_start);
-                self.loop_ends.push(Vec::new());
+                self.begin_loop(Some(loop_start));
 
                 // If there's a condition, check it
                 if let Some(cond_expr) = condition {
@@ -799,17 +986,12 @@ impl Compiler {
                 }
 
                 // Patch all break statements
-                if let Some(breaks) = self.loop_ends.pop() {
-                    for break_jump in breaks {
-                        self.chunk.patch_jump(break_jump);
-                    }
-                }
-                self.loop_starts.pop();
+                self.end_loop();
 
                 Ok(())
             }
 
-            Stmt::TryExcept { try_block, except_var, except_block } => {
+            Stmt::TryExcept { try_block, except_var, except_block, finally_block } => {
                 self.has_exception_flow = true;
                 // Set up exception handler
                 let try_start = self.chunk.instructions.len();
@@ -818,9 +1000,15 @@ impl Compiler {
                 let begin_try_index = self.chunk.emit(OpCode::BeginTry(0));
 
                 // Compile try block
+                self.try_regions.push(TryRegion {
+                    handler_active: true,
+                    finally_block: finally_block.clone(),
+                    runtime_scope_depth: self.runtime_scope_depth,
+                });
                 for stmt in try_block {
                     self.compile_stmt(stmt)?;
                 }
+                self.try_regions.pop();
 
                 // End try block
                 self.chunk.emit(OpCode::EndTry);
@@ -837,11 +1025,27 @@ impl Compiler {
                 // Begin catch and bind exception to variable
                 self.chunk.emit(OpCode::BeginCatch(except_var.clone()));
 
+                // An error escaping the catch block must still run the finally block, so
+                // the catch block gets a handler of its own that jumps to a cleanup copy
+                let cleanup_try_index = finally_block.as_ref().map(|finally_block| {
+                    self.try_regions.push(TryRegion {
+                        handler_active: true,
+                        finally_block: Some(finally_block.clone()),
+                        runtime_scope_depth: self.runtime_scope_depth,
+                    });
+                    self.chunk.emit(OpCode::BeginTry(0))
+                });
+
                 // Compile catch block
                 for stmt in except_block {
                     self.compile_stmt(stmt)?;
                 }
 
+                if cleanup_try_index.is_some() {
+                    self.try_regions.pop();
+                    self.chunk.emit(OpCode::EndTry);
+                }
+
                 // End catch block
                 self.chunk.emit(OpCode::EndCatch);
 
@@ -857,12 +1061,27 @@ impl Compiler {
                     exception_var: except_var.clone(),
                 });
 
+                if let (Some(finally_block), Some(cleanup_try_index)) =
+                    (finally_block, cleanup_try_index)
+                {
+                    // Normal completion of the try or catch block
+                    self.compile_stmt(&Stmt::Block(finally_block.clone()))?;
+                    let skip_cleanup = self.chunk.emit(OpCode::Jump(0));
+
+                    // Error from the catch block: it sits on the stack while the finally
+                    // block runs, then is rethrown
+                    self.chunk.set_jump_target(cleanup_try_index, self.chunk.instructions.len());
+                    self.compile_stmt(&Stmt::Block(finally_block.clone()))?;
+                    self.chunk.emit(OpCode::Throw);
+                    self.chunk.patch_jump(skip_cleanup);
+                }
+
                 Ok(())
             }
 
             Stmt::Block(statements) => {
                 // Enter new scope
-                self.chunk.emit(OpCode::PushScope);
+                self.push_runtime_scope();
                 self.enter_scope();
 
                 // Compile block statements
@@ -872,7 +1091,7 @@ impl Compiler {
 
                 // Exit scope
                 self.exit_scope();
-                self.chunk.emit(OpCode::PopScope);
+                self.pop_runtime_scope();
 
                 Ok(())
             }
@@ -881,6 +1100,8 @@ impl Compiler {
                 self.compile_expr(value)?;
                 if !self.uses_local_slots || self.scope_depth == 0 {
                     self.chunk.emit(OpCode::DefineGlobal(name.clone(), BytecodeBindingKind::Const));
+                } else if self.captured_locals.contains(name) {
+                    self.declare_captured_local(name, BytecodeBindingKind::Const)?;
                 } else {
                     let slot = self.declare_local(name, BytecodeBindingKind::Const)?;
                     self.chunk.emit(OpCode::StoreLocal(slot));
@@ -902,31 +1123,9 @@ impl Compiler {
             }
 
             Stmt::Spawn { body } => {
-                // Spawn creates a background thread
-                // For now, compile as a lambda that gets executed asynchronously
-                // This is simplified - full implementation needs runtime support
-
-                // Create function for spawn body
-                let mut spawn_compiler = Compiler::new();
-                spawn_compiler.used_locals = Self::collect_used_variables(body);
-                spawn_compiler.chunk.name = Some("<spawn>".to_string());
-                spawn_compiler.scope_depth = 0;
-
-                for stmt in body {
-                    spawn_compiler.compile_stmt(stmt)?;
-                }
-
-                spawn_compiler.chunk.emit(OpCode::ReturnNone);
-
-                let func_index =
-                    self.chunk.add_constant(Constant::Function(Box::new(spawn_compiler.chunk)));
-
-                // Load function and call it (runtime will handle thread spawning)
-                self.chunk.emit(OpCode::MakeClosure(func_index));
-                // Deferred post-v1 runtime backlog: dedicated SpawnThread opcode (see docs/V1_SCOPE.md deferred runtime execution section)
-                // For now this will just create a closure
-                self.chunk.emit(OpCode::Pop); // Pop the closure for now
-
+                // The body becomes a zero-argument closure that the VM runs on a new thread.
+                self.compile_closure("<spawn>", &[], &[], false, body)?;
+                self.chunk.emit(OpCode::SpawnThread);
                 Ok(())
             }
 
@@ -956,6 +1155,18 @@ impl Compiler {
                 Ok(())
             }
 
+            Stmt::ImportPath { path, namespace } => {
+                let import_path_const = self.chunk.add_constant(Constant::String(path.clone()));
+                let namespace_const = self.chunk.add_constant(Constant::String(namespace.clone()));
+
+                self.chunk.emit(OpCode::LoadConst(import_path_const));
+                self.chunk.emit(OpCode::LoadConst(namespace_const));
+                self.chunk.emit(OpCode::CallNative("__vm_import_path".to_string(), 2));
+                self.chunk.emit(OpCode::Pop);
+
+                Ok(())
+            }
+
             Stmt::Test { .. }
             | Stmt::TestSetup { .. }
             | Stmt::TestTeardown { .. }
@@ -964,9 +1175,150 @@ impl Compiler {
                 // Test statements are executed by test runner
                 Ok(())
             }
+
+            Stmt::SourcePos { line, column } => {
+                self.chunk.mark_source_position(*line, *column);
+                Ok(())
+            }
         }
     }
 
+    /// Compile a `match` statement or expression. Each case tests a duplicate of the
+    /// value, then its guard; both failures fall through to the next case. The expression
+    /// form leaves the arm's trailing expression statement (or null) on the stack.
+    fn compile_match(
+        &mut self,
+        value: &Expr,
+        cases: &[MatchCase],
+        default: Option<&[Stmt]>,
+        produces_value: bool,
+    ) -> Result<(), String> {
+        // Compile the value to match
+        self.compile_expr(value)?;
+
+        let mut end_jumps = Vec::new();
+
+        for case in cases {
+            self.chunk.emit(OpCode::BeginCase);
+
+            // Duplicate the value for matching
+            self.chunk.emit(OpCode::Dup);
+
+            // Match with case-pattern aware semantics (including tag-style bindings).
+            let pattern_index = self.chunk.add_constant(Constant::MatchCase(case.pattern.clone()));
+            self.chunk.emit(OpCode::MatchCasePattern(pattern_index));
+
+            // If match fails, jump to next case
+            let mut next_case_jumps = vec![self.chunk.emit(OpCode::JumpIfFalse(0))];
+            self.chunk.emit(OpCode::Pop); // Pop match result
+
+            if let Some(guard) = &case.guard {
+                self.compile_expr(guard)?;
+                next_case_jumps.push(self.chunk.emit(OpCode::JumpIfFalse(0)));
+                self.chunk.emit(OpCode::Pop); // Pop guard result
+            }
+
+            // Pop the original value since we matched
+            self.chunk.emit(OpCode::Pop);
+
+            self.compile_match_body(&case.body, produces_value)?;
+
+            // Jump to end of match
+            end_jumps.push(self.chunk.emit(OpCode::Jump(0)));
+
+            // Patch the jumps to next case
+            for next_case_jump in next_case_jumps {
+                self.chunk.patch_jump(next_case_jump);
+            }
+            self.chunk.emit(OpCode::Pop); // Pop match or guard result
+
+            self.chunk.emit(OpCode::EndCase);
+        }
+
+        // Pop the original value, then run the default case if present
+        self.chunk.emit(OpCode::Pop);
+        match default {
+            Some(default_body) => self.compile_match_body(default_body, produces_value)?,
+            None if produces_value => {
+                let none_index = self.chunk.add_constant(Constant::None);
+                self.chunk.emit(OpCode::LoadConst(none_index));
+            }
+            None => {}
+        }
+
+        // Patch all end jumps
+        for end_jump in end_jumps {
+            self.chunk.patch_jump(end_jump);
+        }
+
+        Ok(())
+    }
+
+    fn compile_match_body(&mut self, body: &[Stmt], produces_value: bool) -> Result<(), String> {
+        if !produces_value {
+            for stmt in body {
+                self.compile_stmt(stmt)?;
+            }
+            return Ok(());
+        }
+
+        if let Some((Stmt::ExprStmt(result), leading)) = body.split_last() {
+            for stmt in leading {
+                self.compile_stmt(stmt)?;
+            }
+            self.compile_expr(result)
+        } else {
+            for stmt in body {
+                self.compile_stmt(stmt)?;
+            }
+            let none_index = self.chunk.add_constant(Constant::None);
+            self.chunk.emit(OpCode::LoadConst(none_index));
+            Ok(())
+        }
+    }
+
+    /// Compile `&&`/`||` with a boolean literal operand without the short-circuit
+    /// branches: `true && x` only needs `x`'s truthiness, `false && x` never runs `x`,
+    /// and a literal on the right still evaluates the left for its side effects.
+    /// Returns false when neither operand is a literal.
+    fn compile_logical_with_literal(
+        &mut self,
+        left: &Expr,
+        op: &str,
+        right: &Expr,
+    ) -> Result<bool, String> {
+        let is_and = op == "&&";
+        let combine = if is_and { OpCode::And } else { OpCode::Or };
+
+        if let Expr::Bool(value) = left {
+            let index = self.chunk.add_constant(Constant::Bool(*value));
+            self.chunk.emit(OpCode::LoadConst(index));
+            // `true && x` / `false || x` reduce to `x`; the other two skip it entirely
+            if *value == is_and {
+                self.compile_expr(right)?;
+                self.chunk.emit(combine);
+            }
+            return Ok(true);
+        }
+
+        if let Expr::Bool(value) = right {
+            self.compile_expr(left)?;
+            let index = self.chunk.add_constant(Constant::Bool(*value));
+            if *value == is_and {
+                // `x && true` / `x || false`
+                self.chunk.emit(OpCode::LoadConst(index));
+                self.chunk.emit(combine);
+            } else {
+                // `x && false` / `x || true`
+                self.chunk.emit(OpCode::Pop);
+                self.chunk.emit(OpCode::LoadConst(index));
+            }
+            return Ok(true);
+        }
+
+        Ok(false)
+    }
+
     /// Compile an expression
     fn compile_expr(&mut self, expr: &Expr) -> Result<(), String> {
         match expr {
@@ -1008,6 +1360,12 @@ impl Compiler {
             }
 
             Expr::BinaryOp { left, op, right } => {
+                if (op == "&&" || op == "||")
+                    && self.compile_logical_with_literal(left, op, right)?
+                {
+                    return Ok(());
+                }
+
                 if op == "&&" {
                     self.has_logical_short_circuit = true;
                     self.compile_expr(left)?;
@@ -1100,25 +1458,6 @@ impl Compiler {
                     return Ok(());
                 }
 
-                if op == "|>" {
-                    match right.as_ref() {
-                        Expr::Call { function, args } => {
-                            self.compile_expr(left)?;
-                            for arg in args {
-                                self.compile_expr(arg)?;
-                            }
-                            self.compile_expr(function)?;
-                            self.chunk.emit(OpCode::Call(args.len() + 1));
-                        }
-                        _ => {
-                            self.compile_expr(left)?;
-                            self.compile_expr(right)?;
-                            self.chunk.emit(OpCode::Call(1));
-                        }
-                    }
-                    return Ok(());
-                }
-
                 // Compile operands
                 self.compile_expr(left)?;
                 self.compile_expr(right)?;
@@ -1157,22 +1496,43 @@ impl Compiler {
             }
 
             Expr::Call { function, args } => {
+                let expanded = Self::has_expanded_args(args);
+
                 // Method-call sugar: obj.method(a, b) should lower to a receiver-aware
                 // call path rather than a plain function call of FieldGet.
                 if let Expr::FieldAccess { object, field } = function.as_ref() {
                     self.has_method_call_flow = true;
+                    if expanded {
+                        self.chunk.emit(OpCode::PushArrayMarker);
+                    }
                     // Receiver becomes first argument.
                     self.compile_expr(object)?;
-                    for arg in args {
-                        self.compile_expr(arg)?;
-                    }
+                    let names = if expanded {
+                        Some(self.compile_expanded_args(args)?)
+                    } else {
+                        for arg in args {
+                            self.compile_expr(arg)?;
+                        }
+                        None
+                    };
 
                     // Re-load receiver for FieldGet so method lookup can resolve the member.
                     self.compile_expr(object)?;
                     self.chunk.emit(OpCode::FieldGet(field.clone()));
 
                     // +1 accounts for receiver argument.
-                    self.chunk.emit(OpCode::Call(args.len() + 1));
+                    match names {
+                        Some(names) => self.chunk.emit(OpCode::CallNamed(names)),
+                        None => self.chunk.emit(OpCode::Call(args.len() + 1)),
+                    };
+                    return Ok(());
+                }
+
+                if expanded {
+                    self.chunk.emit(OpCode::PushArrayMarker);
+                    let names = self.compile_expanded_args(args)?;
+                    self.compile_expr(function)?;
+                    self.chunk.emit(OpCode::CallNamed(names));
                     return Ok(());
                 }
 
@@ -1306,49 +1666,30 @@ impl Compiler {
                 Ok(())
             }
 
+            Expr::Slice { object, start, end } => {
+                // Lowered to the `slice` builtin; a missing bound passes null
+                self.compile_expr(object)?;
+                for bound in [start, end] {
+                    if let Some(bound) = bound {
+                        self.compile_expr(bound)?;
+                    } else {
+                        let none_index = self.chunk.add_constant(Constant::None);
+                        self.chunk.emit(OpCode::LoadConst(none_index));
+                    }
+                }
+                self.chunk.emit(OpCode::LoadGlobal("slice".to_string()));
+                self.chunk.emit(OpCode::Call(3));
+                Ok(())
+            }
+
             Expr::FieldAccess { object, field } => {
                 self.compile_expr(object)?;
                 self.chunk.emit(OpCode::FieldGet(field.clone()));
                 Ok(())
             }
 
-            Expr::Function { params, body, .. } => {
-                // Create anonymous function
-                let mut func_compiler = Compiler::new();
-                func_compiler.used_locals = Self::collect_used_variables(body);
-                func_compiler.chunk.name = Some("<lambda>".to_string());
-                func_compiler.chunk.params = params.clone();
-                func_compiler.scope_depth = 1; // Functions create a new scope (not global)
-                func_compiler.uses_local_slots = true;
-
-                // Add parameters as locals
-                for param in params {
-                    if func_compiler.has_local_in_current_scope(param) {
-                        return Err(format!("Duplicate declaration in the same scope: {}", param));
-                    }
-                    func_compiler.add_local(param, 1, BytecodeBindingKind::Mutable);
-                }
-
-                // Analyze the function body to find free variables (captures)
-                let free_vars = Self::find_free_variables(body, params, &self.locals);
-                func_compiler.chunk.upvalues = free_vars.clone();
-
-                // Captured variables are resolved from the closure's captured map at runtime
-                func_compiler.upvalue_names = free_vars.iter().cloned().collect();
-
-                // Compile function body
-                for stmt in body {
-                    func_compiler.compile_stmt(stmt)?;
-                }
-
-                func_compiler.chunk.emit(OpCode::ReturnNone);
-
-                func_compiler.chunk.local_count = func_compiler.next_local_slot;
-                let func_index =
-                    self.chunk.add_constant(Constant::Function(Box::new(func_compiler.chunk)));
-                self.chunk.emit(OpCode::MakeClosure(func_index));
-
-                Ok(())
+            Expr::Function { params, param_defaults, is_variadic, body, .. } => {
+                self.compile_closure("<lambda>", params, param_defaults, *is_variadic, body)
             }
 
             Expr::Ok(value) => {
@@ -1381,7 +1722,35 @@ impl Compiler {
                 Ok(())
             }
 
+            Expr::Ternary { condition, then_expr, else_expr } => {
+                // Same branch-and-merge shape as `&&`/`||`, so keep the optimizer off
+                self.has_logical_short_circuit = true;
+                self.compile_expr(condition)?;
+                let else_jump = self.chunk.emit(OpCode::JumpIfFalse(0));
+                self.chunk.emit(OpCode::Pop); // Pop condition
+                self.compile_expr(then_expr)?;
+                let end_jump = self.chunk.emit(OpCode::Jump(0));
+
+                self.chunk.patch_jump(else_jump);
+                self.chunk.emit(OpCode::Pop); // Pop condition
+                self.compile_expr(else_expr)?;
+                self.chunk.patch_jump(end_jump);
+                Ok(())
+            }
+
+            Expr::Match { value, cases, default } => {
+                self.compile_match(value, cases, default.as_deref(), true)
+            }
+
             Expr::StructInstance { name, fields } => {
+                if let Some(field_names) = self.struct_fields.get(name) {
+                    if let Some((unknown, _)) =
+                        fields.iter().find(|(field_name, _)| !field_names.contains(field_name))
+                    {
+                        return Err(unknown_struct_field_message(name, unknown));
+                    }
+                }
+
                 // Compile field values
                 let mut field_names = Vec::new();
                 for (field_name, field_value) in fields {
@@ -1451,17 +1820,15 @@ impl Compiler {
                     self.compile_expr(value)?;
                 }
 
-                // For now, treat as array with tag name
-                // Deferred post-v1 optimization backlog: specialized enum value layout/handling.
-                let tag_index = self.chunk.add_constant(Constant::String(tag.clone()));
-                self.chunk.emit(OpCode::LoadConst(tag_index));
-                self.chunk.emit(OpCode::MakeArray(values.len() + 1));
+                // Build the same tagged value the interpreter does, so `match` can see the
+                // variant tag and payload.
+                self.chunk.emit(OpCode::MakeTagged(tag.clone(), values.len()));
 
                 Ok(())
             }
 
             Expr::InterpolatedString(parts) => {
-                // Compile each part and concatenate
+                // Push every part, then join them into one string in a single allocation
                 for part in parts {
                     match part {
                         crate::ast::InterpolatedStringPart::Text(s) => {
@@ -1470,18 +1837,10 @@ impl Compiler {
                         }
                         crate::ast::InterpolatedStringPart::Expr(e) => {
                             self.compile_expr(e)?;
-                            // Convert to string using to_string builtin
-                            self.chunk.emit(OpCode::LoadGlobal("to_string".to_string()));
-                            self.chunk.emit(OpCode::Call(1));
                         }
                     }
                 }
-
-                // Concatenate all parts
-                // Deferred post-v1 optimization backlog: dedicated string-builder opcode path.
-                for _ in 1..parts.len() {
-                    self.chunk.emit(OpCode::Add); // String concatenation
-                }
+                self.chunk.emit(OpCode::BuildString(parts.len()));
 
                 Ok(())
             }
@@ -1523,33 +1882,75 @@ impl Compiler {
                 self.has_method_call_flow = true;
                 // Method calls are sugar for calling a method on an object
                 // Translate: obj.method(a, b) -> method(obj, a, b)
+                let expanded = Self::has_expanded_args(args);
+                if expanded {
+                    self.chunk.emit(OpCode::PushArrayMarker);
+                }
 
                 // Compile the object (becomes first argument)
                 self.compile_expr(object)?;
 
+                // The receiver is loaded again below for `FieldGet`. Anything but a plain
+                // name is kept in a temporary so calls like `fetch().then(f)` run `fetch` once.
+                let receiver_temp = if matches!(object.as_ref(), Expr::Identifier(_)) {
+                    None
+                } else {
+                    let temp_name = format!("__receiver_{}", self.chunk.instructions.len());
+                    if self.uses_local_slots {
+                        let slot = self.declare_local(&temp_name, BytecodeBindingKind::Mutable)?;
+                        self.chunk.emit(OpCode::StoreLocal(slot));
+                        Some((temp_name, Some(slot)))
+                    } else {
+                        self.chunk.emit(OpCode::StoreVar(temp_name.clone()));
+                        Some((temp_name, None))
+                    }
+                };
+
                 // Compile other arguments
-                for arg in args {
-                    self.compile_expr(arg)?;
-                }
+                let names = if expanded {
+                    Some(self.compile_expanded_args(args)?)
+                } else {
+                    for arg in args {
+                        self.compile_expr(arg)?;
+                    }
+                    None
+                };
 
                 // Load the method (it's either a field or a built-in method)
                 // For built-in iterator methods (map, filter, etc.), use native calls
                 match method.as_str() {
                     "map" | "filter" | "reduce" | "collect" | "take" | "skip" | "zip"
-                    | "enumerate" | "flatten" | "chunk" => {
-                        // These are native iterator functions
-                        self.chunk.emit(OpCode::CallNative(method.clone(), args.len() + 1));
-                    }
+                    | "enumerate" | "flatten" | "chunk" => match names {
+                        Some(names) => {
+                            self.chunk.emit(OpCode::LoadVar(method.clone()));
+                            self.chunk.emit(OpCode::CallNamed(names));
+                        }
+                        None => {
+                            // These are native iterator functions
+                            self.chunk.emit(OpCode::CallNative(method.clone(), args.len() + 1));
+                        }
+                    },
                     _ => {
                         // General method call: load field then call
-                        self.compile_expr(object)?;
+                        match receiver_temp {
+                            Some((_, Some(slot))) => {
+                                self.chunk.emit(OpCode::LoadLocal(slot));
+                            }
+                            Some((temp_name, None)) => {
+                                self.chunk.emit(OpCode::LoadVar(temp_name));
+                            }
+                            None => self.compile_expr(object)?,
+                        }
                         self.chunk.emit(OpCode::FieldGet(method.clone()));
 
                         // Move function to top of stack (after arguments)
                         // Stack: [obj, arg1, arg2, ..., method]
                         // Need: [obj, arg1, arg2, ..., method] for Call
 
-                        self.chunk.emit(OpCode::Call(args.len() + 1)); // +1 for object as first arg
+                        match names {
+                            Some(names) => self.chunk.emit(OpCode::CallNamed(names)),
+                            None => self.chunk.emit(OpCode::Call(args.len() + 1)), // +1 for object as first arg
+                        };
                     }
                 }
 
@@ -1559,6 +1960,10 @@ impl Compiler {
             Expr::Spread(_) => {
                 Err("Spread operator cannot be compiled as standalone expression".to_string())
             }
+
+            Expr::NamedArg { name, .. } => {
+                Err(format!("Named argument '{}' is only valid in a call", name))
+            }
         }
     }
 
@@ -1574,6 +1979,8 @@ impl Compiler {
                     self.chunk.emit(OpCode::DefineGlobal(name.clone(), binding_kind));
                 } else if self.is_upvalue(name) {
                     self.chunk.emit(OpCode::StoreVar(name.clone()));
+                } else if self.captured_locals.contains(name) {
+                    self.declare_captured_local(name, binding_kind)?;
                 } else {
                     let slot = self.declare_local(name, binding_kind)?;
                     self.chunk.emit(OpCode::StoreLocal(slot));
@@ -1587,19 +1994,10 @@ impl Compiler {
                 Ok(())
             }
 
-            Pattern::Array { elements: _, rest: _ } => {
-                // Use MatchPattern for complex binding
+            Pattern::Array { .. } | Pattern::Dict { .. } => {
+                // Strict destructuring: a mismatch raises instead of silently skipping bindings
                 let pattern_index = self.chunk.add_constant(Constant::Pattern(pattern.clone()));
-                self.chunk.emit(OpCode::MatchPattern(pattern_index, binding_kind));
-                self.chunk.emit(OpCode::Pop); // Pop the success bool
-                Ok(())
-            }
-
-            Pattern::Dict { keys: _, rest: _ } => {
-                // Use MatchPattern for complex binding
-                let pattern_index = self.chunk.add_constant(Constant::Pattern(pattern.clone()));
-                self.chunk.emit(OpCode::MatchPattern(pattern_index, binding_kind));
-                self.chunk.emit(OpCode::Pop); // Pop the success bool
+                self.chunk.emit(OpCode::DestructurePattern(pattern_index, binding_kind));
                 Ok(())
             }
         }
@@ -1712,11 +2110,31 @@ impl Compiler {
             Expr::Ok(expr) | Expr::Err(expr) | Expr::Some(expr) | Expr::Try(expr) => {
                 self.expr_is_pure(expr)
             }
+            Expr::Ternary { condition, then_expr, else_expr } => {
+                self.expr_is_pure(condition)
+                    && self.expr_is_pure(then_expr)
+                    && self.expr_is_pure(else_expr)
+            }
             Expr::Tag(_, values) => values.iter().all(|expr| self.expr_is_pure(expr)),
             _ => false,
         }
     }
 
+    /// `body` without its `Stmt::SourcePos` markers, so loop-shape matching sees the same
+    /// statements whether or not the program was parsed with source positions.
+    fn without_source_positions(body: &[Stmt]) -> Cow<'_, [Stmt]> {
+        if body.iter().any(|stmt| matches!(stmt, Stmt::SourcePos { .. })) {
+            Cow::Owned(
+                body.iter()
+                    .filter(|stmt| !matches!(stmt, Stmt::SourcePos { .. }))
+                    .cloned()
+                    .collect(),
+            )
+        } else {
+            Cow::Borrowed(body)
+        }
+    }
+
     fn match_append_char_until_local_pattern(
         &self,
         condition: &Expr,
@@ -1892,6 +2310,132 @@ impl Compiler {
         Some((map_slot, index_slot, limit_slot))
     }
 
+    /// Compile `body` as an anonymous closure and leave it on the stack.
+    ///
+    /// Free variables resolve through the closure's captured map at runtime.
+    fn compile_closure(
+        &mut self,
+        name: &str,
+        params: &[String],
+        param_defaults: &[Option<Expr>],
+        is_variadic: bool,
+        body: &[Stmt],
+    ) -> Result<(), String> {
+        let mut func_compiler = Compiler::new();
+        func_compiler.struct_fields = self.struct_fields.clone();
+        func_compiler.used_locals = Self::collect_used_variables(body);
+        func_compiler.captured_locals = Self::find_captured_locals(body);
+        func_compiler.chunk.name = Some(name.to_string());
+        func_compiler.chunk.params = params.to_vec();
+        func_compiler.chunk.param_defaults = Self::param_default_constants(param_defaults)?;
+        func_compiler.chunk.is_variadic = is_variadic;
+        func_compiler.scope_depth = 1; // Functions create a new scope (not global)
+        func_compiler.uses_local_slots = true;
+
+        // Add parameters as locals
+        for param in params {
+            if func_compiler.has_local_in_current_scope(param) {
+                return Err(format!("Duplicate declaration in the same scope: {}", param));
+            }
+            func_compiler.add_local(param, 1, BytecodeBindingKind::Mutable);
+        }
+
+        // Analyze the function body to find free variables (captures)
+        let free_vars = Self::find_free_variables(body, params, &self.locals);
+        func_compiler.chunk.upvalues = free_vars.clone();
+        func_compiler.upvalue_names = free_vars.iter().cloned().collect();
+
+        for stmt in body {
+            func_compiler.compile_stmt(stmt)?;
+        }
+
+        func_compiler.chunk.emit(OpCode::ReturnNone);
+
+        func_compiler.chunk.local_count = func_compiler.next_local_slot;
+        let func_index = self.chunk.add_constant(Constant::Function(Arc::new(func_compiler.chunk)));
+        self.chunk.emit(OpCode::MakeClosure(func_index));
+
+        Ok(())
+    }
+
+    /// Constants for a function's literal parameter defaults, aligned with its params.
+    fn param_default_constants(
+        param_defaults: &[Option<Expr>],
+    ) -> Result<Vec<Option<Constant>>, String> {
+        param_defaults
+            .iter()
+            .map(|default| default.as_ref().map(Self::literal_constant).transpose())
+            .collect()
+    }
+
+    /// Constant for a literal the parser accepts as a parameter default.
+    fn literal_constant(expr: &Expr) -> Result<Constant, String> {
+        match expr {
+            Expr::Int(n) => Ok(Constant::Int(*n)),
+            Expr::Float(f) => Ok(Constant::Float(*f)),
+            Expr::String(s) => Ok(Constant::String(s.clone())),
+            Expr::Bool(b) => Ok(Constant::Bool(*b)),
+            Expr::Identifier(name) if name == "null" => Ok(Constant::None),
+            Expr::UnaryOp { op, operand } if op == "-" => match operand.as_ref() {
+                Expr::Int(n) => Ok(Constant::Int(n.wrapping_neg())),
+                Expr::Float(f) => Ok(Constant::Float(-f)),
+                _ => Err("Parameter default must be a literal".to_string()),
+            },
+            Expr::ArrayLiteral(elements) => elements
+                .iter()
+                .map(|element| match element {
+                    ArrayElement::Single(expr) => Self::literal_constant(expr),
+                    ArrayElement::Spread(_) => {
+                        Err("Parameter default must be a literal".to_string())
+                    }
+                })
+                .collect::<Result<Vec<_>, _>>()
+                .map(Constant::Array),
+            Expr::DictLiteral(elements) => elements
+                .iter()
+                .map(|element| match element {
+                    DictElement::Pair(key @ Expr::String(_), value) => {
+                        Ok((Self::literal_constant(key)?, Self::literal_constant(value)?))
+                    }
+                    _ => Err("Parameter default must be a literal".to_string()),
+                })
+                .collect::<Result<Vec<_>, _>>()
+                .map(Constant::Dict),
+            _ => Err("Parameter default must be a literal".to_string()),
+        }
+    }
+
+    /// Whether a call passes `...spread` or `name: value` arguments, which need `CallNamed`.
+    fn has_expanded_args(args: &[Expr]) -> bool {
+        args.iter().any(|arg| matches!(arg, Expr::Spread(_) | Expr::NamedArg { .. }))
+    }
+
+    /// Compile the arguments of a `CallNamed` call. Positional arguments, including any
+    /// receiver already pushed after the caller's `PushArrayMarker`, are collected into
+    /// one array; each named value follows it. Returns the names in order.
+    fn compile_expanded_args(&mut self, args: &[Expr]) -> Result<Vec<String>, String> {
+        for arg in args {
+            match arg {
+                Expr::Spread(expr) => {
+                    self.compile_expr(expr)?;
+                    self.chunk.emit(OpCode::SpreadArray);
+                }
+                Expr::NamedArg { .. } => {}
+                expr => self.compile_expr(expr)?,
+            }
+        }
+        self.chunk.emit(OpCode::MakeArrayFromMarker);
+
+        let mut names = Vec::new();
+        for arg in args {
+            if let Expr::NamedArg { name, value } = arg {
+                self.compile_expr(value)?;
+                names.push(name.clone());
+            }
+        }
+        Ok(names)
+    }
+
     /// Collect variables that are read within the statement list
     fn collect_used_variables(body: &[Stmt]) -> HashSet<String> {
         let mut used_vars = HashSet::new();
@@ -1946,6 +2490,12 @@ impl Compiler {
                     collect_expr_vars(object, used);
                     collect_expr_vars(index, used);
                 }
+                Expr::Slice { object, start, end } => {
+                    collect_expr_vars(object, used);
+                    for bound in [start, end].into_iter().flatten() {
+                        collect_expr_vars(bound, used);
+                    }
+                }
                 Expr::FieldAccess { object, .. } => {
                     collect_expr_vars(object, used);
                 }
@@ -1960,9 +2510,28 @@ impl Compiler {
                 Expr::Yield(Some(expr)) => {
                     collect_expr_vars(expr, used);
                 }
-                Expr::Try(expr) => {
+                Expr::Try(expr) | Expr::Spread(expr) | Expr::NamedArg { value: expr, .. } => {
                     collect_expr_vars(expr, used);
                 }
+                Expr::Ternary { condition, then_expr, else_expr } => {
+                    collect_expr_vars(condition, used);
+                    collect_expr_vars(then_expr, used);
+                    collect_expr_vars(else_expr, used);
+                }
+                Expr::Match { value, cases, default } => {
+                    collect_expr_vars(value, used);
+                    for case in cases {
+                        if let Some(guard) = &case.guard {
+                            collect_expr_vars(guard, used);
+                        }
+                        for stmt in &case.body {
+                            collect_stmt_vars(stmt, used);
+                        }
+                    }
+                    for stmt in default.iter().flatten() {
+                        collect_stmt_vars(stmt, used);
+                    }
+                }
                 Expr::StructInstance { fields, .. } => {
                     for (_, expr) in fields {
                         collect_expr_vars(expr, used);
@@ -1997,6 +2566,23 @@ impl Compiler {
                         _ => {}
                     }
                 }
+                Stmt::MultiAssign { targets, values } => {
+                    for value in values {
+                        collect_expr_vars(value, used);
+                    }
+                    for target in targets {
+                        match target {
+                            Expr::IndexAccess { object, index } => {
+                                collect_expr_vars(object, used);
+                                collect_expr_vars(index, used);
+                            }
+                            Expr::FieldAccess { object, .. } => {
+                                collect_expr_vars(object, used);
+                            }
+                            _ => {}
+                        }
+                    }
+                }
                 Stmt::ExprStmt(expr) => {
                     collect_expr_vars(expr, used);
                 }
@@ -2028,11 +2614,14 @@ impl Compiler {
                         collect_expr_vars(expr, used);
                     }
                 }
-                Stmt::Break | Stmt::Continue => {}
+                Stmt::Break(_) | Stmt::Continue(_) => {}
                 Stmt::Match { value, cases, default } => {
                     collect_expr_vars(value, used);
-                    for (_pattern, stmts) in cases {
-                        for stmt in stmts {
+                    for case in cases {
+                        if let Some(guard) = &case.guard {
+                            collect_expr_vars(guard, used);
+                        }
+                        for stmt in &case.body {
                             collect_stmt_vars(stmt, used);
                         }
                     }
@@ -2046,12 +2635,14 @@ impl Compiler {
                     for stmt in body {
                         collect_stmt_vars(stmt, used);
                     }
-                }
-                Stmt::TryExcept { try_block, except_block, .. } => {
-                    for stmt in try_block {
-                        collect_stmt_vars(stmt, used);
+                    if let Some(rebinding) = stmt.decorator_rebinding() {
+                        collect_stmt_vars(&rebinding, used);
                     }
-                    for stmt in except_block {
+                }
+                Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+                    for stmt in
+                        try_block.iter().chain(except_block).chain(finally_block.iter().flatten())
+                    {
                         collect_stmt_vars(stmt, used);
                     }
                 }
@@ -2063,7 +2654,7 @@ impl Compiler {
                 Stmt::Const { value, .. } => {
                     collect_expr_vars(value, used);
                 }
-                Stmt::Export { stmt } => {
+                Stmt::Export { stmt } | Stmt::LabeledLoop { loop_stmt: stmt, .. } => {
                     collect_stmt_vars(stmt, used);
                 }
                 Stmt::Spawn { body } => {
@@ -2091,7 +2682,12 @@ impl Compiler {
                         collect_stmt_vars(stmt, used);
                     }
                 }
-                Stmt::StructDef { .. } | Stmt::EnumDef { .. } | Stmt::Import { .. } => {}
+                Stmt::StructDef { .. }
+                | Stmt::EnumDef { .. }
+                | Stmt::InterfaceDef { .. }
+                | Stmt::Import { .. }
+                | Stmt::ImportPath { .. }
+                | Stmt::SourcePos { .. } => {}
             }
         }
 
@@ -2104,6 +2700,12 @@ impl Compiler {
 
     /// Find free variables in a function body
     /// Free variables are variables that are used but not defined locally (not params or let bindings)
+    /// Names a closure with this body reads from outside its own parameters and
+    /// declarations. The interpreter captures these when it creates the closure.
+    pub(crate) fn closure_free_variables(body: &[Stmt], params: &[String]) -> Vec<String> {
+        Self::find_free_variables(body, params, &[])
+    }
+
     fn find_free_variables(
         body: &[Stmt],
         params: &[String],
@@ -2165,6 +2767,12 @@ impl Compiler {
                     collect_expr_vars(object, used);
                     collect_expr_vars(index, used);
                 }
+                Expr::Slice { object, start, end } => {
+                    collect_expr_vars(object, used);
+                    for bound in [start, end].into_iter().flatten() {
+                        collect_expr_vars(bound, used);
+                    }
+                }
                 Expr::FieldAccess { object, .. } => {
                     collect_expr_vars(object, used);
                 }
@@ -2180,14 +2788,38 @@ impl Compiler {
                 Expr::Yield(Some(e)) => {
                     collect_expr_vars(e, used);
                 }
-                Expr::Try(e) => {
+                Expr::Try(e) | Expr::Spread(e) | Expr::NamedArg { value: e, .. } => {
                     collect_expr_vars(e, used);
                 }
+                Expr::Ternary { condition, then_expr, else_expr } => {
+                    collect_expr_vars(condition, used);
+                    collect_expr_vars(then_expr, used);
+                    collect_expr_vars(else_expr, used);
+                }
+                Expr::Match { value, cases, default } => {
+                    collect_expr_vars(value, used);
+                    for case in cases {
+                        if let Some(guard) = &case.guard {
+                            collect_expr_vars(guard, used);
+                        }
+                        for stmt in &case.body {
+                            collect_stmt_vars(stmt, used, &mut HashSet::new());
+                        }
+                    }
+                    for stmt in default.iter().flatten() {
+                        collect_stmt_vars(stmt, used, &mut HashSet::new());
+                    }
+                }
                 Expr::StructInstance { fields, .. } => {
                     for (_, expr) in fields {
                         collect_expr_vars(expr, used);
                     }
                 }
+                Expr::Tag(_, values) => {
+                    for value in values {
+                        collect_expr_vars(value, used);
+                    }
+                }
                 Expr::InterpolatedString(parts) => {
                     for part in parts {
                         if let crate::ast::InterpolatedStringPart::Expr(e) = part {
@@ -2234,6 +2866,19 @@ impl Compiler {
                         }
                     }
                 }
+                Stmt::MultiAssign { targets, values } => {
+                    for value in values {
+                        collect_expr_vars(value, used);
+                    }
+                    for target in targets {
+                        // Identifier targets count as usage, as for single assignment
+                        if let Expr::Identifier(name) = target {
+                            used.insert(name.clone());
+                        } else {
+                            collect_expr_vars(target, used);
+                        }
+                    }
+                }
                 Stmt::ExprStmt(expr) => {
                     collect_expr_vars(expr, used);
                 }
@@ -2266,11 +2911,15 @@ impl Compiler {
                         collect_expr_vars(e, used);
                     }
                 }
-                Stmt::Break | Stmt::Continue => {}
+                Stmt::Break(_) | Stmt::Continue(_) | Stmt::SourcePos { .. } => {}
                 Stmt::Match { value, cases, default } => {
                     collect_expr_vars(value, used);
-                    for (_pattern, stmts) in cases {
-                        for s in stmts {
+                    for case in cases {
+                        defined.extend(case.pattern.binding_names());
+                        if let Some(guard) = &case.guard {
+                            collect_expr_vars(guard, used);
+                        }
+                        for s in &case.body {
                             collect_stmt_vars(s, used, defined);
                         }
                     }
@@ -2286,12 +2935,14 @@ impl Compiler {
                     for s in body {
                         collect_stmt_vars(s, used, &mut HashSet::new());
                     }
-                }
-                Stmt::TryExcept { try_block, except_block, .. } => {
-                    for s in try_block {
-                        collect_stmt_vars(s, used, defined);
+                    if let Some(rebinding) = stmt.decorator_rebinding() {
+                        collect_stmt_vars(&rebinding, used, defined);
                     }
-                    for s in except_block {
+                }
+                Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+                    for s in
+                        try_block.iter().chain(except_block).chain(finally_block.iter().flatten())
+                    {
                         collect_stmt_vars(s, used, defined);
                     }
                 }
@@ -2304,7 +2955,7 @@ impl Compiler {
                     defined.insert(name.clone());
                     collect_expr_vars(value, used);
                 }
-                Stmt::Export { stmt } => {
+                Stmt::Export { stmt } | Stmt::LabeledLoop { loop_stmt: stmt, .. } => {
                     collect_stmt_vars(stmt, used, defined);
                 }
                 Stmt::Spawn { body } => {
@@ -2315,9 +2966,12 @@ impl Compiler {
                 Stmt::StructDef { name, .. } => {
                     defined.insert(name.clone());
                 }
-                Stmt::EnumDef { name, .. } => {
+                Stmt::EnumDef { name, .. } | Stmt::InterfaceDef { name, .. } => {
                     defined.insert(name.clone());
                 }
+                Stmt::ImportPath { namespace, .. } => {
+                    defined.insert(namespace.clone());
+                }
                 Stmt::Import { module, symbols } => {
                     // Module itself becomes a variable
                     defined.insert(module.clone());
@@ -2363,4 +3017,204 @@ impl Compiler {
         free_vars.sort();
         free_vars
     }
+
+    /// Escape analysis for closure captures.
+    ///
+    /// Returns the names that closures nested directly in `body` (function
+    /// statements and lambda expressions) capture from the enclosing function.
+    /// Deeper closures are covered too, because their captures show up as free
+    /// variables of the closure that contains them. Locals outside this set never
+    /// escape and keep their fast slots.
+    fn find_captured_locals(body: &[Stmt]) -> HashSet<String> {
+        fn visit_closure(
+            params: &[String],
+            param_defaults: &[Option<Expr>],
+            body: &[Stmt],
+            captured: &mut HashSet<String>,
+        ) {
+            for default in param_defaults.iter().flatten() {
+                visit_expr(default, captured);
+            }
+            captured.extend(Compiler::find_free_variables(body, params, &[]));
+        }
+
+        fn visit_expr(expr: &Expr, captured: &mut HashSet<String>) {
+            match expr {
+                Expr::Function { params, param_defaults, body, .. } => {
+                    visit_closure(params, param_defaults, body, captured);
+                }
+                Expr::Tag(_, values) => {
+                    // `throw(value)` is a tag expression
+                    for value in values {
+                        visit_expr(value, captured);
+                    }
+                }
+                Expr::BinaryOp { left, right, .. } => {
+                    visit_expr(left, captured);
+                    visit_expr(right, captured);
+                }
+                Expr::UnaryOp { operand, .. } => visit_expr(operand, captured),
+                Expr::Call { function, args } => {
+                    visit_expr(function, captured);
+                    for arg in args {
+                        visit_expr(arg, captured);
+                    }
+                }
+                Expr::MethodCall { object, args, .. } => {
+                    visit_expr(object, captured);
+                    for arg in args {
+                        visit_expr(arg, captured);
+                    }
+                }
+                Expr::ArrayLiteral(elements) => {
+                    for element in elements {
+                        match element {
+                            ArrayElement::Single(expr) | ArrayElement::Spread(expr) => {
+                                visit_expr(expr, captured);
+                            }
+                        }
+                    }
+                }
+                Expr::DictLiteral(entries) => {
+                    for entry in entries {
+                        match entry {
+                            DictElement::Pair(key, value) => {
+                                visit_expr(key, captured);
+                                visit_expr(value, captured);
+                            }
+                            DictElement::Spread(expr) => visit_expr(expr, captured),
+                        }
+                    }
+                }
+                Expr::IndexAccess { object, index } => {
+                    visit_expr(object, captured);
+                    visit_expr(index, captured);
+                }
+                Expr::Slice { object, start, end } => {
+                    visit_expr(object, captured);
+                    for bound in [start, end].into_iter().flatten() {
+                        visit_expr(bound, captured);
+                    }
+                }
+                Expr::FieldAccess { object, .. } => visit_expr(object, captured),
+                Expr::Ok(expr)
+                | Expr::Err(expr)
+                | Expr::Some(expr)
+                | Expr::Await(expr)
+                | Expr::Try(expr)
+                | Expr::Spread(expr)
+                | Expr::NamedArg { value: expr, .. }
+                | Expr::Yield(Some(expr)) => visit_expr(expr, captured),
+                Expr::Ternary { condition, then_expr, else_expr } => {
+                    visit_expr(condition, captured);
+                    visit_expr(then_expr, captured);
+                    visit_expr(else_expr, captured);
+                }
+                Expr::Match { value, cases, default } => {
+                    visit_expr(value, captured);
+                    for case in cases {
+                        if let Some(guard) = &case.guard {
+                            visit_expr(guard, captured);
+                        }
+                        visit_stmts(&case.body, captured);
+                    }
+                    if let Some(default_stmts) = default {
+                        visit_stmts(default_stmts, captured);
+                    }
+                }
+                Expr::StructInstance { fields, .. } => {
+                    for (_, expr) in fields {
+                        visit_expr(expr, captured);
+                    }
+                }
+                Expr::InterpolatedString(parts) => {
+                    for part in parts {
+                        if let crate::ast::InterpolatedStringPart::Expr(expr) = part {
+                            visit_expr(expr, captured);
+                        }
+                    }
+                }
+                _ => {}
+            }
+        }
+
+        fn visit_stmts(stmts: &[Stmt], captured: &mut HashSet<String>) {
+            for stmt in stmts {
+                visit_stmt(stmt, captured);
+            }
+        }
+
+        fn visit_stmt(stmt: &Stmt, captured: &mut HashSet<String>) {
+            match stmt {
+                Stmt::FuncDef { params, param_defaults, body, .. } => {
+                    visit_closure(params, param_defaults, body, captured);
+                    if let Some(rebinding) = stmt.decorator_rebinding() {
+                        visit_stmt(&rebinding, captured);
+                    }
+                }
+                // A spawn body runs as a closure with no parameters
+                Stmt::Spawn { body } => visit_closure(&[], &[], body, captured),
+                Stmt::Let { value, .. } | Stmt::Const { value, .. } => visit_expr(value, captured),
+                Stmt::Assign { target, value } => {
+                    visit_expr(target, captured);
+                    visit_expr(value, captured);
+                }
+                Stmt::MultiAssign { targets, values } => {
+                    for expr in targets.iter().chain(values.iter()) {
+                        visit_expr(expr, captured);
+                    }
+                }
+                Stmt::ExprStmt(expr) | Stmt::Return(Some(expr)) => visit_expr(expr, captured),
+                Stmt::If { condition, then_branch, else_branch } => {
+                    visit_expr(condition, captured);
+                    visit_stmts(then_branch, captured);
+                    if let Some(else_stmts) = else_branch {
+                        visit_stmts(else_stmts, captured);
+                    }
+                }
+                Stmt::While { condition, body } => {
+                    visit_expr(condition, captured);
+                    visit_stmts(body, captured);
+                }
+                Stmt::For { iterable, body, .. } => {
+                    visit_expr(iterable, captured);
+                    visit_stmts(body, captured);
+                }
+                Stmt::Loop { condition, body } => {
+                    if let Some(condition) = condition {
+                        visit_expr(condition, captured);
+                    }
+                    visit_stmts(body, captured);
+                }
+                Stmt::Match { value, cases, default } => {
+                    visit_expr(value, captured);
+                    for case in cases {
+                        if let Some(guard) = &case.guard {
+                            visit_expr(guard, captured);
+                        }
+                        visit_stmts(&case.body, captured);
+                    }
+                    if let Some(default_stmts) = default {
+                        visit_stmts(default_stmts, captured);
+                    }
+                }
+                Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+                    visit_stmts(try_block, captured);
+                    visit_stmts(except_block, captured);
+                    if let Some(finally_block) = finally_block {
+                        visit_stmts(finally_block, captured);
+                    }
+                }
+                Stmt::Block(stmts) => visit_stmts(stmts, captured),
+                Stmt::Export { stmt } | Stmt::LabeledLoop { loop_stmt: stmt, .. } => {
+                    visit_stmt(stmt, captured);
+                }
+                _ => {}
+            }
+        }
+
+        let mut captured = HashSet::new();
+        visit_stmts(body, &mut captured);
+        captured
+    }
 }
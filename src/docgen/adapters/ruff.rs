@@ -157,6 +157,30 @@ impl RuffDocAdapter {
                 }
                 *cursor_line = (*cursor_line).max(method_cursor);
             }
+            Stmt::InterfaceDef { name, .. } => {
+                let line = find_keyword_decl_line(source_lines, *cursor_line, "interface", name);
+                *cursor_line = line;
+                symbols.push(DocSymbol {
+                    id: Self::symbol_id(path, line, name, &DocSymbolKind::Interface),
+                    language: "ruff".to_string(),
+                    kind: DocSymbolKind::Interface,
+                    name: name.clone(),
+                    qualified_name: name.clone(),
+                    signature: Some(
+                        source_lines
+                            .get(line.saturating_sub(1))
+                            .map(|line| line.trim().to_string())
+                            .unwrap_or_else(|| format!("interface {}", name)),
+                    ),
+                    visibility: visibility_from_explicit_public(inherited_public),
+                    source_path: path.to_path_buf(),
+                    line,
+                    docs: DocComment::default(),
+                    examples: Vec::new(),
+                    gaps: Vec::new(),
+                    parent: None,
+                });
+            }
             Stmt::EnumDef { name, variants } => {
                 let line = find_keyword_decl_line(source_lines, *cursor_line, "enum", name);
                 *cursor_line = line;
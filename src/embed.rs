@@ -0,0 +1,417 @@
+// File: src/embed.rs
+//
+// Embedding API for host programs.
+//
+// `Ruff` wraps a tree-walking interpreter so Rust applications can use Ruff as a
+// scripting or configuration language: evaluate source, call Ruff functions, and read
+// or define globals. `to_value` and `from_value` convert any serde type to and from
+// Ruff values, so host structs, maps, and vectors cross the boundary directly.
+// `Ruff::register` exposes host closures to scripts as native functions, converting
+// their arguments and results the same way.
+
+use crate::ast::Stmt;
+use crate::builtins::{json_to_ruff_value, ruff_value_to_json};
+use crate::errors::{RuffError, SourceLocation};
+use crate::interpreter::{
+    DictMap, HostCallback, Interpreter, OutputSink, RuntimeCapabilityPolicy, Value,
+};
+use crate::{lexer, parser};
+use serde::de::DeserializeOwned;
+use serde::Serialize;
+use std::collections::{BTreeMap, HashMap};
+use std::fmt::Display;
+use std::sync::{Arc, Mutex};
+
+/// Result of an embedding call; errors carry the Ruff message and, when known, the
+/// source position of the failing statement
+pub type EmbedResult<T> = Result<T, Box<RuffError>>;
+
+/// An embedded Ruff runtime. Globals persist across `eval` and `call`.
+///
+/// ```ignore
+/// let mut ruff = ruff::Ruff::new();
+/// ruff.eval("func greet(name) { return \"Hello, \" + name }")?;
+/// let greeting = ruff.call("greet", &[ruff::to_value("host")?])?;
+/// ```
+pub struct Ruff {
+    interpreter: Interpreter,
+}
+
+impl Ruff {
+    /// Runtime with the trusted capability policy used by `ruff run`
+    pub fn new() -> Self {
+        Self::with_capability_policy(RuntimeCapabilityPolicy::trusted())
+    }
+
+    /// Runtime restricted to `policy`, e.g. to deny file or network access to scripts
+    pub fn with_capability_policy(policy: RuntimeCapabilityPolicy) -> Self {
+        Self { interpreter: Interpreter::with_capability_policy(policy) }
+    }
+
+    /// Runs `source` and returns the value of its final expression statement, or
+    /// `null` when it ends with any other statement.
+    pub fn eval(&mut self, source: &str) -> EmbedResult<Value> {
+        let stmts = parse(source)?;
+        let (body, tail) = match stmts.split_last() {
+            Some((Stmt::ExprStmt(expr), body)) => (body, Some(expr)),
+            _ => (stmts.as_slice(), None),
+        };
+
+        self.interpreter.eval_stmts(body);
+        if let Some(pending) = self.interpreter.return_value.take() {
+            if let Some(message) = error_message(&pending) {
+                let location = self
+                    .interpreter
+                    .error_source_position()
+                    .map_or_else(SourceLocation::unknown, |(line, column)| {
+                        SourceLocation::new(line, column)
+                    });
+                return Err(Box::new(RuffError::runtime_error(message, location)));
+            }
+        }
+
+        match tail {
+            Some(expr) => {
+                let result = self.interpreter.eval_expr_repl(expr);
+                // A raised error also stays pending for `try`; the caller receives it instead
+                self.interpreter.return_value = None;
+                result
+            }
+            None => Ok(Value::Null),
+        }
+    }
+
+    /// Calls the global function `name` with `args`
+    pub fn call(&mut self, name: &str, args: &[Value]) -> EmbedResult<Value> {
+        let function = self.interpreter.env.get(name).ok_or_else(|| {
+            Box::new(RuffError::undefined_function(name.to_string(), SourceLocation::unknown()))
+        })?;
+        if !matches!(function, Value::Function(..) | Value::NativeFunction(_)) {
+            return Err(runtime_error(format!("'{}' is not a function", name)));
+        }
+
+        let result = self.interpreter.call_user_function(&function, args);
+        let raised = self.interpreter.return_value.take().and_then(|value| error_message(&value));
+        match raised.or_else(|| error_message(&result)) {
+            Some(message) => Err(runtime_error(message)),
+            None => Ok(result),
+        }
+    }
+
+    /// Value of the global `name`
+    pub fn get(&self, name: &str) -> Option<Value> {
+        self.interpreter.env.get(name)
+    }
+
+    /// Defines or replaces the global `name`, e.g. to hand configuration to a script
+    pub fn set(&mut self, name: &str, value: Value) {
+        self.interpreter.env.set(name.to_string(), value);
+    }
+
+    /// Captures `print` output in `output` instead of writing it to stdout
+    pub fn set_output(&mut self, output: Arc<Mutex<Vec<u8>>>) {
+        self.interpreter.set_output(output);
+    }
+
+    /// Sends `print` and `eprint` output to `sink`, e.g. to forward it to a host UI
+    pub fn set_output_sink(&mut self, sink: Arc<dyn OutputSink>) {
+        self.interpreter.set_output_sink(sink);
+    }
+
+    /// Exposes `function` to scripts as the global native function `name`.
+    ///
+    /// Each parameter is converted from the matching Ruff argument with `from_value`
+    /// (`Value` parameters are passed through and a trailing `Variadic<T>` collects the
+    /// rest), and the result is converted back with `to_value`. Returning `Err` raises a
+    /// Ruff error that scripts can catch with `try`/`except`.
+    ///
+    /// ```ignore
+    /// ruff.register("fetch", |url: String| -> Result<String, std::io::Error> { ... });
+    /// ruff.register("sum", |Variadic(numbers): Variadic<f64>| numbers.iter().sum::<f64>());
+    /// ```
+    pub fn register<Args: 'static>(&mut self, name: &str, function: impl HostFunction<Args>) {
+        let function_name = name.to_string();
+        let callback: HostCallback = Arc::new(move |values: &[Value]| {
+            let mut args = HostArgs { function: &function_name, values, next: 0 };
+            function.call_host(&mut args)
+        });
+        self.interpreter.register_host_function(name, callback);
+    }
+}
+
+impl Default for Ruff {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Drop for Ruff {
+    fn drop(&mut self) {
+        self.interpreter.cleanup();
+    }
+}
+
+/// Converts a serde-serializable host value into a Ruff value. Structs and maps become
+/// dicts, sequences become arrays, and `None`/unit become `null`.
+pub fn to_value<T: Serialize + ?Sized>(value: &T) -> EmbedResult<Value> {
+    serde_json::to_value(value)
+        .map(json_to_ruff_value)
+        .map_err(|error| runtime_error(format!("Cannot convert to a Ruff value: {}", error)))
+}
+
+/// Converts a Ruff value into a host type. Struct instances convert like dicts of their
+/// fields; functions and other runtime handles cannot be converted.
+pub fn from_value<T: DeserializeOwned>(value: &Value) -> EmbedResult<T> {
+    let json = ruff_value_to_json(&plain_data(value)).map_err(runtime_error)?;
+    serde_json::from_value(json)
+        .map_err(|error| runtime_error(format!("Cannot convert Ruff value: {}", error)))
+}
+
+/// Arguments of a host function call, consumed left to right by `FromHostArg`
+pub struct HostArgs<'a> {
+    function: &'a str,
+    values: &'a [Value],
+    next: usize,
+}
+
+impl HostArgs<'_> {
+    /// Number of arguments not yet consumed
+    pub fn remaining(&self) -> usize {
+        self.values.len() - self.next
+    }
+
+    /// Takes the next argument, or `None` when the script passed fewer
+    pub fn next_value(&mut self) -> Option<&Value> {
+        let value = self.values.get(self.next);
+        self.next += 1;
+        value
+    }
+
+    /// Error message about the argument most recently taken
+    pub fn argument_error(&self, message: impl Display) -> String {
+        format!("{}() argument {}: {}", self.function, self.next, message)
+    }
+
+    fn finish(&self) -> Result<(), String> {
+        if self.next >= self.values.len() {
+            return Ok(());
+        }
+        Err(format!(
+            "{}() expects {} argument{}, got {}",
+            self.function,
+            self.next,
+            if self.next == 1 { "" } else { "s" },
+            self.values.len()
+        ))
+    }
+}
+
+/// A host function parameter built from the Ruff call's arguments
+pub trait FromHostArg: Sized {
+    fn from_host_args(args: &mut HostArgs<'_>) -> Result<Self, String>;
+}
+
+/// Any deserializable type takes one argument; a missing argument converts from `null`,
+/// so `Option` parameters are optional.
+impl<T: DeserializeOwned> FromHostArg for T {
+    fn from_host_args(args: &mut HostArgs<'_>) -> Result<Self, String> {
+        match args.next_value() {
+            Some(value) => {
+                let value = value.clone();
+                convert(&value).map_err(|message| args.argument_error(message))
+            }
+            None => convert(&Value::Null).map_err(|_| args.argument_error("missing")),
+        }
+    }
+}
+
+impl FromHostArg for Value {
+    fn from_host_args(args: &mut HostArgs<'_>) -> Result<Self, String> {
+        args.next_value().cloned().ok_or_else(|| args.argument_error("missing"))
+    }
+}
+
+/// Collects every remaining argument; use it as the last parameter
+pub struct Variadic<T>(pub Vec<T>);
+
+impl<T: FromHostArg> FromHostArg for Variadic<T> {
+    fn from_host_args(args: &mut HostArgs<'_>) -> Result<Self, String> {
+        let mut items = Vec::with_capacity(args.remaining());
+        while args.remaining() > 0 {
+            items.push(T::from_host_args(args)?);
+        }
+        Ok(Variadic(items))
+    }
+}
+
+/// A host function result: a serializable value, a `Value`, or a `Result` of either
+/// whose `Err` becomes a Ruff error
+pub trait HostReturn {
+    fn into_host_result(self) -> Result<Value, String>;
+}
+
+impl HostReturn for Value {
+    fn into_host_result(self) -> Result<Value, String> {
+        Ok(self)
+    }
+}
+
+impl<E: Display> HostReturn for Result<Value, E> {
+    fn into_host_result(self) -> Result<Value, String> {
+        self.map_err(|error| error.to_string())
+    }
+}
+
+impl<T: Serialize, E: Display> HostReturn for Result<T, E> {
+    fn into_host_result(self) -> Result<Value, String> {
+        let value = self.map_err(|error| error.to_string())?;
+        to_value(&value).map_err(|error| error.message)
+    }
+}
+
+macro_rules! serialized_host_return {
+    ($($ty:ty),* $(,)?) => {
+        $(impl HostReturn for $ty {
+            fn into_host_result(self) -> Result<Value, String> {
+                to_value(&self).map_err(|error| error.message)
+            }
+        })*
+    };
+}
+
+serialized_host_return!(
+    (),
+    bool,
+    i8,
+    i16,
+    i32,
+    i64,
+    isize,
+    u8,
+    u16,
+    u32,
+    u64,
+    usize,
+    f32,
+    f64,
+    String,
+    &'static str,
+    serde_json::Value
+);
+
+impl<T: Serialize> HostReturn for Vec<T> {
+    fn into_host_result(self) -> Result<Value, String> {
+        to_value(&self).map_err(|error| error.message)
+    }
+}
+
+impl<T: Serialize> HostReturn for Option<T> {
+    fn into_host_result(self) -> Result<Value, String> {
+        to_value(&self).map_err(|error| error.message)
+    }
+}
+
+impl<T: Serialize> HostReturn for HashMap<String, T> {
+    fn into_host_result(self) -> Result<Value, String> {
+        to_value(&self).map_err(|error| error.message)
+    }
+}
+
+impl<T: Serialize> HostReturn for BTreeMap<String, T> {
+    fn into_host_result(self) -> Result<Value, String> {
+        to_value(&self).map_err(|error| error.message)
+    }
+}
+
+/// A closure that `Ruff::register` can expose; implemented for `Fn`s of up to six
+/// `FromHostArg` parameters returning a `HostReturn`
+pub trait HostFunction<Args>: Send + Sync + 'static {
+    fn call_host(&self, args: &mut HostArgs<'_>) -> Result<Value, String>;
+}
+
+macro_rules! host_function {
+    ($($arg:ident),*) => {
+        impl<Func, Ret, $($arg),*> HostFunction<($($arg,)*)> for Func
+        where
+            Func: Fn($($arg),*) -> Ret + Send + Sync + 'static,
+            Ret: HostReturn,
+            $($arg: FromHostArg,)*
+        {
+            #[allow(non_snake_case, unused_variables)]
+            fn call_host(&self, args: &mut HostArgs<'_>) -> Result<Value, String> {
+                $(let $arg = $arg::from_host_args(args)?;)*
+                args.finish()?;
+                self($($arg),*).into_host_result()
+            }
+        }
+    };
+}
+
+host_function!();
+host_function!(A);
+host_function!(A, B);
+host_function!(A, B, C);
+host_function!(A, B, C, D);
+host_function!(A, B, C, D, E);
+host_function!(A, B, C, D, E, F);
+
+fn convert<T: DeserializeOwned>(value: &Value) -> Result<T, String> {
+    serde_json::from_value(ruff_value_to_json(&plain_data(value))?)
+        .map_err(|error| error.to_string())
+}
+
+/// Rewrites struct instances as dicts of their fields, at any depth
+fn plain_data(value: &Value) -> Value {
+    match value {
+        Value::Struct { fields, .. } => {
+            let mut dict = DictMap::default();
+            for (name, field) in fields {
+                dict.insert(name.as_str().into(), plain_data(field));
+            }
+            Value::Dict(Arc::new(dict))
+        }
+        Value::Array(items) => Value::Array(Arc::new(items.iter().map(plain_data).collect())),
+        Value::Dict(map) => {
+            let map = map.iter().map(|(key, item)| (key.clone(), plain_data(item))).collect();
+            Value::Dict(Arc::new(map))
+        }
+        Value::FixedDict { keys, values } => {
+            Value::FixedDict { keys: keys.clone(), values: values.iter().map(plain_data).collect() }
+        }
+        other => other.clone(),
+    }
+}
+
+fn parse(source: &str) -> EmbedResult<Vec<Stmt>> {
+    let tokens = lexer::tokenize(source).map_err(|diagnostics| {
+        let first = &diagnostics[0];
+        Box::new(RuffError::parse_error(
+            first.message.clone(),
+            SourceLocation::new(first.line, first.column),
+        ))
+    })?;
+    let output = parser::Parser::new(tokens).with_source_positions().parse_with_diagnostics();
+    if let Some(diagnostic) = output.diagnostics.first() {
+        return Err(Box::new(RuffError::parse_error(
+            diagnostic.message.clone(),
+            SourceLocation::new(diagnostic.line, diagnostic.column),
+        )));
+    }
+    // Position markers only annotate the statements after them
+    let mut stmts = output.stmts;
+    if matches!(stmts.last(), Some(Stmt::SourcePos { .. })) {
+        stmts.pop();
+    }
+    Ok(stmts)
+}
+
+fn error_message(value: &Value) -> Option<String> {
+    match value {
+        Value::Error(message) | Value::ErrorObject { message, .. } => Some(message.clone()),
+        _ => None,
+    }
+}
+
+fn runtime_error(message: String) -> Box<RuffError> {
+    Box::new(RuffError::runtime_error(message, SourceLocation::unknown()))
+}
@@ -115,7 +115,10 @@ pub fn line_column_from_byte_offset(source: &str, byte_offset: usize) -> (usize,
 
 #[cfg(test)]
 mod tests {
-    use super::{line_column_from_byte_offset, SourceSpan};
+    use super::{
+        line_column_from_byte_offset, Diagnostic, DiagnosticSeverity, DiagnosticSubsystem,
+        ErrorKind, RuffError, SourceLocation, SourceSpan, StackFrame,
+    };
 
     #[test]
     fn line_column_conversion_handles_multiline_utf8() {
@@ -134,6 +137,79 @@ mod tests {
         assert_eq!(span.start_byte, 4);
         assert_eq!(span.end_byte, 9);
     }
+
+    #[test]
+    fn render_human_shows_source_excerpt_with_caret_under_span() {
+        let source = "x := 1\nlet total := (x + 2\nprint(total)\n";
+        let diagnostic = Diagnostic::new(
+            "RUFPARSE001",
+            DiagnosticSeverity::Error,
+            DiagnosticSubsystem::Parser,
+            "boom",
+        )
+        .with_location(Some("main.ruff".to_string()), 2, 5)
+        .with_highlight_width(5)
+        .with_help("hint")
+        .with_source_excerpt(source);
+
+        assert_eq!(
+            diagnostic.render_human(),
+            "[RUFPARSE001] [parser] error: boom\n  --> main.ruff:2:5\n   |\n 2 | let total := (x + 2\n   |     ^^^^^\n  = help: hint"
+        );
+    }
+
+    #[test]
+    fn render_human_skips_excerpt_for_lines_outside_source() {
+        let diagnostic = Diagnostic::new(
+            "RUFPARSE001",
+            DiagnosticSeverity::Error,
+            DiagnosticSubsystem::Parser,
+            "eof",
+        )
+        .with_location(None, 3, 1)
+        .with_source_excerpt("only one line\n");
+
+        assert_eq!(diagnostic.source_line, None);
+        assert_eq!(diagnostic.render_human(), "[RUFPARSE001] [parser] error: eof\n  --> 3:1");
+    }
+
+    #[test]
+    fn render_human_clamps_caret_to_the_source_line() {
+        let diagnostic = Diagnostic::new(
+            "RUFLEX003",
+            DiagnosticSeverity::Error,
+            DiagnosticSubsystem::Lexer,
+            "open",
+        )
+        .with_location(None, 1, 4)
+        .with_highlight_width(40)
+        .with_source_excerpt("\tx \"abc");
+
+        assert!(diagnostic.render_human().ends_with(" 1 | \tx \"abc\n   | \t  ^^^^"));
+    }
+
+    #[test]
+    fn with_stack_trace_describes_frames_and_collapses_recursion() {
+        let frames = vec![
+            StackFrame::new("<main>", Some((9, 1))),
+            StackFrame::new("fact", Some((3, 12))),
+            StackFrame::new("fact", Some((3, 12))),
+            StackFrame::new("fact", Some((3, 12))),
+            StackFrame::new("native_helper", None),
+        ];
+        let error =
+            RuffError::new(ErrorKind::RuntimeError, "boom".to_string(), SourceLocation::unknown())
+                .with_stack_trace("main.ruff", &frames);
+
+        assert_eq!(
+            error.call_stack,
+            vec![
+                "<main> (main.ruff:9:1)".to_string(),
+                "fact (main.ruff:3:12) [repeated 3 times]".to_string(),
+                "native_helper".to_string(),
+            ]
+        );
+    }
 }
 
 pub const DIAGNOSTIC_CODE_LEXER: &str = "RUFLEX001";
@@ -142,12 +218,17 @@ pub const DIAGNOSTIC_CODE_RUNTIME: &str = "RUFRUN001";
 pub const DIAGNOSTIC_CODE_VM: &str = "RUFVM001";
 pub const DIAGNOSTIC_CODE_CLI: &str = "RUFCLI001";
 pub const DIAGNOSTIC_CODE_LSP: &str = "RUFLSP001";
+pub const DIAGNOSTIC_CODE_TYPE: &str = "RUFTYPE001";
 pub const RUN_RUNTIME_DIAGNOSTIC_CONTRACT_VERSION: &str = "1.0.0-draft";
 
 pub fn unsupported_struct_generator_method_message(struct_name: &str, method_name: &str) -> String {
     format!("Generator methods are not supported for structs: {}.{}", struct_name, method_name)
 }
 
+pub fn unknown_struct_field_message(struct_name: &str, field_name: &str) -> String {
+    format!("Struct '{}' has no field '{}'", struct_name, field_name)
+}
+
 pub fn run_runtime_diagnostic_envelope_json(
     diagnostic: &Diagnostic,
     exit_code: i32,
@@ -183,6 +264,7 @@ pub enum DiagnosticSubsystem {
     Vm,
     Cli,
     Lsp,
+    Type,
 }
 
 impl DiagnosticSubsystem {
@@ -194,6 +276,7 @@ impl DiagnosticSubsystem {
             DiagnosticSubsystem::Vm => "vm",
             DiagnosticSubsystem::Cli => "cli",
             DiagnosticSubsystem::Lsp => "lsp",
+            DiagnosticSubsystem::Type => "type",
         }
     }
 }
@@ -208,6 +291,10 @@ pub struct Diagnostic {
     pub file: Option<String>,
     pub line: usize,
     pub column: usize,
+    /// Text of the source line at `line`, rendered as an excerpt under the location.
+    pub source_line: Option<String>,
+    /// Number of columns the caret underline covers, starting at `column`.
+    pub highlight_width: usize,
 }
 
 impl Diagnostic {
@@ -226,6 +313,8 @@ impl Diagnostic {
             file: None,
             line: 0,
             column: 0,
+            source_line: None,
+            highlight_width: 1,
         }
     }
 
@@ -241,6 +330,18 @@ impl Diagnostic {
         self
     }
 
+    pub fn with_highlight_width(mut self, width: usize) -> Self {
+        self.highlight_width = width.max(1);
+        self
+    }
+
+    /// Attach the line at this diagnostic's location from `source` so the human
+    /// rendering can show an excerpt with a caret under the offending span.
+    pub fn with_source_excerpt(mut self, source: &str) -> Self {
+        self.source_line = source_line_at(source, self.line).map(str::to_string);
+        self
+    }
+
     pub fn render_human(&self) -> String {
         let mut lines = Vec::new();
         lines.push(format!(
@@ -258,6 +359,15 @@ impl Diagnostic {
                 format!("{}:{}", self.line, self.column)
             };
             lines.push(format!("  --> {}", location));
+
+            if let Some(source_line) = &self.source_line {
+                lines.extend(render_source_excerpt(
+                    source_line,
+                    self.line,
+                    self.column,
+                    self.highlight_width,
+                ));
+            }
         }
 
         if let Some(help) = &self.help {
@@ -281,6 +391,36 @@ impl Diagnostic {
     }
 }
 
+/// Return the 1-based `line` of `source` without its line terminator.
+pub fn source_line_at(source: &str, line: usize) -> Option<&str> {
+    if line == 0 {
+        return None;
+    }
+    source.lines().nth(line - 1)
+}
+
+/// Render the gutter, source line, and caret underline shown beneath a location arrow.
+fn render_source_excerpt(
+    source_line: &str,
+    line: usize,
+    column: usize,
+    width: usize,
+) -> Vec<String> {
+    let gutter = " ".repeat(line.to_string().len());
+    let line_chars = source_line.chars().count();
+    let start = column.saturating_sub(1).min(line_chars);
+    // Keep the underline on this line; spans running past it (or past EOF) get one caret.
+    let width = width.min(line_chars.saturating_sub(start)).max(1);
+    let padding: String =
+        source_line.chars().take(start).map(|ch| if ch == '\t' { '\t' } else { ' ' }).collect();
+
+    vec![
+        format!(" {} |", gutter),
+        format!(" {} | {}", line, source_line),
+        format!(" {} | {}{}", gutter, padding, "^".repeat(width)),
+    ]
+}
+
 /// Types of errors that can occur in Ruff
 #[derive(Debug, Clone, PartialEq)]
 #[allow(dead_code)]
@@ -308,6 +448,28 @@ impl fmt::Display for ErrorKind {
     }
 }
 
+/// One frame of a runtime stack trace: the function that was active and the
+/// `(line, column)` of the statement it was executing when the error surfaced.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct StackFrame {
+    pub function: String,
+    pub position: Option<(usize, usize)>,
+}
+
+impl StackFrame {
+    pub fn new(function: impl Into<String>, position: Option<(usize, usize)>) -> Self {
+        Self { function: function.into(), position }
+    }
+
+    /// Renders the frame as `name (file:line:column)`, or just `name` without a position.
+    pub fn describe(&self, file: &str) -> String {
+        match self.position {
+            Some((line, column)) => format!("{} ({}:{}:{})", self.function, file, line, column),
+            None => self.function.clone(),
+        }
+    }
+}
+
 /// A structured error with location information and call stack
 #[derive(Debug, Clone)]
 pub struct RuffError {
@@ -360,6 +522,19 @@ impl RuffError {
         self
     }
 
+    /// Locate this error at `line:column` of `file`, attaching that line of `source`.
+    pub fn with_source_position(
+        mut self,
+        file: &str,
+        source: &str,
+        line: usize,
+        column: usize,
+    ) -> Self {
+        self.location = SourceLocation::with_file(line, column, file.to_string());
+        self.source_line = source_line_at(source, line).map(str::to_string);
+        self
+    }
+
     pub fn with_suggestion(mut self, suggestion: String) -> Self {
         self.suggestion = Some(suggestion);
         self
@@ -380,6 +555,26 @@ impl RuffError {
         self
     }
 
+    /// Attach `frames` (outermost first) as the call stack, describing each one against
+    /// `file`. Runs of identical frames, as in deep recursion, collapse into one entry.
+    pub fn with_stack_trace(mut self, file: &str, frames: &[StackFrame]) -> Self {
+        let mut call_stack: Vec<String> = Vec::new();
+        let mut index = 0;
+        while index < frames.len() {
+            let repeats =
+                frames[index..].iter().take_while(|frame| **frame == frames[index]).count();
+            let described = frames[index].describe(file);
+            if repeats > 1 {
+                call_stack.push(format!("{} [repeated {} times]", described, repeats));
+            } else {
+                call_stack.push(described);
+            }
+            index += repeats;
+        }
+        self.call_stack = call_stack;
+        self
+    }
+
     pub fn with_diagnostic_code(mut self, diagnostic_code: impl Into<String>) -> Self {
         self.diagnostic_code = diagnostic_code.into();
         self
@@ -407,6 +602,7 @@ impl RuffError {
         if let Some(help) = &self.help {
             diagnostic = diagnostic.with_help(help.clone());
         }
+        diagnostic.source_line = self.source_line.clone();
         diagnostic
     }
 
@@ -463,7 +659,7 @@ impl fmt::Display for RuffError {
             let line_num = self.location.line;
             let col_num = self.location.column;
 
-            writeln!(f, "   {}", "|".bright_blue())?;
+            writeln!(f, "    {}", "|".bright_blue())?;
             writeln!(
                 f,
                 "{} {} {}",
@@ -473,12 +669,12 @@ impl fmt::Display for RuffError {
             )?;
             writeln!(
                 f,
-                "   {} {}{}",
+                "    {} {}{}",
                 "|".bright_blue(),
                 " ".repeat(col_num.saturating_sub(1)),
                 "^".red().bold()
             )?;
-            writeln!(f, "   {}", "|".bright_blue())?;
+            writeln!(f, "    {}", "|".bright_blue())?;
         }
 
         // Additional context sections
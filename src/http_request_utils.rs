@@ -30,6 +30,28 @@ pub fn split_http_path_and_query_with_decoded(
     }
 }
 
+/// Match a route pattern such as `/users/:id` against a request path.
+///
+/// Segments starting with `:` capture the matching path segment; every other segment
+/// must match exactly. Returns the captured parameters, or `None` if the path differs.
+pub fn match_http_route_pattern(pattern: &str, path: &str) -> Option<HashMap<String, String>> {
+    let pattern_parts: Vec<&str> = pattern.split('/').collect();
+    let path_parts: Vec<&str> = path.split('/').collect();
+    if pattern_parts.len() != path_parts.len() {
+        return None;
+    }
+
+    let mut params = HashMap::new();
+    for (pattern_part, path_part) in pattern_parts.iter().zip(path_parts.iter()) {
+        if let Some(param_name) = pattern_part.strip_prefix(':') {
+            params.insert(param_name.to_string(), path_part.to_string());
+        } else if pattern_part != path_part {
+            return None;
+        }
+    }
+    Some(params)
+}
+
 fn parse_http_query_params(raw_query: &str, decode_values: bool) -> HashMap<String, String> {
     let mut query_params = HashMap::new();
 
@@ -160,4 +182,15 @@ mod tests {
 
         assert_eq!(decoded_query_map.get("bad").map(String::as_str), Some("%2"));
     }
+
+    #[test]
+    fn match_http_route_pattern_captures_named_segments() {
+        let params = super::match_http_route_pattern("/users/:id/posts/:post", "/users/7/posts/x1")
+            .expect("pattern should match");
+        assert_eq!(params.get("id").map(String::as_str), Some("7"));
+        assert_eq!(params.get("post").map(String::as_str), Some("x1"));
+
+        assert!(super::match_http_route_pattern("/users/:id", "/users/7/posts").is_none());
+        assert!(super::match_http_route_pattern("/users/:id", "/groups/7").is_none());
+    }
 }
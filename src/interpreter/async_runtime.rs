@@ -13,7 +13,7 @@
 
 use once_cell::sync::Lazy;
 use std::time::Duration;
-use tokio::runtime::Runtime;
+use tokio::runtime::{Runtime, RuntimeFlavor};
 use tokio::task::JoinHandle;
 
 use crate::interpreter::Value;
@@ -52,7 +52,10 @@ impl AsyncRuntime {
     ///
     /// This is used by the `await` expression to synchronously wait for
     /// a promise to resolve. While this blocks the Ruff interpreter thread,
-    /// the tokio runtime can still make progress on other tasks.
+    /// the tokio runtime can still make progress on other tasks. When called
+    /// from a multi-threaded runtime worker (for example an `await` inside an
+    /// `async func` body), the worker is handed off via `block_in_place` so the
+    /// nested wait does not panic.
     ///
     /// # Arguments
     /// * `future` - The async computation to wait for
@@ -63,7 +66,12 @@ impl AsyncRuntime {
     where
         F: std::future::Future,
     {
-        Self::runtime().block_on(future)
+        match tokio::runtime::Handle::try_current() {
+            Ok(handle) if handle.runtime_flavor() == RuntimeFlavor::MultiThread => {
+                tokio::task::block_in_place(|| Self::runtime().block_on(future))
+            }
+            _ => Self::runtime().block_on(future),
+        }
     }
 
     /// Create a future that completes after a duration
@@ -119,6 +127,20 @@ mod tests {
         assert_eq!(result, 42);
     }
 
+    #[test]
+    fn test_block_on_nested_inside_runtime_task() {
+        // `await` inside an async function body blocks from a runtime worker thread
+        let handle = AsyncRuntime::spawn_task(async {
+            let inner = AsyncRuntime::block_on(async { 21 });
+            Value::Int(inner * 2)
+        });
+
+        match AsyncRuntime::block_on(handle) {
+            Ok(Value::Int(42)) => {}
+            other => panic!("Expected Int(42), got {:?}", other),
+        }
+    }
+
     #[test]
     fn test_sleep() {
         // Sleep should delay for at least the specified duration
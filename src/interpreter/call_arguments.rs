@@ -0,0 +1,205 @@
+// File: src/interpreter/call_arguments.rs
+//
+// Binding of call-site arguments to parameter lists.
+//
+// A call may pass `...array` spreads and trailing `name: value` arguments, and a
+// function may declare literal parameter defaults and a final `...rest` parameter.
+// Both runtimes resolve such a call here into exactly one value per parameter, so
+// the index-based binding that follows is the same as for a plain call: named
+// values land in their parameter's slot, missing trailing parameters take their
+// defaults, and extra positional values are packed into the rest array.
+
+use super::{CallableArity, DictMap, Interpreter, LeakyFunctionBody, Value};
+use crate::ast::{ArrayElement, DictElement, Expr};
+use std::sync::Arc;
+
+/// Arguments evaluated at a call site: positional values with spreads expanded,
+/// then named values in source order.
+#[derive(Default)]
+pub(crate) struct CallArgs {
+    pub positional: Vec<Value>,
+    pub named: Vec<(String, Value)>,
+}
+
+impl CallArgs {
+    pub fn positional(values: Vec<Value>) -> Self {
+        Self { positional: values, named: Vec::new() }
+    }
+}
+
+/// Resolve `args` against `params`, returning one value per parameter.
+///
+/// `param_defaults` is aligned with `params` or empty; `default_value` turns a stored
+/// default into the value bound for this call. Errors name `callable`.
+pub(crate) fn arrange_call_args<D>(
+    callable: &str,
+    params: &[String],
+    param_defaults: &[Option<D>],
+    is_variadic: bool,
+    args: CallArgs,
+    default_value: impl Fn(&D) -> Value,
+) -> Result<Vec<Value>, String> {
+    let CallArgs { mut positional, named } = args;
+    let fixed = if is_variadic { params.len().saturating_sub(1) } else { params.len() };
+    let required = (0..fixed)
+        .take_while(|&index| param_defaults.get(index).map_or(true, Option::is_none))
+        .count();
+    let arity = if is_variadic {
+        CallableArity::variadic(callable, required, params.to_vec())
+    } else {
+        CallableArity::range(callable, required, fixed, params.to_vec())
+    };
+    if named.is_empty() || positional.len() > fixed {
+        arity.validate(positional.len())?;
+    }
+
+    let rest = if positional.len() > fixed { positional.split_off(fixed) } else { Vec::new() };
+    let mut slots: Vec<Option<Value>> = positional.into_iter().map(Some).collect();
+    slots.resize(fixed, None);
+
+    for (name, value) in named {
+        let Some(index) = params[..fixed].iter().position(|param| *param == name) else {
+            return Err(format!("{} has no parameter named '{}'", callable, name));
+        };
+        if slots[index].is_some() {
+            return Err(format!("{} got multiple values for parameter '{}'", callable, name));
+        }
+        slots[index] = Some(value);
+    }
+
+    let mut arranged = Vec::with_capacity(params.len());
+    for (index, slot) in slots.into_iter().enumerate() {
+        let value =
+            slot.or_else(|| param_defaults.get(index).and_then(Option::as_ref).map(&default_value));
+        match value {
+            Some(value) => arranged.push(value),
+            None => {
+                return Err(format!(
+                    "{} is missing an argument for parameter '{}'",
+                    callable, params[index]
+                ))
+            }
+        }
+    }
+    if is_variadic {
+        arranged.push(Value::Array(Arc::new(rest)));
+    }
+    Ok(arranged)
+}
+
+/// Value of a literal the parser accepts as a parameter default.
+fn literal_default_value(expr: &Expr) -> Value {
+    match expr {
+        Expr::Int(n) => Value::Int(*n),
+        Expr::Float(f) => Value::Float(*f),
+        Expr::String(s) => Value::Str(Arc::new(s.clone())),
+        Expr::Bool(b) => Value::Bool(*b),
+        Expr::UnaryOp { operand, .. } => match literal_default_value(operand) {
+            Value::Int(n) => Value::Int(n.wrapping_neg()),
+            Value::Float(f) => Value::Float(-f),
+            other => other,
+        },
+        Expr::ArrayLiteral(elements) => Value::Array(Arc::new(
+            elements
+                .iter()
+                .filter_map(|element| match element {
+                    ArrayElement::Single(expr) => Some(literal_default_value(expr)),
+                    ArrayElement::Spread(_) => None,
+                })
+                .collect(),
+        )),
+        Expr::DictLiteral(elements) => {
+            let mut dict = DictMap::default();
+            for element in elements {
+                if let DictElement::Pair(Expr::String(key), value) = element {
+                    dict.insert(Arc::from(key.as_str()), literal_default_value(value));
+                }
+            }
+            Value::Dict(Arc::new(dict))
+        }
+        _ => Value::Null,
+    }
+}
+
+impl Interpreter {
+    /// Evaluate call-site arguments in the current scope, expanding `...array`
+    /// spreads and collecting `name: value` pairs. Returns the first error raised.
+    pub(super) fn eval_call_args(&mut self, args: &[Expr]) -> Result<CallArgs, Value> {
+        let mut call_args = CallArgs::default();
+        for arg in args {
+            match arg {
+                Expr::Spread(expr) => match self.eval_expr(expr) {
+                    Value::Array(values) => call_args.positional.extend(values.iter().cloned()),
+                    error if Self::is_error_value(&error) => return Err(error),
+                    other => {
+                        return Err(Value::Error(format!(
+                            "Spread argument must be an array, got {}",
+                            Self::value_type_name(&other)
+                        )))
+                    }
+                },
+                Expr::NamedArg { name, value } => {
+                    let value = self.eval_expr(value);
+                    if Self::is_error_value(&value) {
+                        return Err(value);
+                    }
+                    call_args.named.push((name.clone(), value));
+                }
+                expr => {
+                    let value = self.eval_expr(expr);
+                    if Self::is_error_value(&value) {
+                        return Err(value);
+                    }
+                    call_args.positional.push(value);
+                }
+            }
+        }
+        Ok(call_args)
+    }
+
+    /// Whether a call passes `...spread` or `name: value` arguments.
+    pub(super) fn has_expanded_args(args: &[Expr]) -> bool {
+        args.iter().any(|arg| matches!(arg, Expr::Spread(_) | Expr::NamedArg { .. }))
+    }
+
+    /// One value per parameter of a user function called with `args`.
+    ///
+    /// `skip` leading parameters, such as a method's `self`, are bound by the caller.
+    /// Functions without defaults or a rest parameter that receive no named arguments
+    /// get their positional values back unchanged, leaving arity errors to the caller.
+    pub(super) fn arrange_function_args(
+        callable: &str,
+        params: &[String],
+        body: &LeakyFunctionBody,
+        skip: usize,
+        args: CallArgs,
+    ) -> Result<Vec<Value>, Value> {
+        if !body.has_signature() && args.named.is_empty() {
+            return Ok(args.positional);
+        }
+
+        let body = body.get();
+        let signature = body.signature();
+        // Parameters prepended to the declared ones, like the receiver of a module
+        // function called as a method, have no default.
+        let prepended = if signature.param_defaults.is_empty() {
+            0
+        } else {
+            params.len().saturating_sub(signature.param_defaults.len())
+        };
+        let param_defaults: Vec<Option<&Expr>> = std::iter::repeat(None)
+            .take(prepended)
+            .chain(signature.param_defaults.iter().map(Option::as_ref))
+            .skip(skip)
+            .collect();
+        arrange_call_args(
+            callable,
+            params.get(skip..).unwrap_or_default(),
+            &param_defaults,
+            signature.is_variadic,
+            args,
+            |expr| literal_default_value(expr),
+        )
+        .map_err(Value::Error)
+    }
+}
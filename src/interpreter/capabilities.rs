@@ -12,6 +12,7 @@ pub enum NativeCapability {
     Database,
     Clock,
     Random,
+    Ffi,
 }
 
 impl NativeCapability {
@@ -29,6 +30,7 @@ impl NativeCapability {
             NativeCapability::Database => "database",
             NativeCapability::Clock => "clock",
             NativeCapability::Random => "random",
+            NativeCapability::Ffi => "ffi",
         }
     }
 
@@ -46,6 +48,7 @@ impl NativeCapability {
             NativeCapability::Database => "--allow-database",
             NativeCapability::Clock => "--allow-clock",
             NativeCapability::Random => "--allow-random",
+            NativeCapability::Ffi => "--allow-ffi",
         }
     }
 }
@@ -64,6 +67,7 @@ pub struct RuntimeCapabilityPolicy {
     pub database: bool,
     pub clock: bool,
     pub random: bool,
+    pub ffi: bool,
 }
 
 impl RuntimeCapabilityPolicy {
@@ -81,6 +85,7 @@ impl RuntimeCapabilityPolicy {
             database: true,
             clock: true,
             random: true,
+            ffi: true,
         }
     }
 
@@ -102,6 +107,7 @@ impl RuntimeCapabilityPolicy {
             NativeCapability::Database => self.database,
             NativeCapability::Clock => self.clock,
             NativeCapability::Random => self.random,
+            NativeCapability::Ffi => self.ffi,
         }
     }
 }
@@ -114,8 +120,9 @@ pub fn capability_for_native_function(name: &str) -> Option<NativeCapability> {
         | "path_exists" | "path_is_dir" | "path_is_file" | "path_extension" | "path_absolute"
         | "path_is_symlink" | "dirname" | "basename" | "join_path" | "path_join" | "os_getcwd"
         | "os_environ" | "io_read_bytes" | "io_read_at" | "io_seek_read" | "io_file_metadata"
-        | "load_image" | "md5_file" | "sha256_file" | "read_file_lossy" | "async_read_file"
-        | "async_read_files" | "kv_get" => Some(NativeCapability::FilesystemRead),
+        | "io_open" | "load_image" | "md5_file" | "sha256_file" | "read_file_lossy"
+        | "async_read_file" | "async_read_files" | "kv_get" | "fs.read" | "fs.exists"
+        | "fs.stat" | "fs.glob" | "fs.walk" => Some(NativeCapability::FilesystemRead),
 
         // Filesystem write
         "write_file"
@@ -142,21 +149,25 @@ pub fn capability_for_native_function(name: &str) -> Option<NativeCapability> {
         | "async_write_files"
         | "ssg_render_and_write_pages"
         | "ssg_read_render_and_write_pages"
-        | "kv_set" => Some(NativeCapability::FilesystemWrite),
+        | "kv_set"
+        | "fs.write"
+        | "fs.append"
+        | "fs.mkdir_all" => Some(NativeCapability::FilesystemWrite),
 
         // Filesystem delete
         "delete_file" | "os_rmdir" => Some(NativeCapability::FilesystemDelete),
 
         // Process execution
-        "spawn_process" | "pipe_commands" => Some(NativeCapability::ProcessExec),
+        "spawn_process" | "pipe_commands" | "proc.run" | "proc.spawn" | "proc.pipeline" => {
+            Some(NativeCapability::ProcessExec)
+        }
 
         // Shell execution
         "execute" | "execute_status" => Some(NativeCapability::ShellExec),
 
         // Environment read/write
-        "env" | "env_or" | "env_int" | "env_float" | "env_bool" | "env_required" | "env_list" => {
-            Some(NativeCapability::EnvRead)
-        }
+        "env" | "env_or" | "env_int" | "env_float" | "env_bool" | "env_required" | "env_list"
+        | "os.env" => Some(NativeCapability::EnvRead),
         "env_set" => Some(NativeCapability::EnvWrite),
 
         // Network client/server
@@ -164,25 +175,35 @@ pub fn capability_for_native_function(name: &str) -> Option<NativeCapability> {
         | "http_delete" | "http_get_binary" | "http_get_stream" | "oauth2_get_token"
         | "ai_chat" | "ai_stream_chat" | "ai_embedding" | "ai_tool_loop" | "tcp_connect"
         | "tcp_send" | "tcp_receive" | "udp_send_to" | "udp_receive_from" | "async_http_get"
-        | "async_http_post" => Some(NativeCapability::NetworkClient),
-        "tcp_listen" | "tcp_accept" | "udp_bind" | "http_listen" => {
+        | "async_http_post" | "http.get" | "http.post" | "http.put" | "http.patch"
+        | "http.delete" | "http.request" | "http.get_json" | "http.post_json" | "net.dial" => {
+            Some(NativeCapability::NetworkClient)
+        }
+        "tcp_listen" | "tcp_accept" | "udp_bind" | "http_listen" | "net.listen" | "http.serve" => {
             Some(NativeCapability::NetworkServer)
         }
 
         // Database
         "db_connect" | "db_execute" | "db_query" | "db_close" | "db_pool" | "db_pool_acquire"
         | "db_pool_release" | "db_pool_stats" | "db_pool_close" | "db_begin" | "db_commit"
-        | "db_rollback" | "db_last_insert_id" => Some(NativeCapability::Database),
+        | "db_rollback" | "db_last_insert_id" | "sqlite.open" => Some(NativeCapability::Database),
 
         // Clock/time
         "now" | "now_utc" | "now_unix" | "current_timestamp" | "performance_now" | "time_us"
         | "time_ns" | "format_duration" | "elapsed" | "format_date" | "parse_date" | "sleep"
-        | "async_sleep" | "async_timeout" => Some(NativeCapability::Clock),
+        | "async_sleep" | "async_timeout" | "time_now" | "time_monotonic" | "time_format"
+        | "time_parse" | "time_parts" | "time_add_days" | "time_add_months" | "time_diff"
+        | "time_duration" => Some(NativeCapability::Clock),
 
         // Randomness
         "random" | "random_int" | "random_choice" | "uuid_v4" | "random_id" | "set_random_seed"
         | "clear_random_seed" => Some(NativeCapability::Random),
 
+        // Native code and raw memory
+        "ffi.load" | "ffi.alloc" | "ffi.free" | "ffi.read_pointer" | "ffi.read_string" => {
+            Some(NativeCapability::Ffi)
+        }
+
         _ => None,
     }
 }
@@ -5,7 +5,9 @@
 // The interpreter uses ControlFlow to manage break/continue statements
 // within loops (for, while). This allows the interpreter to signal
 // when execution should exit a loop (Break) or skip to the next iteration
-// (Continue) without using exceptions.
+// (Continue) without using exceptions. A labeled signal (`break outer`)
+// stays pending while inner loops unwind until the loop carrying that
+// label handles it.
 
 /// Control flow signals for loop execution
 ///
@@ -15,8 +17,9 @@
 pub(crate) enum ControlFlow {
     /// Normal execution, continue to next statement
     None,
-    /// Break statement encountered, exit the innermost loop
-    Break,
-    /// Continue statement encountered, skip to next loop iteration
-    Continue,
+    /// Break statement encountered, exit the innermost loop or the labeled one
+    Break(Option<String>),
+    /// Continue statement encountered, skip to the next iteration of the innermost
+    /// loop or the labeled one
+    Continue(Option<String>),
 }
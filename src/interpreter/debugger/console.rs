@@ -0,0 +1,150 @@
+// Console frontend for `ruff debug`: reads one command per line, gdb/pdb style
+
+use super::{Breakpoint, DebugFrontend, PausedFrame, Resume};
+use std::io::{BufRead, Write};
+
+const HELP: &str = "\
+Commands:
+  break, b [FILE:]LINE   add a breakpoint (no argument lists breakpoints)
+  delete, d N            remove breakpoint N
+  step, s                run to the next statement, entering calls
+  next, n                run to the next statement in this frame or its callers
+  finish, f              run until the current function returns
+  continue, c            run to the next breakpoint
+  backtrace, bt          show the call stack
+  print, p EXPR          evaluate EXPR in the current frame
+  list, l                show source around the current line
+  quit, q                stop the program";
+
+pub(super) struct ConsoleFrontend {
+    input: Box<dyn BufRead>,
+    output: Box<dyn Write>,
+}
+
+impl ConsoleFrontend {
+    pub(super) fn stdio() -> Self {
+        Self {
+            input: Box::new(std::io::BufReader::new(std::io::stdin())),
+            output: Box::new(std::io::stdout()),
+        }
+    }
+
+    fn print_location(&mut self, frame: &PausedFrame<'_>) {
+        let line = frame.line();
+        let _ =
+            writeln!(self.output, "Paused at {}:{} in {}", frame.file(), line, frame.function());
+        if let Some(text) = frame.source_lines().get(line - 1) {
+            let _ = writeln!(self.output, "{:>5} | {}", line, text.trim_end());
+        }
+    }
+
+    fn print_listing(&mut self, frame: &PausedFrame<'_>) {
+        let current = frame.line();
+        let lines = frame.source_lines();
+        let first = current.saturating_sub(3).max(1);
+        let last = (current + 3).min(lines.len());
+        for line in first..=last {
+            let marker = if line == current { "->" } else { "  " };
+            let rendered = format!("{} {:>4} | {}", marker, line, lines[line - 1].trim_end());
+            let _ = writeln!(self.output, "{}", rendered.trim_end());
+        }
+    }
+
+    fn print_backtrace(&mut self, frame: &PausedFrame<'_>) {
+        // Innermost frame first
+        for (index, stack_frame) in frame.stack_trace().iter().rev().enumerate() {
+            let _ = writeln!(self.output, "#{} {}", index, stack_frame.describe(frame.file()));
+        }
+    }
+
+    fn list_breakpoints(&mut self, breakpoints: &[Breakpoint]) {
+        if breakpoints.is_empty() {
+            let _ = writeln!(self.output, "No breakpoints");
+        }
+        for (index, breakpoint) in breakpoints.iter().enumerate() {
+            let _ = writeln!(self.output, "{}: {}", index + 1, breakpoint.describe());
+        }
+    }
+}
+
+impl DebugFrontend for ConsoleFrontend {
+    /// Reads commands until one resumes execution; end of input detaches
+    fn pause(&mut self, frame: &mut PausedFrame<'_>) -> Resume {
+        self.print_location(frame);
+
+        loop {
+            let _ = write!(self.output, "(ruff-debug) ");
+            let _ = self.output.flush();
+            let mut line = String::new();
+            if matches!(self.input.read_line(&mut line), Ok(0) | Err(_)) {
+                let _ = writeln!(self.output);
+                return Resume::Detach;
+            }
+
+            let line = line.trim();
+            let (command, argument) = match line.split_once(char::is_whitespace) {
+                Some((command, argument)) => (command, argument.trim()),
+                None => (line, ""),
+            };
+            match command {
+                "" => {}
+                "step" | "s" => return Resume::Step,
+                "next" | "n" => return Resume::Next,
+                "finish" | "f" => return Resume::Finish,
+                "continue" | "c" => return Resume::Continue,
+                "break" | "b" if argument.is_empty() => {
+                    self.list_breakpoints(&frame.state.breakpoints)
+                }
+                "break" | "b" => match Breakpoint::parse(argument) {
+                    Ok(breakpoint) => {
+                        let breakpoints = &mut frame.state.breakpoints;
+                        let _ = writeln!(
+                            self.output,
+                            "Breakpoint {} at {}",
+                            breakpoints.len() + 1,
+                            breakpoint.describe()
+                        );
+                        breakpoints.push(breakpoint);
+                    }
+                    Err(message) => {
+                        let _ = writeln!(self.output, "{}", message);
+                    }
+                },
+                "delete" | "d" => {
+                    let breakpoints = &mut frame.state.breakpoints;
+                    match argument.parse::<usize>() {
+                        Ok(index) if index >= 1 && index <= breakpoints.len() => {
+                            let removed = breakpoints.remove(index - 1);
+                            let _ = writeln!(
+                                self.output,
+                                "Deleted breakpoint at {}",
+                                removed.describe()
+                            );
+                        }
+                        _ => {
+                            let _ = writeln!(self.output, "No breakpoint '{}'", argument);
+                        }
+                    }
+                }
+                "backtrace" | "bt" | "where" => self.print_backtrace(frame),
+                "print" | "p" => {
+                    let _ = match frame.evaluate(argument) {
+                        Ok(value) => writeln!(self.output, "{}", frame.display(&value)),
+                        Err(message) => writeln!(self.output, "Error: {}", message),
+                    };
+                }
+                "list" | "l" => self.print_listing(frame),
+                "help" | "h" => {
+                    let _ = writeln!(self.output, "{}", HELP);
+                }
+                "quit" | "q" => {
+                    let _ = self.output.flush();
+                    std::process::exit(0);
+                }
+                other => {
+                    let _ = writeln!(self.output, "Unknown command '{}' (try 'help')", other);
+                }
+            }
+        }
+    }
+}
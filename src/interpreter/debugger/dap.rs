@@ -0,0 +1,482 @@
+// Debug Adapter Protocol frontend for `ruff run --dap`
+//
+// Serves a single client over TCP. A reader thread decodes requests onto a channel; the
+// interpreter thread answers them, polling between statements while the program runs
+// and blocking while it is paused. Only the entry script carries statement positions,
+// so breakpoints in other files are reported as unverified.
+
+use super::{Breakpoint, DebugFrontend, DebugState, Debugger, PauseReason, PausedFrame, Resume};
+use crate::interpreter::Value;
+use serde_json::{json, Value as Json};
+use std::io::{self, BufRead, BufReader, Write};
+use std::net::{TcpListener, TcpStream};
+use std::path::{Path, PathBuf};
+use std::sync::mpsc::{self, Receiver, TryRecvError};
+use std::time::Duration;
+
+/// The interpreter runs the program on one thread, reported to clients under this id
+const THREAD_ID: i64 = 1;
+const LOCALS_REFERENCE: i64 = 1;
+const GLOBALS_REFERENCE: i64 = 2;
+/// `variablesReference` of the first expandable value handed out during a pause
+const FIRST_VALUE_REFERENCE: i64 = 3;
+
+pub(super) struct DapFrontend {
+    writer: TcpStream,
+    requests: Receiver<Json>,
+    seq: i64,
+    /// Entry script as passed on the command line, which is how the interpreter names it
+    entry_file: String,
+    entry_path: PathBuf,
+    /// Arrays, dicts, and structs the client may expand; valid until the next resume
+    values: Vec<Value>,
+}
+
+impl DapFrontend {
+    /// Waits for a client, then answers requests until `configurationDone`
+    pub(super) fn accept(address: &str, entry_file: &Path) -> io::Result<Debugger> {
+        let listener = TcpListener::bind(listen_address(address))?;
+        eprintln!("Debug adapter listening on {}", listener.local_addr()?);
+        let (stream, _) = listener.accept()?;
+        let reader = stream.try_clone()?;
+        let (sender, requests) = mpsc::channel();
+        std::thread::Builder::new().name("ruff-dap-reader".to_string()).spawn(move || {
+            let mut reader = BufReader::new(reader);
+            while let Ok(Some(message)) = read_message(&mut reader) {
+                if sender.send(message).is_err() {
+                    break;
+                }
+            }
+        })?;
+
+        let mut frontend = DapFrontend {
+            writer: stream,
+            requests,
+            seq: 0,
+            entry_file: entry_file.to_string_lossy().to_string(),
+            entry_path: entry_file.canonicalize().unwrap_or_else(|_| entry_file.to_path_buf()),
+            values: Vec::new(),
+        };
+        let mut breakpoints = Vec::new();
+        let mut stop_on_entry = false;
+        loop {
+            let Ok(request) = frontend.requests.recv() else {
+                return Err(io::Error::new(
+                    io::ErrorKind::ConnectionAborted,
+                    "debug client disconnected before configurationDone",
+                ));
+            };
+            match command(&request) {
+                "initialize" => {
+                    frontend.respond(
+                        &request,
+                        json!({
+                            "supportsConfigurationDoneRequest": true,
+                            "supportsEvaluateForHovers": true,
+                            "supportsTerminateRequest": true,
+                        }),
+                    );
+                    frontend.event("initialized", json!({}));
+                }
+                "launch" | "attach" => {
+                    stop_on_entry = request["arguments"]["stopOnEntry"].as_bool().unwrap_or(false);
+                    frontend.respond(&request, json!({}));
+                }
+                "setBreakpoints" => {
+                    let body = frontend.set_breakpoints(&request, &mut breakpoints);
+                    frontend.respond(&request, body);
+                }
+                "setExceptionBreakpoints" => frontend.respond(&request, json!({})),
+                "threads" => frontend.respond_threads(&request),
+                "configurationDone" => {
+                    frontend.respond(&request, json!({}));
+                    break;
+                }
+                "disconnect" | "terminate" => frontend.disconnect(&request),
+                other => frontend.fail(&request, &format!("Unsupported request '{}'", other)),
+            }
+        }
+
+        let mut debugger = Debugger::new(Box::new(frontend), stop_on_entry);
+        for breakpoint in breakpoints {
+            debugger.add_breakpoint(breakpoint);
+        }
+        Ok(debugger)
+    }
+
+    fn send(&mut self, mut message: Json) {
+        self.seq += 1;
+        message["seq"] = json!(self.seq);
+        // A client that went away is noticed by the reader thread
+        let _ = write_message(&mut self.writer, &message);
+    }
+
+    fn respond(&mut self, request: &Json, body: Json) {
+        self.send(json!({
+            "type": "response",
+            "request_seq": request["seq"],
+            "success": true,
+            "command": request["command"],
+            "body": body,
+        }));
+    }
+
+    fn fail(&mut self, request: &Json, message: &str) {
+        self.send(json!({
+            "type": "response",
+            "request_seq": request["seq"],
+            "success": false,
+            "command": request["command"],
+            "message": message,
+        }));
+    }
+
+    fn event(&mut self, event: &str, body: Json) {
+        self.send(json!({ "type": "event", "event": event, "body": body }));
+    }
+
+    fn respond_threads(&mut self, request: &Json) {
+        self.respond(request, json!({ "threads": [{ "id": THREAD_ID, "name": "main" }] }));
+    }
+
+    /// The client ends the session, which also ends the program it launched
+    fn disconnect(&mut self, request: &Json) -> ! {
+        self.respond(request, json!({}));
+        std::process::exit(0);
+    }
+
+    /// `setBreakpoints` replaces every breakpoint of one source file
+    fn set_breakpoints(&mut self, request: &Json, breakpoints: &mut Vec<Breakpoint>) -> Json {
+        let arguments = &request["arguments"];
+        let requested: Vec<u64> = match arguments["breakpoints"].as_array() {
+            Some(requested) => requested.iter().filter_map(|bp| bp["line"].as_u64()).collect(),
+            None => arguments["lines"]
+                .as_array()
+                .map(|lines| lines.iter().filter_map(Json::as_u64).collect())
+                .unwrap_or_default(),
+        };
+        let lines: Vec<usize> = requested.into_iter().map(|line| line as usize).collect();
+
+        let path = Path::new(arguments["source"]["path"].as_str().unwrap_or_default());
+        let in_entry_script =
+            path.canonicalize().map_or(path == self.entry_path, |path| path == self.entry_path);
+        if in_entry_script {
+            *breakpoints = lines
+                .iter()
+                .map(|line| Breakpoint { file: Some(self.entry_file.clone()), line: *line })
+                .collect();
+        }
+
+        let reported: Vec<Json> = lines
+            .iter()
+            .map(|line| {
+                if in_entry_script {
+                    json!({ "verified": true, "line": line })
+                } else {
+                    json!({
+                        "verified": false,
+                        "line": line,
+                        "message": "Breakpoints are only supported in the entry script",
+                    })
+                }
+            })
+            .collect();
+        json!({ "breakpoints": reported })
+    }
+
+    fn stack_trace(&self, frame: &PausedFrame<'_>) -> Json {
+        let source = json!({
+            "name": self.entry_path.file_name().map(|name| name.to_string_lossy().to_string()),
+            "path": self.entry_path.to_string_lossy(),
+        });
+        // Innermost frame first; frame ids are depths below the paused statement
+        let frames: Vec<Json> = frame
+            .stack_trace()
+            .iter()
+            .rev()
+            .enumerate()
+            .map(|(id, stack_frame)| {
+                let (line, column) = stack_frame.position.unwrap_or((0, 0));
+                json!({
+                    "id": id,
+                    "name": stack_frame.function,
+                    "source": source,
+                    "line": line,
+                    "column": column,
+                })
+            })
+            .collect();
+        json!({ "totalFrames": frames.len(), "stackFrames": frames })
+    }
+
+    /// Only the paused frame's scope is live in the interpreter, so callers get globals only
+    fn scopes(&self, frame: &PausedFrame<'_>, frame_id: u64) -> Json {
+        let mut scopes = Vec::new();
+        if frame_id == 0 && !frame.locals().is_empty() {
+            let locals = json!({ "name": "Locals", "variablesReference": LOCALS_REFERENCE, "expensive": false });
+            scopes.push(locals);
+        }
+        scopes.push(json!({
+            "name": "Globals",
+            "variablesReference": GLOBALS_REFERENCE,
+            "expensive": false,
+        }));
+        json!({ "scopes": scopes })
+    }
+
+    fn variables(&mut self, frame: &mut PausedFrame<'_>, reference: i64) -> Json {
+        let bindings = match reference {
+            LOCALS_REFERENCE => frame.locals(),
+            GLOBALS_REFERENCE => frame.globals(),
+            reference => usize::try_from(reference - FIRST_VALUE_REFERENCE)
+                .ok()
+                .and_then(|index| self.values.get(index))
+                .map(children)
+                .unwrap_or_default(),
+        };
+        let variables: Vec<Json> = bindings
+            .iter()
+            .map(|(name, value)| {
+                json!({
+                    "name": name,
+                    "value": render(frame, value),
+                    "type": PausedFrame::type_name(value),
+                    "variablesReference": self.reference_for(value),
+                })
+            })
+            .collect();
+        json!({ "variables": variables })
+    }
+
+    /// Hands out a `variablesReference` for values with children, 0 otherwise
+    fn reference_for(&mut self, value: &Value) -> i64 {
+        if children(value).is_empty() {
+            return 0;
+        }
+        self.values.push(value.clone());
+        FIRST_VALUE_REFERENCE + self.values.len() as i64 - 1
+    }
+}
+
+impl DebugFrontend for DapFrontend {
+    fn poll(&mut self, state: &mut DebugState) {
+        loop {
+            let request = match self.requests.try_recv() {
+                Ok(request) => request,
+                Err(TryRecvError::Empty) => return,
+                Err(TryRecvError::Disconnected) => {
+                    state.detach();
+                    return;
+                }
+            };
+            match command(&request) {
+                "pause" => {
+                    state.request_pause();
+                    self.respond(&request, json!({}));
+                }
+                "setBreakpoints" => {
+                    let body = self.set_breakpoints(&request, &mut state.breakpoints);
+                    self.respond(&request, body);
+                }
+                "setExceptionBreakpoints" => self.respond(&request, json!({})),
+                "threads" => self.respond_threads(&request),
+                "disconnect" | "terminate" => self.disconnect(&request),
+                _ => self.fail(&request, "Only available while the program is paused"),
+            }
+        }
+    }
+
+    fn pause(&mut self, frame: &mut PausedFrame<'_>) -> Resume {
+        self.values.clear();
+        let reason = match frame.reason() {
+            PauseReason::Entry => "entry",
+            PauseReason::Breakpoint => "breakpoint",
+            PauseReason::Step => "step",
+            PauseReason::Pause => "pause",
+        };
+        self.event(
+            "stopped",
+            json!({ "reason": reason, "threadId": THREAD_ID, "allThreadsStopped": true }),
+        );
+
+        loop {
+            let Ok(request) = self.requests.recv() else {
+                return Resume::Detach;
+            };
+            let resume = match command(&request) {
+                "continue" => Some(Resume::Continue),
+                "next" => Some(Resume::Next),
+                "stepIn" => Some(Resume::Step),
+                "stepOut" => Some(Resume::Finish),
+                _ => None,
+            };
+            if let Some(resume) = resume {
+                self.respond(&request, json!({ "allThreadsContinued": true }));
+                return resume;
+            }
+
+            match command(&request) {
+                "threads" => self.respond_threads(&request),
+                "stackTrace" => {
+                    let body = self.stack_trace(frame);
+                    self.respond(&request, body);
+                }
+                "scopes" => {
+                    let frame_id = request["arguments"]["frameId"].as_u64().unwrap_or(0);
+                    let body = self.scopes(frame, frame_id);
+                    self.respond(&request, body);
+                }
+                "variables" => {
+                    let reference =
+                        request["arguments"]["variablesReference"].as_i64().unwrap_or(0);
+                    let body = self.variables(frame, reference);
+                    self.respond(&request, body);
+                }
+                "evaluate" => {
+                    let expression = request["arguments"]["expression"].as_str().unwrap_or("");
+                    match frame.evaluate(expression) {
+                        Ok(value) => {
+                            let body = json!({
+                                "result": render(frame, &value),
+                                "type": PausedFrame::type_name(&value),
+                                "variablesReference": self.reference_for(&value),
+                            });
+                            self.respond(&request, body);
+                        }
+                        Err(message) => self.fail(&request, &message),
+                    }
+                }
+                "setBreakpoints" => {
+                    let body = self.set_breakpoints(&request, &mut frame.state.breakpoints);
+                    self.respond(&request, body);
+                }
+                "pause" | "setExceptionBreakpoints" => self.respond(&request, json!({})),
+                "disconnect" | "terminate" => self.disconnect(&request),
+                other => self.fail(&request, &format!("Unsupported request '{}'", other)),
+            }
+        }
+    }
+
+    fn finished(&mut self, exit_code: i32, error: Option<&str>) {
+        if let Some(error) = error {
+            let output = format!("Error: {}\n", error);
+            self.event("output", json!({ "category": "stderr", "output": output }));
+        }
+        self.event("exited", json!({ "exitCode": exit_code }));
+        self.event("terminated", json!({}));
+
+        // Clients answer `terminated` with `disconnect`; give them a moment to get a reply
+        while let Ok(request) = self.requests.recv_timeout(Duration::from_secs(1)) {
+            if matches!(command(&request), "disconnect" | "terminate") {
+                self.respond(&request, json!({}));
+                return;
+            }
+            self.fail(&request, "The program has ended");
+        }
+    }
+}
+
+fn command(request: &Json) -> &str {
+    request["command"].as_str().unwrap_or("")
+}
+
+/// `:4711` and `4711` listen on localhost only
+fn listen_address(address: &str) -> String {
+    let port = address.strip_prefix(':').unwrap_or(address);
+    if port.chars().all(|c| c.is_ascii_digit()) {
+        format!("127.0.0.1:{}", port)
+    } else {
+        address.to_string()
+    }
+}
+
+/// Strings are quoted so they read apart from numbers and identifiers
+fn render(frame: &mut PausedFrame<'_>, value: &Value) -> String {
+    match value {
+        Value::Str(text) => format!("{:?}", text.as_str()),
+        value => frame.display(value),
+    }
+}
+
+fn children(value: &Value) -> Vec<(String, Value)> {
+    let mut children: Vec<(String, Value)> = match value {
+        Value::Array(items) => {
+            return items
+                .iter()
+                .enumerate()
+                .map(|(i, item)| (i.to_string(), item.clone()))
+                .collect()
+        }
+        Value::Dict(map) => {
+            map.iter().map(|(key, value)| (key.to_string(), value.clone())).collect()
+        }
+        Value::FixedDict { keys, values } => {
+            keys.iter().zip(values).map(|(key, value)| (key.to_string(), value.clone())).collect()
+        }
+        Value::Struct { fields, .. } => {
+            fields.iter().map(|(name, value)| (name.clone(), value.clone())).collect()
+        }
+        _ => Vec::new(),
+    };
+    children.sort_by(|left, right| left.0.cmp(&right.0));
+    children
+}
+
+/// Reads one `Content-Length`-framed message; `None` once the client closes the stream
+fn read_message(reader: &mut impl BufRead) -> io::Result<Option<Json>> {
+    let mut content_length = None;
+    loop {
+        let mut header = String::new();
+        if reader.read_line(&mut header)? == 0 {
+            return Ok(None);
+        }
+        let header = header.trim_end();
+        if header.is_empty() {
+            if content_length.is_some() {
+                break;
+            }
+            continue;
+        }
+        if let Some(length) = header.strip_prefix("Content-Length:") {
+            content_length = length.trim().parse::<usize>().ok();
+        }
+    }
+
+    let mut body = vec![0; content_length.unwrap_or(0)];
+    reader.read_exact(&mut body)?;
+    serde_json::from_slice(&body)
+        .map(Some)
+        .map_err(|error| io::Error::new(io::ErrorKind::InvalidData, error))
+}
+
+fn write_message(writer: &mut impl Write, message: &Json) -> io::Result<()> {
+    let body = message.to_string();
+    write!(writer, "Content-Length: {}\r\n\r\n{}", body.len(), body)?;
+    writer.flush()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn messages_round_trip_through_content_length_framing() {
+        let mut framed = Vec::new();
+        write_message(&mut framed, &json!({ "seq": 1, "command": "threads" })).unwrap();
+        write_message(&mut framed, &json!({ "seq": 2, "command": "continue" })).unwrap();
+
+        let mut reader = io::Cursor::new(framed);
+        let first = read_message(&mut reader).unwrap().unwrap();
+        let second = read_message(&mut reader).unwrap().unwrap();
+        assert_eq!(command(&first), "threads");
+        assert_eq!(second["seq"].as_u64(), Some(2));
+        assert!(read_message(&mut reader).unwrap().is_none());
+    }
+
+    #[test]
+    fn port_only_addresses_listen_on_localhost() {
+        assert_eq!(listen_address(":4711"), "127.0.0.1:4711");
+        assert_eq!(listen_address("4711"), "127.0.0.1:4711");
+        assert_eq!(listen_address("0.0.0.0:4711"), "0.0.0.0:4711");
+    }
+}
@@ -0,0 +1,401 @@
+// Step debugger for the tree-walking interpreter
+//
+// The interpreter offers the debugger every statement that carries a source position
+// (programs parsed `with_source_positions`). When a breakpoint or the current step mode
+// asks for a pause, control passes to a frontend until it decides how to resume. The
+// console frontend reads commands from stdin (`ruff debug`); the DAP frontend speaks the
+// Debug Adapter Protocol to an editor (`ruff run --dap`).
+
+mod console;
+mod dap;
+
+use super::{Interpreter, Value};
+use crate::ast::Stmt;
+use crate::errors::StackFrame;
+use crate::{lexer, parser};
+use std::path::Path;
+
+/// A `FILE:LINE` breakpoint. Without a file it applies to the entry script.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Breakpoint {
+    pub file: Option<String>,
+    pub line: usize,
+}
+
+impl Breakpoint {
+    /// Parses `FILE:LINE` or a bare `LINE`
+    pub fn parse(spec: &str) -> Result<Self, String> {
+        let spec = spec.trim();
+        let (file, line) = match spec.rsplit_once(':') {
+            Some((file, line)) if !file.is_empty() => (Some(file.to_string()), line),
+            _ => (None, spec),
+        };
+        match line.parse::<usize>() {
+            Ok(line) if line > 0 => Ok(Self { file, line }),
+            _ => Err(format!("Invalid breakpoint '{}': expected FILE:LINE or LINE", spec)),
+        }
+    }
+
+    /// A breakpoint file matches when it names a trailing part of the source path, so
+    /// `main.ruff` matches `examples/main.ruff`.
+    fn matches(&self, source_file: Option<&str>, line: usize) -> bool {
+        if self.line != line {
+            return false;
+        }
+        match (&self.file, source_file) {
+            (None, _) => true,
+            (Some(file), Some(source_file)) => Path::new(source_file).ends_with(file),
+            (Some(_), None) => false,
+        }
+    }
+
+    fn describe(&self) -> String {
+        match &self.file {
+            Some(file) => format!("{}:{}", file, self.line),
+            None => self.line.to_string(),
+        }
+    }
+}
+
+/// Why execution stopped, as reported to the frontend
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum PauseReason {
+    Entry,
+    Breakpoint,
+    Step,
+    /// The frontend asked for a pause while the program was running
+    Pause,
+}
+
+/// How a frontend resumes a paused program
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub enum Resume {
+    /// Pause at the next statement anywhere
+    Step,
+    /// Pause at the next statement in this frame or its callers
+    Next,
+    /// Pause once the current function returns
+    Finish,
+    /// Pause only at breakpoints
+    Continue,
+    /// Stop debugging and run the program to completion
+    Detach,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq)]
+enum StepMode {
+    Continue,
+    Entry,
+    Step,
+    /// Pause at the next statement at most `depth` frames deep
+    Next {
+        depth: usize,
+    },
+    /// Pause at the next statement fewer than `depth` frames deep
+    Finish {
+        depth: usize,
+    },
+}
+
+/// Breakpoints and stepping state shared between the debugger and its frontend
+pub struct DebugState {
+    pub breakpoints: Vec<Breakpoint>,
+    mode: StepMode,
+    pause_requested: bool,
+    detached: bool,
+}
+
+impl DebugState {
+    /// Pauses before the next positioned statement
+    pub fn request_pause(&mut self) {
+        self.pause_requested = true;
+    }
+
+    /// Drops all breakpoints and never pauses again
+    pub fn detach(&mut self) {
+        self.detached = true;
+        self.breakpoints.clear();
+    }
+
+    fn pause_reason(
+        &self,
+        source_file: Option<&str>,
+        line: usize,
+        depth: usize,
+    ) -> Option<PauseReason> {
+        if self.detached {
+            return None;
+        }
+        if self.breakpoints.iter().any(|breakpoint| breakpoint.matches(source_file, line)) {
+            return Some(PauseReason::Breakpoint);
+        }
+        if self.pause_requested {
+            return Some(PauseReason::Pause);
+        }
+        let stepped = match self.mode {
+            StepMode::Continue => false,
+            StepMode::Entry => return Some(PauseReason::Entry),
+            StepMode::Step => true,
+            StepMode::Next { depth: limit } => depth <= limit,
+            StepMode::Finish { depth: limit } => depth < limit,
+        };
+        stepped.then_some(PauseReason::Step)
+    }
+}
+
+/// Debugger UI: decides how to resume each pause
+pub trait DebugFrontend {
+    /// Called before every positioned statement, so requests that arrive while the
+    /// program runs (new breakpoints, pause) take effect promptly
+    fn poll(&mut self, _state: &mut DebugState) {}
+
+    fn pause(&mut self, frame: &mut PausedFrame<'_>) -> Resume;
+
+    /// The program ended with `exit_code`; `error` is the uncaught runtime error, if any
+    fn finished(&mut self, _exit_code: i32, _error: Option<&str>) {}
+}
+
+/// Drives a frontend from the interpreter's statement checkpoints
+pub struct Debugger {
+    state: DebugState,
+    frontend: Box<dyn DebugFrontend>,
+}
+
+impl Debugger {
+    pub fn new(frontend: Box<dyn DebugFrontend>, stop_on_entry: bool) -> Self {
+        let mode = if stop_on_entry { StepMode::Entry } else { StepMode::Continue };
+        Self {
+            state: DebugState {
+                breakpoints: Vec::new(),
+                mode,
+                pause_requested: false,
+                detached: false,
+            },
+            frontend,
+        }
+    }
+
+    /// Console debugger reading commands from stdin; pauses before the first statement
+    pub fn console() -> Self {
+        Self::new(Box::new(console::ConsoleFrontend::stdio()), true)
+    }
+
+    /// Listens on `address` for one Debug Adapter Protocol client and completes its
+    /// configuration handshake. `entry_file` is the script being debugged.
+    pub fn serve_dap(address: &str, entry_file: &Path) -> std::io::Result<Self> {
+        dap::DapFrontend::accept(address, entry_file)
+    }
+
+    pub fn add_breakpoint(&mut self, breakpoint: Breakpoint) {
+        self.state.breakpoints.push(breakpoint);
+    }
+
+    /// Tells the frontend the program ended
+    pub fn finish(mut self, exit_code: i32, error: Option<&str>) {
+        self.frontend.finished(exit_code, error);
+    }
+
+    fn checkpoint(&mut self, interp: &mut Interpreter, position: (usize, usize)) {
+        self.frontend.poll(&mut self.state);
+        let depth = interp.active_frames.len();
+        let Some(reason) =
+            self.state.pause_reason(interp.source_file.as_deref(), position.0, depth)
+        else {
+            return;
+        };
+
+        self.state.pause_requested = false;
+        let mut frame = PausedFrame { interp, position, reason, state: &mut self.state };
+        let resume = self.frontend.pause(&mut frame);
+        self.state.mode = match resume {
+            Resume::Step => StepMode::Step,
+            Resume::Next => StepMode::Next { depth },
+            Resume::Finish => StepMode::Finish { depth },
+            Resume::Continue => StepMode::Continue,
+            Resume::Detach => {
+                self.state.detach();
+                StepMode::Continue
+            }
+        };
+    }
+}
+
+/// The paused program, as seen by a frontend
+pub struct PausedFrame<'a> {
+    interp: &'a mut Interpreter,
+    position: (usize, usize),
+    reason: PauseReason,
+    pub state: &'a mut DebugState,
+}
+
+impl PausedFrame<'_> {
+    pub fn reason(&self) -> PauseReason {
+        self.reason
+    }
+
+    pub fn line(&self) -> usize {
+        self.position.0
+    }
+
+    pub fn file(&self) -> &str {
+        self.interp.source_file.as_deref().unwrap_or("<script>")
+    }
+
+    pub fn source_lines(&self) -> &[String] {
+        &self.interp.source_lines
+    }
+
+    /// Name of the function the paused statement belongs to
+    pub fn function(&self) -> &str {
+        self.interp.active_frames.last().map_or("<main>", |frame| frame.name.as_str())
+    }
+
+    /// Call stack with `<main>` first and the paused statement last
+    pub fn stack_trace(&self) -> Vec<StackFrame> {
+        let trace = self.interp.capture_stack_trace(self.position);
+        if trace.is_empty() {
+            return vec![StackFrame::new("<main>", Some(self.position))];
+        }
+        trace
+    }
+
+    /// Bindings visible in the paused function's scopes, sorted by name; empty at
+    /// top level. Shadowed outer bindings are left out.
+    pub fn locals(&self) -> Vec<(String, Value)> {
+        let mut seen = std::collections::HashSet::new();
+        let mut locals: Vec<(String, Value)> = self
+            .interp
+            .env
+            .local_bindings()
+            .into_iter()
+            .rev()
+            .filter(|(name, _)| seen.insert(name.clone()))
+            .collect();
+        locals.sort_by(|left, right| left.0.cmp(&right.0));
+        locals
+    }
+
+    /// Top-level bindings, without the builtins, sorted by name
+    pub fn globals(&self) -> Vec<(String, Value)> {
+        let mut globals: Vec<(String, Value)> = self
+            .interp
+            .env
+            .globals
+            .iter()
+            .filter(|(_, value)| !matches!(value, Value::NativeFunction(_)))
+            .map(|(name, value)| (name.clone(), value.clone()))
+            .collect();
+        globals.sort_by(|left, right| left.0.cmp(&right.0));
+        globals
+    }
+
+    /// Evaluates `source` as an expression in the paused scope. The interpreter's
+    /// pending result and error bookkeeping are restored afterwards so the program
+    /// resumes as if nothing ran.
+    pub fn evaluate(&mut self, source: &str) -> Result<Value, String> {
+        let tokens = lexer::tokenize(source).map_err(|diagnostics| {
+            diagnostics.first().map_or("invalid expression".to_string(), |d| d.message.clone())
+        })?;
+        let parse_output = parser::Parser::new(tokens).parse_with_diagnostics();
+        if let Some(diagnostic) = parse_output.diagnostics.first() {
+            return Err(diagnostic.message.clone());
+        }
+        let expr = match parse_output.stmts.as_slice() {
+            [Stmt::ExprStmt(expr)] => expr,
+            _ => return Err("expected a single expression".to_string()),
+        };
+
+        let interp = &mut *self.interp;
+        let saved_return = interp.return_value.take();
+        let saved_error_position = interp.error_position.take();
+        let saved_error_trace = std::mem::take(&mut interp.error_trace);
+        let value = interp.eval_expr(expr);
+        let raised = interp.return_value.take();
+        interp.return_value = saved_return;
+        interp.error_position = saved_error_position;
+        interp.error_trace = saved_error_trace;
+
+        match raised.filter(Interpreter::is_error_value).unwrap_or(value) {
+            Value::Error(message) | Value::ErrorObject { message, .. } => Err(message),
+            value => Ok(value),
+        }
+    }
+
+    /// Renders `value` the way `print` would
+    pub fn display(&mut self, value: &Value) -> String {
+        self.interp.display_string(value)
+    }
+
+    pub fn type_name(value: &Value) -> &'static str {
+        Interpreter::value_type_name(value)
+    }
+}
+
+impl Interpreter {
+    /// Attaches a debugger that is consulted before every positioned statement
+    pub fn attach_debugger(&mut self, debugger: Debugger) {
+        self.debugger = Some(Box::new(debugger));
+    }
+
+    /// Removes the attached debugger, e.g. to `finish` it once the program ends
+    pub fn detach_debugger(&mut self) -> Option<Debugger> {
+        self.debugger.take().map(|debugger| *debugger)
+    }
+
+    pub(super) fn debugger_checkpoint(&mut self, stmt: &Stmt, position: (usize, usize)) {
+        // Function definitions are hoisted and evaluated out of source order
+        if matches!(stmt, Stmt::FuncDef { .. })
+            || matches!(stmt, Stmt::Export { stmt } if matches!(stmt.as_ref(), Stmt::FuncDef { .. }))
+        {
+            return;
+        }
+        // Detach while paused so evaluated expressions are not stepped into
+        let Some(mut debugger) = self.debugger.take() else {
+            return;
+        };
+        debugger.checkpoint(self, position);
+        self.debugger = Some(debugger);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn breakpoints_parse_with_and_without_a_file() {
+        assert_eq!(
+            Breakpoint::parse("src/main.ruff:12"),
+            Ok(Breakpoint { file: Some("src/main.ruff".to_string()), line: 12 })
+        );
+        assert_eq!(Breakpoint::parse("7"), Ok(Breakpoint { file: None, line: 7 }));
+        assert!(Breakpoint::parse("main.ruff:0").is_err());
+        assert!(Breakpoint::parse("main.ruff").is_err());
+    }
+
+    #[test]
+    fn breakpoint_files_match_trailing_path_components() {
+        let breakpoint = Breakpoint::parse("main.ruff:3").unwrap();
+        assert!(breakpoint.matches(Some("examples/main.ruff"), 3));
+        assert!(!breakpoint.matches(Some("examples/main.ruff"), 4));
+        assert!(!breakpoint.matches(Some("examples/domain.ruff"), 3));
+    }
+
+    #[test]
+    fn breakpoints_take_precedence_over_step_modes() {
+        let mut state = DebugState {
+            breakpoints: vec![Breakpoint { file: None, line: 4 }],
+            mode: StepMode::Next { depth: 0 },
+            pause_requested: false,
+            detached: false,
+        };
+        assert_eq!(state.pause_reason(None, 4, 1), Some(PauseReason::Breakpoint));
+        assert_eq!(state.pause_reason(None, 5, 1), None);
+        assert_eq!(state.pause_reason(None, 5, 0), Some(PauseReason::Step));
+
+        state.request_pause();
+        assert_eq!(state.pause_reason(None, 5, 1), Some(PauseReason::Pause));
+        state.detach();
+        assert_eq!(state.pause_reason(None, 4, 0), None);
+    }
+}
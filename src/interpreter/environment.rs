@@ -1,10 +1,12 @@
 // File: src/interpreter/environment.rs
 //
 // Lexical scoping environment for variable management in the Ruff interpreter.
-// Implements a stack of scopes where inner scopes shadow outer scopes.
+// Implements a stack of scopes where inner scopes shadow outer scopes. Each local
+// binding lives in its own cell, which closures share to capture by reference.
 
 use super::value::Value;
 use std::collections::HashMap;
+use std::sync::{Arc, Mutex, MutexGuard, Weak};
 
 #[derive(Clone, Copy, Debug, PartialEq, Eq)]
 pub enum BindingKind {
@@ -37,12 +39,69 @@ impl BindingKind {
     }
 }
 
+/// The storage of one local binding. A closure that captures the binding holds the
+/// same cell as the scope that declared it, so each sees the other's writes.
+type Cell = Arc<Mutex<Value>>;
+
+/// A binding a closure uses before its scope declares it. The declaration fills it in,
+/// so the closure finds the local even after the declaring call has returned.
+type LateBinding = Arc<Mutex<Option<(Cell, BindingKind)>>>;
+
+/// One function or block scope: bindings plus their mutability metadata.
+#[derive(Clone, Debug, Default)]
+struct Scope {
+    values: HashMap<String, Cell>,
+    kinds: HashMap<String, BindingKind>,
+    /// Names closures created here use before they are declared, see [`LateBinding`]
+    later: HashMap<String, LateBinding>,
+}
+
+/// A function or block scope shared by every environment that can see it.
+type SharedScope = Arc<Mutex<Scope>>;
+
+/// A named closure's binding for itself. Keeping the function in its own captured
+/// environment would make that environment own itself, so the function is rebuilt
+/// on lookup from a weak reference instead.
+#[derive(Clone, Debug)]
+struct OwnBinding {
+    name: String,
+    /// The function with no captured environment; `env` is filled in on lookup
+    function: Value,
+    env: Weak<Mutex<Environment>>,
+}
+
+impl OwnBinding {
+    fn function(&self) -> Option<Value> {
+        let env = self.env.upgrade()?;
+        Some(match &self.function {
+            Value::Function(params, body, _) => {
+                Value::Function(params.clone(), body.clone(), Some(env))
+            }
+            Value::AsyncFunction(params, body, _) => {
+                Value::AsyncFunction(params.clone(), body.clone(), Some(env))
+            }
+            other => other.clone(),
+        })
+    }
+}
+
 /// Variable storage using lexical scoping
 ///
-/// The Environment maintains a stack of scopes (Vec<HashMap>). When looking up
-/// a variable, we search from the innermost scope (end of Vec) outward. This
+/// The Environment maintains a global scope plus a stack of nested scopes. When
+/// looking up a variable, we search from the innermost scope outward. This
 /// implements proper lexical scoping with shadowing.
 ///
+/// Cloning an environment copies the global scope but shares the nested scopes by
+/// reference. A closure does not hold such a clone: that would keep the enclosing
+/// frame alive for as long as the closure, and the frame usually holds the closure,
+/// so neither would be freed. [`Environment::capture`] instead gives the closure the
+/// cells of the locals it uses, so it reads and writes the enclosing function's locals
+/// themselves, and sibling closures created in the same call see each other's updates.
+/// A local the closure uses but its scope declares later, such as a mutually recursive
+/// inner function, reaches the closure when the declaration runs. Anything else is
+/// found through weak references to the enclosing scopes, which only resolve while the
+/// enclosing call is running.
+///
 /// # Examples
 ///
 /// ```ignore
@@ -60,28 +119,169 @@ impl BindingKind {
 /// ```
 #[derive(Clone, Debug)]
 pub struct Environment {
-    pub scopes: Vec<HashMap<String, Value>>,
-    binding_kinds: Vec<HashMap<String, BindingKind>>,
+    /// Global bindings. The VM also addresses this map directly through raw pointers.
+    pub globals: HashMap<String, Value>,
+    global_kinds: HashMap<String, BindingKind>,
+    scopes: Vec<SharedScope>,
+    /// Scopes that enclosed a closure when it was created, outermost first. Held
+    /// weakly so a closure stored in one of them does not keep it alive.
+    enclosing: Vec<Weak<Mutex<Scope>>>,
+    /// Locals this closure uses that were declared after it was created
+    late: HashMap<String, LateBinding>,
+    own_binding: Option<Box<OwnBinding>>,
+    /// Bumped on every write to `globals`, so inline caches can tell a cached
+    /// global is still current without hashing its name again.
+    globals_version: u64,
+}
+
+fn lock_scope(scope: &SharedScope) -> MutexGuard<'_, Scope> {
+    scope.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+}
+
+fn lock_cell(cell: &Cell) -> MutexGuard<'_, Value> {
+    cell.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+}
+
+fn filled_late_binding(late: &LateBinding) -> Option<(Cell, BindingKind)> {
+    late.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).clone()
+}
+
+/// The cell and mutability of `name` in `scope`, if the scope declares it.
+fn scope_binding(scope: &SharedScope, name: &str) -> Option<(Cell, BindingKind)> {
+    let scope = lock_scope(scope);
+    let cell = scope.values.get(name)?.clone();
+    let kind = scope.kinds.get(name).copied().unwrap_or(BindingKind::Mutable);
+    Some((cell, kind))
+}
+
+fn scope_values(scope: &SharedScope) -> Vec<(String, Value)> {
+    let scope = lock_scope(scope);
+    scope.values.iter().map(|(name, cell)| (name.clone(), lock_cell(cell).clone())).collect()
 }
 
 impl Environment {
     /// Create a new environment with a single global scope
     pub fn new() -> Self {
-        Environment { scopes: vec![HashMap::new()], binding_kinds: vec![HashMap::new()] }
+        Environment {
+            globals: HashMap::new(),
+            global_kinds: HashMap::new(),
+            scopes: Vec::new(),
+            enclosing: Vec::new(),
+            late: HashMap::new(),
+            own_binding: None,
+            globals_version: 0,
+        }
+    }
+
+    /// Environment for a closure created here that reads the locals in `free_variables`.
+    ///
+    /// The closure gets the globals and one scope sharing the cells of those locals.
+    /// A name that is neither a local yet nor a global is registered with the current
+    /// scope, and the closure picks it up if that scope declares it later. Anything else
+    /// resolves at call time through the enclosing scopes, held weakly, or the globals.
+    /// When `own_name` is given, the closure is a named function and finds itself under
+    /// that name without its environment owning it.
+    pub fn capture(
+        &self,
+        free_variables: &[String],
+        own_name: Option<(&str, Value, Weak<Mutex<Environment>>)>,
+    ) -> Environment {
+        let own_binding = own_name.map(|(name, function, env)| {
+            Box::new(OwnBinding { name: name.to_string(), function, env })
+        });
+        if self.scopes.is_empty() {
+            return Environment { own_binding, ..self.clone() };
+        }
+
+        let mut captured = Scope::default();
+        let mut late = HashMap::new();
+        for name in free_variables {
+            if own_binding.as_ref().is_some_and(|own| &own.name == name) {
+                continue;
+            }
+            if let Some((cell, kind)) = self.find_binding(name) {
+                captured.values.insert(name.clone(), cell);
+                captured.kinds.insert(name.clone(), kind);
+            } else if let Some(function) = self.own_function(name) {
+                captured.values.insert(name.clone(), Arc::new(Mutex::new(function)));
+                captured.kinds.insert(name.clone(), BindingKind::Mutable);
+            } else if !self.globals.contains_key(name) {
+                late.insert(name.clone(), self.declare_later(name));
+            }
+        }
+
+        let mut enclosing = self.enclosing.clone();
+        enclosing.extend(self.scopes.iter().map(Arc::downgrade));
+        Environment {
+            globals: self.globals.clone(),
+            global_kinds: self.global_kinds.clone(),
+            scopes: vec![Arc::new(Mutex::new(captured))],
+            enclosing,
+            late,
+            own_binding,
+            globals_version: self.globals_version,
+        }
+    }
+
+    /// The late binding the innermost scope hands out for `name`, shared by every
+    /// closure created there before the declaration.
+    fn declare_later(&self, name: &str) -> LateBinding {
+        let scope = self.scopes.last().expect("capture with pushed scopes");
+        lock_scope(scope).later.entry(name.to_string()).or_default().clone()
+    }
+
+    /// Innermost local binding of `name`: pushed scopes first, then locals declared
+    /// after this closure was created, then enclosing scopes that are still alive.
+    fn find_binding(&self, name: &str) -> Option<(Cell, BindingKind)> {
+        self.scopes
+            .iter()
+            .rev()
+            .find_map(|scope| scope_binding(scope, name))
+            .or_else(|| self.late_binding(name))
+            .or_else(|| self.enclosing_binding(name))
+    }
+
+    fn late_binding(&self, name: &str) -> Option<(Cell, BindingKind)> {
+        filled_late_binding(self.late.get(name)?)
+    }
+
+    fn enclosing_binding(&self, name: &str) -> Option<(Cell, BindingKind)> {
+        self.enclosing.iter().rev().find_map(|scope| scope_binding(&scope.upgrade()?, name))
+    }
+
+    fn own_function(&self, name: &str) -> Option<Value> {
+        self.own_binding.as_ref().filter(|own| own.name == name)?.function()
+    }
+
+    /// Enclosing scopes that are still alive followed by the pushed scopes, outermost first.
+    fn visible_scopes(&self) -> Vec<SharedScope> {
+        self.enclosing.iter().filter_map(Weak::upgrade).chain(self.scopes.iter().cloned()).collect()
+    }
+
+    /// Version of the global scope for inline caches, or `None` while nested scopes
+    /// are pushed, since those can shadow a global without touching the counter.
+    pub fn globals_version(&self) -> Option<u64> {
+        self.scopes.is_empty().then_some(self.globals_version)
+    }
+
+    /// Invalidate cached globals after `globals` was written through a raw pointer.
+    pub fn mark_globals_changed(&mut self) {
+        self.globals_version = self.globals_version.wrapping_add(1);
+    }
+
+    /// Number of scopes, counting the global scope
+    pub fn depth(&self) -> usize {
+        self.scopes.len() + 1
     }
 
     /// Push a new scope onto the stack (e.g., entering a function)
     pub fn push_scope(&mut self) {
-        self.scopes.push(HashMap::new());
-        self.binding_kinds.push(HashMap::new());
+        self.scopes.push(Arc::new(Mutex::new(Scope::default())));
     }
 
     /// Pop the innermost scope from the stack (e.g., exiting a function)
     pub fn pop_scope(&mut self) {
-        if self.scopes.len() > 1 {
-            self.scopes.pop();
-            self.binding_kinds.pop();
-        }
+        self.scopes.pop();
     }
 
     /// Get a variable from the environment, searching from inner to outer scopes
@@ -89,11 +289,42 @@ impl Environment {
     pub fn get(&self, name: &str) -> Option<Value> {
         // Search from innermost to outermost scope
         for scope in self.scopes.iter().rev() {
-            if let Some(value) = scope.get(name) {
-                return Some(value.clone());
+            if let Some(cell) = lock_scope(scope).values.get(name) {
+                return Some(lock_cell(cell).clone());
+            }
+        }
+        if let Some(function) = self.own_function(name) {
+            return Some(function);
+        }
+        if let Some((cell, _)) = self.late_binding(name).or_else(|| self.enclosing_binding(name)) {
+            return Some(lock_cell(&cell).clone());
+        }
+        self.globals.get(name).cloned()
+    }
+
+    /// Every visible binding, outermost scope first, so inner bindings come last
+    pub fn bindings(&self) -> Vec<(String, Value)> {
+        let mut bindings: Vec<(String, Value)> =
+            self.globals.iter().map(|(name, value)| (name.clone(), value.clone())).collect();
+        bindings.extend(self.local_bindings());
+        bindings
+    }
+
+    /// Bindings of the pushed scopes only, outermost first, leaving out globals
+    pub fn local_bindings(&self) -> Vec<(String, Value)> {
+        let mut bindings = Vec::new();
+        if let Some(own) = &self.own_binding {
+            bindings.extend(own.function().map(|function| (own.name.clone(), function)));
+        }
+        for (name, late) in &self.late {
+            if let Some((cell, _)) = filled_late_binding(late) {
+                bindings.push((name.clone(), lock_cell(&cell).clone()));
             }
         }
-        None
+        for scope in self.visible_scopes() {
+            bindings.extend(scope_values(&scope));
+        }
+        bindings
     }
 
     /// Define a new variable in the current (innermost) scope
@@ -102,11 +333,23 @@ impl Environment {
     }
 
     pub fn define_with_kind(&mut self, name: String, value: Value, kind: BindingKind) {
-        if let Some(scope) = self.scopes.last_mut() {
-            scope.insert(name.clone(), value);
-        }
-        if let Some(kinds) = self.binding_kinds.last_mut() {
-            kinds.insert(name, kind);
+        match self.scopes.last() {
+            Some(scope) => {
+                // A fresh cell: closures created for an earlier declaration keep theirs
+                let mut scope = lock_scope(scope);
+                let cell = Arc::new(Mutex::new(value));
+                if let Some(late) = scope.later.remove(&name) {
+                    *late.lock().unwrap_or_else(|poisoned| poisoned.into_inner()) =
+                        Some((cell.clone(), kind));
+                }
+                scope.values.insert(name.clone(), cell);
+                scope.kinds.insert(name, kind);
+            }
+            None => {
+                self.mark_globals_changed();
+                self.globals.insert(name.clone(), value);
+                self.global_kinds.insert(name, kind);
+            }
         }
     }
 
@@ -125,40 +368,60 @@ impl Environment {
     }
 
     fn current_scope_contains(&self, name: &str) -> bool {
-        self.scopes.last().map(|scope| scope.contains_key(name)).unwrap_or(false)
+        match self.scopes.last() {
+            Some(scope) => lock_scope(scope).values.contains_key(name),
+            None => self.globals.contains_key(name),
+        }
+    }
+
+    /// Run `f` against the innermost binding of `name` and its mutability.
+    /// Returns `None` when no scope defines `name`.
+    fn with_binding<R>(
+        &mut self,
+        name: &str,
+        f: impl FnOnce(&mut Value, BindingKind) -> R,
+    ) -> Option<R> {
+        if let Some((cell, kind)) = self.find_binding(name) {
+            return Some(f(&mut lock_cell(&cell), kind));
+        }
+
+        let kind = self.global_kinds.get(name).copied().unwrap_or(BindingKind::Mutable);
+        let value = self.globals.get_mut(name)?;
+        self.globals_version = self.globals_version.wrapping_add(1);
+        Some(f(value, kind))
     }
 
     /// Set an existing variable, searching from inner to outer scopes
     /// If not found, creates it in the current scope
     pub fn set(&mut self, name: String, value: Value) {
         // Try to find and update existing variable
-        for scope in self.scopes.iter_mut().rev() {
-            if scope.contains_key(&name) {
-                scope.insert(name, value);
-                return;
-            }
+        let mut pending = Some(value);
+        if self.with_binding(&name, |slot, _| *slot = pending.take().unwrap()).is_some() {
+            return;
         }
         // If not found, create in current scope
-        self.define(name, value);
+        if let Some(value) = pending {
+            self.define(name, value);
+        }
     }
 
     pub fn assign_checked(&mut self, name: String, value: Value) -> Result<(), String> {
-        for (scope_index, scope) in self.scopes.iter_mut().enumerate().rev() {
-            if scope.contains_key(&name) {
-                let kind = self.binding_kinds[scope_index]
-                    .get(&name)
-                    .copied()
-                    .unwrap_or(BindingKind::Mutable);
-                if !kind.allows_mutation() {
-                    return Err(kind.reassignment_error(&name));
-                }
-                scope.insert(name, value);
-                return Ok(());
+        let mut pending = Some(value);
+        let assigned = self.with_binding(&name, |slot, kind| {
+            if !kind.allows_mutation() {
+                return Err(kind.reassignment_error(&name));
             }
+            *slot = pending.take().unwrap();
+            Ok(())
+        });
+        if let Some(result) = assigned {
+            return result;
         }
 
         // Preserve existing Ruff behavior: assignment can create a new mutable binding.
-        self.define(name, value);
+        if let Some(value) = pending {
+            self.define(name, value);
+        }
         Ok(())
     }
 
@@ -171,50 +434,38 @@ impl Environment {
     where
         F: FnOnce(&mut Value),
     {
-        // Find the scope containing this variable
-        for scope in self.scopes.iter_mut().rev() {
-            if let Some(value) = scope.get_mut(name) {
-                f(value);
-                return true;
-            }
-        }
-        false
+        self.with_binding(name, |value, _| f(value)).is_some()
     }
 
     pub fn mutate_checked<F>(&mut self, name: &str, f: F) -> Result<(), String>
     where
         F: FnOnce(&mut Value),
     {
-        for (scope_index, scope) in self.scopes.iter_mut().enumerate().rev() {
-            if let Some(value) = scope.get_mut(name) {
-                let kind = self.binding_kinds[scope_index]
-                    .get(name)
-                    .copied()
-                    .unwrap_or(BindingKind::Mutable);
-                if !kind.allows_mutation() {
-                    return Err(kind.mutation_error(name));
-                }
-
-                f(value);
-                return Ok(());
+        self.with_binding(name, |value, kind| {
+            if !kind.allows_mutation() {
+                return Err(kind.mutation_error(name));
             }
-        }
 
-        Err(format!("Undefined variable: {}", name))
+            f(value);
+            Ok(())
+        })
+        .unwrap_or_else(|| Err(format!("Undefined variable: {}", name)))
     }
 
     pub fn ensure_mutable_for_mutation(&self, name: &str) -> Result<(), String> {
-        for (scope_index, scope) in self.scopes.iter().enumerate().rev() {
-            if scope.contains_key(name) {
-                let kind = self.binding_kinds[scope_index]
-                    .get(name)
-                    .copied()
-                    .unwrap_or(BindingKind::Mutable);
-                if kind.allows_mutation() {
-                    return Ok(());
-                }
-                return Err(kind.mutation_error(name));
+        if let Some((_, kind)) = self.find_binding(name) {
+            if kind.allows_mutation() {
+                return Ok(());
+            }
+            return Err(kind.mutation_error(name));
+        }
+
+        if self.globals.contains_key(name) {
+            let kind = self.global_kinds.get(name).copied().unwrap_or(BindingKind::Mutable);
+            if kind.allows_mutation() {
+                return Ok(());
             }
+            return Err(kind.mutation_error(name));
         }
 
         Err(format!("Undefined variable: {}", name))
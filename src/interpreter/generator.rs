@@ -0,0 +1,586 @@
+// File: src/interpreter/generator.rs
+//
+// Resumable execution of generator bodies for the tree-walking interpreter.
+//
+// A generator runs its body until a `yield` statement and suspends there. Statements
+// that contain no yield run through `eval_stmt` as usual. The statements enclosing a
+// yield (blocks, `if`, `match`, loops, and `try`) run here instead, and each keeps a
+// frame recording how far it got, so the next `next()` resumes inside loops and nested
+// blocks. Scopes those statements opened stay open in the generator's environment while
+// it is suspended.
+
+use super::control_flow::ControlFlow;
+use super::value::SequenceCursor;
+use super::{Environment, Interpreter, LeakyFunctionBody, Sequence, Value};
+use crate::ast::{Expr, Stmt};
+use std::sync::{Arc, Mutex};
+
+/// Resume state shared by every copy of one generator value.
+#[derive(Default)]
+pub struct GeneratorResume {
+    /// One frame per statement list the suspended body is inside, outermost first.
+    frames: Vec<GeneratorFrame>,
+    pub(crate) is_exhausted: bool,
+    /// Set while the body runs, so a generator that resumes itself fails instead of
+    /// starting over.
+    running: bool,
+}
+
+/// Progress through one statement list of a generator body.
+struct GeneratorFrame {
+    /// Index of the statement being run.
+    pc: usize,
+    /// Position of the latest `SourcePos` marker before `pc`.
+    position: Option<(usize, usize)>,
+    /// State of the statement at `pc` while a yield inside it is pending; its own
+    /// statement list runs in the next frame.
+    active: Option<ActiveStmt>,
+}
+
+enum ActiveStmt {
+    /// Suspended at this `yield`; resuming moves past it.
+    Yield,
+    Block,
+    If {
+        then_branch: bool,
+    },
+    /// The selected arm, or `None` for the default arm.
+    Match {
+        arm: Option<usize>,
+    },
+    Loop {
+        label: Option<String>,
+    },
+    For {
+        label: Option<String>,
+        cursor: SequenceCursor,
+    },
+    Try {
+        stage: TryStage,
+        pending_return: Option<Value>,
+        pending_control_flow: ControlFlow,
+    },
+}
+
+#[derive(Clone, Copy)]
+enum TryStage {
+    Try,
+    Except,
+    Finally,
+}
+
+enum Flow {
+    Finished,
+    Yielded(Value),
+}
+
+/// Whether `stmt` has a `yield` statement that is not inside a nested function.
+fn contains_yield(stmt: &Stmt) -> bool {
+    let any = |body: &[Stmt]| body.iter().any(contains_yield);
+    match stmt {
+        Stmt::ExprStmt(Expr::Yield(_)) => true,
+        Stmt::Block(body)
+        | Stmt::Loop { body, .. }
+        | Stmt::While { body, .. }
+        | Stmt::For { body, .. } => any(body),
+        Stmt::If { then_branch, else_branch, .. } => {
+            any(then_branch) || else_branch.as_deref().is_some_and(any)
+        }
+        Stmt::Match { cases, default, .. } => {
+            cases.iter().any(|case| any(&case.body)) || default.as_deref().is_some_and(any)
+        }
+        Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+            any(try_block) || any(except_block) || finally_block.as_deref().is_some_and(any)
+        }
+        Stmt::LabeledLoop { loop_stmt, .. } => contains_yield(loop_stmt),
+        _ => false,
+    }
+}
+
+impl Interpreter {
+    /// Bind `args` to `params` in a new scope over the current environment and return
+    /// a generator that has not started running yet.
+    pub(super) fn start_generator(
+        &self,
+        params: &[String],
+        body: &LeakyFunctionBody,
+        args: &[Value],
+    ) -> Value {
+        let mut gen_env: Environment = self.env.clone();
+        gen_env.push_scope();
+        for (param, arg) in params.iter().zip(args) {
+            gen_env.define(param.clone(), arg.clone());
+        }
+
+        Value::Generator {
+            params: params.to_vec(),
+            body: body.clone(),
+            env: Arc::new(Mutex::new(gen_env)),
+            state: Arc::new(Mutex::new(GeneratorResume::default())),
+        }
+    }
+
+    /// Run a generator to its next `yield`.
+    ///
+    /// Returns `Some(value)` for a yielded value and `None` once the body has finished.
+    /// An error raised by the body is returned as-is, and the generator is exhausted
+    /// afterwards.
+    pub(super) fn generator_next(&mut self, generator: &mut Value) -> Value {
+        let Value::Generator { body, env, state, .. } = generator else {
+            return Value::Error("generator_next() can only be called on generators".to_string());
+        };
+
+        let mut frames = {
+            let mut state = state.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+            if state.is_exhausted {
+                return Value::Option { is_some: false, value: Box::new(Value::Null) };
+            }
+            if state.running {
+                return Value::Error("generator is already running".to_string());
+            }
+            state.running = true;
+            std::mem::take(&mut state.frames)
+        };
+
+        let generator_env = env.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).clone();
+        let saved_env = std::mem::replace(&mut self.env, generator_env);
+        let saved_return_value = self.return_value.take();
+        let saved_control_flow = std::mem::replace(&mut self.control_flow, ControlFlow::None);
+
+        // Loops the body is suspended inside are live again for break/continue
+        let loop_labels: Vec<Option<String>> = frames
+            .iter()
+            .filter_map(|frame| match &frame.active {
+                Some(ActiveStmt::Loop { label }) | Some(ActiveStmt::For { label, .. }) => {
+                    Some(label.clone())
+                }
+                _ => None,
+            })
+            .collect();
+        let stmts = body.get();
+        let flow = self
+            .with_function_context("<generator>", |interp| {
+                interp.loop_labels = loop_labels;
+                interp.run_generator_block(&mut frames, &stmts, 0)
+            })
+            .unwrap_or_else(|error| {
+                self.return_value = Some(error);
+                Flow::Finished
+            });
+
+        *env.lock().unwrap_or_else(|poisoned| poisoned.into_inner()) =
+            std::mem::replace(&mut self.env, saved_env);
+        let outcome = std::mem::replace(&mut self.return_value, saved_return_value);
+        self.control_flow = saved_control_flow;
+
+        let mut state = state.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+        state.running = false;
+        match flow {
+            Flow::Yielded(value) => {
+                state.frames = frames;
+                Value::Option { is_some: true, value: Box::new(value) }
+            }
+            Flow::Finished => {
+                state.is_exhausted = true;
+                match outcome {
+                    Some(error) if Self::is_error_value(&error) => error,
+                    _ => Value::Option { is_some: false, value: Box::new(Value::Null) },
+                }
+            }
+        }
+    }
+
+    /// Run (or resume) the statement list at nesting level `depth` of a generator body.
+    fn run_generator_block(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        stmts: &[Stmt],
+        depth: usize,
+    ) -> Flow {
+        if frames.len() == depth {
+            frames.push(GeneratorFrame { pc: 0, position: None, active: None });
+        }
+
+        while let Some(stmt) = stmts.get(frames[depth].pc) {
+            let frame = &mut frames[depth];
+            if let Stmt::SourcePos { line, column } = stmt {
+                frame.position = Some((*line, *column));
+                frame.pc += 1;
+                continue;
+            }
+
+            if frame.active.is_none() && !contains_yield(stmt) {
+                let position = frame.position;
+                self.eval_positioned_stmt(stmt, position);
+            } else {
+                if let Some(budget) = &self.execution_budget {
+                    if let Err(message) = budget.tick() {
+                        self.return_value = Some(Value::Error(message));
+                        break;
+                    }
+                }
+                if frame.position.is_some() {
+                    self.current_position = frame.position;
+                }
+                if let Flow::Yielded(value) = self.resume_generator_stmt(frames, stmt, depth) {
+                    return Flow::Yielded(value);
+                }
+                frames[depth].active = None;
+            }
+
+            frames[depth].pc += 1;
+            if self.return_value.is_some() || self.control_flow != ControlFlow::None {
+                break;
+            }
+        }
+
+        frames.truncate(depth);
+        Flow::Finished
+    }
+
+    /// Run (or resume) a statement that contains a `yield`, mirroring `eval_stmt`.
+    fn resume_generator_stmt(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        stmt: &Stmt,
+        depth: usize,
+    ) -> Flow {
+        let resuming = frames[depth].active.is_some();
+        match stmt {
+            Stmt::ExprStmt(Expr::Yield(value)) => {
+                if resuming {
+                    return Flow::Finished;
+                }
+                let value = value.as_ref().map_or(Value::Null, |value| self.eval_expr(value));
+                if self.set_return_if_error(&value) {
+                    return Flow::Finished;
+                }
+                frames[depth].active = Some(ActiveStmt::Yield);
+                Flow::Yielded(value)
+            }
+            Stmt::Block(body) => {
+                if !resuming {
+                    self.env.push_scope();
+                    frames[depth].active = Some(ActiveStmt::Block);
+                }
+                self.run_generator_scope(frames, body, depth)
+            }
+            Stmt::If { condition, then_branch, else_branch } => {
+                let then_taken = match &frames[depth].active {
+                    Some(ActiveStmt::If { then_branch }) => *then_branch,
+                    _ => {
+                        let condition = self.eval_expr(condition);
+                        if self.set_return_if_error(&condition) {
+                            return Flow::Finished;
+                        }
+                        let then_taken = condition.is_truthy();
+                        if !then_taken && else_branch.is_none() {
+                            return Flow::Finished;
+                        }
+                        self.env.push_scope();
+                        frames[depth].active = Some(ActiveStmt::If { then_branch: then_taken });
+                        then_taken
+                    }
+                };
+                let body =
+                    if then_taken { then_branch } else { else_branch.as_deref().unwrap_or(&[]) };
+                self.run_generator_scope(frames, body, depth)
+            }
+            Stmt::Match { value, cases, default } => {
+                let arm = match &frames[depth].active {
+                    Some(ActiveStmt::Match { arm }) => *arm,
+                    _ => {
+                        let value = self.eval_expr(value);
+                        if self.set_return_if_error(&value) {
+                            return Flow::Finished;
+                        }
+                        let arm = match self.select_match_arm(&value, cases, default) {
+                            Ok(Some(body)) => cases
+                                .iter()
+                                .position(|case| std::ptr::eq(case.body.as_slice(), body)),
+                            Ok(None) => return Flow::Finished,
+                            Err(error) => {
+                                self.return_value = Some(error);
+                                return Flow::Finished;
+                            }
+                        };
+                        frames[depth].active = Some(ActiveStmt::Match { arm });
+                        arm
+                    }
+                };
+                let body = match arm {
+                    Some(index) => cases[index].body.as_slice(),
+                    None => default.as_deref().unwrap_or(&[]),
+                };
+                self.run_generator_block(frames, body, depth + 1)
+            }
+            Stmt::Loop { condition, body } => {
+                self.resume_generator_loop(frames, condition.as_ref(), body, depth)
+            }
+            Stmt::While { condition, body } => {
+                self.resume_generator_loop(frames, Some(condition), body, depth)
+            }
+            Stmt::For { var, iterable, body } => {
+                self.resume_generator_for(frames, var, iterable, body, depth)
+            }
+            Stmt::LabeledLoop { label, loop_stmt } => {
+                if !resuming {
+                    self.pending_loop_label = Some(label.clone());
+                }
+                self.resume_generator_stmt(frames, loop_stmt, depth)
+            }
+            Stmt::TryExcept { try_block, except_var, except_block, finally_block } => self
+                .resume_generator_try(
+                    frames,
+                    try_block,
+                    except_var,
+                    except_block,
+                    finally_block.as_deref(),
+                    depth,
+                ),
+            _ => {
+                self.eval_stmt(stmt);
+                Flow::Finished
+            }
+        }
+    }
+
+    /// Run the block of the statement at `depth` in the scope that statement opened,
+    /// closing the scope once the block finishes.
+    fn run_generator_scope(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        body: &[Stmt],
+        depth: usize,
+    ) -> Flow {
+        let flow = self.run_generator_block(frames, body, depth + 1);
+        if matches!(flow, Flow::Finished) {
+            self.env.pop_scope();
+        }
+        flow
+    }
+
+    /// `loop` and `while` bodies that yield.
+    fn resume_generator_loop(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        condition: Option<&Expr>,
+        body: &[Stmt],
+        depth: usize,
+    ) -> Flow {
+        let mut resuming = frames[depth].active.is_some();
+        if !resuming {
+            let label = self.pending_loop_label.take();
+            self.loop_labels.push(label.clone());
+            frames[depth].active = Some(ActiveStmt::Loop { label });
+        }
+
+        loop {
+            if !resuming {
+                if let Some(condition) = condition {
+                    let condition = self.eval_expr(condition);
+                    if self.set_return_if_error(&condition) || !condition.is_truthy() {
+                        break;
+                    }
+                }
+                self.env.push_scope();
+            }
+            resuming = false;
+
+            if let Flow::Yielded(value) = self.run_generator_scope(frames, body, depth) {
+                return Flow::Yielded(value);
+            }
+
+            match self.take_loop_signal() {
+                ControlFlow::Break(_) => break,
+                ControlFlow::Continue(_) => continue,
+                ControlFlow::None => {}
+            }
+            if self.return_value.is_some() {
+                break;
+            }
+        }
+
+        self.loop_labels.pop();
+        Flow::Finished
+    }
+
+    /// `for` bodies that yield. The iterable is walked with a sequence cursor, so
+    /// another generator or a lazy sequence is pulled one element per iteration.
+    fn resume_generator_for(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        var: &str,
+        iterable: &Expr,
+        body: &[Stmt],
+        depth: usize,
+    ) -> Flow {
+        let mut resuming = frames[depth].active.is_some();
+        if !resuming {
+            let label = self.pending_loop_label.take();
+            let mut iterable = self.eval_expr(iterable);
+            if self.set_return_if_error(&iterable) {
+                return Flow::Finished;
+            }
+            match iterable {
+                Value::GeneratorDef(..) => iterable = self.call_user_function(&iterable, &[]),
+                Value::Float(count) => iterable = Value::Int(count as i64),
+                _ => {}
+            }
+            let cursor = match Sequence::from_iterable(&iterable) {
+                Ok(sequence) => sequence.cursor(),
+                Err(_) => {
+                    eprintln!("Cannot iterate over non-iterable type");
+                    return Flow::Finished;
+                }
+            };
+            self.loop_labels.push(label.clone());
+            frames[depth].active = Some(ActiveStmt::For { label, cursor });
+        }
+
+        loop {
+            if !resuming {
+                let Some(ActiveStmt::For { cursor, .. }) = &mut frames[depth].active else {
+                    break;
+                };
+                match cursor.next(self) {
+                    Ok(Some(item)) => {
+                        self.env.push_scope();
+                        self.env.define(var.to_string(), item);
+                    }
+                    Ok(None) => break,
+                    Err(message) => {
+                        self.return_value = Some(Value::Error(message));
+                        break;
+                    }
+                }
+            }
+            resuming = false;
+
+            if let Flow::Yielded(value) = self.run_generator_scope(frames, body, depth) {
+                return Flow::Yielded(value);
+            }
+
+            match self.take_loop_signal() {
+                ControlFlow::Break(_) => break,
+                ControlFlow::Continue(_) => continue,
+                ControlFlow::None => {}
+            }
+            if self.return_value.is_some() {
+                break;
+            }
+        }
+
+        self.loop_labels.pop();
+        Flow::Finished
+    }
+
+    /// `try` statements that yield in any of their blocks.
+    fn resume_generator_try(
+        &mut self,
+        frames: &mut Vec<GeneratorFrame>,
+        try_block: &[Stmt],
+        except_var: &str,
+        except_block: &[Stmt],
+        finally_block: Option<&[Stmt]>,
+        depth: usize,
+    ) -> Flow {
+        if frames[depth].active.is_none() {
+            self.env.push_scope();
+            frames[depth].active = Some(ActiveStmt::Try {
+                stage: TryStage::Try,
+                pending_return: None,
+                pending_control_flow: ControlFlow::None,
+            });
+        }
+
+        loop {
+            let Some(ActiveStmt::Try { stage, .. }) = &frames[depth].active else {
+                return Flow::Finished;
+            };
+            match *stage {
+                TryStage::Try => {
+                    let flow = self.run_generator_block(frames, try_block, depth + 1);
+                    if matches!(flow, Flow::Yielded(_)) {
+                        return flow;
+                    }
+
+                    // Same rule as `eval_stmt`: an exceeded execution limit is not catchable
+                    let error_occurred = matches!(
+                        self.return_value,
+                        Some(Value::Error(_)) | Some(Value::ErrorObject { .. })
+                    ) && !self
+                        .execution_budget
+                        .as_ref()
+                        .is_some_and(|budget| budget.is_exhausted());
+                    self.env.pop_scope();
+                    if error_occurred {
+                        let error_value = self.return_value.take().unwrap_or(Value::Null);
+                        self.env.push_scope();
+                        self.env.define(
+                            except_var.to_string(),
+                            error_value.into_caught_error_binding(),
+                        );
+                        self.error_position = None;
+                        self.error_trace.clear();
+                        if let Some(ActiveStmt::Try { stage, .. }) = &mut frames[depth].active {
+                            *stage = TryStage::Except;
+                        }
+                    } else if !self.enter_generator_finally(frames, depth, finally_block) {
+                        return Flow::Finished;
+                    }
+                }
+                TryStage::Except => {
+                    let flow = self.run_generator_block(frames, except_block, depth + 1);
+                    if matches!(flow, Flow::Yielded(_)) {
+                        return flow;
+                    }
+                    self.env.pop_scope();
+                    if !self.enter_generator_finally(frames, depth, finally_block) {
+                        return Flow::Finished;
+                    }
+                }
+                TryStage::Finally => {
+                    let flow =
+                        self.run_generator_scope(frames, finally_block.unwrap_or(&[]), depth);
+                    if matches!(flow, Flow::Yielded(_)) {
+                        return flow;
+                    }
+                    // As in `eval_finally_block`, the set-aside outcome resumes unless the
+                    // block ended in one of its own
+                    if let Some(ActiveStmt::Try { pending_return, pending_control_flow, .. }) =
+                        frames[depth].active.take()
+                    {
+                        if self.return_value.is_none() && self.control_flow == ControlFlow::None {
+                            self.return_value = pending_return;
+                            self.control_flow = pending_control_flow;
+                        }
+                    }
+                    return Flow::Finished;
+                }
+            }
+        }
+    }
+
+    /// Start the `finally` block, setting aside any pending return, error, or loop
+    /// signal. Returns false when there is no `finally` block.
+    fn enter_generator_finally(
+        &mut self,
+        frames: &mut [GeneratorFrame],
+        depth: usize,
+        finally_block: Option<&[Stmt]>,
+    ) -> bool {
+        if finally_block.is_none() {
+            return false;
+        }
+        if let Some(ActiveStmt::Try { stage, pending_return, pending_control_flow }) =
+            &mut frames[depth].active
+        {
+            *stage = TryStage::Finally;
+            *pending_return = self.return_value.take();
+            *pending_control_flow = std::mem::replace(&mut self.control_flow, ControlFlow::None);
+        }
+        self.env.push_scope();
+        true
+    }
+}
@@ -0,0 +1,397 @@
+// File: src/interpreter/hashing.rs
+//
+// Hash keys and sort order for runtime values.
+//
+// `==` compares arrays, dicts, sets, structs, and tagged values by content (see
+// `Value::equals`), and dict keys follow the same rule: an array, dict, set, struct,
+// `Result`, or `Option` used as a key is stored under a canonical text that every equal
+// value shares, so `counts[[x, y]]` finds the entry no matter which equal array built it.
+// That text starts with a NUL character, which string keys may not, so it never names a
+// string's entry, and the key it was made from is kept in a side table so `keys()`,
+// `items()`, and `for` hand back the array or dict itself. Values are copied on write,
+// so changing an array after using it as a key leaves the entry where it was.
+//
+// `sort` without a comparator orders values of different kinds by kind: null, bool,
+// number, string, bytes, array, dict, set, then everything else by type name. Values of
+// one kind compare by content: numbers numerically (NaN last), strings and bytes
+// lexicographically, arrays element by element, and dicts by their sorted entries.
+
+use super::{Interpreter, Value};
+use std::cmp::Ordering;
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex, OnceLock};
+
+/// First character of the text a composite key is stored under.
+const COMPOSITE_KEY_MARKER: char = '\u{0}';
+
+/// The first key stored under each composite key text. Equal keys share a text, so
+/// any of them stands for the rest.
+fn composite_keys() -> &'static Mutex<HashMap<Arc<str>, Value>> {
+    static COMPOSITE_KEYS: OnceLock<Mutex<HashMap<Arc<str>, Value>>> = OnceLock::new();
+    COMPOSITE_KEYS.get_or_init(|| Mutex::new(HashMap::new()))
+}
+
+impl Value {
+    /// Whether this value is a dict key stored under its `hash_key` text instead of
+    /// being rejected by `dict_key`.
+    pub fn is_composite_key(&self) -> bool {
+        self.is_map()
+            || matches!(
+                self,
+                Value::Array(_)
+                    | Value::Set(_)
+                    | Value::Struct { .. }
+                    | Value::Tagged { .. }
+                    | Value::Result { .. }
+                    | Value::Option { .. }
+            )
+    }
+
+    /// Whether this value is one of the dict representations.
+    pub fn is_map(&self) -> bool {
+        matches!(
+            self,
+            Value::Dict(_)
+                | Value::FixedDict { .. }
+                | Value::IntDict(_)
+                | Value::DenseIntDict(_)
+                | Value::DenseIntDictInt(_)
+                | Value::DenseIntDictIntFull(_)
+                | Value::OrderedDict(_)
+        )
+    }
+
+    /// Canonical text of a hashable value: values that are `==` get the same text.
+    /// Functions, handles, and other values compared by identity are not hashable.
+    pub fn hash_key(&self) -> Result<String, String> {
+        let mut key = String::new();
+        self.write_hash_key(&mut key)?;
+        Ok(key)
+    }
+
+    fn write_hash_key(&self, out: &mut String) -> Result<(), String> {
+        match self {
+            Value::Null => out.push_str("null"),
+            Value::Bool(b) => out.push_str(if *b { "true" } else { "false" }),
+            Value::Int(n) => out.push_str(&n.to_string()),
+            Value::BigInt(n) => out.push_str(&n.to_string()),
+            // Whole floats share their integer's key, since `1 == 1.0`
+            Value::Float(f) if *f == 0.0 => out.push('0'),
+            Value::Float(f) if f.is_finite() && f.fract() == 0.0 => {
+                out.push_str(&format!("{:.0}", f))
+            }
+            Value::Float(f) => out.push_str(&format!("{:?}", f)),
+            Value::Str(s) => out.push_str(&format!("{:?}", s.as_str())),
+            Value::Bytes(bytes) => {
+                out.push_str("b\"");
+                for byte in bytes {
+                    out.push_str(&format!("{:02x}", byte));
+                }
+                out.push('"');
+            }
+            Value::Array(items) => Self::write_hash_items(out, "[", items.iter(), "]")?,
+            Value::Set(items) => {
+                let mut keys = items.iter().map(Value::hash_key).collect::<Result<Vec<_>, _>>()?;
+                keys.sort();
+                keys.dedup();
+                out.push_str(&format!("set{{{}}}", keys.join(",")));
+            }
+            // Order is part of an ordered map's value, so its entries keep theirs
+            Value::OrderedDict(map) => {
+                out.push_str("ordmap");
+                Self::write_hash_entries(
+                    out,
+                    map.iter().map(|(key, value)| (key.to_string(), value.clone())).collect(),
+                )?;
+            }
+            Value::Struct { name, fields } | Value::Tagged { tag: name, fields } => {
+                out.push_str(name);
+                let mut entries: Vec<(String, Value)> =
+                    fields.iter().map(|(key, value)| (key.clone(), value.clone())).collect();
+                entries.sort_by(|(left, _), (right, _)| left.cmp(right));
+                Self::write_hash_entries(out, entries)?;
+            }
+            Value::Result { is_ok, value } => {
+                out.push_str(if *is_ok { "Ok(" } else { "Err(" });
+                value.write_hash_key(out)?;
+                out.push(')');
+            }
+            Value::Option { is_some: true, value } => {
+                out.push_str("Some(");
+                value.write_hash_key(out)?;
+                out.push(')');
+            }
+            Value::Option { is_some: false, .. } => out.push_str("None"),
+            map if map.is_map() => {
+                let mut entries = Self::map_entries(map).unwrap_or_default();
+                entries.sort_by(|(left, _), (right, _)| left.cmp(right));
+                Self::write_hash_entries(out, entries)?;
+            }
+            other => {
+                return Err(format!(
+                    "{} values cannot be hashed",
+                    Interpreter::value_type_name(other)
+                ))
+            }
+        }
+        Ok(())
+    }
+
+    fn write_hash_items<'a>(
+        out: &mut String,
+        open: &str,
+        items: impl Iterator<Item = &'a Value>,
+        close: &str,
+    ) -> Result<(), String> {
+        out.push_str(open);
+        for (index, item) in items.enumerate() {
+            if index > 0 {
+                out.push(',');
+            }
+            item.write_hash_key(out)?;
+        }
+        out.push_str(close);
+        Ok(())
+    }
+
+    /// `{"key":value,...}` for entries already in their canonical order.
+    fn write_hash_entries(out: &mut String, entries: Vec<(String, Value)>) -> Result<(), String> {
+        out.push('{');
+        for (index, (key, value)) in entries.iter().enumerate() {
+            if index > 0 {
+                out.push(',');
+            }
+            out.push_str(&format!("{:?}:", key));
+            value.write_hash_key(out)?;
+        }
+        out.push('}');
+        Ok(())
+    }
+
+    /// `index` as the key it names in `container`: a composite key of a dict becomes
+    /// its stored key text. `None` when the index is used as-is.
+    pub fn map_key_index(container: &Value, index: &Value) -> Option<Result<Value, String>> {
+        if !container.is_map() {
+            return None;
+        }
+        match index {
+            Value::Str(text) => Self::check_string_key(text).err().map(Err),
+            composite if composite.is_composite_key() => Some(
+                composite.composite_key_text().map(|key| Value::Str(Arc::new(key.to_string()))),
+            ),
+            _ => None,
+        }
+    }
+
+    /// Text a composite key is stored under in a string-keyed dict: a NUL character
+    /// followed by its hash key. Records the key so `map_key_value` can return it.
+    pub fn composite_key_text(&self) -> Result<Arc<str>, String> {
+        let mut text = String::from(COMPOSITE_KEY_MARKER);
+        self.write_hash_key(&mut text)?;
+        let text: Arc<str> = Arc::from(text);
+        let mut keys = composite_keys().lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+        keys.entry(text.clone()).or_insert_with(|| self.clone());
+        Ok(text)
+    }
+
+    /// String keys cannot start with the composite key marker.
+    pub fn check_string_key(key: &str) -> Result<(), String> {
+        if key.starts_with(COMPOSITE_KEY_MARKER) {
+            return Err("Dict keys cannot start with a NUL character".to_string());
+        }
+        Ok(())
+    }
+
+    /// The key a string-keyed dict entry was stored under: the original array, dict, or
+    /// other composite value, or the string itself.
+    pub fn map_key_value(key: &str) -> Value {
+        if key.starts_with(COMPOSITE_KEY_MARKER) {
+            let keys = composite_keys().lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+            if let Some(original) = keys.get(key) {
+                return original.clone();
+            }
+        }
+        Value::Str(Arc::new(key.to_string()))
+    }
+
+    /// Order used by `sort` without a comparator; total over all values.
+    pub fn total_cmp(left: &Value, right: &Value) -> Ordering {
+        let (left_rank, right_rank) = (Self::sort_rank(left), Self::sort_rank(right));
+        if left_rank != right_rank {
+            return left_rank.cmp(&right_rank);
+        }
+
+        match (left, right) {
+            (Value::Bool(a), Value::Bool(b)) => a.cmp(b),
+            (Value::Int(a), Value::Int(b)) => a.cmp(b),
+            (Value::Str(a), Value::Str(b)) => a.as_str().cmp(b.as_str()),
+            (Value::Bytes(a), Value::Bytes(b)) => a.cmp(b),
+            (Value::Array(a), Value::Array(b)) => Self::total_cmp_items(a.iter(), b.iter()),
+            (Value::Set(a), Value::Set(b)) => {
+                let mut a: Vec<&Value> = a.iter().collect();
+                let mut b: Vec<&Value> = b.iter().collect();
+                a.sort_by(|x, y| Self::total_cmp(x, y));
+                b.sort_by(|x, y| Self::total_cmp(x, y));
+                Self::total_cmp_items(a.into_iter(), b.into_iter())
+            }
+            _ if left.is_map() && right.is_map() => {
+                let sorted_entries = |value: &Value| {
+                    let mut entries = Self::map_entries(value).unwrap_or_default();
+                    entries.sort_by(|(x, _), (y, _)| x.cmp(y));
+                    entries
+                };
+                let (a, b) = (sorted_entries(left), sorted_entries(right));
+                for ((left_key, left_value), (right_key, right_value)) in a.iter().zip(b.iter()) {
+                    let ordering = left_key
+                        .cmp(right_key)
+                        .then_with(|| Self::total_cmp(left_value, right_value));
+                    if ordering != Ordering::Equal {
+                        return ordering;
+                    }
+                }
+                a.len().cmp(&b.len())
+            }
+            _ if left_rank == 2 => Self::number_cmp(left, right),
+            _ => Interpreter::value_type_name(left).cmp(Interpreter::value_type_name(right)),
+        }
+    }
+
+    fn total_cmp_items<'a>(
+        mut left: impl Iterator<Item = &'a Value>,
+        mut right: impl Iterator<Item = &'a Value>,
+    ) -> Ordering {
+        loop {
+            match (left.next(), right.next()) {
+                (Some(a), Some(b)) => match Self::total_cmp(a, b) {
+                    Ordering::Equal => continue,
+                    ordering => return ordering,
+                },
+                (None, Some(_)) => return Ordering::Less,
+                (Some(_), None) => return Ordering::Greater,
+                (None, None) => return Ordering::Equal,
+            }
+        }
+    }
+
+    /// Numeric order over ints, floats, and big ints, with NaN after every number.
+    fn number_cmp(left: &Value, right: &Value) -> Ordering {
+        let is_nan = |value: &Value| matches!(value, Value::Float(f) if f.is_nan());
+        match (is_nan(left), is_nan(right)) {
+            (true, true) => return Ordering::Equal,
+            (true, false) => return Ordering::Greater,
+            (false, true) => return Ordering::Less,
+            (false, false) => {}
+        }
+        let ordering = |op: &str| Value::compare_order(left, op, right).unwrap_or(false);
+        if ordering("<") {
+            Ordering::Less
+        } else if ordering(">") {
+            Ordering::Greater
+        } else {
+            Ordering::Equal
+        }
+    }
+
+    fn sort_rank(value: &Value) -> u8 {
+        match value {
+            Value::Null => 0,
+            Value::Bool(_) => 1,
+            Value::Int(_) | Value::Float(_) | Value::BigInt(_) => 2,
+            Value::Str(_) => 3,
+            Value::Bytes(_) => 4,
+            Value::Array(_) => 5,
+            map if map.is_map() => 6,
+            Value::Set(_) => 7,
+            _ => 8,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::super::{DictMap, Value};
+    use std::cmp::Ordering;
+    use std::sync::Arc;
+
+    fn text(s: &str) -> Value {
+        Value::Str(Arc::new(s.to_string()))
+    }
+
+    fn array(items: Vec<Value>) -> Value {
+        Value::Array(Arc::new(items))
+    }
+
+    #[test]
+    fn equal_values_share_a_hash_key() {
+        let mut left = DictMap::default();
+        left.insert(Arc::from("b"), array(vec![Value::Int(1), Value::Float(2.0)]));
+        left.insert(Arc::from("a"), text("x"));
+        let mut right = DictMap::default();
+        right.insert(Arc::from("a"), text("x"));
+        right.insert(Arc::from("b"), array(vec![Value::Float(1.0), Value::Int(2)]));
+        let (left, right) = (Value::Dict(Arc::new(left)), Value::Dict(Arc::new(right)));
+
+        assert!(Value::equals(&left, &right));
+        assert_eq!(left.hash_key().unwrap(), right.hash_key().unwrap());
+        assert_eq!(left.hash_key().unwrap(), r#"{"a":"x","b":[1,2]}"#);
+
+        assert_ne!(
+            array(vec![Value::Int(1)]).hash_key().unwrap(),
+            array(vec![text("1")]).hash_key().unwrap()
+        );
+        let set = |items: Vec<Value>| Value::Set(items);
+        assert_eq!(
+            set(vec![Value::Int(2), Value::Int(1)]).hash_key().unwrap(),
+            set(vec![Value::Int(1), Value::Int(2)]).hash_key().unwrap()
+        );
+        assert!(array(vec![Value::NativeFunction("print".to_string())]).hash_key().is_err());
+    }
+
+    #[test]
+    fn composite_keys_index_maps_apart_from_strings() {
+        let point = array(vec![Value::Int(1), Value::Int(2)]);
+        let key = Value::dict_key(&point).unwrap();
+        assert_eq!(key.as_ref(), "\u{0}[1,2]");
+        assert_ne!(key, Value::dict_key(&text("[1,2]")).unwrap());
+
+        let map = Value::Dict(Arc::new(DictMap::default()));
+        assert!(matches!(
+            Value::map_key_index(&map, &point),
+            Some(Ok(Value::Str(text))) if text.as_str() == "\u{0}[1,2]"
+        ));
+        assert!(Value::map_key_index(&map, &text("k")).is_none());
+        assert!(matches!(Value::map_key_index(&map, &text("\u{0}[1,2]")), Some(Err(_))));
+        assert!(Value::map_key_index(&point, &point).is_none());
+        assert!(Value::dict_key(&Value::Float(1.5)).is_err());
+
+        let stored = Value::map_key_value(&key);
+        assert!(Value::equals(&stored, &point));
+        assert!(
+            matches!(Value::map_key_value("[1,2]"), Value::Str(text) if text.as_str() == "[1,2]")
+        );
+    }
+
+    #[test]
+    fn total_cmp_orders_mixed_values_by_kind_then_content() {
+        let mut values = vec![
+            text("b"),
+            array(vec![Value::Int(1), Value::Int(2)]),
+            Value::Float(f64::NAN),
+            Value::Int(3),
+            Value::Null,
+            array(vec![Value::Int(1)]),
+            Value::Float(1.5),
+            Value::Bool(true),
+            text("a"),
+            Value::Bool(false),
+        ];
+        values.sort_by(Value::total_cmp);
+
+        let keys: Vec<String> = values.iter().map(|value| value.hash_key().unwrap()).collect();
+        assert_eq!(
+            keys,
+            vec!["null", "false", "true", "1.5", "3", "NaN", "\"a\"", "\"b\"", "[1]", "[1,2]"]
+        );
+        assert_eq!(Value::total_cmp(&Value::Int(1), &Value::Float(1.0)), Ordering::Equal);
+    }
+}
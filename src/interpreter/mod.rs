@@ -18,9 +18,13 @@
 
 // Module structure
 mod async_runtime;
+mod call_arguments;
 mod capabilities;
 mod control_flow;
+mod debugger;
 mod environment;
+mod generator;
+mod hashing;
 mod native_functions;
 mod test_runner;
 mod value;
@@ -28,23 +32,35 @@ mod value;
 // Re-exports for backward compatibility
 pub use async_runtime::AsyncRuntime;
 pub use capabilities::{NativeCapability, RuntimeCapabilityPolicy};
+pub use debugger::{Breakpoint, Debugger};
 pub use environment::{BindingKind, Environment};
 // Test framework exports - used by CLI test command
 #[allow(unused_imports)]
-pub use test_runner::{TestCase, TestReport, TestResult, TestRunner};
+pub use test_runner::{discover_test_files, TestCase, TestReport, TestResult, TestRunner};
 // Database infrastructure - used by stub database.rs module
 #[allow(unused_imports)]
 pub use value::{
-    CallableArity, ConnectionPool, DatabaseConnection, DenseIntDict, DenseIntDictInt,
-    DenseIntDictIntFull, DictMap, IntDictMap, LeakyFunctionBody, Value,
+    CallableArity, ChannelPoll, ChannelState, ConnectionPool, DatabaseConnection, Decorated,
+    DenseIntDict, DenseIntDictInt, DenseIntDictIntFull, DictMap, IntDictMap, LeakyFunctionBody,
+    MutexState, OrderedDictMap, ParamSignature, RouterState, Sequence, SequenceHost, SyncMapState,
+    Value, WaitGroupState,
 };
 
+pub(crate) use call_arguments::{arrange_call_args, CallArgs};
+
+pub(crate) use native_functions::async_ops::PromiseCallback;
+pub(crate) use native_functions::decorators::DecoratorHost;
+
 // Internal-only imports
 use control_flow::ControlFlow;
 
-use crate::ast::{Expr, Stmt};
+use crate::ast::{Expr, MatchCase, Stmt};
 use crate::builtins;
-use crate::errors::{unsupported_struct_generator_method_message, RuffError};
+use crate::compiler::Compiler;
+use crate::errors::{
+    unknown_struct_field_message, unsupported_struct_generator_method_message, RuffError,
+    StackFrame,
+};
 use crate::http_request_utils;
 use crate::module::ModuleLoader;
 use crate::runtime_limits;
@@ -79,7 +95,6 @@ use std::collections::{HashMap, VecDeque};
 use std::fs::File;
 #[allow(unused_imports)]
 use std::io::Read;
-use std::io::Write;
 #[allow(unused_imports)]
 use std::path::Path;
 use std::sync::{Arc, Mutex};
@@ -99,7 +114,10 @@ fn lock_or_runtime_error<'a, T>(
 
 #[derive(Clone, Debug)]
 enum SpawnCapturedValue {
-    Tagged { tag: String, fields: Vec<(String, SpawnCapturedValue)> },
+    Tagged {
+        tag: String,
+        fields: Vec<(String, SpawnCapturedValue)>,
+    },
     Int(i64),
     Float(f64),
     Str(String),
@@ -107,7 +125,10 @@ enum SpawnCapturedValue {
     Null,
     Bytes(Vec<u8>),
     NativeFunction(String),
-    Struct { name: String, fields: Vec<(String, SpawnCapturedValue)> },
+    Struct {
+        name: String,
+        fields: Vec<(String, SpawnCapturedValue)>,
+    },
     Array(Vec<SpawnCapturedValue>),
     Dict(Vec<(String, SpawnCapturedValue)>),
     FixedDict(Vec<(String, SpawnCapturedValue)>),
@@ -115,8 +136,17 @@ enum SpawnCapturedValue {
     DenseIntDict(Vec<SpawnCapturedValue>),
     DenseIntDictInt(Vec<Option<i64>>),
     DenseIntDictIntFull(Vec<i64>),
-    Result { is_ok: bool, value: Box<SpawnCapturedValue> },
-    Option { is_some: bool, value: Box<SpawnCapturedValue> },
+    Result {
+        is_ok: bool,
+        value: Box<SpawnCapturedValue>,
+    },
+    Option {
+        is_some: bool,
+        value: Box<SpawnCapturedValue>,
+    },
+    /// Functions, struct definitions, and synchronization handles cross threads as-is:
+    /// their mutable state (closure environments, channel queues, counters) is mutex-guarded.
+    Shared(Value),
 }
 
 #[cfg(test)]
@@ -156,6 +186,38 @@ mod runtime_limit_tests {
         ));
     }
 
+    #[test]
+    fn execution_budgets_bound_depth_and_cannot_be_caught() {
+        use crate::lexer::tokenize;
+        use crate::parser::Parser;
+
+        let mut interpreter = Interpreter::new();
+        interpreter.set_execution_budget(Some(runtime_limits::ExecutionBudget::start(
+            runtime_limits::ExecutionLimits {
+                timeout: Some(std::time::Duration::from_millis(50)),
+                max_call_depth: Some(4),
+                ..Default::default()
+            },
+        )));
+        let result = interpreter.with_function_context("probe", |interp| {
+            interp.with_function_context("nested", |_| ()).is_ok()
+        });
+        assert!(matches!(result, Ok(true)));
+        interpreter.function_depth = 4;
+        assert!(interpreter.with_function_context("too_deep", |_| ()).is_err());
+        interpreter.function_depth = 0;
+
+        let program = Parser::new(
+            tokenize("while true {\n try {\n x := 1\n } except e {\n x := 2\n }\n}").unwrap(),
+        )
+        .parse();
+        interpreter.eval_stmts(&program);
+        assert!(matches!(
+            &interpreter.return_value,
+            Some(Value::Error(message)) if message == "Execution time limit of 50ms exceeded"
+        ));
+    }
+
     #[test]
     fn function_context_allows_boundary_minus_one() {
         let mut interpreter = Interpreter::new();
@@ -249,6 +311,23 @@ impl SpawnCapturedValue {
                 is_some: *is_some,
                 value: Box::new(Self::from_value(value)?),
             }),
+            Value::Function(..)
+            | Value::AsyncFunction(..)
+            | Value::GeneratorDef(..)
+            | Value::StructDef { .. }
+            | Value::Interface { .. }
+            | Value::Channel(_)
+            | Value::WaitGroup(_)
+            | Value::Mutex(_)
+            | Value::Atomic(_)
+            | Value::SyncMap(_)
+            | Value::Decorated(_)
+            | Value::Router(_)
+            | Value::StringBuilder(_)
+            | Value::NativeLibrary(_)
+            | Value::Process(_)
+            | Value::Socket(_)
+            | Value::Sequence(_) => Some(SpawnCapturedValue::Shared(value.clone())),
             _ => None,
         }
     }
@@ -315,24 +394,89 @@ impl SpawnCapturedValue {
             SpawnCapturedValue::Option { is_some, value } => {
                 Value::Option { is_some, value: Box::new(value.into_value()) }
             }
+            SpawnCapturedValue::Shared(value) => value,
         }
     }
 }
 
+/// Call requested by `return f(...)` in tail position.
+///
+/// The arguments are already evaluated in the returning function's scope. The call
+/// loop that invoked that function performs the call in place of a nested one.
+struct PendingTailCall {
+    callable_name: String,
+    params: Vec<String>,
+    body: LeakyFunctionBody,
+    captured_env: Option<Arc<Mutex<Environment>>>,
+    args: Vec<Value>,
+}
+
+/// A function body being evaluated, with the position of the statement that called it.
+struct ActiveFrame {
+    name: String,
+    call_site: Option<(usize, usize)>,
+}
+
+/// Host function registered by an embedding program; an `Err` is raised as a Ruff error.
+pub type HostCallback = Arc<dyn Fn(&[Value]) -> Result<Value, String> + Send + Sync>;
+
+/// Destination for script output when a host replaces the process streams, such as
+/// an embedding program or the WebAssembly build's JavaScript callbacks.
+pub trait OutputSink: Send + Sync {
+    fn write_stdout(&self, text: &str);
+
+    fn write_stderr(&self, text: &str) {
+        eprint!("{}", text);
+    }
+}
+
+/// Captured output buffer; standard error still goes to the process.
+impl OutputSink for Mutex<Vec<u8>> {
+    fn write_stdout(&self, text: &str) {
+        let mut buffer = self.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+        buffer.extend_from_slice(text.as_bytes());
+    }
+}
+
 /// Main interpreter that executes Ruff programs
 pub struct Interpreter {
     pub env: Environment,
     pub return_value: Option<Value>,
     control_flow: ControlFlow,
     function_depth: usize,
-    loop_depth: usize,
-    output: Option<Arc<Mutex<Vec<u8>>>>,
+    /// Labels of the loops enclosing the current statement (innermost last)
+    loop_labels: Vec<Option<String>>,
+    /// Label attached by `LabeledLoop`, consumed when the loop it wraps starts
+    pending_loop_label: Option<String>,
+    /// Whether `return f(...)` may hand its call to the enclosing call loop. True only
+    /// directly inside a function body run by `call_function_with_tail_calls`, outside
+    /// any `try` block and generator body.
+    tail_call_allowed: bool,
+    pending_tail_call: Option<PendingTailCall>,
+    output: Option<Arc<dyn OutputSink>>,
     pub source_file: Option<String>,
     pub source_lines: Vec<String>,
+    /// `(line, column)` of the innermost positioned statement that raised the pending
+    /// error, with the sequence number of that statement's evaluation.
+    error_position: Option<((usize, usize), u64)>,
+    /// Bumped each time a statement with a known source position starts evaluating.
+    statement_sequence: u64,
+    /// Position of the innermost positioned statement evaluating in the current frame.
+    current_position: Option<(usize, usize)>,
+    /// Function bodies being evaluated, outermost first.
+    active_frames: Vec<ActiveFrame>,
+    /// Stack trace captured when the pending error was recorded in `error_position`.
+    error_trace: Vec<StackFrame>,
+    /// Interactive debugger consulted before each positioned statement (`ruff debug`).
+    debugger: Option<Box<Debugger>>,
+    /// Native functions supplied by the embedding host, shared with worker interpreters.
+    host_functions: HashMap<String, HostCallback>,
     pub module_loader: ModuleLoader,
     call_stack: Vec<String>, // Track function calls for stack traces
     async_task_pool_size: usize,
     capability_policy: RuntimeCapabilityPolicy,
+    /// Time, memory, and call-depth limits for the run (`ruff run --sandbox`).
+    execution_budget: Option<Arc<runtime_limits::ExecutionBudget>>,
 }
 
 impl Interpreter {
@@ -341,11 +485,55 @@ impl Interpreter {
             "println" => "print",
             "type_of" => "type",
             "str" => "to_string",
+            "int" => "to_int",
+            "float" => "to_float",
             "time" => "current_timestamp",
             "substr" => "substring",
             "pad_start" => "pad_left",
             "pad_end" => "pad_right",
             "now_utc_seconds" => "now_unix",
+            "chan" => "channel",
+            "assert_eq" => "assert_equal",
+            "fmt.sprintf" => "format",
+            "fmt.printf" => "print_f",
+            "time.now" => "time_now",
+            "time.monotonic" => "time_monotonic",
+            "time.format" => "time_format",
+            "time.parse" => "time_parse",
+            "time.parts" => "time_parts",
+            "time.add_days" => "time_add_days",
+            "time.add_months" => "time_add_months",
+            "time.diff" => "time_diff",
+            "time.duration" => "time_duration",
+            "math.sqrt" => "sqrt",
+            "math.pow" => "pow",
+            "math.abs" => "abs",
+            "math.floor" => "floor",
+            "math.ceil" => "ceil",
+            "math.round" => "round",
+            "math.min" => "min",
+            "math.max" => "max",
+            "math.sin" => "sin",
+            "math.cos" => "cos",
+            "math.tan" => "tan",
+            "math.log" => "log",
+            "math.exp" => "exp",
+            "math.random" => "random",
+            "math.seed" => "set_random_seed",
+            "io.read_file" => "read_file",
+            "io.write_file" => "write_file",
+            "io.append_file" => "append_file",
+            "io.read_lines" => "read_lines",
+            "io.open" => "io_open",
+            "io.read_bytes" => "io_read_bytes",
+            "io.write_bytes" => "io_write_bytes",
+            "io.append_bytes" => "io_append_bytes",
+            "io.read_at" => "io_read_at",
+            "io.write_at" => "io_write_at",
+            "io.seek_read" => "io_seek_read",
+            "io.file_metadata" => "io_file_metadata",
+            "io.truncate" => "io_truncate",
+            "io.copy_range" => "io_copy_range",
             other => other,
         }
     }
@@ -369,15 +557,27 @@ impl Interpreter {
             return_value: None,
             control_flow: ControlFlow::None,
             function_depth: 0,
-            loop_depth: 0,
+            loop_labels: Vec::new(),
+            pending_loop_label: None,
+            tail_call_allowed: false,
+            pending_tail_call: None,
             output: None,
             source_file: None,
             source_lines: Vec::new(),
+            error_position: None,
+            statement_sequence: 0,
+            current_position: None,
+            active_frames: Vec::new(),
+            error_trace: Vec::new(),
+            debugger: None,
+            host_functions: HashMap::new(),
             module_loader: ModuleLoader::new(),
             call_stack: Vec::new(),
             async_task_pool_size: DEFAULT_ASYNC_TASK_POOL_SIZE,
-            capability_policy,
+            capability_policy: capability_policy.clone(),
+            execution_budget: None,
         };
+        interpreter.module_loader.set_capability_policy(capability_policy);
 
         // Register built-in functions and constants
         interpreter.register_builtins();
@@ -390,9 +590,28 @@ impl Interpreter {
     }
 
     pub fn set_capability_policy(&mut self, capability_policy: RuntimeCapabilityPolicy) {
+        self.module_loader.set_capability_policy(capability_policy.clone());
         self.capability_policy = capability_policy;
     }
 
+    pub fn execution_budget(&self) -> Option<&Arc<runtime_limits::ExecutionBudget>> {
+        self.execution_budget.as_ref()
+    }
+
+    /// Enforces `budget` on this interpreter and the modules it imports.
+    pub fn set_execution_budget(&mut self, budget: Option<Arc<runtime_limits::ExecutionBudget>>) {
+        self.module_loader.set_execution_budget(budget.clone());
+        self.execution_budget = budget;
+    }
+
+    /// Defines the global `name` as a native function that runs `callback`. A host
+    /// function shadows a built-in of the same name.
+    #[allow(dead_code)] // Used by the embedding API (`ruff::Ruff::register`)
+    pub fn register_host_function(&mut self, name: &str, callback: HostCallback) {
+        self.host_functions.insert(name.to_string(), callback);
+        self.env.set(name.to_string(), Value::NativeFunction(name.to_string()));
+    }
+
     pub fn capability_error(capability: NativeCapability, surface: &str) -> Value {
         Value::Error(format!(
             "Capability denied: {} required for {}; rerun with {}",
@@ -441,7 +660,11 @@ impl Interpreter {
         callable_name: &str,
         body: impl FnOnce(&mut Self) -> T,
     ) -> Result<T, Value> {
-        let max_depth = runtime_limits::DEFAULT_MAX_INTERPRETER_CALL_DEPTH;
+        let max_depth = self
+            .execution_budget
+            .as_ref()
+            .and_then(|budget| budget.max_call_depth())
+            .unwrap_or(runtime_limits::DEFAULT_MAX_INTERPRETER_CALL_DEPTH);
         if self.function_depth >= max_depth || self.call_stack.len() >= max_depth {
             return Err(Value::Error(format!(
                 "Maximum call stack depth of {} exceeded while calling {}",
@@ -449,27 +672,188 @@ impl Interpreter {
             )));
         }
 
+        // Loops in the caller are not visible to break/continue inside the callee.
+        let caller_loop_labels = std::mem::take(&mut self.loop_labels);
+        let caller_tail_call_allowed = std::mem::replace(&mut self.tail_call_allowed, false);
+        // The callee starts without a position until one of its statements sets it, so
+        // bodies parsed without positions (modules) do not inherit the call site.
+        let call_site = self.current_position.take();
+        self.active_frames.push(ActiveFrame { name: callable_name.to_string(), call_site });
         self.function_depth += 1;
         let result = body(self);
         self.function_depth = self.function_depth.saturating_sub(1);
+        self.active_frames.pop();
+        self.current_position = call_site;
+        self.loop_labels = caller_loop_labels;
+        self.tail_call_allowed = caller_tail_call_allowed;
         Ok(result)
     }
 
+    /// Run a user function call whose frame is already on `call_stack`, popping it
+    /// when done.
+    ///
+    /// A `return f(...)` in the body does not recurse: it leaves a pending tail call
+    /// behind, and this loop runs that call in the same frame. Tail-recursive code
+    /// therefore needs neither Rust stack nor call-depth budget per iteration.
+    fn call_function_with_tail_calls(&mut self, call: PendingTailCall) -> Value {
+        let mut call = call;
+        loop {
+            let arity = Self::function_arity(call.callable_name.clone(), &call.params);
+            if let Some(error) = self.validate_callable_arity(&arity, call.args.len()) {
+                self.call_stack.pop();
+                return error;
+            }
+
+            // Closures run in their captured environment; the caller's is restored after.
+            let saved_env = call.captured_env.as_ref().map(|closure_env_ref| {
+                let closure_env =
+                    closure_env_ref.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).clone();
+                std::mem::replace(&mut self.env, closure_env)
+            });
+            self.env.push_scope();
+
+            for (i, param) in call.params.iter().enumerate() {
+                if let Some(arg) = call.args.get(i) {
+                    self.env.define(param.clone(), arg.clone());
+                }
+            }
+
+            let body = call.body.clone();
+            let outcome = self.with_function_context(call.callable_name.as_str(), |interp| {
+                interp.tail_call_allowed = true;
+                interp.eval_stmts(&body.get())
+            });
+            let tail_call = self.pending_tail_call.take();
+
+            let result = match outcome {
+                Err(error) => Some(error),
+                Ok(()) if tail_call.is_some() => {
+                    // The `return` that requested the tail call carries no value
+                    self.return_value = None;
+                    None
+                }
+                Ok(()) => Some(if let Some(Value::Return(val)) = self.return_value.clone() {
+                    self.return_value = None;
+                    *val
+                } else if let Some(Value::Error(msg)) = self.return_value.clone() {
+                    Value::Error(msg)
+                } else if let Some(Value::ErrorObject { .. }) = self.return_value.clone() {
+                    self.return_value.clone().unwrap()
+                } else {
+                    self.return_value = None;
+                    Value::Null
+                }),
+            };
+
+            self.env.pop_scope();
+            if let Some(saved_env) = saved_env {
+                if let Some(closure_env_ref) = &call.captured_env {
+                    // Update the captured environment
+                    *closure_env_ref.lock().unwrap_or_else(|poisoned| poisoned.into_inner()) =
+                        self.env.clone();
+                }
+                self.env = saved_env;
+            }
+
+            match (result, tail_call) {
+                (None, Some(next_call)) => {
+                    if let Some(frame) = self.call_stack.last_mut() {
+                        frame.clone_from(&next_call.callable_name);
+                    }
+                    call = next_call;
+                }
+                (result, _) => {
+                    self.call_stack.pop();
+                    return result.unwrap_or(Value::Null);
+                }
+            }
+        }
+    }
+
+    /// Turn `return name(args)` into a pending tail call when the current body allows it.
+    ///
+    /// Only plain calls to user functions qualify; method calls, natives, async
+    /// functions, and generators keep their regular call paths. Returns the argument
+    /// error instead when evaluating an argument fails.
+    fn prepare_tail_call(
+        &mut self,
+        function: &Expr,
+        args: &[Expr],
+    ) -> Option<Result<PendingTailCall, Value>> {
+        if !self.tail_call_allowed {
+            return None;
+        }
+        let Expr::Identifier(name) = function else {
+            return None;
+        };
+        let Some(Value::Function(params, body, captured_env)) = self.env.get(name) else {
+            return None;
+        };
+
+        let evaluated_args = match self
+            .eval_call_args(args)
+            .and_then(|call_args| Self::arrange_function_args(name, &params, &body, 0, call_args))
+        {
+            Ok(evaluated_args) => evaluated_args,
+            Err(error) => return Some(Err(error)),
+        };
+
+        Some(Ok(PendingTailCall {
+            callable_name: name.clone(),
+            params,
+            body,
+            captured_env,
+            args: evaluated_args,
+        }))
+    }
+
     fn with_loop_context<T>(&mut self, body: impl FnOnce(&mut Self) -> T) -> T {
-        self.loop_depth += 1;
+        let label = self.pending_loop_label.take();
+        self.loop_labels.push(label);
         let result = body(self);
-        self.loop_depth = self.loop_depth.saturating_sub(1);
+        self.loop_labels.pop();
         result
     }
 
+    /// Resolve a pending break/continue after one iteration of the innermost loop.
+    ///
+    /// Signals aimed at this loop (unlabeled, or naming its label) are consumed. A
+    /// signal naming an outer loop stays pending and is reported as a break so this
+    /// loop exits and the enclosing loop sees it next.
+    fn take_loop_signal(&mut self) -> ControlFlow {
+        let target = match &self.control_flow {
+            ControlFlow::None => return ControlFlow::None,
+            ControlFlow::Break(target) | ControlFlow::Continue(target) => target,
+        };
+        let this_loop = self.loop_labels.last().and_then(|label| label.as_ref());
+        if target.is_some() && target.as_ref() != this_loop {
+            return ControlFlow::Break(None);
+        }
+        std::mem::replace(&mut self.control_flow, ControlFlow::None)
+    }
+
+    /// Validate a break/continue target against the enclosing loops.
+    fn loop_jump_error(&self, keyword: &str, label: Option<&String>) -> Option<Value> {
+        if self.loop_labels.is_empty() {
+            return Some(Value::Error(format!("{} can only be used inside a loop", keyword)));
+        }
+        match label {
+            Some(label) if !self.loop_labels.iter().any(|l| l.as_ref() == Some(label)) => {
+                Some(Value::Error(format!(
+                    "{} label '{}' does not name an enclosing loop",
+                    keyword, label
+                )))
+            }
+            _ => None,
+        }
+    }
+
     fn capture_spawn_bindings(&self) -> Vec<(String, SpawnCapturedValue)> {
         let mut merged_bindings: HashMap<String, SpawnCapturedValue> = HashMap::new();
 
-        for scope in &self.env.scopes {
-            for (name, value) in scope {
-                if let Some(captured_value) = SpawnCapturedValue::from_value(value) {
-                    merged_bindings.insert(name.clone(), captured_value);
-                }
+        for (name, value) in self.env.bindings() {
+            if let Some(captured_value) = SpawnCapturedValue::from_value(&value) {
+                merged_bindings.insert(name, captured_value);
             }
         }
 
@@ -575,11 +959,15 @@ impl Interpreter {
             "take",
             "skip",
             "windows",
+            // Generators
+            "next",
             // Array generation functions
             "range",
             // String formatting functions
             "format",
+            "print_f",
             // Dict functions
+            "ordmap",
             "keys",
             "values",
             "items",
@@ -590,6 +978,9 @@ impl Interpreter {
             "invert",
             "update",
             "get_default",
+            // Nested data access
+            "get_path",
+            "set_path",
             // I/O functions
             "input",
             // Type conversion functions
@@ -597,6 +988,8 @@ impl Interpreter {
             "parse_float",
             "to_int",
             "to_float",
+            "int",
+            "float",
             "to_string",
             "str",
             "to_bool",
@@ -616,6 +1009,7 @@ impl Interpreter {
             "is_dict",
             "is_null",
             "is_function",
+            "implements",
             // Assert & Debug functions
             "assert",
             "debug",
@@ -645,6 +1039,7 @@ impl Interpreter {
             "io_file_metadata",
             "io_truncate",
             "io_copy_range",
+            "io_open",
             // JSON functions
             "parse_json",
             "to_json",
@@ -683,6 +1078,15 @@ impl Interpreter {
             "elapsed",
             "format_date",
             "parse_date",
+            "time_now",
+            "time_monotonic",
+            "time_format",
+            "time_parse",
+            "time_parts",
+            "time_add_days",
+            "time_add_months",
+            "time_diff",
+            "time_duration",
             // System operation functions
             "env",
             "env_or",
@@ -794,6 +1198,9 @@ impl Interpreter {
             "stack_to_array",
             // Concurrency functions
             "channel",
+            "chan",
+            "wait_group",
+            "select",
             "shared_set",
             "shared_get",
             "shared_has",
@@ -824,6 +1231,14 @@ impl Interpreter {
             "assert_true",
             "assert_false",
             "assert_contains",
+            "assert_eq",
+            "assert_raises",
+            "fail",
+            "bench",
+            // Function decorators
+            "memo",
+            "timed",
+            "retry",
             // Image processing functions
             "load_image",
             "gif_to_webp",
@@ -883,6 +1298,7 @@ impl Interpreter {
         self.env.define("E".to_string(), Value::Float(std::f64::consts::E));
 
         // Math functions
+        self.env.define("math".to_string(), builtins::math_module_value());
         self.env.define("abs".to_string(), Value::NativeFunction("abs".to_string()));
         self.env.define("sqrt".to_string(), Value::NativeFunction("sqrt".to_string()));
         self.env.define("pow".to_string(), Value::NativeFunction("pow".to_string()));
@@ -904,6 +1320,32 @@ impl Interpreter {
         self.env.define("bit_shr".to_string(), Value::NativeFunction("bit_shr".to_string()));
 
         // String functions
+        self.env.define("strings".to_string(), builtins::strings_module_value());
+
+        // Lazy sequences
+        self.env.define("iter".to_string(), builtins::iter_module_value());
+
+        // Native shared libraries
+        self.env.define("ffi".to_string(), builtins::ffi_module_value());
+
+        // External programs
+        self.env.define("proc".to_string(), builtins::proc_module_value());
+
+        // Sockets
+        self.env.define("net".to_string(), builtins::net_module_value());
+
+        // Command-line tools
+        self.env.define("os".to_string(), builtins::os_module_value());
+        self.env.define("flags".to_string(), builtins::flags_module_value());
+
+        // Local databases
+        self.env.define("sqlite".to_string(), builtins::sqlite_module_value());
+
+        // Shared state for spawned workers
+        self.env.define("sync".to_string(), builtins::sync_module_value());
+
+        // printf-style formatting
+        self.env.define("fmt".to_string(), builtins::fmt_module_value());
         self.env.define("len".to_string(), Value::NativeFunction("len".to_string()));
         self.env.define(
             "__vm_for_iterable".to_string(),
@@ -1009,13 +1451,18 @@ impl Interpreter {
         self.env.define("skip".to_string(), Value::NativeFunction("skip".to_string()));
         self.env.define("windows".to_string(), Value::NativeFunction("windows".to_string()));
 
+        // Generators
+        self.env.define("next".to_string(), Value::NativeFunction("next".to_string()));
+
         // Array generation functions
         self.env.define("range".to_string(), Value::NativeFunction("range".to_string()));
 
         // String formatting functions
         self.env.define("format".to_string(), Value::NativeFunction("format".to_string()));
+        self.env.define("print_f".to_string(), Value::NativeFunction("print_f".to_string()));
 
         // Dict functions
+        self.env.define("ordmap".to_string(), Value::NativeFunction("ordmap".to_string()));
         self.env.define("keys".to_string(), Value::NativeFunction("keys".to_string()));
         self.env.define("values".to_string(), Value::NativeFunction("values".to_string()));
         self.env.define("items".to_string(), Value::NativeFunction("items".to_string()));
@@ -1031,6 +1478,10 @@ impl Interpreter {
         self.env
             .define("get_default".to_string(), Value::NativeFunction("get_default".to_string()));
 
+        // Nested data access
+        self.env.define("get_path".to_string(), Value::NativeFunction("get_path".to_string()));
+        self.env.define("set_path".to_string(), Value::NativeFunction("set_path".to_string()));
+
         // I/O functions
         self.env.define("input".to_string(), Value::NativeFunction("input".to_string()));
 
@@ -1040,6 +1491,8 @@ impl Interpreter {
             .define("parse_float".to_string(), Value::NativeFunction("parse_float".to_string()));
         self.env.define("to_int".to_string(), Value::NativeFunction("to_int".to_string()));
         self.env.define("to_float".to_string(), Value::NativeFunction("to_float".to_string()));
+        self.env.define("int".to_string(), Value::NativeFunction("to_int".to_string()));
+        self.env.define("float".to_string(), Value::NativeFunction("to_float".to_string()));
         self.env.define("to_string".to_string(), Value::NativeFunction("to_string".to_string()));
         self.env.define("str".to_string(), Value::NativeFunction("to_string".to_string()));
         self.env.define("to_bool".to_string(), Value::NativeFunction("to_bool".to_string()));
@@ -1061,12 +1514,15 @@ impl Interpreter {
         self.env.define("is_null".to_string(), Value::NativeFunction("is_null".to_string()));
         self.env
             .define("is_function".to_string(), Value::NativeFunction("is_function".to_string()));
+        self.env.define("implements".to_string(), Value::NativeFunction("implements".to_string()));
 
         // Assert & Debug functions
         self.env.define("assert".to_string(), Value::NativeFunction("assert".to_string()));
         self.env.define("debug".to_string(), Value::NativeFunction("debug".to_string()));
 
         // File I/O functions
+        self.env.define("fs".to_string(), builtins::fs_module_value());
+        self.env.define("io".to_string(), builtins::io_module_value());
         self.env.define("read_file".to_string(), Value::NativeFunction("read_file".to_string()));
         self.env.define(
             "read_file_lossy".to_string(),
@@ -1125,8 +1581,10 @@ impl Interpreter {
             "io_copy_range".to_string(),
             Value::NativeFunction("io_copy_range".to_string()),
         );
+        self.env.define("io_open".to_string(), Value::NativeFunction("io_open".to_string()));
 
         // JSON functions
+        self.env.define("json".to_string(), builtins::json_module_value());
         self.env.define("parse_json".to_string(), Value::NativeFunction("parse_json".to_string()));
         self.env.define("to_json".to_string(), Value::NativeFunction("to_json".to_string()));
         self.env.define(
@@ -1184,7 +1642,7 @@ impl Interpreter {
             "current_timestamp".to_string(),
             Value::NativeFunction("current_timestamp".to_string()),
         );
-        self.env.define("time".to_string(), Value::NativeFunction("current_timestamp".to_string()));
+        self.env.define("time".to_string(), builtins::time_module_value());
         self.env.define(
             "performance_now".to_string(),
             Value::NativeFunction("performance_now".to_string()),
@@ -1199,6 +1657,28 @@ impl Interpreter {
         self.env
             .define("format_date".to_string(), Value::NativeFunction("format_date".to_string()));
         self.env.define("parse_date".to_string(), Value::NativeFunction("parse_date".to_string()));
+        self.env.define("time_now".to_string(), Value::NativeFunction("time_now".to_string()));
+        self.env.define(
+            "time_monotonic".to_string(),
+            Value::NativeFunction("time_monotonic".to_string()),
+        );
+        self.env
+            .define("time_format".to_string(), Value::NativeFunction("time_format".to_string()));
+        self.env.define("time_parse".to_string(), Value::NativeFunction("time_parse".to_string()));
+        self.env.define("time_parts".to_string(), Value::NativeFunction("time_parts".to_string()));
+        self.env.define(
+            "time_add_days".to_string(),
+            Value::NativeFunction("time_add_days".to_string()),
+        );
+        self.env.define(
+            "time_add_months".to_string(),
+            Value::NativeFunction("time_add_months".to_string()),
+        );
+        self.env.define("time_diff".to_string(), Value::NativeFunction("time_diff".to_string()));
+        self.env.define(
+            "time_duration".to_string(),
+            Value::NativeFunction("time_duration".to_string()),
+        );
 
         // System operation functions
         self.env.define("env".to_string(), Value::NativeFunction("env".to_string()));
@@ -1253,6 +1733,7 @@ impl Interpreter {
         );
 
         // Regular expression functions
+        self.env.define("regex".to_string(), builtins::regex_module_value());
         self.env
             .define("regex_match".to_string(), Value::NativeFunction("regex_match".to_string()));
         self.env.define(
@@ -1267,6 +1748,7 @@ impl Interpreter {
             .define("regex_split".to_string(), Value::NativeFunction("regex_split".to_string()));
 
         // HTTP client functions
+        self.env.define("http".to_string(), builtins::http_module_value());
         self.env.define("http_get".to_string(), Value::NativeFunction("http_get".to_string()));
         self.env
             .define("http_request".to_string(), Value::NativeFunction("http_request".to_string()));
@@ -1427,6 +1909,9 @@ impl Interpreter {
 
         // Concurrency functions
         self.env.define("channel".to_string(), Value::NativeFunction("channel".to_string()));
+        self.env.define("chan".to_string(), Value::NativeFunction("chan".to_string()));
+        self.env.define("wait_group".to_string(), Value::NativeFunction("wait_group".to_string()));
+        self.env.define("select".to_string(), Value::NativeFunction("select".to_string()));
         self.env.define("shared_set".to_string(), Value::NativeFunction("shared_set".to_string()));
         self.env.define("shared_get".to_string(), Value::NativeFunction("shared_get".to_string()));
         self.env.define("shared_has".to_string(), Value::NativeFunction("shared_has".to_string()));
@@ -1503,6 +1988,18 @@ impl Interpreter {
             "assert_contains".to_string(),
             Value::NativeFunction("assert_contains".to_string()),
         );
+        self.env.define("assert_eq".to_string(), Value::NativeFunction("assert_eq".to_string()));
+        self.env.define(
+            "assert_raises".to_string(),
+            Value::NativeFunction("assert_raises".to_string()),
+        );
+        self.env.define("fail".to_string(), Value::NativeFunction("fail".to_string()));
+        self.env.define("bench".to_string(), Value::NativeFunction("bench".to_string()));
+
+        // Function decorators
+        self.env.define("memo".to_string(), Value::NativeFunction("memo".to_string()));
+        self.env.define("timed".to_string(), Value::NativeFunction("timed".to_string()));
+        self.env.define("retry".to_string(), Value::NativeFunction("retry".to_string()));
 
         // Image processing functions
         self.env.define("load_image".to_string(), Value::NativeFunction("load_image".to_string()));
@@ -1596,6 +2093,39 @@ impl Interpreter {
         self.source_lines = content.lines().map(|s| s.to_string()).collect();
     }
 
+    /// Source position of the statement that raised the current error, when the program
+    /// was parsed `with_source_positions`.
+    pub fn error_source_position(&self) -> Option<(usize, usize)> {
+        self.error_position.map(|(position, _)| position)
+    }
+
+    /// Call stack (outermost first) at the statement that raised the current error. Empty
+    /// when the error was raised outside any function call.
+    pub fn error_stack_trace(&self) -> Vec<StackFrame> {
+        self.error_trace.clone()
+    }
+
+    /// Unwind the active frames into a stack trace for an error raised at `position`.
+    ///
+    /// Each frame reports the statement it is executing: the innermost frame's is
+    /// `position`, and every other frame's is the call site of the frame above it.
+    fn capture_stack_trace(&self, position: (usize, usize)) -> Vec<StackFrame> {
+        let Some(outermost) = self.active_frames.first() else {
+            return Vec::new();
+        };
+
+        let mut trace = Vec::with_capacity(self.active_frames.len() + 1);
+        trace.push(StackFrame::new("<main>", outermost.call_site));
+        for (index, frame) in self.active_frames.iter().enumerate() {
+            let executing = match self.active_frames.get(index + 1) {
+                Some(callee) => callee.call_site,
+                None => Some(position),
+            };
+            trace.push(StackFrame::new(frame.name.clone(), executing));
+        }
+        trace
+    }
+
     /// Reports a runtime error with source location
     #[allow(dead_code)]
     fn report_error(&self, error: RuffError) {
@@ -1618,9 +2148,59 @@ impl Interpreter {
         self.output = Some(output);
     }
 
+    /// Routes `print` and `eprint` output to `sink` instead of stdout and stderr
+    #[allow(dead_code)] // Used by the embedding API and the WebAssembly exports
+    pub fn set_output_sink(&mut self, sink: Arc<dyn OutputSink>) {
+        self.output = Some(sink);
+    }
+
     /// Helper function to call a user-defined function with given arguments
     /// Used by higher-order functions like map, filter, reduce
     pub(crate) fn call_user_function(&mut self, func: &Value, args: &[Value]) -> Value {
+        match func {
+            Value::Function(_, body, _) | Value::GeneratorDef(_, body) if body.has_signature() => {
+                self.call_user_function_with_args(func, CallArgs::positional(args.to_vec()))
+            }
+            _ => self.call_arranged_user_function(func, args),
+        }
+    }
+
+    /// Call a user-defined function with named arguments, filling in its parameter
+    /// defaults and rest parameter first.
+    pub(crate) fn call_user_function_with_args(&mut self, func: &Value, args: CallArgs) -> Value {
+        let arranged = match func {
+            Value::Function(params, body, _) | Value::GeneratorDef(params, body) => {
+                Self::arrange_function_args("<anonymous function>", params, body, 0, args)
+            }
+            _ if args.named.is_empty() => Ok(args.positional),
+            _ => Err(Value::Error(
+                "Named arguments can only be passed to user-defined functions".to_string(),
+            )),
+        };
+        match arranged {
+            Ok(arranged) => self.call_arranged_user_function(func, &arranged),
+            Err(error) => error,
+        }
+    }
+
+    /// Call a function wrapped by `memo`, `timed`, or `retry(n)`. An error the wrapper
+    /// lets through is left pending, as it would be from the function itself.
+    fn call_decorated_value(&mut self, decorated: &Decorated, args: &[Value]) -> Value {
+        let result = Self::call_decorated_impl(self, decorated, args);
+        if Self::is_error_value(&result) {
+            self.return_value = Some(result.clone());
+        }
+        result
+    }
+
+    /// Environment captured by an anonymous function or struct method defined here.
+    fn capture_closure_env(&self, params: &[String], body: &[Stmt]) -> Arc<Mutex<Environment>> {
+        let free_variables = Compiler::closure_free_variables(body, params);
+        Arc::new(Mutex::new(self.env.capture(&free_variables, None)))
+    }
+
+    /// Call a function whose arguments already line up one-to-one with its parameters.
+    fn call_arranged_user_function(&mut self, func: &Value, args: &[Value]) -> Value {
         match func {
             Value::GeneratorDef(params, body) => {
                 let arity = Self::function_arity("<anonymous generator>", params);
@@ -1629,25 +2209,7 @@ impl Interpreter {
                 }
 
                 // Calling a generator function returns a Generator instance
-                // Create a new environment for the generator
-                let mut gen_env = self.env.clone();
-                gen_env.push_scope();
-
-                // Bind parameters to arguments
-                for (i, param) in params.iter().enumerate() {
-                    if let Some(arg) = args.get(i) {
-                        gen_env.define(param.clone(), arg.clone());
-                    }
-                }
-
-                // Return a Generator instance
-                Value::Generator {
-                    params: params.clone(),
-                    body: body.clone(),
-                    env: Arc::new(Mutex::new(gen_env)),
-                    pc: 0,
-                    is_exhausted: false,
-                }
+                self.start_generator(params, body, args)
             }
             Value::Function(params, body, captured_env) => {
                 let arity = Self::function_arity("<anonymous function>", params);
@@ -1768,6 +2330,12 @@ impl Interpreter {
                     result
                 }
             }
+            Value::NativeFunction(name) => self.call_native_function_impl(name, args),
+            Value::Decorated(decorated) => self.call_decorated_value(decorated, args),
+            Value::StructDef { name, field_names, .. } => {
+                Value::construct_struct(name, field_names, args.to_vec())
+                    .unwrap_or_else(Value::Error)
+            }
             _ => Value::Int(0),
         }
     }
@@ -1933,12 +2501,71 @@ impl Interpreter {
         Some(params)
     }
 
+    /// Runs `http.serve`; every request's handler gets a fresh interpreter over a copy of
+    /// this environment, the same isolation async function bodies use.
+    fn serve_http_module(&mut self, args: &[Value]) -> Value {
+        let env = self.env.clone();
+        let capability_policy = self.capability_policy.clone();
+        let execution_budget = self.execution_budget.clone();
+        let host_functions = self.host_functions.clone();
+        Self::serve_http_module_impl(args, |handler| {
+            let env = env.clone();
+            let capability_policy = capability_policy.clone();
+            let execution_budget = execution_budget.clone();
+            let host_functions = host_functions.clone();
+            Box::new(move |args| {
+                let mut worker = Interpreter::with_capability_policy(capability_policy);
+                worker.set_execution_budget(execution_budget.clone());
+                worker.host_functions = host_functions;
+                worker.env = env;
+                worker.call_user_function(&handler, &args)
+            })
+        })
+    }
+
+    fn call_router_method(
+        &mut self,
+        router: &Arc<RouterState>,
+        method: &str,
+        args: &[Value],
+    ) -> Value {
+        Self::call_router_method_impl(router, method, args, |handler, request| {
+            self.call_user_function(&handler, &[request])
+        })
+    }
+
+    /// Shared `http.serve` loop used by both the interpreter and the VM.
+    pub(crate) fn serve_http_module_impl(
+        args: &[Value],
+        spawn_worker: impl FnMut(Value) -> PromiseCallback,
+    ) -> Value {
+        native_functions::http::serve_http_module(args, spawn_worker)
+    }
+
+    /// Shared `Router` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_router_method_impl(
+        router: &Arc<RouterState>,
+        method: &str,
+        args: &[Value],
+        call_handler: impl FnOnce(Value, Value) -> Value,
+    ) -> Value {
+        native_functions::http::call_router_method(router, method, args, call_handler)
+    }
+
+    /// Shared `fs.walk` traversal used by both the interpreter and the VM.
+    pub(crate) fn walk_fs_impl(
+        root: &str,
+        visit: &mut dyn FnMut(Value) -> Value,
+    ) -> Result<i64, Value> {
+        native_functions::filesystem::walk_fs(root, visit)
+    }
+
     /// Starts an HTTP server with registered routes
     fn start_http_server(
         &mut self,
         host: &str,
         port: u16,
-        routes: Vec<(String, String, Value)>,
+        mut routes: Vec<(String, String, Value)>,
     ) -> Value {
         use tiny_http::{Response, Server};
         if let Err(error) =
@@ -1956,9 +2583,16 @@ impl Interpreter {
 
         println!("Server listening on http://{}:{}", host, port);
         println!("Press Ctrl+C to stop");
+        let hot_reload = crate::watch::enable_hot_reload();
 
         // Main server loop
         for mut request in server.incoming_requests() {
+            if hot_reload {
+                let reloads = self.module_loader.reload_changed_modules();
+                if !reloads.is_empty() {
+                    crate::module::apply_module_reloads(&reloads, &mut self.env, &mut routes);
+                }
+            }
             let method = request.method().to_string();
             let request_url = request.url().to_string();
             let (url_path, query_params, decoded_query_params, raw_query) =
@@ -2188,10 +2822,45 @@ impl Interpreter {
     /// This is used both by call_native_function (after evaluating Expr args)
     /// and by the VM (which already has Value args)
     pub fn call_native_function_impl(&mut self, name: &str, arg_values: &[Value]) -> Value {
+        if let Some(callback) = self.host_functions.get(name) {
+            return callback(arg_values).unwrap_or_else(Value::Error);
+        }
+        if Self::renders_with_to_string_hook(name)
+            && arg_values.iter().any(|value| matches!(value, Value::Struct { .. }))
+        {
+            let rendered: Vec<Value> = arg_values
+                .iter()
+                .map(|value| match value {
+                    Value::Struct { .. } => Value::Str(Arc::new(self.display_string(value))),
+                    other => other.clone(),
+                })
+                .collect();
+            return native_functions::call_native_function(self, name, &rendered);
+        }
+
         // Delegate to the native_functions module dispatcher
         native_functions::call_native_function(self, name, arg_values)
     }
 
+    /// Natives that render their arguments for display and so honor a struct's
+    /// `to_string` method.
+    pub(crate) fn renders_with_to_string_hook(name: &str) -> bool {
+        matches!(
+            Self::canonical_native_function_name(name),
+            "print" | "println" | "eprint" | "to_string" | "str" | "format" | "print_f"
+        )
+    }
+
+    /// Renders a value for display, calling its struct's `to_string` method when
+    /// the struct defines one.
+    fn display_string(&mut self, value: &Value) -> String {
+        match self.try_call_unary_operator_method(value, crate::ast::operator_methods::TO_STRING) {
+            Some(Value::Str(text)) => text.as_ref().clone(),
+            Some(other) => Self::stringify_value(&other),
+            None => Self::stringify_value(value),
+        }
+    }
+
     /// Helper method to check if two values are equal (for Set operations)
     fn values_equal(a: &Value, b: &Value) -> bool {
         Value::equals(a, b)
@@ -2205,6 +2874,150 @@ impl Interpreter {
         matches!(value, Value::Error(_) | Value::ErrorObject { .. })
     }
 
+    /// Settles an `assert_raises` call once its callable has run. `raised` is the message
+    /// of the error the callable produced; the assertion passes with that message when an
+    /// error was raised and it contains the optional `expected` substring.
+    pub(crate) fn assert_raises_outcome(raised: Option<String>, expected: Option<&Value>) -> Value {
+        let Some(message) = raised else {
+            return Value::Error("Assertion failed: expected an error to be raised".to_string());
+        };
+        match expected {
+            None => Value::Str(Arc::new(message)),
+            Some(Value::Str(needle)) if message.contains(needle.as_str()) => {
+                Value::Str(Arc::new(message))
+            }
+            Some(Value::Str(needle)) => Value::Error(format!(
+                "Assertion failed: expected error containing {:?}, got {:?}",
+                needle.as_str(),
+                message
+            )),
+            Some(_) => Value::Error("assert_raises expected message must be a string".to_string()),
+        }
+    }
+
+    /// Drives a `bench(name, fn, opts?)` call. `call` invokes the benchmarked function once
+    /// and returns its error message if it raised. `opts.warmup` untimed calls (default 2)
+    /// precede `opts.iterations` timed ones (default 10); the result is a dict of the
+    /// sample statistics in nanoseconds plus `ops_per_sec`.
+    pub(crate) fn run_bench(
+        args: &[Value],
+        mut call: impl FnMut(&Value) -> Result<(), String>,
+    ) -> Value {
+        let name = match args.first() {
+            Some(Value::Str(name)) => name.as_ref().clone(),
+            _ => {
+                return Value::Error(
+                    "bench expects a string name as its first argument".to_string(),
+                )
+            }
+        };
+        let mut warmup = 2;
+        let mut iterations = 10;
+        if let Some(options) = args.get(2) {
+            let lookup = |key: &str| match options {
+                Value::Dict(entries) => Ok(entries.get(key).cloned()),
+                Value::FixedDict { keys, values } => Ok(keys
+                    .iter()
+                    .position(|candidate| candidate.as_ref() == key)
+                    .map(|index| values[index].clone())),
+                _ => Err(Value::Error("bench options must be a dict".to_string())),
+            };
+            for (key, slot) in [("warmup", &mut warmup), ("iterations", &mut iterations)] {
+                let option = match lookup(key) {
+                    Ok(option) => option,
+                    Err(error) => return error,
+                };
+                match option.as_ref() {
+                    None => {}
+                    Some(Value::Int(count)) if *count >= 0 => *slot = *count as usize,
+                    Some(_) => {
+                        return Value::Error(format!(
+                            "bench option '{}' must be a non-negative integer",
+                            key
+                        ))
+                    }
+                }
+            }
+        }
+        if iterations == 0 {
+            return Value::Error("bench option 'iterations' must be at least 1".to_string());
+        }
+
+        let func = &args[1];
+        for _ in 0..warmup {
+            if let Err(message) = call(func) {
+                return Value::Error(format!("bench '{}' failed during warmup: {}", name, message));
+            }
+        }
+        let mut samples = Vec::with_capacity(iterations);
+        for _ in 0..iterations {
+            let started = std::time::Instant::now();
+            if let Err(message) = call(func) {
+                return Value::Error(format!("bench '{}' failed: {}", name, message));
+            }
+            samples.push(started.elapsed());
+        }
+
+        let Some(stats) = crate::benchmarks::Statistics::from_samples(&samples) else {
+            return Value::Error("bench collected no samples".to_string());
+        };
+        let nanos = |duration: std::time::Duration| Value::Int(duration.as_nanos() as i64);
+        let mut report = DictMap::default();
+        report.insert("name".into(), Value::Str(Arc::new(name)));
+        report.insert("iterations".into(), Value::Int(stats.samples as i64));
+        report.insert("warmup".into(), Value::Int(warmup as i64));
+        report.insert("mean_ns".into(), nanos(stats.mean));
+        report.insert("median_ns".into(), nanos(stats.median));
+        report.insert("stddev_ns".into(), nanos(stats.stddev));
+        report.insert("min_ns".into(), nanos(stats.min));
+        report.insert("max_ns".into(), nanos(stats.max));
+        report.insert("ops_per_sec".into(), Value::Float(stats.ops_per_sec()));
+        Value::Dict(Arc::new(report))
+    }
+
+    /// Picks the body of the first `match` case whose pattern matches and whose guard
+    /// holds, binding the pattern's names in the current scope. Falls back to `default`.
+    fn select_match_arm<'a>(
+        &mut self,
+        value: &Value,
+        cases: &'a [MatchCase],
+        default: &'a Option<Vec<Stmt>>,
+    ) -> Result<Option<&'a [Stmt]>, Value> {
+        for case in cases {
+            let mut bindings = Vec::new();
+            if !Value::match_pattern(&case.pattern, value, &mut bindings) {
+                continue;
+            }
+            for (name, bound) in bindings {
+                self.env.define(name, bound);
+            }
+            if let Some(guard) = &case.guard {
+                let passed = self.eval_expr(guard);
+                if Self::is_error_value(&passed) {
+                    return Err(passed);
+                }
+                if !passed.is_truthy() {
+                    continue;
+                }
+            }
+            return Ok(Some(&case.body));
+        }
+        Ok(default.as_deref())
+    }
+
+    /// A `match` expression evaluates to its arm's trailing expression statement, or null.
+    fn eval_match_expr_body(&mut self, body: &[Stmt]) -> Value {
+        let Some((Stmt::ExprStmt(result), leading)) = body.split_last() else {
+            self.eval_stmts(body);
+            return Value::Null;
+        };
+        self.eval_stmts(leading);
+        if self.return_value.is_some() || self.control_flow != ControlFlow::None {
+            return Value::Null;
+        }
+        self.eval_expr(result)
+    }
+
     fn set_return_if_error(&mut self, value: &Value) -> bool {
         if Self::is_error_value(value) {
             self.return_value = Some(value.clone());
@@ -2223,7 +3036,7 @@ impl Interpreter {
             Value::Array(_) => "array",
             Value::Dict(_) => "dict",
             Value::Struct { .. } => "struct",
-            Value::Function(..) => "function",
+            Value::Function(..) | Value::Decorated(_) => "function",
             Value::NativeFunction(_) => "native_function",
             Value::Null => "null",
             Value::Error(_) | Value::ErrorObject { .. } => "error",
@@ -2253,6 +3066,12 @@ impl Interpreter {
     }
 
     fn index_value(object: &Value, index: &Value) -> Value {
+        match Value::map_key_index(object, index) {
+            Some(Ok(key)) => return Self::index_value(object, &key),
+            Some(Err(message)) => return Value::Error(message),
+            None => {}
+        }
+
         match (object, index) {
             (Value::Array(arr), Value::Int(i)) => {
                 let idx = if *i < 0 { (arr.len() as i64) + *i } else { *i };
@@ -2264,20 +3083,6 @@ impl Interpreter {
                         .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
                 }
             }
-            (Value::Array(arr), Value::Float(i)) => {
-                if !i.is_finite() {
-                    return Value::Error("Invalid index operation".to_string());
-                }
-                let idx = *i as i64;
-                let resolved = if idx < 0 { (arr.len() as i64) + idx } else { idx };
-                if resolved < 0 {
-                    Value::Error(format!("Index out of bounds: {}", i))
-                } else {
-                    arr.get(resolved as usize)
-                        .cloned()
-                        .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
-                }
-            }
             (Value::Str(s), Value::Int(i)) => {
                 let idx = if *i < 0 { (s.chars().count() as i64) + *i } else { *i };
                 if idx < 0 {
@@ -2289,21 +3094,6 @@ impl Interpreter {
                         .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
                 }
             }
-            (Value::Str(s), Value::Float(i)) => {
-                if !i.is_finite() {
-                    return Value::Error("Invalid index operation".to_string());
-                }
-                let idx = *i as i64;
-                let resolved = if idx < 0 { (s.chars().count() as i64) + idx } else { idx };
-                if resolved < 0 {
-                    Value::Error(format!("Index out of bounds: {}", i))
-                } else {
-                    s.chars()
-                        .nth(resolved as usize)
-                        .map(|c| Value::Str(Arc::new(c.to_string())))
-                        .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
-                }
-            }
             (Value::Bytes(bytes), Value::Int(i)) => {
                 let idx = if *i < 0 { (bytes.len() as i64) + *i } else { *i };
                 if idx < 0 {
@@ -2316,22 +3106,6 @@ impl Interpreter {
                         .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
                 }
             }
-            (Value::Bytes(bytes), Value::Float(i)) => {
-                if !i.is_finite() {
-                    return Value::Error("Invalid index operation".to_string());
-                }
-                let idx = *i as i64;
-                let resolved = if idx < 0 { (bytes.len() as i64) + idx } else { idx };
-                if resolved < 0 {
-                    Value::Error(format!("Index out of bounds: {}", i))
-                } else {
-                    bytes
-                        .get(resolved as usize)
-                        .copied()
-                        .map(|byte| Value::Int(byte as i64))
-                        .unwrap_or_else(|| Value::Error(format!("Index out of bounds: {}", i)))
-                }
-            }
             (Value::Dict(map), Value::Str(key)) => map
                 .get(key.as_str())
                 .cloned()
@@ -2340,8 +3114,27 @@ impl Interpreter {
                 .get(key.to_string().as_str())
                 .cloned()
                 .unwrap_or_else(|| Value::Error(format!("Missing map key: {}", key))),
-            _ => Value::Error("Invalid index operation".to_string()),
+            (Value::OrderedDict(map), Value::Str(key)) => map
+                .get(key.as_str())
+                .cloned()
+                .unwrap_or_else(|| Value::Error(format!("Missing map key: {:?}", key.as_ref()))),
+            (Value::OrderedDict(map), Value::Int(key)) => map
+                .get(key.to_string().as_str())
+                .cloned()
+                .unwrap_or_else(|| Value::Error(format!("Missing map key: {}", key))),
+            _ => Value::Error(Value::invalid_index_error(index)),
+        }
+    }
+
+    /// Key a dict assignment stores under: composite keys by their stored key text,
+    /// anything else as it prints.
+    fn dict_assignment_key(index: &Value) -> Result<String, String> {
+        if index.is_composite_key() {
+            return index.composite_key_text().map(|key| key.to_string());
         }
+        let key = Self::stringify_value(index);
+        Value::check_string_key(&key)?;
+        Ok(key)
     }
 
     fn assign_index(&mut self, object: &Expr, index: &Expr, value: &Value) -> Value {
@@ -2363,7 +3156,6 @@ impl Interpreter {
             Value::Array(arr) => {
                 let idx = match &index_clone {
                     Value::Int(i) => *i,
-                    Value::Float(f) if f.is_finite() => *f as i64,
                     _ => {
                         assignment_error = Some(
                             "Invalid index assignment: unsupported array index type".to_string(),
@@ -2381,10 +3173,18 @@ impl Interpreter {
 
                 arr_mut[resolved as usize] = value_clone.clone();
             }
-            Value::Dict(dict) => {
-                let key = Self::stringify_value(&index_clone);
-                Arc::make_mut(dict).insert(key.into(), value_clone.clone());
-            }
+            Value::Dict(dict) => match Self::dict_assignment_key(&index_clone) {
+                Ok(key) => {
+                    Arc::make_mut(dict).insert(key.into(), value_clone.clone());
+                }
+                Err(message) => assignment_error = Some(message),
+            },
+            Value::OrderedDict(map) => match Value::dict_key(&index_clone) {
+                Ok(key) => {
+                    Arc::make_mut(map).insert(key, value_clone.clone());
+                }
+                Err(message) => assignment_error = Some(message),
+            },
             _ => {
                 assignment_error = Some("Invalid index assignment".to_string());
             }
@@ -2448,7 +3248,6 @@ impl Interpreter {
                         Value::Array(arr) => {
                             let idx = match &index_clone {
                                 Value::Int(i) => *i,
-                                Value::Float(f) if f.is_finite() => *f as i64,
                                 _ => {
                                     assignment_error = Some(
                                         "Invalid index assignment: unsupported array index type"
@@ -2475,7 +3274,13 @@ impl Interpreter {
                             }
                         }
                         Value::Dict(dict) => {
-                            let key = Self::stringify_value(&index_clone);
+                            let key = match Self::dict_assignment_key(&index_clone) {
+                                Ok(key) => key,
+                                Err(message) => {
+                                    assignment_error = Some(message);
+                                    return;
+                                }
+                            };
                             if let Some(Value::Struct { name: _, fields }) =
                                 Arc::make_mut(dict).get_mut(key.as_str())
                             {
@@ -2508,10 +3313,9 @@ impl Interpreter {
 
     fn unary_op_value(&self, op: &str, value: &Value) -> Value {
         match (op, value) {
-            ("-", Value::Int(n)) => match n.checked_neg() {
-                Some(result) => Value::Int(result),
-                None => Value::Error(format!("Integer overflow: -({})", n)),
-            },
+            ("-", Value::Int(_) | Value::BigInt(_)) => {
+                Value::negate_int(value).unwrap_or_else(|| Self::invalid_unary_operation(op, value))
+            }
             ("-", Value::Float(n)) => Value::Float(-n),
             ("!", Value::Bool(b)) => Value::Bool(!b),
             _ => Self::invalid_unary_operation(op, value),
@@ -2540,12 +3344,19 @@ impl Interpreter {
 
         match (left, right) {
             (Value::Int(a), Value::Int(b)) => match op {
-                "+" | "-" | "*" | "/" | "%" => match Value::checked_int_arithmetic(*a, op, *b) {
-                    Ok(result) => Value::Int(result),
+                "+" | "-" | "*" | "/" | "%" => match Value::promoting_int_arithmetic(*a, op, *b) {
+                    Ok(result) => result,
                     Err(error) => Value::Error(error),
                 },
                 _ => Self::invalid_binary_operation(op, left, right),
             },
+            (Value::BigInt(_), _) | (_, Value::BigInt(_)) => {
+                match Value::big_int_binary_op(left, op, right) {
+                    Some(Ok(result)) => result,
+                    Some(Err(error)) => Value::Error(error),
+                    None => Self::invalid_binary_operation(op, left, right),
+                }
+            }
             (Value::Float(a), Value::Float(b)) => match op {
                 "+" | "-" | "*" | "/" | "%" => match Value::checked_float_arithmetic(*a, op, *b) {
                     Ok(result) => Value::Float(result),
@@ -2619,9 +3430,175 @@ impl Interpreter {
                 CallableArity::exact("__vm_for_iterable", vec!["value".to_string()])
             }
             "dict" => CallableArity::exact("dict", vec![]),
-            "error" => CallableArity::exact("error", vec!["message".to_string()]),
+            "ordmap" => CallableArity::range("ordmap", 0, 1, vec!["pairs".to_string()]),
+            "wait_group" => CallableArity::exact("wait_group", vec![]),
+            "time_now" | "time_monotonic" => CallableArity::exact(name, vec![]),
+            "time_format" => CallableArity::range(
+                name,
+                1,
+                3,
+                vec!["timestamp".to_string(), "layout".to_string(), "zone".to_string()],
+            ),
+            "time_parse" => CallableArity::range(
+                name,
+                1,
+                3,
+                vec!["text".to_string(), "layout".to_string(), "zone".to_string()],
+            ),
+            "time_parts" => {
+                CallableArity::range(name, 1, 2, vec!["timestamp".to_string(), "zone".to_string()])
+            }
+            "time_add_days" => CallableArity::range(
+                name,
+                2,
+                3,
+                vec!["timestamp".to_string(), "days".to_string(), "zone".to_string()],
+            ),
+            "time_add_months" => CallableArity::range(
+                name,
+                2,
+                3,
+                vec!["timestamp".to_string(), "months".to_string(), "zone".to_string()],
+            ),
+            "time_diff" => CallableArity::range(
+                name,
+                2,
+                3,
+                vec!["start".to_string(), "end".to_string(), "unit".to_string()],
+            ),
+            "time_duration" => CallableArity::exact(name, vec!["text".to_string()]),
+            "http.get" | "http.get_json" | "http.delete" => {
+                CallableArity::range(name, 1, 2, vec!["url".to_string(), "options".to_string()])
+            }
+            "http.post" | "http.post_json" | "http.put" | "http.patch" => CallableArity::range(
+                name,
+                2,
+                4,
+                vec![
+                    "url".to_string(),
+                    "body".to_string(),
+                    "headers".to_string(),
+                    "options".to_string(),
+                ],
+            ),
+            "http.request" => CallableArity::range(
+                name,
+                2,
+                3,
+                vec!["method".to_string(), "url".to_string(), "options".to_string()],
+            ),
+            "http.serve" => {
+                CallableArity::exact(name, vec!["addr".to_string(), "handler".to_string()])
+            }
+            "http.router" => CallableArity::exact(name, vec![]),
+            "json.parse" => CallableArity::exact(name, vec!["text".to_string()]),
+            "json.stringify" => {
+                CallableArity::range(name, 1, 2, vec!["value".to_string(), "indent".to_string()])
+            }
+            "fs.read" | "fs.exists" | "fs.stat" | "fs.mkdir_all" => {
+                CallableArity::exact(name, vec!["path".to_string()])
+            }
+            "fs.write" | "fs.append" => {
+                CallableArity::exact(name, vec!["path".to_string(), "data".to_string()])
+            }
+            "fs.walk" => {
+                CallableArity::exact(name, vec!["dir".to_string(), "callback".to_string()])
+            }
+            "fs.glob" => CallableArity::exact(name, vec!["pattern".to_string()]),
+            "regex.match"
+            | "regex.find"
+            | "regex.find_all"
+            | "regex.captures"
+            | "regex.captures_all"
+            | "regex.named_captures"
+            | "regex.split" => {
+                CallableArity::exact(name, vec!["text".to_string(), "pattern".to_string()])
+            }
+            "regex.replace" => CallableArity::exact(
+                name,
+                vec!["text".to_string(), "pattern".to_string(), "replacement".to_string()],
+            ),
+            "regex.escape" => CallableArity::exact(name, vec!["text".to_string()]),
+            "strings.builder" => CallableArity::range(name, 0, 1, vec!["initial".to_string()]),
+            "ffi.load" => CallableArity::exact(name, vec!["path".to_string()]),
+            "ffi.alloc" => CallableArity::exact(name, vec!["size".to_string()]),
+            "ffi.free" | "ffi.read_pointer" | "ffi.read_string" => {
+                CallableArity::exact(name, vec!["pointer".to_string()])
+            }
+            "proc.run" | "proc.spawn" => CallableArity::range(
+                name,
+                1,
+                3,
+                vec!["cmd".to_string(), "args".to_string(), "options".to_string()],
+            ),
+            "proc.pipeline" => CallableArity::range(
+                name,
+                1,
+                2,
+                vec!["commands".to_string(), "options".to_string()],
+            ),
+            "net.dial" | "net.listen" => CallableArity::range(
+                name,
+                2,
+                3,
+                vec!["network".to_string(), "address".to_string(), "options".to_string()],
+            ),
+            "os.env" => {
+                CallableArity::range(name, 0, 2, vec!["name".to_string(), "default".to_string()])
+            }
+            "os.exit" => CallableArity::range(name, 0, 1, vec!["code".to_string()]),
+            "flags.parse" => {
+                CallableArity::range(name, 1, 2, vec!["spec".to_string(), "argv".to_string()])
+            }
+            "flags.help" => CallableArity::exact(name, vec!["spec".to_string()]),
+            "sqlite.open" => CallableArity::exact(name, vec!["path".to_string()]),
+            "sync.mutex" | "sync.map" => CallableArity::exact(name, vec![]),
+            "sync.atomic" => CallableArity::range(name, 0, 1, vec!["initial".to_string()]),
+            "sync.atomic_add" => {
+                CallableArity::exact(name, vec!["counter".to_string(), "delta".to_string()])
+            }
+            "sync.atomic_load" => CallableArity::exact(name, vec!["counter".to_string()]),
+            "sync.atomic_store" => {
+                CallableArity::exact(name, vec!["counter".to_string(), "value".to_string()])
+            }
+            "iter.range" => CallableArity::range(
+                name,
+                1,
+                3,
+                vec!["start".to_string(), "end".to_string(), "step".to_string()],
+            ),
+            "iter.from" | "iter.enumerate" | "iter.collect" => {
+                CallableArity::exact(name, vec!["iterable".to_string()])
+            }
+            "iter.map" => {
+                CallableArity::exact(name, vec!["iterable".to_string(), "func".to_string()])
+            }
+            "iter.filter" => {
+                CallableArity::exact(name, vec!["iterable".to_string(), "predicate".to_string()])
+            }
+            "iter.take" => {
+                CallableArity::exact(name, vec!["iterable".to_string(), "count".to_string()])
+            }
+            "iter.zip" => CallableArity::exact(name, vec!["left".to_string(), "right".to_string()]),
+            "iter.reduce" => CallableArity::exact(
+                name,
+                vec!["iterable".to_string(), "initial".to_string(), "func".to_string()],
+            ),
+            "select" => CallableArity::range(
+                "select",
+                1,
+                2,
+                vec!["channels".to_string(), "timeout_ms".to_string()],
+            ),
+            "error" => CallableArity::range(
+                "error",
+                1,
+                2,
+                vec!["message".to_string(), "payload".to_string()],
+            ),
             "collect" => CallableArity::exact("collect", vec!["iterable".to_string()]),
             "len" => CallableArity::exact("len", vec!["value".to_string()]),
+            "next" => CallableArity::exact("next", vec!["generator".to_string()]),
             "bit_not" => CallableArity::exact("bit_not", vec!["value".to_string()]),
             "bit_and" | "bit_or" | "bit_xor" | "bit_shl" | "bit_shr" => {
                 CallableArity::exact(name, vec!["left".to_string(), "right".to_string()])
@@ -2670,9 +3647,43 @@ impl Interpreter {
                 CallableArity::exact("repeat", vec!["value".to_string(), "count".to_string()])
             }
             "input" => CallableArity::range("input", 0, 1, vec!["prompt".to_string()]),
+            "get_path" => CallableArity::range(
+                "get_path",
+                2,
+                3,
+                vec!["data".to_string(), "path".to_string(), "default".to_string()],
+            ),
+            "set_path" => CallableArity::exact(
+                "set_path",
+                vec!["data".to_string(), "path".to_string(), "value".to_string()],
+            ),
+            "io_open" => CallableArity::range(
+                "io_open",
+                2,
+                3,
+                vec!["path".to_string(), "mode".to_string(), "overwrite".to_string()],
+            ),
             "exit" => CallableArity::range("exit", 0, 1, vec!["code".to_string()]),
             "type" | "type_of" => CallableArity::exact("type", vec!["value".to_string()]),
             "is_truthy" => CallableArity::exact("is_truthy", vec!["value".to_string()]),
+            "implements" => CallableArity::exact(
+                "implements",
+                vec!["value".to_string(), "interface".to_string()],
+            ),
+            "assert_raises" => CallableArity::range(
+                "assert_raises",
+                1,
+                2,
+                vec!["callable".to_string(), "expected".to_string()],
+            ),
+            "bench" => CallableArity::range(
+                "bench",
+                2,
+                3,
+                vec!["name".to_string(), "fn".to_string(), "opts".to_string()],
+            ),
+            "memo" | "timed" => CallableArity::exact(name, vec!["fn".to_string()]),
+            "retry" => CallableArity::exact(name, vec!["attempts".to_string()]),
             "read_file_lossy" => CallableArity::exact("read_file_lossy", vec!["path".to_string()]),
             "Promise.all" => CallableArity::range(
                 "Promise.all",
@@ -2723,6 +3734,41 @@ impl Interpreter {
         Some(metadata)
     }
 
+    /// Splits the array on the right of `a, b := pair` into one value per target.
+    pub(crate) fn unpack_assignment_values(
+        value: &Value,
+        count: usize,
+    ) -> Result<Vec<Value>, String> {
+        match value {
+            Value::Array(items) if items.len() == count => Ok(items.to_vec()),
+            Value::Array(items) => Err(format!(
+                "Multiple assignment has {} targets but the array has {} elements",
+                count,
+                items.len()
+            )),
+            other => Err(format!(
+                "Cannot unpack {} into {} assignment targets",
+                Self::value_type_name(other),
+                count
+            )),
+        }
+    }
+
+    /// Store `val` into an assignment target, returning `Value::Null` or an error value.
+    fn assign_to_target(&mut self, target: &Expr, val: &Value) -> Value {
+        match target {
+            Expr::Identifier(name) => match self.env.assign_checked(name.clone(), val.clone()) {
+                Ok(()) => Value::Null,
+                Err(error) => Value::Error(error),
+            },
+            Expr::IndexAccess { object, index } => {
+                self.assign_index(object.as_ref(), index.as_ref(), val)
+            }
+            Expr::FieldAccess { object, field } => self.assign_member(object.as_ref(), field, val),
+            _ => Value::Error("Invalid assignment target".to_string()),
+        }
+    }
+
     /// Binds a pattern to a value, defining variables as needed
     fn bind_pattern(
         &mut self,
@@ -2740,44 +3786,39 @@ impl Interpreter {
                 // Do nothing - value is discarded
             }
             Pattern::Array { elements, rest } => {
-                // Extract array elements
-                if let Value::Array(arr) = value {
-                    let mut i = 0;
-                    let arr_len = arr.len();
-
-                    // Bind each pattern element
-                    for pattern_elem in elements {
-                        if i < arr_len {
-                            self.bind_pattern(pattern_elem, arr[i].clone(), binding)?;
-                            i += 1;
-                        } else {
-                            // Not enough elements - bind to null
-                            self.bind_pattern(pattern_elem, Value::Null, binding)?;
-                        }
-                    }
+                let Value::Array(arr) = value else {
+                    return Err(format!(
+                        "Cannot destructure {} with an array pattern",
+                        Self::value_type_name(&value)
+                    ));
+                };
 
-                    // Bind rest elements if present
-                    if let Some(rest_name) = rest {
-                        let rest_values: Vec<Value> =
-                            if i < arr_len { arr[i..].to_vec() } else { vec![] };
-                        self.env.define_with_kind_checked(
-                            rest_name.clone(),
-                            Value::Array(Arc::new(rest_values)),
-                            binding,
-                        )?;
-                    }
-                } else {
-                    // Not an array - bind all patterns to null
-                    for pattern_elem in elements {
-                        self.bind_pattern(pattern_elem, Value::Null, binding)?;
-                    }
-                    if let Some(rest_name) = rest {
-                        self.env.define_with_kind_checked(
-                            rest_name.clone(),
-                            Value::Array(Arc::new(vec![])),
-                            binding,
-                        )?;
-                    }
+                // Without a rest element the pattern is strict: lengths must match exactly
+                if rest.is_none() && arr.len() != elements.len() {
+                    return Err(format!(
+                        "Array destructuring expected {} elements but got {}",
+                        elements.len(),
+                        arr.len()
+                    ));
+                }
+                if arr.len() < elements.len() {
+                    return Err(format!(
+                        "Array destructuring expected at least {} elements but got {}",
+                        elements.len(),
+                        arr.len()
+                    ));
+                }
+
+                for (pattern_elem, elem) in elements.iter().zip(arr.iter()) {
+                    self.bind_pattern(pattern_elem, elem.clone(), binding)?;
+                }
+
+                if let Some(rest_name) = rest {
+                    self.env.define_with_kind_checked(
+                        rest_name.clone(),
+                        Value::Array(Arc::new(arr[elements.len()..].to_vec())),
+                        binding,
+                    )?;
                 }
             }
             Pattern::Dict { keys, rest } => {
@@ -2921,18 +3962,35 @@ impl Interpreter {
                             binding,
                         )?;
                     }
-                } else {
-                    // Not a dict - bind all to null
+                } else if let Value::IntDict(map) = value {
                     for key in keys {
-                        self.env.define_with_kind_checked(key.clone(), Value::Null, binding)?;
+                        let val = key
+                            .parse::<i64>()
+                            .ok()
+                            .and_then(|int_key| map.get(&int_key).cloned())
+                            .unwrap_or(Value::Null);
+                        self.env.define_with_kind_checked(key.clone(), val, binding)?;
                     }
+
                     if let Some(rest_name) = rest {
+                        let mut rest_dict = DictMap::default();
+                        for (int_key, value) in map.iter() {
+                            let key = int_key.to_string();
+                            if !keys.iter().any(|existing| existing.as_str() == key.as_str()) {
+                                rest_dict.insert(Arc::from(key.as_str()), value.clone());
+                            }
+                        }
                         self.env.define_with_kind_checked(
                             rest_name.clone(),
-                            Value::Dict(Arc::new(DictMap::default())),
+                            Value::Dict(Arc::new(rest_dict)),
                             binding,
                         )?;
                     }
+                } else {
+                    return Err(format!(
+                        "Cannot destructure {} with a dict pattern",
+                        Self::value_type_name(&value)
+                    ));
                 }
             }
         }
@@ -2942,20 +4000,24 @@ impl Interpreter {
 
     /// Evaluates a list of statements sequentially, stopping on return/error
     pub fn eval_stmts(&mut self, stmts: &[Stmt]) {
+        // Decorated functions are not hoisted: their decorators run in program order.
         let is_hoistable = |stmt: &Stmt| match stmt {
-            Stmt::FuncDef { .. } => true,
+            Stmt::FuncDef { decorators, .. } => decorators.is_empty(),
             Stmt::Export { stmt } => matches!(stmt.as_ref(), Stmt::FuncDef { .. }),
             _ => false,
         };
 
         // Only hoist at module scope. Nested-scope hoisting captures lexical state
         // too early (before local bindings exist) and breaks closure behavior.
-        let should_hoist = self.env.scopes.len() == 1;
+        let should_hoist = self.env.depth() == 1;
 
         if should_hoist {
+            let mut position = None;
             for stmt in stmts {
-                if is_hoistable(stmt) {
-                    self.eval_stmt(stmt);
+                if let Stmt::SourcePos { line, column } = stmt {
+                    position = Some((*line, *column));
+                } else if is_hoistable(stmt) {
+                    self.eval_positioned_stmt(stmt, position);
                     if self.return_value.is_some() || self.control_flow != ControlFlow::None {
                         break;
                     }
@@ -2963,17 +4025,64 @@ impl Interpreter {
             }
         }
 
+        let mut position = None;
         for stmt in stmts {
+            if let Stmt::SourcePos { line, column } = stmt {
+                position = Some((*line, *column));
+                continue;
+            }
             if should_hoist && is_hoistable(stmt) {
                 continue;
             }
-            self.eval_stmt(stmt);
+            self.eval_positioned_stmt(stmt, position);
             if self.return_value.is_some() || self.control_flow != ControlFlow::None {
                 break;
             }
         }
     }
 
+    /// Evaluate `stmt`, recording `position` as the error location if it fails.
+    ///
+    /// Errors propagate outward through enclosing statements, so a position is only
+    /// recorded when no statement that started after this one already claimed the error.
+    fn eval_positioned_stmt(&mut self, stmt: &Stmt, position: Option<(usize, usize)>) {
+        let Some(position) = position else {
+            self.eval_stmt(stmt);
+            return;
+        };
+
+        self.statement_sequence += 1;
+        let sequence = self.statement_sequence;
+        if self.debugger.is_some() {
+            self.debugger_checkpoint(stmt, position);
+        }
+        let enclosing_position = self.current_position.replace(position);
+        self.eval_stmt(stmt);
+        self.current_position = enclosing_position;
+
+        let failed = self.return_value.as_ref().is_some_and(Self::is_error_value);
+        if failed && self.error_position.map_or(true, |(_, recorded)| recorded < sequence) {
+            self.error_position = Some((position, sequence));
+            self.error_trace = self.capture_stack_trace(position);
+        }
+    }
+
+    /// Run a `finally` block after its `try` statement, whatever way the statement ended.
+    ///
+    /// A pending error, `return`, `break`, or `continue` is set aside while the block runs
+    /// and resumes afterwards, unless the block itself ends in one of those.
+    fn eval_finally_block(&mut self, finally_block: &[Stmt]) {
+        let pending_return = self.return_value.take();
+        let pending_control_flow = std::mem::replace(&mut self.control_flow, ControlFlow::None);
+
+        self.eval_scoped_stmts(finally_block);
+
+        if self.return_value.is_none() && self.control_flow == ControlFlow::None {
+            self.return_value = pending_return;
+            self.control_flow = pending_control_flow;
+        }
+    }
+
     fn eval_scoped_stmts(&mut self, stmts: &[Stmt]) {
         self.env.push_scope();
         self.eval_stmts(stmts);
@@ -3032,18 +4141,50 @@ impl Interpreter {
         }
     }
 
-    /// Helper to write output to either the output buffer or stdout
+    /// Helper to write output to either the output sink or stdout
     fn write_output(&self, msg: &str) {
         if let Some(out) = &self.output {
-            let mut buffer = out.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
-            let _ = writeln!(buffer, "{}", msg); // already includes newline
+            out.write_stdout(&format!("{}\n", msg));
         } else {
             println!("{}", msg);
         }
     }
 
+    /// Like `write_output`, but writes `text` as-is with no trailing newline
+    fn write_output_text(&self, text: &str) {
+        if let Some(out) = &self.output {
+            out.write_stdout(text);
+        } else {
+            print!("{}", text);
+            let _ = std::io::Write::flush(&mut std::io::stdout());
+        }
+    }
+
+    /// Helper to write error output to either the output sink or stderr
+    pub(crate) fn write_error_output(&self, msg: &str) {
+        if let Some(out) = &self.output {
+            out.write_stderr(&format!("{}\n", msg));
+        } else {
+            eprintln!("{}", msg);
+        }
+    }
+
+    /// Charges one step to the execution budget, setting the limit error as the return
+    /// value and returning true once it is exhausted. Statements and loop iterations each
+    /// tick, so a loop with an empty body is bounded too.
+    fn tick_execution_budget(&mut self) -> bool {
+        let Some(Err(message)) = self.execution_budget.as_ref().map(|budget| budget.tick()) else {
+            return false;
+        };
+        self.return_value = Some(Value::Error(message));
+        true
+    }
+
     /// Evaluates a single statement
     fn eval_stmt(&mut self, stmt: &Stmt) {
+        if self.tick_execution_budget() {
+            return;
+        }
         match stmt {
             Stmt::If { condition, then_branch, else_branch } => {
                 let cond_val = self.eval_expr(condition);
@@ -3091,21 +4232,7 @@ impl Interpreter {
                 self.set_return_if_error(&val);
 
                 // Always perform the assignment, even for errors
-                let assignment_result = match target {
-                    Expr::Identifier(name) => {
-                        match self.env.assign_checked(name.clone(), val.clone()) {
-                            Ok(()) => Value::Null,
-                            Err(error) => Value::Error(error),
-                        }
-                    }
-                    Expr::IndexAccess { object, index } => {
-                        self.assign_index(object.as_ref(), index.as_ref(), &val)
-                    }
-                    Expr::FieldAccess { object, field } => {
-                        self.assign_member(object.as_ref(), field, &val)
-                    }
-                    _ => Value::Error("Invalid assignment target".to_string()),
-                };
+                let assignment_result = self.assign_to_target(target, &val);
 
                 if self.set_return_if_error(&assignment_result) {
                     return;
@@ -3114,45 +4241,89 @@ impl Interpreter {
                 // If expression evaluation resulted in an error, propagate it
                 self.set_return_if_error(&val);
             }
+            Stmt::MultiAssign { targets, values } => {
+                // Evaluate every right-hand side before binding so `a, b = b, a + b` swaps
+                let mut evaluated = Vec::with_capacity(values.len());
+                for value in values {
+                    let val = self.eval_expr(value);
+                    if self.set_return_if_error(&val) {
+                        return;
+                    }
+                    evaluated.push(val);
+                }
+
+                if evaluated.len() == 1 && targets.len() > 1 {
+                    match Self::unpack_assignment_values(&evaluated[0], targets.len()) {
+                        Ok(values) => evaluated = values,
+                        Err(message) => {
+                            self.set_return_if_error(&Value::Error(message));
+                            return;
+                        }
+                    }
+                }
+
+                for (target, val) in targets.iter().zip(evaluated.iter()) {
+                    let assignment_result = self.assign_to_target(target, val);
+                    if self.set_return_if_error(&assignment_result) {
+                        return;
+                    }
+                }
+            }
             Stmt::FuncDef {
                 name,
                 params,
                 param_types: _,
+                param_defaults,
+                is_variadic,
                 return_type: _,
                 body,
                 is_generator,
                 is_async,
+                decorators: _,
             } => {
-                // Named functions defined in nested scopes should capture lexical state
-                // so interpreter behavior matches compiler/VM closure semantics.
-                let captured_env = if self.env.scopes.len() > 1 {
-                    Some(Arc::new(Mutex::new(self.env.clone())))
-                } else {
-                    None
-                };
+                let signature = ParamSignature::new(param_defaults, *is_variadic);
+                let function_body = LeakyFunctionBody::with_signature(body.clone(), signature);
 
                 // If it's a generator, create a generator value instead
                 if *is_generator {
-                    let gen =
-                        Value::GeneratorDef(params.clone(), LeakyFunctionBody::new(body.clone()));
+                    let gen = Value::GeneratorDef(params.clone(), function_body);
                     self.env.define(name.clone(), gen);
-                } else if *is_async {
+                } else {
                     // Async functions are marked with a flag
                     // When called, they return a Promise and execute in background
-                    let func = Value::AsyncFunction(
-                        params.clone(),
-                        LeakyFunctionBody::new(body.clone()),
-                        captured_env,
-                    );
-                    self.env.define(name.clone(), func);
-                } else {
-                    let func = Value::Function(
-                        params.clone(),
-                        LeakyFunctionBody::new(body.clone()),
-                        captured_env,
-                    );
+                    let make_function = |captured_env| {
+                        if *is_async {
+                            Value::AsyncFunction(
+                                params.clone(),
+                                function_body.clone(),
+                                captured_env,
+                            )
+                        } else {
+                            Value::Function(params.clone(), function_body.clone(), captured_env)
+                        }
+                    };
+                    // Named functions defined in nested scopes should capture lexical state
+                    // so interpreter behavior matches compiler/VM closure semantics.
+                    let captured_env = (self.env.depth() > 1).then(|| {
+                        let free_variables = Compiler::closure_free_variables(body, params);
+                        Arc::new_cyclic(|own_env| {
+                            let own_binding = (name.as_str(), make_function(None), own_env.clone());
+                            Mutex::new(self.env.capture(&free_variables, Some(own_binding)))
+                        })
+                    });
+                    let func = make_function(captured_env);
                     self.env.define(name.clone(), func);
                 }
+
+                if let Some(rebinding) = stmt.decorator_rebinding() {
+                    self.eval_stmt(&rebinding);
+                }
+            }
+            Stmt::InterfaceDef { name, methods } => {
+                self.env.define(
+                    name.clone(),
+                    Value::Interface { name: name.clone(), methods: methods.clone() },
+                );
             }
             Stmt::EnumDef { name, variants } => {
                 for variant in variants {
@@ -3203,6 +4374,19 @@ impl Interpreter {
                     }
                 }
             }
+            Stmt::ImportPath { path, namespace } => {
+                let importer_file = self.source_file.as_deref().map(Path::new);
+                match self.module_loader.load_module_path(path, importer_file) {
+                    Ok(module) => {
+                        let value =
+                            Value::Module { name: module.name, exports: Arc::new(module.exports) };
+                        self.env.define(namespace.clone(), value);
+                    }
+                    Err(err) => {
+                        self.return_value = Some(Value::Error(err.message));
+                    }
+                }
+            }
             Stmt::Export { stmt } => {
                 // Export is metadata for module system - execute the inner statement
                 self.eval_stmt(stmt);
@@ -3213,103 +4397,18 @@ impl Interpreter {
                     return;
                 }
 
-                // Clone cases and default to avoid borrow issues during evaluation
-                let cases_clone = cases.clone();
-                let default_clone = default.clone();
-
-                // Handle Result and Option types specially by extracting data first
-                let (is_result_or_option, short_tag, full_tag, extracted_value) = match &val {
-                    Value::Result { is_ok, value } => {
-                        let tag = if *is_ok { "Ok" } else { "Err" };
-                        (true, tag.to_string(), format!("Result::{}", tag), Some((**value).clone()))
-                    }
-                    Value::Option { is_some, value } => {
-                        let tag = if *is_some { "Some" } else { "None" };
-                        let val = if *is_some { Some((**value).clone()) } else { None };
-                        (true, tag.to_string(), format!("Option::{}", tag), val)
-                    }
-                    _ => (false, String::new(), String::new(), None),
-                };
-
-                if is_result_or_option {
-                    // Match against Result or Option
-                    for (pattern, body) in cases_clone.iter() {
-                        if let Some(open_paren) = pattern.find('(') {
-                            let (enum_tag, param_var) = pattern.split_at(open_paren);
-                            let param_var = param_var.trim_matches(&['(', ')'][..]);
-                            let enum_tag = enum_tag.trim();
-                            if short_tag == enum_tag || full_tag == enum_tag {
-                                if let Some(val) = extracted_value {
-                                    self.env.define(param_var.to_string(), val);
-                                }
-                                self.eval_stmts(body);
-                                return;
-                            }
-                        } else {
-                            let pattern = pattern.as_str().trim();
-                            if pattern == short_tag || pattern == full_tag {
-                                self.eval_stmts(body);
-                                return;
-                            }
-                        }
-                    }
-
-                    if let Some(default_body) = default_clone {
-                        self.eval_stmts(&default_body);
-                    }
-                    return;
-                }
-
-                // Handle other value types (existing code)
-                let empty_map = HashMap::new();
-                let (tag, fields): (String, &HashMap<String, Value>) = match &val {
-                    Value::Tagged { tag, fields } => (tag.clone(), fields),
-                    Value::Enum(e) => (e.clone(), &empty_map),
-                    Value::Str(s) => (s.as_ref().clone(), &empty_map),
-                    Value::Float(n) => (n.to_string(), &empty_map),
-                    _ => {
-                        if let Some(default_body) = default_clone {
-                            self.eval_stmts(&default_body);
-                        }
-                        return;
-                    }
-                };
-
-                for (pattern, body) in &cases_clone {
-                    if let Some(open_paren) = pattern.find('(') {
-                        let (enum_tag, param_var) = pattern.split_at(open_paren);
-                        let param_var = param_var.trim_matches(&['(', ')'][..]);
-                        if tag == enum_tag.trim() {
-                            for i in 0.. {
-                                let key = format!("${}", i);
-                                if let Some(val) = fields.get(&key) {
-                                    let param_name = if i == 0 {
-                                        param_var.to_string()
-                                    } else {
-                                        format!("{}_{}", param_var, i)
-                                    };
-                                    self.env.define(param_name, val.clone());
-                                } else {
-                                    break;
-                                }
-                            }
-
-                            self.eval_stmts(body);
-                            return;
-                        }
-                    } else if pattern.as_str() == tag {
-                        self.eval_stmts(body);
-                        return;
-                    }
-                }
-
-                if let Some(default_body) = default_clone {
-                    self.eval_stmts(&default_body);
+                match self.select_match_arm(&val, cases, default) {
+                    Ok(Some(body)) => self.eval_stmts(body),
+                    Ok(None) => {}
+                    Err(error) => self.return_value = Some(error),
                 }
             }
             Stmt::Loop { condition, body } => {
                 self.with_loop_context(|interp| {
                     loop {
+                        if interp.tick_execution_budget() {
+                            break;
+                        }
                         if let Some(condition) = condition.as_ref() {
                             let condition_value = interp.eval_expr(condition);
                             if interp.set_return_if_error(&condition_value) {
@@ -3323,12 +4422,10 @@ impl Interpreter {
                         interp.eval_scoped_stmts(body);
 
                         // Handle control flow
-                        if interp.control_flow == ControlFlow::Break {
-                            interp.control_flow = ControlFlow::None;
-                            break;
-                        } else if interp.control_flow == ControlFlow::Continue {
-                            interp.control_flow = ControlFlow::None;
-                            continue;
+                        match interp.take_loop_signal() {
+                            ControlFlow::Break(_) => break,
+                            ControlFlow::Continue(_) => continue,
+                            ControlFlow::None => {}
                         }
 
                         if interp.return_value.is_some() {
@@ -3354,6 +4451,9 @@ impl Interpreter {
                     if matches!(&iterable_value, Value::Generator { .. }) {
                         let mut gen_value = iterable_value;
                         loop {
+                            if interp.tick_execution_budget() {
+                                break;
+                            }
                             let next_option = interp.generator_next(&mut gen_value);
                             match next_option {
                                 Value::Option { is_some: true, value } => {
@@ -3366,12 +4466,10 @@ impl Interpreter {
                                     interp.env.pop_scope();
 
                                     // Handle control flow
-                                    if interp.control_flow == ControlFlow::Break {
-                                        interp.control_flow = ControlFlow::None;
-                                        break;
-                                    } else if interp.control_flow == ControlFlow::Continue {
-                                        interp.control_flow = ControlFlow::None;
-                                        continue;
+                                    match interp.take_loop_signal() {
+                                        ControlFlow::Break(_) => break,
+                                        ControlFlow::Continue(_) => continue,
+                                        ControlFlow::None => {}
                                     }
 
                                     if interp.return_value.is_some() {
@@ -3382,8 +4480,8 @@ impl Interpreter {
                                     // Generator exhausted
                                     break;
                                 }
-                                Value::Error(msg) => {
-                                    interp.return_value = Some(Value::Error(msg));
+                                error if Self::is_error_value(&error) => {
+                                    interp.return_value = Some(error);
                                     break;
                                 }
                                 _ => {
@@ -3395,10 +4493,49 @@ impl Interpreter {
                         return;
                     }
 
+                    // Sequences are pulled one element per iteration, so a pipeline over a
+                    // large range never materializes.
+                    if let Value::Sequence(sequence) = &iterable_value {
+                        let mut cursor = sequence.cursor();
+                        loop {
+                            if interp.tick_execution_budget() {
+                                break;
+                            }
+                            let item = match cursor.next(interp) {
+                                Ok(Some(item)) => item,
+                                Ok(None) => break,
+                                Err(message) => {
+                                    interp.return_value = Some(Value::Error(message));
+                                    break;
+                                }
+                            };
+                            interp.env.push_scope();
+                            interp.env.define(var.clone(), item);
+
+                            interp.eval_stmts(body);
+
+                            interp.env.pop_scope();
+
+                            match interp.take_loop_signal() {
+                                ControlFlow::Break(_) => break,
+                                ControlFlow::Continue(_) => continue,
+                                ControlFlow::None => {}
+                            }
+
+                            if interp.return_value.is_some() {
+                                break;
+                            }
+                        }
+                        return;
+                    }
+
                     match &iterable_value {
                         Value::Int(n) => {
                             // Numeric range: for i in 5 { ... } iterates 0..5
                             for i in 0..*n {
+                                if interp.tick_execution_budget() {
+                                    break;
+                                }
                                 // Create new scope for loop iteration
                                 // Push new scope
                                 interp.env.push_scope();
@@ -3410,12 +4547,10 @@ impl Interpreter {
                                 interp.env.pop_scope();
 
                                 // Handle control flow
-                                if interp.control_flow == ControlFlow::Break {
-                                    interp.control_flow = ControlFlow::None;
-                                    break;
-                                } else if interp.control_flow == ControlFlow::Continue {
-                                    interp.control_flow = ControlFlow::None;
-                                    continue;
+                                match interp.take_loop_signal() {
+                                    ControlFlow::Break(_) => break,
+                                    ControlFlow::Continue(_) => continue,
+                                    ControlFlow::None => {}
                                 }
 
                                 if interp.return_value.is_some() {
@@ -3426,6 +4561,9 @@ impl Interpreter {
                         Value::Float(n) => {
                             // Numeric range: for i in 5.0 { ... } iterates 0..5
                             for i in 0..*n as i64 {
+                                if interp.tick_execution_budget() {
+                                    break;
+                                }
                                 // Create new scope for loop iteration
                                 // Push new scope
                                 interp.env.push_scope();
@@ -3437,12 +4575,10 @@ impl Interpreter {
                                 interp.env.pop_scope();
 
                                 // Handle control flow
-                                if interp.control_flow == ControlFlow::Break {
-                                    interp.control_flow = ControlFlow::None;
-                                    break;
-                                } else if interp.control_flow == ControlFlow::Continue {
-                                    interp.control_flow = ControlFlow::None;
-                                    continue;
+                                match interp.take_loop_signal() {
+                                    ControlFlow::Break(_) => break,
+                                    ControlFlow::Continue(_) => continue,
+                                    ControlFlow::None => {}
                                 }
 
                                 if interp.return_value.is_some() {
@@ -3454,6 +4590,9 @@ impl Interpreter {
                             // Array iteration: for item in [1, 2, 3] { ... }
                             let arr_clone = arr.as_ref().clone();
                             for item in arr_clone {
+                                if interp.tick_execution_budget() {
+                                    break;
+                                }
                                 // Create new scope for loop iteration
                                 // Push new scope
                                 interp.env.push_scope();
@@ -3465,12 +4604,10 @@ impl Interpreter {
                                 interp.env.pop_scope();
 
                                 // Handle control flow
-                                if interp.control_flow == ControlFlow::Break {
-                                    interp.control_flow = ControlFlow::None;
-                                    break;
-                                } else if interp.control_flow == ControlFlow::Continue {
-                                    interp.control_flow = ControlFlow::None;
-                                    continue;
+                                match interp.take_loop_signal() {
+                                    ControlFlow::Break(_) => break,
+                                    ControlFlow::Continue(_) => continue,
+                                    ControlFlow::None => {}
                                 }
 
                                 if interp.return_value.is_some() {
@@ -3478,16 +4615,18 @@ impl Interpreter {
                                 }
                             }
                         }
-                        Value::Dict(dict) => {
+                        dict @ (Value::Dict(_) | Value::OrderedDict(_)) => {
                             // Dictionary iteration: for key in {"a": 1, "b": 2} { ... }
-                            // Iterate over keys
-                            let keys: Vec<String> =
-                                dict.keys().map(|key| key.to_string()).collect();
+                            // Iterate over keys in the same order `keys()` returns them
+                            let keys = dict.dict_keys_in_order().unwrap_or_default();
                             for key in keys {
+                                if interp.tick_execution_budget() {
+                                    break;
+                                }
                                 // Create new scope for loop iteration
                                 // Push new scope
                                 interp.env.push_scope();
-                                interp.env.define(var.clone(), Value::Str(Arc::new(key)));
+                                interp.env.define(var.clone(), key);
 
                                 interp.eval_stmts(body);
 
@@ -3495,12 +4634,10 @@ impl Interpreter {
                                 interp.env.pop_scope();
 
                                 // Handle control flow
-                                if interp.control_flow == ControlFlow::Break {
-                                    interp.control_flow = ControlFlow::None;
-                                    break;
-                                } else if interp.control_flow == ControlFlow::Continue {
-                                    interp.control_flow = ControlFlow::None;
-                                    continue;
+                                match interp.take_loop_signal() {
+                                    ControlFlow::Break(_) => break,
+                                    ControlFlow::Continue(_) => continue,
+                                    ControlFlow::None => {}
                                 }
 
                                 if interp.return_value.is_some() {
@@ -3512,6 +4649,9 @@ impl Interpreter {
                             // String iteration: for char in "hello" { ... }
                             let chars: Vec<char> = s.chars().collect();
                             for ch in chars {
+                                if interp.tick_execution_budget() {
+                                    break;
+                                }
                                 // Create new scope for loop iteration
                                 // Push new scope
                                 interp.env.push_scope();
@@ -3525,12 +4665,10 @@ impl Interpreter {
                                 interp.env.pop_scope();
 
                                 // Handle control flow
-                                if interp.control_flow == ControlFlow::Break {
-                                    interp.control_flow = ControlFlow::None;
-                                    break;
-                                } else if interp.control_flow == ControlFlow::Continue {
-                                    interp.control_flow = ControlFlow::None;
-                                    continue;
+                                match interp.take_loop_signal() {
+                                    ControlFlow::Break(_) => break,
+                                    ControlFlow::Continue(_) => continue,
+                                    ControlFlow::None => {}
                                 }
 
                                 if interp.return_value.is_some() {
@@ -3548,6 +4686,9 @@ impl Interpreter {
                 self.with_loop_context(|interp| {
                     // While loop: execute body while condition is truthy
                     loop {
+                        if interp.tick_execution_budget() {
+                            break;
+                        }
                         let cond_val = interp.eval_expr(condition);
                         if interp.set_return_if_error(&cond_val) {
                             return;
@@ -3559,12 +4700,10 @@ impl Interpreter {
                         interp.eval_scoped_stmts(body);
 
                         // Handle control flow
-                        if interp.control_flow == ControlFlow::Break {
-                            interp.control_flow = ControlFlow::None;
-                            break;
-                        } else if interp.control_flow == ControlFlow::Continue {
-                            interp.control_flow = ControlFlow::None;
-                            continue;
+                        match interp.take_loop_signal() {
+                            ControlFlow::Break(_) => break,
+                            ControlFlow::Continue(_) => continue,
+                            ControlFlow::None => {}
                         }
 
                         if interp.return_value.is_some() {
@@ -3573,23 +4712,39 @@ impl Interpreter {
                     }
                 });
             }
-            Stmt::Break => {
-                if self.loop_depth == 0 {
-                    self.return_value =
-                        Some(Value::Error("break can only be used inside a loop".to_string()));
+            Stmt::LabeledLoop { label, loop_stmt } => {
+                self.pending_loop_label = Some(label.clone());
+                self.eval_stmt(loop_stmt);
+            }
+            Stmt::Break(label) => {
+                if let Some(error) = self.loop_jump_error("break", label.as_ref()) {
+                    self.return_value = Some(error);
                 } else {
-                    self.control_flow = ControlFlow::Break;
+                    self.control_flow = ControlFlow::Break(label.clone());
                 }
             }
-            Stmt::Continue => {
-                if self.loop_depth == 0 {
-                    self.return_value =
-                        Some(Value::Error("continue can only be used inside a loop".to_string()));
+            Stmt::Continue(label) => {
+                if let Some(error) = self.loop_jump_error("continue", label.as_ref()) {
+                    self.return_value = Some(error);
                 } else {
-                    self.control_flow = ControlFlow::Continue;
+                    self.control_flow = ControlFlow::Continue(label.clone());
                 }
             }
             Stmt::Return(expr) => {
+                if let Some(Expr::Call { function, args }) = expr {
+                    match self.prepare_tail_call(function, args) {
+                        Some(Ok(tail_call)) => {
+                            self.pending_tail_call = Some(tail_call);
+                            self.return_value = Some(Value::Return(Box::new(Value::Null)));
+                            return;
+                        }
+                        Some(Err(error)) => {
+                            self.return_value = Some(error);
+                            return;
+                        }
+                        None => {}
+                    }
+                }
                 let value = expr.as_ref().map(|e| self.eval_expr(e)).unwrap_or(Value::Null);
                 if Self::is_error_value(&value) {
                     self.return_value = Some(value);
@@ -3597,18 +4752,29 @@ impl Interpreter {
                     self.return_value = Some(Value::Return(Box::new(value)));
                 }
             }
-            Stmt::TryExcept { try_block, except_var, except_block } => {
+            Stmt::TryExcept { try_block, except_var, except_block, finally_block } => {
                 // Save current environment and create child scope for try block
                 // Push new scope
                 self.env.push_scope();
 
+                // A tail call would leave the try block before its errors could be caught.
+                // With a finally block it would also run only after that block, so the
+                // except and finally blocks make regular calls too.
+                let tail_call_allowed = std::mem::replace(&mut self.tail_call_allowed, false);
                 self.eval_stmts(try_block);
+                if finally_block.is_none() {
+                    self.tail_call_allowed = tail_call_allowed;
+                }
 
-                // Check if an error occurred (support both old Error and new ErrorObject)
+                // Check if an error occurred (support both old Error and new ErrorObject).
+                // An exceeded execution limit is not catchable.
                 let error_occurred = matches!(
                     self.return_value,
                     Some(Value::Error(_)) | Some(Value::ErrorObject { .. })
-                );
+                ) && !self
+                    .execution_budget
+                    .as_ref()
+                    .is_some_and(|budget| budget.is_exhausted());
 
                 if error_occurred {
                     let error_value = self.return_value.clone().unwrap();
@@ -3618,49 +4784,22 @@ impl Interpreter {
                     self.env.push_scope();
 
                     // Create error object with properties accessible via field access
-                    match error_value {
-                        Value::Error(msg) => {
-                            // Legacy simple error - convert to struct-like object
-                            let mut fields = HashMap::new();
-                            fields.insert("message".to_string(), Value::Str(Arc::new(msg)));
-                            fields.insert("stack".to_string(), Value::Array(Arc::new(Vec::new())));
-                            fields.insert("line".to_string(), Value::Int(0));
-
-                            self.env.define(
-                                except_var.clone(),
-                                Value::Struct { name: "Error".to_string(), fields },
-                            );
-                        }
-                        Value::ErrorObject { message, stack, line, cause } => {
-                            // New error object with full info
-                            let mut fields = HashMap::new();
-                            fields.insert("message".to_string(), Value::Str(Arc::new(message)));
-                            fields.insert(
-                                "stack".to_string(),
-                                Value::Array(Arc::new(
-                                    stack.iter().map(|s| Value::Str(Arc::new(s.clone()))).collect(),
-                                )),
-                            );
-                            fields.insert("line".to_string(), Value::Int(line.unwrap_or(0) as i64));
-                            if let Some(cause_val) = cause {
-                                fields.insert("cause".to_string(), *cause_val);
-                            }
-
-                            self.env.define(
-                                except_var.clone(),
-                                Value::Struct { name: "Error".to_string(), fields },
-                            );
-                        }
-                        _ => unreachable!(),
-                    }
+                    self.env.define(except_var.clone(), error_value.into_caught_error_binding());
 
                     // Clear error and execute except block
                     self.return_value = None;
+                    self.error_position = None;
+                    self.error_trace.clear();
                     self.eval_stmts(except_block);
                 }
 
                 // Restore parent environment
                 self.env.pop_scope();
+
+                if let Some(finally_block) = finally_block {
+                    self.eval_finally_block(finally_block);
+                    self.tail_call_allowed = tail_call_allowed;
+                }
             }
             Stmt::ExprStmt(expr) => {
                 match expr {
@@ -3672,7 +4811,7 @@ impl Interpreter {
                             if self.set_return_if_error(&v) {
                                 return;
                             }
-                            output_parts.push(Interpreter::stringify_value(&v));
+                            output_parts.push(self.display_string(&v));
                         }
                         self.write_output(&output_parts.join(" "));
                     }
@@ -3681,47 +4820,13 @@ impl Interpreter {
                     Expr::Tag(name, args) if name == "throw" => {
                         if let Some(arg) = args.first() {
                             let val = self.eval_expr(arg);
-                            match val {
-                                Value::Str(s) => {
-                                    // Simple string error - create ErrorObject
-                                    self.return_value = Some(Value::ErrorObject {
-                                        message: s.as_ref().clone(),
-                                        stack: self.call_stack.clone(),
-                                        line: None,
-                                        cause: None,
-                                    });
-                                }
-                                Value::Struct { name, fields } => {
-                                    // Custom error struct - wrap it in ErrorObject
-                                    let message =
-                                        if let Some(Value::Str(msg)) = fields.get("message") {
-                                            msg.as_ref().clone()
-                                        } else {
-                                            format!("{} error", name)
-                                        };
-
-                                    let cause = fields.get("cause").cloned();
-
-                                    self.return_value = Some(Value::ErrorObject {
-                                        message,
-                                        stack: self.call_stack.clone(),
-                                        line: None,
-                                        cause: cause.map(Box::new),
-                                    });
-                                }
-                                Value::ErrorObject { .. } => {
-                                    // Already an error object, propagate it
-                                    self.return_value = Some(val);
-                                }
-                                _ => {
-                                    self.return_value = Some(Value::ErrorObject {
-                                        message: "error".to_string(),
-                                        stack: self.call_stack.clone(),
-                                        line: None,
-                                        cause: None,
-                                    });
+                            let mut error = val.into_thrown_error(self.call_stack.clone());
+                            if let Value::ErrorObject { line, .. } = &mut error {
+                                if line.is_none() {
+                                    *line = self.current_position.map(|(line, _)| line);
                                 }
                             }
+                            self.return_value = Some(error);
                         }
                     }
 
@@ -3748,11 +4853,15 @@ impl Interpreter {
                 let body_clone = body.clone();
                 let captured_bindings = self.capture_spawn_bindings();
                 let capability_policy = self.capability_policy.clone();
+                let execution_budget = self.execution_budget.clone();
+                let host_functions = self.host_functions.clone();
 
                 // Spawn a new thread to execute the body with a transferable snapshot
                 // of parent bindings. Unsupported non-transferable values remain isolated.
                 std::thread::spawn(move || {
                     let mut thread_interp = Interpreter::with_capability_policy(capability_policy);
+                    thread_interp.set_execution_budget(execution_budget);
+                    thread_interp.host_functions = host_functions;
 
                     for (name, captured_value) in captured_bindings {
                         thread_interp.env.define(name, captured_value.into_value());
@@ -3762,6 +4871,9 @@ impl Interpreter {
                 });
                 // Don't wait for the thread to finish - it runs in the background
             }
+            Stmt::SourcePos { .. } => {
+                // Positions are consumed by eval_stmts; evaluating one does nothing
+            }
             Stmt::Test { .. }
             | Stmt::TestSetup { .. }
             | Stmt::TestTeardown { .. }
@@ -3770,7 +4882,7 @@ impl Interpreter {
                 // When running normally (not in test mode), they are no-ops
                 // This allows test files to be syntax-checked without running tests
             }
-            Stmt::StructDef { name, fields, methods } => {
+            Stmt::StructDef { name, fields, methods, implements } => {
                 // Extract field names
                 let field_names: Vec<String> =
                     fields.iter().map(|(name, _type)| name.clone()).collect();
@@ -3782,10 +4894,13 @@ impl Interpreter {
                         name: method_name,
                         params,
                         param_types: _,
+                        param_defaults,
+                        is_variadic,
                         return_type: _,
                         body,
                         is_generator,
                         is_async: _,
+                        decorators: _,
                     } = method_stmt
                     {
                         if *is_generator {
@@ -3798,14 +4913,35 @@ impl Interpreter {
                         } else {
                             let func = Value::Function(
                                 params.clone(),
-                                LeakyFunctionBody::new(body.clone()),
-                                Some(Arc::new(Mutex::new(self.env.clone()))),
+                                LeakyFunctionBody::with_signature(
+                                    body.clone(),
+                                    ParamSignature::new(param_defaults, *is_variadic),
+                                ),
+                                Some(self.capture_closure_env(params, body)),
                             );
+                            if let Some(hook) =
+                                crate::ast::operator_methods::special_method_hook(method_name)
+                            {
+                                method_map.entry(hook.to_string()).or_insert_with(|| func.clone());
+                            }
                             method_map.insert(method_name.clone(), func);
                         }
                     }
                 }
 
+                for interface_name in implements {
+                    let interface = self.env.get(interface_name);
+                    if let Err(message) = Value::check_implements(
+                        name,
+                        &method_map,
+                        interface_name,
+                        interface.as_ref(),
+                    ) {
+                        self.set_return_if_error(&Value::Error(message));
+                        return;
+                    }
+                }
+
                 // Store struct definition
                 let struct_def =
                     Value::StructDef { name: name.clone(), field_names, methods: method_map };
@@ -3834,7 +4970,7 @@ impl Interpreter {
                             if Self::is_error_value(&val) {
                                 return val;
                             }
-                            result.push_str(&Self::stringify_value(&val));
+                            result.push_str(&self.display_string(&val));
                         }
                     }
                 }
@@ -3850,25 +4986,31 @@ impl Interpreter {
             Expr::Function {
                 params,
                 param_types: _,
+                param_defaults,
+                is_variadic,
                 return_type: _,
                 body,
                 is_generator,
                 is_async,
             } => {
                 // Anonymous function expression - return as a value with captured environment
+                let function_body = LeakyFunctionBody::with_signature(
+                    body.clone(),
+                    ParamSignature::new(param_defaults, *is_variadic),
+                );
                 if *is_generator {
-                    Value::GeneratorDef(params.clone(), LeakyFunctionBody::new(body.clone()))
+                    Value::GeneratorDef(params.clone(), function_body)
                 } else if *is_async {
                     Value::AsyncFunction(
                         params.clone(),
-                        LeakyFunctionBody::new(body.clone()),
-                        Some(Arc::new(Mutex::new(self.env.clone()))),
+                        function_body,
+                        Some(self.capture_closure_env(params, body)),
                     )
                 } else {
                     Value::Function(
                         params.clone(),
-                        LeakyFunctionBody::new(body.clone()),
-                        Some(Arc::new(Mutex::new(self.env.clone()))),
+                        function_body,
+                        Some(self.capture_closure_env(params, body)),
                     )
                 }
             }
@@ -3958,93 +5100,6 @@ impl Interpreter {
                         }
                         return Value::Null;
                     }
-                    // Pipe operator: pass left value as first argument to right function
-                    "|>" => {
-                        let value = self.eval_expr(left);
-                        if Self::is_error_value(&value) {
-                            return value;
-                        }
-                        let func = self.eval_expr(right);
-                        if Self::is_error_value(&func) {
-                            return func;
-                        }
-
-                        // Call the function with the value as the first argument
-                        if let Value::Function(params, body, captured_env) = func {
-                            // Push new scope
-                            self.env.push_scope();
-
-                            // Restore captured environment if this is a closure
-                            let restore_env = if let Some(ref closure_env) = captured_env {
-                                // Store current environment
-                                let current = self.env.clone();
-                                // Set interpreter's environment to the closure's captured environment
-                                self.env = closure_env
-                                    .lock()
-                                    .unwrap_or_else(|poisoned| poisoned.into_inner())
-                                    .clone();
-                                Some(current)
-                            } else {
-                                None
-                            };
-
-                            // Bind the piped value as the first parameter
-                            if let Some(param) = params.first() {
-                                self.env.define(param.clone(), value);
-                            }
-
-                            // Execute function body
-                            if let Err(error) = self
-                                .with_function_context("<pipe function>", |interp| {
-                                    interp.eval_stmts(&body.get())
-                                })
-                            {
-                                self.return_value = Some(error);
-                            }
-                            let mut result = Value::Null;
-                            if let Some(Value::Return(val)) = self.return_value.clone() {
-                                self.return_value = None;
-                                result = *val;
-                            } else if let Some(Value::Error(message)) = self.return_value.clone() {
-                                self.return_value = None;
-                                result = Value::Error(message);
-                            } else if let Some(Value::ErrorObject { .. }) =
-                                self.return_value.clone()
-                            {
-                                result = self.return_value.clone().unwrap();
-                            }
-
-                            // Restore environment if we changed it
-                            if let Some(env) = restore_env {
-                                self.env = env;
-                            }
-
-                            // Pop scope
-                            self.env.pop_scope();
-
-                            return result;
-                        } else if let Value::NativeFunction(ref name) = func {
-                            // Handle built-in functions
-                            // Create a simple expression for the value and call the native function
-                            let arg_expr = match value {
-                                Value::Int(n) => Expr::Int(n),
-                                Value::Float(n) => Expr::Float(n),
-                                Value::Str(s) => Expr::String(s.as_ref().clone()),
-                                Value::Bool(b) => Expr::Bool(b),
-                                _ => {
-                                    return Value::Error(
-                                        "Cannot pipe this value type to native function"
-                                            .to_string(),
-                                    )
-                                }
-                            };
-                            return self.call_native_function(name, &[arg_expr]);
-                        }
-
-                        return Value::Error(
-                            "Pipe operator requires a function on the right side".to_string(),
-                        );
-                    }
                     _ => {}
                 }
 
@@ -4063,6 +5118,17 @@ impl Interpreter {
                         return result;
                     }
                 }
+                // `!=` negates a user-defined `==` when no inequality method exists
+                if op == "!=" {
+                    if let Some(result) =
+                        self.try_call_operator_method(&l, crate::ast::operator_methods::EQ, &r)
+                    {
+                        if Self::is_error_value(&result) {
+                            return result;
+                        }
+                        return Value::Bool(!result.is_truthy());
+                    }
+                }
 
                 self.binary_op_value(&l, op.as_str(), &r)
             }
@@ -4145,34 +5211,32 @@ impl Interpreter {
                         }
                     }
 
-                    // Handle Channel methods
-                    if let Value::Channel(chan) = &obj_val {
-                        match field.as_str() {
-                            "send" => {
-                                // chan.send(value) - send value to channel
-                                if args.len() != 1 {
-                                    return Value::Error(format!(
-                                        "Channel.send expects 1 arguments, got {}",
-                                        args.len()
-                                    ));
-                                }
+                    // Handle Promise chaining
+                    if matches!(obj_val, Value::Promise { .. })
+                        && Self::is_promise_chain_method(field)
+                    {
+                        let arg_values: Vec<Value> =
+                            args.iter().map(|arg| self.eval_expr(arg)).collect();
+                        return self.chain_promise_with_callback(&obj_val, field, arg_values);
+                    }
 
-                                let value = self.eval_expr(&args[0]);
-                                return self.channel_send(chan, value);
-                            }
-                            "receive" => {
-                                if !args.is_empty() {
-                                    return Value::Error(format!(
-                                        "Channel.receive expects 0 arguments, got {}",
-                                        args.len()
-                                    ));
-                                }
-                                return self.channel_receive_blocking(chan);
-                            }
-                            _ => return Value::Error(format!("Channel has no method '{}'", field)),
+                    // Handle Channel and WaitGroup methods
+                    if matches!(obj_val, Value::Channel(_) | Value::WaitGroup(_)) {
+                        let arg_values: Vec<Value> =
+                            args.iter().map(|arg| self.eval_expr(arg)).collect();
+                        if let Some(result) =
+                            Self::call_concurrency_method_impl(&obj_val, field, &arg_values)
+                        {
+                            return result;
                         }
                     }
 
+                    if let Value::Router(router) = &obj_val {
+                        let arg_values: Vec<Value> =
+                            args.iter().map(|arg| self.eval_expr(arg)).collect();
+                        return self.call_router_method(router, field, &arg_values);
+                    }
+
                     // Handle ArgParser methods
                     if let Value::Struct { name, fields } = &obj_val {
                         if name == "ArgParser" {
@@ -4332,6 +5396,7 @@ impl Interpreter {
                                                 stack: Vec::new(),
                                                 line: None,
                                                 cause: None,
+                                                payload: None,
                                             }
                                         }
                                     }
@@ -4417,85 +5482,19 @@ impl Interpreter {
                             if let Some(Value::Function(params, body, _captured_env)) =
                                 methods.get(field)
                             {
-                                let evaluated_args: Vec<Value> =
-                                    args.iter().map(|arg| self.eval_expr(arg)).collect();
-                                if let Some(error) =
-                                    evaluated_args.iter().find(|value| Self::is_error_value(value))
-                                {
-                                    return error.clone();
-                                }
-
-                                let arity = Self::struct_method_arity(name, field, params);
-                                if let Some(error) =
-                                    self.validate_callable_arity(&arity, evaluated_args.len())
-                                {
-                                    return error;
-                                }
-
-                                // Create new scope for method call
-                                // Push new scope
-                                self.env.push_scope();
-
-                                // Check if method has 'self' as first parameter
-                                let has_self_param =
-                                    params.first().map(|p| p == "self").unwrap_or(false);
-
-                                if has_self_param {
-                                    // Bind self to the struct instance
-                                    self.env.define("self".to_string(), obj_val.clone());
-
-                                    // Bind remaining method parameters (skip first 'self' param)
-                                    for (i, param) in params.iter().skip(1).enumerate() {
-                                        if let Some(arg) = evaluated_args.get(i) {
-                                            self.env.define(param.clone(), arg.clone());
-                                        }
-                                    }
-                                } else {
-                                    // Backward compatibility: bind fields directly into scope
-                                    for (field_name, field_value) in fields {
-                                        self.env.define(field_name.clone(), field_value.clone());
-                                    }
-
-                                    // Bind method parameters
-                                    for (i, param) in params.iter().enumerate() {
-                                        if let Some(arg) = evaluated_args.get(i) {
-                                            self.env.define(param.clone(), arg.clone());
-                                        }
-                                    }
-                                }
-
-                                // Execute method body
-                                if let Err(error) = self.with_function_context(
-                                    &format!("{}.{}", name, field),
-                                    |interp| interp.eval_stmts(&body.get()),
-                                ) {
-                                    self.env.pop_scope();
-                                    return error;
-                                }
-                                let result = if let Some(Value::Return(val)) =
-                                    self.return_value.clone()
-                                {
-                                    self.return_value = None; // Clear return value
-                                    *val
-                                } else if let Some(Value::Error(msg)) = self.return_value.clone() {
-                                    // Propagate error - don't clear
-                                    Value::Error(msg)
-                                } else {
-                                    // No explicit return - clear any lingering state
-                                    self.return_value = None;
-                                    Value::Null
+                                let call_args = match self.eval_call_args(args) {
+                                    Ok(call_args) => call_args,
+                                    Err(error) => return error,
                                 };
-
-                                // Restore parent environment
-                                self.env.pop_scope();
-
-                                return result;
+                                return self.call_struct_method(
+                                    name, fields, field, params, body, call_args,
+                                );
                             }
                         }
                     }
                 }
                 // Regular function call
-                let func_val = self.eval_expr(function);
+                let func_val = builtins::callable_namespace(self.eval_expr(function));
                 if Self::is_error_value(&func_val) {
                     return func_val;
                 }
@@ -4506,7 +5505,20 @@ impl Interpreter {
                 let call_result = match func_val {
                     Value::NativeFunction(name) => {
                         // Handle native function calls
-                        let res = self.call_native_function(&name, args);
+                        let res = if Self::has_expanded_args(args) {
+                            match self.eval_call_args(args) {
+                                Ok(call_args) if call_args.named.is_empty() => {
+                                    self.call_native_function_impl(&name, &call_args.positional)
+                                }
+                                Ok(_) => Value::Error(format!(
+                                    "{}() does not accept named arguments",
+                                    name
+                                )),
+                                Err(error) => error,
+                            }
+                        } else {
+                            self.call_native_function(&name, args)
+                        };
                         // Check if result is an error and set return_value to trigger try/except handling
                         match res {
                             Value::ErrorObject { .. } => {
@@ -4525,127 +5537,44 @@ impl Interpreter {
                         self.call_stack.push(callable_name.clone());
 
                         // Evaluate call arguments in the caller scope before any environment switch.
-                        let evaluated_args: Vec<Value> =
-                            args.iter().map(|arg| self.eval_expr(arg)).collect();
-                        if let Some(error) =
-                            evaluated_args.iter().find(|value| Self::is_error_value(value))
-                        {
-                            self.call_stack.pop();
-                            return error.clone();
-                        }
-
-                        let arity = Self::function_arity(callable_name.clone(), &params);
-                        if let Some(error) =
-                            self.validate_callable_arity(&arity, evaluated_args.len())
-                        {
-                            self.call_stack.pop();
-                            return error;
-                        }
-
-                        // Handle closure with captured environment
-                        if let Some(closure_env_ref) = captured_env {
-                            // Save current environment
-                            let saved_env = self.env.clone();
-
-                            // Use the captured environment
-                            self.env = closure_env_ref
-                                .lock()
-                                .unwrap_or_else(|poisoned| poisoned.into_inner())
-                                .clone();
-                            self.env.push_scope();
-
-                            for (i, param) in params.iter().enumerate() {
-                                if let Some(arg) = evaluated_args.get(i) {
-                                    self.env.define(param.clone(), arg.clone());
-                                }
-                            }
-
-                            if let Err(error) = self
-                                .with_function_context(callable_name.as_str(), |interp| {
-                                    interp.eval_stmts(&body.get())
-                                })
-                            {
-                                self.env.pop_scope();
-                                self.env = saved_env;
-                                self.call_stack.pop();
-                                return error;
-                            }
-
-                            let result = if let Some(Value::Return(val)) = self.return_value.clone()
-                            {
-                                self.return_value = None;
-                                *val
-                            } else if let Some(Value::Error(msg)) = self.return_value.clone() {
-                                Value::Error(msg)
-                            } else if let Some(Value::ErrorObject { .. }) =
-                                self.return_value.clone()
-                            {
-                                self.return_value.clone().unwrap()
-                            } else {
-                                self.return_value = None;
-                                Value::Null
-                            };
-
-                            self.env.pop_scope();
-                            // Update the captured environment
-                            *closure_env_ref
-                                .lock()
-                                .unwrap_or_else(|poisoned| poisoned.into_inner()) =
-                                self.env.clone();
-                            self.env = saved_env;
-                            self.call_stack.pop();
-
-                            result
-                        } else {
-                            // Non-closure: just create new scope
-                            self.env.push_scope();
-
-                            for (i, param) in params.iter().enumerate() {
-                                if let Some(arg) = evaluated_args.get(i) {
-                                    self.env.define(param.clone(), arg.clone());
-                                }
-                            }
-
-                            if let Err(error) = self
-                                .with_function_context(callable_name.as_str(), |interp| {
-                                    interp.eval_stmts(&body.get())
-                                })
-                            {
-                                self.env.pop_scope();
+                        let evaluated_args = match self.eval_call_args(args).and_then(|call_args| {
+                            Self::arrange_function_args(
+                                &callable_name,
+                                &params,
+                                &body,
+                                0,
+                                call_args,
+                            )
+                        }) {
+                            Ok(evaluated_args) => evaluated_args,
+                            Err(error) => {
                                 self.call_stack.pop();
                                 return error;
                             }
+                        };
 
-                            let result = if let Some(Value::Return(val)) = self.return_value.clone()
-                            {
-                                self.return_value = None;
-                                *val
-                            } else if let Some(Value::Error(msg)) = self.return_value.clone() {
-                                Value::Error(msg)
-                            } else if let Some(Value::ErrorObject { .. }) =
-                                self.return_value.clone()
-                            {
-                                self.return_value.clone().unwrap()
-                            } else {
-                                self.return_value = None;
-                                Value::Null
-                            };
-
-                            self.env.pop_scope();
-                            self.call_stack.pop();
-
-                            result
-                        }
+                        self.call_function_with_tail_calls(PendingTailCall {
+                            callable_name,
+                            params,
+                            body,
+                            captured_env,
+                            args: evaluated_args,
+                        })
                     }
                     Value::AsyncFunction(params, body, captured_env) => {
                         // Evaluate arguments
-                        let args_vec: Vec<Value> =
-                            args.iter().map(|arg| self.eval_expr(arg)).collect();
-                        if let Some(error) =
-                            args_vec.iter().find(|value| Self::is_error_value(value))
-                        {
-                            return error.clone();
-                        }
+                        let args_vec = match self.eval_call_args(args).and_then(|call_args| {
+                            Self::arrange_function_args(
+                                &callable_name,
+                                &params,
+                                &body,
+                                0,
+                                call_args,
+                            )
+                        }) {
+                            Ok(args_vec) => args_vec,
+                            Err(error) => return error,
+                        };
 
                         let arity = Self::function_arity(callable_name.clone(), &params);
                         if let Some(error) = self.validate_callable_arity(&arity, args_vec.len()) {
@@ -4662,6 +5591,8 @@ impl Interpreter {
                         };
                         let closure_env_for_update = captured_env.clone();
                         let capability_policy = self.capability_policy.clone();
+                        let execution_budget = self.execution_budget.clone();
+                        let host_functions = self.host_functions.clone();
 
                         // Create a tokio oneshot channel for the result
                         let (tx, rx) = tokio::sync::oneshot::channel();
@@ -4670,6 +5601,8 @@ impl Interpreter {
                         AsyncRuntime::spawn_task(async move {
                             let mut async_interpreter =
                                 Interpreter::with_capability_policy(capability_policy);
+                            async_interpreter.set_execution_budget(execution_budget);
+                            async_interpreter.host_functions = host_functions;
                             async_interpreter.env = base_env;
                             async_interpreter.env.push_scope();
 
@@ -4722,38 +5655,48 @@ impl Interpreter {
                     }
                     Value::GeneratorDef(ref params, ref body) => {
                         // Calling a generator function creates a Generator instance
-                        let args_vec: Vec<Value> =
-                            args.iter().map(|arg| self.eval_expr(arg)).collect();
-                        if let Some(error) =
-                            args_vec.iter().find(|value| Self::is_error_value(value))
-                        {
-                            return error.clone();
-                        }
+                        let args_vec = match self.eval_call_args(args).and_then(|call_args| {
+                            Self::arrange_function_args(&callable_name, params, body, 0, call_args)
+                        }) {
+                            Ok(args_vec) => args_vec,
+                            Err(error) => return error,
+                        };
 
                         let arity = Self::function_arity(callable_name.clone(), params);
                         if let Some(error) = self.validate_callable_arity(&arity, args_vec.len()) {
                             return error;
                         }
 
-                        // Create a new environment for the generator
-                        let mut gen_env = self.env.clone();
-                        gen_env.push_scope();
-
-                        // Bind parameters to arguments
-                        for (i, param) in params.iter().enumerate() {
-                            if let Some(arg) = args_vec.get(i) {
-                                gen_env.define(param.clone(), arg.clone());
+                        self.start_generator(params, body, &args_vec)
+                    }
+                    Value::Decorated(decorated) => {
+                        let args_vec = match self.eval_call_args(args) {
+                            Ok(call_args) if call_args.named.is_empty() => call_args.positional,
+                            Ok(_) => {
+                                return Value::Error(format!(
+                                    "{} functions do not accept named arguments",
+                                    decorated.name()
+                                ))
                             }
-                        }
+                            Err(error) => return error,
+                        };
+                        self.call_decorated_value(&decorated, &args_vec)
+                    }
+                    Value::StructDef { name, field_names, .. } => {
+                        // Positional constructor: Point(3, 4)
+                        let args_vec = match self.eval_call_args(args) {
+                            Ok(call_args) if call_args.named.is_empty() => call_args.positional,
+                            Ok(_) => {
+                                return Value::Error(format!(
+                                    "{}() does not accept named arguments",
+                                    name
+                                ))
+                            }
+                            Err(error) => return error,
+                        };
 
-                        // Return a Generator instance
-                        Value::Generator {
-                            params: params.clone(),
-                            body: body.clone(),
-                            env: Arc::new(Mutex::new(gen_env)),
-                            pc: 0,
-                            is_exhausted: false,
-                        }
+                        Value::construct_struct(&name, &field_names, args_vec)
+                            .unwrap_or_else(Value::Error)
                     }
                     _ => Value::Int(0),
                 };
@@ -4928,25 +5871,7 @@ impl Interpreter {
                                 return error;
                             }
 
-                            // Create a new environment for the generator
-                            let mut gen_env = self.env.clone();
-                            gen_env.push_scope();
-
-                            // Bind parameters to arguments
-                            for (i, param) in params.iter().enumerate() {
-                                if let Some(arg) = args_vec.get(i) {
-                                    gen_env.define(param.clone(), arg.clone());
-                                }
-                            }
-
-                            // Return a Generator instance
-                            return Value::Generator {
-                                params: params.clone(),
-                                body: body.clone(),
-                                env: Arc::new(Mutex::new(gen_env)),
-                                pc: 0,
-                                is_exhausted: false,
-                            };
+                            return self.start_generator(params, body, &args_vec);
                         }
                         _ => {}
                     }
@@ -4964,6 +5889,13 @@ impl Interpreter {
                 Value::Tagged { tag: name.clone(), fields }
             }
             Expr::StructInstance { name, fields } => {
+                if let Some(Value::StructDef { field_names, .. }) = self.env.get(name) {
+                    if let Some((unknown, _)) =
+                        fields.iter().find(|(field_name, _)| !field_names.contains(field_name))
+                    {
+                        return Value::Error(unknown_struct_field_message(name, unknown));
+                    }
+                }
                 // Create a struct instance
                 let mut field_values = HashMap::new();
                 for (field_name, field_expr) in fields {
@@ -5002,6 +5934,11 @@ impl Interpreter {
                             _ => Value::Error(format!("Image has no field '{}'", field)),
                         }
                     }
+                    Value::Module { name, exports } => {
+                        exports.get(field).cloned().unwrap_or_else(|| {
+                            Value::Error(format!("Module '{}' has no export '{}'", name, field))
+                        })
+                    }
                     Value::Error(_) | Value::ErrorObject { .. } => obj_val,
                     _ => Value::Error(format!(
                         "Cannot access field or method '{}' on non-struct value",
@@ -5047,16 +5984,16 @@ impl Interpreter {
                         DictElement::Pair(key_expr, val_expr) => {
                             let key = match self.eval_expr(key_expr) {
                                 error if Self::is_error_value(&error) => return error,
-                                Value::Str(s) => s.as_ref().clone(),
-                                Value::Int(n) => n.to_string(),
-                                Value::Float(n) => n.to_string(),
-                                _ => continue,
+                                key => match Value::dict_key(&key) {
+                                    Ok(key) => key,
+                                    Err(error) => return Value::Error(error),
+                                },
                             };
                             let value = self.eval_expr(val_expr);
                             if Self::is_error_value(&value) {
                                 return value;
                             }
-                            map.insert(Arc::from(key), value);
+                            map.insert(key, value);
                         }
                         DictElement::Spread(expr) => {
                             // Evaluate spread expression and merge its entries
@@ -5086,8 +6023,27 @@ impl Interpreter {
                     return idx_val;
                 }
 
+                if let Some(result) = self.try_call_operator_method(
+                    &obj_val,
+                    crate::ast::operator_methods::INDEX,
+                    &idx_val,
+                ) {
+                    return result;
+                }
+
                 Self::index_value(&obj_val, &idx_val)
             }
+            Expr::Slice { object, start, end } => {
+                // Same `slice` builtin the VM lowers to; a missing bound is null
+                let mut args = vec![self.eval_expr(object)];
+                for bound in [start, end] {
+                    args.push(bound.as_ref().map_or(Value::Null, |bound| self.eval_expr(bound)));
+                }
+                if let Some(error) = args.iter().find(|value| Self::is_error_value(value)) {
+                    return error.clone();
+                }
+                self.call_native_function_impl("slice", &args)
+            }
             Expr::Ok(value_expr) => {
                 let value = self.eval_expr(value_expr);
                 Value::Result { is_ok: true, value: Box::new(value) }
@@ -5101,6 +6057,28 @@ impl Interpreter {
                 Value::Option { is_some: true, value: Box::new(value) }
             }
             Expr::None => Value::Option { is_some: false, value: Box::new(Value::Null) },
+            Expr::Ternary { condition, then_expr, else_expr } => {
+                let cond_val = self.eval_expr(condition);
+                if Self::is_error_value(&cond_val) {
+                    return cond_val;
+                }
+                if cond_val.is_truthy() {
+                    self.eval_expr(then_expr)
+                } else {
+                    self.eval_expr(else_expr)
+                }
+            }
+            Expr::Match { value, cases, default } => {
+                let subject = self.eval_expr(value);
+                if Self::is_error_value(&subject) {
+                    return subject;
+                }
+                match self.select_match_arm(&subject, cases, default) {
+                    Ok(Some(body)) => self.eval_match_expr_body(body),
+                    Ok(None) => Value::Null,
+                    Err(error) => error,
+                }
+            }
             Expr::Try(expr) => {
                 let value = self.eval_expr(expr);
                 match value {
@@ -5242,22 +6220,26 @@ impl Interpreter {
                 if Self::is_error_value(&obj_value) {
                     return obj_value;
                 }
-                let arg_values: Vec<Value> = args.iter().map(|arg| self.eval_expr(arg)).collect();
-                if let Some(error) = arg_values.iter().find(|value| Self::is_error_value(value)) {
-                    return error.clone();
-                }
+                let call_args = match self.eval_call_args(args) {
+                    Ok(call_args) => call_args,
+                    Err(error) => return error,
+                };
 
                 // Call the method on the object
-                self.call_method(obj_value, method, arg_values)
+                self.call_method_with_args(obj_value, method, call_args)
             }
             Expr::Spread(_) => {
-                // Spread expressions should only appear inside array/dict literals
-                // If we reach here, it's a syntax error, but we'll return an error value
+                // Spread expressions should only appear inside array/dict literals and
+                // call arguments; if we reach here, return an error value
                 Value::Error(
-                    "Spread operator (...) can only be used inside array or dict literals"
+                    "Spread operator (...) can only be used in array or dict literals and calls"
                         .to_string(),
                 )
             }
+            Expr::NamedArg { name, .. } => Value::Error(format!(
+                "Named argument '{}' can only be passed to user-defined functions",
+                name
+            )),
         };
         result
     }
@@ -5463,50 +6445,196 @@ impl Interpreter {
         Some(result)
     }
 
-    fn channel_send(
+    /// Promise methods that derive a new promise from a callback.
+    pub(crate) fn is_promise_chain_method(method: &str) -> bool {
+        matches!(method, "then" | "catch" | "finally")
+    }
+
+    /// Validate a `.then`/`.catch`/`.finally` call; returns the single callback argument.
+    pub(crate) fn promise_chain_callback_arg(
+        method: &str,
+        args: Vec<Value>,
+    ) -> Result<Value, Value> {
+        let mut args = args;
+        if args.len() != 1 {
+            return Err(Value::Error(format!(
+                "Promise.{} expects 1 argument, got {}",
+                method,
+                args.len()
+            )));
+        }
+        Ok(args.remove(0))
+    }
+
+    /// Shared `.then`/`.catch`/`.finally` derivation used by both the interpreter and the VM.
+    pub(crate) fn chain_promise_impl(
+        promise: &Value,
+        method: &str,
+        callback: PromiseCallback,
+    ) -> Value {
+        native_functions::async_ops::chain_promise(promise, method, callback)
+    }
+
+    fn chain_promise_with_callback(
         &self,
-        chan: &Arc<Mutex<(std::sync::mpsc::Sender<Value>, std::sync::mpsc::Receiver<Value>)>>,
-        value: Value,
+        promise: &Value,
+        method: &str,
+        args: Vec<Value>,
     ) -> Value {
-        let chan_lock = match lock_or_runtime_error(chan.as_ref(), "channel.send") {
-            Ok(guard) => guard,
+        let callback = match Self::promise_chain_callback_arg(method, args) {
+            Ok(callback) => callback,
             Err(error) => return error,
         };
-        let (sender, _) = &*chan_lock;
-        match sender.send(value) {
-            Ok(_) => Value::Bool(true),
-            Err(_) => Value::Error("Failed to send to channel".to_string()),
+        // Like async function bodies, the callback runs against a copy of this environment.
+        let env = self.env.clone();
+        let capability_policy = self.capability_policy.clone();
+        let execution_budget = self.execution_budget.clone();
+        let host_functions = self.host_functions.clone();
+        let callback: PromiseCallback = Box::new(move |args| {
+            let mut callback_interp = Interpreter::with_capability_policy(capability_policy);
+            callback_interp.set_execution_budget(execution_budget.clone());
+            callback_interp.host_functions = host_functions;
+            callback_interp.env = env;
+            callback_interp.call_user_function(&callback, &args)
+        });
+        Self::chain_promise_impl(promise, method, callback)
+    }
+
+    /// Shared `Channel` and `WaitGroup` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_concurrency_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        match obj {
+            Value::Channel(channel) => {
+                Some(native_functions::concurrency::call_channel_method(channel, method, args))
+            }
+            Value::WaitGroup(wait_group) => Some(
+                native_functions::concurrency::call_wait_group_method(wait_group, method, args),
+            ),
+            _ => None,
         }
     }
 
-    fn channel_receive_blocking(
-        &self,
-        chan: &Arc<Mutex<(std::sync::mpsc::Sender<Value>, std::sync::mpsc::Receiver<Value>)>>,
+    /// Shared `Mutex` and `SyncMap` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_sync_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+        call: impl FnOnce(&Value) -> Value,
+    ) -> Option<Value> {
+        native_functions::concurrency::call_sync_method(obj, method, args, call)
+    }
+
+    /// Shared call of a `memo`, `timed`, or `retry(n)` function used by both the
+    /// interpreter and the VM.
+    pub(crate) fn call_decorated_impl(
+        host: &mut dyn DecoratorHost,
+        decorated: &Decorated,
+        args: &[Value],
     ) -> Value {
-        loop {
-            let receive_result = {
-                let chan_lock = match lock_or_runtime_error(chan.as_ref(), "channel.receive") {
-                    Ok(guard) => guard,
-                    Err(error) => return error,
-                };
-                let (_, receiver) = &*chan_lock;
-                receiver.try_recv()
-            };
+        native_functions::decorators::call_decorated(host, decorated, args)
+    }
 
-            match receive_result {
-                Ok(value) => return value,
-                Err(std::sync::mpsc::TryRecvError::Empty) => {
-                    std::thread::sleep(std::time::Duration::from_millis(1));
-                }
-                Err(std::sync::mpsc::TryRecvError::Disconnected) => {
-                    return Value::Error("Channel disconnected".to_string());
-                }
-            }
-        }
+    /// Shared `FileHandle` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_file_handle_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::io::call_file_handle_method(obj, method, args)
+    }
+
+    /// Shared `StringBuilder` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_string_builder_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::strings::call_string_builder_method(obj, method, args)
+    }
+
+    /// Shared `NativeLibrary` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_native_library_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::ffi::call_native_library_method(obj, method, args)
+    }
+
+    /// Shared `Process` method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_process_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::process::call_process_method(obj, method, args)
+    }
+
+    /// Shared `net` socket method dispatch used by both the interpreter and the VM.
+    pub(crate) fn call_socket_method_impl(
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::net::call_socket_method(obj, method, args)
+    }
+
+    /// Shared `Database` method dispatch; the VM passes itself as the host so
+    /// `transaction()` callbacks run as bytecode.
+    pub(crate) fn call_database_method_impl(
+        host: &mut dyn SequenceHost,
+        obj: &Value,
+        method: &str,
+        args: &[Value],
+    ) -> Option<Value> {
+        native_functions::database::call_database_method(host, obj, method, args)
+    }
+
+    /// Shared `iter` namespace dispatch; the VM passes itself as the host so callbacks
+    /// run as bytecode.
+    pub(crate) fn call_iter_module_impl(
+        host: &mut dyn SequenceHost,
+        method: &str,
+        args: &[Value],
+    ) -> Result<Value, String> {
+        native_functions::collections::call_iter_module(host, method, args)
+    }
+
+    /// Shared `sort(values, compare)`; the VM passes itself as the host so the comparator
+    /// runs as bytecode.
+    pub(crate) fn sort_with_comparator_impl(
+        host: &mut dyn SequenceHost,
+        values: &[Value],
+        compare: &Value,
+    ) -> Result<Vec<Value>, String> {
+        native_functions::collections::sort_with_comparator(host, values, compare)
+    }
+
+    /// Array builtins that `values.method(...)` calls with the array as the first argument.
+    pub(crate) fn is_array_method(method: &str) -> bool {
+        native_functions::collections::ARRAY_METHODS.contains(&method)
+    }
+
+    /// Shared `next(generator)`; each engine resumes its own generators.
+    pub(crate) fn generator_step_impl(
+        host: &mut dyn SequenceHost,
+        args: &[Value],
+    ) -> Result<Value, String> {
+        native_functions::collections::generator_step(host, args)
     }
 
     /// Call a method on a value (used for iterator chaining and other method calls)
     fn call_method(&mut self, obj: Value, method: &str, args: Vec<Value>) -> Value {
+        if let Value::Module { name, exports } = &obj {
+            return match exports.get(method) {
+                Some(function) => self.call_user_function(function, &args),
+                None => Value::Error(format!("Module '{}' has no export '{}'", name, method)),
+            };
+        }
+
         if method == "save" {
             if matches!(&obj, Value::Image { .. }) {
                 if let Err(error) =
@@ -5521,6 +6649,36 @@ impl Interpreter {
             return result;
         }
 
+        if let Some(result) = Self::call_file_handle_method_impl(&obj, method, &args) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_string_builder_method_impl(&obj, method, &args) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_sync_method_impl(&obj, method, &args, |function| {
+            self.call_user_function(function, &[])
+        }) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_native_library_method_impl(&obj, method, &args) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_process_method_impl(&obj, method, &args) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_socket_method_impl(&obj, method, &args) {
+            return result;
+        }
+
+        if let Some(result) = Self::call_database_method_impl(self, &obj, method, &args) {
+            return result;
+        }
+
         if let Value::HttpServer { host, port, routes } = &obj {
             return match method {
                 "route" => {
@@ -5573,30 +6731,23 @@ impl Interpreter {
             };
         }
 
-        if let Value::Channel(chan) = &obj {
-            return match method {
-                "send" => {
-                    if args.len() != 1 {
-                        return Value::Error(format!(
-                            "Channel.send expects 1 arguments, got {}",
-                            args.len()
-                        ));
-                    }
+        if matches!(obj, Value::Promise { .. }) && Self::is_promise_chain_method(method) {
+            return self.chain_promise_with_callback(&obj, method, args);
+        }
 
-                    self.channel_send(chan, args[0].clone())
-                }
-                "receive" => {
-                    if !args.is_empty() {
-                        return Value::Error(format!(
-                            "Channel.receive expects 0 arguments, got {}",
-                            args.len()
-                        ));
-                    }
+        if let Some(result) = Self::call_concurrency_method_impl(&obj, method, &args) {
+            return result;
+        }
 
-                    self.channel_receive_blocking(chan)
-                }
-                _ => Value::Error(format!("Channel has no method '{}'", method)),
-            };
+        if let Value::Router(router) = &obj {
+            return self.call_router_method(router, method, &args);
+        }
+
+        if matches!(obj, Value::Array(_)) && Self::is_array_method(method) {
+            let mut call_args = Vec::with_capacity(args.len() + 1);
+            call_args.push(obj);
+            call_args.extend(args);
+            return self.call_native_function_impl(method, &call_args);
         }
 
         match method {
@@ -5689,10 +6840,12 @@ impl Interpreter {
                 // Collect iterator into an array
                 self.collect_iterator(obj)
             }
-            "next" if args.is_empty() => {
+            "next" if args.is_empty() => match obj {
+                // Generators share their resume state, so stepping this copy advances all
+                mut generator @ Value::Generator { .. } => self.generator_next(&mut generator),
                 // Get next value from iterator
-                self.iterator_next(obj)
-            }
+                iterator => self.iterator_next(iterator),
+            },
             _ => {
                 // Check if it's a struct method
                 match obj {
@@ -5703,77 +6856,127 @@ impl Interpreter {
                             if let Some(Value::Function(params, body, _captured_env)) =
                                 methods.get(method)
                             {
-                                let arity = Self::struct_method_arity(&name, method, params);
-                                if let Some(error) =
-                                    self.validate_callable_arity(&arity, args.len())
-                                {
-                                    return error;
-                                }
-
-                                self.env.push_scope();
+                                return self.call_struct_method(
+                                    &name,
+                                    &fields,
+                                    method,
+                                    params,
+                                    body,
+                                    CallArgs::positional(args),
+                                );
+                            }
+                        }
 
-                                let has_self_param =
-                                    params.first().map(|param| param == "self").unwrap_or(false);
+                        Value::Error(format!("Unknown method: {}", method))
+                    }
+                    _ => Value::Error(format!("Unknown method: {}", method)),
+                }
+            }
+        }
+    }
 
-                                if has_self_param {
-                                    self.env.define(
-                                        "self".to_string(),
-                                        Value::Struct {
-                                            name: name.clone(),
-                                            fields: fields.clone(),
-                                        },
-                                    );
+    /// `call_method` for a call site that may pass named arguments. Only struct
+    /// methods and module functions written in Ruff accept them.
+    fn call_method_with_args(&mut self, obj: Value, method: &str, args: CallArgs) -> Value {
+        if args.named.is_empty() {
+            return self.call_method(obj, method, args.positional);
+        }
 
-                                    for (index, param) in params.iter().skip(1).enumerate() {
-                                        if let Some(arg) = args.get(index) {
-                                            self.env.define(param.clone(), arg.clone());
-                                        }
-                                    }
-                                } else {
-                                    for (field_name, field_value) in &fields {
-                                        self.env.define(field_name.clone(), field_value.clone());
-                                    }
+        match &obj {
+            Value::Module { exports, .. } => {
+                if let Some(function) = exports.get(method) {
+                    return self.call_user_function_with_args(function, args);
+                }
+            }
+            Value::Struct { name, fields } => {
+                if let Some(Value::StructDef { methods, .. }) = self.env.get(name) {
+                    if let Some(Value::Function(params, body, _captured_env)) = methods.get(method)
+                    {
+                        return self.call_struct_method(name, fields, method, params, body, args);
+                    }
+                }
+            }
+            _ => {}
+        }
+        Value::Error(format!("{}() does not accept named arguments", method))
+    }
 
-                                    for (index, param) in params.iter().enumerate() {
-                                        if let Some(arg) = args.get(index) {
-                                            self.env.define(param.clone(), arg.clone());
-                                        }
-                                    }
-                                }
+    /// Run a struct method on the instance `name { fields }`.
+    ///
+    /// A leading `self` parameter receives the instance; methods written without one
+    /// see the instance's fields as plain names instead.
+    fn call_struct_method(
+        &mut self,
+        name: &str,
+        fields: &HashMap<String, Value>,
+        method: &str,
+        params: &[String],
+        body: &LeakyFunctionBody,
+        args: CallArgs,
+    ) -> Value {
+        let has_self_param = params.first().map(|param| param == "self").unwrap_or(false);
+        let callable_name = format!("{}.{}", name, method);
+        let args = match Self::arrange_function_args(
+            &callable_name,
+            params,
+            body,
+            usize::from(has_self_param),
+            args,
+        ) {
+            Ok(args) => args,
+            Err(error) => return error,
+        };
 
-                                if let Err(error) = self.with_function_context(
-                                    &format!("{}.{}", name, method),
-                                    |interp| interp.eval_stmts(&body.get()),
-                                ) {
-                                    self.env.pop_scope();
-                                    return error;
-                                }
+        let arity = Self::struct_method_arity(name, method, params);
+        if let Some(error) = self.validate_callable_arity(&arity, args.len()) {
+            return error;
+        }
 
-                                let result =
-                                    if let Some(Value::Return(value)) = self.return_value.clone() {
-                                        self.return_value = None;
-                                        *value
-                                    } else if let Some(Value::Error(message)) =
-                                        self.return_value.clone()
-                                    {
-                                        Value::Error(message)
-                                    } else {
-                                        self.return_value = None;
-                                        Value::Null
-                                    };
+        self.env.push_scope();
 
-                                self.env.pop_scope();
+        if has_self_param {
+            self.env.define(
+                "self".to_string(),
+                Value::Struct { name: name.to_string(), fields: fields.clone() },
+            );
 
-                                return result;
-                            }
-                        }
+            for (index, param) in params.iter().skip(1).enumerate() {
+                if let Some(arg) = args.get(index) {
+                    self.env.define(param.clone(), arg.clone());
+                }
+            }
+        } else {
+            for (field_name, field_value) in fields {
+                self.env.define(field_name.clone(), field_value.clone());
+            }
 
-                        Value::Error(format!("Unknown method: {}", method))
-                    }
-                    _ => Value::Error(format!("Unknown method: {}", method)),
+            for (index, param) in params.iter().enumerate() {
+                if let Some(arg) = args.get(index) {
+                    self.env.define(param.clone(), arg.clone());
                 }
             }
         }
+
+        if let Err(error) =
+            self.with_function_context(&callable_name, |interp| interp.eval_stmts(&body.get()))
+        {
+            self.env.pop_scope();
+            return error;
+        }
+
+        let result = if let Some(Value::Return(value)) = self.return_value.clone() {
+            self.return_value = None;
+            *value
+        } else if let Some(Value::Error(message)) = self.return_value.clone() {
+            Value::Error(message)
+        } else {
+            self.return_value = None;
+            Value::Null
+        };
+
+        self.env.pop_scope();
+
+        result
     }
 
     /// Collect all values from an iterator into an array
@@ -5917,77 +7120,6 @@ impl Interpreter {
         }
     }
 
-    /// Execute a generator until it yields a value or completes
-    /// Returns Some(value) if yielded, None if exhausted
-    fn generator_next(&mut self, generator: &mut Value) -> Value {
-        match generator {
-            Value::Generator { params: _, body, env, pc, is_exhausted } => {
-                if *is_exhausted {
-                    return Value::Option { is_some: false, value: Box::new(Value::Null) };
-                }
-
-                // Save current interpreter state
-                let saved_env = self.env.clone();
-                let saved_return_value = self.return_value.take();
-
-                // Use the generator's environment
-                self.env = env.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).clone();
-
-                let stmts = body.get();
-                let mut yielded_value = None;
-
-                // Execute statements starting from PC until yield or end
-                while *pc < stmts.len() {
-                    let current_pc = *pc;
-
-                    self.eval_stmt(&stmts[current_pc]);
-
-                    // Check if a yield occurred (signaled by Return value)
-                    if let Some(ret_val) = &self.return_value {
-                        match ret_val {
-                            Value::Return(inner) => {
-                                // This is a yield - extract the value and suspend
-                                // Advance PC so next call continues from next statement
-                                *pc += 1;
-                                yielded_value = Some(inner.as_ref().clone());
-                                self.return_value = None;
-                                break;
-                            }
-                            _ => {
-                                // Regular return - generator is done
-                                *is_exhausted = true;
-                                break;
-                            }
-                        }
-                    } else {
-                        // Statement completed without yield - advance to next statement
-                        *pc += 1;
-                    }
-                }
-
-                // Save the generator's environment state
-                *env.lock().unwrap_or_else(|poisoned| poisoned.into_inner()) = self.env.clone();
-
-                // If we finished all statements without explicit return/yield, generator is exhausted
-                if *pc >= stmts.len() {
-                    *is_exhausted = true;
-                }
-
-                // Restore interpreter state
-                self.env = saved_env;
-                self.return_value = saved_return_value;
-
-                // Return the yielded value or None if exhausted
-                if let Some(value) = yielded_value {
-                    Value::Option { is_some: true, value: Box::new(value) }
-                } else {
-                    Value::Option { is_some: false, value: Box::new(Value::Null) }
-                }
-            }
-            _ => Value::Error("generator_next() can only be called on generators".to_string()),
-        }
-    }
-
     /// Get the next value from an iterator
     fn iterator_next(&mut self, mut iterator: Value) -> Value {
         match &mut iterator {
@@ -6097,11 +7229,21 @@ impl Interpreter {
     }
 
     /// Converts a runtime value to a string for display
-    fn stringify_value(value: &Value) -> String {
+    /// A string-keyed dict key as `stringify_value` prints it: quoted text, or the
+    /// composite key it was stored for.
+    fn stringify_dict_key(key: &str) -> String {
+        match Value::map_key_value(key) {
+            Value::Str(text) => format!("\"{}\"", text),
+            composite => Self::stringify_value(&composite),
+        }
+    }
+
+    pub(crate) fn stringify_value(value: &Value) -> String {
         match value {
             Value::Str(s) => s.as_ref().clone(),
             Value::Int(n) => n.to_string(),
-            Value::Float(n) => n.to_string(),
+            Value::BigInt(n) => n.to_string(),
+            Value::Float(n) => Value::format_float(*n),
             Value::Bool(b) => b.to_string(),
             Value::Null => "null".to_string(),
             Value::Tagged { tag, fields } => {
@@ -6136,20 +7278,39 @@ impl Interpreter {
                     .iter()
                     .map(|k| {
                         format!(
-                            "\"{}\": {}",
-                            k,
+                            "{}: {}",
+                            Interpreter::stringify_dict_key(k),
                             Interpreter::stringify_value(map.get(k.as_ref()).unwrap())
                         )
                     })
                     .collect();
                 format!("{{{}}}", pair_strs.join(", "))
             }
+            Value::OrderedDict(map) => {
+                let pair_strs: Vec<String> = map
+                    .iter()
+                    .map(|(k, v)| {
+                        format!(
+                            "{}: {}",
+                            Interpreter::stringify_dict_key(k),
+                            Interpreter::stringify_value(v)
+                        )
+                    })
+                    .collect();
+                format!("{{{}}}", pair_strs.join(", "))
+            }
             Value::FixedDict { keys, values } => {
                 let mut pairs: Vec<(&Arc<str>, &Value)> = keys.iter().zip(values.iter()).collect();
                 pairs.sort_by(|(a, _), (b, _)| a.as_ref().cmp(b.as_ref()));
                 let pair_strs: Vec<String> = pairs
                     .iter()
-                    .map(|(k, v)| format!("\"{}\": {}", k, Interpreter::stringify_value(v)))
+                    .map(|(k, v)| {
+                        format!(
+                            "{}: {}",
+                            Interpreter::stringify_dict_key(k),
+                            Interpreter::stringify_value(v)
+                        )
+                    })
                     .collect();
                 format!("{{{}}}", pair_strs.join(", "))
             }
@@ -6214,14 +7375,34 @@ impl Interpreter {
             Value::GeneratorDef(params, _) => {
                 format!("<generator function with {} params>", params.len())
             }
-            Value::Generator { params, is_exhausted, .. } => {
-                if *is_exhausted {
+            Value::Generator { params, state, .. } => {
+                if state.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).is_exhausted {
                     format!("<exhausted generator ({} params)>", params.len())
                 } else {
                     format!("<generator ({} params)>", params.len())
                 }
             }
             Value::Iterator { .. } => "<iterator>".to_string(),
+            Value::FileHandle { path, mode, .. } => {
+                format!("<file handle: {} (mode {})>", path, mode)
+            }
+            Value::StringBuilder(buffer) => format!(
+                "<string builder: {} bytes>",
+                buffer.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).len()
+            ),
+            Value::NativeLibrary(library) => format!("<native library: {}>", library.path()),
+            Value::Process(process) => format!("<process: {}>", process.label()),
+            Value::Socket(socket) => format!("<{}: {}>", socket.type_name(), socket.label()),
+            Value::Sequence(_) => "<sequence>".to_string(),
+            Value::Mutex(mutex) => {
+                format!("<mutex: {}>", if mutex.is_locked() { "locked" } else { "unlocked" })
+            }
+            Value::Atomic(counter) => {
+                format!("<atomic: {}>", counter.load(std::sync::atomic::Ordering::SeqCst))
+            }
+            Value::SyncMap(map) => format!("<sync map: {} keys>", map.len()),
+            Value::Decorated(decorated) => format!("<{} function>", decorated.name()),
+            Value::Interface { name, .. } => format!("<interface {}>", name),
             _ => "<unknown>".into(),
         }
     }
@@ -6231,7 +7412,7 @@ impl Interpreter {
     pub fn cleanup(&mut self) {
         // Get all variables from the environment
         let var_names: Vec<String> =
-            self.env.scopes.iter().flat_map(|scope| scope.keys().cloned()).collect();
+            self.env.bindings().into_iter().map(|(name, _)| name).collect();
 
         for var_name in var_names {
             if let Some(Value::Database { connection, db_type, in_transaction, .. }) =
@@ -6279,3 +7460,36 @@ impl Interpreter {
         }
     }
 }
+
+impl DecoratorHost for Interpreter {
+    fn call_decorated_function(&mut self, function: &Value, args: &[Value]) -> Value {
+        let result = self.call_user_function(function, args);
+        // The decorator decides whether the error unwinds, so it must not be pending yet
+        if Self::is_error_value(&result) {
+            self.return_value = None;
+        }
+        result
+    }
+
+    fn write_decorator_output(&mut self, line: &str) {
+        self.write_error_output(line);
+    }
+}
+
+impl SequenceHost for Interpreter {
+    fn call_sequence_callback(&mut self, func: &Value, args: Vec<Value>) -> Result<Value, String> {
+        match self.call_user_function(func, &args) {
+            Value::Error(message) | Value::ErrorObject { message, .. } => Err(message),
+            value => Ok(value),
+        }
+    }
+
+    fn step_generator(&mut self, generator: &mut Value) -> Result<Option<Value>, String> {
+        match self.generator_next(generator) {
+            Value::Option { is_some: true, value } => Ok(Some(*value)),
+            Value::Option { is_some: false, .. } => Ok(None),
+            Value::Error(message) | Value::ErrorObject { message, .. } => Err(message),
+            other => Err(format!("Generator step returned {:?} instead of an Option", other)),
+        }
+    }
+}
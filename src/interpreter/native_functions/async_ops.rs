@@ -589,6 +589,111 @@ fn cache_promise_result(
     Ok(())
 }
 
+/// Callback attached with `then`/`catch`/`finally`; runs on the chain's background thread.
+pub(crate) type PromiseCallback = Box<dyn FnOnce(Vec<Value>) -> Value + Send>;
+
+/// The message of a settled promise that was rejected or resolved to an error value.
+fn promise_rejection(result: &Result<Value, String>) -> Option<&str> {
+    match result {
+        Err(message) => Some(message),
+        Ok(Value::Error(message)) | Ok(Value::ErrorObject { message, .. }) => Some(message),
+        Ok(_) => None,
+    }
+}
+
+/// Block the current thread until `promise` settles, caching the result on it.
+pub(crate) fn block_on_promise(promise: &Value) -> Result<Value, String> {
+    let Value::Promise { receiver, is_polled, cached_result, .. } = promise else {
+        return Ok(promise.clone());
+    };
+    if let Some(cached) = read_cached_promise_result(is_polled, cached_result) {
+        return cached;
+    }
+
+    let actual_rx = {
+        let mut recv_guard = lock_or_async_error(receiver.as_ref(), "promise.receiver")?;
+        let (dummy_tx, dummy_rx) = tokio::sync::oneshot::channel();
+        drop(dummy_tx);
+        std::mem::replace(&mut *recv_guard, dummy_rx)
+    };
+    let result = AsyncRuntime::block_on(actual_rx)
+        .unwrap_or_else(|_| Err("Promise never resolved".to_string()));
+    cache_promise_result(is_polled, cached_result, result.clone())?;
+    result
+}
+
+/// Derive a promise from `promise` for `.then(f)`, `.catch(f)`, or `.finally(f)`.
+///
+/// The source's receiver is replaced by a relay that the chain thread fills once the
+/// source settles, so the source can still be awaited or chained again. `then` maps a
+/// fulfilled value, `catch` maps a rejection (given as an `Error` struct, as in
+/// `except`), and `finally` runs with no arguments and passes the original outcome
+/// through. A callback that returns a promise is flattened into the derived promise.
+pub(crate) fn chain_promise(promise: &Value, method: &str, callback: PromiseCallback) -> Value {
+    let Value::Promise { receiver, is_polled, cached_result, .. } = promise else {
+        return Value::Error(format!("Promise.{} requires a Promise receiver", method));
+    };
+
+    let pending = match read_cached_promise_result(is_polled, cached_result) {
+        Some(settled) => Err(settled),
+        None => {
+            let mut recv_guard = match lock_or_async_error(receiver.as_ref(), "promise.receiver") {
+                Ok(guard) => guard,
+                Err(error) => return Value::Error(error),
+            };
+            let (relay_tx, relay_rx) = tokio::sync::oneshot::channel();
+            Ok((std::mem::replace(&mut *recv_guard, relay_rx), relay_tx))
+        }
+    };
+
+    let method = method.to_string();
+    let (tx, rx) = tokio::sync::oneshot::channel();
+    std::thread::spawn(move || {
+        let settled = match pending {
+            Err(settled) => settled,
+            Ok((source_rx, relay_tx)) => {
+                let settled = AsyncRuntime::block_on(source_rx)
+                    .unwrap_or_else(|_| Err("Promise never resolved".to_string()));
+                let _ = relay_tx.send(settled.clone());
+                settled
+            }
+        };
+
+        let rejection = promise_rejection(&settled).map(str::to_string);
+        let derived = match (method.as_str(), rejection) {
+            ("then", None) => callback(vec![settled.clone().unwrap_or(Value::Null)]),
+            ("catch", Some(message)) => {
+                let error = match settled {
+                    Ok(error_value) => error_value,
+                    Err(_) => Value::Error(message),
+                };
+                callback(vec![error.into_caught_error_binding()])
+            }
+            ("finally", _) => {
+                let cleanup = block_on_promise(&callback(Vec::new()));
+                let _ = tx.send(match cleanup {
+                    Ok(value) if promise_rejection(&Ok(value.clone())).is_some() => Ok(value),
+                    Ok(_) => settled,
+                    Err(message) => Err(message),
+                });
+                return;
+            }
+            _ => {
+                let _ = tx.send(settled);
+                return;
+            }
+        };
+        let _ = tx.send(block_on_promise(&derived));
+    });
+
+    Value::Promise {
+        receiver: Arc::new(Mutex::new(rx)),
+        is_polled: Arc::new(Mutex::new(false)),
+        cached_result: Arc::new(Mutex::new(None)),
+        task_handle: None,
+    }
+}
+
 fn supports_rayon_parallel_map_native(mapper_name: &str) -> bool {
     matches!(mapper_name, "len" | "to_upper" | "upper" | "to_lower" | "lower")
 }
@@ -2641,7 +2746,7 @@ mod tests {
         chunk.emit(OpCode::Return);
 
         Value::BytecodeFunction {
-            chunk,
+            chunk: Arc::new(chunk),
             captured: HashMap::<String, Arc<Mutex<Value>>>::new(),
             captured_binding_kinds: HashMap::new(),
         }
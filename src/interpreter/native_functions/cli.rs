@@ -0,0 +1,311 @@
+// File: src/interpreter/native_functions/cli.rs
+//
+// `os` and `flags` namespaces: what a script needs to act as a command-line tool.
+//
+// `os.args` (an export built in builtins.rs), `os.env()`, and `os.exit()` expose the
+// script's arguments, environment, and exit code. `flags.parse()` parses options from a
+// declarative spec dict and `flags.help()` renders the matching help text; both share the
+// parser behind `arg_parser()`.
+
+use super::system::{dict_entries, error_object, value_type_name};
+use crate::builtins::{self, ArgumentDef};
+use crate::interpreter::{DictMap, Interpreter, Value};
+use std::collections::HashSet;
+use std::sync::Arc;
+
+/// A validated `flags` spec.
+struct FlagSpec {
+    name: String,
+    description: String,
+    defs: Vec<ArgumentDef>,
+    /// Whether `-h`/`--help` is handled by `flags.parse()` instead of a user-defined flag.
+    auto_help: bool,
+}
+
+impl FlagSpec {
+    fn help_text(&self) -> String {
+        let mut defs = self.defs.clone();
+        if self.auto_help {
+            defs.push(ArgumentDef {
+                long_name: "--help".to_string(),
+                short_name: Some("-h".to_string()),
+                arg_type: "bool".to_string(),
+                required: false,
+                help: "Show this help and exit".to_string(),
+                default: None,
+            });
+        }
+        builtins::generate_help(&defs, &self.name, &self.description)
+    }
+}
+
+fn optional_string(function: &str, key: &str, value: &Value) -> Result<String, Value> {
+    match value {
+        Value::Str(text) => Ok(text.as_ref().clone()),
+        other => Err(Value::Error(format!(
+            "{}() spec field '{}' must be a string, got {}",
+            function,
+            key,
+            value_type_name(other)
+        ))),
+    }
+}
+
+/// Renders a flag default the way `ArgumentDef` stores it, rejecting values that do not
+/// match the flag's type.
+fn default_text(
+    function: &str,
+    flag: &str,
+    arg_type: &str,
+    value: &Value,
+) -> Result<String, Value> {
+    match (arg_type, value) {
+        ("bool", Value::Bool(flag)) => Ok(flag.to_string()),
+        ("string", Value::Str(text)) => Ok(text.as_ref().clone()),
+        ("int", Value::Int(number)) => Ok(number.to_string()),
+        ("float", Value::Float(number)) => Ok(number.to_string()),
+        ("float", Value::Int(number)) => Ok(number.to_string()),
+        (_, other) => Err(Value::Error(format!(
+            "{}() flag '{}' has type {} but its default is {}",
+            function,
+            flag,
+            arg_type,
+            value_type_name(other)
+        ))),
+    }
+}
+
+fn parse_flag(function: &str, value: &Value) -> Result<ArgumentDef, Value> {
+    let Some(entries) = dict_entries(value) else {
+        return Err(Value::Error(format!(
+            "{}() expects each flag to be a dict, got {}",
+            function,
+            value_type_name(value)
+        )));
+    };
+
+    let mut name = None;
+    let mut short = None;
+    let mut arg_type = None;
+    let mut default = None;
+    let mut required = false;
+    let mut help = String::new();
+    for (key, value) in &entries {
+        match key.as_str() {
+            "name" => name = Some(optional_string(function, key, value)?),
+            "short" => short = Some(optional_string(function, key, value)?),
+            "type" => arg_type = Some(optional_string(function, key, value)?),
+            "help" => help = optional_string(function, key, value)?,
+            "default" => default = Some(value.clone()),
+            "required" => match value {
+                Value::Bool(flag) => required = *flag,
+                other => {
+                    return Err(Value::Error(format!(
+                        "{}() flag field 'required' must be a bool, got {}",
+                        function,
+                        value_type_name(other)
+                    )))
+                }
+            },
+            other => {
+                return Err(Value::Error(format!(
+                    "{}() got unknown flag field '{}' (supported: name, short, type, default, required, help)",
+                    function, other
+                )))
+            }
+        }
+    }
+
+    let Some(name) = name.map(|name| name.trim_start_matches('-').to_string()) else {
+        return Err(Value::Error(format!("{}() requires a 'name' for every flag", function)));
+    };
+    if name.is_empty() || name.contains(|c: char| c == '=' || c.is_whitespace()) {
+        return Err(Value::Error(format!("{}() got an invalid flag name '{}'", function, name)));
+    }
+
+    let short = match short.map(|short| short.trim_start_matches('-').to_string()) {
+        Some(short) if short.chars().count() == 1 && short != "=" => Some(format!("-{}", short)),
+        Some(short) => {
+            return Err(Value::Error(format!(
+                "{}() flag '{}' needs a single-character 'short', got '{}'",
+                function, name, short
+            )))
+        }
+        None => None,
+    };
+
+    // Without an explicit type, the default's type decides; plain flags take a string
+    let arg_type = match (arg_type, &default) {
+        (Some(arg_type), _) => arg_type,
+        (None, Some(Value::Bool(_))) => "bool".to_string(),
+        (None, Some(Value::Int(_))) => "int".to_string(),
+        (None, Some(Value::Float(_))) => "float".to_string(),
+        (None, _) => "string".to_string(),
+    };
+    if !matches!(arg_type.as_str(), "bool" | "string" | "int" | "float") {
+        return Err(Value::Error(format!(
+            "{}() flag '{}' has unknown type '{}' (supported: bool, string, int, float)",
+            function, name, arg_type
+        )));
+    }
+
+    let default = match default {
+        Some(Value::Null) | None => None,
+        Some(value) => Some(default_text(function, &name, &arg_type, &value)?),
+    };
+
+    Ok(ArgumentDef {
+        long_name: format!("--{}", name),
+        short_name: short,
+        arg_type,
+        required,
+        help,
+        default,
+    })
+}
+
+fn parse_spec(function: &str, value: &Value) -> Result<FlagSpec, Value> {
+    let Some(entries) = dict_entries(value) else {
+        return Err(Value::Error(format!(
+            "{}() expects a spec dict, got {}",
+            function,
+            value_type_name(value)
+        )));
+    };
+
+    let mut spec = FlagSpec {
+        name: "program".to_string(),
+        description: String::new(),
+        defs: Vec::new(),
+        auto_help: true,
+    };
+    for (key, value) in &entries {
+        match key.as_str() {
+            "name" => spec.name = optional_string(function, key, value)?,
+            "description" => spec.description = optional_string(function, key, value)?,
+            "flags" => {
+                let Value::Array(flags) = value else {
+                    return Err(Value::Error(format!(
+                        "{}() spec field 'flags' must be an array, got {}",
+                        function,
+                        value_type_name(value)
+                    )));
+                };
+                for flag in flags.iter() {
+                    spec.defs.push(parse_flag(function, flag)?);
+                }
+            }
+            other => {
+                return Err(Value::Error(format!(
+                    "{}() got unknown spec field '{}' (supported: name, description, flags)",
+                    function, other
+                )))
+            }
+        }
+    }
+
+    let mut seen = HashSet::new();
+    for def in &spec.defs {
+        for spelling in std::iter::once(&def.long_name).chain(def.short_name.as_ref()) {
+            if !seen.insert(spelling.clone()) {
+                return Err(Value::Error(format!(
+                    "{}() defines {} more than once",
+                    function, spelling
+                )));
+            }
+        }
+    }
+    spec.auto_help = !seen.contains("--help") && !seen.contains("-h");
+
+    Ok(spec)
+}
+
+fn string_arguments(function: &str, value: &Value) -> Result<Vec<String>, Value> {
+    let Value::Array(items) = value else {
+        return Err(Value::Error(format!(
+            "{}() expects argv to be an array of strings, got {}",
+            function,
+            value_type_name(value)
+        )));
+    };
+    items
+        .iter()
+        .map(|item| match item {
+            Value::Str(text) => Ok(text.as_ref().clone()),
+            other => Err(Value::Error(format!(
+                "{}() expects argv to be an array of strings, found {}",
+                function,
+                value_type_name(other)
+            ))),
+        })
+        .collect()
+}
+
+fn flags_parse(interp: &mut Interpreter, args: &[Value]) -> Value {
+    let spec = match args.first().map(|value| parse_spec("flags.parse", value)) {
+        Some(Ok(spec)) => spec,
+        Some(Err(error)) => return error,
+        None => return Value::Error("flags.parse() requires a spec dict".to_string()),
+    };
+    let argv = match args.get(1) {
+        Some(value) => match string_arguments("flags.parse", value) {
+            Ok(argv) => argv,
+            Err(error) => return error,
+        },
+        None => builtins::get_args(),
+    };
+
+    // `-h`/`--help` anywhere before a bare `--` prints the help text and ends the script
+    if spec.auto_help
+        && argv.iter().take_while(|arg| *arg != "--").any(|arg| arg == "--help" || arg == "-h")
+    {
+        interp.write_output_text(&spec.help_text());
+        std::process::exit(0);
+    }
+
+    match builtins::parse_arguments(&spec.defs, &argv) {
+        Ok(mut parsed) => {
+            if !parsed.contains_key("_positional") {
+                parsed.insert("_positional".into(), Value::Array(Arc::new(Vec::new())));
+            }
+            Value::Dict(Arc::new(parsed))
+        }
+        Err(message) => error_object(format!("flags.parse(): {}", message)),
+    }
+}
+
+fn os_env(args: &[Value]) -> Value {
+    match args {
+        [] => {
+            let mut vars = DictMap::default();
+            for (key, value) in builtins::env_list() {
+                vars.insert(Arc::<str>::from(key), Value::Str(Arc::new(value)));
+            }
+            Value::Dict(Arc::new(vars))
+        }
+        [Value::Str(name), rest @ ..] => match std::env::var(name.as_ref()) {
+            Ok(value) => Value::Str(Arc::new(value)),
+            Err(_) => rest.first().cloned().unwrap_or(Value::Null),
+        },
+        [other, ..] => Value::Error(format!(
+            "os.env() expects a string variable name, got {}",
+            value_type_name(other)
+        )),
+    }
+}
+
+pub fn handle(interp: &mut Interpreter, name: &str, args: &[Value]) -> Option<Value> {
+    let result = match name {
+        "os.env" => os_env(args),
+        // Same exit path as the global `exit()`
+        "os.exit" => return super::system::handle("exit", args),
+        "flags.parse" => flags_parse(interp, args),
+        "flags.help" => match args.first().map(|value| parse_spec("flags.help", value)) {
+            Some(Ok(spec)) => Value::Str(Arc::new(spec.help_text())),
+            Some(Err(error)) => error,
+            None => Value::Error("flags.help() requires a spec dict".to_string()),
+        },
+        _ => return None,
+    };
+    Some(result)
+}
@@ -3,7 +3,9 @@
 // Collection manipulation native functions (arrays, dicts, sets)
 
 use crate::builtins;
-use crate::interpreter::{DictMap, IntDictMap, Interpreter, Value};
+use crate::interpreter::{
+    DictMap, IntDictMap, Interpreter, OrderedDictMap, Sequence, SequenceHost, Value,
+};
 use std::collections::{HashSet, VecDeque};
 use std::sync::Arc;
 
@@ -25,16 +27,42 @@ fn fixed_dict_to_dict(keys: &[Arc<str>], values: &[Value]) -> DictMap {
     dict
 }
 
-fn parse_slice_bound(value: &Value, label: &str) -> Result<i64, Value> {
+/// `ordmap(pairs)`: an ordered map holding `[key, value]` pairs in array order.
+/// A repeated key keeps its first position and takes the last value.
+fn ordered_dict_from_pairs(pairs: &[Value]) -> Value {
+    let mut map = OrderedDictMap::default();
+    for pair in pairs {
+        let Value::Array(entry) = pair else {
+            return Value::Error("ordmap() entries must be [key, value] pairs".to_string());
+        };
+        let [key, value] = entry.as_slice() else {
+            return Value::Error("ordmap() entries must be [key, value] pairs".to_string());
+        };
+        match Value::dict_key(key) {
+            Ok(key) => {
+                map.insert(key, value.clone());
+            }
+            Err(message) => return Value::Error(format!("ordmap() {}", message)),
+        }
+    }
+    Value::OrderedDict(Arc::new(map))
+}
+
+/// A `null` bound means "run to that end", which is what `value[:end]` and `value[start:]`
+/// pass for the missing side.
+fn parse_slice_bound(value: &Value, label: &str) -> Result<Option<i64>, Value> {
     match value {
-        Value::Int(number) => Ok(*number),
-        Value::Float(number) if number.is_finite() => Ok(*number as i64),
+        Value::Int(number) => Ok(Some(*number)),
+        Value::Float(number) if number.is_finite() => Ok(Some(*number as i64)),
+        Value::Null => Ok(None),
         _ => Err(Value::Error(format!("slice() {} must be a number", label))),
     }
 }
 
-fn normalize_slice_bounds(len: usize, start: i64, end: i64) -> (usize, usize) {
+fn normalize_slice_bounds(len: usize, start: Option<i64>, end: Option<i64>) -> (usize, usize) {
     let len_i64 = len as i64;
+    let start = start.unwrap_or(0);
+    let end = end.unwrap_or(len_i64);
     let start_idx = if start < 0 { len_i64 + start } else { start };
     let start_idx = start_idx.max(0).min(len_i64);
     let end_idx = if end < 0 { len_i64 + end } else { end };
@@ -42,8 +70,428 @@ fn normalize_slice_bounds(len: usize, start: i64, end: i64) -> (usize, usize) {
     (start_idx as usize, end_idx as usize)
 }
 
+/// One step of a `get_path`/`set_path` path.
+///
+/// `Key` segments come from dotted names or quoted brackets and index dicts by key; on arrays
+/// they are accepted only when they spell a non-negative integer. `Index` segments come from
+/// `[n]` brackets or integer entries in an array path and index arrays directly.
+#[derive(Debug, Clone, PartialEq)]
+enum PathSegment {
+    Key(String),
+    Index(i64),
+}
+
+impl PathSegment {
+    fn key_text(&self) -> String {
+        match self {
+            PathSegment::Key(key) => key.clone(),
+            PathSegment::Index(index) => index.to_string(),
+        }
+    }
+
+    fn as_int(&self) -> Option<i64> {
+        match self {
+            PathSegment::Key(key) => key.parse::<i64>().ok(),
+            PathSegment::Index(index) => Some(*index),
+        }
+    }
+
+    fn as_array_index(&self) -> Option<usize> {
+        match self {
+            PathSegment::Key(key) if key.bytes().all(|byte| byte.is_ascii_digit()) => {
+                key.parse::<usize>().ok()
+            }
+            PathSegment::Key(_) => None,
+            PathSegment::Index(index) => usize::try_from(*index).ok(),
+        }
+    }
+}
+
+/// Parse a dotted/bracketed path string such as `a.b[0]["x.y"]` into segments.
+fn parse_path_string(path: &str) -> Result<Vec<PathSegment>, String> {
+    let chars: Vec<char> = path.chars().collect();
+    let mut segments = Vec::new();
+    let mut pos = 0;
+    let mut expect_segment = true;
+
+    while pos < chars.len() {
+        match chars[pos] {
+            '.' => {
+                if expect_segment {
+                    return Err(format!("empty segment at position {}", pos));
+                }
+                expect_segment = true;
+                pos += 1;
+                if pos >= chars.len() {
+                    return Err("path must not end with '.'".to_string());
+                }
+            }
+            '[' => {
+                pos += 1;
+                if pos < chars.len() && chars[pos] == '"' {
+                    pos += 1;
+                    let mut key = String::new();
+                    loop {
+                        match chars.get(pos) {
+                            None => return Err("unterminated quoted key".to_string()),
+                            Some('"') => break,
+                            Some('\\') => match chars.get(pos + 1) {
+                                Some(escaped @ ('"' | '\\')) => {
+                                    key.push(*escaped);
+                                    pos += 2;
+                                }
+                                _ => {
+                                    return Err(format!("invalid escape at position {}", pos));
+                                }
+                            },
+                            Some(ch) => {
+                                key.push(*ch);
+                                pos += 1;
+                            }
+                        }
+                    }
+                    pos += 1;
+                    if chars.get(pos) != Some(&']') {
+                        return Err(format!("expected ']' at position {}", pos));
+                    }
+                    segments.push(PathSegment::Key(key));
+                } else {
+                    let start = pos;
+                    while pos < chars.len() && chars[pos].is_ascii_digit() {
+                        pos += 1;
+                    }
+                    if start == pos || chars.get(pos) != Some(&']') {
+                        return Err(format!(
+                            "expected a non-negative integer or quoted key after '[' at position {}",
+                            start - 1
+                        ));
+                    }
+                    let digits: String = chars[start..pos].iter().collect();
+                    let index = digits
+                        .parse::<i64>()
+                        .map_err(|_| format!("index '{}' is out of range", digits))?;
+                    segments.push(PathSegment::Index(index));
+                }
+                pos += 1;
+                expect_segment = false;
+            }
+            ']' => return Err(format!("unexpected ']' at position {}", pos)),
+            _ => {
+                if !expect_segment {
+                    return Err(format!("expected '.' or '[' at position {}", pos));
+                }
+                let start = pos;
+                while pos < chars.len() && !matches!(chars[pos], '.' | '[' | ']') {
+                    pos += 1;
+                }
+                segments.push(PathSegment::Key(chars[start..pos].iter().collect()));
+                expect_segment = false;
+            }
+        }
+    }
+
+    Ok(segments)
+}
+
+/// Accept either a path string or an array of string/int segments.
+fn parse_data_path(function_name: &str, path: &Value) -> Result<Vec<PathSegment>, Value> {
+    match path {
+        Value::Str(text) => parse_path_string(text).map_err(|message| {
+            Value::Error(format!(
+                "{}() invalid path {:?}: {}",
+                function_name,
+                text.as_str(),
+                message
+            ))
+        }),
+        Value::Array(items) => items
+            .iter()
+            .map(|item| match item {
+                Value::Str(key) => Ok(PathSegment::Key(key.as_ref().clone())),
+                Value::Int(index) => Ok(PathSegment::Index(*index)),
+                _ => Err(Value::Error(format!(
+                    "{}() path array entries must be strings or integers",
+                    function_name
+                ))),
+            })
+            .collect(),
+        _ => Err(Value::Error(format!(
+            "{}() path must be a string or an array of segments",
+            function_name
+        ))),
+    }
+}
+
+fn int_dict_entries(value: &Value) -> Option<IntDictMap> {
+    let mut dict = IntDictMap::default();
+    match value {
+        Value::IntDict(entries) => return Some((**entries).clone()),
+        Value::DenseIntDict(values) => {
+            for (index, value) in values.iter().enumerate() {
+                dict.insert(index as i64, value.clone());
+            }
+        }
+        Value::DenseIntDictInt(values) => {
+            for (index, value) in values.iter().enumerate() {
+                if let Some(value) = value {
+                    dict.insert(index as i64, Value::Int(*value));
+                }
+            }
+        }
+        Value::DenseIntDictIntFull(values) => {
+            for (index, value) in values.iter().enumerate() {
+                dict.insert(index as i64, Value::Int(*value));
+            }
+        }
+        _ => return None,
+    }
+    Some(dict)
+}
+
+/// Resolve one path segment against a container, returning `None` when it is missing.
+fn path_step(current: &Value, segment: &PathSegment) -> Option<Value> {
+    match current {
+        Value::Dict(dict) => dict.get(segment.key_text().as_str()).cloned(),
+        Value::FixedDict { keys, values } => {
+            let key = segment.key_text();
+            keys.iter().position(|k| k.as_ref() == key).and_then(|i| values.get(i).cloned())
+        }
+        Value::Array(items) => segment.as_array_index().and_then(|index| items.get(index).cloned()),
+        Value::IntDict(_)
+        | Value::DenseIntDict(_)
+        | Value::DenseIntDictInt(_)
+        | Value::DenseIntDictIntFull(_) => {
+            let key = segment.as_int()?;
+            int_dict_entries(current)?.get(&key).cloned()
+        }
+        _ => None,
+    }
+}
+
+/// Return a copy of `current` with `value` stored at `segments`, creating missing
+/// intermediates (arrays for `Index` segments, dicts otherwise).
+fn path_assign(current: &Value, segments: &[PathSegment], value: Value) -> Result<Value, String> {
+    let Some((segment, rest)) = segments.split_first() else {
+        return Ok(value);
+    };
+
+    match current {
+        Value::Null => {
+            let container = match segment {
+                PathSegment::Index(_) => Value::Array(Arc::new(Vec::new())),
+                PathSegment::Key(_) => Value::Dict(Arc::new(DictMap::default())),
+            };
+            path_assign(&container, segments, value)
+        }
+        Value::Dict(_) | Value::FixedDict { .. } => {
+            let mut dict = match current {
+                Value::Dict(dict) => (**dict).clone(),
+                Value::FixedDict { keys, values } => fixed_dict_to_dict(keys, values),
+                _ => unreachable!(),
+            };
+            let key: Arc<str> = Arc::from(segment.key_text());
+            let child = dict.get(&key).cloned().unwrap_or(Value::Null);
+            dict.insert(key, path_assign(&child, rest, value)?);
+            Ok(Value::Dict(Arc::new(dict)))
+        }
+        Value::Array(items) => {
+            let index = segment.as_array_index().ok_or_else(|| {
+                format!("array index must be a non-negative integer, got '{}'", segment.key_text())
+            })?;
+            let mut items = (**items).clone();
+            let child = items.get(index).cloned().unwrap_or(Value::Null);
+            let updated = path_assign(&child, rest, value)?;
+            if index >= items.len() {
+                items.resize(index + 1, Value::Null);
+            }
+            items[index] = updated;
+            Ok(Value::Array(Arc::new(items)))
+        }
+        Value::IntDict(_)
+        | Value::DenseIntDict(_)
+        | Value::DenseIntDictInt(_)
+        | Value::DenseIntDictIntFull(_) => {
+            let key = segment.as_int().ok_or_else(|| {
+                format!("integer-keyed dict requires an integer key, got '{}'", segment.key_text())
+            })?;
+            let mut dict = int_dict_entries(current).unwrap_or_default();
+            let child = dict.get(&key).cloned().unwrap_or(Value::Null);
+            dict.insert(key, path_assign(&child, rest, value)?);
+            Ok(Value::IntDict(Arc::new(dict)))
+        }
+        _ => Err(format!(
+            "cannot set '{}' inside a non-container value ({})",
+            segment.key_text(),
+            Interpreter::value_type_name(current)
+        )),
+    }
+}
+
+fn int_arg(method: &str, label: &str, value: &Value) -> Result<i64, String> {
+    match value {
+        Value::Int(number) => Ok(*number),
+        other => Err(format!(
+            "iter.{}() {} must be an int, got {}",
+            method,
+            label,
+            Interpreter::value_type_name(other)
+        )),
+    }
+}
+
+/// Exports of the `iter` namespace. Every stage wraps its input in a lazy `Sequence`;
+/// only `reduce` and `collect` pull elements, through `host`, so both engines share this.
+pub fn call_iter_module(
+    host: &mut dyn SequenceHost,
+    method: &str,
+    args: &[Value],
+) -> Result<Value, String> {
+    let sequence = match method {
+        "range" => {
+            let labels: &[&str] =
+                if args.len() == 1 { &["end"] } else { &["start", "end", "step"] };
+            let bounds = args
+                .iter()
+                .zip(labels)
+                .map(|(value, label)| int_arg(method, label, value))
+                .collect::<Result<Vec<_>, _>>()?;
+            let (start, end, step) = match bounds.as_slice() {
+                [end] => (0, *end, 1),
+                [start, end] => (*start, *end, 1),
+                [start, end, step] => (*start, *end, *step),
+                _ => return Err("iter.range() expects 1 to 3 arguments".to_string()),
+            };
+            if step == 0 {
+                return Err("iter.range() step must not be zero".to_string());
+            }
+            Sequence::Range { start, end, step }
+        }
+        "from" => return Sequence::from_iterable(&args[0]).map(Value::Sequence),
+        "map" => {
+            Sequence::Map { source: Sequence::from_iterable(&args[0])?, func: args[1].clone() }
+        }
+        "filter" => Sequence::Filter {
+            source: Sequence::from_iterable(&args[0])?,
+            predicate: args[1].clone(),
+        },
+        "take" => {
+            let count = int_arg(method, "count", &args[1])?;
+            if count < 0 {
+                return Err("iter.take() count must not be negative".to_string());
+            }
+            Sequence::Take { source: Sequence::from_iterable(&args[0])?, count: count as usize }
+        }
+        "zip" => {
+            Sequence::Zip(Sequence::from_iterable(&args[0])?, Sequence::from_iterable(&args[1])?)
+        }
+        "enumerate" => Sequence::Enumerate(Sequence::from_iterable(&args[0])?),
+        "reduce" => {
+            let mut cursor = Sequence::from_iterable(&args[0])?.cursor();
+            let mut accumulator = args[1].clone();
+            while let Some(value) = cursor.next(host)? {
+                accumulator = host.call_sequence_callback(&args[2], vec![accumulator, value])?;
+            }
+            return Ok(accumulator);
+        }
+        "collect" => {
+            let values = Sequence::from_iterable(&args[0])?.collect(host)?;
+            return Ok(Value::Array(Arc::new(values)));
+        }
+        _ => return Err(format!("Module 'iter' has no export '{}'", method)),
+    };
+    Ok(Value::Sequence(Arc::new(sequence)))
+}
+
+/// Array builtins that can also be called as methods: `values.push(4)` is `push(values, 4)`.
+/// Like the builtins they return new arrays rather than changing the receiver.
+pub const ARRAY_METHODS: &[&str] = &[
+    "len", "push", "pop", "insert", "remove", "slice", "sort", "reverse", "index_of", "contains",
+    "join", "flatten", "unique",
+];
+
+/// `sort(values, compare)`: a stable merge sort driven by a user comparator. `compare(a, b)`
+/// returns a number; negative puts `a` first, positive puts `b` first, and zero keeps the
+/// original order. Merging by hand means an inconsistent comparator gives some order rather
+/// than a panic.
+pub fn sort_with_comparator(
+    host: &mut dyn SequenceHost,
+    values: &[Value],
+    compare: &Value,
+) -> Result<Vec<Value>, String> {
+    if values.len() < 2 {
+        return Ok(values.to_vec());
+    }
+    let (left, right) = values.split_at(values.len() / 2);
+    let left = sort_with_comparator(host, left, compare)?;
+    let right = sort_with_comparator(host, right, compare)?;
+
+    let mut merged = Vec::with_capacity(values.len());
+    let mut left = left.into_iter().peekable();
+    let mut right = right.into_iter().peekable();
+    while let (Some(a), Some(b)) = (left.peek(), right.peek()) {
+        let order = host.call_sequence_callback(compare, vec![a.clone(), b.clone()])?;
+        let b_first = match order {
+            Value::Int(number) => number > 0,
+            Value::Float(number) => number > 0.0,
+            other => {
+                return Err(format!(
+                    "sort() comparator must return a number, got {}",
+                    Interpreter::value_type_name(&other)
+                ))
+            }
+        };
+        let next = if b_first { right.next() } else { left.next() };
+        merged.extend(next);
+    }
+    merged.extend(left);
+    merged.extend(right);
+    Ok(merged)
+}
+
+/// `next(generator)`: resume a generator, giving `Some(value)` for the value it yields
+/// and `None` once it has finished.
+pub fn generator_step(host: &mut dyn SequenceHost, args: &[Value]) -> Result<Value, String> {
+    match args {
+        [generator @ (Value::Generator { .. } | Value::BytecodeGenerator { .. })] => {
+            let step = host.step_generator(&mut generator.clone())?;
+            Ok(Value::Option {
+                is_some: step.is_some(),
+                value: Box::new(step.unwrap_or(Value::Null)),
+            })
+        }
+        [other] => {
+            Err(format!("next() expects a generator, got {}", Interpreter::value_type_name(other)))
+        }
+        _ => Err(format!("next() expects 1 argument, got {}", args.len())),
+    }
+}
+
 pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Option<Value> {
+    // A composite key names the entry stored under its hash key text
+    if let ("has_key" | "get" | "get_default" | "remove", [map, key, rest @ ..]) =
+        (name, arg_values)
+    {
+        if let Some(key) = Value::map_key_index(map, key) {
+            let key = match key {
+                Ok(key) => key,
+                Err(message) => return Some(Value::Error(message)),
+            };
+            let mut args = vec![map.clone(), key];
+            args.extend(rest.iter().cloned());
+            return handle(interp, name, &args);
+        }
+    }
+
     let result = match name {
+        name if name.starts_with("iter.") => {
+            call_iter_module(interp, &name["iter.".len()..], arg_values)
+                .unwrap_or_else(Value::Error)
+        }
+        // Resumed directly so an error thrown in the body keeps its line and payload
+        "next" => match arg_values {
+            [generator @ Value::Generator { .. }] => interp.generator_next(&mut generator.clone()),
+            _ => generator_step(interp, arg_values).unwrap_or_else(Value::Error),
+        },
         // Polymorphic len function - handles arrays, dicts, sets, queues, stacks, bytes
         "len" => match arg_values.first() {
             Some(Value::Array(arr)) => Value::Int(arr.len() as i64),
@@ -52,6 +500,7 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
             Some(Value::DenseIntDict(values)) => Value::Int(values.len() as i64),
             Some(Value::DenseIntDictInt(values)) => Value::Int(values.len() as i64),
             Some(Value::DenseIntDictIntFull(values)) => Value::Int(values.len() as i64),
+            Some(Value::OrderedDict(map)) => Value::Int(map.len() as i64),
             Some(Value::Bytes(bytes)) => Value::Int(bytes.len() as i64),
             Some(Value::Set(set)) => Value::Int(set.len() as i64),
             Some(Value::Queue(queue)) => Value::Int(queue.len() as i64),
@@ -138,8 +587,14 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                         let (start_idx, end_idx) = normalize_slice_bounds(bytes.len(), start, end);
                         Value::Bytes(bytes[start_idx..end_idx].to_vec())
                     }
+                    Some(Value::Str(text)) => {
+                        // Strings slice by character, matching `len` and indexing
+                        let chars: Vec<char> = text.chars().collect();
+                        let (start_idx, end_idx) = normalize_slice_bounds(chars.len(), start, end);
+                        Value::Str(Arc::new(chars[start_idx..end_idx].iter().collect()))
+                    }
                     _ => Value::Error(
-                        "slice() requires array or bytes and numeric start/end arguments"
+                        "slice() requires an array, bytes, or string and numeric start/end arguments"
                             .to_string(),
                     ),
                 }
@@ -201,6 +656,13 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                         let removed = dict.remove(key.as_str()).unwrap_or(Value::Int(0));
                         Value::Array(Arc::new(vec![Value::Dict(Arc::new(dict)), removed]))
                     }
+                    (Some(Value::OrderedDict(mut map)), Some(Value::Str(key))) => {
+                        // Shifting keeps the remaining keys in insertion order
+                        let removed = Arc::make_mut(&mut map)
+                            .shift_remove(key.as_str())
+                            .unwrap_or(Value::Int(0));
+                        Value::Array(Arc::new(vec![Value::OrderedDict(map), removed]))
+                    }
                     (Some(Value::IntDict(dict)), Some(key_val)) => {
                         let int_key = match key_val {
                             Value::Int(i) => Some(*i),
@@ -350,6 +812,9 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                     Some(Value::Array(_)) => Value::Array(Arc::new(builtins::array_clear())),
                     Some(Value::Dict(_)) => Value::Dict(Arc::new(DictMap::default())),
                     Some(Value::FixedDict { .. }) => Value::Dict(Arc::new(DictMap::default())),
+                    Some(Value::OrderedDict(_)) => {
+                        Value::OrderedDict(Arc::new(OrderedDictMap::default()))
+                    }
                     _ => Value::Error("clear() requires an array or dict argument".to_string()),
                 }
             }
@@ -547,24 +1012,24 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         }
 
         "sort" => {
-            if 1 != arg_values.len() {
-                strict_arity_error("sort", 1, arg_values.len())
+            if !(1..=2).contains(&arg_values.len()) {
+                Value::Error(format!("sort expects 1 or 2 arguments, got {}", arg_values.len()))
+            } else if let (Some(Value::Array(arr)), Some(compare)) =
+                (arg_values.first(), arg_values.get(1))
+            {
+                if !matches!(compare, Value::Function(..) | Value::BytecodeFunction { .. }) {
+                    return Some(Value::Error(format!(
+                        "sort() comparator must be a function, got {}",
+                        Interpreter::value_type_name(compare)
+                    )));
+                }
+                match sort_with_comparator(interp, arr, compare) {
+                    Ok(sorted) => Value::Array(Arc::new(sorted)),
+                    Err(message) => Value::Error(message),
+                }
             } else if let Some(Value::Array(arr)) = arg_values.first() {
                 let mut sorted = (**arr).clone();
-                sorted.sort_by(|a, b| match (a, b) {
-                    (Value::Int(x), Value::Int(y)) => x.cmp(y),
-                    (Value::Float(x), Value::Float(y)) => {
-                        x.partial_cmp(y).unwrap_or(std::cmp::Ordering::Equal)
-                    }
-                    (Value::Int(x), Value::Float(y)) => {
-                        (*x as f64).partial_cmp(y).unwrap_or(std::cmp::Ordering::Equal)
-                    }
-                    (Value::Float(x), Value::Int(y)) => {
-                        x.partial_cmp(&(*y as f64)).unwrap_or(std::cmp::Ordering::Equal)
-                    }
-                    (Value::Str(x), Value::Str(y)) => x.as_ref().cmp(y.as_ref()),
-                    _ => std::cmp::Ordering::Equal,
-                });
+                sorted.sort_by(Value::total_cmp);
                 Value::Array(Arc::new(sorted))
             } else {
                 Value::Error("sort requires an array argument".to_string())
@@ -591,8 +1056,12 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                 let mut result = Vec::new();
 
                 for element in arr.iter() {
-                    let key = format!("{:?}", element);
-                    if seen.insert(key) {
+                    let is_new = match element.hash_key() {
+                        Ok(key) => seen.insert(key),
+                        // Functions and handles compare by identity and have no hash key
+                        Err(_) => !result.iter().any(|kept| Value::equals(kept, element)),
+                    };
+                    if is_new {
                         result.push(element.clone());
                     }
                 }
@@ -746,46 +1215,21 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         },
 
         // Dict functions
-        "keys" => {
-            if let Some(Value::Dict(dict)) = arg_values.first() {
-                let mut keys: Vec<String> = Vec::with_capacity(dict.len());
-                for key in dict.keys() {
-                    keys.push(key.to_string());
-                }
-                keys.sort();
-                let keys: Vec<Value> = keys.into_iter().map(|k| Value::Str(Arc::new(k))).collect();
-                Value::Array(Arc::new(keys))
-            } else if let Some(Value::FixedDict { keys, .. }) = arg_values.first() {
-                let mut key_strings: Vec<String> = keys.iter().map(|k| k.to_string()).collect();
-                key_strings.sort();
-                let keys: Vec<Value> =
-                    key_strings.into_iter().map(|k| Value::Str(Arc::new(k))).collect();
-                Value::Array(Arc::new(keys))
-            } else if let Some(Value::IntDict(dict)) = arg_values.first() {
-                let mut keys: Vec<i64> = dict.keys().copied().collect();
-                keys.sort();
-                let keys: Vec<Value> =
-                    keys.into_iter().map(|k| Value::Str(Arc::new(k.to_string()))).collect();
-                Value::Array(Arc::new(keys))
-            } else if let Some(Value::DenseIntDict(values)) = arg_values.first() {
-                let keys: Vec<Value> =
-                    (0..values.len()).map(|k| Value::Str(Arc::new(k.to_string()))).collect();
-                Value::Array(Arc::new(keys))
-            } else if let Some(Value::DenseIntDictInt(values)) = arg_values.first() {
-                let keys: Vec<Value> =
-                    (0..values.len()).map(|k| Value::Str(Arc::new(k.to_string()))).collect();
-                Value::Array(Arc::new(keys))
-            } else if let Some(Value::DenseIntDictIntFull(values)) = arg_values.first() {
-                let keys: Vec<Value> =
-                    (0..values.len()).map(|k| Value::Str(Arc::new(k.to_string()))).collect();
-                Value::Array(Arc::new(keys))
-            } else {
-                Value::Array(Arc::new(vec![]))
-            }
-        }
+        "ordmap" => match arg_values {
+            [] => Value::OrderedDict(Arc::new(OrderedDictMap::default())),
+            [Value::Array(pairs)] => ordered_dict_from_pairs(pairs),
+            [Value::OrderedDict(map)] => Value::OrderedDict(map.clone()),
+            _ => Value::Error("ordmap() takes an optional array of [key, value] pairs".to_string()),
+        },
+
+        "keys" => Value::Array(Arc::new(
+            arg_values.first().and_then(Value::dict_keys_in_order).unwrap_or_default(),
+        )),
 
         "values" => {
-            if let Some(Value::Dict(dict)) = arg_values.first() {
+            if let Some(Value::OrderedDict(map)) = arg_values.first() {
+                Value::Array(Arc::new(map.values().cloned().collect()))
+            } else if let Some(Value::Dict(dict)) = arg_values.first() {
                 let mut keys: Vec<&Arc<str>> = Vec::with_capacity(dict.len());
                 for key in dict.keys() {
                     keys.push(key);
@@ -821,7 +1265,12 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         }
 
         "has_key" => {
-            if let (Some(Value::Dict(dict)), Some(Value::Str(key))) =
+            if let (Some(Value::OrderedDict(map)), Some(key)) =
+                (arg_values.first(), arg_values.get(1))
+            {
+                let present = Value::dict_key(key).is_ok_and(|key| map.contains_key(&key));
+                Value::Int(if present { 1 } else { 0 })
+            } else if let (Some(Value::Dict(dict)), Some(Value::Str(key))) =
                 (arg_values.first(), arg_values.get(1))
             {
                 Value::Int(if dict.contains_key(key.as_str()) { 1 } else { 0 })
@@ -881,7 +1330,15 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         }
 
         "items" => {
-            if let Some(Value::Dict(dict)) = arg_values.first() {
+            if let Some(Value::OrderedDict(map)) = arg_values.first() {
+                let items: Vec<Value> = map
+                    .iter()
+                    .map(|(key, value)| {
+                        Value::Array(Arc::new(vec![Value::map_key_value(key), value.clone()]))
+                    })
+                    .collect();
+                Value::Array(Arc::new(items))
+            } else if let Some(Value::Dict(dict)) = arg_values.first() {
                 let mut keys: Vec<&Arc<str>> = Vec::with_capacity(dict.len());
                 for key in dict.keys() {
                     keys.push(key);
@@ -891,7 +1348,7 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                     .iter()
                     .map(|k| {
                         Value::Array(Arc::new(vec![
-                            Value::Str(Arc::new(k.to_string())),
+                            Value::map_key_value(k),
                             dict.get(k.as_ref()).unwrap().clone(),
                         ]))
                     })
@@ -903,10 +1360,7 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
                 let items: Vec<Value> = pairs
                     .iter()
                     .map(|(k, v)| {
-                        Value::Array(Arc::new(vec![
-                            Value::Str(Arc::new(k.to_string())),
-                            (*v).clone(),
-                        ]))
+                        Value::Array(Arc::new(vec![Value::map_key_value(k), (*v).clone()]))
                     })
                     .collect();
                 Value::Array(Arc::new(items))
@@ -965,7 +1419,12 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         }
 
         "get" => {
-            if let (Some(Value::Dict(dict)), Some(Value::Str(key))) =
+            if let (Some(Value::OrderedDict(map)), Some(key)) =
+                (arg_values.first(), arg_values.get(1))
+            {
+                let default = arg_values.get(2).cloned().unwrap_or(Value::Null);
+                Value::dict_key(key).ok().and_then(|key| map.get(&key).cloned()).unwrap_or(default)
+            } else if let (Some(Value::Dict(dict)), Some(Value::Str(key))) =
                 (arg_values.first(), arg_values.get(1))
             {
                 let default = arg_values.get(2).cloned().unwrap_or(Value::Null);
@@ -1365,6 +1824,34 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
             }
         }
 
+        "get_path" => {
+            let data = arg_values.first().cloned().unwrap_or(Value::Null);
+            let default = arg_values.get(2).cloned().unwrap_or(Value::Null);
+            match parse_data_path("get_path", arg_values.get(1).unwrap_or(&Value::Null)) {
+                Ok(segments) => {
+                    let mut current = data;
+                    for segment in &segments {
+                        match path_step(&current, segment) {
+                            Some(next) => current = next,
+                            None => return Some(default),
+                        }
+                    }
+                    current
+                }
+                Err(error) => error,
+            }
+        }
+
+        "set_path" => {
+            let data = arg_values.first().cloned().unwrap_or(Value::Null);
+            let value = arg_values.get(2).cloned().unwrap_or(Value::Null);
+            match parse_data_path("set_path", arg_values.get(1).unwrap_or(&Value::Null)) {
+                Ok(segments) => path_assign(&data, &segments, value)
+                    .unwrap_or_else(|message| Value::Error(format!("set_path() {}", message))),
+                Err(error) => error,
+            }
+        }
+
         // Set functions
         "Set" => {
             if arg_values.len() > 1 {
@@ -1629,6 +2116,31 @@ mod tests {
     use crate::interpreter::{Interpreter, LeakyFunctionBody, Value};
     use std::sync::Arc;
 
+    #[test]
+    fn test_parse_path_string_grammar() {
+        use super::{parse_path_string, PathSegment};
+
+        assert_eq!(parse_path_string("").unwrap(), vec![]);
+        assert_eq!(
+            parse_path_string(r#"a.b[2]["x.y"].0"#).unwrap(),
+            vec![
+                PathSegment::Key("a".to_string()),
+                PathSegment::Key("b".to_string()),
+                PathSegment::Index(2),
+                PathSegment::Key("x.y".to_string()),
+                PathSegment::Key("0".to_string()),
+            ]
+        );
+        assert_eq!(
+            parse_path_string(r#"[0]["q\"uote"]"#).unwrap(),
+            vec![PathSegment::Index(0), PathSegment::Key("q\"uote".to_string())]
+        );
+
+        for invalid in [".a", "a.", "a..b", "a[", "a[-1]", "a[x]", "a]b", "a[0]b", r#"a["x"#] {
+            assert!(parse_path_string(invalid).is_err(), "expected '{}' to be rejected", invalid);
+        }
+    }
+
     #[test]
     fn test_array_and_higher_order_collection_strict_arity_contracts() {
         let mut interpreter = Interpreter::new();
@@ -1690,7 +2202,11 @@ mod tests {
                 vec![array_arg.clone(), function_arg.clone(), Value::Int(1)],
                 "expects 2 arguments",
             ),
-            ("sort", vec![array_arg.clone(), Value::Int(1)], "expects 1 argument"),
+            (
+                "sort",
+                vec![array_arg.clone(), Value::Int(1), Value::Int(1)],
+                "expects 1 or 2 arguments",
+            ),
             ("reverse", vec![array_arg.clone(), Value::Int(1)], "expects 1 argument"),
             ("unique", vec![array_arg.clone(), Value::Int(1)], "expects 1 argument"),
             ("sum", vec![array_arg.clone(), Value::Int(1)], "expects 1 argument"),
@@ -1723,6 +2239,36 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_slice_null_bounds_and_string_characters() {
+        let mut interpreter = Interpreter::new();
+        let values = Value::Array(Arc::new(vec![
+            Value::Int(1),
+            Value::Int(2),
+            Value::Int(3),
+            Value::Int(4),
+        ]));
+
+        let tail =
+            handle(&mut interpreter, "slice", &[values.clone(), Value::Int(-2), Value::Null])
+                .expect("handler should match");
+        assert!(matches!(tail, Value::Array(items) if items.len() == 2
+            && matches!(&items[0], Value::Int(3))
+            && matches!(&items[1], Value::Int(4))));
+
+        let whole = handle(&mut interpreter, "slice", &[values, Value::Null, Value::Null])
+            .expect("handler should match");
+        assert!(matches!(whole, Value::Array(items) if items.len() == 4));
+
+        let text = handle(
+            &mut interpreter,
+            "slice",
+            &[Value::Str(Arc::new("héllo".to_string())), Value::Int(1), Value::Int(3)],
+        )
+        .expect("handler should match");
+        assert!(matches!(text, Value::Str(text) if text.as_str() == "él"));
+    }
+
     #[test]
     fn test_array_collection_behavior_contracts() {
         let mut interpreter = Interpreter::new();
@@ -1785,7 +2331,7 @@ mod tests {
             handle(&mut interpreter, "slice", &[Value::Null, Value::Int(0), Value::Int(1)])
                 .expect("handler");
         assert!(
-            matches!(slice_wrong_type, Value::Error(message) if message.contains("slice() requires array or bytes and numeric start/end arguments"))
+            matches!(slice_wrong_type, Value::Error(message) if message.contains("slice() requires an array, bytes, or string and numeric start/end arguments"))
         );
 
         let concat_wrong_type =
@@ -1801,6 +2347,54 @@ mod tests {
         );
     }
 
+    #[test]
+    fn test_ordmap_keeps_insertion_order_contracts() {
+        let mut interpreter = Interpreter::new();
+        let text = |value: &str| Value::Str(Arc::new(value.to_string()));
+        let pair =
+            |key: &str, value: i64| Value::Array(Arc::new(vec![text(key), Value::Int(value)]));
+        let key_names = |value: &Value| match value {
+            Value::Array(keys) => keys
+                .iter()
+                .map(|key| match key {
+                    Value::Str(key) => key.to_string(),
+                    other => panic!("Expected string key, got {:?}", other),
+                })
+                .collect::<Vec<_>>(),
+            other => panic!("Expected key array, got {:?}", other),
+        };
+
+        let pairs =
+            Value::Array(Arc::new(vec![pair("zeta", 1), pair("alpha", 2), pair("zeta", 3)]));
+        let map =
+            handle(&mut interpreter, "ordmap", &[pairs]).expect("ordmap handler should match");
+        let keys = handle(&mut interpreter, "keys", std::slice::from_ref(&map))
+            .expect("keys handler should match");
+        assert_eq!(
+            key_names(&keys),
+            vec!["zeta", "alpha"],
+            "re-inserting keeps the first position"
+        );
+        let values = handle(&mut interpreter, "values", std::slice::from_ref(&map))
+            .expect("values handler should match");
+        assert!(
+            matches!(values, Value::Array(values) if matches!(values.as_slice(), [Value::Int(3), Value::Int(2)]))
+        );
+
+        let removed = handle(&mut interpreter, "remove", &[map.clone(), text("zeta")])
+            .expect("remove handler should match");
+        assert!(matches!(removed, Value::Array(values) if values.len() == 2
+                && matches!(&values[0], Value::OrderedDict(map) if map.len() == 1 && map.contains_key("alpha"))
+                && matches!(&values[1], Value::Int(3))));
+
+        let empty = handle(&mut interpreter, "ordmap", &[]).expect("ordmap handler should match");
+        assert!(matches!(empty, Value::OrderedDict(map) if map.is_empty()));
+        let invalid =
+            handle(&mut interpreter, "ordmap", &[Value::Array(Arc::new(vec![text("x")]))])
+                .expect("ordmap handler should match");
+        assert!(matches!(invalid, Value::Error(message) if message.contains("[key, value] pairs")));
+    }
+
     #[test]
     fn test_fixed_dict_merge_clear_remove_contracts() {
         let mut interpreter = Interpreter::new();
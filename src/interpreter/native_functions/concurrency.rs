@@ -2,10 +2,15 @@
 //
 // Concurrency-related native functions (spawn, channels, etc.)
 
-use crate::interpreter::{Interpreter, Value};
+use crate::interpreter::{
+    ChannelPoll, ChannelState, DictMap, Interpreter, MutexState, SyncMapState, Value,
+    WaitGroupState,
+};
 use std::collections::HashMap;
+use std::sync::atomic::{AtomicI64, Ordering};
 use std::sync::OnceLock;
 use std::sync::{Arc, Mutex, MutexGuard};
+use std::time::{Duration, Instant};
 
 fn shared_value_store() -> &'static Mutex<HashMap<String, Arc<Mutex<Value>>>> {
     static SHARED_VALUE_STORE: OnceLock<Mutex<HashMap<String, Arc<Mutex<Value>>>>> =
@@ -20,6 +25,291 @@ fn lock_or_concurrency_error<'a, T>(
     mutex.lock().map_err(|_| Value::Error(format!("{}: shared state lock poisoned", context)))
 }
 
+/// `select(channels, timeout_ms?)`: wait for the first channel in `channels` with a value.
+///
+/// Returns `[index, value]` for the first ready channel in list order, or `[-1, null]`
+/// once every channel is closed and drained or the timeout elapses. A timeout of 0
+/// polls once without waiting.
+fn select_channels(arg_values: &[Value]) -> Value {
+    let channels = match arg_values.first() {
+        Some(Value::Array(values)) => {
+            let mut channels = Vec::with_capacity(values.len());
+            for value in values.iter() {
+                match value {
+                    Value::Channel(channel) => channels.push(Arc::clone(channel)),
+                    _ => {
+                        return Value::Error("select() expects an array of channels".to_string());
+                    }
+                }
+            }
+            channels
+        }
+        _ => return Value::Error("select() expects an array of channels".to_string()),
+    };
+
+    let deadline = match arg_values.get(1) {
+        None => None,
+        Some(Value::Int(timeout_ms)) if *timeout_ms >= 0 => {
+            Some(Instant::now() + Duration::from_millis(*timeout_ms as u64))
+        }
+        Some(_) => {
+            return Value::Error("select() timeout_ms must be a non-negative int".to_string());
+        }
+    };
+
+    let no_value = || Value::Array(Arc::new(vec![Value::Int(-1), Value::Null]));
+    loop {
+        let mut closed_count = 0;
+        for (index, channel) in channels.iter().enumerate() {
+            match channel.poll() {
+                ChannelPoll::Ready(value) => {
+                    return Value::Array(Arc::new(vec![Value::Int(index as i64), value]));
+                }
+                ChannelPoll::Closed => closed_count += 1,
+                ChannelPoll::Empty => {}
+            }
+        }
+
+        if closed_count == channels.len() {
+            return no_value();
+        }
+        if deadline.is_some_and(|deadline| Instant::now() >= deadline) {
+            return no_value();
+        }
+        std::thread::sleep(Duration::from_millis(1));
+    }
+}
+
+/// Shared `Channel` method dispatch used by both the interpreter and the VM.
+pub fn call_channel_method(channel: &ChannelState, method: &str, args: &[Value]) -> Value {
+    match method {
+        "send" => {
+            if args.len() != 1 {
+                return Value::Error(format!(
+                    "Channel.send expects 1 arguments, got {}",
+                    args.len()
+                ));
+            }
+            match channel.send(args[0].clone()) {
+                Ok(()) => Value::Bool(true),
+                Err(message) => Value::Error(message),
+            }
+        }
+        "receive" | "close" | "is_closed" if !args.is_empty() => {
+            Value::Error(format!("Channel.{} expects 0 arguments, got {}", method, args.len()))
+        }
+        // A closed, drained channel yields null, mirroring a zero-value receive.
+        "receive" => channel.receive().unwrap_or(Value::Null),
+        "close" => match channel.close() {
+            Ok(()) => Value::Null,
+            Err(message) => Value::Error(message),
+        },
+        "is_closed" => Value::Bool(channel.is_closed()),
+        _ => Value::Error(format!("Channel has no method '{}'", method)),
+    }
+}
+
+/// Shared `WaitGroup` method dispatch used by both the interpreter and the VM.
+pub fn call_wait_group_method(wait_group: &WaitGroupState, method: &str, args: &[Value]) -> Value {
+    let result = match (method, args) {
+        ("add", []) => wait_group.add(1),
+        ("add", [Value::Int(delta)]) => wait_group.add(*delta),
+        ("add", [_]) => return Value::Error("WaitGroup.add delta must be an int".to_string()),
+        ("done", []) => wait_group.add(-1),
+        ("wait", []) => {
+            wait_group.wait();
+            return Value::Null;
+        }
+        ("pending", []) => return Value::Int(wait_group.pending()),
+        ("add" | "done" | "wait" | "pending", _) => {
+            return Value::Error(format!(
+                "WaitGroup.{} got unexpected arguments ({})",
+                method,
+                args.len()
+            ));
+        }
+        _ => return Value::Error(format!("WaitGroup has no method '{}'", method)),
+    };
+
+    match result {
+        Ok(pending) => Value::Int(pending),
+        Err(message) => Value::Error(message),
+    }
+}
+
+/// Shared `Mutex` and `SyncMap` method dispatch used by both the interpreter and the VM.
+/// `call` runs the function passed to `with_lock` and returns its result.
+pub fn call_sync_method(
+    obj: &Value,
+    method: &str,
+    args: &[Value],
+    call: impl FnOnce(&Value) -> Value,
+) -> Option<Value> {
+    match obj {
+        Value::Mutex(mutex) => Some(call_mutex_method(mutex, method, args, call)),
+        Value::SyncMap(map) => Some(call_sync_map_method(map, method, args)),
+        _ => None,
+    }
+}
+
+fn call_mutex_method(
+    mutex: &MutexState,
+    method: &str,
+    args: &[Value],
+    call: impl FnOnce(&Value) -> Value,
+) -> Value {
+    match (method, args) {
+        ("lock", []) => {
+            mutex.lock();
+            Value::Null
+        }
+        ("try_lock", []) => Value::Bool(mutex.try_lock()),
+        ("unlock", []) => match mutex.unlock() {
+            Ok(()) => Value::Null,
+            Err(message) => Value::Error(message),
+        },
+        ("is_locked", []) => Value::Bool(mutex.is_locked()),
+        // The lock is released even when the function fails, so the error can propagate
+        ("with_lock", [function]) => {
+            mutex.lock();
+            let result = call(function);
+            // The function may already have unlocked it, which is not an error here
+            let _ = mutex.unlock();
+            result
+        }
+        ("lock" | "try_lock" | "unlock" | "is_locked" | "with_lock", _) => {
+            Value::Error(format!("Mutex.{} got unexpected arguments ({})", method, args.len()))
+        }
+        _ => Value::Error(format!("Mutex has no method '{}'", method)),
+    }
+}
+
+fn call_sync_map_method(map: &SyncMapState, method: &str, args: &[Value]) -> Value {
+    // Every method that takes a key takes it first; the arms below check the count
+    let key = || Value::dict_key(&args[0]);
+    match (method, args.len()) {
+        ("set", 2) => match key() {
+            Ok(key) => {
+                map.lock_entries().insert(key, args[1].clone());
+                Value::Null
+            }
+            Err(message) => Value::Error(message),
+        },
+        ("get", 1 | 2) => match key() {
+            Ok(key) => map
+                .lock_entries()
+                .get(&key)
+                .cloned()
+                .unwrap_or_else(|| args.get(1).cloned().unwrap_or(Value::Null)),
+            Err(message) => Value::Error(message),
+        },
+        ("has", 1) => match key() {
+            Ok(key) => Value::Bool(map.lock_entries().contains_key(&key)),
+            Err(message) => Value::Error(message),
+        },
+        ("delete", 1) => match key() {
+            Ok(key) => Value::Bool(map.lock_entries().remove(&key).is_some()),
+            Err(message) => Value::Error(message),
+        },
+        // Read, add, and write happen under one lock, so concurrent adds are never lost
+        ("add", 2) => {
+            let key = match key() {
+                Ok(key) => key,
+                Err(message) => return Value::Error(message),
+            };
+            let Value::Int(delta) = args[1] else {
+                return Value::Error("SyncMap.add delta must be an int".to_string());
+            };
+            let mut entries = map.lock_entries();
+            let current = match entries.get(&key) {
+                None => 0,
+                Some(Value::Int(current)) => *current,
+                Some(_) => {
+                    return Value::Error(format!(
+                        "SyncMap.add requires key '{}' to hold an int",
+                        key
+                    ))
+                }
+            };
+            match Value::checked_int_arithmetic(current, "+", delta) {
+                Ok(updated) => {
+                    entries.insert(key, Value::Int(updated));
+                    Value::Int(updated)
+                }
+                Err(message) => Value::Error(message),
+            }
+        }
+        ("keys", 0) => {
+            let mut keys: Vec<String> =
+                map.lock_entries().keys().map(|key| key.to_string()).collect();
+            keys.sort();
+            Value::Array(Arc::new(keys.into_iter().map(|key| Value::Str(Arc::new(key))).collect()))
+        }
+        ("len", 0) => Value::Int(map.len() as i64),
+        ("to_dict", 0) => {
+            let snapshot: DictMap = map.lock_entries().clone();
+            Value::Dict(Arc::new(snapshot))
+        }
+        ("set" | "get" | "has" | "delete" | "add" | "keys" | "len" | "to_dict", _) => {
+            Value::Error(format!("SyncMap.{} got unexpected arguments ({})", method, args.len()))
+        }
+        _ => Value::Error(format!("SyncMap has no method '{}'", method)),
+    }
+}
+
+fn atomic_counter<'a>(
+    function_name: &str,
+    value: Option<&'a Value>,
+) -> Result<&'a AtomicI64, Value> {
+    match value {
+        Some(Value::Atomic(counter)) => Ok(counter.as_ref()),
+        _ => Err(Value::Error(format!("{}() expects an atomic from sync.atomic()", function_name))),
+    }
+}
+
+fn int_argument(function_name: &str, label: &str, value: Option<&Value>) -> Result<i64, Value> {
+    match value {
+        Some(Value::Int(n)) => Ok(*n),
+        _ => Err(Value::Error(format!("{}() {} must be an int", function_name, label))),
+    }
+}
+
+/// The `sync.*` functions that do not run user code.
+fn call_sync_module(name: &str, arg_values: &[Value]) -> Result<Value, Value> {
+    match name {
+        "sync.mutex" => Ok(Value::Mutex(Arc::new(MutexState::new()))),
+        "sync.map" => Ok(Value::SyncMap(Arc::new(SyncMapState::new()))),
+        "sync.atomic" => {
+            let initial = match arg_values.first() {
+                None => 0,
+                value => int_argument(name, "initial value", value)?,
+            };
+            Ok(Value::Atomic(Arc::new(AtomicI64::new(initial))))
+        }
+        "sync.atomic_add" => {
+            let counter = atomic_counter(name, arg_values.first())?;
+            let delta = int_argument(name, "delta", arg_values.get(1))?;
+            counter
+                .fetch_update(Ordering::SeqCst, Ordering::SeqCst, |current| {
+                    current.checked_add(delta)
+                })
+                .map(|previous| Value::Int(previous + delta))
+                .map_err(|current| {
+                    Value::Error(format!("Integer overflow: {} + {}", current, delta))
+                })
+        }
+        "sync.atomic_load" => {
+            Ok(Value::Int(atomic_counter(name, arg_values.first())?.load(Ordering::SeqCst)))
+        }
+        "sync.atomic_store" => {
+            let counter = atomic_counter(name, arg_values.first())?;
+            counter.store(int_argument(name, "value", arg_values.get(1))?, Ordering::SeqCst);
+            Ok(Value::Null)
+        }
+        _ => Err(Value::Error(format!("Unknown function: {}", name))),
+    }
+}
+
 pub fn handle(_interp: &mut Interpreter, name: &str, _arg_values: &[Value]) -> Option<Value> {
     let arg_values = _arg_values;
     let result = match name {
@@ -32,12 +322,13 @@ pub fn handle(_interp: &mut Interpreter, name: &str, _arg_values: &[Value]) -> O
                 )));
             }
 
-            use std::sync::mpsc;
-            let (sender, receiver) = mpsc::channel();
-            #[allow(clippy::arc_with_non_send_sync)]
-            let channel = Arc::new(Mutex::new((sender, receiver)));
-            Value::Channel(channel)
+            Value::Channel(Arc::new(ChannelState::new()))
+        }
+        "wait_group" => Value::WaitGroup(Arc::new(WaitGroupState::new())),
+        name if name.starts_with("sync.") => {
+            call_sync_module(name, arg_values).unwrap_or_else(|error| error)
         }
+        "select" => select_channels(arg_values),
         "shared_set" => {
             if arg_values.len() != 2 {
                 return Some(Value::Error(
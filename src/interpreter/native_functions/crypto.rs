@@ -16,7 +16,7 @@ use crate::interpreter::{DictMap, Value};
 use std::sync::Arc;
 
 fn error_object(message: String) -> Value {
-    Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
+    Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None, payload: None }
 }
 
 fn sha256_hex(bytes: &[u8]) -> String {
@@ -1,8 +1,13 @@
 // File: src/interpreter/native_functions/database.rs
 //
 // Database access native functions
+//
+// The `db_*` functions take a connection as their first argument. `sqlite.open()` returns
+// the same `Database` value, whose methods (`exec`, `query`, `transaction`, ...) wrap
+// those functions and raise their failures as catchable errors.
 
-use crate::interpreter::{ConnectionPool, DatabaseConnection, DictMap, Value};
+use super::system::{error_object, value_type_name};
+use crate::interpreter::{ConnectionPool, DatabaseConnection, DictMap, SequenceHost, Value};
 use mysql_async::prelude::Queryable;
 use postgres::NoTls;
 use std::collections::HashMap;
@@ -23,7 +28,19 @@ fn map_sqlite_value(value: rusqlite::types::Value) -> Value {
         rusqlite::types::Value::Real(number) => Value::Float(number),
         rusqlite::types::Value::Text(text) => Value::Str(Arc::new(text)),
         rusqlite::types::Value::Null => Value::Null,
-        rusqlite::types::Value::Blob(_) => Value::Str(Arc::new("[blob]".to_string())),
+        rusqlite::types::Value::Blob(bytes) => Value::Bytes(bytes),
+    }
+}
+
+fn to_sqlite_param(value: &Value) -> Box<dyn rusqlite::ToSql> {
+    match value {
+        Value::Str(text) => Box::new(text.as_ref().to_string()),
+        Value::Int(number) => Box::new(*number),
+        Value::Float(number) => Box::new(*number),
+        Value::Bool(flag) => Box::new(*flag),
+        Value::Bytes(bytes) => Box::new(bytes.clone()),
+        Value::Null => Box::new(rusqlite::types::Null),
+        other => Box::new(format!("{:?}", other)),
     }
 }
 
@@ -169,26 +186,8 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                         (DatabaseConnection::Sqlite(connection), "sqlite") => {
                             let connection = lock_or_db_error!(connection, "database.connection");
                             let execute_result = if let Some(Value::Array(param_arr)) = params {
-                                let param_values: Vec<Box<dyn rusqlite::ToSql>> = param_arr
-                                    .iter()
-                                    .map(|value| match value {
-                                        Value::Str(text) => Box::new(text.as_ref().to_string())
-                                            as Box<dyn rusqlite::ToSql>,
-                                        Value::Int(number) => {
-                                            Box::new(*number) as Box<dyn rusqlite::ToSql>
-                                        }
-                                        Value::Float(number) => {
-                                            Box::new(*number) as Box<dyn rusqlite::ToSql>
-                                        }
-                                        Value::Bool(flag) => {
-                                            Box::new(*flag) as Box<dyn rusqlite::ToSql>
-                                        }
-                                        Value::Null => Box::new(rusqlite::types::Null)
-                                            as Box<dyn rusqlite::ToSql>,
-                                        other => Box::new(format!("{:?}", other))
-                                            as Box<dyn rusqlite::ToSql>,
-                                    })
-                                    .collect();
+                                let param_values: Vec<Box<dyn rusqlite::ToSql>> =
+                                    param_arr.iter().map(to_sqlite_param).collect();
                                 let params_refs: Vec<&dyn rusqlite::ToSql> =
                                     param_values.iter().map(|value| value.as_ref()).collect();
                                 connection.execute(sql.as_ref(), params_refs.as_slice())
@@ -296,26 +295,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
 
                             let param_values: Vec<Box<dyn rusqlite::ToSql>> =
                                 if let Some(Value::Array(param_arr)) = params {
-                                    param_arr
-                                        .iter()
-                                        .map(|value| match value {
-                                            Value::Str(text) => Box::new(text.as_ref().to_string())
-                                                as Box<dyn rusqlite::ToSql>,
-                                            Value::Int(number) => {
-                                                Box::new(*number) as Box<dyn rusqlite::ToSql>
-                                            }
-                                            Value::Float(number) => {
-                                                Box::new(*number) as Box<dyn rusqlite::ToSql>
-                                            }
-                                            Value::Bool(flag) => {
-                                                Box::new(*flag) as Box<dyn rusqlite::ToSql>
-                                            }
-                                            Value::Null => Box::new(rusqlite::types::Null)
-                                                as Box<dyn rusqlite::ToSql>,
-                                            other => Box::new(format!("{:?}", other))
-                                                as Box<dyn rusqlite::ToSql>,
-                                        })
-                                        .collect()
+                                    param_arr.iter().map(to_sqlite_param).collect()
                                 } else {
                                     Vec::new()
                                 };
@@ -826,6 +806,8 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             ),
         },
 
+        "sqlite.open" => sqlite_open(arg_values),
+
         "db_last_insert_id" => {
             if arg_values.len() > 1 {
                 return Some(Value::Error(
@@ -889,6 +871,160 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
     Some(result)
 }
 
+fn sqlite_open(args: &[Value]) -> Value {
+    let path = match args {
+        [Value::Str(path)] => path,
+        [other] => {
+            return Value::Error(format!(
+                "sqlite.open() expects a path string, got {}",
+                value_type_name(other)
+            ))
+        }
+        _ => return Value::Error("sqlite.open() requires a database path".to_string()),
+    };
+
+    // rusqlite treats ":memory:" as a private in-memory database
+    match rusqlite::Connection::open(path.as_ref()) {
+        Ok(connection) => Value::Database {
+            connection: DatabaseConnection::Sqlite(Arc::new(Mutex::new(connection))),
+            db_type: "sqlite".to_string(),
+            connection_string: path.as_ref().to_string(),
+            in_transaction: Arc::new(Mutex::new(false)),
+        },
+        Err(error) => error_object(format!("sqlite.open(): cannot open '{}': {}", path, error)),
+    }
+}
+
+/// Runs a `db_*` function with `obj` as its connection, raising its failure as an error
+/// attributed to the method.
+fn delegate(method: &str, function: &str, obj: &Value, args: &[Value]) -> Value {
+    let mut call_args = Vec::with_capacity(args.len() + 1);
+    call_args.push(obj.clone());
+    call_args.extend_from_slice(args);
+    match handle(function, &call_args) {
+        Some(Value::Error(message)) => error_object(format!("Database.{}(): {}", method, message)),
+        Some(value) => value,
+        None => unreachable!("{} is a database function", function),
+    }
+}
+
+fn is_in_transaction(in_transaction: &Mutex<bool>) -> bool {
+    in_transaction.lock().map(|flag| *flag).unwrap_or(false)
+}
+
+/// `Database.transaction(fn)`: commits when `fn` returns and rolls back when it fails.
+fn transaction(
+    host: &mut dyn SequenceHost,
+    obj: &Value,
+    in_transaction: &Mutex<bool>,
+    func: &Value,
+) -> Value {
+    let begun = delegate("transaction", "db_begin", obj, &[]);
+    if matches!(begun, Value::ErrorObject { .. }) {
+        return begun;
+    }
+
+    match host.call_sequence_callback(func, vec![obj.clone()]) {
+        // The callback may have committed or rolled back itself
+        Ok(value) if !is_in_transaction(in_transaction) => value,
+        Ok(value) => match delegate("transaction", "db_commit", obj, &[]) {
+            error @ Value::ErrorObject { .. } => error,
+            _ => value,
+        },
+        Err(message) => {
+            if is_in_transaction(in_transaction) {
+                delegate("transaction", "db_rollback", obj, &[]);
+            }
+            error_object(message)
+        }
+    }
+}
+
+/// Shared `Database` method dispatch used by both the interpreter and the VM.
+pub(crate) fn call_database_method(
+    host: &mut dyn SequenceHost,
+    obj: &Value,
+    method: &str,
+    args: &[Value],
+) -> Option<Value> {
+    let Value::Database { in_transaction, .. } = obj else {
+        return None;
+    };
+    let (min, max) = match method {
+        "exec" | "query" | "query_one" => (1, 2),
+        "transaction" => (1, 1),
+        "begin" | "commit" | "rollback" | "last_insert_id" | "close" => (0, 0),
+        _ => return Some(Value::Error(format!("Database has no method '{}'", method))),
+    };
+    if args.len() < min || args.len() > max {
+        let expected = if min == max { min.to_string() } else { format!("{}-{}", min, max) };
+        return Some(Value::Error(format!(
+            "Database.{}() expects {} argument{}, got {}",
+            method,
+            expected,
+            if expected == "1" { "" } else { "s" },
+            args.len()
+        )));
+    }
+    if let Some(first) = args.first() {
+        let (expected, accepted) = if method == "transaction" {
+            (
+                "a function",
+                matches!(
+                    first,
+                    Value::Function(..) | Value::BytecodeFunction { .. } | Value::NativeFunction(_)
+                ),
+            )
+        } else {
+            ("an SQL string", matches!(first, Value::Str(_)))
+        };
+        if !accepted {
+            return Some(Value::Error(format!(
+                "Database.{}() expects {}, got {}",
+                method,
+                expected,
+                value_type_name(first)
+            )));
+        }
+    }
+    if let Some(params) = args.get(1) {
+        if !matches!(params, Value::Array(_)) {
+            return Some(Value::Error(format!(
+                "Database.{}() expects params as an array, got {}",
+                method,
+                value_type_name(params)
+            )));
+        }
+    }
+
+    let result = match method {
+        "exec" => delegate(method, "db_execute", obj, args),
+        "query" => delegate(method, "db_query", obj, args),
+        "query_one" => match delegate(method, "db_query", obj, args) {
+            Value::Array(rows) => rows.first().cloned().unwrap_or(Value::Null),
+            error => error,
+        },
+        "transaction" => transaction(host, obj, in_transaction, &args[0]),
+        "begin" => delegate(method, "db_begin", obj, args),
+        "commit" => delegate(method, "db_commit", obj, args),
+        "rollback" => delegate(method, "db_rollback", obj, args),
+        "last_insert_id" => match delegate(method, "db_last_insert_id", obj, args) {
+            // db_last_insert_id() reports SQLite row ids as floats
+            Value::Float(id) => Value::Int(id as i64),
+            other => other,
+        },
+        _ => {
+            // The connection is released with its last reference; closing abandons any
+            // transaction the script left open.
+            if is_in_transaction(in_transaction) {
+                delegate(method, "db_rollback", obj, &[]);
+            }
+            Value::Null
+        }
+    };
+    Some(result)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -1036,6 +1172,45 @@ mod tests {
         let _ = std::fs::remove_file(db_path);
     }
 
+    #[test]
+    fn test_sqlite_open_methods_round_trip_blobs_and_report_errors() {
+        let mut interpreter = crate::interpreter::Interpreter::new();
+        let db = handle("sqlite.open", &[str_value(":memory:")]).unwrap();
+        let mut call = |method: &str, args: &[Value]| {
+            call_database_method(&mut interpreter, &db, method, args).unwrap()
+        };
+
+        call("exec", &[str_value("CREATE TABLE files (name TEXT, data BLOB)")]);
+        let params =
+            Value::Array(Arc::new(vec![str_value("logo.png"), Value::Bytes(vec![0x89, 0x50, 0])]));
+        let inserted = call("exec", &[str_value("INSERT INTO files VALUES (?, ?)"), params]);
+        assert!(matches!(inserted, Value::Int(1)));
+        assert!(matches!(call("last_insert_id", &[]), Value::Int(1)));
+
+        let row = call("query_one", &[str_value("SELECT data FROM files")]);
+        let Value::Dict(row) = row else { panic!("query_one should return a row dict") };
+        assert!(matches!(row.get("data"), Some(Value::Bytes(bytes)) if bytes == &[0x89, 0x50, 0]));
+
+        let missing = call("exec", &[str_value("INSERT INTO nowhere VALUES (1)")]);
+        assert!(matches!(
+            missing,
+            Value::ErrorObject { message, .. }
+                if message.starts_with("Database.exec(): SQLite execution error")
+        ));
+
+        let bad_params = call("query", &[str_value("SELECT 1"), Value::Int(1)]);
+        assert!(matches!(
+            bad_params,
+            Value::Error(message) if message == "Database.query() expects params as an array, got int"
+        ));
+
+        let commit = call("commit", &[]);
+        assert!(matches!(
+            commit,
+            Value::ErrorObject { message, .. } if message.contains("No transaction in progress")
+        ));
+    }
+
     #[test]
     fn test_db_argument_shape_errors() {
         let execute_error = handle("db_execute", &[Value::Int(1)]).unwrap();
@@ -0,0 +1,219 @@
+// File: src/interpreter/native_functions/decorators.rs
+//
+// Stdlib function decorators (`memo`, `timed`, `retry(n)`). Each one wraps a function
+// in a `Value::Decorated` that both the interpreter and the VM can call.
+
+use crate::interpreter::{Decorated, Value};
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+use std::time::Instant;
+
+/// Engine hooks a decorated call needs: the interpreter and the VM call functions and
+/// write to stderr differently.
+pub trait DecoratorHost {
+    /// Call `function`, handing back any error it raises as the result instead of
+    /// unwinding, so the decorator decides whether the error propagates.
+    fn call_decorated_function(&mut self, function: &Value, args: &[Value]) -> Value;
+
+    /// Write one line of decorator output to the program's stderr.
+    fn write_decorator_output(&mut self, line: &str);
+}
+
+fn is_error(value: &Value) -> bool {
+    matches!(value, Value::Error(_) | Value::ErrorObject { .. })
+}
+
+fn is_callable(value: &Value) -> bool {
+    matches!(
+        value,
+        Value::Function(..)
+            | Value::NativeFunction(_)
+            | Value::BytecodeFunction { .. }
+            | Value::Decorated(_)
+    )
+}
+
+/// The name `timed` reports for `function`. Interpreter closures carry no name.
+fn function_label(function: &Value) -> String {
+    match function {
+        Value::BytecodeFunction { chunk, .. } => {
+            chunk.name.clone().unwrap_or_else(|| "<lambda>".to_string())
+        }
+        Value::NativeFunction(name) => name.clone(),
+        Value::Decorated(decorated) => match decorated.as_ref() {
+            Decorated::Memo { function, .. }
+            | Decorated::Timed { function }
+            | Decorated::Retrying { function, .. } => function_label(function),
+            Decorated::Retry { .. } => "retry".to_string(),
+        },
+        _ => "<function>".to_string(),
+    }
+}
+
+fn function_argument(name: &str, args: &[Value]) -> Result<Value, Value> {
+    match args {
+        [function] if is_callable(function) => Ok(function.clone()),
+        [_] => Err(Value::Error(format!("{} expects a function to decorate", name))),
+        _ => Err(Value::Error(format!("{} expects 1 argument, got {}", name, args.len()))),
+    }
+}
+
+fn decorate(decorated: Decorated) -> Value {
+    Value::Decorated(Arc::new(decorated))
+}
+
+/// Call a decorated function with already evaluated arguments.
+pub fn call_decorated(
+    host: &mut dyn DecoratorHost,
+    decorated: &Decorated,
+    args: &[Value],
+) -> Value {
+    match decorated {
+        Decorated::Retry { attempts } => match function_argument("retry", args) {
+            Ok(function) => decorate(Decorated::Retrying { function, attempts: *attempts }),
+            Err(error) => error,
+        },
+        Decorated::Memo { function, cache } => {
+            let key = match Value::Array(Arc::new(args.to_vec())).hash_key() {
+                Ok(key) => key,
+                Err(message) => return Value::Error(format!("memo: {}", message)),
+            };
+            let cached =
+                cache.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).get(&key).cloned();
+            if let Some(result) = cached {
+                return result;
+            }
+
+            // The lock is not held during the call: recursive calls fill the cache too
+            let result = host.call_decorated_function(function, args);
+            if !is_error(&result) {
+                cache
+                    .lock()
+                    .unwrap_or_else(|poisoned| poisoned.into_inner())
+                    .insert(key, result.clone());
+            }
+            result
+        }
+        Decorated::Timed { function } => {
+            let started = Instant::now();
+            let result = host.call_decorated_function(function, args);
+            let elapsed_ms = started.elapsed().as_secs_f64() * 1000.0;
+            host.write_decorator_output(&format!(
+                "timed: {} took {:.3}ms",
+                function_label(function),
+                elapsed_ms
+            ));
+            result
+        }
+        Decorated::Retrying { function, attempts } => {
+            let mut result = host.call_decorated_function(function, args);
+            for _ in 1..*attempts {
+                if !is_error(&result) {
+                    break;
+                }
+                result = host.call_decorated_function(function, args);
+            }
+            result
+        }
+    }
+}
+
+pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
+    let result = match name {
+        "memo" => match function_argument(name, arg_values) {
+            Ok(function) => {
+                decorate(Decorated::Memo { function, cache: Mutex::new(HashMap::new()) })
+            }
+            Err(error) => error,
+        },
+        "timed" => match function_argument(name, arg_values) {
+            Ok(function) => decorate(Decorated::Timed { function }),
+            Err(error) => error,
+        },
+        "retry" => match arg_values {
+            [Value::Int(attempts)] if *attempts >= 1 => {
+                decorate(Decorated::Retry { attempts: *attempts as usize })
+            }
+            [_] => Value::Error("retry expects a positive number of attempts".to_string()),
+            _ => Value::Error(format!("retry expects 1 argument, got {}", arg_values.len())),
+        },
+        _ => return None,
+    };
+    Some(result)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::{call_decorated, handle, DecoratorHost};
+    use crate::interpreter::Value;
+
+    /// Counts calls and fails until `failures` calls have been made.
+    struct CountingHost {
+        calls: usize,
+        failures: usize,
+        output: Vec<String>,
+    }
+
+    impl DecoratorHost for CountingHost {
+        fn call_decorated_function(&mut self, _function: &Value, args: &[Value]) -> Value {
+            self.calls += 1;
+            if self.calls <= self.failures {
+                return Value::Error(format!("attempt {} failed", self.calls));
+            }
+            args.first().cloned().unwrap_or(Value::Null)
+        }
+
+        fn write_decorator_output(&mut self, line: &str) {
+            self.output.push(line.to_string());
+        }
+    }
+
+    fn decorated(name: &str, args: &[Value]) -> Value {
+        handle(name, args).expect("decorator should be handled")
+    }
+
+    fn call(host: &mut CountingHost, function: &Value, args: &[Value]) -> Value {
+        match function {
+            Value::Decorated(decorated) => call_decorated(host, decorated, args),
+            other => panic!("expected a decorated function, got {:?}", other),
+        }
+    }
+
+    #[test]
+    fn memo_calls_the_function_once_per_argument_list() {
+        let mut host = CountingHost { calls: 0, failures: 0, output: Vec::new() };
+        let memo = decorated("memo", &[Value::NativeFunction("len".to_string())]);
+
+        for _ in 0..3 {
+            assert!(matches!(call(&mut host, &memo, &[Value::Int(7)]), Value::Int(7)));
+        }
+        assert!(matches!(call(&mut host, &memo, &[Value::Int(8)]), Value::Int(8)));
+        assert_eq!(host.calls, 2);
+    }
+
+    #[test]
+    fn retry_stops_after_the_first_success_or_the_last_attempt() {
+        let retry = decorated("retry", &[Value::Int(3)]);
+        let mut host = CountingHost { calls: 0, failures: 2, output: Vec::new() };
+        let retrying = call(&mut host, &retry, &[Value::NativeFunction("len".to_string())]);
+        assert!(matches!(call(&mut host, &retrying, &[Value::Int(1)]), Value::Int(1)));
+        assert_eq!(host.calls, 3);
+
+        let mut host = CountingHost { calls: 0, failures: 5, output: Vec::new() };
+        let result = call(&mut host, &retrying, &[Value::Int(1)]);
+        assert!(matches!(result, Value::Error(message) if message == "attempt 3 failed"));
+        assert_eq!(host.calls, 3);
+    }
+
+    #[test]
+    fn timed_reports_each_call_and_decorators_reject_non_functions() {
+        let mut host = CountingHost { calls: 0, failures: 0, output: Vec::new() };
+        let timed = decorated("timed", &[Value::NativeFunction("len".to_string())]);
+        call(&mut host, &timed, &[Value::Int(1)]);
+        assert_eq!(host.output.len(), 1);
+        assert!(host.output[0].starts_with("timed: len took "));
+
+        assert!(matches!(decorated("memo", &[Value::Int(1)]), Value::Error(_)));
+        assert!(matches!(decorated("retry", &[Value::Int(0)]), Value::Error(_)));
+    }
+}
@@ -0,0 +1,490 @@
+// File: src/interpreter/native_functions/ffi.rs
+//
+// `ffi` namespace: load C shared libraries and call their functions through declared
+// signatures, plus raw memory helpers for out-parameters.
+//
+// Calls go through a fixed wide signature instead of libffi. On the supported 64-bit
+// Unix ABIs (x86-64 System V and AArch64) integer-class and floating-point arguments
+// travel in separate register files, so a function taking up to 6 integer/pointer and
+// 8 floating-point parameters receives exactly the values it expects when called as
+// `fn(i64 x 6, f64 x 8)`; the unused registers are ignored. Variadic functions and
+// struct parameters are not supported.
+
+use crate::interpreter::Value;
+use std::collections::HashMap;
+use std::ffi::{CStr, CString};
+use std::sync::{Arc, Mutex};
+
+const MAX_INT_PARAMS: usize = 6;
+const MAX_FLOAT_PARAMS: usize = 8;
+
+/// C type named in a signature declaration
+#[derive(Clone, Copy, Debug, PartialEq)]
+enum CType {
+    Void,
+    Bool,
+    Int,
+    UInt,
+    Long,
+    ULong,
+    Float,
+    Double,
+    String,
+    Pointer,
+}
+
+impl CType {
+    fn parse(name: &str) -> Option<Self> {
+        Some(match name {
+            "void" => CType::Void,
+            "bool" => CType::Bool,
+            "int" | "int32" => CType::Int,
+            "uint" | "uint32" => CType::UInt,
+            "long" | "int64" | "ssize_t" => CType::Long,
+            "ulong" | "uint64" | "size_t" => CType::ULong,
+            "float" => CType::Float,
+            "double" => CType::Double,
+            "string" => CType::String,
+            "pointer" => CType::Pointer,
+            _ => return None,
+        })
+    }
+
+    fn name(self) -> &'static str {
+        match self {
+            CType::Void => "void",
+            CType::Bool => "bool",
+            CType::Int => "int",
+            CType::UInt => "uint",
+            CType::Long => "long",
+            CType::ULong => "ulong",
+            CType::Float => "float",
+            CType::Double => "double",
+            CType::String => "string",
+            CType::Pointer => "pointer",
+        }
+    }
+
+    fn is_floating(self) -> bool {
+        matches!(self, CType::Float | CType::Double)
+    }
+}
+
+#[derive(Clone)]
+struct Signature {
+    address: usize,
+    params: Vec<CType>,
+    returns: CType,
+}
+
+/// A library opened by `ffi.load`, with the functions declared on it so far
+pub struct NativeLibrary {
+    path: String,
+    handle: usize,
+    signatures: Mutex<HashMap<String, Signature>>,
+}
+
+impl NativeLibrary {
+    pub fn path(&self) -> &str {
+        &self.path
+    }
+}
+
+#[cfg(unix)]
+impl Drop for NativeLibrary {
+    fn drop(&mut self) {
+        // SAFETY: `handle` came from a successful `dlopen` and is closed exactly once.
+        unsafe {
+            libc::dlclose(self.handle as *mut libc::c_void);
+        }
+    }
+}
+
+#[cfg(unix)]
+fn last_dl_error() -> String {
+    // SAFETY: `dlerror` returns null or a valid C string owned by the loader.
+    unsafe {
+        let message = libc::dlerror();
+        if message.is_null() {
+            "unknown error".to_string()
+        } else {
+            CStr::from_ptr(message).to_string_lossy().into_owned()
+        }
+    }
+}
+
+#[cfg(unix)]
+fn open_library(path: &str) -> Result<NativeLibrary, String> {
+    let c_path =
+        CString::new(path).map_err(|_| "ffi.load() path must not contain NUL bytes".to_string())?;
+    // SAFETY: `c_path` is a valid C string; loading runs the library's initializers,
+    // which is the caller's explicit intent.
+    let handle = unsafe { libc::dlopen(c_path.as_ptr(), libc::RTLD_NOW | libc::RTLD_LOCAL) };
+    if handle.is_null() {
+        return Err(format!("ffi.load() could not load '{}': {}", path, last_dl_error()));
+    }
+    Ok(NativeLibrary {
+        path: path.to_string(),
+        handle: handle as usize,
+        signatures: Mutex::new(HashMap::new()),
+    })
+}
+
+#[cfg(not(unix))]
+fn open_library(_path: &str) -> Result<NativeLibrary, String> {
+    Err("ffi.load() is not supported on this platform".to_string())
+}
+
+#[cfg(unix)]
+fn lookup_symbol(library: &NativeLibrary, name: &str) -> Result<usize, String> {
+    let c_name = CString::new(name)
+        .map_err(|_| "ffi symbol names must not contain NUL bytes".to_string())?;
+    // SAFETY: `handle` is an open library and `c_name` a valid C string.
+    let address = unsafe { libc::dlsym(library.handle as *mut libc::c_void, c_name.as_ptr()) };
+    if address.is_null() {
+        return Err(format!("'{}' has no symbol '{}'", library.path, name));
+    }
+    Ok(address as usize)
+}
+
+#[cfg(not(unix))]
+fn lookup_symbol(library: &NativeLibrary, name: &str) -> Result<usize, String> {
+    Err(format!("'{}' has no symbol '{}'", library.path, name))
+}
+
+/// Argument registers for one call; C strings stay alive until the call returns
+#[derive(Default)]
+struct CallFrame {
+    ints: Vec<i64>,
+    floats: Vec<f64>,
+    buffers: Vec<CString>,
+    bytes: Vec<Vec<u8>>,
+}
+
+impl CallFrame {
+    fn push(&mut self, ty: CType, value: &Value) -> Result<(), String> {
+        match (ty, value) {
+            (CType::Bool, Value::Bool(flag)) => self.ints.push(*flag as i64),
+            (
+                CType::Bool | CType::Int | CType::UInt | CType::Long | CType::ULong,
+                Value::Int(n),
+            ) => self.ints.push(*n),
+            (CType::Float, Value::Float(n)) => {
+                // A `float` parameter reads the low 32 bits of its register
+                self.floats.push(f64::from_bits((*n as f32).to_bits() as u64))
+            }
+            (CType::Float, Value::Int(n)) => {
+                self.floats.push(f64::from_bits((*n as f32).to_bits() as u64))
+            }
+            (CType::Double, Value::Float(n)) => self.floats.push(*n),
+            (CType::Double, Value::Int(n)) => self.floats.push(*n as f64),
+            (CType::String | CType::Pointer, Value::Null) => self.ints.push(0),
+            (CType::String | CType::Pointer, Value::Str(text)) => {
+                let c_string = CString::new(text.as_str())
+                    .map_err(|_| "string contains a NUL byte".to_string())?;
+                self.ints.push(c_string.as_ptr() as i64);
+                self.buffers.push(c_string);
+            }
+            (CType::Pointer, Value::Int(address)) => self.ints.push(*address),
+            (CType::Pointer, Value::Bytes(data)) => {
+                let copy = data.clone();
+                self.ints.push(copy.as_ptr() as i64);
+                self.bytes.push(copy);
+            }
+            (expected, other) => {
+                return Err(format!(
+                    "expects {}, got {}",
+                    expected.name(),
+                    crate::interpreter::Interpreter::value_type_name(other)
+                ))
+            }
+        }
+        Ok(())
+    }
+}
+
+#[cfg(all(unix, any(target_arch = "x86_64", target_arch = "aarch64")))]
+fn invoke(signature: &Signature, frame: &CallFrame) -> Value {
+    type Wide<R> = unsafe extern "C" fn(
+        i64,
+        i64,
+        i64,
+        i64,
+        i64,
+        i64,
+        f64,
+        f64,
+        f64,
+        f64,
+        f64,
+        f64,
+        f64,
+        f64,
+    ) -> R;
+
+    let mut i = [0i64; MAX_INT_PARAMS];
+    i[..frame.ints.len()].copy_from_slice(&frame.ints);
+    let mut f = [0f64; MAX_FLOAT_PARAMS];
+    f[..frame.floats.len()].copy_from_slice(&frame.floats);
+
+    // SAFETY: `address` is a symbol the script declared with this signature. The wide
+    // call places every declared argument in the register the callee reads it from (see
+    // the module comment); a wrong declaration is the script's error, as in C.
+    unsafe {
+        macro_rules! call {
+            ($ret:ty) => {{
+                let function: Wide<$ret> = std::mem::transmute(signature.address);
+                function(
+                    i[0], i[1], i[2], i[3], i[4], i[5], f[0], f[1], f[2], f[3], f[4], f[5], f[6],
+                    f[7],
+                )
+            }};
+        }
+
+        match signature.returns {
+            CType::Double => Value::Float(call!(f64)),
+            CType::Float => Value::Float(call!(f32) as f64),
+            integer => {
+                let raw = call!(i64);
+                match integer {
+                    CType::Void => Value::Null,
+                    CType::Bool => Value::Bool(raw as u8 != 0),
+                    CType::Int => Value::Int(raw as i32 as i64),
+                    CType::UInt => Value::Int(raw as u32 as i64),
+                    CType::String if raw == 0 => Value::Null,
+                    CType::String => Value::Str(Arc::new(
+                        CStr::from_ptr(raw as *const libc::c_char).to_string_lossy().into_owned(),
+                    )),
+                    CType::Pointer if raw == 0 => Value::Null,
+                    _ => Value::Int(raw),
+                }
+            }
+        }
+    }
+}
+
+#[cfg(not(all(unix, any(target_arch = "x86_64", target_arch = "aarch64"))))]
+fn invoke(_signature: &Signature, _frame: &CallFrame) -> Value {
+    Value::Error("ffi calls are not supported on this platform".to_string())
+}
+
+fn parse_signature(library: &NativeLibrary, args: &[Value]) -> Result<(String, Signature), String> {
+    let (name, params, returns) = match args {
+        [Value::Str(name), Value::Array(params), Value::Str(returns)] => (name, params, returns),
+        _ => {
+            return Err("NativeLibrary.declare() requires (name, param_types_array, return_type)"
+                .to_string())
+        }
+    };
+
+    let unknown = |ty: &str| format!("NativeLibrary.declare() unknown C type '{}'", ty);
+    let params = params
+        .iter()
+        .map(|param| match param {
+            Value::Str(ty) => match CType::parse(ty) {
+                Some(CType::Void) => {
+                    Err("NativeLibrary.declare() parameters cannot be void".into())
+                }
+                Some(parsed) => Ok(parsed),
+                None => Err(unknown(ty)),
+            },
+            other => Err(format!(
+                "NativeLibrary.declare() parameter types must be strings, got {}",
+                crate::interpreter::Interpreter::value_type_name(other)
+            )),
+        })
+        .collect::<Result<Vec<_>, String>>()?;
+    let returns = CType::parse(returns).ok_or_else(|| unknown(returns))?;
+
+    let floats = params.iter().filter(|param| param.is_floating()).count();
+    if floats > MAX_FLOAT_PARAMS || params.len() - floats > MAX_INT_PARAMS {
+        return Err(format!(
+            "NativeLibrary.declare() supports at most {} integer/pointer and {} floating-point parameters",
+            MAX_INT_PARAMS, MAX_FLOAT_PARAMS
+        ));
+    }
+
+    let address = lookup_symbol(library, name)?;
+    Ok((name.to_string(), Signature { address, params, returns }))
+}
+
+fn call_declared(library: &NativeLibrary, args: &[Value]) -> Value {
+    let Some(Value::Str(name)) = args.first() else {
+        return Value::Error("NativeLibrary.call() requires a function name".to_string());
+    };
+    let signature = {
+        let signatures = library.signatures.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+        match signatures.get(name.as_str()) {
+            Some(signature) => signature.clone(),
+            None => {
+                return Value::Error(format!(
+                    "'{}' is not declared; call declare(\"{}\", param_types, return_type) first",
+                    name, name
+                ))
+            }
+        }
+    };
+
+    let call_args = &args[1..];
+    if call_args.len() != signature.params.len() {
+        return Value::Error(format!(
+            "{}() expects {} argument{}, got {}",
+            name,
+            signature.params.len(),
+            if signature.params.len() == 1 { "" } else { "s" },
+            call_args.len()
+        ));
+    }
+    let mut frame = CallFrame::default();
+    for (index, (ty, value)) in signature.params.iter().zip(call_args).enumerate() {
+        if let Err(message) = frame.push(*ty, value) {
+            return Value::Error(format!("{}() argument {} {}", name, index + 1, message));
+        }
+    }
+    invoke(&signature, &frame)
+}
+
+/// Methods on the `NativeLibrary` returned by `ffi.load()`
+pub(crate) fn call_native_library_method(
+    obj: &Value,
+    method: &str,
+    args: &[Value],
+) -> Option<Value> {
+    let Value::NativeLibrary(library) = obj else {
+        return None;
+    };
+
+    let result = match method {
+        "declare" => match parse_signature(library, args) {
+            Ok((name, signature)) => {
+                library
+                    .signatures
+                    .lock()
+                    .unwrap_or_else(|poisoned| poisoned.into_inner())
+                    .insert(name, signature);
+                // Returning the library lets declarations chain into a call
+                obj.clone()
+            }
+            Err(message) => Value::Error(message),
+        },
+        "call" => call_declared(library, args),
+        "path" => Value::Str(Arc::new(library.path.clone())),
+        _ => Value::Error(format!("NativeLibrary has no method '{}'", method)),
+    };
+    Some(result)
+}
+
+fn require_address(args: &[Value], function_name: &str) -> Result<usize, Value> {
+    match args.first() {
+        Some(Value::Int(address)) if *address != 0 => Ok(*address as usize),
+        Some(Value::Null) | Some(Value::Int(0)) => {
+            Err(Value::Error(format!("{}() received a null pointer", function_name)))
+        }
+        _ => Err(Value::Error(format!("{}() requires a pointer argument", function_name))),
+    }
+}
+
+fn call_ffi_module(method: &str, args: &[Value]) -> Value {
+    match method {
+        "load" => match args.first() {
+            Some(Value::Str(path)) => match open_library(path) {
+                Ok(library) => Value::NativeLibrary(Arc::new(library)),
+                Err(message) => Value::Error(message),
+            },
+            _ => Value::Error("ffi.load() requires a library path string".to_string()),
+        },
+        "alloc" => match args.first() {
+            Some(Value::Int(size)) if *size > 0 => {
+                // SAFETY: plain zeroed allocation; the script frees it with `ffi.free`.
+                let pointer = unsafe { libc::calloc(1, *size as usize) };
+                if pointer.is_null() {
+                    Value::Error(format!("ffi.alloc() could not allocate {} bytes", size))
+                } else {
+                    Value::Int(pointer as i64)
+                }
+            }
+            _ => Value::Error("ffi.alloc() requires a positive size".to_string()),
+        },
+        "free" => match args.first() {
+            Some(Value::Null) => Value::Null,
+            Some(Value::Int(address)) => {
+                // SAFETY: the script passes memory from `ffi.alloc` or a C allocator.
+                unsafe { libc::free(*address as *mut libc::c_void) };
+                Value::Null
+            }
+            _ => Value::Error("ffi.free() requires a pointer argument".to_string()),
+        },
+        "read_pointer" => match require_address(args, "ffi.read_pointer") {
+            // SAFETY: the script vouches that the address holds a pointer-sized value.
+            Ok(address) => match unsafe { std::ptr::read_unaligned(address as *const i64) } {
+                0 => Value::Null,
+                value => Value::Int(value),
+            },
+            Err(error) => error,
+        },
+        "read_string" => match require_address(args, "ffi.read_string") {
+            // SAFETY: the script vouches that the address holds a NUL-terminated string.
+            Ok(address) => Value::Str(Arc::new(
+                unsafe { CStr::from_ptr(address as *const libc::c_char) }
+                    .to_string_lossy()
+                    .into_owned(),
+            )),
+            Err(error) => error,
+        },
+        _ => Value::Error(format!("Module 'ffi' has no export '{}'", method)),
+    }
+}
+
+pub fn handle(name: &str, args: &[Value]) -> Option<Value> {
+    name.strip_prefix("ffi.").map(|method| call_ffi_module(method, args))
+}
+
+#[cfg(all(test, target_os = "linux", any(target_arch = "x86_64", target_arch = "aarch64")))]
+mod tests {
+    use super::*;
+
+    fn declare(library: &Value, name: &str, params: &[&str], returns: &str) {
+        let params =
+            params.iter().map(|param| Value::Str(Arc::new(param.to_string()))).collect::<Vec<_>>();
+        let declared = call_native_library_method(
+            library,
+            "declare",
+            &[
+                Value::Str(Arc::new(name.to_string())),
+                Value::Array(Arc::new(params)),
+                Value::Str(Arc::new(returns.to_string())),
+            ],
+        );
+        assert!(matches!(declared, Some(Value::NativeLibrary(_))), "{:?}", declared);
+    }
+
+    fn call(library: &Value, name: &str, args: &[Value]) -> Value {
+        let mut call_args = vec![Value::Str(Arc::new(name.to_string()))];
+        call_args.extend_from_slice(args);
+        call_native_library_method(library, "call", &call_args).unwrap()
+    }
+
+    #[test]
+    fn test_mixed_integer_and_floating_arguments_reach_their_registers() {
+        let libm = call_ffi_module("load", &[Value::Str(Arc::new("libm.so.6".to_string()))]);
+        declare(&libm, "ldexp", &["double", "int"], "double");
+        declare(&libm, "powf", &["float", "float"], "float");
+        assert!(
+            matches!(call(&libm, "ldexp", &[Value::Float(1.5), Value::Int(3)]), Value::Float(n) if n == 12.0)
+        );
+        assert!(
+            matches!(call(&libm, "powf", &[Value::Float(2.0), Value::Int(10)]), Value::Float(n) if n == 1024.0)
+        );
+
+        let libc = call_ffi_module("load", &[Value::Str(Arc::new("libc.so.6".to_string()))]);
+        declare(&libc, "strtol", &["string", "pointer", "int"], "long");
+        assert!(matches!(
+            call(
+                &libc,
+                "strtol",
+                &[Value::Str(Arc::new("-ff".to_string())), Value::Null, Value::Int(16)]
+            ),
+            Value::Int(-255)
+        ));
+    }
+}
@@ -312,8 +312,280 @@ fn extract_zip_archive_with_limits(
     Ok(extracted_files)
 }
 
+fn fs_path_arg<'a>(method: &str, arg_values: &'a [Value]) -> Result<&'a str, Value> {
+    match arg_values.first() {
+        Some(Value::Str(path)) => Ok(path.as_str()),
+        _ => Err(Value::Error(format!("fs.{}() requires a path string", method))),
+    }
+}
+
+fn fs_data_arg<'a>(method: &str, arg_values: &'a [Value]) -> Result<&'a [u8], Value> {
+    match arg_values.get(1) {
+        Some(Value::Str(text)) => Ok(text.as_bytes()),
+        Some(Value::Bytes(bytes)) => Ok(bytes.as_slice()),
+        _ => Err(Value::Error(format!("fs.{}() data must be a string or bytes", method))),
+    }
+}
+
+/// `{size, mtime, mode, is_file, is_dir, is_symlink}` for `path`; `mtime` is Unix seconds.
+/// Metadata follows symlinks, except `is_symlink` which reports the link itself.
+fn fs_stat_fields(path: &Path) -> Result<DictMap, String> {
+    let metadata = fs::metadata(path)
+        .map_err(|error| format!("Cannot stat '{}': {}", path.display(), error))?;
+    let is_symlink =
+        fs::symlink_metadata(path).map(|link| link.file_type().is_symlink()).unwrap_or(false);
+    let mtime = metadata
+        .modified()
+        .ok()
+        .and_then(|modified| modified.duration_since(std::time::UNIX_EPOCH).ok())
+        .map(|elapsed| elapsed.as_secs() as i64)
+        .unwrap_or(0);
+    #[cfg(unix)]
+    let mode = {
+        use std::os::unix::fs::PermissionsExt;
+        (metadata.permissions().mode() & 0o7777) as i64
+    };
+    #[cfg(not(unix))]
+    let mode = if metadata.permissions().readonly() { 0o444 } else { 0o644 };
+
+    let mut fields = DictMap::default();
+    fields.insert("size".into(), Value::Int(metadata.len() as i64));
+    fields.insert("mtime".into(), Value::Int(mtime));
+    fields.insert("mode".into(), Value::Int(mode));
+    fields.insert("is_file".into(), Value::Bool(metadata.is_file()));
+    fields.insert("is_dir".into(), Value::Bool(metadata.is_dir()));
+    fields.insert("is_symlink".into(), Value::Bool(is_symlink));
+    Ok(fields)
+}
+
+fn sorted_dir_entries(dir: &Path) -> Result<Vec<fs::DirEntry>, String> {
+    let mut entries: Vec<fs::DirEntry> = fs::read_dir(dir)
+        .map_err(|error| format!("Cannot list directory '{}': {}", dir.display(), error))?
+        .flatten()
+        .collect();
+    entries.sort_by_key(|entry| entry.file_name());
+    Ok(entries)
+}
+
+fn walk_fs_dir(
+    dir: &Path,
+    visit: &mut dyn FnMut(Value) -> Value,
+    visited: &mut i64,
+) -> Result<(), Value> {
+    for entry in sorted_dir_entries(dir).map_err(Value::Error)? {
+        let path = entry.path();
+        let mut fields = fs_stat_fields(&path).map_err(Value::Error)?;
+        let descend = matches!(fields.get("is_dir"), Some(Value::Bool(true)))
+            && !matches!(fields.get("is_symlink"), Some(Value::Bool(true)));
+        fields.insert("path".into(), Value::Str(Arc::new(path.to_string_lossy().to_string())));
+        fields.insert(
+            "name".into(),
+            Value::Str(Arc::new(entry.file_name().to_string_lossy().to_string())),
+        );
+
+        *visited += 1;
+        match visit(Value::Dict(Arc::new(fields))) {
+            error @ (Value::Error(_) | Value::ErrorObject { .. }) => return Err(error),
+            Value::Bool(false) => {}
+            _ if descend => walk_fs_dir(&path, visit, visited)?,
+            _ => {}
+        }
+    }
+    Ok(())
+}
+
+/// Visit everything below `root` depth-first, parents before children, in name order.
+///
+/// `visit` receives each entry's stat fields plus `path` and `name`. Returning `false` for a
+/// directory skips its contents; returning an error stops the walk. Symlinked directories
+/// are reported but not followed. Returns the number of entries visited.
+pub(crate) fn walk_fs(root: &str, visit: &mut dyn FnMut(Value) -> Value) -> Result<i64, Value> {
+    let mut visited = 0;
+    walk_fs_dir(Path::new(root), visit, &mut visited)?;
+    Ok(visited)
+}
+
+/// One `/`-separated piece of a glob pattern.
+enum GlobSegment {
+    Literal(String),
+    Pattern(regex::Regex),
+    AnyDepth,
+}
+
+impl GlobSegment {
+    fn parse(segment: &str) -> Result<Self, String> {
+        if segment == "**" {
+            return Ok(GlobSegment::AnyDepth);
+        }
+        if !segment.contains(['*', '?', '[']) {
+            return Ok(GlobSegment::Literal(segment.to_string()));
+        }
+
+        let mut pattern = String::from("^");
+        let mut chars = segment.chars().peekable();
+        while let Some(ch) = chars.next() {
+            match ch {
+                '*' => pattern.push_str(".*"),
+                '?' => pattern.push('.'),
+                '[' => {
+                    pattern.push('[');
+                    if chars.peek() == Some(&'!') {
+                        chars.next();
+                        pattern.push('^');
+                    }
+                    for class_ch in chars.by_ref() {
+                        if class_ch == ']' {
+                            break;
+                        }
+                        if class_ch == '\\' || class_ch == '[' {
+                            pattern.push('\\');
+                        }
+                        pattern.push(class_ch);
+                    }
+                    pattern.push(']');
+                }
+                other => pattern.push_str(&regex::escape(&other.to_string())),
+            }
+        }
+        pattern.push('$');
+        regex::Regex::new(&pattern)
+            .map(GlobSegment::Pattern)
+            .map_err(|error| format!("fs.glob() invalid pattern segment '{}': {}", segment, error))
+    }
+}
+
+fn glob_join(prefix: &str, name: &str) -> String {
+    match prefix {
+        "" => name.to_string(),
+        "/" => format!("/{}", name),
+        _ => format!("{}/{}", prefix, name),
+    }
+}
+
+fn glob_collect(
+    dir: &Path,
+    shown: &str,
+    segments: &[GlobSegment],
+    matches: &mut std::collections::BTreeSet<String>,
+) {
+    let Some((segment, rest)) = segments.split_first() else {
+        matches.insert(shown.to_string());
+        return;
+    };
+    let fs_dir = if dir.as_os_str().is_empty() { Path::new(".") } else { dir };
+
+    match segment {
+        GlobSegment::Literal(name) => {
+            let next = dir.join(name);
+            if (rest.is_empty() && next.exists()) || next.is_dir() {
+                glob_collect(&next, &glob_join(shown, name), rest, matches);
+            }
+        }
+        GlobSegment::Pattern(pattern) => {
+            for entry in sorted_dir_entries(fs_dir).unwrap_or_default() {
+                let name = entry.file_name().to_string_lossy().to_string();
+                // Like shell globs, wildcards skip dotfiles unless the pattern names the dot.
+                if name.starts_with('.') || !pattern.is_match(&name) {
+                    continue;
+                }
+                if rest.is_empty() || entry.path().is_dir() {
+                    glob_collect(&dir.join(&name), &glob_join(shown, &name), rest, matches);
+                }
+            }
+        }
+        GlobSegment::AnyDepth => {
+            glob_collect(dir, shown, rest, matches);
+            for entry in sorted_dir_entries(fs_dir).unwrap_or_default() {
+                let name = entry.file_name().to_string_lossy().to_string();
+                let is_real_dir = entry.file_type().map(|kind| kind.is_dir()).unwrap_or(false);
+                if is_real_dir && !name.starts_with('.') {
+                    glob_collect(&dir.join(&name), &glob_join(shown, &name), segments, matches);
+                }
+            }
+        }
+    }
+}
+
+/// Expand `*`, `?`, `[abc]`/`[!abc]`, and `**` (any number of directories) into the sorted
+/// list of existing paths. Wildcards never match a leading `.`, and `**` does not follow
+/// symlinked directories.
+fn glob_paths(pattern: &str) -> Result<Vec<String>, String> {
+    let (root, shown, body) = match pattern.strip_prefix('/') {
+        Some(body) => (Path::new("/"), "/", body),
+        None => (Path::new(""), "", pattern),
+    };
+    let segments = body
+        .split('/')
+        .filter(|segment| !segment.is_empty())
+        .map(GlobSegment::parse)
+        .collect::<Result<Vec<_>, _>>()?;
+    if segments.is_empty() {
+        return Err("fs.glob() requires a non-empty pattern".to_string());
+    }
+
+    let mut matches = std::collections::BTreeSet::new();
+    glob_collect(root, shown, &segments, &mut matches);
+    Ok(matches.into_iter().collect())
+}
+
+/// Dispatch for the `fs.<method>` natives exported by `builtins::fs_module_value`.
+/// `fs.walk` needs to call back into the running program, so it is handled by each backend.
+fn call_fs_module(method: &str, arg_values: &[Value]) -> Result<Value, Value> {
+    let path = fs_path_arg(method, arg_values)?;
+    let result = match method {
+        "read" => {
+            validate_read_size_limit(path).map_err(Value::Error)?;
+            fs::read_to_string(path)
+                .map(|content| Value::Str(Arc::new(content)))
+                .map_err(|error| format!("Cannot read file '{}': {}", path, error))
+        }
+        "write" => {
+            let data = fs_data_arg(method, arg_values)?;
+            validate_write_size_limit(path, data.len()).map_err(Value::Error)?;
+            fs::write(path, data)
+                .map(|_| Value::Bool(true))
+                .map_err(|error| format!("Cannot write file '{}': {}", path, error))
+        }
+        "append" => {
+            let data = fs_data_arg(method, arg_values)?;
+            validate_append_size_limit(path, data.len()).map_err(Value::Error)?;
+            OpenOptions::new()
+                .create(true)
+                .append(true)
+                .open(path)
+                .and_then(|mut file| file.write_all(data))
+                .map(|_| Value::Bool(true))
+                .map_err(|error| format!("Cannot append to file '{}': {}", path, error))
+        }
+        "exists" => Ok(Value::Bool(Path::new(path).exists())),
+        "mkdir_all" => fs::create_dir_all(path)
+            .map(|_| Value::Bool(true))
+            .map_err(|error| format!("Cannot create directory '{}': {}", path, error)),
+        "stat" => fs_stat_fields(Path::new(path)).map(|fields| Value::Dict(Arc::new(fields))),
+        "glob" => glob_paths(path).map(|paths| {
+            Value::Array(Arc::new(
+                paths.into_iter().map(|path| Value::Str(Arc::new(path))).collect(),
+            ))
+        }),
+        _ => Err(format!("Module 'fs' has no export '{}'", method)),
+    };
+    result.map_err(Value::Error)
+}
+
 pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Option<Value> {
     let result = match name {
+        "fs.walk" => match (arg_values.first(), arg_values.get(1)) {
+            (Some(Value::Str(root)), Some(callback)) => {
+                let callback = callback.clone();
+                let mut visit = |entry: Value| _interp.call_user_function(&callback, &[entry]);
+                walk_fs(root, &mut visit).map(Value::Int).unwrap_or_else(|error| error)
+            }
+            _ => Value::Error("fs.walk() requires a directory path and a callback".to_string()),
+        },
+        name if name.starts_with("fs.") => {
+            call_fs_module(&name["fs.".len()..], arg_values).unwrap_or_else(|error| error)
+        }
+
         // Async file operations - return Promises for true concurrency
         "read_file_async" => {
             if let Some(Value::Str(path)) = arg_values.first() {
@@ -781,6 +1053,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                         stack: Vec::new(),
                         line: None,
                         cause: None,
+                        payload: None,
                     },
                 }
             } else {
@@ -820,6 +1093,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                             stack: Vec::new(),
                                             line: None,
                                             cause: None,
+                                            payload: None,
                                         },
                                     },
                                     Err(error) => Value::ErrorObject {
@@ -830,6 +1104,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                         stack: Vec::new(),
                                         line: None,
                                         cause: None,
+                                        payload: None,
                                     },
                                 }
                             }
@@ -842,6 +1117,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     } else {
@@ -874,6 +1150,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     } else {
@@ -902,6 +1179,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             stack: Vec::new(),
                             line: None,
                             cause: None,
+                            payload: None,
                         },
                     }
                 } else {
@@ -935,6 +1213,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                     stack: Vec::new(),
                                     line: None,
                                     cause: None,
+                                    payload: None,
                                 },
                             },
                             Err(error) => Value::ErrorObject {
@@ -946,6 +1225,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         },
                         Err(error) => Value::ErrorObject {
@@ -957,6 +1237,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             stack: Vec::new(),
                             line: None,
                             cause: None,
+                            payload: None,
                         },
                     }
                 }
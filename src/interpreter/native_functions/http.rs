@@ -1,11 +1,13 @@
 // File: src/interpreter/native_functions/http.rs
 //
-// HTTP client native functions
+// HTTP client and server native functions
 
-use crate::interpreter::{DictMap, Value};
+use super::async_ops::{self, PromiseCallback};
+use crate::interpreter::{DictMap, RouterState, Value};
 use crate::{builtins, network_policy};
 use reqwest::Method;
 use std::collections::HashMap;
+use std::sync::atomic::{AtomicUsize, Ordering};
 use std::sync::Arc;
 use std::time::Duration;
 
@@ -460,8 +462,500 @@ fn extract_tool_call_names(response_json: &Value) -> Vec<String> {
     names
 }
 
+/// A request assembled from `http.*` arguments before it is sent.
+struct HttpModuleRequest {
+    method: Method,
+    url: String,
+    headers: Vec<(String, String)>,
+    body: Option<String>,
+    timeout: Duration,
+}
+
+fn has_header(headers: &[(String, String)], name: &str) -> bool {
+    headers.iter().any(|(key, _)| key.eq_ignore_ascii_case(name))
+}
+
+/// Strings are sent as-is; any other value is encoded as JSON and labelled as such.
+fn encode_http_module_body(
+    value: &Value,
+    headers: &mut Vec<(String, String)>,
+    surface: &str,
+) -> Result<Option<String>, String> {
+    let encoded = match value {
+        Value::Null => return Ok(None),
+        Value::Str(text) => return Ok(Some(text.to_string())),
+        other => builtins::to_json(other)
+            .map_err(|error| format!("{}() could not encode body as JSON: {}", surface, error))?,
+    };
+    if !has_header(headers, "Content-Type") {
+        headers.push(("Content-Type".to_string(), "application/json".to_string()));
+    }
+    Ok(Some(encoded))
+}
+
+fn http_module_options(value: Option<&Value>, surface: &str) -> Result<DictMap, String> {
+    match value {
+        None | Some(Value::Null) => Ok(DictMap::default()),
+        Some(value) => dict_like_from_value(value)
+            .ok_or_else(|| format!("{}() options must be a dictionary", surface)),
+    }
+}
+
+fn http_module_headers(
+    value: Option<&Value>,
+    surface: &str,
+) -> Result<Vec<(String, String)>, String> {
+    match value {
+        None | Some(Value::Null) => Ok(Vec::new()),
+        Some(value) => header_pairs_from_value(value)
+            .ok_or_else(|| format!("{}() headers must be a dictionary of strings", surface)),
+    }
+}
+
+fn parse_http_module_request(method: &str, args: &[Value]) -> Result<HttpModuleRequest, String> {
+    let surface = format!("http.{}", method);
+    let (verb, url_index) = match method {
+        "get" | "get_json" => (Method::GET, 0),
+        "post" | "post_json" => (Method::POST, 0),
+        "put" => (Method::PUT, 0),
+        "patch" => (Method::PATCH, 0),
+        "delete" => (Method::DELETE, 0),
+        "request" => {
+            let verb = match args.first() {
+                Some(Value::Str(verb)) => Method::from_bytes(verb.to_uppercase().as_bytes())
+                    .map_err(|error| format!("Invalid HTTP method '{}': {}", verb, error))?,
+                _ => return Err(format!("{}() requires an HTTP method string", surface)),
+            };
+            (verb, 1)
+        }
+        _ => return Err(format!("Module 'http' has no export '{}'", method)),
+    };
+
+    let url = match args.get(url_index) {
+        Some(Value::Str(url)) => url.to_string(),
+        _ => return Err(format!("{}() requires a URL string", surface)),
+    };
+
+    // Methods that carry a body take (url, body, headers?, options?); the rest (url, options?).
+    let carries_body = matches!(method, "post" | "post_json" | "put" | "patch");
+    let (mut headers, options) = if carries_body {
+        (http_module_headers(args.get(2), &surface)?, http_module_options(args.get(3), &surface)?)
+    } else {
+        (Vec::new(), http_module_options(args.get(url_index + 1), &surface)?)
+    };
+    if let Some(extra) = options.get("headers") {
+        headers.extend(http_module_headers(Some(extra), &surface)?);
+    }
+
+    let body_value = if carries_body {
+        args.get(1).cloned()
+    } else {
+        options.get("json").or_else(|| options.get("body")).cloned()
+    };
+    let body = match body_value {
+        Some(value) => encode_http_module_body(&value, &mut headers, &surface)?,
+        None => None,
+    };
+    if method.ends_with("_json") && !has_header(&headers, "Accept") {
+        headers.push(("Accept".to_string(), "application/json".to_string()));
+    }
+
+    let timeout = match options.get("timeout") {
+        None | Some(Value::Null) => network_policy::default_http_timeout(),
+        Some(value) => match value_to_f64(value) {
+            Some(seconds) if seconds > 0.0 => Duration::from_secs_f64(seconds),
+            _ => {
+                return Err(format!(
+                    "{}() options.timeout must be a positive number of seconds",
+                    surface
+                ))
+            }
+        },
+    };
+
+    Ok(HttpModuleRequest { method: verb, url, headers, body, timeout })
+}
+
+/// Send the request and describe the reply as `{status, ok, headers, body}`.
+fn send_http_module_request(request: HttpModuleRequest) -> Result<DictMap, String> {
+    let surface = format!("HTTP {}", request.method.as_str());
+    network_policy::enforce_http_url_destination_policy(&request.url, &surface)?;
+    let task_surface = surface.clone();
+    let (status, response_headers, body_bytes) =
+        network_policy::run_blocking_http_task(&surface, move || {
+            let client = network_policy::build_http_client(request.timeout)?;
+            let mut builder = client.request(request.method, &request.url);
+            for (key, value) in request.headers {
+                builder = builder.header(&key, &value);
+            }
+            if let Some(body) = request.body {
+                builder = builder.body(body);
+            }
+            let response =
+                builder.send().map_err(|error| format!("{} failed: {}", task_surface, error))?;
+            network_policy::read_http_response_bytes(response, &task_surface)
+        })?;
+
+    let mut headers = DictMap::default();
+    for (name, value) in response_headers.iter() {
+        if let Ok(value) = value.to_str() {
+            headers.insert(name.as_str().into(), Value::Str(Arc::new(value.to_string())));
+        }
+    }
+
+    let mut response = DictMap::default();
+    response.insert("status".into(), Value::Int(status as i64));
+    response.insert("ok".into(), Value::Bool((200..300).contains(&status)));
+    response.insert("headers".into(), Value::Dict(Arc::new(headers)));
+    response.insert(
+        "body".into(),
+        Value::Str(Arc::new(String::from_utf8_lossy(&body_bytes).to_string())),
+    );
+    Ok(response)
+}
+
+/// Entry point for the `http.<method>` natives exported by `builtins::http_module_value`.
+fn call_http_module(method: &str, args: &[Value]) -> Value {
+    if method == "router" {
+        return Value::Router(Arc::new(RouterState::new()));
+    }
+    let request = match parse_http_module_request(method, args) {
+        Ok(request) => request,
+        Err(message) => return Value::Error(message),
+    };
+    let url = request.url.clone();
+    let response = match send_http_module_request(request) {
+        Ok(response) => response,
+        Err(message) => return Value::Error(message),
+    };
+    if !method.ends_with("_json") {
+        return Value::Dict(Arc::new(response));
+    }
+
+    // JSON helpers hand back the decoded body; failures keep the response as the error payload.
+    let status = value_to_i64(response.get("status").unwrap_or(&Value::Null)).unwrap_or(0);
+    let decoded = match response.get("body") {
+        Some(Value::Str(body)) if (200..300).contains(&status) => builtins::parse_json(body)
+            .map_err(|error| {
+                format!("http.{}() response from {} is not valid JSON: {}", method, url, error)
+            }),
+        _ => Err(format!("http.{}() got HTTP {} from {}", method, status, url)),
+    };
+    decoded.unwrap_or_else(|message| Value::ErrorObject {
+        message,
+        stack: Vec::new(),
+        line: None,
+        cause: None,
+        payload: Some(Box::new(Value::Dict(Arc::new(response)))),
+    })
+}
+
+/// Status, headers, and body produced from a route handler's return value.
+#[derive(Debug, PartialEq)]
+struct HttpHandlerResponse {
+    status: u16,
+    headers: Vec<(String, String)>,
+    body: String,
+}
+
+impl HttpHandlerResponse {
+    fn text(status: u16, body: impl Into<String>) -> Self {
+        HttpHandlerResponse {
+            status,
+            headers: vec![("Content-Type".to_string(), "text/plain; charset=utf-8".to_string())],
+            body: body.into(),
+        }
+    }
+
+    fn into_value(self) -> Value {
+        let mut headers = DictMap::default();
+        for (name, value) in self.headers {
+            headers.insert(name.into(), Value::Str(Arc::new(value)));
+        }
+        let mut response = DictMap::default();
+        response.insert("status".into(), Value::Int(self.status as i64));
+        response.insert("headers".into(), Value::Dict(Arc::new(headers)));
+        response.insert("body".into(), Value::Str(Arc::new(self.body)));
+        Value::Dict(Arc::new(response))
+    }
+}
+
+fn http_response_from_map(map: &DictMap) -> Result<HttpHandlerResponse, String> {
+    let status = match map.get("status") {
+        None | Some(Value::Null) => 200,
+        Some(Value::Int(code)) if (100..=999).contains(code) => *code as u16,
+        Some(_) => {
+            return Err("http.serve() response status must be an HTTP status code".to_string())
+        }
+    };
+    let mut headers = http_module_headers(map.get("headers"), "http.serve")?;
+    let body = map.get("body").unwrap_or(&Value::Null);
+    let body = encode_http_module_body(body, &mut headers, "http.serve")?.unwrap_or_default();
+    Ok(HttpHandlerResponse { status, headers, body })
+}
+
+/// Strings become `200 text/plain`; response maps supply `status`, `headers`, and `body`
+/// (non-string bodies are sent as JSON); async handlers are awaited; errors become `500`.
+fn http_handler_response(result: Value) -> HttpHandlerResponse {
+    match result {
+        Value::Promise { .. } => match async_ops::block_on_promise(&result) {
+            Ok(value) => http_handler_response(value),
+            Err(message) => HttpHandlerResponse::text(500, message),
+        },
+        Value::Str(body) => HttpHandlerResponse::text(200, body.as_ref().clone()),
+        Value::Null => {
+            HttpHandlerResponse { status: 204, headers: Vec::new(), body: String::new() }
+        }
+        Value::HttpResponse { status, body, headers } => {
+            HttpHandlerResponse { status, headers: headers.into_iter().collect(), body }
+        }
+        Value::Error(message) | Value::ErrorObject { message, .. } => {
+            HttpHandlerResponse::text(500, message)
+        }
+        other => match dict_like_from_value(&other) {
+            Some(map) => http_response_from_map(&map)
+                .unwrap_or_else(|message| HttpHandlerResponse::text(500, message)),
+            None => HttpHandlerResponse::text(
+                500,
+                "Internal Server Error: route handler must return a response map or string",
+            ),
+        },
+    }
+}
+
+fn is_http_handler(value: &Value) -> bool {
+    matches!(
+        value,
+        Value::Function(..)
+            | Value::AsyncFunction(..)
+            | Value::BytecodeFunction { .. }
+            | Value::NativeFunction(_)
+    )
+}
+
+/// What `http.serve` dispatches to: a router, or one handler for every request.
+enum HttpServeTarget {
+    Router(Arc<RouterState>),
+    Handler(Value),
+}
+
+impl HttpServeTarget {
+    fn from_value(value: &Value) -> Option<Self> {
+        match value {
+            Value::Router(router) => Some(HttpServeTarget::Router(router.clone())),
+            handler if is_http_handler(handler) => Some(HttpServeTarget::Handler(handler.clone())),
+            _ => None,
+        }
+    }
+
+    /// Pick the handler for a request map and fill in its `params`.
+    fn route(&self, mut request: DictMap) -> Option<(Value, Value)> {
+        let (handler, params) = match self {
+            HttpServeTarget::Router(router) => {
+                let method = match request.get("method") {
+                    Some(Value::Str(method)) => method.to_string(),
+                    _ => "GET".to_string(),
+                };
+                let path = match request.get("path") {
+                    Some(Value::Str(path)) => path.to_string(),
+                    _ => "/".to_string(),
+                };
+                router.resolve(&method, &path)?
+            }
+            HttpServeTarget::Handler(handler) => (handler.clone(), HashMap::new()),
+        };
+        let mut params_dict = DictMap::default();
+        for (key, value) in params {
+            params_dict.insert(key.into(), Value::Str(Arc::new(value)));
+        }
+        request.insert("params".into(), Value::Dict(Arc::new(params_dict)));
+        Some((handler, Value::Dict(Arc::new(request))))
+    }
+}
+
+fn http_serve_addr(value: Option<&Value>) -> Result<String, String> {
+    let invalid = || "http.serve() address must be \"host:port\", \":port\", or a port number";
+    match value {
+        Some(Value::Int(port)) if (0..=65535).contains(port) => Ok(format!("0.0.0.0:{}", port)),
+        Some(Value::Str(addr)) => match addr.trim().rsplit_once(':') {
+            Some((host, port)) if port.parse::<u16>().is_ok() => {
+                let host = if host.is_empty() { "0.0.0.0" } else { host };
+                Ok(format!("{}:{}", host, port))
+            }
+            _ => Err(invalid().to_string()),
+        },
+        _ => Err(invalid().to_string()),
+    }
+}
+
+/// Requests `http.serve` handles at once; further requests get a 503 until a worker frees up.
+const MAX_HTTP_SERVE_WORKERS: usize = 64;
+
+/// The request map handlers receive, without its body; header names are lowercased.
+fn http_request_fields(request: &tiny_http::Request) -> DictMap {
+    let method = request.method().to_string();
+    let raw_path = request.url().to_string();
+    let (path, query, decoded_query, query_string) =
+        crate::http_request_utils::split_http_path_and_query_with_decoded(&raw_path);
+
+    let string_dict = |pairs: HashMap<String, String>| {
+        let mut dict = DictMap::default();
+        for (key, value) in pairs {
+            dict.insert(key.into(), Value::Str(Arc::new(value)));
+        }
+        Value::Dict(Arc::new(dict))
+    };
+    let headers: HashMap<String, String> = request
+        .headers()
+        .iter()
+        .map(|header| {
+            (header.field.as_str().to_ascii_lowercase(), header.value.as_str().to_string())
+        })
+        .collect();
+
+    let mut fields = DictMap::default();
+    fields.insert("method".into(), Value::Str(Arc::new(method)));
+    fields.insert("path".into(), Value::Str(Arc::new(path)));
+    fields.insert("raw_path".into(), Value::Str(Arc::new(raw_path)));
+    fields.insert("query".into(), string_dict(query));
+    fields.insert("query_decoded".into(), string_dict(decoded_query));
+    fields.insert("query_string".into(), Value::Str(Arc::new(query_string)));
+    fields.insert("headers".into(), string_dict(headers));
+    fields
+}
+
+/// Reads a request body of at most `MAX_NETWORK_BODY_BYTES`; `None` means it was larger.
+fn read_http_request_body(request: &mut tiny_http::Request) -> Option<String> {
+    use std::io::Read;
+
+    let limit = network_policy::MAX_NETWORK_BODY_BYTES;
+    if request.body_length().is_some_and(|length| length > limit) {
+        return None;
+    }
+    let mut body = Vec::new();
+    request.as_reader().take(limit as u64 + 1).read_to_end(&mut body).ok();
+    (body.len() <= limit).then(|| String::from_utf8_lossy(&body).into_owned())
+}
+
+/// Releases a `http.serve` worker slot when the worker finishes.
+struct HttpWorkerSlot(Arc<AtomicUsize>);
+
+impl Drop for HttpWorkerSlot {
+    fn drop(&mut self) {
+        self.0.fetch_sub(1, Ordering::SeqCst);
+    }
+}
+
+fn respond_http(request: tiny_http::Request, response: HttpHandlerResponse) {
+    let mut reply =
+        tiny_http::Response::from_string(response.body).with_status_code(response.status);
+    for (name, value) in response.headers {
+        if let Ok(header) = tiny_http::Header::from_bytes(name.as_bytes(), value.as_bytes()) {
+            reply = reply.with_header(header);
+        }
+    }
+    let _ = request.respond(reply);
+}
+
+/// `http.serve(addr, handler)`: accept requests until the process exits, running each
+/// matched handler on its own worker thread. `spawn_worker` packages a handler so it can
+/// run off the accept loop; each backend supplies its own. Bodies are read by the worker,
+/// so a slow or oversized upload never stalls the accept loop.
+pub(crate) fn serve_http_module(
+    args: &[Value],
+    mut spawn_worker: impl FnMut(Value) -> PromiseCallback,
+) -> Value {
+    let addr = match http_serve_addr(args.first()) {
+        Ok(addr) => addr,
+        Err(message) => return Value::Error(message),
+    };
+    let Some(target) = args.get(1).and_then(HttpServeTarget::from_value) else {
+        return Value::Error("http.serve() handler must be a function or router".to_string());
+    };
+    let server = match tiny_http::Server::http(addr.as_str()) {
+        Ok(server) => server,
+        Err(error) => {
+            return Value::Error(format!("http.serve() could not listen on {}: {}", addr, error))
+        }
+    };
+
+    println!("Serving HTTP on http://{}", addr);
+    let active_workers = Arc::new(AtomicUsize::new(0));
+    for mut request in server.incoming_requests() {
+        let fields = http_request_fields(&request);
+        let Some((handler, mut request_value)) = target.route(fields) else {
+            respond_http(request, HttpHandlerResponse::text(404, "Not Found"));
+            continue;
+        };
+        if active_workers.fetch_add(1, Ordering::SeqCst) >= MAX_HTTP_SERVE_WORKERS {
+            active_workers.fetch_sub(1, Ordering::SeqCst);
+            respond_http(request, HttpHandlerResponse::text(503, "Service Unavailable"));
+            continue;
+        }
+        let slot = HttpWorkerSlot(active_workers.clone());
+        let worker = spawn_worker(handler);
+        std::thread::spawn(move || {
+            let _slot = slot;
+            let Some(body) = read_http_request_body(&mut request) else {
+                respond_http(request, HttpHandlerResponse::text(413, "Payload Too Large"));
+                return;
+            };
+            if let Value::Dict(fields) = &mut request_value {
+                Arc::make_mut(fields).insert("body".into(), Value::Str(Arc::new(body)));
+            }
+            respond_http(request, http_handler_response(worker(vec![request_value])));
+        });
+    }
+    Value::Null
+}
+
+/// Shared `Router` method dispatch. `router.handle(request)` routes a request map in-process
+/// exactly like `http.serve` would, running the handler through `call_handler`.
+pub(crate) fn call_router_method(
+    router: &Arc<RouterState>,
+    method: &str,
+    args: &[Value],
+    call_handler: impl FnOnce(Value, Value) -> Value,
+) -> Value {
+    match (method, args) {
+        ("get" | "post" | "put" | "patch" | "delete", [Value::Str(path), handler])
+            if is_http_handler(handler) =>
+        {
+            router.add(method, path, handler.clone());
+            Value::Router(router.clone())
+        }
+        ("get" | "post" | "put" | "patch" | "delete", _) => {
+            Value::Error(format!("Router.{}() requires (path, handler_function)", method))
+        }
+        ("route", [Value::Str(verb), Value::Str(path), handler]) if is_http_handler(handler) => {
+            router.add(verb, path, handler.clone());
+            Value::Router(router.clone())
+        }
+        ("route", _) => {
+            Value::Error("Router.route() requires (method, path, handler_function)".to_string())
+        }
+        ("handle", [request]) => {
+            let Some(fields) = dict_like_from_value(request) else {
+                return Value::Error("Router.handle() requires a request dictionary".to_string());
+            };
+            let response = match HttpServeTarget::Router(router.clone()).route(fields) {
+                Some((handler, request)) => http_handler_response(call_handler(handler, request)),
+                None => HttpHandlerResponse::text(404, "Not Found"),
+            };
+            response.into_value()
+        }
+        ("handle", _) => Value::Error(format!(
+            "Router.handle() expects 1 argument (request), got {}",
+            args.len()
+        )),
+        _ => Value::Error(format!("Router has no method '{}'", method)),
+    }
+}
+
 pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
     let result = match name {
+        name if name.starts_with("http.") => call_http_module(&name["http.".len()..], arg_values),
+
         "parallel_http" => {
             if arg_values.len() != 1 {
                 return Some(Value::Error(format!(
@@ -1721,6 +2215,170 @@ mod tests {
         ));
     }
 
+    #[test]
+    fn test_http_module_argument_contract_errors() {
+        let expect_error = |name: &str, args: &[Value], expected: &str| {
+            let result = handle(name, args).expect("http module natives are handled");
+            assert!(
+                matches!(&result, Value::Error(message) if message.contains(expected)),
+                "{name}: expected error containing {expected:?}, got {result:?}"
+            );
+        };
+
+        expect_error("http.get", &[Value::Int(1)], "http.get() requires a URL string");
+        expect_error(
+            "http.get",
+            &[str_value("https://example.com"), Value::Int(5)],
+            "http.get() options must be a dictionary",
+        );
+        let mut options = DictMap::default();
+        options.insert("timeout".into(), Value::Int(0));
+        expect_error(
+            "http.delete",
+            &[str_value("https://example.com"), Value::Dict(Arc::new(options))],
+            "options.timeout must be a positive number of seconds",
+        );
+        expect_error(
+            "http.post",
+            &[str_value("https://example.com"), str_value("{}"), Value::Int(1)],
+            "http.post() headers must be a dictionary of strings",
+        );
+        expect_error(
+            "http.request",
+            &[Value::Int(1), str_value("https://example.com")],
+            "http.request() requires an HTTP method string",
+        );
+        expect_error("http.fetch", &[str_value("https://example.com")], "no export 'fetch'");
+    }
+
+    #[test]
+    fn test_http_handler_response_normalizes_handler_results() {
+        assert_eq!(
+            http_handler_response(str_value("hello")),
+            HttpHandlerResponse::text(200, "hello")
+        );
+        assert_eq!(http_handler_response(Value::Null).status, 204);
+        assert_eq!(
+            http_handler_response(Value::Error("boom".to_string())),
+            HttpHandlerResponse::text(500, "boom")
+        );
+        assert_eq!(http_handler_response(Value::Int(3)).status, 500);
+
+        let mut data = DictMap::default();
+        data.insert("id".into(), Value::Int(7));
+        let mut response = DictMap::default();
+        response.insert("status".into(), Value::Int(201));
+        response.insert("body".into(), Value::Dict(Arc::new(data)));
+        let created = http_handler_response(Value::Dict(Arc::new(response)));
+        assert_eq!(created.status, 201);
+        assert_eq!(created.body, "{\"id\":7}");
+        assert!(has_header(&created.headers, "content-type"));
+
+        let mut bad_status = DictMap::default();
+        bad_status.insert("status".into(), str_value("ok"));
+        assert_eq!(http_handler_response(Value::Dict(Arc::new(bad_status))).status, 500);
+    }
+
+    #[test]
+    fn test_http_serve_addr_accepts_host_port_shorthand_and_port_numbers() {
+        assert_eq!(http_serve_addr(Some(&str_value("127.0.0.1:8080"))).unwrap(), "127.0.0.1:8080");
+        assert_eq!(http_serve_addr(Some(&str_value(":9000"))).unwrap(), "0.0.0.0:9000");
+        assert_eq!(http_serve_addr(Some(&Value::Int(3000))).unwrap(), "0.0.0.0:3000");
+        assert!(http_serve_addr(Some(&str_value("localhost"))).is_err());
+        assert!(http_serve_addr(Some(&Value::Int(70000))).is_err());
+    }
+
+    #[test]
+    fn test_http_serve_request_body_is_capped() {
+        let mut request: tiny_http::Request =
+            tiny_http::TestRequest::new().with_body("payload").into();
+        assert_eq!(read_http_request_body(&mut request).as_deref(), Some("payload"));
+
+        let oversized = "x".repeat(network_policy::MAX_NETWORK_BODY_BYTES + 1);
+        let mut request: tiny_http::Request =
+            tiny_http::TestRequest::new().with_body(&oversized).into();
+        assert_eq!(read_http_request_body(&mut request), None);
+    }
+
+    #[test]
+    fn test_router_prefers_exact_routes_and_captures_params() {
+        let router = Arc::new(RouterState::new());
+        router.add("get", "/users/:id", Value::NativeFunction("param".to_string()));
+        router.add("GET", "/users/me", Value::NativeFunction("exact".to_string()));
+
+        let (handler, params) = router.resolve("GET", "/users/me").expect("exact route");
+        assert!(matches!(handler, Value::NativeFunction(name) if name == "exact"));
+        assert!(params.is_empty());
+
+        let (handler, params) = router.resolve("get", "/users/42").expect("param route");
+        assert!(matches!(handler, Value::NativeFunction(name) if name == "param"));
+        assert_eq!(params.get("id").map(String::as_str), Some("42"));
+
+        assert!(router.resolve("POST", "/users/42").is_none());
+
+        let missing = call_router_method(&router, "handle", &[Value::dict(Default::default())], {
+            |_, _| panic!("no route should match")
+        });
+        assert!(matches!(
+            missing,
+            Value::Dict(response) if matches!(response.get("status"), Some(Value::Int(404)))
+        ));
+    }
+
+    #[test]
+    fn test_http_module_post_json_encodes_body_and_decodes_reply() {
+        let Some((endpoint, request_rx, server_handle)) =
+            one_shot_json_server(201, "{\"id\":7,\"tags\":[\"a\"]}")
+        else {
+            eprintln!(
+                "skipping test_http_module_post_json_encodes_body_and_decodes_reply: local TCP bind not permitted in this environment"
+            );
+            return;
+        };
+
+        let mut payload = DictMap::default();
+        payload.insert("name".into(), str_value("ruff"));
+        let result =
+            handle("http.post_json", &[str_value(&endpoint), Value::Dict(Arc::new(payload))])
+                .expect("http.post_json should return a value");
+        server_handle.join().expect("server thread should finish");
+
+        let sent_body = request_rx.recv().expect("server should capture the request body");
+        assert_eq!(sent_body, "{\"name\":\"ruff\"}");
+        assert!(matches!(
+            result,
+            Value::Dict(dict)
+                if matches!(dict.get("id"), Some(Value::Int(7)))
+                    && matches!(dict.get("tags"), Some(Value::Array(tags)) if tags.len() == 1)
+        ));
+    }
+
+    #[test]
+    fn test_http_module_get_reports_status_headers_and_body() {
+        let Some((endpoint, _request_rx, server_handle)) = one_shot_json_server(404, "{}") else {
+            eprintln!(
+                "skipping test_http_module_get_reports_status_headers_and_body: local TCP bind not permitted in this environment"
+            );
+            return;
+        };
+
+        let result =
+            handle("http.get", &[str_value(&endpoint)]).expect("http.get should return a value");
+        server_handle.join().expect("server thread should finish");
+
+        let Value::Dict(response) = result else {
+            panic!("expected response dictionary, got {result:?}");
+        };
+        assert!(matches!(response.get("status"), Some(Value::Int(404))));
+        assert!(matches!(response.get("ok"), Some(Value::Bool(false))));
+        assert!(matches!(response.get("body"), Some(Value::Str(body)) if body.as_str() == "{}"));
+        assert!(matches!(
+            response.get("headers"),
+            Some(Value::Dict(headers))
+                if matches!(headers.get("content-type"), Some(Value::Str(value)) if value.as_str() == "application/json")
+        ));
+    }
+
     #[test]
     fn test_http_request_supports_single_request_dictionary_form() {
         let Some((endpoint, _request_rx, server_handle)) =
@@ -1,11 +1,12 @@
 // File: src/interpreter/native_functions/io.rs
 //
-// I/O-related native functions (print, input, etc.)
+// I/O-related native functions (print, input, byte-level file access, file handles)
 
-use crate::interpreter::{DictMap, Interpreter, Value};
+use crate::interpreter::{DictMap, Interpreter, NativeCapability, Value};
+use crate::runtime_limits;
 use std::fs::{self, File, OpenOptions};
-use std::io::{Read, Seek, SeekFrom, Write};
-use std::sync::Arc;
+use std::io::{BufRead, BufReader, ErrorKind, Read, Seek, SeekFrom, Write};
+use std::sync::{Arc, Mutex};
 use std::time::UNIX_EPOCH;
 
 fn parse_non_negative_u64(value: &Value, error_message: &str) -> Result<u64, Value> {
@@ -34,10 +35,24 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
         "eprint" => {
             let output_parts: Vec<String> =
                 arg_values.iter().map(Interpreter::stringify_value).collect();
-            eprintln!("{}", output_parts.join(" "));
+            interp.write_error_output(&output_parts.join(" "));
             Value::Null
         }
 
+        "print_f" => match arg_values.split_first() {
+            Some((Value::Str(template), format_args)) => {
+                match crate::builtins::format_string(template.as_ref(), format_args) {
+                    Ok(text) => {
+                        interp.write_output_text(&text);
+                        Value::Null
+                    }
+                    Err(message) => Value::Error(message),
+                }
+            }
+            Some(_) => Value::Error("print_f() first argument must be a string".to_string()),
+            None => Value::Error("print_f() requires at least 1 argument (template)".to_string()),
+        },
+
         "io_read_bytes" => {
             if 2 != arg_values.len() {
                 Value::Error("io_read_bytes requires two arguments: path and count".to_string())
@@ -449,11 +464,180 @@ pub fn handle(interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Opt
             }
         }
 
+        "io_open" => {
+            if !(2..=3).contains(&arg_values.len()) {
+                return Some(Value::Error(
+                    "io_open requires 2 or 3 arguments: path, mode, [overwrite]".to_string(),
+                ));
+            }
+            let (path, mode) = match (arg_values.first(), arg_values.get(1)) {
+                (Some(Value::Str(path)), Some(Value::Str(mode))) => (path, mode),
+                _ => {
+                    return Some(Value::Error(
+                        "io_open requires path (string) and mode (string) arguments".to_string(),
+                    ))
+                }
+            };
+            let overwrite = match arg_values.get(2) {
+                None => false,
+                Some(Value::Bool(flag)) => *flag,
+                Some(_) => {
+                    return Some(Value::Error(
+                        "io_open optional overwrite flag must be a bool".to_string(),
+                    ))
+                }
+            };
+            if overwrite && mode.as_str() != "w" {
+                return Some(Value::Error(
+                    "io_open overwrite flag is only valid with mode \"w\"".to_string(),
+                ));
+            }
+
+            let mut options = OpenOptions::new();
+            match mode.as_str() {
+                "r" => {
+                    options.read(true);
+                }
+                "w" | "a" => {
+                    if let Err(error) =
+                        interp.require_capability(NativeCapability::FilesystemWrite, "io_open")
+                    {
+                        return Some(error);
+                    }
+                    if mode.as_str() == "w" {
+                        // Match write_file: replacing an existing file must be explicit.
+                        if overwrite {
+                            options.write(true).create(true).truncate(true);
+                        } else {
+                            options.write(true).create_new(true);
+                        }
+                    } else {
+                        options.append(true).create(true);
+                    }
+                }
+                other => {
+                    return Some(Value::Error(format!(
+                        "io_open mode must be \"r\", \"w\", or \"a\", got \"{}\"",
+                        other
+                    )))
+                }
+            }
+
+            match options.open(path.as_ref()) {
+                Ok(file) => Value::FileHandle {
+                    file: Arc::new(Mutex::new(Some(BufReader::new(file)))),
+                    path: path.as_ref().clone(),
+                    mode: mode.as_ref().clone(),
+                },
+                Err(error) if error.kind() == ErrorKind::AlreadyExists => Value::Error(format!(
+                    "Cannot open file '{}' for writing: file already exists (pass overwrite=true to replace it)",
+                    path.as_ref()
+                )),
+                Err(error) => {
+                    Value::Error(format!("Cannot open file '{}': {}", path.as_ref(), error))
+                }
+            }
+        }
+
         _ => return None,
     };
     Some(result)
 }
 
+/// Dispatch `.read()`, `.read_line()`, `.write(content)`, and `.close()` on a `FileHandle`.
+/// Returns None when `obj` is not a file handle so callers can fall through.
+pub(crate) fn call_file_handle_method(obj: &Value, method: &str, args: &[Value]) -> Option<Value> {
+    let (file, path, mode) = match obj {
+        Value::FileHandle { file, path, mode } => (file, path, mode),
+        _ => return None,
+    };
+
+    let expected_args = if method == "write" { 1 } else { 0 };
+    if matches!(method, "read" | "read_line" | "write" | "close") && args.len() != expected_args {
+        return Some(Value::Error(format!(
+            "FileHandle.{}() expects {} argument{}, got {}",
+            method,
+            expected_args,
+            if expected_args == 1 { "" } else { "s" },
+            args.len()
+        )));
+    }
+
+    let mut guard = file.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+    if method == "close" {
+        // Closing twice is a no-op so cleanup paths can close unconditionally.
+        *guard = None;
+        return Some(Value::Null);
+    }
+
+    let reader = match guard.as_mut() {
+        Some(reader) => reader,
+        None if matches!(method, "read" | "read_line" | "write") => {
+            return Some(Value::Error(format!(
+                "Cannot {} file '{}': file handle is closed",
+                method, path
+            )))
+        }
+        None => return Some(Value::Error(format!("FileHandle has no method '{}'", method))),
+    };
+
+    let result = match method {
+        "read" | "read_line" if mode != "r" => Value::Error(format!(
+            "Cannot read file '{}': handle was opened with mode \"{}\"",
+            path, mode
+        )),
+        "read" => {
+            let mut content = String::new();
+            let limit = runtime_limits::MAX_FILE_IO_BYTES as u64;
+            match reader.by_ref().take(limit + 1).read_to_string(&mut content) {
+                Ok(bytes_read) if bytes_read as u64 > limit => Value::Error(format!(
+                    "Cannot read file '{}': exceeds maximum read size ({} bytes); use read_line() to stream it",
+                    path, limit
+                )),
+                Ok(_) => Value::Str(Arc::new(content)),
+                Err(error) => Value::Error(format!("Cannot read file '{}': {}", path, error)),
+            }
+        }
+        "read_line" => {
+            let mut line = String::new();
+            match reader.read_line(&mut line) {
+                Ok(0) => Value::Null,
+                Ok(_) => {
+                    if line.ends_with('\n') {
+                        line.pop();
+                        if line.ends_with('\r') {
+                            line.pop();
+                        }
+                    }
+                    Value::Str(Arc::new(line))
+                }
+                Err(error) => Value::Error(format!("Cannot read file '{}': {}", path, error)),
+            }
+        }
+        "write" if mode == "r" => Value::Error(format!(
+            "Cannot write file '{}': handle was opened with mode \"r\"",
+            path
+        )),
+        "write" => match &args[0] {
+            Value::Str(content) if content.len() > runtime_limits::MAX_FILE_IO_BYTES => {
+                Value::Error(format!(
+                    "Cannot write file '{}': payload exceeds maximum write size ({} bytes > {} bytes)",
+                    path,
+                    content.len(),
+                    runtime_limits::MAX_FILE_IO_BYTES
+                ))
+            }
+            Value::Str(content) => match reader.get_mut().write_all(content.as_bytes()) {
+                Ok(_) => Value::Int(content.len() as i64),
+                Err(error) => Value::Error(format!("Cannot write file '{}': {}", path, error)),
+            },
+            _ => Value::Error("FileHandle.write() requires a string argument".to_string()),
+        },
+        _ => Value::Error(format!("FileHandle has no method '{}'", method)),
+    };
+    Some(result)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -572,6 +756,58 @@ mod tests {
         let _ = std::fs::remove_file(path);
     }
 
+    #[test]
+    fn test_io_open_handle_streams_lines_and_enforces_modes() {
+        let mut interpreter = Interpreter::new();
+        let path = tmp_test_path("io_open_handle.txt");
+        let path_value = Value::Str(Arc::new(path.clone()));
+        let _ = std::fs::remove_file(&path);
+
+        let writer = handle(
+            &mut interpreter,
+            "io_open",
+            &[path_value.clone(), Value::Str(Arc::new("w".into()))],
+        )
+        .unwrap();
+        let written =
+            call_file_handle_method(&writer, "write", &[Value::Str(Arc::new("a\r\nb".into()))]);
+        assert!(matches!(written, Some(Value::Int(4))));
+        let read_on_writer = call_file_handle_method(&writer, "read", &[]);
+        assert!(
+            matches!(read_on_writer, Some(Value::Error(ref message)) if message.contains("opened with mode \"w\""))
+        );
+        assert!(matches!(call_file_handle_method(&writer, "close", &[]), Some(Value::Null)));
+
+        let reopen = handle(
+            &mut interpreter,
+            "io_open",
+            &[path_value.clone(), Value::Str(Arc::new("w".into()))],
+        )
+        .unwrap();
+        assert!(
+            matches!(reopen, Value::Error(ref message) if message.contains("file already exists"))
+        );
+
+        let reader =
+            handle(&mut interpreter, "io_open", &[path_value, Value::Str(Arc::new("r".into()))])
+                .unwrap();
+        assert!(
+            matches!(call_file_handle_method(&reader, "read_line", &[]), Some(Value::Str(ref line)) if line.as_str() == "a")
+        );
+        assert!(
+            matches!(call_file_handle_method(&reader, "read_line", &[]), Some(Value::Str(ref line)) if line.as_str() == "b")
+        );
+        assert!(matches!(call_file_handle_method(&reader, "read_line", &[]), Some(Value::Null)));
+        call_file_handle_method(&reader, "close", &[]);
+        let after_close = call_file_handle_method(&reader, "read", &[]);
+        assert!(
+            matches!(after_close, Some(Value::Error(ref message)) if message.contains("file handle is closed"))
+        );
+        assert!(call_file_handle_method(&Value::Null, "read", &[]).is_none());
+
+        let _ = std::fs::remove_file(path);
+    }
+
     #[test]
     fn test_io_copy_range() {
         let mut interpreter = Interpreter::new();
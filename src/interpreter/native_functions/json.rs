@@ -6,8 +6,38 @@ use crate::builtins;
 use crate::interpreter::Value;
 use std::sync::Arc;
 
+/// Indent string for `json.stringify`: a number of spaces (0 for compact output) or the
+/// indent text itself.
+fn json_stringify_indent(value: Option<&Value>) -> Result<String, String> {
+    match value {
+        None | Some(Value::Null) => Ok(String::new()),
+        Some(Value::Int(spaces)) if (0..=16).contains(spaces) => Ok(" ".repeat(*spaces as usize)),
+        Some(Value::Str(indent)) => Ok(indent.to_string()),
+        _ => Err("json.stringify() indent must be 0-16 spaces or an indent string".to_string()),
+    }
+}
+
 pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
     let result = match name {
+        "json.parse" => match arg_values.first() {
+            Some(Value::Str(text)) => {
+                builtins::parse_json(text.as_ref()).unwrap_or_else(Value::Error)
+            }
+            _ => Value::Error("json.parse() requires a JSON string".to_string()),
+        },
+
+        "json.stringify" => {
+            let indent = match json_stringify_indent(arg_values.get(1)) {
+                Ok(indent) => indent,
+                Err(message) => return Some(Value::Error(message)),
+            };
+            match arg_values.first().map(|value| builtins::to_json_indented(value, &indent)) {
+                Some(Ok(json_str)) => Value::Str(Arc::new(json_str)),
+                Some(Err(error)) => Value::Error(format!("json.stringify() failed: {}", error)),
+                None => Value::Error("json.stringify() requires a value argument".to_string()),
+            }
+        }
+
         "parse_json" => {
             if arg_values.len() != 1 {
                 return Some(Value::Error("parse_json requires a string argument".to_string()));
@@ -183,7 +213,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
 #[cfg(test)]
 mod tests {
     use super::handle;
-    use crate::interpreter::{DictMap, Value};
+    use crate::interpreter::{DictMap, OrderedDictMap, Value};
     use std::sync::Arc;
 
     fn string_value(value: &str) -> Value {
@@ -223,6 +253,46 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_to_json_keeps_ordmap_insertion_order() {
+        let mut nested = DictMap::default();
+        nested.insert(Arc::<str>::from("z"), Value::Int(1));
+        nested.insert(Arc::<str>::from("a"), Value::Int(2));
+        let mut map = OrderedDictMap::default();
+        map.insert(Arc::<str>::from("zeta"), Value::Dict(Arc::new(nested)));
+        map.insert(Arc::<str>::from("alpha"), Value::Array(Arc::new(vec![Value::Null])));
+
+        let json = handle("to_json", &[Value::OrderedDict(Arc::new(map))]).unwrap();
+        assert!(
+            matches!(&json, Value::Str(json) if json.as_str() == "{\"zeta\":{\"a\":2,\"z\":1},\"alpha\":[null]}"),
+            "ordmaps keep insertion order and dicts stay sorted: {:?}",
+            json
+        );
+    }
+
+    #[test]
+    fn test_json_namespace_parse_and_stringify_contracts() {
+        let parsed = handle("json.parse", &[string_value("[1, {\"a\": null}]")]).unwrap();
+        assert!(matches!(&parsed, Value::Array(items) if items.len() == 2));
+
+        let compact = handle("json.stringify", &[parsed.clone()]).unwrap();
+        assert!(matches!(compact, Value::Str(json) if json.as_str() == "[1,{\"a\":null}]"));
+        let tabbed = handle("json.stringify", &[parsed.clone(), string_value("\t")]).unwrap();
+        assert!(matches!(tabbed, Value::Str(json) if json.contains("\n\t{\n\t\t\"a\": null")));
+        let zero = handle("json.stringify", &[parsed.clone(), Value::Int(0)]).unwrap();
+        assert!(matches!(zero, Value::Str(json) if !json.contains('\n')));
+
+        let malformed = handle("json.parse", &[string_value("{\"a\": }")]).unwrap();
+        assert!(matches!(malformed, Value::Error(message) if message.contains("line 1 column")));
+        let bad_indent = handle("json.stringify", &[parsed, Value::Int(-1)]).unwrap();
+        assert!(matches!(bad_indent, Value::Error(message) if message.contains("indent must be")));
+        let unencodable =
+            handle("json.stringify", &[Value::NativeFunction("print".to_string())]).unwrap();
+        assert!(
+            matches!(unencodable, Value::Error(message) if message.starts_with("json.stringify() failed"))
+        );
+    }
+
     #[test]
     fn test_parse_toml_and_to_toml() {
         let parse_result = handle("parse_toml", &[string_value("title = \"Ruff\"")]).unwrap();
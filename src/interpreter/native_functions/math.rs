@@ -8,6 +8,7 @@ use crate::interpreter::Value;
 fn number_arg(name: &str, arg_name: &str, value: &Value) -> Result<f64, Value> {
     match value {
         Value::Int(n) => Ok(*n as f64),
+        Value::BigInt(n) => Ok(n.to_f64()),
         Value::Float(n) => Ok(*n),
         _ => Err(Value::Error(format!(
             "{}() expects numeric argument '{}' , got {:?}",
@@ -5,6 +5,7 @@
 // call_native_function_impl into manageable category-based modules.
 
 pub mod async_ops;
+pub mod cli;
 pub mod collections;
 pub mod concurrency;
 pub mod crypto;
@@ -12,26 +13,42 @@ pub mod crypto;
 pub mod database;
 #[cfg(not(feature = "runtime-db"))]
 pub mod database {
-    use super::super::Value;
+    use super::super::{SequenceHost, Value};
 
     pub fn handle(name: &str, _arg_values: &[Value]) -> Option<Value> {
         match name {
             "db_connect" | "db_execute" | "db_query" | "db_close" | "db_pool"
             | "db_pool_acquire" | "db_pool_release" | "db_pool_stats" | "db_pool_close"
-            | "db_begin" | "db_commit" | "db_rollback" | "db_last_insert_id" => Some(Value::Error(
-                "Database native APIs are disabled in this build (enable the 'runtime-db' feature)"
-                    .to_string(),
-            )),
+            | "db_begin" | "db_commit" | "db_rollback" | "db_last_insert_id" | "sqlite.open" => {
+                Some(Value::Error(
+                    "Database native APIs are disabled in this build (enable the 'runtime-db' feature)"
+                        .to_string(),
+                ))
+            }
             _ => None,
         }
     }
+
+    /// No `Database` values exist without the feature, so there is nothing to dispatch.
+    pub(crate) fn call_database_method(
+        _host: &mut dyn SequenceHost,
+        _obj: &Value,
+        _method: &str,
+        _args: &[Value],
+    ) -> Option<Value> {
+        None
+    }
 }
+pub mod decorators;
+pub mod ffi;
 pub mod filesystem;
 pub mod http;
 pub mod io;
 pub mod json;
 pub mod math;
+pub mod net;
 pub mod network;
+pub mod process;
 pub mod strings;
 pub mod system;
 pub mod type_ops;
@@ -61,20 +78,6 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
                     Value::Array(std::sync::Arc::new(values))
                 }
                 Value::Array(arr) => Value::Array(arr.clone()),
-                Value::Dict(dict) => {
-                    let keys: Vec<Value> = dict
-                        .keys()
-                        .map(|key| Value::Str(std::sync::Arc::new(key.to_string())))
-                        .collect();
-                    Value::Array(std::sync::Arc::new(keys))
-                }
-                Value::FixedDict { keys, .. } => {
-                    let key_values: Vec<Value> = keys
-                        .iter()
-                        .map(|key| Value::Str(std::sync::Arc::new(key.to_string())))
-                        .collect();
-                    Value::Array(std::sync::Arc::new(key_values))
-                }
                 Value::Str(text) => {
                     let chars: Vec<Value> = text
                         .chars()
@@ -82,7 +85,11 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
                         .collect();
                     Value::Array(std::sync::Arc::new(chars))
                 }
-                other => other.clone(),
+                // Every dict kind yields keys, in the same order as `keys()` and the interpreter's `for`
+                other => match other.dict_keys_in_order() {
+                    Some(keys) => Value::Array(std::sync::Arc::new(keys)),
+                    None => other.clone(),
+                },
             };
         }
         "dict" => {
@@ -107,8 +114,15 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
                 Value::Str(text) => text.as_ref().clone(),
                 other => format!("{:?}", other),
             };
-
-            return Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None };
+            let payload = arg_values.get(1).cloned().map(Box::new);
+
+            return Value::ErrorObject {
+                message,
+                stack: Vec::new(),
+                line: None,
+                cause: None,
+                payload,
+            };
         }
         "collect" => {
             if let Some(arity) = Interpreter::native_callable_arity("collect") {
@@ -123,6 +137,66 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
                 _ => Value::Error("collect() expects an iterator or array".to_string()),
             };
         }
+        "implements" => {
+            if let Some(arity) = Interpreter::native_callable_arity("implements") {
+                if let Err(message) = arity.validate(arg_values.len()) {
+                    return Value::Error(message);
+                }
+            }
+
+            // Instances resolve to their struct definition in the current scope
+            let struct_def = match &arg_values[0] {
+                Value::StructDef { .. } => Some(arg_values[0].clone()),
+                Value::Struct { name, .. } => interp.env.get(name),
+                _ => None,
+            };
+            return match Value::satisfies_interface(struct_def.as_ref(), &arg_values[1]) {
+                Ok(result) => Value::Bool(result),
+                Err(message) => Value::Error(message),
+            };
+        }
+        "assert_raises" => {
+            if let Some(arity) = Interpreter::native_callable_arity("assert_raises") {
+                if let Err(message) = arity.validate(arg_values.len()) {
+                    return Value::Error(message);
+                }
+            }
+
+            if !matches!(arg_values[0], Value::Function(..) | Value::NativeFunction(_)) {
+                return Value::Error(
+                    "assert_raises expects a function as its first argument".to_string(),
+                );
+            }
+            let raised = match interp.call_user_function(&arg_values[0], &[]) {
+                Value::Error(message) | Value::ErrorObject { message, .. } => Some(message),
+                _ => None,
+            };
+            // The expected error is the assertion's subject, so it must not keep unwinding
+            if raised.is_some() {
+                interp.return_value = None;
+            }
+            return Interpreter::assert_raises_outcome(raised, arg_values.get(1));
+        }
+        "bench" => {
+            if let Some(arity) = Interpreter::native_callable_arity("bench") {
+                if let Err(message) = arity.validate(arg_values.len()) {
+                    return Value::Error(message);
+                }
+            }
+
+            if !matches!(arg_values[1], Value::Function(..) | Value::NativeFunction(_)) {
+                return Value::Error("bench expects a function as its second argument".to_string());
+            }
+            return Interpreter::run_bench(arg_values, |func| {
+                match interp.call_user_function(func, &[]) {
+                    Value::Error(message) | Value::ErrorObject { message, .. } => {
+                        interp.return_value = None;
+                        Err(message)
+                    }
+                    _ => Ok(()),
+                }
+            });
+        }
         _ => {}
     }
 
@@ -140,6 +214,11 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
         }
     }
 
+    // `http.serve` re-enters the interpreter for every request, so it cannot live in `http::handle`.
+    if canonical_name == "http.serve" {
+        return interp.serve_http_module(arg_values);
+    }
+
     // Try async operations first (high priority for async functions)
     if let Some(result) = async_ops::handle(interp, canonical_name, arg_values) {
         return result;
@@ -178,12 +257,27 @@ pub fn call_native_function(interp: &mut Interpreter, name: &str, arg_values: &[
     if let Some(result) = concurrency::handle(interp, canonical_name, arg_values) {
         return result;
     }
+    if let Some(result) = decorators::handle(canonical_name, arg_values) {
+        return result;
+    }
     if let Some(result) = database::handle(canonical_name, arg_values) {
         return result;
     }
     if let Some(result) = network::handle(interp, canonical_name, arg_values) {
         return result;
     }
+    if let Some(result) = ffi::handle(canonical_name, arg_values) {
+        return result;
+    }
+    if let Some(result) = process::handle(canonical_name, arg_values) {
+        return result;
+    }
+    if let Some(result) = net::handle(canonical_name, arg_values) {
+        return result;
+    }
+    if let Some(result) = cli::handle(interp, canonical_name, arg_values) {
+        return result;
+    }
 
     // Unknown function
     Value::Error(format!("Unknown native function: {}", name))
@@ -325,6 +419,7 @@ mod tests {
             "take",
             "skip",
             "windows",
+            "next",
             "starts_with",
             "ends_with",
             "repeat",
@@ -378,6 +473,7 @@ mod tests {
             "io_file_metadata",
             "io_truncate",
             "io_copy_range",
+            "io_open",
             "http_get",
             "http_request",
             "http_post",
@@ -500,6 +596,15 @@ mod tests {
             "elapsed",
             "format_date",
             "parse_date",
+            "time_now",
+            "time_monotonic",
+            "time_format",
+            "time_parse",
+            "time_parts",
+            "time_add_days",
+            "time_add_months",
+            "time_diff",
+            "time_duration",
             "abs",
             "sqrt",
             "pow",
@@ -514,6 +619,7 @@ mod tests {
             "log",
             "exp",
             "range",
+            "ordmap",
             "keys",
             "values",
             "items",
@@ -523,7 +629,10 @@ mod tests {
             "invert",
             "update",
             "get_default",
+            "get_path",
+            "set_path",
             "format",
+            "print_f",
             "parse_json",
             "to_json",
             "to_json_pretty",
@@ -2463,7 +2572,11 @@ mod tests {
                 vec![base_array.clone(), empty_function.clone(), Value::Int(1)],
                 "expects 2 arguments",
             ),
-            ("sort", vec![base_array.clone(), Value::Int(1)], "expects 1 argument"),
+            (
+                "sort",
+                vec![base_array.clone(), Value::Int(1), Value::Int(1)],
+                "expects 1 or 2 arguments",
+            ),
             ("reverse", vec![base_array.clone(), Value::Int(1)], "expects 1 argument"),
             ("unique", vec![base_array.clone(), Value::Int(1)], "expects 1 argument"),
             ("sum", vec![base_array.clone(), Value::Int(1)], "expects 1 argument"),
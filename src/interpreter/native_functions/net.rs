@@ -0,0 +1,809 @@
+// File: src/interpreter/native_functions/net.rs
+//
+// `net` namespace: TCP and UDP sockets as handles with methods.
+//
+// `net.dial()` opens a connection and `net.listen()` a TCP listener or a bound UDP socket.
+// Connections buffer their input, so `read_line()`, `read_exact()`, and `read()` can be
+// mixed freely when speaking a line-based protocol. Reads and writes use the network
+// policy timeouts until a deadline is set; deadlines are absolute Unix timestamps in
+// seconds, the same clock as `time.now()`.
+
+use super::network::timeout_aware_error_message;
+use super::system::{dict_entries, error_object, value_type_name};
+use crate::interpreter::{DictMap, Interpreter, Value};
+use crate::network_policy;
+use crate::runtime_limits;
+use std::io::{self, BufRead, BufReader, ErrorKind, Read, Write};
+use std::net::{Shutdown, SocketAddr, TcpListener, TcpStream, ToSocketAddrs, UdpSocket};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex, MutexGuard};
+use std::thread;
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// Bytes `read()` and `read_from()` return when no size is given.
+const DEFAULT_READ_SIZE: usize = 4096;
+
+/// Input buffer per connection; large enough for any UDP datagram.
+const READ_BUFFER_BYTES: usize = 64 * 1024;
+
+/// How often a listener polls for connections, so `close()` and deadlines take effect.
+const ACCEPT_POLL_INTERVAL_MS: u64 = 10;
+
+/// The socket under a connection; TCP streams and connected UDP sockets read and write alike.
+enum Transport {
+    Tcp(TcpStream),
+    Udp(UdpSocket),
+}
+
+impl Transport {
+    fn try_clone(&self) -> io::Result<Self> {
+        match self {
+            Transport::Tcp(stream) => stream.try_clone().map(Transport::Tcp),
+            Transport::Udp(socket) => socket.try_clone().map(Transport::Udp),
+        }
+    }
+
+    fn set_read_timeout(&self, timeout: Duration) -> io::Result<()> {
+        match self {
+            Transport::Tcp(stream) => stream.set_read_timeout(Some(timeout)),
+            Transport::Udp(socket) => socket.set_read_timeout(Some(timeout)),
+        }
+    }
+
+    fn set_write_timeout(&self, timeout: Duration) -> io::Result<()> {
+        match self {
+            Transport::Tcp(stream) => stream.set_write_timeout(Some(timeout)),
+            Transport::Udp(socket) => socket.set_write_timeout(Some(timeout)),
+        }
+    }
+}
+
+impl Read for Transport {
+    fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+        match self {
+            Transport::Tcp(stream) => stream.read(buf),
+            Transport::Udp(socket) => socket.recv(buf),
+        }
+    }
+}
+
+impl Write for Transport {
+    fn write(&mut self, buf: &[u8]) -> io::Result<usize> {
+        match self {
+            Transport::Tcp(stream) => stream.write(buf),
+            Transport::Udp(socket) => socket.send(buf),
+        }
+    }
+
+    fn flush(&mut self) -> io::Result<()> {
+        match self {
+            Transport::Tcp(stream) => stream.flush(),
+            Transport::Udp(_) => Ok(()),
+        }
+    }
+}
+
+enum SocketKind {
+    Conn {
+        reader: Mutex<BufReader<Transport>>,
+        writer: Mutex<Transport>,
+        /// A clone used only to shut the stream down while another call is blocked on it.
+        control: Option<TcpStream>,
+        line_ending: String,
+    },
+    Listener {
+        listener: Mutex<Option<TcpListener>>,
+        line_ending: String,
+    },
+    Packet {
+        socket: UdpSocket,
+    },
+}
+
+#[derive(Default, Clone, Copy)]
+struct Deadlines {
+    read: Option<SystemTime>,
+    write: Option<SystemTime>,
+}
+
+/// A socket opened by `net.dial()`, `net.listen()`, or `Listener.accept()`.
+pub struct NetSocket {
+    label: String,
+    local_addr: String,
+    remote_addr: Option<String>,
+    kind: SocketKind,
+    closed: AtomicBool,
+    deadlines: Mutex<Deadlines>,
+}
+
+fn lock<T>(mutex: &Mutex<T>) -> MutexGuard<'_, T> {
+    mutex.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+}
+
+/// Time left before `deadline`, or the policy `default` when no deadline is set; `None`
+/// once the deadline has passed.
+fn time_left(deadline: Option<SystemTime>, default: Duration) -> Option<Duration> {
+    match deadline {
+        None => Some(default),
+        Some(deadline) => {
+            deadline.duration_since(SystemTime::now()).ok().filter(|left| !left.is_zero())
+        }
+    }
+}
+
+fn deadline_exceeded(operation: &str) -> Value {
+    error_object(format!("{} timed out: deadline exceeded", operation))
+}
+
+fn io_failure(operation: &str, deadline: Option<SystemTime>, error: &io::Error) -> Value {
+    let timed_out = matches!(error.kind(), ErrorKind::TimedOut | ErrorKind::WouldBlock);
+    if timed_out && deadline.is_some() {
+        deadline_exceeded(operation)
+    } else {
+        error_object(timeout_aware_error_message(operation, error))
+    }
+}
+
+fn bytes_value(bytes: Vec<u8>) -> Value {
+    match String::from_utf8(bytes) {
+        Ok(text) => Value::Str(Arc::new(text)),
+        Err(error) => Value::Bytes(error.into_bytes()),
+    }
+}
+
+fn payload<'a>(operation: &str, value: &'a Value) -> Result<&'a [u8], Value> {
+    match value {
+        Value::Str(text) => Ok(text.as_bytes()),
+        Value::Bytes(bytes) => Ok(bytes),
+        other => Err(Value::Error(format!(
+            "{} expects string or bytes data, got {}",
+            operation,
+            value_type_name(other)
+        ))),
+    }
+}
+
+fn read_size(operation: &str, value: Option<&Value>) -> Result<usize, Value> {
+    match value {
+        None => Ok(DEFAULT_READ_SIZE),
+        Some(Value::Int(size)) => {
+            network_policy::validate_receive_size(*size, operation).map_err(Value::Error)
+        }
+        Some(other) => Err(Value::Error(format!(
+            "{} expects an int size, got {}",
+            operation,
+            value_type_name(other)
+        ))),
+    }
+}
+
+fn parse_deadline(operation: &str, value: &Value) -> Result<Option<SystemTime>, Value> {
+    let seconds = match value {
+        Value::Null => return Ok(None),
+        Value::Int(seconds) => *seconds as f64,
+        Value::Float(seconds) if seconds.is_finite() => *seconds,
+        other => {
+            return Err(Value::Error(format!(
+                "{} expects a Unix timestamp in seconds or null, got {}",
+                operation,
+                value_type_name(other)
+            )))
+        }
+    };
+    Duration::try_from_secs_f64(seconds.max(0.0))
+        .ok()
+        .and_then(|offset| UNIX_EPOCH.checked_add(offset))
+        .map(Some)
+        .ok_or_else(|| Value::Error(format!("{} got an out-of-range deadline", operation)))
+}
+
+fn string_argument(value: Option<&Value>) -> Option<&str> {
+    match value {
+        Some(Value::Str(text)) => Some(text.as_str()),
+        _ => None,
+    }
+}
+
+/// Splits `host:port`, accepting bracketed IPv6 hosts such as `[::1]:80`.
+fn split_host_port(addr: &str) -> Option<(&str, i64)> {
+    let (host, port) = addr.rsplit_once(':')?;
+    let host = host.strip_prefix('[').and_then(|host| host.strip_suffix(']')).unwrap_or(host);
+    let port = port.parse::<u16>().ok()?;
+    Some((host, i64::from(port)))
+}
+
+fn connection(network: &str, transport: Transport, line_ending: String) -> io::Result<NetSocket> {
+    let (local, remote, control) = match &transport {
+        Transport::Tcp(stream) => {
+            (stream.local_addr()?, stream.peer_addr()?, Some(stream.try_clone()?))
+        }
+        Transport::Udp(socket) => (socket.local_addr()?, socket.peer_addr()?, None),
+    };
+    let writer = transport.try_clone()?;
+    Ok(NetSocket {
+        label: format!("{} {}", network, remote),
+        local_addr: local.to_string(),
+        remote_addr: Some(remote.to_string()),
+        kind: SocketKind::Conn {
+            reader: Mutex::new(BufReader::with_capacity(READ_BUFFER_BYTES, transport)),
+            writer: Mutex::new(writer),
+            control,
+            line_ending,
+        },
+        closed: AtomicBool::new(false),
+        deadlines: Mutex::new(Deadlines::default()),
+    })
+}
+
+impl NetSocket {
+    pub fn label(&self) -> &str {
+        &self.label
+    }
+
+    /// The name `type()` reports for this socket.
+    pub fn type_name(&self) -> &'static str {
+        match self.kind {
+            SocketKind::Conn { .. } => "net_conn",
+            SocketKind::Listener { .. } => "net_listener",
+            SocketKind::Packet { .. } => "net_packet_conn",
+        }
+    }
+
+    fn class(&self) -> &'static str {
+        match self.kind {
+            SocketKind::Conn { .. } => "Conn",
+            SocketKind::Listener { .. } => "Listener",
+            SocketKind::Packet { .. } => "PacketConn",
+        }
+    }
+
+    fn operation(&self, method: &str) -> String {
+        format!("{}.{}()", self.class(), method)
+    }
+
+    fn ensure_open(&self, operation: &str) -> Result<(), Value> {
+        if self.closed.load(Ordering::SeqCst) {
+            return Err(error_object(format!("{} failed: socket is closed", operation)));
+        }
+        Ok(())
+    }
+
+    fn read_deadline(&self) -> Option<SystemTime> {
+        lock(&self.deadlines).read
+    }
+
+    fn write_deadline(&self) -> Option<SystemTime> {
+        lock(&self.deadlines).write
+    }
+
+    /// Refreshes the read timeout before a blocking read so the deadline bounds the whole
+    /// call, not each system call within it.
+    fn arm_read(
+        &self,
+        reader: &BufReader<Transport>,
+        deadline: Option<SystemTime>,
+        operation: &str,
+    ) -> Result<(), Value> {
+        self.ensure_open(operation)?;
+        let Some(left) = time_left(deadline, network_policy::read_timeout()) else {
+            return Err(deadline_exceeded(operation));
+        };
+        reader.get_ref().set_read_timeout(left).map_err(|error| io_failure(operation, None, &error))
+    }
+
+    /// Runs `read` against the connection's buffered input.
+    fn with_reader(
+        &self,
+        method: &str,
+        read: impl FnOnce(
+            &Self,
+            &mut BufReader<Transport>,
+            Option<SystemTime>,
+            &str,
+        ) -> Result<Value, Value>,
+    ) -> Value {
+        let SocketKind::Conn { reader, .. } = &self.kind else {
+            unreachable!("only connections read");
+        };
+        let operation = self.operation(method);
+        if let Err(error) = self.ensure_open(&operation) {
+            return error;
+        }
+        let deadline = self.read_deadline();
+        let mut reader = lock(reader);
+        read(self, &mut reader, deadline, &operation).unwrap_or_else(|error| error)
+    }
+
+    /// Returns buffered input, or waits for more; `None` at end of stream.
+    fn fill<'r>(
+        &self,
+        reader: &'r mut BufReader<Transport>,
+        deadline: Option<SystemTime>,
+        operation: &str,
+    ) -> Result<&'r [u8], Value> {
+        if reader.buffer().is_empty() {
+            self.arm_read(reader, deadline, operation)?;
+        }
+        reader.fill_buf().map_err(|error| io_failure(operation, deadline, &error))
+    }
+
+    fn read(&self, args: &[Value]) -> Value {
+        self.with_reader("read", |socket, reader, deadline, operation| {
+            let size = read_size(operation, args.first())?;
+            let available = socket.fill(reader, deadline, operation)?;
+            if available.is_empty() {
+                return Ok(Value::Null);
+            }
+            let data = available[..available.len().min(size)].to_vec();
+            reader.consume(data.len());
+            Ok(bytes_value(data))
+        })
+    }
+
+    fn read_line(&self) -> Value {
+        self.with_reader("read_line", |socket, reader, deadline, operation| {
+            let mut line = Vec::new();
+            loop {
+                let available = socket.fill(reader, deadline, operation)?;
+                if available.is_empty() {
+                    // End of stream: a final unterminated line is still a line
+                    if line.is_empty() {
+                        return Ok(Value::Null);
+                    }
+                    break;
+                }
+                let newline = available.iter().position(|byte| *byte == b'\n');
+                let used = newline.map_or(available.len(), |index| index + 1);
+                line.extend_from_slice(&available[..newline.unwrap_or(used)]);
+                reader.consume(used);
+                if newline.is_some() {
+                    break;
+                }
+                if line.len() > runtime_limits::MAX_NETWORK_BODY_BYTES {
+                    return Err(error_object(format!(
+                        "{} failed: line exceeds {} bytes",
+                        operation,
+                        runtime_limits::MAX_NETWORK_BODY_BYTES
+                    )));
+                }
+            }
+            if line.last() == Some(&b'\r') {
+                line.pop();
+            }
+            Ok(bytes_value(line))
+        })
+    }
+
+    fn read_exact(&self, args: &[Value]) -> Value {
+        self.with_reader("read_exact", |socket, reader, deadline, operation| {
+            let size = read_size(operation, args.first())?;
+            let mut data = Vec::with_capacity(size);
+            while data.len() < size {
+                let available = socket.fill(reader, deadline, operation)?;
+                if available.is_empty() {
+                    if data.is_empty() {
+                        return Ok(Value::Null);
+                    }
+                    return Err(error_object(format!(
+                        "{} failed: connection closed after {} of {} bytes",
+                        operation,
+                        data.len(),
+                        size
+                    )));
+                }
+                let take = available.len().min(size - data.len());
+                data.extend_from_slice(&available[..take]);
+                reader.consume(take);
+            }
+            Ok(bytes_value(data))
+        })
+    }
+
+    fn write_all(&self, method: &str, parts: &[&[u8]]) -> Value {
+        let SocketKind::Conn { writer, .. } = &self.kind else {
+            unreachable!("only connections write");
+        };
+        let operation = self.operation(method);
+        if let Err(error) = self.ensure_open(&operation) {
+            return error;
+        }
+        let deadline = self.write_deadline();
+        let Some(left) = time_left(deadline, network_policy::write_timeout()) else {
+            return deadline_exceeded(&operation);
+        };
+        let mut writer = lock(writer);
+        let written = writer.set_write_timeout(left).and_then(|_| {
+            // One buffer per call keeps a UDP line in a single datagram
+            let data = parts.concat();
+            writer.write_all(&data)?;
+            writer.flush()?;
+            Ok(data.len())
+        });
+        match written {
+            Ok(count) => Value::Int(count as i64),
+            Err(error) => io_failure(&operation, deadline, &error),
+        }
+    }
+
+    fn accept(&self) -> Value {
+        let SocketKind::Listener { listener, line_ending } = &self.kind else {
+            unreachable!("only listeners accept");
+        };
+        let operation = self.operation("accept");
+        let deadline = self.read_deadline();
+        loop {
+            if let Err(error) = self.ensure_open(&operation) {
+                return error;
+            }
+            let accepted = match lock(listener).as_ref() {
+                Some(listener) => listener.accept(),
+                None => return error_object(format!("{} failed: socket is closed", operation)),
+            };
+            match accepted {
+                Ok((stream, _)) => {
+                    let prepared =
+                        stream.set_nonblocking(false).map_err(|error| error.to_string()).and_then(
+                            |_| network_policy::apply_tcp_stream_timeouts(&stream, &operation),
+                        );
+                    if let Err(error) = prepared {
+                        return error_object(error);
+                    }
+                    return match connection("tcp", Transport::Tcp(stream), line_ending.clone()) {
+                        Ok(conn) => Value::Socket(Arc::new(conn)),
+                        Err(error) => io_failure(&operation, None, &error),
+                    };
+                }
+                Err(error) if error.kind() == ErrorKind::WouldBlock => {}
+                Err(error) => return io_failure(&operation, None, &error),
+            }
+            if deadline.is_some_and(|deadline| SystemTime::now() >= deadline) {
+                return deadline_exceeded(&operation);
+            }
+            thread::sleep(Duration::from_millis(ACCEPT_POLL_INTERVAL_MS));
+        }
+    }
+
+    fn read_from(&self, socket: &UdpSocket, args: &[Value]) -> Value {
+        let operation = self.operation("read_from");
+        let size = match read_size(&operation, args.first()) {
+            Ok(size) => size,
+            Err(error) => return error,
+        };
+        if let Err(error) = self.ensure_open(&operation) {
+            return error;
+        }
+        let deadline = self.read_deadline();
+        let Some(left) = time_left(deadline, network_policy::read_timeout()) else {
+            return deadline_exceeded(&operation);
+        };
+        let mut buffer = vec![0u8; size];
+        let received =
+            socket.set_read_timeout(Some(left)).and_then(|_| socket.recv_from(&mut buffer));
+        match received {
+            Ok((count, from)) => {
+                buffer.truncate(count);
+                let mut result = DictMap::default();
+                result.insert("data".into(), bytes_value(buffer));
+                result.insert("from".into(), Value::Str(Arc::new(from.to_string())));
+                result.insert("size".into(), Value::Int(count as i64));
+                Value::Dict(Arc::new(result))
+            }
+            Err(error) => io_failure(&operation, deadline, &error),
+        }
+    }
+
+    fn write_to(&self, socket: &UdpSocket, args: &[Value]) -> Value {
+        let operation = self.operation("write_to");
+        let data = match payload(&operation, &args[0]) {
+            Ok(data) => data,
+            Err(error) => return error,
+        };
+        let Some((host, port)) = string_argument(args.get(1)).and_then(split_host_port) else {
+            return Value::Error(format!("{} expects an address like \"host:port\"", operation));
+        };
+        if let Err(error) =
+            network_policy::enforce_host_port_destination_policy(host, port, &operation)
+        {
+            return error_object(error);
+        }
+        if let Err(error) = self.ensure_open(&operation) {
+            return error;
+        }
+        let deadline = self.write_deadline();
+        let Some(left) = time_left(deadline, network_policy::write_timeout()) else {
+            return deadline_exceeded(&operation);
+        };
+        let address = (host, port as u16);
+        match socket.set_write_timeout(Some(left)).and_then(|_| socket.send_to(data, address)) {
+            Ok(count) => Value::Int(count as i64),
+            Err(error) => io_failure(&operation, deadline, &error),
+        }
+    }
+
+    fn close(&self) -> Value {
+        if self.closed.swap(true, Ordering::SeqCst) {
+            return Value::Null;
+        }
+        match &self.kind {
+            // Shutting the stream down wakes any call blocked on it
+            SocketKind::Conn { control: Some(stream), .. } => {
+                let _ = stream.shutdown(Shutdown::Both);
+            }
+            SocketKind::Listener { listener, .. } => {
+                lock(listener).take();
+            }
+            SocketKind::Conn { .. } | SocketKind::Packet { .. } => {}
+        }
+        Value::Null
+    }
+
+    fn set_deadline(&self, method: &str, value: &Value) -> Value {
+        let deadline = match parse_deadline(&self.operation(method), value) {
+            Ok(deadline) => deadline,
+            Err(error) => return error,
+        };
+        let mut deadlines = lock(&self.deadlines);
+        match method {
+            "set_read_deadline" => deadlines.read = deadline,
+            "set_write_deadline" => deadlines.write = deadline,
+            _ => *deadlines = Deadlines { read: deadline, write: deadline },
+        }
+        Value::Null
+    }
+}
+
+/// Accepted argument counts for each method, by socket kind.
+fn method_arity(kind: &SocketKind, method: &str) -> Option<(usize, usize)> {
+    let arity = match (kind, method) {
+        (_, "close" | "local_addr") => (0, 0),
+        (_, "set_deadline") => (1, 1),
+        (SocketKind::Conn { .. } | SocketKind::Packet { .. }, "set_read_deadline")
+        | (SocketKind::Conn { .. } | SocketKind::Packet { .. }, "set_write_deadline") => (1, 1),
+        (SocketKind::Conn { .. }, "read") => (0, 1),
+        (SocketKind::Conn { .. }, "read_line" | "remote_addr") => (0, 0),
+        (SocketKind::Conn { .. }, "read_exact" | "write" | "write_line") => (1, 1),
+        (SocketKind::Listener { .. }, "accept") => (0, 0),
+        (SocketKind::Packet { .. }, "read_from") => (0, 1),
+        (SocketKind::Packet { .. }, "write_to") => (2, 2),
+        _ => return None,
+    };
+    Some(arity)
+}
+
+pub(crate) fn call_socket_method(obj: &Value, method: &str, args: &[Value]) -> Option<Value> {
+    let Value::Socket(socket) = obj else {
+        return None;
+    };
+    let Some((min, max)) = method_arity(&socket.kind, method) else {
+        return Some(Value::Error(format!("{} has no method '{}'", socket.class(), method)));
+    };
+    if args.len() < min || args.len() > max {
+        let expected = if min == max { min.to_string() } else { format!("{}-{}", min, max) };
+        return Some(Value::Error(format!(
+            "{} expects {} argument{}, got {}",
+            socket.operation(method),
+            expected,
+            if expected == "1" { "" } else { "s" },
+            args.len()
+        )));
+    }
+
+    let result = match (&socket.kind, method) {
+        (_, "close") => socket.close(),
+        (_, "local_addr") => Value::Str(Arc::new(socket.local_addr.clone())),
+        (_, "set_deadline" | "set_read_deadline" | "set_write_deadline") => {
+            socket.set_deadline(method, &args[0])
+        }
+        (SocketKind::Conn { .. }, "remote_addr") => {
+            Value::Str(Arc::new(socket.remote_addr.clone().unwrap_or_default()))
+        }
+        (SocketKind::Conn { .. }, "read") => socket.read(args),
+        (SocketKind::Conn { .. }, "read_line") => socket.read_line(),
+        (SocketKind::Conn { .. }, "read_exact") => socket.read_exact(args),
+        (SocketKind::Conn { .. }, "write") => match payload(&socket.operation(method), &args[0]) {
+            Ok(data) => socket.write_all(method, &[data]),
+            Err(error) => error,
+        },
+        (SocketKind::Conn { line_ending, .. }, "write_line") => {
+            match payload(&socket.operation(method), &args[0]) {
+                Ok(data) => socket.write_all(method, &[data, line_ending.as_bytes()]),
+                Err(error) => error,
+            }
+        }
+        (SocketKind::Listener { .. }, _) => socket.accept(),
+        (SocketKind::Packet { socket: udp }, "read_from") => socket.read_from(udp, args),
+        (SocketKind::Packet { socket: udp }, _) => socket.write_to(udp, args),
+        (SocketKind::Conn { .. }, _) => unreachable!("method_arity admits only known methods"),
+    };
+    Some(result)
+}
+
+struct SocketOptions {
+    timeout: Duration,
+    line_ending: String,
+}
+
+fn parse_options(function: &str, value: Option<&Value>) -> Result<SocketOptions, Value> {
+    let mut options =
+        SocketOptions { timeout: network_policy::connect_timeout(), line_ending: "\n".to_string() };
+    let Some(value) = value else {
+        return Ok(options);
+    };
+    let Some(entries) = dict_entries(value) else {
+        return Err(Value::Error(format!(
+            "{}() expects an options dict, got {}",
+            function,
+            value_type_name(value)
+        )));
+    };
+    for (key, value) in entries {
+        match (key.as_str(), &value) {
+            ("timeout", Value::Int(seconds)) if function == "net.dial" && *seconds > 0 => {
+                options.timeout = Duration::from_secs(*seconds as u64);
+            }
+            ("timeout", Value::Float(seconds))
+                if function == "net.dial" && seconds.is_finite() && *seconds > 0.0 =>
+            {
+                options.timeout = Duration::from_secs_f64(seconds.min(86_400.0));
+            }
+            ("timeout", _) if function == "net.dial" => {
+                return Err(Value::Error(format!(
+                    "{}() option 'timeout' must be a positive number of seconds",
+                    function
+                )))
+            }
+            ("line_ending", Value::Str(ending)) if !ending.is_empty() => {
+                options.line_ending = ending.as_ref().clone();
+            }
+            ("line_ending", _) => {
+                return Err(Value::Error(format!(
+                    "{}() option 'line_ending' must be a non-empty string",
+                    function
+                )))
+            }
+            (other, _) => {
+                let supported =
+                    if function == "net.dial" { "timeout, line_ending" } else { "line_ending" };
+                return Err(Value::Error(format!(
+                    "{}() got unknown option '{}' (supported: {})",
+                    function, other, supported
+                )));
+            }
+        }
+    }
+    Ok(options)
+}
+
+/// Reads the `(network, address, options?)` arguments shared by `net.dial` and `net.listen`.
+fn socket_arguments<'a>(
+    function: &str,
+    args: &'a [Value],
+) -> Result<(&'a str, &'a str, SocketOptions), Value> {
+    let network = match args.first() {
+        Some(Value::Str(network)) if matches!(network.as_str(), "tcp" | "udp") => network.as_str(),
+        Some(other) => {
+            return Err(Value::Error(format!(
+                "{}() expects network \"tcp\" or \"udp\", got {}",
+                function,
+                Interpreter::stringify_value(other)
+            )))
+        }
+        None => return Err(Value::Error(format!("{}() requires a network", function))),
+    };
+    let Some(address) = string_argument(args.get(1)) else {
+        return Err(Value::Error(format!("{}() requires an address string", function)));
+    };
+    Ok((network, address, parse_options(function, args.get(2))?))
+}
+
+fn dial(args: &[Value]) -> Value {
+    let (network, address, options) = match socket_arguments("net.dial", args) {
+        Ok(arguments) => arguments,
+        Err(error) => return error,
+    };
+    let Some((host, port)) = split_host_port(address) else {
+        return Value::Error(format!(
+            "net.dial() expects an address like \"host:port\", got '{}'",
+            address
+        ));
+    };
+    if let Err(error) = network_policy::enforce_host_port_destination_policy(host, port, "net.dial")
+    {
+        return error_object(error);
+    }
+
+    let transport = if network == "tcp" {
+        network_policy::connect_tcp_stream_with_timeout(address, "net.dial", options.timeout)
+            .map(Transport::Tcp)
+    } else {
+        dial_udp(address).map(Transport::Udp)
+    };
+    match transport {
+        Ok(transport) => match connection(network, transport, options.line_ending) {
+            Ok(conn) => Value::Socket(Arc::new(conn)),
+            Err(error) => error_object(format!("net.dial() failed: {}", error)),
+        },
+        Err(error) => error_object(error),
+    }
+}
+
+/// A UDP socket on an ephemeral local port, connected to `address`.
+fn dial_udp(address: &str) -> Result<UdpSocket, String> {
+    let failure =
+        |error: io::Error| format!("net.dial failed to connect to '{}': {}", address, error);
+    let remote = address.to_socket_addrs().map_err(failure)?.next().ok_or_else(|| {
+        format!("net.dial failed: no socket addresses resolved for '{}'", address)
+    })?;
+    let local = match remote {
+        SocketAddr::V4(_) => "0.0.0.0:0",
+        SocketAddr::V6(_) => "[::]:0",
+    };
+    let socket = UdpSocket::bind(local).map_err(failure)?;
+    socket.connect(remote).map_err(failure)?;
+    network_policy::apply_udp_socket_timeouts(&socket, "net.dial")?;
+    Ok(socket)
+}
+
+fn listen(args: &[Value]) -> Value {
+    let (network, address, options) = match socket_arguments("net.listen", args) {
+        Ok(arguments) => arguments,
+        Err(error) => return error,
+    };
+    // `:8080` listens on every interface, as in other languages' socket APIs
+    let address =
+        if address.starts_with(':') { format!("0.0.0.0{}", address) } else { address.to_string() };
+    let failure = |error: io::Error| {
+        error_object(format!("net.listen() failed to bind {} '{}': {}", network, address, error))
+    };
+
+    if network == "tcp" {
+        let listener = match TcpListener::bind(&address) {
+            Ok(listener) => listener,
+            Err(error) => return failure(error),
+        };
+        let local = match listener.set_nonblocking(true).and_then(|_| listener.local_addr()) {
+            Ok(local) => local,
+            Err(error) => return failure(error),
+        };
+        return Value::Socket(Arc::new(NetSocket {
+            label: format!("tcp {}", local),
+            local_addr: local.to_string(),
+            remote_addr: None,
+            kind: SocketKind::Listener {
+                listener: Mutex::new(Some(listener)),
+                line_ending: options.line_ending,
+            },
+            closed: AtomicBool::new(false),
+            deadlines: Mutex::new(Deadlines::default()),
+        }));
+    }
+
+    let socket = match UdpSocket::bind(&address) {
+        Ok(socket) => socket,
+        Err(error) => return failure(error),
+    };
+    let local = match socket.local_addr() {
+        Ok(local) => local,
+        Err(error) => return failure(error),
+    };
+    Value::Socket(Arc::new(NetSocket {
+        label: format!("udp {}", local),
+        local_addr: local.to_string(),
+        remote_addr: None,
+        kind: SocketKind::Packet { socket },
+        closed: AtomicBool::new(false),
+        deadlines: Mutex::new(Deadlines::default()),
+    }))
+}
+
+fn call_net_module(method: &str, args: &[Value]) -> Value {
+    match method {
+        "dial" => dial(args),
+        "listen" => listen(args),
+        _ => Value::Error(format!("Module 'net' has no export '{}'", method)),
+    }
+}
+
+pub fn handle(name: &str, args: &[Value]) -> Option<Value> {
+    name.strip_prefix("net.").map(|method| call_net_module(method, args))
+}
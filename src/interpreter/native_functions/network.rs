@@ -7,7 +7,7 @@ use crate::network_policy;
 use std::io::{Read, Write};
 use std::sync::{Arc, Mutex, MutexGuard};
 
-fn timeout_aware_error_message(operation: &str, error: &std::io::Error) -> String {
+pub(super) fn timeout_aware_error_message(operation: &str, error: &std::io::Error) -> String {
     match error.kind() {
         std::io::ErrorKind::TimedOut | std::io::ErrorKind::WouldBlock => format!(
             "{} timed out after {}ms read/{}ms write timeout policy: {}",
@@ -29,6 +29,7 @@ fn lock_or_network_error<'a, T>(
         stack: Vec::new(),
         line: None,
         cause: None,
+        payload: None,
     })
 }
 
@@ -57,6 +58,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -88,6 +90,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                     stack: Vec::new(),
                                     line: None,
                                     cause: None,
+                                    payload: None,
                                 },
                             }
                         }
@@ -99,6 +102,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             stack: Vec::new(),
                             line: None,
                             cause: None,
+                            payload: None,
                         },
                     }
                 } else {
@@ -123,6 +127,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             });
                         }
                         let address = format!("{}:{}", host.as_ref(), port);
@@ -136,6 +141,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -169,6 +175,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                     stack: Vec::new(),
                                     line: None,
                                     cause: None,
+                                    payload: None,
                                 },
                             },
                             Err(error) => Value::ErrorObject {
@@ -179,6 +186,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -198,6 +206,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                     stack: Vec::new(),
                                     line: None,
                                     cause: None,
+                                    payload: None,
                                 },
                             },
                             Err(error) => Value::ErrorObject {
@@ -208,6 +217,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -251,6 +261,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -300,6 +311,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -319,6 +331,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -350,6 +363,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                         stack: Vec::new(),
                                         line: None,
                                         cause: None,
+                                        payload: None,
                                     },
                                 }
                             }
@@ -361,6 +375,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
@@ -400,7 +415,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             message: error,
                             stack: Vec::new(),
                             line: None,
-                            cause: None,
+                            cause: None, payload: None,
                         });
                     }
                     let address = format!("{}:{}", host.as_ref(), port);
@@ -417,7 +432,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             ),
                             stack: Vec::new(),
                             line: None,
-                            cause: None,
+                            cause: None, payload: None,
                         },
                     }
                 }
@@ -436,7 +451,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             message: error,
                             stack: Vec::new(),
                             line: None,
-                            cause: None,
+                            cause: None, payload: None,
                         });
                     }
                     let address = format!("{}:{}", host.as_ref(), port);
@@ -453,7 +468,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                             ),
                             stack: Vec::new(),
                             line: None,
-                            cause: None,
+                            cause: None, payload: None,
                         },
                     }
                 }
@@ -512,6 +527,7 @@ pub fn handle(_interp: &mut Interpreter, name: &str, arg_values: &[Value]) -> Op
                                 stack: Vec::new(),
                                 line: None,
                                 cause: None,
+                                payload: None,
                             },
                         }
                     }
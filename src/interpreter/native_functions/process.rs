@@ -0,0 +1,494 @@
+// File: src/interpreter/native_functions/process.rs
+//
+// `proc` namespace: run external programs directly, without a shell.
+//
+// `proc.run` waits for a program and returns its `ProcessResult`. `proc.spawn` returns a
+// `Process` handle whose stdout can be read line by line while the program runs.
+// `proc.pipeline` connects programs with OS pipes, the way `a | b | c` does in a shell.
+// Every captured stream is drained by its own reader thread and stdin is fed from a
+// writer thread, so a program that fills one pipe while we wait on another cannot
+// deadlock the script.
+
+use super::system::{
+    apply_env_policy, collect_stream_with_limit, error_object, parse_process_options_with_defaults,
+    process_result_to_value, render_command_for_error, run_command_with_options,
+    spawn_stdin_writer, value_type_name, ProcessExecOptions, ProcessExecutionResult,
+    MAX_PROCESS_MAX_OUTPUT_BYTES, PROCESS_POLL_INTERVAL_MS,
+};
+use crate::interpreter::Value;
+use std::io::{BufRead, BufReader, Read, Write};
+use std::process::{Child, ChildStdin, Command, ExitStatus, Stdio};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::mpsc::{self, Receiver, RecvTimeoutError};
+use std::sync::{Arc, Mutex};
+use std::thread::{self, JoinHandle};
+use std::time::{Duration, Instant};
+
+/// Lines a spawned program may write ahead of the script before it blocks on its stdout.
+const STREAM_LINE_BUFFER: usize = 256;
+
+type StreamReader = JoinHandle<Result<(Vec<u8>, bool), String>>;
+
+/// A program started by `proc.spawn()`.
+pub struct ProcessHandle {
+    label: String,
+    pid: u32,
+    child: Mutex<Child>,
+    stdin: Mutex<Option<ChildStdin>>,
+    stdout_lines: Mutex<Receiver<Vec<u8>>>,
+    stderr: Mutex<Option<StreamReader>>,
+    deadline: Option<Instant>,
+    max_output_bytes: usize,
+    timed_out: AtomicBool,
+}
+
+impl ProcessHandle {
+    pub fn label(&self) -> &str {
+        &self.label
+    }
+
+    /// Kills the program once its deadline has passed; the pipes then reach end of file.
+    fn enforce_deadline(&self) {
+        if self.deadline.is_some_and(|deadline| Instant::now() >= deadline)
+            && !self.timed_out.swap(true, Ordering::SeqCst)
+        {
+            let _ = lock(&self.child).kill();
+        }
+    }
+
+    /// The next raw stdout line, including its newline; `None` at end of output.
+    fn next_line(&self) -> Option<Vec<u8>> {
+        let lines = lock(&self.stdout_lines);
+        loop {
+            match lines.recv_timeout(Duration::from_millis(PROCESS_POLL_INTERVAL_MS)) {
+                Ok(line) => return Some(line),
+                Err(RecvTimeoutError::Timeout) => self.enforce_deadline(),
+                Err(RecvTimeoutError::Disconnected) => return None,
+            }
+        }
+    }
+
+    fn read_line(&self) -> Value {
+        match self.next_line() {
+            Some(mut line) => {
+                if line.last() == Some(&b'\n') {
+                    line.pop();
+                    if line.last() == Some(&b'\r') {
+                        line.pop();
+                    }
+                }
+                Value::Str(Arc::new(String::from_utf8_lossy(&line).into_owned()))
+            }
+            None => Value::Null,
+        }
+    }
+
+    fn write(&self, args: &[Value]) -> Value {
+        let bytes = match args {
+            [Value::Str(text)] => text.as_bytes(),
+            [Value::Bytes(bytes)] => bytes.as_slice(),
+            _ => return Value::Error("Process.write() requires a string or bytes".to_string()),
+        };
+        let mut stdin = lock(&self.stdin);
+        let Some(pipe) = stdin.as_mut() else {
+            return Value::Error(format!("Process '{}' stdin is closed", self.label));
+        };
+        match pipe.write_all(bytes).and_then(|_| pipe.flush()) {
+            Ok(()) => Value::Int(bytes.len() as i64),
+            Err(error) => {
+                Value::Error(format!("Cannot write to process '{}': {}", self.label, error))
+            }
+        }
+    }
+
+    /// Closes stdin, collects the unread stdout and all of stderr, and waits for exit.
+    fn wait(&self) -> Value {
+        lock(&self.stdin).take();
+        let mut stdout = Vec::new();
+        let mut stdout_truncated = false;
+        while let Some(line) = self.next_line() {
+            let room = self.max_output_bytes.saturating_sub(stdout.len());
+            stdout_truncated |= line.len() > room;
+            stdout.extend_from_slice(&line[..line.len().min(room)]);
+        }
+        let status = loop {
+            match lock(&self.child).try_wait() {
+                Ok(Some(status)) => break status,
+                Ok(None) => {
+                    self.enforce_deadline();
+                    thread::sleep(Duration::from_millis(PROCESS_POLL_INTERVAL_MS));
+                }
+                Err(error) => {
+                    return error_object(format!(
+                        "Failed while waiting for process '{}': {}",
+                        self.label, error
+                    ))
+                }
+            }
+        };
+        let (stderr, stderr_truncated) = match lock(&self.stderr).take().map(JoinHandle::join) {
+            Some(Ok(Ok(collected))) => collected,
+            None => (Vec::new(), false),
+            Some(_) => {
+                return error_object(format!("Failed to read stderr for process '{}'", self.label))
+            }
+        };
+        let timed_out = self.timed_out.load(Ordering::SeqCst);
+        process_result_to_value(execution_result(
+            status,
+            timed_out,
+            (stdout, stdout_truncated),
+            (stderr, stderr_truncated),
+        ))
+    }
+}
+
+fn lock<T>(mutex: &Mutex<T>) -> std::sync::MutexGuard<'_, T> {
+    mutex.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+}
+
+fn execution_result(
+    status: ExitStatus,
+    timed_out: bool,
+    (stdout, stdout_truncated): (Vec<u8>, bool),
+    (stderr, stderr_truncated): (Vec<u8>, bool),
+) -> ProcessExecutionResult {
+    ProcessExecutionResult {
+        exitcode: status.code().unwrap_or(-1) as i64,
+        success: status.success() && !timed_out,
+        timed_out,
+        stdout,
+        stderr,
+        stdout_truncated,
+        stderr_truncated,
+    }
+}
+
+/// `proc.*` runs may take as long as they need and keep up to 16 MiB of each stream.
+fn proc_defaults() -> ProcessExecOptions {
+    let mut options = ProcessExecOptions::default();
+    options.timeout_ms = u64::MAX;
+    options.max_output_bytes = MAX_PROCESS_MAX_OUTPUT_BYTES;
+    options
+}
+
+fn deadline(options: &ProcessExecOptions) -> Option<Instant> {
+    Instant::now().checked_add(Duration::from_millis(options.timeout_ms))
+}
+
+fn string_list(function: &str, what: &str, value: &Value) -> Result<Vec<String>, Value> {
+    let Value::Array(items) = value else {
+        return Err(Value::Error(format!(
+            "{}() {} must be an array of strings, got {}",
+            function,
+            what,
+            value_type_name(value)
+        )));
+    };
+    items
+        .iter()
+        .map(|item| match item {
+            Value::Str(text) => Ok(text.as_ref().clone()),
+            other => Err(Value::Error(format!(
+                "{}() {} must contain only strings, got {}",
+                function,
+                what,
+                value_type_name(other)
+            ))),
+        })
+        .collect()
+}
+
+/// Reads the `(cmd, args?, options?)` arguments of `proc.run` and `proc.spawn`.
+/// The options dict may come second when there are no arguments to pass.
+fn command_arguments(
+    function: &str,
+    args: &[Value],
+) -> Result<(String, Vec<String>, ProcessExecOptions), Value> {
+    let Some(Value::Str(program)) = args.first() else {
+        return Err(Value::Error(format!("{}() requires a program name string", function)));
+    };
+    let (arguments, options) = match (args.get(1), args.get(2)) {
+        (Some(Value::Dict(_) | Value::FixedDict { .. }), None) => (Vec::new(), args.get(1)),
+        (None | Some(Value::Null), options) => (Vec::new(), options),
+        (Some(list), options) => (string_list(function, "args", list)?, options),
+    };
+    let options = parse_process_options_with_defaults(options, proc_defaults())?;
+    Ok((program.as_ref().clone(), arguments, options))
+}
+
+fn spawn_failure(label: &str, error: std::io::Error) -> Value {
+    error_object(format!("Failed to spawn process '{}': {}", label, error))
+}
+
+fn run(args: &[Value]) -> Value {
+    let (program, arguments, options) = match command_arguments("proc.run", args) {
+        Ok(parsed) => parsed,
+        Err(error) => return error,
+    };
+    let label = render_command_for_error(&program, &arguments);
+    let mut command = Command::new(&program);
+    command.args(&arguments);
+    match run_command_with_options(command, &options, None, &label) {
+        Ok(result) => process_result_to_value(result),
+        Err(error) => error,
+    }
+}
+
+fn spawn(args: &[Value]) -> Value {
+    let (program, arguments, options) = match command_arguments("proc.spawn", args) {
+        Ok(parsed) => parsed,
+        Err(error) => return error,
+    };
+    let label = render_command_for_error(&program, &arguments);
+    let mut command = Command::new(&program);
+    command.args(&arguments);
+    apply_env_policy(&mut command, &options);
+    command.stdin(Stdio::piped()).stdout(Stdio::piped()).stderr(Stdio::piped());
+    let mut child = match command.spawn() {
+        Ok(child) => child,
+        Err(error) => return spawn_failure(&label, error),
+    };
+
+    let stdin = match (options.stdin.clone(), child.stdin.take()) {
+        // Initial input is written in the background and then stdin is closed
+        (Some(input), Some(pipe)) => {
+            spawn_stdin_writer(pipe, input);
+            None
+        }
+        (_, pipe) => pipe,
+    };
+    let (Some(stdout), Some(stderr)) = (child.stdout.take(), child.stderr.take()) else {
+        let _ = child.kill();
+        return error_object(format!("Failed to capture output for process '{}'", label));
+    };
+    let max_output_bytes = options.max_output_bytes;
+    let (sender, receiver) = mpsc::sync_channel(STREAM_LINE_BUFFER);
+    thread::spawn(move || {
+        let mut reader = BufReader::new(stdout);
+        loop {
+            // A line longer than the output limit is delivered in pieces
+            let mut line = Vec::new();
+            match (&mut reader).take(max_output_bytes as u64).read_until(b'\n', &mut line) {
+                Ok(0) | Err(_) => break,
+                Ok(_) if sender.send(line).is_err() => break,
+                Ok(_) => {}
+            }
+        }
+    });
+    let stderr = thread::spawn(move || collect_stream_with_limit(stderr, max_output_bytes));
+
+    Value::Process(Arc::new(ProcessHandle {
+        label,
+        pid: child.id(),
+        child: Mutex::new(child),
+        stdin: Mutex::new(stdin),
+        stdout_lines: Mutex::new(receiver),
+        stderr: Mutex::new(Some(stderr)),
+        deadline: deadline(&options),
+        max_output_bytes,
+        timed_out: AtomicBool::new(false),
+    }))
+}
+
+fn pipeline(args: &[Value]) -> Value {
+    let stages = match args.first() {
+        Some(Value::Array(stages)) if !stages.is_empty() => stages,
+        _ => {
+            return Value::Error(
+                "proc.pipeline() requires a non-empty array of [program, args...] arrays"
+                    .to_string(),
+            )
+        }
+    };
+    let mut commands = Vec::with_capacity(stages.len());
+    for stage in stages.iter() {
+        match string_list("proc.pipeline", "stage", stage) {
+            Ok(parts) if !parts.is_empty() => commands.push(parts),
+            Ok(_) => return Value::Error("proc.pipeline() stages must not be empty".to_string()),
+            Err(error) => return error,
+        }
+    }
+    let options = match parse_process_options_with_defaults(args.get(1), proc_defaults()) {
+        Ok(options) => options,
+        Err(error) => return error,
+    };
+    match run_pipeline(&commands, &options) {
+        Ok((result, exit_codes)) => match process_result_to_value(result) {
+            Value::Struct { name, mut fields } => {
+                fields.insert(
+                    "exitcodes".to_string(),
+                    Value::Array(Arc::new(exit_codes.into_iter().map(Value::Int).collect())),
+                );
+                Value::Struct { name, fields }
+            }
+            other => other,
+        },
+        Err(error) => error,
+    }
+}
+
+/// Starts every stage at once with each stdout piped into the next stdin. The result carries
+/// the last stage's stdout and exit code, every stage's stderr in order, and is successful
+/// only when every stage exits with 0, like `set -o pipefail`.
+fn run_pipeline(
+    commands: &[Vec<String>],
+    options: &ProcessExecOptions,
+) -> Result<(ProcessExecutionResult, Vec<i64>), Value> {
+    let mut children: Vec<Child> = Vec::with_capacity(commands.len());
+    let mut stderr_readers = Vec::with_capacity(commands.len());
+    let mut stdin_writer = None;
+    let mut previous_stdout = None;
+    let kill_all = |children: &mut Vec<Child>| {
+        for child in children.iter_mut() {
+            let _ = child.kill();
+            let _ = child.wait();
+        }
+    };
+
+    for (index, parts) in commands.iter().enumerate() {
+        let label = render_command_for_error(&parts[0], &parts[1..]);
+        let mut command = Command::new(&parts[0]);
+        command.args(&parts[1..]);
+        apply_env_policy(&mut command, options);
+        match previous_stdout.take() {
+            Some(stdout) => {
+                command.stdin(Stdio::from(stdout));
+            }
+            None if options.stdin.is_some() => {
+                command.stdin(Stdio::piped());
+            }
+            None => {}
+        }
+        command.stdout(Stdio::piped()).stderr(Stdio::piped());
+        let mut child = match command.spawn() {
+            Ok(child) => child,
+            Err(error) => {
+                kill_all(&mut children);
+                return Err(spawn_failure(&label, error));
+            }
+        };
+        if index == 0 {
+            if let (Some(input), Some(pipe)) = (options.stdin.clone(), child.stdin.take()) {
+                stdin_writer = Some(spawn_stdin_writer(pipe, input));
+            }
+        }
+        let max_output_bytes = options.max_output_bytes;
+        if let Some(stderr) = child.stderr.take() {
+            stderr_readers
+                .push(thread::spawn(move || collect_stream_with_limit(stderr, max_output_bytes)));
+        }
+        previous_stdout = child.stdout.take();
+        children.push(child);
+    }
+
+    let max_output_bytes = options.max_output_bytes;
+    let stdout_reader = previous_stdout
+        .map(|stdout| thread::spawn(move || collect_stream_with_limit(stdout, max_output_bytes)));
+
+    let deadline = deadline(options);
+    let mut statuses: Vec<Option<ExitStatus>> = vec![None; children.len()];
+    let mut timed_out = false;
+    loop {
+        let mut wait_error = None;
+        for (child, status) in children.iter_mut().zip(statuses.iter_mut()) {
+            if status.is_none() {
+                match child.try_wait() {
+                    Ok(done) => *status = done,
+                    Err(error) => wait_error = Some(error),
+                }
+            }
+        }
+        if let Some(error) = wait_error {
+            kill_all(&mut children);
+            return Err(error_object(format!("Failed while waiting for pipeline: {}", error)));
+        }
+        if statuses.iter().all(Option::is_some) {
+            break;
+        }
+        if !timed_out && deadline.is_some_and(|deadline| Instant::now() >= deadline) {
+            timed_out = true;
+            for child in children.iter_mut() {
+                let _ = child.kill();
+            }
+        }
+        thread::sleep(Duration::from_millis(PROCESS_POLL_INTERVAL_MS));
+    }
+
+    if let Some(writer) = stdin_writer {
+        let _ = writer.join();
+    }
+    let join = |reader: StreamReader| match reader.join() {
+        Ok(Ok(collected)) => Ok(collected),
+        _ => Err(error_object("Failed to read pipeline output".to_string())),
+    };
+    let stdout = match stdout_reader {
+        Some(reader) => join(reader)?,
+        None => (Vec::new(), false),
+    };
+    let mut stderr = (Vec::new(), false);
+    for reader in stderr_readers {
+        let (bytes, truncated) = join(reader)?;
+        stderr.0.extend_from_slice(&bytes);
+        stderr.1 |= truncated;
+    }
+
+    let statuses: Vec<ExitStatus> = statuses.into_iter().flatten().collect();
+    let exit_codes: Vec<i64> =
+        statuses.iter().map(|status| status.code().unwrap_or(-1) as i64).collect();
+    let last = *statuses.last().expect("a pipeline has at least one stage");
+    let mut result = execution_result(last, timed_out, stdout, stderr);
+    result.success &= statuses.iter().all(ExitStatus::success);
+    Ok((result, exit_codes))
+}
+
+/// Methods on the `Process` returned by `proc.spawn()`
+pub(crate) fn call_process_method(obj: &Value, method: &str, args: &[Value]) -> Option<Value> {
+    let Value::Process(process) = obj else {
+        return None;
+    };
+    let expected_args = match method {
+        "write" => 1,
+        "read_line" | "close_stdin" | "wait" | "kill" | "pid" => 0,
+        _ => return Some(Value::Error(format!("Process has no method '{}'", method))),
+    };
+    if args.len() != expected_args {
+        return Some(Value::Error(format!(
+            "Process.{}() expects {} argument{}, got {}",
+            method,
+            expected_args,
+            if expected_args == 1 { "" } else { "s" },
+            args.len()
+        )));
+    }
+
+    let result = match method {
+        "read_line" => process.read_line(),
+        "write" => process.write(args),
+        "close_stdin" => {
+            lock(&process.stdin).take();
+            Value::Null
+        }
+        "wait" => process.wait(),
+        "kill" => {
+            // Killing a program that already exited is not an error
+            let _ = lock(&process.child).kill();
+            Value::Null
+        }
+        _ => Value::Int(i64::from(process.pid)),
+    };
+    Some(result)
+}
+
+fn call_proc_module(method: &str, args: &[Value]) -> Value {
+    match method {
+        "run" => run(args),
+        "spawn" => spawn(args),
+        "pipeline" => pipeline(args),
+        _ => Value::Error(format!("Module 'proc' has no export '{}'", method)),
+    }
+}
+
+pub fn handle(name: &str, args: &[Value]) -> Option<Value> {
+    name.strip_prefix("proc.").map(|method| call_proc_module(method, args))
+}
@@ -3,8 +3,8 @@
 // String manipulation native functions
 
 use crate::builtins;
-use crate::interpreter::{DictMap, Value};
-use std::sync::Arc;
+use crate::interpreter::{DictMap, Interpreter, Value};
+use std::sync::{Arc, Mutex};
 
 fn require_string_arg<'a>(
     args: &'a [Value],
@@ -34,6 +34,137 @@ fn require_number_arg(
     }
 }
 
+fn group_array(captures: &regex::Captures) -> Value {
+    Value::Array(Arc::new(
+        captures
+            .iter()
+            .map(|group| match group {
+                Some(group) => Value::Str(Arc::new(group.as_str().to_string())),
+                None => Value::Null,
+            })
+            .collect(),
+    ))
+}
+
+/// Dispatch for the `regex.<method>` natives exported by `builtins::regex_module_value`.
+///
+/// Unlike the flat `regex_*` helpers, an invalid pattern is an error rather than a silent
+/// non-match. Capture groups that did not take part in the match are `null`.
+fn call_regex_module(method: &str, args: &[Value]) -> Value {
+    if method == "escape" {
+        return match args.first() {
+            Some(Value::Str(text)) => Value::Str(Arc::new(regex::escape(text))),
+            _ => Value::Error("regex.escape() requires a string argument".to_string()),
+        };
+    }
+
+    let (Some(Value::Str(text)), Some(Value::Str(pattern))) = (args.first(), args.get(1)) else {
+        return Value::Error(format!("regex.{}() requires (text, pattern) strings", method));
+    };
+    let compiled = match builtins::compile_regex(pattern) {
+        Ok(compiled) => compiled,
+        Err(error) => {
+            // Syntax errors render as a multi-line caret diagram; keep just the reason.
+            let rendered = error.to_string();
+            let reason = rendered.lines().last().unwrap_or_default().trim_start_matches("error: ");
+            return Value::Error(format!(
+                "regex.{}() invalid pattern '{}': {}",
+                method, pattern, reason
+            ));
+        }
+    };
+    let text = text.as_str();
+
+    match method {
+        "match" => Value::Bool(compiled.is_match(text)),
+        "find" => compiled
+            .find(text)
+            .map(|found| Value::Str(Arc::new(found.as_str().to_string())))
+            .unwrap_or(Value::Null),
+        "find_all" => Value::Array(Arc::new(
+            compiled
+                .find_iter(text)
+                .map(|found| Value::Str(Arc::new(found.as_str().to_string())))
+                .collect(),
+        )),
+        "captures" => {
+            compiled.captures(text).map(|captures| group_array(&captures)).unwrap_or(Value::Null)
+        }
+        "captures_all" => Value::Array(Arc::new(
+            compiled.captures_iter(text).map(|captures| group_array(&captures)).collect(),
+        )),
+        "named_captures" => match compiled.captures(text) {
+            Some(captures) => {
+                let mut groups = DictMap::default();
+                for name in compiled.capture_names().flatten() {
+                    let value = match captures.name(name) {
+                        Some(group) => Value::Str(Arc::new(group.as_str().to_string())),
+                        None => Value::Null,
+                    };
+                    groups.insert(name.into(), value);
+                }
+                Value::Dict(Arc::new(groups))
+            }
+            None => Value::Null,
+        },
+        "replace" => match args.get(2) {
+            Some(Value::Str(replacement)) => {
+                Value::Str(Arc::new(compiled.replace_all(text, replacement.as_str()).to_string()))
+            }
+            _ => Value::Error(
+                "regex.replace() requires (text, pattern, replacement) strings".to_string(),
+            ),
+        },
+        "split" => Value::Array(Arc::new(
+            compiled.split(text).map(|part| Value::Str(Arc::new(part.to_string()))).collect(),
+        )),
+        _ => Value::Error(format!("Module 'regex' has no export '{}'", method)),
+    }
+}
+
+/// Methods on the `StringBuilder` returned by `strings.builder()`. Appends mutate the shared
+/// buffer in place, so building a string in a loop is linear rather than quadratic.
+pub(crate) fn call_string_builder_method(
+    obj: &Value,
+    method: &str,
+    args: &[Value],
+) -> Option<Value> {
+    let Value::StringBuilder(buffer) = obj else {
+        return None;
+    };
+
+    let expected_args = if method == "append" { 1 } else { 0 };
+    if matches!(method, "append" | "to_string" | "len" | "clear") && args.len() != expected_args {
+        return Some(Value::Error(format!(
+            "StringBuilder.{}() expects {} argument{}, got {}",
+            method,
+            expected_args,
+            if expected_args == 1 { "" } else { "s" },
+            args.len()
+        )));
+    }
+
+    let mut buffer_guard = buffer.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+    let result = match method {
+        "append" => {
+            match &args[0] {
+                Value::Str(text) => buffer_guard.push_str(text),
+                other => buffer_guard.push_str(&Interpreter::stringify_value(other)),
+            }
+            // Returning the builder lets calls chain: `sb.append("a").append("b")`.
+            obj.clone()
+        }
+        "to_string" => Value::Str(Arc::new(buffer_guard.clone())),
+        "len" => Value::Int(buffer_guard.chars().count() as i64),
+        "clear" => {
+            buffer_guard.clear();
+            Value::Null
+        }
+        _ => Value::Error(format!("StringBuilder has no method '{}'", method)),
+    };
+    Some(result)
+}
+
 pub fn handle(name: &str, args: &[Value]) -> Option<Value> {
     let result = match name {
         "len" => {
@@ -311,6 +442,16 @@ pub fn handle(name: &str, args: &[Value]) -> Option<Value> {
             }
         }
 
+        name if name.starts_with("regex.") => call_regex_module(&name["regex.".len()..], args),
+
+        "strings.builder" => match args.first() {
+            None => Value::StringBuilder(Arc::new(Mutex::new(String::new()))),
+            Some(Value::Str(initial)) => {
+                Value::StringBuilder(Arc::new(Mutex::new(initial.as_ref().clone())))
+            }
+            Some(_) => Value::Error("strings.builder() initial value must be a string".to_string()),
+        },
+
         "join" => {
             if let (Some(Value::Array(arr)), Some(Value::Str(separator))) =
                 (args.first(), args.get(1))
@@ -729,6 +870,74 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_regex_namespace_captures_and_errors() {
+        let captures =
+            handle("regex.captures", &[str_value("k=v"), str_value("(\\w)=(\\w)(!)?")]).unwrap();
+        match captures {
+            Value::Array(groups) => {
+                assert_eq!(groups.len(), 4);
+                assert!(matches!(&groups[0], Value::Str(s) if s.as_ref() == "k=v"));
+                assert!(matches!(&groups[2], Value::Str(s) if s.as_ref() == "v"));
+                assert!(matches!(&groups[3], Value::Null));
+            }
+            _ => panic!("Expected Value::Array from regex.captures"),
+        }
+
+        let named = handle(
+            "regex.named_captures",
+            &[str_value("2026-10"), str_value("(?P<year>\\d+)-(?P<month>\\d+)")],
+        )
+        .unwrap();
+        match named {
+            Value::Dict(groups) => {
+                assert!(matches!(groups.get("year"), Some(Value::Str(s)) if s.as_ref() == "2026"));
+                assert!(matches!(groups.get("month"), Some(Value::Str(s)) if s.as_ref() == "10"));
+            }
+            _ => panic!("Expected Value::Dict from regex.named_captures"),
+        }
+
+        let no_match = handle("regex.find", &[str_value("abc"), str_value("\\d")]).unwrap();
+        assert!(matches!(no_match, Value::Null));
+        let swapped = handle(
+            "regex.replace",
+            &[str_value("John Smith"), str_value("(\\w+) (\\w+)"), str_value("$2, $1")],
+        )
+        .unwrap();
+        assert!(matches!(swapped, Value::Str(s) if s.as_ref() == "Smith, John"));
+
+        let invalid = handle("regex.match", &[str_value("x"), str_value("(")]).unwrap();
+        assert!(
+            matches!(invalid, Value::Error(message) if message == "regex.match() invalid pattern '(': unclosed group")
+        );
+    }
+
+    #[test]
+    fn test_string_builder_appends_in_place_and_validates_calls() {
+        let builder = handle("strings.builder", &[str_value("a")]).unwrap();
+        let alias = builder.clone();
+        let chained = call_string_builder_method(&builder, "append", &[Value::Int(1)]).unwrap();
+        call_string_builder_method(&chained, "append", &[str_value("→")]).unwrap();
+        call_string_builder_method(&alias, "append", &[Value::Bool(true)]).unwrap();
+
+        let text = call_string_builder_method(&builder, "to_string", &[]).unwrap();
+        assert!(matches!(text, Value::Str(s) if s.as_ref() == "a1→true"));
+        let length = call_string_builder_method(&builder, "len", &[]).unwrap();
+        assert!(matches!(length, Value::Int(7)));
+
+        call_string_builder_method(&builder, "clear", &[]).unwrap();
+        let cleared = call_string_builder_method(&alias, "to_string", &[]).unwrap();
+        assert!(matches!(cleared, Value::Str(s) if s.is_empty()));
+
+        let missing_arg = call_string_builder_method(&builder, "append", &[]).unwrap();
+        assert!(
+            matches!(missing_arg, Value::Error(message) if message == "StringBuilder.append() expects 1 argument, got 0")
+        );
+        assert!(call_string_builder_method(&str_value("x"), "append", &[]).is_none());
+        let bad_seed = handle("strings.builder", &[Value::Int(1)]).unwrap();
+        assert!(matches!(bad_seed, Value::Error(message) if message.contains("must be a string")));
+    }
+
     #[test]
     fn test_regex_argument_validation_errors() {
         let match_error = handle("regex_match", &[Value::Int(1)]).unwrap();
@@ -4,6 +4,7 @@
 
 use crate::builtins;
 use crate::interpreter::{DictMap, Value};
+use chrono::{Datelike, Timelike};
 use std::collections::HashMap;
 use std::io::{Read, Write};
 use std::process::{Command, Stdio};
@@ -13,17 +14,19 @@ use std::time::{Duration, Instant};
 
 const DEFAULT_PROCESS_TIMEOUT_MS: u64 = 30_000;
 const DEFAULT_PROCESS_MAX_OUTPUT_BYTES: usize = 1024 * 1024;
-const MAX_PROCESS_MAX_OUTPUT_BYTES: usize = 16 * 1024 * 1024;
-const PROCESS_POLL_INTERVAL_MS: u64 = 10;
+pub(super) const MAX_PROCESS_MAX_OUTPUT_BYTES: usize = 16 * 1024 * 1024;
+pub(super) const PROCESS_POLL_INTERVAL_MS: u64 = 10;
 
 #[derive(Clone, Debug)]
-struct ProcessExecOptions {
-    timeout_ms: u64,
-    max_output_bytes: usize,
+pub(super) struct ProcessExecOptions {
+    pub(super) timeout_ms: u64,
+    pub(super) max_output_bytes: usize,
     inherit_env: bool,
     env_allow: Option<Vec<String>>,
     env_deny: Vec<String>,
     env: HashMap<String, String>,
+    cwd: Option<String>,
+    pub(super) stdin: Option<Vec<u8>>,
 }
 
 impl Default for ProcessExecOptions {
@@ -35,26 +38,34 @@ impl Default for ProcessExecOptions {
             env_allow: None,
             env_deny: Vec::new(),
             env: HashMap::new(),
+            cwd: None,
+            stdin: None,
         }
     }
 }
 
 #[derive(Clone, Debug)]
-struct ProcessExecutionResult {
-    exitcode: i64,
-    success: bool,
-    timed_out: bool,
-    stdout: Vec<u8>,
-    stderr: Vec<u8>,
-    stdout_truncated: bool,
-    stderr_truncated: bool,
+pub(super) struct ProcessExecutionResult {
+    pub(super) exitcode: i64,
+    pub(super) success: bool,
+    pub(super) timed_out: bool,
+    pub(super) stdout: Vec<u8>,
+    pub(super) stderr: Vec<u8>,
+    pub(super) stdout_truncated: bool,
+    pub(super) stderr_truncated: bool,
 }
 
-fn error_object(message: impl Into<String>) -> Value {
-    Value::ErrorObject { message: message.into(), stack: Vec::new(), line: None, cause: None }
+pub(super) fn error_object(message: impl Into<String>) -> Value {
+    Value::ErrorObject {
+        message: message.into(),
+        stack: Vec::new(),
+        line: None,
+        cause: None,
+        payload: None,
+    }
 }
 
-fn value_type_name(value: &Value) -> &'static str {
+pub(super) fn value_type_name(value: &Value) -> &'static str {
     match value {
         Value::Int(_) => "int",
         Value::Float(_) => "float",
@@ -67,7 +78,7 @@ fn value_type_name(value: &Value) -> &'static str {
     }
 }
 
-fn dict_entries(value: &Value) -> Option<Vec<(String, Value)>> {
+pub(super) fn dict_entries(value: &Value) -> Option<Vec<(String, Value)>> {
     match value {
         Value::Dict(map) => {
             Some(map.iter().map(|(key, value)| (key.as_ref().to_string(), value.clone())).collect())
@@ -159,15 +170,23 @@ fn validate_shell_command_text(command_text: &str, surface: &str) -> Result<(),
 }
 
 fn parse_process_options(options: Option<&Value>) -> Result<ProcessExecOptions, Value> {
+    parse_process_options_with_defaults(options, ProcessExecOptions::default())
+}
+
+/// Parses a process options dict over `defaults`, so `proc.*` can default to no timeout.
+pub(super) fn parse_process_options_with_defaults(
+    options: Option<&Value>,
+    defaults: ProcessExecOptions,
+) -> Result<ProcessExecOptions, Value> {
     let Some(options_value) = options else {
-        return Ok(ProcessExecOptions::default());
+        return Ok(defaults);
     };
 
     let Some(entries) = dict_entries(options_value) else {
         return Err(Value::Error("process options must be provided as a dict".to_string()));
     };
 
-    let mut options = ProcessExecOptions::default();
+    let mut options = defaults;
 
     for (key, value) in entries {
         match key.as_str() {
@@ -199,9 +218,33 @@ fn parse_process_options(options: Option<&Value>) -> Result<ProcessExecOptions,
             "env" => {
                 options.env = parse_string_dict(&value, "env")?;
             }
+            "timeout" => {
+                // Seconds, matching the `time` module; `timeout_ms` stays for older scripts
+                options.timeout_ms = match value {
+                    Value::Int(seconds) if seconds > 0 => (seconds as u64).saturating_mul(1000),
+                    Value::Float(seconds) if seconds.is_finite() && seconds > 0.0 => {
+                        ((seconds * 1000.0).ceil() as u64).max(1)
+                    }
+                    _ => {
+                        return Err(Value::Error(
+                            "timeout must be a positive number of seconds".to_string(),
+                        ))
+                    }
+                };
+            }
+            "cwd" => match value {
+                Value::Str(path) => options.cwd = Some(path.as_ref().clone()),
+                _ => return Err(Value::Error("cwd must be a string path".to_string())),
+            },
+            "stdin" => match value {
+                Value::Str(text) => options.stdin = Some(text.as_bytes().to_vec()),
+                Value::Bytes(bytes) => options.stdin = Some(bytes),
+                Value::Null => options.stdin = None,
+                _ => return Err(Value::Error("stdin must be a string or bytes".to_string())),
+            },
             _ => {
                 return Err(Value::Error(format!(
-                    "unsupported process option '{}'; supported options are timeout_ms, max_output_bytes, inherit_env, env_allow, env_deny, env",
+                    "unsupported process option '{}'; supported options are timeout, timeout_ms, max_output_bytes, inherit_env, env_allow, env_deny, env, cwd, stdin",
                     key
                 )));
             }
@@ -211,7 +254,12 @@ fn parse_process_options(options: Option<&Value>) -> Result<ProcessExecOptions,
     Ok(options)
 }
 
-fn apply_env_policy(command: &mut Command, options: &ProcessExecOptions) {
+/// Applies the environment policy and working directory of `options` to `command`.
+pub(super) fn apply_env_policy(command: &mut Command, options: &ProcessExecOptions) {
+    if let Some(cwd) = &options.cwd {
+        command.current_dir(cwd);
+    }
+
     match &options.env_allow {
         Some(allow_list) => {
             command.env_clear();
@@ -236,7 +284,7 @@ fn apply_env_policy(command: &mut Command, options: &ProcessExecOptions) {
     }
 }
 
-fn collect_stream_with_limit<R: Read>(
+pub(super) fn collect_stream_with_limit<R: Read>(
     mut reader: R,
     max_output_bytes: usize,
 ) -> Result<(Vec<u8>, bool), String> {
@@ -265,7 +313,7 @@ fn collect_stream_with_limit<R: Read>(
     Ok((collected, truncated))
 }
 
-fn render_command_for_error(program: &str, args: &[String]) -> String {
+pub(super) fn render_command_for_error(program: &str, args: &[String]) -> String {
     if args.is_empty() {
         program.to_string()
     } else {
@@ -273,7 +321,20 @@ fn render_command_for_error(program: &str, args: &[String]) -> String {
     }
 }
 
-fn run_command_with_options(
+/// Feeds `input` to a child's stdin from its own thread and closes the pipe when done, so a
+/// child that writes a lot of output before reading all of its input cannot deadlock us.
+pub(super) fn spawn_stdin_writer(
+    mut stdin: std::process::ChildStdin,
+    input: Vec<u8>,
+) -> thread::JoinHandle<std::io::Result<()>> {
+    thread::spawn(move || match stdin.write_all(&input) {
+        // The child may exit without reading everything; that is not a write failure
+        Err(error) if error.kind() == std::io::ErrorKind::BrokenPipe => Ok(()),
+        other => other,
+    })
+}
+
+pub(super) fn run_command_with_options(
     mut command: Command,
     options: &ProcessExecOptions,
     stdin_input: Option<Vec<u8>>,
@@ -281,6 +342,7 @@ fn run_command_with_options(
 ) -> Result<ProcessExecutionResult, Value> {
     apply_env_policy(&mut command, options);
 
+    let stdin_input = stdin_input.or_else(|| options.stdin.clone());
     command.stdout(Stdio::piped()).stderr(Stdio::piped());
     if stdin_input.is_some() {
         command.stdin(Stdio::piped());
@@ -296,16 +358,10 @@ fn run_command_with_options(
         }
     };
 
-    if let Some(input) = stdin_input {
-        if let Some(mut stdin) = child.stdin.take() {
-            if let Err(error) = stdin.write_all(&input) {
-                return Err(error_object(format!(
-                    "Failed to write stdin for process '{}': {}",
-                    command_label, error
-                )));
-            }
-        }
-    }
+    let stdin_writer = match (stdin_input, child.stdin.take()) {
+        (Some(input), Some(stdin)) => Some(spawn_stdin_writer(stdin, input)),
+        _ => None,
+    };
 
     let Some(stdout_reader) = child.stdout.take() else {
         return Err(error_object(format!(
@@ -359,6 +415,13 @@ fn run_command_with_options(
         }
     };
 
+    if let Some(Ok(Err(error))) = stdin_writer.map(thread::JoinHandle::join) {
+        return Err(error_object(format!(
+            "Failed to write stdin for process '{}': {}",
+            command_label, error
+        )));
+    }
+
     let (stdout, stdout_truncated) = match stdout_handle.join() {
         Ok(Ok(value)) => value,
         Ok(Err(error)) => {
@@ -402,7 +465,7 @@ fn run_command_with_options(
     })
 }
 
-fn process_result_to_value(result: ProcessExecutionResult) -> Value {
+pub(super) fn process_result_to_value(result: ProcessExecutionResult) -> Value {
     let mut fields = HashMap::new();
     fields.insert("exitcode".to_string(), Value::Int(result.exitcode));
     fields.insert(
@@ -421,6 +484,101 @@ fn process_result_to_value(result: ProcessExecutionResult) -> Value {
     Value::Struct { name: "ProcessResult".to_string(), fields }
 }
 
+/// Runs one of the `time.*` functions; arity has already been checked.
+fn time_function(name: &str, arg_values: &[Value]) -> Result<Value, String> {
+    let label = name.replacen("time_", "time.", 1);
+    let timestamp = |index: usize| match arg_values.get(index) {
+        Some(Value::Int(n)) => Ok(*n as f64),
+        Some(Value::Float(n)) => Ok(*n),
+        Some(other) => {
+            Err(format!("{}() expects a timestamp number, got {}", label, value_type_name(other)))
+        }
+        None => Err(format!("{}() requires a timestamp", label)),
+    };
+    // `null` skips an optional string, so `time.format(t, null, "local")` keeps the default layout
+    let optional_str = |index: usize, what: &str| match arg_values.get(index) {
+        None | Some(Value::Null) => Ok(None),
+        Some(Value::Str(text)) => Ok(Some(text.as_str())),
+        Some(other) => {
+            Err(format!("{}() {} must be a string, got {}", label, what, value_type_name(other)))
+        }
+    };
+    let zone = |index: usize| -> Result<builtins::TimeZoneSpec, String> {
+        optional_str(index, "time zone")?
+            .map_or(Ok(builtins::TimeZoneSpec::Utc), builtins::parse_time_zone)
+            .map_err(|message| format!("{}() {}", label, message))
+    };
+    let count = |index: usize, what: &str| match arg_values.get(index) {
+        Some(Value::Int(n)) => Ok(*n),
+        _ => Err(format!("{}() {} must be an int", label, what)),
+    };
+    let with_label = |message: String| format!("{}() failed: {}", label, message);
+
+    Ok(match name {
+        "time_now" => Value::Float(builtins::time_now()),
+        "time_monotonic" => Value::Float(builtins::time_monotonic()),
+        "time_format" => {
+            let text = builtins::time_format(timestamp(0)?, optional_str(1, "layout")?, zone(2)?)
+                .map_err(with_label)?;
+            Value::Str(Arc::new(text))
+        }
+        "time_parse" => {
+            let Some(text) = optional_str(0, "text")? else {
+                return Err(format!("{}() requires the text to parse", label));
+            };
+            Value::Float(
+                builtins::time_parse(text, optional_str(1, "layout")?, zone(2)?)
+                    .map_err(with_label)?,
+            )
+        }
+        "time_parts" => {
+            let time = builtins::time_in_zone(timestamp(0)?, zone(1)?).map_err(with_label)?;
+            let mut parts = DictMap::default();
+            let fields = [
+                ("year", i64::from(time.year())),
+                ("month", i64::from(time.month())),
+                ("day", i64::from(time.day())),
+                ("hour", i64::from(time.hour())),
+                ("minute", i64::from(time.minute())),
+                ("second", i64::from(time.second())),
+                ("nanosecond", i64::from(time.nanosecond())),
+                ("weekday", i64::from(time.weekday().number_from_monday())),
+                ("yearday", i64::from(time.ordinal())),
+                ("offset", i64::from(time.offset().local_minus_utc())),
+            ];
+            for (key, value) in fields {
+                parts.insert(Arc::<str>::from(key), Value::Int(value));
+            }
+            Value::Dict(Arc::new(parts))
+        }
+        "time_add_days" | "time_add_months" => {
+            let amount = count(1, if name == "time_add_days" { "days" } else { "months" })?;
+            let (days, months) = if name == "time_add_days" { (amount, 0) } else { (0, amount) };
+            Value::Float(
+                builtins::time_add_calendar(timestamp(0)?, days, months, zone(2)?)
+                    .map_err(with_label)?,
+            )
+        }
+        "time_diff" => {
+            let unit = optional_str(2, "unit")?.unwrap_or("seconds");
+            let Some(unit_seconds) = builtins::time_unit_seconds(unit) else {
+                return Err(format!(
+                    "{}() unit '{}' is not one of milliseconds, seconds, minutes, hours, days, weeks",
+                    label, unit
+                ));
+            };
+            Value::Float((timestamp(1)? - timestamp(0)?) / unit_seconds)
+        }
+        "time_duration" => {
+            let Some(text) = optional_str(0, "duration")? else {
+                return Err(format!("{}() requires a duration string", label));
+            };
+            Value::Float(builtins::time_duration(text).map_err(with_label)?)
+        }
+        _ => unreachable!("time_function called for {}", name),
+    })
+}
+
 pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
     let result = match name {
         // Random functions
@@ -719,15 +877,24 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             {
                 match builtins::parse_date(date_str, format) {
                     Ok(timestamp) => Value::Float(timestamp),
-                    Err(message) => {
-                        Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
-                    }
+                    Err(message) => Value::ErrorObject {
+                        message,
+                        stack: Vec::new(),
+                        line: None,
+                        cause: None,
+                        payload: None,
+                    },
                 }
             } else {
                 Value::Error("parse_date requires date string and format string".to_string())
             }
         }
 
+        "time_now" | "time_monotonic" | "time_format" | "time_parse" | "time_parts"
+        | "time_add_days" | "time_add_months" | "time_diff" | "time_duration" => {
+            time_function(name, arg_values).unwrap_or_else(Value::Error)
+        }
+
         "kv_set" => {
             if arg_values.len() != 2 {
                 return Some(Value::Error(format!(
@@ -813,9 +980,13 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             if let Some(Value::Str(var_name)) = arg_values.first() {
                 match builtins::env_int(var_name.as_ref()) {
                     Ok(value) => Value::Int(value),
-                    Err(message) => {
-                        Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
-                    }
+                    Err(message) => Value::ErrorObject {
+                        message,
+                        stack: Vec::new(),
+                        line: None,
+                        cause: None,
+                        payload: None,
+                    },
                 }
             } else {
                 Value::Error("env_int requires a string argument (variable name)".to_string())
@@ -833,9 +1004,13 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             if let Some(Value::Str(var_name)) = arg_values.first() {
                 match builtins::env_float(var_name.as_ref()) {
                     Ok(value) => Value::Float(value),
-                    Err(message) => {
-                        Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
-                    }
+                    Err(message) => Value::ErrorObject {
+                        message,
+                        stack: Vec::new(),
+                        line: None,
+                        cause: None,
+                        payload: None,
+                    },
                 }
             } else {
                 Value::Error("env_float requires a string argument (variable name)".to_string())
@@ -853,9 +1028,13 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             if let Some(Value::Str(var_name)) = arg_values.first() {
                 match builtins::env_bool(var_name.as_ref()) {
                     Ok(value) => Value::Bool(value),
-                    Err(message) => {
-                        Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
-                    }
+                    Err(message) => Value::ErrorObject {
+                        message,
+                        stack: Vec::new(),
+                        line: None,
+                        cause: None,
+                        payload: None,
+                    },
                 }
             } else {
                 Value::Error("env_bool requires a string argument (variable name)".to_string())
@@ -873,9 +1052,13 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             if let Some(Value::Str(var_name)) = arg_values.first() {
                 match builtins::env_required(var_name.as_ref()) {
                     Ok(value) => Value::Str(Arc::new(value)),
-                    Err(message) => {
-                        Value::ErrorObject { message, stack: Vec::new(), line: None, cause: None }
-                    }
+                    Err(message) => Value::ErrorObject {
+                        message,
+                        stack: Vec::new(),
+                        line: None,
+                        cause: None,
+                        payload: None,
+                    },
                 }
             } else {
                 Value::Error("env_required requires a string argument (variable name)".to_string())
@@ -1260,6 +1443,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                             stack: Vec::new(),
                             line: None,
                             cause: None,
+                            payload: None,
                         },
                     }
                 } else {
@@ -1342,6 +1526,80 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_time_module_layouts_zones_and_arithmetic() {
+        let text = |value: Value| match value {
+            Value::Str(text) => text.as_ref().clone(),
+            other => panic!("expected a string, got {:?}", other),
+        };
+        let float = |value: Value| match value {
+            Value::Float(number) => number,
+            other => panic!("expected a float, got {:?}", other),
+        };
+        let jan_31 = float(
+            handle(
+                "time_parse",
+                &[string_value("2024-01-31 23:30"), string_value("YYYY-MM-DD HH:mm")],
+            )
+            .unwrap(),
+        );
+        assert_eq!(jan_31, 1_706_743_800.0);
+        assert_eq!(
+            text(handle("time_format", &[Value::Float(jan_31)]).unwrap()),
+            "2024-01-31T23:30:00Z"
+        );
+        assert_eq!(
+            text(
+                handle(
+                    "time_format",
+                    &[
+                        Value::Float(jan_31),
+                        string_value("ddd DD MMM [at] hh:mm A Z"),
+                        string_value("+01:00")
+                    ],
+                )
+                .unwrap()
+            ),
+            "Thu 01 Feb at 12:30 AM +01:00"
+        );
+
+        let leap_day =
+            float(handle("time_add_months", &[Value::Float(jan_31), Value::Int(1)]).unwrap());
+        assert_eq!(
+            text(
+                handle("time_format", &[Value::Float(leap_day), string_value("YYYY-MM-DD")])
+                    .unwrap()
+            ),
+            "2024-02-29"
+        );
+        let days = handle(
+            "time_diff",
+            &[Value::Float(jan_31), Value::Float(leap_day), string_value("days")],
+        );
+        assert!(matches!(days, Some(Value::Float(n)) if n == 29.0));
+
+        let parts = handle("time_parts", &[Value::Float(jan_31), string_value("-0800")]).unwrap();
+        let Value::Dict(parts) = parts else { panic!("time_parts should return a dict") };
+        assert!(matches!(parts.get("hour"), Some(Value::Int(15))));
+        assert!(matches!(parts.get("weekday"), Some(Value::Int(3))));
+        assert!(matches!(parts.get("offset"), Some(Value::Int(-28_800))));
+
+        assert_eq!(float(handle("time_duration", &[string_value("1h30m15.5s")]).unwrap()), 5415.5);
+        for (name, args) in [
+            ("time_duration", vec![string_value("5 minutes")]),
+            ("time_parse", vec![string_value("2024/01/31"), string_value("YYYY-MM-DD")]),
+            ("time_format", vec![Value::Int(0), Value::Null, string_value("America/New_York")]),
+            ("time_diff", vec![Value::Int(0), Value::Int(1), string_value("fortnights")]),
+        ] {
+            let result = handle(name, &args).unwrap();
+            assert!(
+                matches!(&result, Value::Error(message) if message.starts_with("time.")),
+                "{:?}",
+                result
+            );
+        }
+    }
+
     #[test]
     fn test_args_returns_array() {
         let result = handle("args", &[]).unwrap();
@@ -2,10 +2,32 @@
 //
 // Type checking and conversion functions
 
+use crate::bigint::BigInt;
 use crate::builtins;
 use crate::interpreter::{Interpreter, Value};
 use std::sync::Arc;
 
+/// Parses decimal integer text, promoting values outside `i64` to `BigInt`.
+fn parse_int_text(text: &str) -> Option<Value> {
+    let text = text.trim();
+    match text.parse::<i64>() {
+        Ok(n) => Some(Value::Int(n)),
+        Err(_) => BigInt::parse(text).map(Value::from_big_int),
+    }
+}
+
+/// Truncates a finite float toward zero, promoting past the `i64` range.
+fn float_to_int(value: f64) -> Option<Value> {
+    if !value.is_finite() {
+        return None;
+    }
+    let truncated = value.trunc();
+    if truncated >= i64::MIN as f64 && truncated < i64::MAX as f64 {
+        return Some(Value::Int(truncated as i64));
+    }
+    BigInt::parse(&format!("{:.0}", truncated)).map(Value::from_big_int)
+}
+
 pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
     let result = match name {
         // Type conversion functions
@@ -15,10 +37,8 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             }
 
             if let Some(Value::Str(s)) = arg_values.first() {
-                match s.trim().parse::<i64>() {
-                    Ok(n) => Value::Int(n),
-                    Err(_) => Value::Error(format!("Cannot parse '{}' as integer", s)),
-                }
+                parse_int_text(s)
+                    .unwrap_or_else(|| Value::Error(format!("Cannot parse '{}' as integer", s)))
             } else {
                 Value::Error("parse_int requires a string argument".to_string())
             }
@@ -46,12 +66,11 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
 
             if let Some(val) = arg_values.first() {
                 match val {
-                    Value::Int(n) => Value::Int(*n),
-                    Value::Float(f) => Value::Int(f.trunc() as i64),
-                    Value::Str(s) => match s.trim().parse::<i64>() {
-                        Ok(n) => Value::Int(n),
-                        Err(_) => Value::Error(format!("Cannot convert '{}' to int", s)),
-                    },
+                    Value::Int(_) | Value::BigInt(_) => val.clone(),
+                    Value::Float(f) => float_to_int(*f)
+                        .unwrap_or_else(|| Value::Error(format!("Cannot convert {} to int", f))),
+                    Value::Str(s) => parse_int_text(s)
+                        .unwrap_or_else(|| Value::Error(format!("Cannot convert '{}' to int", s))),
                     Value::Bool(b) => Value::Int(if *b { 1 } else { 0 }),
                     _ => Value::Error(format!(
                         "Cannot convert {} to int",
@@ -76,6 +95,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             if let Some(val) = arg_values.first() {
                 match val {
                     Value::Int(n) => Value::Float(*n as f64),
+                    Value::BigInt(n) => Value::Float(n.to_f64()),
                     Value::Float(f) => Value::Float(*f),
                     Value::Str(s) => match s.trim().parse::<f64>() {
                         Ok(n) => Value::Float(n),
@@ -131,6 +151,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                     Value::DenseIntDict(values) => Value::Bool(!values.is_empty()),
                     Value::DenseIntDictInt(values) => Value::Bool(!values.is_empty()),
                     Value::DenseIntDictIntFull(values) => Value::Bool(!values.is_empty()),
+                    Value::OrderedDict(map) => Value::Bool(!map.is_empty()),
                     _ => Value::Bool(true),
                 }
             } else {
@@ -189,7 +210,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
 
             if let Some(val) = arg_values.first() {
                 let type_name = match val {
-                    Value::Int(_) => "int",
+                    Value::Int(_) | Value::BigInt(_) => "int",
                     Value::Float(_) => "float",
                     Value::Str(_) => "string",
                     Value::Bool(_) => "bool",
@@ -201,6 +222,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                     Value::DenseIntDict(_) => "dict",
                     Value::DenseIntDictInt(_) => "dict",
                     Value::DenseIntDictIntFull(_) => "dict",
+                    Value::OrderedDict(_) => "ordmap",
                     Value::Set(_) => "set",
                     Value::Queue(_) => "queue",
                     Value::Stack(_) => "stack",
@@ -212,16 +234,30 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                     Value::ArrayMarker => "arraymarker",
                     Value::Struct { .. } => "struct",
                     Value::StructDef { .. } => "structdef",
+                    Value::Interface { .. } => "interface",
+                    Value::Module { .. } => "module",
                     Value::Tagged { .. } => "tagged",
                     Value::Enum(_) => "enum",
                     Value::Bytes(_) => "bytes",
                     Value::Channel(_) => "channel",
+                    Value::WaitGroup(_) => "wait_group",
+                    Value::Mutex(_) => "mutex",
+                    Value::Atomic(_) => "atomic",
+                    Value::SyncMap(_) => "sync_map",
+                    Value::Decorated(_) => "function",
+                    Value::Router(_) => "router",
+                    Value::StringBuilder(_) => "stringbuilder",
+                    Value::NativeLibrary(_) => "native_library",
+                    Value::Process(_) => "process",
+                    Value::Socket(socket) => socket.type_name(),
+                    Value::Sequence(_) => "sequence",
                     Value::HttpServer { .. } => "httpserver",
                     Value::HttpResponse { .. } => "httpresponse",
                     Value::Database { .. } => "database",
                     Value::DatabasePool { .. } => "databasepool",
                     Value::Image { .. } => "image",
                     Value::ZipArchive { .. } => "ziparchive",
+                    Value::FileHandle { .. } => "filehandle",
                     Value::TcpListener { .. } => "tcplistener",
                     Value::TcpStream { .. } => "tcpstream",
                     Value::UdpSocket { .. } => "udpsocket",
@@ -303,6 +339,7 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                         | Value::DenseIntDict(_)
                         | Value::DenseIntDictInt(_)
                         | Value::DenseIntDictIntFull(_)
+                        | Value::OrderedDict(_)
                 ))
             } else {
                 Value::Bool(false)
@@ -339,7 +376,10 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             }
 
             if let Some(val) = arg_values.first() {
-                Value::Bool(matches!(val, Value::Function(_, _, _) | Value::NativeFunction(_)))
+                Value::Bool(matches!(
+                    val,
+                    Value::Function(_, _, _) | Value::NativeFunction(_) | Value::Decorated(_)
+                ))
             } else {
                 Value::Bool(false)
             }
@@ -420,6 +460,18 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
             }
         }
 
+        "fail" => {
+            if arg_values.len() > 1 {
+                return Some(Value::Error("fail accepts at most 1 argument: message".to_string()));
+            }
+
+            match arg_values.first() {
+                None => Value::Error("Test failed".to_string()),
+                Some(Value::Str(message)) => Value::Error(message.as_ref().clone()),
+                Some(other) => Value::Error(format!("{:?}", other)),
+            }
+        }
+
         "assert_true" => {
             if arg_values.len() != 1 {
                 return Some(Value::Error("assert_true requires exactly 1 argument".to_string()));
@@ -485,6 +537,9 @@ pub fn handle(name: &str, arg_values: &[Value]) -> Option<Value> {
                         false
                     }
                 }
+                Value::OrderedDict(map) => {
+                    Value::dict_key(item).map(|key| map.contains_key(&key)).unwrap_or(false)
+                }
                 Value::IntDict(dict) => {
                     let int_key = match item {
                         Value::Int(i) => Some(*i),
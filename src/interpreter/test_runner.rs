@@ -8,15 +8,20 @@
 // - Setup/teardown hooks for test initialization and cleanup
 // - Result reporting with colored output
 // - Test grouping and organization
+// - Discovering `*_test.ruff` files and their `test_*` functions for `ruff test`
 
-use crate::ast::Stmt;
+use crate::ast::{Expr, Stmt};
 use crate::interpreter::{Interpreter, Value};
+use std::path::{Path, PathBuf};
 
 /// Test runner for executing Ruff test suites
 pub struct TestRunner {
     pub tests: Vec<TestCase>,
     pub setup: Option<Vec<Stmt>>,
     pub teardown: Option<Vec<Stmt>>,
+    /// Module-level statements replayed before every test so each one sees
+    /// freshly defined functions and globals
+    pub prelude: Vec<Stmt>,
     pub results: Vec<TestResult>,
 }
 
@@ -39,7 +44,13 @@ pub struct TestResult {
 impl TestRunner {
     /// Create a new test runner
     pub fn new() -> Self {
-        TestRunner { tests: Vec::new(), setup: None, teardown: None, results: Vec::new() }
+        TestRunner {
+            tests: Vec::new(),
+            setup: None,
+            teardown: None,
+            prelude: Vec::new(),
+            results: Vec::new(),
+        }
     }
 
     /// Collect all test statements from the AST
@@ -64,6 +75,32 @@ impl TestRunner {
         }
     }
 
+    /// Collect tests from a `*_test.ruff` module: `test` blocks as usual, plus every
+    /// top-level function whose name starts with `test_`, in declaration order.
+    /// All other top-level statements become the prelude each test runs first.
+    pub fn collect_module_tests(&mut self, stmts: &[Stmt]) {
+        self.collect_tests(stmts);
+
+        for stmt in stmts {
+            match stmt {
+                Stmt::Test { .. }
+                | Stmt::TestSetup { .. }
+                | Stmt::TestTeardown { .. }
+                | Stmt::TestGroup { .. } => {}
+                Stmt::FuncDef { name, .. } if name.starts_with("test_") => {
+                    self.prelude.push(stmt.clone());
+                    let call = Expr::Call {
+                        function: Box::new(Expr::Identifier(name.clone())),
+                        args: Vec::new(),
+                    };
+                    self.tests
+                        .push(TestCase { name: name.clone(), body: vec![Stmt::ExprStmt(call)] });
+                }
+                _ => self.prelude.push(stmt.clone()),
+            }
+        }
+    }
+
     /// Run all collected tests and return a report
     pub fn run_all(&mut self, base_interp: &Interpreter) -> TestReport {
         let start_time = std::time::Instant::now();
@@ -93,6 +130,23 @@ impl TestRunner {
         // Copy environment from base interpreter (for imports, etc.)
         test_interp.env = base_interp.env.clone();
 
+        if !self.prelude.is_empty() {
+            test_interp.eval_stmts(&self.prelude);
+
+            if let Some(Value::Error(msg) | Value::ErrorObject { message: msg, .. }) =
+                &test_interp.return_value
+            {
+                return TestResult {
+                    name: name.to_string(),
+                    passed: false,
+                    message: Some(format!("Module failed to load: {}", msg)),
+                    duration_ms: start_time.elapsed().as_millis(),
+                };
+            }
+
+            test_interp.return_value = None;
+        }
+
         // Run setup if present
         if let Some(setup_stmts) = &self.setup {
             test_interp.eval_stmts(setup_stmts);
@@ -161,7 +215,7 @@ impl TestRunner {
 }
 
 /// Summary report of test execution
-#[derive(Debug)]
+#[derive(Debug, Default)]
 pub struct TestReport {
     pub total: usize,
     pub passed: usize,
@@ -181,28 +235,37 @@ impl TestReport {
 
         if verbose {
             for result in &self.results {
-                if result.passed {
-                    println!(
-                        "  {} {} ({}ms)",
-                        "✓".green().bold(),
-                        result.name.green(),
-                        result.duration_ms
-                    );
-                } else {
-                    println!(
-                        "  {} {} ({}ms)",
-                        "✗".red().bold(),
-                        result.name.red(),
-                        result.duration_ms
-                    );
-                    if let Some(msg) = &result.message {
-                        println!("    {}: {}", "Error".red().bold(), msg.dimmed());
-                    }
-                }
+                Self::print_result(result);
             }
             println!();
         }
 
+        self.print_totals();
+    }
+
+    /// Print every result from one test file, headed by its path
+    pub fn print_file_results(&self, path: &Path) {
+        use colored::Colorize;
+
+        println!("{}", path.display().to_string().bold());
+        for result in &self.results {
+            Self::print_result(result);
+        }
+    }
+
+    /// Fold another file's report into this running total
+    pub fn absorb(&mut self, other: TestReport) {
+        self.total += other.total;
+        self.passed += other.passed;
+        self.failed += other.failed;
+        self.duration_ms += other.duration_ms;
+        self.results.extend(other.results);
+    }
+
+    /// Print the pass/fail counts, elapsed time and closing verdict
+    pub fn print_totals(&self) {
+        use colored::Colorize;
+
         println!(
             "Tests: {} total, {} passed, {} failed",
             self.total,
@@ -219,6 +282,19 @@ impl TestReport {
         }
     }
 
+    fn print_result(result: &TestResult) {
+        use colored::Colorize;
+
+        if result.passed {
+            println!("  {} {} ({}ms)", "✓".green().bold(), result.name.green(), result.duration_ms);
+        } else {
+            println!("  {} {} ({}ms)", "✗".red().bold(), result.name.red(), result.duration_ms);
+            if let Some(msg) = &result.message {
+                println!("    {}: {}", "Error".red().bold(), msg.dimmed());
+            }
+        }
+    }
+
     /// Get exit code (0 for success, 1 for failure)
     pub fn exit_code(&self) -> i32 {
         if self.failed == 0 {
@@ -228,3 +304,37 @@ impl TestReport {
         }
     }
 }
+
+/// Find the test files `ruff test` should run. Files passed explicitly are kept as-is;
+/// directories are searched recursively for `*_test.ruff`, skipping hidden directories
+/// and `target/`. The result is sorted so runs are reproducible.
+pub fn discover_test_files(paths: &[PathBuf]) -> Vec<PathBuf> {
+    let mut files = Vec::new();
+    for path in paths {
+        if path.is_dir() {
+            collect_test_files(path, &mut files);
+        } else {
+            files.push(path.clone());
+        }
+    }
+    files.sort();
+    files.dedup();
+    files
+}
+
+fn collect_test_files(dir: &Path, files: &mut Vec<PathBuf>) {
+    let Ok(entries) = std::fs::read_dir(dir) else {
+        return;
+    };
+    for entry in entries.flatten() {
+        let path = entry.path();
+        let file_name = entry.file_name().to_string_lossy().to_string();
+        if path.is_dir() {
+            if !file_name.starts_with('.') && file_name != "target" {
+                collect_test_files(&path, files);
+            }
+        } else if file_name.ends_with("_test.ruff") {
+            files.push(path);
+        }
+    }
+}
@@ -3,24 +3,31 @@
 // Runtime value types for the Ruff programming language.
 // Defines all value types that can be represented and manipulated at runtime.
 
-use crate::ast::Stmt;
+use crate::ast::{Expr, InterfaceMethod, MatchLiteral, MatchPattern, Stmt};
+use crate::bigint::BigInt;
 use ahash::AHasher;
 use image::DynamicImage;
+use indexmap::IndexMap;
 use mysql_async::Conn as MysqlConn;
 use nohash_hasher::NoHashHasher;
 use postgres::Client as PostgresClient;
 use rusqlite::Connection as SqliteConnection;
-use std::collections::HashMap;
+use std::collections::{HashMap, VecDeque};
 use std::fs::File;
 use std::hash::BuildHasherDefault;
+use std::io::BufReader;
 use std::ops::Deref;
-use std::sync::atomic::{AtomicUsize, Ordering};
+use std::sync::atomic::{AtomicI64, AtomicUsize, Ordering};
 use std::sync::OnceLock;
-use std::sync::{Arc, Mutex};
+use std::sync::{Arc, Condvar, Mutex, MutexGuard};
 use zip::ZipWriter;
 
 // Forward declaration - Environment is in a sibling module
 use super::environment::Environment;
+use super::native_functions::ffi::NativeLibrary;
+use super::native_functions::net::NetSocket;
+use super::native_functions::process::ProcessHandle;
+use super::Interpreter;
 
 /// Hash map for integer-keyed dictionaries.
 pub type IntDictMap = HashMap<i64, Value, BuildHasherDefault<NoHashHasher<i64>>>;
@@ -37,6 +44,9 @@ pub type DenseIntDictIntFull = Vec<i64>;
 /// Hash map for string-keyed dictionaries.
 pub type DictMap = HashMap<Arc<str>, Value, BuildHasherDefault<AHasher>>;
 
+/// String-keyed map that remembers insertion order, backing `ordmap()` values.
+pub type OrderedDictMap = IndexMap<Arc<str>, Value, BuildHasherDefault<AHasher>>;
+
 /// Stores function bodies and drops deeply nested statement trees iteratively.
 ///
 /// Function bodies can contain very deep nesting through statements like loops,
@@ -48,11 +58,34 @@ pub type DictMap = HashMap<Arc<str>, Value, BuildHasherDefault<AHasher>>;
 /// leak-on-drop strategy with a non-recursive drop traversal.
 pub struct LeakyFunctionBody {
     id: usize,
+    /// Whether the stored signature declares defaults or a rest parameter
+    has_signature: bool,
 }
 
 #[derive(Clone)]
 struct FunctionBodyStorage {
     body: Vec<Stmt>,
+    signature: ParamSignature,
+}
+
+/// Parameter defaults and rest-parameter flag of a function, kept beside its body.
+#[derive(Clone, Default)]
+pub struct ParamSignature {
+    /// Literal default per parameter; empty when no parameter has one
+    pub param_defaults: Vec<Option<Expr>>,
+    /// True if the last parameter is `...rest`
+    pub is_variadic: bool,
+}
+
+impl ParamSignature {
+    pub fn new(param_defaults: &[Option<Expr>], is_variadic: bool) -> Self {
+        Self { param_defaults: param_defaults.to_vec(), is_variadic }
+    }
+
+    /// True when calls bind arguments one-to-one with the parameter list.
+    pub fn is_plain(&self) -> bool {
+        self.param_defaults.is_empty() && !self.is_variadic
+    }
 }
 
 struct StoredFunctionBody {
@@ -91,8 +124,8 @@ fn drop_stmt_tree_iterative(root: Vec<Stmt>) {
                 stack.extend(std::mem::take(body));
             }
             Stmt::Match { cases, default, .. } => {
-                for (_, case_body) in std::mem::take(cases) {
-                    stack.extend(case_body);
+                for case in std::mem::take(cases) {
+                    stack.extend(case.body);
                 }
                 if let Some(default_body) = default.take() {
                     stack.extend(default_body);
@@ -104,11 +137,12 @@ fn drop_stmt_tree_iterative(root: Vec<Stmt>) {
                     stack.extend(else_body);
                 }
             }
-            Stmt::TryExcept { try_block, except_block, .. } => {
+            Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
                 stack.extend(std::mem::take(try_block));
                 stack.extend(std::mem::take(except_block));
+                stack.extend(finally_block.take().into_iter().flatten());
             }
-            Stmt::Export { stmt } => {
+            Stmt::Export { stmt } | Stmt::LabeledLoop { loop_stmt: stmt, .. } => {
                 let inner_stmt = std::mem::replace(stmt, Box::new(Stmt::Block(Vec::new())));
                 stack.push(*inner_stmt);
             }
@@ -118,12 +152,16 @@ fn drop_stmt_tree_iterative(root: Vec<Stmt>) {
             Stmt::Let { .. }
             | Stmt::Const { .. }
             | Stmt::Assign { .. }
+            | Stmt::MultiAssign { .. }
             | Stmt::EnumDef { .. }
+            | Stmt::InterfaceDef { .. }
             | Stmt::ExprStmt(_)
             | Stmt::Return(_)
-            | Stmt::Break
-            | Stmt::Continue
-            | Stmt::Import { .. } => {}
+            | Stmt::Break(_)
+            | Stmt::Continue(_)
+            | Stmt::Import { .. }
+            | Stmt::ImportPath { .. }
+            | Stmt::SourcePos { .. } => {}
         }
     }
 }
@@ -132,6 +170,12 @@ pub struct FunctionBodyRef {
     storage: Arc<FunctionBodyStorage>,
 }
 
+impl FunctionBodyRef {
+    pub fn signature(&self) -> &ParamSignature {
+        &self.storage.signature
+    }
+}
+
 impl Deref for FunctionBodyRef {
     type Target = Vec<Stmt>;
 
@@ -147,7 +191,7 @@ impl Clone for LeakyFunctionBody {
             entry.refs += 1;
         }
 
-        LeakyFunctionBody { id: self.id }
+        LeakyFunctionBody { id: self.id, has_signature: self.has_signature }
     }
 }
 
@@ -166,13 +210,25 @@ impl Drop for LeakyFunctionBody {
 
 impl LeakyFunctionBody {
     pub fn new(body: Vec<Stmt>) -> Self {
+        Self::with_signature(body, ParamSignature::default())
+    }
+
+    /// Store a body together with its parameters' defaults and rest flag.
+    pub fn with_signature(body: Vec<Stmt>, signature: ParamSignature) -> Self {
         let id = NEXT_FUNCTION_BODY_ID.fetch_add(1, Ordering::Relaxed);
-        let storage = Arc::new(FunctionBodyStorage { body });
+        let has_signature = !signature.is_plain();
+        let storage = Arc::new(FunctionBodyStorage { body, signature });
 
         let mut store = function_body_store().lock().unwrap();
         store.insert(id, StoredFunctionBody { refs: 1, storage });
 
-        LeakyFunctionBody { id }
+        LeakyFunctionBody { id, has_signature }
+    }
+
+    /// True when calls must go through argument arrangement even without named
+    /// arguments, because the function declares defaults or a rest parameter.
+    pub fn has_signature(&self) -> bool {
+        self.has_signature
     }
 
     pub fn get(&self) -> FunctionBodyRef {
@@ -180,7 +236,12 @@ impl LeakyFunctionBody {
         if let Some(entry) = store.get(&self.id) {
             FunctionBodyRef { storage: Arc::clone(&entry.storage) }
         } else {
-            FunctionBodyRef { storage: Arc::new(FunctionBodyStorage { body: Vec::new() }) }
+            FunctionBodyRef {
+                storage: Arc::new(FunctionBodyStorage {
+                    body: Vec::new(),
+                    signature: ParamSignature::default(),
+                }),
+            }
         }
     }
 
@@ -196,7 +257,7 @@ mod tests {
     use std::sync::Arc;
 
     fn deeply_nested_loop_stmt(depth: usize) -> Stmt {
-        let mut current = Stmt::Break;
+        let mut current = Stmt::Break(None);
         for _ in 0..depth {
             current = Stmt::Loop { condition: None, body: vec![current] };
         }
@@ -205,13 +266,13 @@ mod tests {
 
     #[test]
     fn function_body_retains_statements() {
-        let body = LeakyFunctionBody::new(vec![Stmt::Break, Stmt::Continue]);
+        let body = LeakyFunctionBody::new(vec![Stmt::Break(None), Stmt::Continue(None)]);
         assert_eq!(2, body.get().len());
     }
 
     #[test]
     fn cloned_function_body_can_be_dropped_multiple_times() {
-        let body = LeakyFunctionBody::new(vec![Stmt::Break]);
+        let body = LeakyFunctionBody::new(vec![Stmt::Break(None)]);
         let clone = body.clone();
 
         drop(clone);
@@ -226,7 +287,7 @@ mod tests {
 
     #[test]
     fn cloned_handles_share_same_function_body_storage() {
-        let body = LeakyFunctionBody::new(vec![Stmt::Break, Stmt::Continue]);
+        let body = LeakyFunctionBody::new(vec![Stmt::Break(None), Stmt::Continue(None)]);
         let clone = body.clone();
 
         assert_eq!(body.get().len(), clone.get().len());
@@ -525,6 +586,475 @@ impl ConnectionPool {
     }
 }
 
+/// Message queue behind a `Channel` value, shared by every thread holding a clone.
+///
+/// Receivers block until a value arrives or the channel is closed. Values sent
+/// before `close` are still delivered; once drained, a closed channel yields nothing.
+pub struct ChannelState {
+    queue: Mutex<ChannelQueue>,
+    ready: Condvar,
+}
+
+#[derive(Default)]
+struct ChannelQueue {
+    values: VecDeque<Value>,
+    closed: bool,
+}
+
+/// Outcome of a non-blocking receive attempt on a channel.
+pub enum ChannelPoll {
+    Ready(Value),
+    Empty,
+    Closed,
+}
+
+impl ChannelState {
+    pub fn new() -> Self {
+        ChannelState { queue: Mutex::new(ChannelQueue::default()), ready: Condvar::new() }
+    }
+
+    fn lock_queue(&self) -> MutexGuard<'_, ChannelQueue> {
+        self.queue.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+    }
+
+    pub fn send(&self, value: Value) -> Result<(), String> {
+        let mut queue = self.lock_queue();
+        if queue.closed {
+            return Err("Cannot send on a closed channel".to_string());
+        }
+        queue.values.push_back(value);
+        self.ready.notify_one();
+        Ok(())
+    }
+
+    /// Block until a value is available; `None` once the channel is closed and drained.
+    pub fn receive(&self) -> Option<Value> {
+        let mut queue = self.lock_queue();
+        loop {
+            if let Some(value) = queue.values.pop_front() {
+                return Some(value);
+            }
+            if queue.closed {
+                return None;
+            }
+            queue = self.ready.wait(queue).unwrap_or_else(|poisoned| poisoned.into_inner());
+        }
+    }
+
+    pub fn poll(&self) -> ChannelPoll {
+        let mut queue = self.lock_queue();
+        match queue.values.pop_front() {
+            Some(value) => ChannelPoll::Ready(value),
+            None if queue.closed => ChannelPoll::Closed,
+            None => ChannelPoll::Empty,
+        }
+    }
+
+    pub fn close(&self) -> Result<(), String> {
+        let mut queue = self.lock_queue();
+        if queue.closed {
+            return Err("Channel is already closed".to_string());
+        }
+        queue.closed = true;
+        self.ready.notify_all();
+        Ok(())
+    }
+
+    pub fn is_closed(&self) -> bool {
+        self.lock_queue().closed
+    }
+}
+
+impl Default for ChannelState {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Counter behind a `WaitGroup` value: `wait` blocks until every `add` is matched by `done`.
+pub struct WaitGroupState {
+    pending: Mutex<i64>,
+    idle: Condvar,
+}
+
+impl WaitGroupState {
+    pub fn new() -> Self {
+        WaitGroupState { pending: Mutex::new(0), idle: Condvar::new() }
+    }
+
+    fn lock_pending(&self) -> MutexGuard<'_, i64> {
+        self.pending.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+    }
+
+    /// Adjust the pending count by `delta`, returning the new count.
+    pub fn add(&self, delta: i64) -> Result<i64, String> {
+        let mut pending = self.lock_pending();
+        let updated = *pending + delta;
+        if updated < 0 {
+            return Err("WaitGroup counter cannot go negative".to_string());
+        }
+        *pending = updated;
+        if updated == 0 {
+            self.idle.notify_all();
+        }
+        Ok(updated)
+    }
+
+    pub fn wait(&self) {
+        let mut pending = self.lock_pending();
+        while *pending > 0 {
+            pending = self.idle.wait(pending).unwrap_or_else(|poisoned| poisoned.into_inner());
+        }
+    }
+
+    pub fn pending(&self) -> i64 {
+        *self.lock_pending()
+    }
+}
+
+impl Default for WaitGroupState {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Lock behind a `Mutex` value. Like Go's `sync.Mutex` it has no owner: any thread may
+/// `unlock` it, and locking it again from the thread that holds it blocks forever.
+pub struct MutexState {
+    locked: Mutex<bool>,
+    released: Condvar,
+}
+
+impl MutexState {
+    pub fn new() -> Self {
+        MutexState { locked: Mutex::new(false), released: Condvar::new() }
+    }
+
+    fn lock_flag(&self) -> MutexGuard<'_, bool> {
+        self.locked.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+    }
+
+    /// Block until the mutex is free, then take it.
+    pub fn lock(&self) {
+        let mut locked = self.lock_flag();
+        while *locked {
+            locked = self.released.wait(locked).unwrap_or_else(|poisoned| poisoned.into_inner());
+        }
+        *locked = true;
+    }
+
+    /// Take the mutex if it is free, without waiting.
+    pub fn try_lock(&self) -> bool {
+        let mut locked = self.lock_flag();
+        if *locked {
+            return false;
+        }
+        *locked = true;
+        true
+    }
+
+    pub fn unlock(&self) -> Result<(), String> {
+        let mut locked = self.lock_flag();
+        if !*locked {
+            return Err("Mutex.unlock called on an unlocked mutex".to_string());
+        }
+        *locked = false;
+        self.released.notify_one();
+        Ok(())
+    }
+
+    pub fn is_locked(&self) -> bool {
+        *self.lock_flag()
+    }
+}
+
+impl Default for MutexState {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Entries behind a `SyncMap` value, keyed like dict entries (see `Value::dict_key`).
+pub struct SyncMapState {
+    entries: Mutex<DictMap>,
+}
+
+impl SyncMapState {
+    pub fn new() -> Self {
+        SyncMapState { entries: Mutex::new(DictMap::default()) }
+    }
+
+    /// The entries, held for the duration of one map operation.
+    pub fn lock_entries(&self) -> MutexGuard<'_, DictMap> {
+        self.entries.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+    }
+
+    pub fn len(&self) -> usize {
+        self.lock_entries().len()
+    }
+}
+
+impl Default for SyncMapState {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Function built by the stdlib decorators; both runtimes call it through
+/// `native_functions::decorators::call_decorated`.
+pub enum Decorated {
+    /// `retry(n)` before it is applied to a function
+    Retry { attempts: usize },
+    /// `memo(f)`: results cached by the canonical key of the arguments (see `Value::hash_key`)
+    Memo { function: Value, cache: Mutex<HashMap<String, Value>> },
+    /// `timed(f)`: writes how long each call took to stderr
+    Timed { function: Value },
+    /// `retry(n)(f)`: calls `function` again after an error, at most `attempts` times in all
+    Retrying { function: Value, attempts: usize },
+}
+
+impl Decorated {
+    /// The decorator's name, as written in source.
+    pub fn name(&self) -> &'static str {
+        match self {
+            Decorated::Memo { .. } => "memo",
+            Decorated::Timed { .. } => "timed",
+            Decorated::Retry { .. } | Decorated::Retrying { .. } => "retry",
+        }
+    }
+}
+
+/// Route table behind a `Router` value; clones share it, so `router.get(...)` registers in place.
+pub struct RouterState {
+    routes: Mutex<Vec<(String, String, Value)>>,
+}
+
+impl RouterState {
+    pub fn new() -> Self {
+        RouterState { routes: Mutex::new(Vec::new()) }
+    }
+
+    fn lock_routes(&self) -> MutexGuard<'_, Vec<(String, String, Value)>> {
+        self.routes.lock().unwrap_or_else(|poisoned| poisoned.into_inner())
+    }
+
+    pub fn add(&self, method: &str, pattern: &str, handler: Value) {
+        self.lock_routes().push((method.to_ascii_uppercase(), pattern.to_string(), handler));
+    }
+
+    pub fn route_count(&self) -> usize {
+        self.lock_routes().len()
+    }
+
+    /// Find the handler for a request. Exact paths win over `:param` patterns, matching
+    /// `HttpServer` routing; the second value holds the captured path parameters.
+    pub fn resolve(&self, method: &str, path: &str) -> Option<(Value, HashMap<String, String>)> {
+        let routes = self.lock_routes();
+        let method = method.to_ascii_uppercase();
+        let candidates = || routes.iter().filter(|(route_method, _, _)| *route_method == method);
+
+        if let Some((_, _, handler)) = candidates().find(|(_, pattern, _)| pattern == path) {
+            return Some((handler.clone(), HashMap::new()));
+        }
+        candidates().filter(|(_, pattern, _)| pattern.contains(':')).find_map(
+            |(_, pattern, handler)| {
+                crate::http_request_utils::match_http_route_pattern(pattern, path)
+                    .map(|params| (handler.clone(), params))
+            },
+        )
+    }
+}
+
+impl Default for RouterState {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+/// Lazy pipeline behind a `Sequence` value, built by the `iter` namespace.
+///
+/// Stages only record their source and callback. A `SequenceCursor` pulls one element at a
+/// time through the whole chain, so `iter.map(iter.range(1000000), f)` never builds the
+/// intermediate array. A sequence is a description, not a position: every cursor starts
+/// over, except that stages reading a generator drain that generator.
+pub enum Sequence {
+    Range {
+        start: i64,
+        end: i64,
+        step: i64,
+    },
+    /// Array, string, dict, or generator; strings yield characters and dicts yield keys.
+    Source(Value),
+    Map {
+        source: Arc<Sequence>,
+        func: Value,
+    },
+    Filter {
+        source: Arc<Sequence>,
+        predicate: Value,
+    },
+    Take {
+        source: Arc<Sequence>,
+        count: usize,
+    },
+    Zip(Arc<Sequence>, Arc<Sequence>),
+    Enumerate(Arc<Sequence>),
+}
+
+/// Engine hooks a `SequenceCursor` needs: the interpreter and the VM call user functions
+/// and resume generators differently.
+pub trait SequenceHost {
+    fn call_sequence_callback(&mut self, func: &Value, args: Vec<Value>) -> Result<Value, String>;
+
+    /// Resume `generator`, returning `None` once it is exhausted.
+    fn step_generator(&mut self, generator: &mut Value) -> Result<Option<Value>, String>;
+}
+
+/// Iteration state for one pass over a `Sequence`.
+pub enum SequenceCursor {
+    Range { next: i64, end: i64, step: i64 },
+    Items { items: Arc<Vec<Value>>, index: usize },
+    Generator(Value),
+    Map { source: Box<SequenceCursor>, func: Value },
+    Filter { source: Box<SequenceCursor>, predicate: Value },
+    Take { source: Box<SequenceCursor>, remaining: usize },
+    Zip(Box<SequenceCursor>, Box<SequenceCursor>),
+    Enumerate { source: Box<SequenceCursor>, index: i64 },
+}
+
+impl Sequence {
+    /// Wrap anything `for` can iterate. Sequences pass through unchanged and an int `n`
+    /// becomes the range `0..n`, matching `for i in n`.
+    pub fn from_iterable(value: &Value) -> Result<Arc<Sequence>, String> {
+        match value {
+            Value::Sequence(sequence) => Ok(sequence.clone()),
+            Value::Int(end) => Ok(Arc::new(Sequence::Range { start: 0, end: *end, step: 1 })),
+            Value::Array(_)
+            | Value::Str(_)
+            | Value::Dict(_)
+            | Value::FixedDict { .. }
+            | Value::OrderedDict(_)
+            | Value::Generator { .. }
+            | Value::BytecodeGenerator { .. } => Ok(Arc::new(Sequence::Source(value.clone()))),
+            other => Err(format!(
+                "Expected an iterable (array, string, dict, int range, generator, or sequence), got {}",
+                Value::type_name(other)
+            )),
+        }
+    }
+
+    pub fn cursor(&self) -> SequenceCursor {
+        match self {
+            Sequence::Range { start, end, step } => {
+                SequenceCursor::Range { next: *start, end: *end, step: *step }
+            }
+            Sequence::Source(value) => match value {
+                Value::Array(items) => SequenceCursor::Items { items: items.clone(), index: 0 },
+                Value::Str(text) => SequenceCursor::Items {
+                    items: Arc::new(
+                        text.chars().map(|ch| Value::Str(Arc::new(ch.to_string()))).collect(),
+                    ),
+                    index: 0,
+                },
+                dict @ (Value::Dict(_) | Value::FixedDict { .. } | Value::OrderedDict(_)) => {
+                    SequenceCursor::Items {
+                        items: Arc::new(dict.dict_keys_in_order().unwrap_or_default()),
+                        index: 0,
+                    }
+                }
+                generator => SequenceCursor::Generator(generator.clone()),
+            },
+            Sequence::Map { source, func } => {
+                SequenceCursor::Map { source: Box::new(source.cursor()), func: func.clone() }
+            }
+            Sequence::Filter { source, predicate } => SequenceCursor::Filter {
+                source: Box::new(source.cursor()),
+                predicate: predicate.clone(),
+            },
+            Sequence::Take { source, count } => {
+                SequenceCursor::Take { source: Box::new(source.cursor()), remaining: *count }
+            }
+            Sequence::Zip(left, right) => {
+                SequenceCursor::Zip(Box::new(left.cursor()), Box::new(right.cursor()))
+            }
+            Sequence::Enumerate(source) => {
+                SequenceCursor::Enumerate { source: Box::new(source.cursor()), index: 0 }
+            }
+        }
+    }
+
+    /// Drain a fresh cursor into an array.
+    pub fn collect(&self, host: &mut dyn SequenceHost) -> Result<Vec<Value>, String> {
+        let mut cursor = self.cursor();
+        let mut values = Vec::new();
+        while let Some(value) = cursor.next(host)? {
+            values.push(value);
+        }
+        Ok(values)
+    }
+}
+
+impl SequenceCursor {
+    pub fn next(&mut self, host: &mut dyn SequenceHost) -> Result<Option<Value>, String> {
+        match self {
+            SequenceCursor::Range { next, end, step } => {
+                let exhausted = if *step > 0 { *next >= *end } else { *next <= *end };
+                if exhausted {
+                    return Ok(None);
+                }
+                let value = *next;
+                // Saturate at `end` so a range ending near i64::MAX stops instead of wrapping.
+                *next = next.checked_add(*step).unwrap_or(*end);
+                Ok(Some(Value::Int(value)))
+            }
+            SequenceCursor::Items { items, index } => {
+                let value = items.get(*index).cloned();
+                if value.is_some() {
+                    *index += 1;
+                }
+                Ok(value)
+            }
+            SequenceCursor::Generator(generator) => host.step_generator(generator),
+            SequenceCursor::Map { source, func } => match source.next(host)? {
+                Some(value) => host.call_sequence_callback(func, vec![value]).map(Some),
+                None => Ok(None),
+            },
+            SequenceCursor::Filter { source, predicate } => {
+                while let Some(value) = source.next(host)? {
+                    if host.call_sequence_callback(predicate, vec![value.clone()])?.is_truthy() {
+                        return Ok(Some(value));
+                    }
+                }
+                Ok(None)
+            }
+            SequenceCursor::Take { source, remaining } => {
+                if *remaining == 0 {
+                    return Ok(None);
+                }
+                *remaining -= 1;
+                source.next(host)
+            }
+            SequenceCursor::Zip(left, right) => {
+                let Some(left_value) = left.next(host)? else {
+                    return Ok(None);
+                };
+                let Some(right_value) = right.next(host)? else {
+                    return Ok(None);
+                };
+                Ok(Some(Value::Array(Arc::new(vec![left_value, right_value]))))
+            }
+            SequenceCursor::Enumerate { source, index } => match source.next(host)? {
+                Some(value) => {
+                    let position = *index;
+                    *index += 1;
+                    Ok(Some(Value::Array(Arc::new(vec![Value::Int(position), value]))))
+                }
+                None => Ok(None),
+            },
+        }
+    }
+}
+
 /// Runtime values in the Ruff interpreter
 ///
 /// This enum represents all possible runtime values in Ruff. It's a large enum
@@ -535,6 +1065,9 @@ pub enum Value {
     Tagged { tag: String, fields: HashMap<String, Value> },
     /// 64-bit signed integer
     Int(i64),
+    /// Integer outside the `i64` range, produced when `Int` arithmetic
+    /// overflows. Never holds a value that fits in `Int`.
+    BigInt(Arc<BigInt>),
     /// 64-bit floating point number
     Float(f64),
     /// String value (reference-counted for cheap cloning)
@@ -554,7 +1087,7 @@ pub enum Value {
     /// Bytecode function (experimental - VM not yet default)
     #[allow(dead_code)]
     BytecodeFunction {
-        chunk: crate::bytecode::BytecodeChunk,
+        chunk: Arc<crate::bytecode::BytecodeChunk>,
         /// Captured variables with shared mutable state
         captured: HashMap<String, Arc<Mutex<Value>>>,
         /// Captured binding mutability metadata keyed by variable name.
@@ -575,6 +1108,8 @@ pub enum Value {
         stack: Vec<String>,
         line: Option<usize>,
         cause: Option<Box<Value>>,
+        /// Value thrown alongside the message (a struct, dict, or any other value)
+        payload: Option<Box<Value>>,
     },
     /// Enum type (currently unused)
     #[allow(dead_code)]
@@ -583,6 +1118,10 @@ pub enum Value {
     Struct { name: String, fields: HashMap<String, Value> },
     /// Struct definition with methods
     StructDef { name: String, field_names: Vec<String>, methods: HashMap<String, Value> },
+    /// Interface declaration: method signatures a struct must define to implement it
+    Interface { name: String, methods: Vec<InterfaceMethod> },
+    /// Namespace bound by `import "path"`: a module's exports, accessed as `name.member`
+    Module { name: String, exports: Arc<HashMap<String, Value>> },
     /// Array of values (reference-counted for cheap cloning)
     Array(Arc<Vec<Value>>),
     /// Dictionary (hash map) of string keys to values (reference-counted for cheap cloning)
@@ -597,6 +1136,8 @@ pub enum Value {
     DenseIntDictInt(Arc<DenseIntDictInt>),
     /// Dense integer dictionary for non-negative integer keys with int values only (no nulls)
     DenseIntDictIntFull(Arc<DenseIntDictIntFull>),
+    /// String-keyed map that iterates, prints, and serializes in insertion order
+    OrderedDict(Arc<OrderedDictMap>),
     /// Set of unique values
     Set(Vec<Value>),
     /// FIFO queue
@@ -604,7 +1145,29 @@ pub enum Value {
     /// LIFO stack
     Stack(Vec<Value>),
     /// Thread-safe channel for message passing
-    Channel(Arc<Mutex<(std::sync::mpsc::Sender<Value>, std::sync::mpsc::Receiver<Value>)>>),
+    Channel(Arc<ChannelState>),
+    /// Counter that lets one thread wait for a group of spawned workers
+    WaitGroup(Arc<WaitGroupState>),
+    /// Lock returned by `sync.mutex()`; clones and spawned workers share it
+    Mutex(Arc<MutexState>),
+    /// Integer counter returned by `sync.atomic()` and updated with `sync.atomic_add`
+    Atomic(Arc<AtomicI64>),
+    /// Map returned by `sync.map()` whose entries workers read and write under one lock
+    SyncMap(Arc<SyncMapState>),
+    /// Function wrapped by `memo`, `timed`, or `retry(n)`, or the decorator `retry(n)` returns
+    Decorated(Arc<Decorated>),
+    /// Request router shared between `http.serve` workers
+    Router(Arc<RouterState>),
+    /// Growable buffer returned by `strings.builder()`; clones append to the same buffer
+    StringBuilder(Arc<Mutex<String>>),
+    /// Shared library opened by `ffi.load()`, closed when the last clone is dropped
+    NativeLibrary(Arc<NativeLibrary>),
+    /// Running program started by `proc.spawn()`; clones share the same process
+    Process(Arc<ProcessHandle>),
+    /// Connection, listener, or UDP socket from the `net` namespace; clones share the socket
+    Socket(Arc<NetSocket>),
+    /// Lazy pipeline built by the `iter` namespace; see `Sequence`
+    Sequence(Arc<Sequence>),
     /// HTTP server with routes
     HttpServer {
         host: String,
@@ -632,6 +1195,8 @@ pub enum Value {
     /// Infrastructure for zip.rs stub module
     #[allow(dead_code)]
     ZipArchive { writer: Arc<Mutex<Option<ZipWriter<File>>>>, path: String },
+    /// Open file handle returned by `io_open`; `None` once the handle is closed
+    FileHandle { file: Arc<Mutex<Option<BufReader<File>>>>, path: String, mode: String },
     /// TCP listener for accepting connections
     /// Infrastructure for network.rs stub module
     #[allow(dead_code)]
@@ -655,8 +1220,8 @@ pub enum Value {
         params: Vec<String>,
         body: LeakyFunctionBody,
         env: Arc<Mutex<Environment>>,
-        pc: usize, // Program counter
-        is_exhausted: bool,
+        /// Where the body is suspended; shared by every copy of the generator
+        state: Arc<Mutex<super::generator::GeneratorResume>>,
     },
     /// Iterator instance wrapping a collection or generator
     Iterator {
@@ -766,6 +1331,7 @@ impl std::fmt::Debug for Value {
                 f.debug_struct("Tagged").field("tag", tag).field("fields", fields).finish()
             }
             Value::Int(n) => write!(f, "Int({})", n),
+            Value::BigInt(n) => write!(f, "BigInt({})", n),
             Value::Float(n) => write!(f, "Float({})", n),
             Value::Str(s) => write!(f, "Str({:?})", s.as_ref()),
             Value::Bool(b) => write!(f, "Bool({})", b),
@@ -801,12 +1367,13 @@ impl std::fmt::Debug for Value {
             Value::ArrayMarker => write!(f, "ArrayMarker"),
             Value::Return(v) => write!(f, "Return({:?})", v),
             Value::Error(e) => write!(f, "Error({})", e),
-            Value::ErrorObject { message, stack, line, cause } => f
+            Value::ErrorObject { message, stack, line, cause, payload } => f
                 .debug_struct("ErrorObject")
                 .field("message", message)
                 .field("stack", stack)
                 .field("line", line)
                 .field("cause", &cause.as_ref().map(|_| "..."))
+                .field("payload", &payload.as_ref().map(|_| "..."))
                 .finish(),
             Value::Enum(e) => write!(f, "Enum({})", e),
             Value::Struct { name, fields } => {
@@ -818,6 +1385,16 @@ impl std::fmt::Debug for Value {
                 .field("field_names", field_names)
                 .field("methods", &format!("{} methods", methods.len()))
                 .finish(),
+            Value::Interface { name, methods } => f
+                .debug_struct("Interface")
+                .field("name", name)
+                .field("methods", &format!("{} methods", methods.len()))
+                .finish(),
+            Value::Module { name, exports } => f
+                .debug_struct("Module")
+                .field("name", name)
+                .field("exports", &format!("{} exports", exports.len()))
+                .finish(),
             Value::Array(elements) => write!(f, "Array[{}]", elements.len()),
             Value::Dict(map) => write!(f, "Dict{{{} keys}}", map.len()),
             Value::FixedDict { keys, .. } => write!(f, "FixedDict{{{} keys}}", keys.len()),
@@ -827,10 +1404,28 @@ impl std::fmt::Debug for Value {
             Value::DenseIntDictIntFull(values) => {
                 write!(f, "DenseIntDictIntFull{{{} keys}}", values.len())
             }
+            Value::OrderedDict(map) => write!(f, "OrderedDict{{{} keys}}", map.len()),
             Value::Set(elements) => write!(f, "Set{{{} items}}", elements.len()),
             Value::Queue(queue) => write!(f, "Queue({} items)", queue.len()),
             Value::Stack(stack) => write!(f, "Stack({} items)", stack.len()),
             Value::Channel(_) => write!(f, "Channel"),
+            Value::WaitGroup(wait_group) => write!(f, "WaitGroup({})", wait_group.pending()),
+            Value::Mutex(mutex) => {
+                write!(f, "Mutex({})", if mutex.is_locked() { "locked" } else { "unlocked" })
+            }
+            Value::Atomic(counter) => write!(f, "Atomic({})", counter.load(Ordering::SeqCst)),
+            Value::SyncMap(map) => write!(f, "SyncMap({} keys)", map.len()),
+            Value::Decorated(decorated) => write!(f, "Decorated({})", decorated.name()),
+            Value::Router(router) => write!(f, "Router({} routes)", router.route_count()),
+            Value::StringBuilder(buffer) => write!(
+                f,
+                "StringBuilder({} bytes)",
+                buffer.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).len()
+            ),
+            Value::NativeLibrary(library) => write!(f, "NativeLibrary({})", library.path()),
+            Value::Process(process) => write!(f, "Process({})", process.label()),
+            Value::Socket(socket) => write!(f, "Socket({})", socket.label()),
+            Value::Sequence(_) => write!(f, "Sequence"),
             Value::HttpServer { host, port, routes } => {
                 write!(f, "HttpServer(host={}, port={}, {} routes)", host, port, routes.len())
             }
@@ -851,6 +1446,9 @@ impl std::fmt::Debug for Value {
             Value::ZipArchive { path, .. } => {
                 write!(f, "ZipArchive(path={})", path)
             }
+            Value::FileHandle { path, mode, .. } => {
+                write!(f, "FileHandle(path={}, mode={})", path, mode)
+            }
             Value::TcpListener { addr, .. } => {
                 write!(f, "TcpListener(addr={})", addr)
             }
@@ -877,8 +1475,10 @@ impl std::fmt::Debug for Value {
             Value::GeneratorDef(params, body) => {
                 write!(f, "GeneratorDef({:?}, {} stmts)", params, body.get().len())
             }
-            Value::Generator { params, is_exhausted, pc, .. } => {
-                write!(f, "Generator({:?}, pc={}, exhausted={})", params, pc, is_exhausted)
+            Value::Generator { params, state, .. } => {
+                let is_exhausted =
+                    state.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).is_exhausted;
+                write!(f, "Generator({:?}, exhausted={})", params, is_exhausted)
             }
             Value::Iterator { source, index, .. } => {
                 write!(f, "Iterator(source={:?}, index={})", source, index)
@@ -928,6 +1528,131 @@ impl Value {
         Value::Dict(Arc::new(map))
     }
 
+    /// Normalize a thrown value into an `ErrorObject`, shared by `throw` on both runtimes.
+    ///
+    /// Strings become the message. Structs and dicts become the payload, taking the
+    /// message (and `cause`) from their own fields. Rethrowing a caught `Error` binding
+    /// restores the original error. Any other value is both the payload and, as text,
+    /// the message. `stack` is used when the value does not carry a stack already.
+    pub fn into_thrown_error(self, stack: Vec<String>) -> Value {
+        match self {
+            Value::ErrorObject { message, stack: own_stack, line, cause, payload } => {
+                let stack = if own_stack.is_empty() { stack } else { own_stack };
+                Value::ErrorObject { message, stack, line, cause, payload }
+            }
+            Value::Error(message) => {
+                Value::ErrorObject { message, stack, line: None, cause: None, payload: None }
+            }
+            Value::Str(message) => Value::ErrorObject {
+                message: message.as_ref().clone(),
+                stack,
+                line: None,
+                cause: None,
+                payload: None,
+            },
+            Value::Struct { name, fields } if name == "Error" && fields.contains_key("stack") => {
+                Self::error_from_caught_binding(fields)
+            }
+            Value::Struct { name, fields } => {
+                let message = match fields.get("message") {
+                    Some(Value::Str(message)) => message.as_ref().clone(),
+                    _ => format!("{} error", name),
+                };
+                let cause = fields.get("cause").cloned().map(Box::new);
+                let payload = Some(Box::new(Value::Struct { name, fields }));
+                Value::ErrorObject { message, stack, line: None, cause, payload }
+            }
+            dict @ (Value::Dict(_) | Value::FixedDict { .. }) => {
+                let message = match Self::string_keyed_entry(&dict, "message") {
+                    Some(Value::Str(message)) => message.as_ref().clone(),
+                    _ => Interpreter::stringify_value(&dict),
+                };
+                let cause = Self::string_keyed_entry(&dict, "cause").cloned().map(Box::new);
+                Value::ErrorObject {
+                    message,
+                    stack,
+                    line: None,
+                    cause,
+                    payload: Some(Box::new(dict)),
+                }
+            }
+            other => Value::ErrorObject {
+                message: Interpreter::stringify_value(&other),
+                stack,
+                line: None,
+                cause: None,
+                payload: Some(Box::new(other)),
+            },
+        }
+    }
+
+    /// The `Error` struct bound to the variable of a `catch`/`except` clause.
+    ///
+    /// It always has `message`, `stack`, `line`, and `payload` (`null` when nothing was
+    /// thrown alongside the message) fields, plus `cause` when the error has one.
+    pub fn into_caught_error_binding(self) -> Value {
+        let (message, stack, line, cause, payload) = match self.into_thrown_error(Vec::new()) {
+            Value::ErrorObject { message, stack, line, cause, payload } => {
+                (message, stack, line, cause, payload)
+            }
+            _ => unreachable!("into_thrown_error always yields an ErrorObject"),
+        };
+
+        let mut fields = HashMap::new();
+        fields.insert("message".to_string(), Value::Str(Arc::new(message)));
+        fields.insert(
+            "stack".to_string(),
+            Value::Array(Arc::new(
+                stack.into_iter().map(|frame| Value::Str(Arc::new(frame))).collect(),
+            )),
+        );
+        fields.insert("line".to_string(), Value::Int(line.unwrap_or(0) as i64));
+        fields.insert("payload".to_string(), payload.map_or(Value::Null, |payload| *payload));
+        if let Some(cause) = cause {
+            fields.insert("cause".to_string(), *cause);
+        }
+        Value::Struct { name: "Error".to_string(), fields }
+    }
+
+    fn string_keyed_entry<'v>(dict: &'v Value, key: &str) -> Option<&'v Value> {
+        match dict {
+            Value::Dict(entries) => entries.get(key),
+            Value::FixedDict { keys, values } => keys
+                .iter()
+                .position(|candidate| candidate.as_ref() == key)
+                .map(|index| &values[index]),
+            _ => None,
+        }
+    }
+
+    /// Rebuild the `ErrorObject` a caught `Error` binding was made from.
+    fn error_from_caught_binding(mut fields: HashMap<String, Value>) -> Value {
+        let message = match fields.remove("message") {
+            Some(Value::Str(message)) => message.as_ref().clone(),
+            _ => "Error error".to_string(),
+        };
+        let stack = match fields.remove("stack") {
+            Some(Value::Array(frames)) => frames
+                .iter()
+                .filter_map(|frame| match frame {
+                    Value::Str(frame) => Some(frame.as_ref().clone()),
+                    _ => None,
+                })
+                .collect(),
+            _ => Vec::new(),
+        };
+        let line = match fields.remove("line") {
+            Some(Value::Int(line)) if line > 0 => Some(line as usize),
+            _ => None,
+        };
+        let cause = fields.remove("cause").map(Box::new);
+        let payload = match fields.remove("payload") {
+            None | Some(Value::Null) => None,
+            Some(payload) => Some(Box::new(payload)),
+        };
+        Value::ErrorObject { message, stack, line, cause, payload }
+    }
+
     /// Ruff runtime truthiness contract used by interpreter and VM condition evaluation.
     pub fn is_truthy(&self) -> bool {
         match self {
@@ -952,6 +1677,9 @@ impl Value {
             (Value::Float(a), Value::Float(b)) => Self::float_equals(*a, *b),
             (Value::Int(a), Value::Float(b)) => Self::float_equals(*a as f64, *b),
             (Value::Float(a), Value::Int(b)) => Self::float_equals(*a, *b as f64),
+            (Value::BigInt(a), Value::BigInt(b)) => a == b,
+            (Value::BigInt(a), Value::Float(b)) => Self::float_equals(a.to_f64(), *b),
+            (Value::Float(a), Value::BigInt(b)) => Self::float_equals(*a, b.to_f64()),
             (Value::Array(a), Value::Array(b)) => {
                 a.len() == b.len()
                     && a.iter().zip(b.iter()).all(|(lhs, rhs)| Self::equals(lhs, rhs))
@@ -994,6 +1722,26 @@ impl Value {
             | (Value::DenseIntDictIntFull(_), Value::DenseIntDictIntFull(_)) => {
                 Self::map_values_equal(left, right)
             }
+            (Value::Set(a), Value::Set(b)) => {
+                a.len() == b.len() && a.iter().all(|lhs| b.iter().any(|rhs| Self::equals(lhs, rhs)))
+            }
+            (Value::Queue(a), Value::Queue(b)) => {
+                a.len() == b.len()
+                    && a.iter().zip(b.iter()).all(|(lhs, rhs)| Self::equals(lhs, rhs))
+            }
+            (Value::Stack(a), Value::Stack(b)) => {
+                a.len() == b.len()
+                    && a.iter().zip(b.iter()).all(|(lhs, rhs)| Self::equals(lhs, rhs))
+            }
+            // Order is part of an ordered map's value, as for Python's `OrderedDict`.
+            (Value::OrderedDict(a), Value::OrderedDict(b)) => {
+                a.len() == b.len()
+                    && a.iter().zip(b.iter()).all(
+                        |((left_key, left_value), (right_key, right_value))| {
+                            left_key == right_key && Self::equals(left_value, right_value)
+                        },
+                    )
+            }
             (
                 Value::Tagged { tag: left_tag, fields: left_fields },
                 Value::Tagged { tag: right_tag, fields: right_fields },
@@ -1024,6 +1772,10 @@ impl Value {
                     && left_field_names == right_field_names
                     && Self::string_key_map_values_equal(left_methods, right_methods)
             }
+            (
+                Value::Interface { name: left_name, methods: left_methods },
+                Value::Interface { name: right_name, methods: right_methods },
+            ) => left_name == right_name && left_methods == right_methods,
             (
                 Value::Result { is_ok: left_ok, value: left_value },
                 Value::Result { is_ok: right_ok, value: right_value },
@@ -1076,6 +1828,21 @@ impl Value {
     }
 
     pub fn compare_order(left: &Value, op: &str, right: &Value) -> Result<bool, String> {
+        if let Some(ordering) = Self::big_int_ordering(left, right) {
+            return match op {
+                "<" => Ok(ordering == Some(std::cmp::Ordering::Less)),
+                ">" => Ok(ordering == Some(std::cmp::Ordering::Greater)),
+                "<=" => Ok(matches!(
+                    ordering,
+                    Some(std::cmp::Ordering::Less | std::cmp::Ordering::Equal)
+                )),
+                ">=" => Ok(matches!(
+                    ordering,
+                    Some(std::cmp::Ordering::Greater | std::cmp::Ordering::Equal)
+                )),
+                _ => Err(format!("Unknown comparison: {}", op)),
+            };
+        }
         match (left, right) {
             (Value::Int(a), Value::Int(b)) => match op {
                 "<" => Ok(a < b),
@@ -1121,6 +1888,88 @@ impl Value {
         }
     }
 
+    /// Keys of a dict-like value, in the order `keys()` and `for` visit them: insertion
+    /// order for an `ordmap` and ascending key order for every other dict. Keys are
+    /// strings except composite keys, which come back as the value that was stored.
+    pub fn dict_keys_in_order(&self) -> Option<Vec<Value>> {
+        let text = |key: &str| Value::Str(Arc::new(key.to_string()));
+        match self {
+            Value::Dict(map) => {
+                let mut keys: Vec<&Arc<str>> = map.keys().collect();
+                keys.sort();
+                Some(keys.into_iter().map(|key| Value::map_key_value(key)).collect())
+            }
+            Value::FixedDict { keys, .. } => {
+                let mut keys: Vec<&Arc<str>> = keys.iter().collect();
+                keys.sort();
+                Some(keys.into_iter().map(|key| Value::map_key_value(key)).collect())
+            }
+            Value::IntDict(map) => {
+                let mut keys: Vec<i64> = map.keys().copied().collect();
+                keys.sort();
+                Some(keys.into_iter().map(|key| text(&key.to_string())).collect())
+            }
+            Value::DenseIntDict(values) => Some(Self::dense_keys(values.len())),
+            Value::DenseIntDictInt(values) => Some(Self::dense_keys(values.len())),
+            Value::DenseIntDictIntFull(values) => Some(Self::dense_keys(values.len())),
+            Value::OrderedDict(map) => {
+                Some(map.keys().map(|key| Value::map_key_value(key)).collect())
+            }
+            _ => None,
+        }
+    }
+
+    fn dense_keys(len: usize) -> Vec<Value> {
+        (0..len).map(|key| Value::Str(Arc::new(key.to_string()))).collect()
+    }
+
+    /// Key under which `key` is stored in a string-keyed dict: strings as-is, ints in
+    /// decimal, and arrays, dicts, sets, and structs by their `composite_key_text`. Floats
+    /// are rejected so `d[1]` and `d[1.0]` cannot alias.
+    pub fn dict_key(key: &Value) -> Result<Arc<str>, String> {
+        match key {
+            Value::Str(s) => Self::check_string_key(s).map(|()| Arc::from(s.as_str())),
+            Value::Int(n) => Ok(Arc::from(n.to_string())),
+            Value::BigInt(n) => Ok(Arc::from(n.to_string())),
+            composite if composite.is_composite_key() => composite.composite_key_text(),
+            _ => Err(format!("Dict keys must be strings or ints, got {}", Self::type_name(key))),
+        }
+    }
+
+    /// Error for an index the container does not accept. Float indices get a
+    /// pointer to `int(...)` since arrays, strings, and bytes only take ints.
+    pub fn invalid_index_error(index: &Value) -> String {
+        match index {
+            Value::Float(_) => {
+                "Invalid index operation: index must be an int, got float (use int(...))"
+                    .to_string()
+            }
+            _ => "Invalid index operation".to_string(),
+        }
+    }
+
+    /// Ordering for comparisons with a `BigInt` operand; `None` when neither
+    /// side is a `BigInt`. The inner `None` marks an unordered NaN comparison.
+    fn big_int_ordering(left: &Value, right: &Value) -> Option<Option<std::cmp::Ordering>> {
+        match (left, right) {
+            (Value::BigInt(a), Value::BigInt(b)) => Some(Some(a.as_ref().cmp(b))),
+            // A BigInt lies outside the i64 range, so its sign decides.
+            (Value::BigInt(a), Value::Int(_)) => Some(Some(if a.is_negative() {
+                std::cmp::Ordering::Less
+            } else {
+                std::cmp::Ordering::Greater
+            })),
+            (Value::Int(_), Value::BigInt(b)) => Some(Some(if b.is_negative() {
+                std::cmp::Ordering::Greater
+            } else {
+                std::cmp::Ordering::Less
+            })),
+            (Value::BigInt(a), Value::Float(b)) => Some(a.to_f64().partial_cmp(b)),
+            (Value::Float(a), Value::BigInt(b)) => Some(a.partial_cmp(&b.to_f64())),
+            _ => None,
+        }
+    }
+
     fn optional_env_ptr_eq(
         left: &Option<Arc<Mutex<Environment>>>,
         right: &Option<Arc<Mutex<Environment>>>,
@@ -1132,6 +1981,215 @@ impl Value {
         }
     }
 
+    /// Builds a struct instance from a positional constructor call such as
+    /// `Point(3, 4)`; arguments fill the declared fields in order.
+    pub fn construct_struct(
+        name: &str,
+        field_names: &[String],
+        args: Vec<Value>,
+    ) -> Result<Value, String> {
+        CallableArity::exact(name, field_names.to_vec()).validate(args.len())?;
+        let fields = field_names.iter().cloned().zip(args).collect();
+        Ok(Value::Struct { name: name.to_string(), fields })
+    }
+
+    /// Checks that a struct's methods cover every method the interface bound to
+    /// `interface_name` requires, with matching argument counts. Shared by
+    /// `struct ... implements` and `implements()`.
+    pub fn check_implements(
+        struct_name: &str,
+        methods: &HashMap<String, Value>,
+        interface_name: &str,
+        interface: Option<&Value>,
+    ) -> Result<(), String> {
+        let required = match interface {
+            Some(Value::Interface { methods, .. }) => methods,
+            Some(_) => return Err(format!("'{}' is not an interface", interface_name)),
+            None => return Err(format!("Undefined interface: {}", interface_name)),
+        };
+
+        for method in required {
+            let params = match methods.get(&method.name) {
+                Some(Value::Function(params, _, _)) | Some(Value::AsyncFunction(params, _, _)) => {
+                    params
+                }
+                Some(Value::BytecodeFunction { chunk, .. }) => &chunk.params,
+                _ => {
+                    return Err(format!(
+                        "Struct '{}' does not implement interface '{}': missing method '{}'",
+                        struct_name, interface_name, method.name
+                    ))
+                }
+            };
+            let has_self = params.first().map(|param| param == "self").unwrap_or(false);
+            let found_arity = params.len() - usize::from(has_self);
+            if found_arity != method.arity() {
+                return Err(format!(
+                    "Struct '{}' does not implement interface '{}': method '{}' takes {} arguments, interface requires {}",
+                    struct_name,
+                    interface_name,
+                    method.name,
+                    found_arity,
+                    method.arity()
+                ));
+            }
+        }
+
+        Ok(())
+    }
+
+    /// Result of `implements(value, Interface)` once the engine has resolved the
+    /// value's struct definition; values that are not structs implement nothing.
+    pub fn satisfies_interface(
+        struct_def: Option<&Value>,
+        interface: &Value,
+    ) -> Result<bool, String> {
+        let Value::Interface { name: interface_name, .. } = interface else {
+            return Err("implements() expects an interface as its second argument".to_string());
+        };
+        match struct_def {
+            Some(Value::StructDef { name, methods, .. }) => {
+                Ok(Self::check_implements(name, methods, interface_name, Some(interface)).is_ok())
+            }
+            _ => Ok(false),
+        }
+    }
+
+    /// Tests `value` against a `match` case pattern, appending the names it binds.
+    /// Shared by the interpreter and the VM so both engines agree on which case wins.
+    pub fn match_pattern(
+        pattern: &MatchPattern,
+        value: &Value,
+        bindings: &mut Vec<(String, Value)>,
+    ) -> bool {
+        match pattern {
+            MatchPattern::Wildcard => true,
+            MatchPattern::Binding(name) => {
+                bindings.push((name.clone(), value.clone()));
+                true
+            }
+            MatchPattern::Literal(literal) => {
+                let expected = match literal {
+                    MatchLiteral::Int(n) => Value::Int(*n),
+                    MatchLiteral::Float(n) => Value::Float(*n),
+                    MatchLiteral::Str(text) => Value::Str(Arc::new(text.clone())),
+                    MatchLiteral::Bool(flag) => Value::Bool(*flag),
+                    MatchLiteral::Null => Value::Null,
+                };
+                Self::equals(&expected, value)
+            }
+            MatchPattern::Tag { tag, payload } => {
+                Self::match_tag_pattern(tag, payload.as_deref(), value, bindings)
+            }
+            MatchPattern::Array { elements, rest } => {
+                let Value::Array(items) = value else {
+                    return false;
+                };
+                let length_matches = match rest {
+                    Some(_) => items.len() >= elements.len(),
+                    None => items.len() == elements.len(),
+                };
+                if !length_matches
+                    || !elements
+                        .iter()
+                        .zip(items.iter())
+                        .all(|(element, item)| Self::match_pattern(element, item, bindings))
+                {
+                    return false;
+                }
+                if let Some(rest) = rest {
+                    let remaining = items[elements.len()..].to_vec();
+                    bindings.push((rest.clone(), Value::Array(Arc::new(remaining))));
+                }
+                true
+            }
+            MatchPattern::Dict { entries, rest } => {
+                let Some(mut remaining) = Self::map_entries(value) else {
+                    return false;
+                };
+                for (key, entry_pattern) in entries {
+                    let Some(index) = remaining.iter().position(|(name, _)| name == key) else {
+                        return false;
+                    };
+                    let (_, entry_value) = remaining.remove(index);
+                    if !Self::match_pattern(entry_pattern, &entry_value, bindings) {
+                        return false;
+                    }
+                }
+                if let Some(rest) = rest {
+                    let mut map = DictMap::default();
+                    for (key, entry_value) in remaining {
+                        map.insert(Arc::from(key.as_str()), entry_value);
+                    }
+                    bindings.push((rest.clone(), Value::Dict(Arc::new(map))));
+                }
+                true
+            }
+        }
+    }
+
+    /// `Ok`/`Err`/`Some`/`None` match by short or qualified name; enum variants and
+    /// legacy string tags match exactly. A binding payload on a multi-field variant
+    /// binds the extra fields as `name_1`, `name_2`, and so on.
+    fn match_tag_pattern(
+        tag: &str,
+        payload: Option<&MatchPattern>,
+        value: &Value,
+        bindings: &mut Vec<(String, Value)>,
+    ) -> bool {
+        let payload_value = match value {
+            Value::Result { is_ok, value } => {
+                let short_tag = if *is_ok { "Ok" } else { "Err" };
+                if tag != short_tag && tag != format!("Result::{}", short_tag) {
+                    return false;
+                }
+                Some((**value).clone())
+            }
+            Value::Option { is_some, value } => {
+                let short_tag = if *is_some { "Some" } else { "None" };
+                if tag != short_tag && tag != format!("Option::{}", short_tag) {
+                    return false;
+                }
+                is_some.then(|| (**value).clone())
+            }
+            Value::Tagged { tag: value_tag, fields } => {
+                if tag != value_tag {
+                    return false;
+                }
+                if let Some(MatchPattern::Binding(name)) = payload {
+                    for index in 1.. {
+                        let Some(field) = fields.get(&format!("${}", index)) else {
+                            break;
+                        };
+                        bindings.push((format!("{}_{}", name, index), field.clone()));
+                    }
+                }
+                fields.get("$0").cloned()
+            }
+            Value::Enum(value_tag) => {
+                if tag != value_tag {
+                    return false;
+                }
+                None
+            }
+            Value::Str(text) => {
+                if tag != text.as_str() {
+                    return false;
+                }
+                None
+            }
+            _ => return false,
+        };
+
+        match (payload, payload_value) {
+            (None, _) => true,
+            (Some(payload), Some(payload_value)) => {
+                Self::match_pattern(payload, &payload_value, bindings)
+            }
+            (Some(payload), None) => payload.is_irrefutable(),
+        }
+    }
+
     fn map_values_equal(left: &Value, right: &Value) -> bool {
         let Some(left_entries) = Self::map_entries(left) else {
             return false;
@@ -1152,11 +2210,14 @@ impl Value {
         })
     }
 
-    fn map_entries(value: &Value) -> Option<Vec<(String, Value)>> {
+    pub(super) fn map_entries(value: &Value) -> Option<Vec<(String, Value)>> {
         match value {
             Value::Dict(map) => Some(
                 map.iter().map(|(key, value)| (key.as_ref().to_string(), value.clone())).collect(),
             ),
+            Value::OrderedDict(map) => Some(
+                map.iter().map(|(key, value)| (key.as_ref().to_string(), value.clone())).collect(),
+            ),
             Value::FixedDict { keys, values } => Some(
                 keys.iter()
                     .zip(values.iter())
@@ -1227,7 +2288,7 @@ impl Value {
 
     fn type_name(value: &Value) -> &'static str {
         match value {
-            Value::Int(_) => "int",
+            Value::Int(_) | Value::BigInt(_) => "int",
             Value::Float(_) => "float",
             Value::Bool(_) => "bool",
             Value::Str(_) => "string",
@@ -1238,12 +2299,15 @@ impl Value {
             | Value::DenseIntDict(_)
             | Value::DenseIntDictInt(_)
             | Value::DenseIntDictIntFull(_) => "dict",
+            Value::OrderedDict(_) => "ordmap",
             Value::Struct { .. } => "struct",
             Value::Function(..)
             | Value::AsyncFunction(..)
             | Value::GeneratorDef(..)
-            | Value::Generator { .. } => "function",
+            | Value::Generator { .. }
+            | Value::Decorated(_) => "function",
             Value::NativeFunction(_) => "native_function",
+            Value::Sequence(_) => "sequence",
             Value::Null => "null",
             Value::Error(_) | Value::ErrorObject { .. } => "error",
             _ => "value",
@@ -1275,6 +2339,100 @@ impl Value {
         }
     }
 
+    /// Integer arithmetic that promotes to `BigInt` instead of overflowing.
+    /// The checked `i64` path runs first, so in-range operands never allocate.
+    pub fn promoting_int_arithmetic(left: i64, op: &str, right: i64) -> Result<Value, String> {
+        match Self::checked_int_arithmetic(left, op, right) {
+            Ok(result) => Ok(Value::Int(result)),
+            Err(_) if right != 0 && matches!(op, "+" | "-" | "*" | "/" | "%") => {
+                Self::big_int_arithmetic(&BigInt::from_i64(left), op, &BigInt::from_i64(right))
+            }
+            Err(error) => Err(error),
+        }
+    }
+
+    /// Arbitrary-precision arithmetic; results that fit are demoted to `Int`.
+    pub fn big_int_arithmetic(left: &BigInt, op: &str, right: &BigInt) -> Result<Value, String> {
+        let result = match op {
+            "+" => left.add(right),
+            "-" => left.sub(right),
+            "*" => left.mul(right),
+            "/" => left.div_rem(right).ok_or_else(|| "Division by zero".to_string())?.0,
+            "%" => left.div_rem(right).ok_or_else(|| "Modulo by zero".to_string())?.1,
+            _ => return Err(format!("Unsupported integer operator: {}", op)),
+        };
+        Ok(Value::from_big_int(result))
+    }
+
+    /// Arithmetic for operand pairs involving a `BigInt`; `None` when neither
+    /// side is one or `op` is not arithmetic. Mixing with `Float` yields `Float`.
+    pub fn big_int_binary_op(
+        left: &Value,
+        op: &str,
+        right: &Value,
+    ) -> Option<Result<Value, String>> {
+        if !matches!(op, "+" | "-" | "*" | "/" | "%") {
+            return None;
+        }
+        let result = match (left, right) {
+            (Value::BigInt(a), Value::BigInt(b)) => Self::big_int_arithmetic(a, op, b),
+            (Value::BigInt(a), Value::Int(b)) => {
+                Self::big_int_arithmetic(a, op, &BigInt::from_i64(*b))
+            }
+            (Value::Int(a), Value::BigInt(b)) => {
+                Self::big_int_arithmetic(&BigInt::from_i64(*a), op, b)
+            }
+            (Value::BigInt(a), Value::Float(b)) => {
+                Self::checked_float_arithmetic(a.to_f64(), op, *b).map(Value::Float)
+            }
+            (Value::Float(a), Value::BigInt(b)) => {
+                Self::checked_float_arithmetic(*a, op, b.to_f64()).map(Value::Float)
+            }
+            _ => return None,
+        };
+        Some(result)
+    }
+
+    /// Adds `addend` to an integer accumulator in place, promoting it to
+    /// `BigInt` on overflow. Used by fused loop-sum opcodes.
+    pub fn add_int_in_place(target: &mut Value, addend: i64) -> Result<(), String> {
+        match target {
+            Value::Int(sum) => {
+                if let Some(result) = sum.checked_add(addend) {
+                    *sum = result;
+                } else {
+                    *target = Self::promoting_int_arithmetic(*sum, "+", addend)?;
+                }
+                Ok(())
+            }
+            Value::BigInt(sum) => {
+                *target = Self::big_int_arithmetic(sum, "+", &BigInt::from_i64(addend))?;
+                Ok(())
+            }
+            _ => Err("Type mismatch in integer accumulation".to_string()),
+        }
+    }
+
+    /// Wraps a big integer, demoting it to `Int` when it fits in 64 bits.
+    pub fn from_big_int(value: BigInt) -> Value {
+        match value.to_i64() {
+            Some(small) => Value::Int(small),
+            None => Value::BigInt(Arc::new(value)),
+        }
+    }
+
+    /// Integer negation that promotes `-i64::MIN` to `BigInt`.
+    pub fn negate_int(value: &Value) -> Option<Value> {
+        match value {
+            Value::Int(n) => Some(match n.checked_neg() {
+                Some(result) => Value::Int(result),
+                None => Value::from_big_int(BigInt::from_i64(*n).neg()),
+            }),
+            Value::BigInt(n) => Some(Value::from_big_int(n.neg())),
+            _ => None,
+        }
+    }
+
     /// Float arithmetic semantics for Ruff runtime operations.
     pub fn checked_float_arithmetic(left: f64, op: &str, right: f64) -> Result<f64, String> {
         match op {
@@ -1299,6 +2457,16 @@ impl Value {
         }
     }
 
+    /// Display form of a float. Integral values keep a `.0` suffix so a
+    /// float never prints like an int (`3.0` versus `3`).
+    pub fn format_float(value: f64) -> String {
+        if value.is_finite() && value.fract() == 0.0 && value.abs() < 1e16 {
+            format!("{:.1}", value)
+        } else {
+            value.to_string()
+        }
+    }
+
     /// Float equality semantics:
     /// - NaN is never equal to any value (including itself)
     /// - infinities compare by exact IEEE sign/value
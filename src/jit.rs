@@ -8029,6 +8029,12 @@ impl JitCompiler {
     /// Check if a function can be JIT-compiled
     /// Returns true if all opcodes in the function are supported
     pub fn can_compile_function(&self, chunk: &BytecodeChunk) -> bool {
+        // Compiled code binds arguments by position, so defaults and rest
+        // parameters stay on the interpreter path that arranges them
+        if chunk.is_variadic || !chunk.param_defaults.is_empty() {
+            return false;
+        }
+
         for instr in &chunk.instructions {
             if !self.is_supported_opcode(instr, &chunk.constants) {
                 return false;
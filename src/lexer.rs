@@ -41,6 +41,8 @@ pub struct Token {
     pub column: usize,
     #[allow(dead_code)]
     pub byte_offset: usize,
+    /// Line of the token's last character; differs from `line` only for multi-line strings.
+    pub end_line: usize,
 }
 
 #[derive(Debug, Clone, PartialEq, Eq)]
@@ -84,6 +86,26 @@ impl LexerDiagnosticKind {
     }
 }
 
+impl LexerDiagnosticKind {
+    fn hint(&self) -> &'static str {
+        match self {
+            LexerDiagnosticKind::InvalidCharacter => {
+                "Remove the character, or move it inside a string literal or comment."
+            }
+            LexerDiagnosticKind::UnterminatedString => {
+                "Close the string with a matching quote before the end of the file."
+            }
+            LexerDiagnosticKind::UnterminatedComment => {
+                "Close the block comment with '*/' before the end of the file."
+            }
+            LexerDiagnosticKind::InvalidEscape => {
+                "Use one of the supported escapes: \\n, \\t, \\r, \\\\, \\\", or \\$."
+            }
+            _ => "Fix the lexical error in source and run again.",
+        }
+    }
+}
+
 impl LexerDiagnostic {
     pub fn diagnostic_code(&self) -> String {
         let prefix = DIAGNOSTIC_CODE_LEXER.trim_end_matches("001");
@@ -97,15 +119,30 @@ impl LexerDiagnostic {
             DiagnosticSubsystem::Lexer,
             self.message.clone(),
         )
-        .with_help("Fix the lexical error in source and run again.")
+        .with_help(self.kind.hint())
         .with_location(self.file.clone(), self.line, self.column)
     }
 }
 
+/// A source comment (`# ...`, `// ...`, or `/* ... */`).
+///
+/// Comments never reach the parser; they are collected on the side so tools that re-emit
+/// source (the formatter) can put them back.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Comment {
+    /// Comment text including its markers, without the line break that ends it.
+    pub text: String,
+    pub line: usize,
+    /// Last line the comment covers; differs from `line` only for block comments.
+    pub end_line: usize,
+    pub byte_offset: usize,
+}
+
 #[derive(Debug, Clone)]
 pub struct LexOutput {
     pub tokens: Vec<Token>,
     pub diagnostics: Vec<LexerDiagnostic>,
+    pub comments: Vec<Comment>,
 }
 
 impl LexOutput {
@@ -144,6 +181,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
 
     let mut tokens = Vec::new();
     let mut diagnostics = Vec::new();
+    let mut comments = Vec::new();
     let mut idx = 0usize;
     let mut line = 1usize;
     let mut col = 1usize;
@@ -178,7 +216,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
         column: usize,
         byte_offset: usize,
     ) {
-        tokens.push(Token { kind, line, column, byte_offset });
+        tokens.push(Token { kind, line, column, byte_offset, end_line: line });
     }
 
     fn bump(chars: &[char], idx: &mut usize) -> Option<char> {
@@ -235,6 +273,9 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                 );
             }
             '#' => {
+                let start_line = line;
+                let start_offset = current_offset(&offsets, idx, source.len());
+                let mut text = String::new();
                 while let Some(ch) = peek(&chars, idx) {
                     bump(&chars, &mut idx);
                     advance_position(ch, &mut line, &mut col);
@@ -244,14 +285,33 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                         }
                         break;
                     }
+                    text.push(ch);
                 }
+                comments.push(Comment {
+                    text,
+                    line: start_line,
+                    end_line: start_line,
+                    byte_offset: start_offset,
+                });
             }
-            '"' => {
+            '"' | 'r' if c == '"' || peek(&chars, idx + 1) == Some('"') => {
                 let start_line = line;
                 let start_col = col;
                 let start_offset = current_offset(&offsets, idx, source.len());
-                bump(&chars, &mut idx);
-                advance_position('"', &mut line, &mut col);
+                // `r"..."` keeps backslashes and `${` as written
+                let raw = c == 'r';
+                if raw {
+                    bump(&chars, &mut idx);
+                    advance_position('r', &mut line, &mut col);
+                }
+                // `"""..."""` may span lines and contain unescaped quotes
+                let triple =
+                    peek(&chars, idx + 1) == Some('"') && peek(&chars, idx + 2) == Some('"');
+                let quote_len = if triple { 3 } else { 1 };
+                for _ in 0..quote_len {
+                    bump(&chars, &mut idx);
+                    advance_position('"', &mut line, &mut col);
+                }
 
                 let mut parts = Vec::new();
                 let mut current_text = String::new();
@@ -261,14 +321,21 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                 let mut string_too_long_reported = false;
 
                 while let Some(ch) = peek(&chars, idx) {
-                    if ch == '"' {
-                        bump(&chars, &mut idx);
-                        advance_position(ch, &mut line, &mut col);
+                    let closes = ch == '"'
+                        && (!triple
+                            || (peek(&chars, idx + 1) == Some('"')
+                                && peek(&chars, idx + 2) == Some('"')
+                                && peek(&chars, idx + 3) != Some('"')));
+                    if closes {
+                        for _ in 0..quote_len {
+                            bump(&chars, &mut idx);
+                            advance_position('"', &mut line, &mut col);
+                        }
                         terminated = true;
                         break;
                     }
 
-                    if ch == '\\' {
+                    if ch == '\\' && !raw {
                         bump(&chars, &mut idx);
                         advance_position('\\', &mut line, &mut col);
                         let escape_line = line;
@@ -310,7 +377,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                             );
                             break;
                         }
-                    } else if ch == '$' && peek(&chars, idx + 1) == Some('{') {
+                    } else if ch == '$' && !raw && peek(&chars, idx + 1) == Some('{') {
                         has_interpolation = true;
                         bump(&chars, &mut idx);
                         advance_position('$', &mut line, &mut col);
@@ -324,10 +391,25 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                         let mut expr = String::new();
                         let mut brace_depth = 1usize;
                         let mut interpolation_closed = false;
+                        let mut in_string = false;
                         while let Some(inner) = peek(&chars, idx) {
                             bump(&chars, &mut idx);
                             advance_position(inner, &mut line, &mut col);
-                            if inner == '{' {
+                            // Braces inside a nested string literal do not count
+                            if in_string {
+                                expr.push(inner);
+                                if inner == '\\' {
+                                    if let Some(escaped) = bump(&chars, &mut idx) {
+                                        advance_position(escaped, &mut line, &mut col);
+                                        expr.push(escaped);
+                                    }
+                                } else if inner == '"' {
+                                    in_string = false;
+                                }
+                            } else if inner == '"' {
+                                in_string = true;
+                                expr.push(inner);
+                            } else if inner == '{' {
                                 brace_depth += 1;
                                 expr.push(inner);
                             } else if inner == '}' {
@@ -357,7 +439,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                         }
 
                         parts.push(InterpolatedPart::Expression(expr));
-                    } else if ch == '\n' || ch == '\r' {
+                    } else if (ch == '\n' || ch == '\r') && !triple {
                         string_error = true;
                         push_diag(
                             &mut diagnostics,
@@ -372,7 +454,15 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                     } else {
                         bump(&chars, &mut idx);
                         advance_position(ch, &mut line, &mut col);
-                        current_text.push(ch);
+                        if ch == '\r' {
+                            // Multi-line strings hold `\n` line breaks whatever the file uses
+                            if peek(&chars, idx) == Some('\n') {
+                                bump(&chars, &mut idx);
+                            }
+                            current_text.push('\n');
+                        } else {
+                            current_text.push(ch);
+                        }
 
                         if current_text.chars().count() > MAX_STRING_LITERAL_LENGTH
                             && !string_too_long_reported
@@ -439,6 +529,9 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                             start_offset,
                         );
                     }
+                    if let Some(token) = tokens.last_mut() {
+                        token.end_line = line;
+                    }
                 }
             }
             '0'..='9' => {
@@ -614,8 +707,8 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                     "let" | "mut" | "const" | "func" | "return" | "enum" | "match" | "case"
                     | "default" | "if" | "else" | "loop" | "while" | "for" | "in" | "break"
                     | "continue" | "try" | "except" | "int" | "float" | "string" | "bool"
-                    | "import" | "export" | "from" | "struct" | "impl" | "self" | "null"
-                    | "spawn" | "test" | "test_setup" | "test_teardown" | "test_group"
+                    | "import" | "export" | "from" | "struct" | "interface" | "impl" | "self"
+                    | "null" | "spawn" | "test" | "test_setup" | "test_teardown" | "test_group"
                     | "yield" | "async" | "await" => TokenKind::Keyword(ident),
                     "true" => TokenKind::Bool(true),
                     "false" => TokenKind::Bool(false),
@@ -798,6 +891,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                     advance_position('*', &mut line, &mut col);
 
                     let mut found_end = false;
+                    let mut text = String::from("/*");
                     while let Some(ch) = peek(&chars, idx) {
                         bump(&chars, &mut idx);
                         advance_position(ch, &mut line, &mut col);
@@ -805,15 +899,30 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                         if ch == '*' && peek(&chars, idx) == Some('/') {
                             bump(&chars, &mut idx);
                             advance_position('/', &mut line, &mut col);
+                            text.push_str("*/");
                             found_end = true;
                             break;
                         }
 
-                        if ch == '\r' && peek(&chars, idx) == Some('\n') {
-                            bump(&chars, &mut idx);
+                        if ch == '\r' {
+                            if peek(&chars, idx) == Some('\n') {
+                                bump(&chars, &mut idx);
+                            }
+                            text.push('\n');
+                        } else {
+                            text.push(ch);
                         }
                     }
 
+                    if found_end {
+                        comments.push(Comment {
+                            text,
+                            line: start_line,
+                            end_line: line,
+                            byte_offset: start_offset,
+                        });
+                    }
+
                     if !found_end {
                         push_diag(
                             &mut diagnostics,
@@ -828,6 +937,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                 } else if peek(&chars, idx) == Some('/') {
                     bump(&chars, &mut idx);
                     advance_position('/', &mut line, &mut col);
+                    let mut text = String::from("//");
                     while let Some(ch) = peek(&chars, idx) {
                         bump(&chars, &mut idx);
                         advance_position(ch, &mut line, &mut col);
@@ -837,7 +947,14 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                             }
                             break;
                         }
+                        text.push(ch);
                     }
+                    comments.push(Comment {
+                        text,
+                        line: start_line,
+                        end_line: start_line,
+                        byte_offset: start_offset,
+                    });
                 } else {
                     push_token(
                         &mut tokens,
@@ -981,7 +1098,7 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
                     );
                 }
             }
-            '(' | ')' | '{' | '}' | '[' | ']' | ',' | ';' => {
+            '(' | ')' | '{' | '}' | '[' | ']' | ',' | ';' | '@' => {
                 let start_line = line;
                 let start_col = col;
                 let start_offset = current_offset(&offsets, idx, source.len());
@@ -1016,23 +1133,24 @@ fn tokenize_with_diagnostics_and_file(source: &str, file: Option<&str>) -> LexOu
 
     push_token(&mut tokens, TokenKind::Eof, line, col, source.len());
 
-    LexOutput { tokens, diagnostics }
+    LexOutput { tokens, diagnostics, comments }
 }
 
 #[cfg(test)]
 mod tests {
     use super::{
-        tokenize, tokenize_with_diagnostics, tokenize_with_file, LexerDiagnosticKind, TokenKind,
-        MAX_IDENTIFIER_LENGTH, MAX_NUMERIC_LITERAL_LENGTH, MAX_STRING_LITERAL_LENGTH,
+        tokenize, tokenize_with_diagnostics, tokenize_with_file, InterpolatedPart,
+        LexerDiagnosticKind, TokenKind, MAX_IDENTIFIER_LENGTH, MAX_NUMERIC_LITERAL_LENGTH,
+        MAX_STRING_LITERAL_LENGTH,
     };
 
     #[test]
     fn invalid_character_reports_diagnostic() {
-        let result = tokenize("let x := @");
+        let result = tokenize("let x := $");
         let diagnostics = result.expect_err("expected lexical diagnostics");
         assert!(diagnostics
             .iter()
-            .any(|d| d.kind == LexerDiagnosticKind::InvalidCharacter && d.message.contains("@")));
+            .any(|d| d.kind == LexerDiagnosticKind::InvalidCharacter && d.message.contains("$")));
     }
 
     #[test]
@@ -1049,6 +1167,64 @@ mod tests {
         assert!(diagnostics.iter().any(|d| d.kind == LexerDiagnosticKind::UnterminatedComment));
     }
 
+    #[test]
+    fn comments_are_collected_beside_the_token_stream() {
+        let output =
+            tokenize_with_diagnostics("# header\nx := 1 // trailing\r\n/* one\r\ntwo */ y := 2\n");
+        assert!(output.diagnostics.is_empty());
+        assert_eq!(
+            output
+                .comments
+                .iter()
+                .map(|c| (c.text.as_str(), c.line, c.end_line))
+                .collect::<Vec<_>>(),
+            vec![("# header", 1, 1), ("// trailing", 2, 2), ("/* one\ntwo */", 3, 4)]
+        );
+        assert!(output
+            .tokens
+            .iter()
+            .any(|t| t.kind == TokenKind::Identifier("y".into()) && t.line == 4));
+    }
+
+    #[test]
+    fn raw_and_triple_quoted_strings_tokenize() {
+        let tokens = tokenize(
+            "a := r\"C:\\new\\${x}\"\nb := \"\"\"one\r\n\"two\"\"\"\"\nc := r\"\"\"\\d+ \"q\"\"\"\"\nd := 1",
+        )
+        .expect("strings should tokenize");
+        let strings: Vec<(String, usize, usize)> = tokens
+            .iter()
+            .filter_map(|token| match &token.kind {
+                TokenKind::String(text) => Some((text.clone(), token.line, token.end_line)),
+                _ => None,
+            })
+            .collect();
+        assert_eq!(
+            strings,
+            vec![
+                ("C:\\new\\${x}".to_string(), 1, 1),
+                ("one\n\"two\"".to_string(), 2, 3),
+                ("\\d+ \"q\"".to_string(), 4, 4),
+            ]
+        );
+        assert!(tokens.iter().any(|t| t.kind == TokenKind::Identifier("d".into()) && t.line == 5));
+        assert!(tokenize("x := r\"unterminated").is_err());
+        assert!(tokenize("x := \"\"\"open\nforever").is_err());
+    }
+
+    #[test]
+    fn interpolation_expressions_may_contain_string_literals() {
+        let tokens = tokenize("\"a ${join(xs, \"}\")} b\"").expect("string should tokenize");
+        assert_eq!(
+            tokens[0].kind,
+            TokenKind::InterpolatedString(vec![
+                InterpolatedPart::Text("a ".to_string()),
+                InterpolatedPart::Expression("join(xs, \"}\")".to_string()),
+                InterpolatedPart::Text(" b".to_string()),
+            ])
+        );
+    }
+
     #[test]
     fn invalid_escape_reports_diagnostic() {
         let result = tokenize("let x := \"bad\\q\"");
@@ -1088,7 +1264,7 @@ mod tests {
 
     #[test]
     fn mixed_line_endings_preserve_line_column() {
-        let output = tokenize_with_diagnostics("ok\r\n@\rnext\n@");
+        let output = tokenize_with_diagnostics("ok\r\n$\rnext\n$");
         let invalids: Vec<_> = output
             .diagnostics
             .iter()
@@ -6,12 +6,16 @@
 
 pub mod ast;
 pub mod benchmarks;
+pub mod bigint;
 pub mod builtins;
+pub mod bundle;
 pub mod bytecode;
+pub mod bytecode_cache;
 pub mod cli_output;
 pub mod compiler;
 pub mod doc_generator;
 pub mod docgen;
+pub mod embed;
 pub mod errors;
 pub mod formatter;
 pub mod http_request_utils;
@@ -22,6 +26,7 @@ pub mod jit;
 #[path = "jit_disabled.rs"]
 pub mod jit;
 pub mod lexer;
+pub mod line_mode;
 pub mod linter;
 pub mod lsp_code_actions;
 pub mod lsp_completion;
@@ -37,10 +42,16 @@ pub mod optimizer;
 pub mod package_workflow;
 pub mod parser;
 pub mod path_security;
+pub mod playground;
 pub mod repl;
 pub mod reserved_names;
 pub mod runtime_limits;
 pub mod serve_http;
+pub mod syntax_tree;
 pub mod type_checker;
 pub mod vm;
+pub mod wasm;
+pub mod watch;
 pub mod workflow_pack;
+
+pub use embed::{from_value, to_value, Ruff, Variadic};
@@ -0,0 +1,244 @@
+// File: src/line_mode.rs
+//
+// Streaming line-transform mode behind `ruff -e 'EXPR'`.
+//
+// The expression program is parsed once and evaluated for every line of the input
+// with a persistent interpreter, so bindings created by `--begin` or by earlier lines
+// stay visible to later lines and to `--end`.
+
+use crate::ast::Stmt;
+use crate::interpreter::{Interpreter, Value};
+use crate::lexer;
+use crate::parser::Parser;
+use std::io::{BufRead, Write};
+use std::sync::Arc;
+
+/// What to do when evaluating the per-line program fails at runtime.
+#[derive(Clone, Copy, Debug, PartialEq, Eq)]
+pub enum LineErrorPolicy {
+    /// Stop at the first failing line (default).
+    Abort,
+    /// Report the failing line on stderr and continue with the next one.
+    Skip,
+}
+
+/// Options for one line-mode invocation.
+#[derive(Clone, Debug)]
+pub struct LineModeOptions {
+    /// Program evaluated for each input line.
+    pub expr: String,
+    /// Program evaluated once before the first line.
+    pub begin: Option<String>,
+    /// Program evaluated once after the last line.
+    pub end: Option<String>,
+    /// Suppress printing the per-line result (`-n`).
+    pub no_print: bool,
+    pub on_error: LineErrorPolicy,
+}
+
+/// Failure categories so the CLI can map them to exit codes.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum LineModeError {
+    /// `-e`, `--begin`, or `--end` source failed to lex or parse.
+    Parse(String),
+    /// Runtime failure in `--begin`, `--end`, or (under `Abort`) a line program.
+    Runtime(String),
+    /// Reading input or writing output failed.
+    Io(String),
+}
+
+/// Counters reported after a successful run.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct LineModeSummary {
+    pub lines_read: usize,
+    pub lines_skipped: usize,
+}
+
+fn parse_program(label: &str, source: &str) -> Result<Vec<Stmt>, LineModeError> {
+    let tokens = lexer::tokenize(source).map_err(|diagnostics| {
+        let message = diagnostics
+            .first()
+            .map(|diagnostic| diagnostic.message.clone())
+            .unwrap_or_else(|| "unknown lexer error".to_string());
+        LineModeError::Parse(format!("{}: {}", label, message))
+    })?;
+
+    let output = Parser::new(tokens).parse_with_diagnostics();
+    if let Some(diagnostic) = output.diagnostics.first() {
+        return Err(LineModeError::Parse(format!("{}: {}", label, diagnostic.message)));
+    }
+
+    Ok(output.stmts)
+}
+
+/// Evaluate a program and return the value of its trailing expression statement, if any.
+fn eval_program(interpreter: &mut Interpreter, stmts: &[Stmt]) -> Result<Option<Value>, String> {
+    let mut last_value = None;
+
+    for stmt in stmts {
+        match stmt {
+            Stmt::ExprStmt(expr) => {
+                last_value =
+                    Some(interpreter.eval_expr_repl(expr).map_err(|error| error.message.clone())?);
+            }
+            _ => {
+                interpreter.eval_stmt_repl(stmt).map_err(|error| error.message.clone())?;
+                last_value = None;
+            }
+        }
+    }
+
+    Ok(last_value)
+}
+
+fn bind_line_variables(interpreter: &mut Interpreter, line: &str, line_number: usize) {
+    let fields: Vec<Value> =
+        line.split_whitespace().map(|field| Value::Str(Arc::new(field.to_string()))).collect();
+
+    interpreter.env.set("line".to_string(), Value::Str(Arc::new(line.to_string())));
+    interpreter.env.set("line_number".to_string(), Value::Int(line_number as i64));
+    interpreter.env.set("fields".to_string(), Value::Array(Arc::new(fields)));
+}
+
+/// Run line mode over `input`, writing auto-printed results to `output`.
+///
+/// Per-line results are printed with the same rendering as `print(...)`; `null`
+/// results are never printed, so side-effect-only programs stay quiet without `-n`.
+pub fn run_line_mode<R: BufRead, W: Write>(
+    options: &LineModeOptions,
+    interpreter: &mut Interpreter,
+    input: R,
+    output: &mut W,
+) -> Result<LineModeSummary, LineModeError> {
+    let line_program = parse_program("-e", &options.expr)?;
+    let begin_program = options.begin.as_deref().map(|src| parse_program("--begin", src));
+    let begin_program = begin_program.transpose()?;
+    let end_program = options.end.as_deref().map(|src| parse_program("--end", src));
+    let end_program = end_program.transpose()?;
+
+    interpreter.set_source("<line-mode>".to_string(), &options.expr);
+
+    if let Some(stmts) = &begin_program {
+        eval_program(interpreter, stmts)
+            .map_err(|message| LineModeError::Runtime(format!("--begin: {}", message)))?;
+    }
+
+    let mut summary = LineModeSummary::default();
+    for line in input.lines() {
+        let line = line.map_err(|error| LineModeError::Io(error.to_string()))?;
+        summary.lines_read += 1;
+        bind_line_variables(interpreter, &line, summary.lines_read);
+
+        match eval_program(interpreter, &line_program) {
+            Ok(Some(value)) if !options.no_print && !matches!(value, Value::Null) => {
+                writeln!(output, "{}", Interpreter::stringify_value(&value))
+                    .map_err(|error| LineModeError::Io(error.to_string()))?;
+            }
+            Ok(_) => {}
+            Err(message) => {
+                let message = format!("line {}: {}", summary.lines_read, message);
+                match options.on_error {
+                    LineErrorPolicy::Abort => return Err(LineModeError::Runtime(message)),
+                    LineErrorPolicy::Skip => {
+                        eprintln!("Warning: skipped {}", message);
+                        summary.lines_skipped += 1;
+                    }
+                }
+            }
+        }
+    }
+
+    if let Some(stmts) = &end_program {
+        match eval_program(interpreter, stmts) {
+            Ok(Some(value)) if !matches!(value, Value::Null) => {
+                writeln!(output, "{}", Interpreter::stringify_value(&value))
+                    .map_err(|error| LineModeError::Io(error.to_string()))?;
+            }
+            Ok(_) => {}
+            Err(message) => return Err(LineModeError::Runtime(format!("--end: {}", message))),
+        }
+    }
+
+    output.flush().map_err(|error| LineModeError::Io(error.to_string()))?;
+    Ok(summary)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn options(expr: &str) -> LineModeOptions {
+        LineModeOptions {
+            expr: expr.to_string(),
+            begin: None,
+            end: None,
+            no_print: false,
+            on_error: LineErrorPolicy::Abort,
+        }
+    }
+
+    fn run(
+        options: &LineModeOptions,
+        input: &str,
+    ) -> (Result<LineModeSummary, LineModeError>, String) {
+        let mut interpreter = Interpreter::new();
+        let mut output = Vec::new();
+        let result = run_line_mode(options, &mut interpreter, input.as_bytes(), &mut output);
+        (result, String::from_utf8(output).expect("line mode output should be utf-8"))
+    }
+
+    #[test]
+    fn prints_expression_result_per_line() {
+        let (result, output) = run(&options("to_upper(line)"), "alpha\nbeta\n");
+        assert_eq!(result, Ok(LineModeSummary { lines_read: 2, lines_skipped: 0 }));
+        assert_eq!(output, "ALPHA\nBETA\n");
+    }
+
+    #[test]
+    fn exposes_line_number_and_fields() {
+        let (_, output) = run(&options(r#""${line_number}:${fields[1]}""#), "a b\nc d e\n");
+        assert_eq!(output, "1:b\n2:d\n");
+    }
+
+    #[test]
+    fn begin_and_end_share_state_with_line_program() {
+        let mut opts = options("total := total + len(fields)");
+        opts.begin = Some("total := 0".to_string());
+        opts.end = Some("total".to_string());
+        opts.no_print = true;
+
+        let (result, output) = run(&opts, "a b\nc\n\nd e f\n");
+        assert!(result.is_ok(), "unexpected failure: {:?}", result);
+        assert_eq!(output, "6\n");
+    }
+
+    #[test]
+    fn null_results_are_not_printed() {
+        let (_, output) = run(&options(r#"if contains(line, "x") { print("seen") }"#), "x\ny\n");
+        assert_eq!(output, "");
+    }
+
+    #[test]
+    fn runtime_error_aborts_by_default_and_skips_on_request() {
+        let (result, output) = run(&options("parse_int(line) * 2"), "1\nnope\n3\n");
+        assert!(
+            matches!(&result, Err(LineModeError::Runtime(message)) if message.starts_with("line 2:")),
+            "unexpected result: {:?}",
+            result
+        );
+        assert_eq!(output, "2\n");
+
+        let mut opts = options("parse_int(line) * 2");
+        opts.on_error = LineErrorPolicy::Skip;
+        let (result, output) = run(&opts, "1\nnope\n3\n");
+        assert_eq!(result, Ok(LineModeSummary { lines_read: 3, lines_skipped: 1 }));
+        assert_eq!(output, "2\n6\n");
+    }
+
+    #[test]
+    fn parse_errors_are_reported_before_reading_input() {
+        let (result, output) = run(&options("(line"), "a\n");
+        assert!(matches!(result, Err(LineModeError::Parse(message)) if message.starts_with("-e:")));
+        assert_eq!(output, "");
+    }
+}
@@ -1,6 +1,23 @@
-use crate::lexer::{self, TokenKind};
+// File: src/linter.rs
+//
+// Rule-based linter behind `ruff lint`.
+//
+// A `Linter` lexes and parses the source once and hands every rule a `LintContext` with the
+// text, the tokens, and the syntax tree. Rules implement `LintRule`, so embedders and future
+// built-ins plug in without touching the driver. A rule may attach a `LintFix` that replaces
+// one source line; `ruff lint --fix` applies only those mechanical rewrites.
+
+use crate::ast::{
+    ArrayElement, DictElement, Expr, InterpolatedStringPart, MatchCase, MatchLiteral, MatchPattern,
+    Pattern, Stmt, TypeAnnotation,
+};
+use crate::errors::SourceSpan;
+use crate::formatter::recorded_statement_spans;
+use crate::interpreter::Interpreter;
+use crate::lexer::{self, Token, TokenKind};
+use crate::parser::Parser;
 use regex::Regex;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 
 #[derive(Debug, Clone, PartialEq, Eq)]
 pub enum LintSeverity {
@@ -33,14 +50,171 @@ pub struct LintIssue {
     pub fix: Option<LintFix>,
 }
 
+impl LintIssue {
+    fn warning(rule_id: &str, (line, column): (usize, usize), message: String) -> Self {
+        LintIssue {
+            rule_id: rule_id.to_string(),
+            line,
+            column,
+            severity: LintSeverity::Warning,
+            message,
+            fix: None,
+        }
+    }
+}
+
+/// The source file a rule inspects.
+pub struct LintContext<'a> {
+    pub source: &'a str,
+    /// Tokens of the source; empty when it does not lex.
+    pub tokens: &'a [Token],
+    /// Top-level statements; `None` when the source does not lex or parse. The linter
+    /// reports those errors itself, so rules that need the tree can return nothing.
+    pub program: Option<&'a [Stmt]>,
+    spans: HashMap<*const Stmt, SourceSpan>,
+}
+
+impl<'a> LintContext<'a> {
+    /// The span the parser recorded for `stmt`, a statement of `program`.
+    pub fn span(&self, stmt: &Stmt) -> Option<&SourceSpan> {
+        self.spans.get(&(stmt as *const Stmt))
+    }
+
+    /// 1-based line and column where `stmt` starts, or the top of the file when unknown.
+    pub fn position(&self, stmt: &Stmt) -> (usize, usize) {
+        self.span(stmt).map_or((1, 1), |span| (span.start.line, span.start.column))
+    }
+
+    /// Text of the 1-based `line`, without its line ending.
+    pub fn line(&self, line: usize) -> Option<&'a str> {
+        self.source.lines().nth(line.checked_sub(1)?)
+    }
+}
+
+/// A check run over every linted file.
+pub trait LintRule: Send + Sync {
+    /// Identifier reported as the issue's `rule_id` and accepted by `ruff lint --ignore`.
+    fn id(&self) -> &'static str;
+    fn check(&self, context: &LintContext<'_>) -> Vec<LintIssue>;
+}
+
+#[derive(Clone, Copy)]
+struct BuiltinRule {
+    id: &'static str,
+    check: fn(&LintContext<'_>) -> Vec<LintIssue>,
+}
+
+impl LintRule for BuiltinRule {
+    fn id(&self) -> &'static str {
+        self.id
+    }
+
+    fn check(&self, context: &LintContext<'_>) -> Vec<LintIssue> {
+        (self.check)(context)
+    }
+}
+
+const BUILTIN_RULES: &[BuiltinRule] = &[
+    BuiltinRule { id: "unused-variable", check: check_unused_variables },
+    BuiltinRule { id: "unreachable-code", check: check_unreachable_code },
+    BuiltinRule { id: "shadowed-builtin", check: check_shadowed_builtins },
+    BuiltinRule { id: "suspicious-equality", check: check_suspicious_equality },
+    BuiltinRule { id: "empty-block", check: check_empty_blocks },
+    BuiltinRule { id: "obvious-type-mismatch", check: check_obvious_type_mismatches },
+    BuiltinRule {
+        id: "missing-error-handling-pattern",
+        check: check_missing_error_handling_patterns,
+    },
+    BuiltinRule { id: "non-exhaustive-match", check: check_non_exhaustive_matches },
+];
+
+/// Runs a set of lint rules over source files. `Linter::new()` starts with the built-in rules.
+pub struct Linter {
+    rules: Vec<Box<dyn LintRule>>,
+}
+
+impl Default for Linter {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Linter {
+    pub fn new() -> Self {
+        Self {
+            rules: BUILTIN_RULES.iter().map(|rule| Box::new(*rule) as Box<dyn LintRule>).collect(),
+        }
+    }
+
+    /// Add `rule`, which runs after the rules already registered.
+    #[allow(dead_code)]
+    // Extension point for embedders; the CLI runs the built-in rules.
+    pub fn with_rule(mut self, rule: impl LintRule + 'static) -> Self {
+        self.rules.push(Box::new(rule));
+        self
+    }
+
+    /// Stop running the rule with id `rule_id`. Returns false when no such rule is registered.
+    pub fn disable(&mut self, rule_id: &str) -> bool {
+        let before = self.rules.len();
+        self.rules.retain(|rule| rule.id() != rule_id);
+        self.rules.len() != before
+    }
+
+    /// Lint `source`, returning issues ordered by position. Sources that do not lex or parse
+    /// also get `lexer-error` or `parse-error` issues.
+    pub fn lint(&self, source: &str) -> Vec<LintIssue> {
+        let mut issues = Vec::new();
+        let tokens = match lexer::tokenize(source) {
+            Ok(tokens) => tokens,
+            Err(diagnostics) => {
+                issues.extend(diagnostics.into_iter().map(|diagnostic| LintIssue {
+                    rule_id: "lexer-error".to_string(),
+                    line: diagnostic.line,
+                    column: diagnostic.column,
+                    severity: LintSeverity::Error,
+                    message: diagnostic.message,
+                    fix: None,
+                }));
+                Vec::new()
+            }
+        };
+        let parsed =
+            (!tokens.is_empty()).then(|| Parser::new(tokens.clone()).parse_with_diagnostics());
+        if let Some(parsed) = &parsed {
+            issues.extend(parsed.diagnostics.iter().map(|diagnostic| LintIssue {
+                rule_id: "parse-error".to_string(),
+                line: diagnostic.line,
+                column: diagnostic.column,
+                severity: LintSeverity::Error,
+                message: diagnostic.message.clone(),
+                fix: None,
+            }));
+        }
+        let parsed = parsed.filter(|parsed| parsed.diagnostics.is_empty());
+
+        let context = LintContext {
+            source,
+            tokens: &tokens,
+            program: parsed.as_ref().map(|parsed| parsed.stmts.as_slice()),
+            spans: parsed
+                .as_ref()
+                .and_then(|parsed| recorded_statement_spans(&parsed.stmts, &parsed.ast_spans))
+                .unwrap_or_default(),
+        };
+        for rule in &self.rules {
+            issues.extend(rule.check(&context));
+        }
+        issues.sort_by_key(|issue| (issue.line, issue.column, issue.rule_id.clone()));
+        issues
+    }
+}
+
+/// Lint `source` with the built-in rules.
+#[allow(dead_code)]
+// Library entry point; the CLI builds a `Linter` so `--ignore` can drop rules.
 pub fn lint_source(source: &str) -> Vec<LintIssue> {
-    let mut issues = Vec::new();
-    issues.extend(check_unused_variables(source));
-    issues.extend(check_unreachable_code(source));
-    issues.extend(check_obvious_type_mismatches(source));
-    issues.extend(check_missing_error_handling_patterns(source));
-    issues.sort_by_key(|issue| (issue.line, issue.column, issue.rule_id.clone()));
-    issues
+    Linter::new().lint(source)
 }
 
 pub fn apply_safe_fixes(source: &str, issues: &[LintIssue]) -> String {
@@ -68,25 +242,8 @@ pub fn apply_safe_fixes(source: &str, issues: &[LintIssue]) -> String {
     output
 }
 
-fn check_unused_variables(source: &str) -> Vec<LintIssue> {
-    let tokens = match lexer::tokenize(source) {
-        Ok(tokens) => tokens,
-        Err(diagnostics) => {
-            return diagnostics
-                .into_iter()
-                .map(|diagnostic| LintIssue {
-                    rule_id: "lexer-error".to_string(),
-                    line: diagnostic.line,
-                    column: diagnostic.column,
-                    severity: LintSeverity::Error,
-                    message: diagnostic.message,
-                    fix: None,
-                })
-                .collect()
-        }
-    };
-    let source_lines: Vec<&str> = source.lines().collect();
-
+fn check_unused_variables(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let tokens = context.tokens;
     let mut usage_counts: HashMap<String, usize> = HashMap::new();
     let mut declarations: Vec<(String, usize, usize)> = Vec::new();
 
@@ -134,8 +291,8 @@ fn check_unused_variables(source: &str) -> Vec<LintIssue> {
         }
 
         if usage_counts.get(&name).copied().unwrap_or(0) <= 1 {
-            let replacement_line = source_lines
-                .get(line.saturating_sub(1))
+            let replacement_line = context
+                .line(line)
                 .map(|original| {
                     let needle = format!("let {}", name);
                     if original.contains(&needle) {
@@ -164,54 +321,504 @@ fn check_unused_variables(source: &str) -> Vec<LintIssue> {
     issues
 }
 
-fn check_unreachable_code(source: &str) -> Vec<LintIssue> {
+/// Flags the first statement of a block that follows a `return`, `break`, `continue`, or
+/// `throw`, or an `if` whose branches all end in one.
+fn check_unreachable_code(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let Some(program) = context.program else {
+        return Vec::new();
+    };
     let mut issues = Vec::new();
-    let mut in_unreachable_region = false;
-    let mut brace_balance: i64 = 0;
+    walk_block(program, &mut |node| {
+        let Node::Block(body) = node else {
+            return;
+        };
+        let Some(index) = body.iter().position(|stmt| exit_description(stmt).is_some()) else {
+            return;
+        };
+        if let Some(next) = body.get(index + 1) {
+            let exit = exit_description(&body[index]).unwrap_or_default();
+            issues.push(LintIssue::warning(
+                "unreachable-code",
+                context.position(next),
+                format!("Unreachable code after {}", exit),
+            ));
+        }
+    });
+    issues
+}
 
-    for (index, line) in source.lines().enumerate() {
-        let line_number = index + 1;
-        let trimmed = line.trim();
-        if trimmed.is_empty() || trimmed.starts_with('#') || trimmed.starts_with("//") {
-            continue;
+/// How `stmt` leaves its block unconditionally, or `None` when control can fall through.
+fn exit_description(stmt: &Stmt) -> Option<&'static str> {
+    match stmt {
+        Stmt::Return(_) => Some("`return`"),
+        Stmt::Break(_) => Some("`break`"),
+        Stmt::Continue(_) => Some("`continue`"),
+        Stmt::ExprStmt(Expr::Tag(tag, _)) if tag == "throw" => Some("`throw`"),
+        Stmt::If { then_branch, else_branch: Some(else_branch), .. }
+            if [then_branch, else_branch]
+                .iter()
+                .all(|body| body.iter().any(|stmt| exit_description(stmt).is_some())) =>
+        {
+            Some("an `if` whose branches all exit")
         }
+        Stmt::Block(body) => body.iter().find_map(exit_description),
+        _ => None,
+    }
+}
 
-        let opening = trimmed.chars().filter(|ch| *ch == '{').count() as i64;
-        let closing = trimmed.chars().filter(|ch| *ch == '}').count() as i64;
+/// Flags variables, constants, functions, and parameters named after a built-in function,
+/// once per name.
+fn check_shadowed_builtins(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let Some(program) = context.program else {
+        return Vec::new();
+    };
+    let builtins: HashSet<&str> = Interpreter::get_builtin_names()
+        .into_iter()
+        .filter(|name| !name.starts_with("__"))
+        .collect();
+    let mut reported = HashSet::new();
+    let mut issues = Vec::new();
+    for binding in bindings(program) {
+        if builtins.contains(binding.name) && reported.insert(binding.name) {
+            issues.push(LintIssue::warning(
+                "shadowed-builtin",
+                context.position(binding.at),
+                format!(
+                    "'{}' shadows the built-in function of the same name; rename it",
+                    binding.name
+                ),
+            ));
+        }
+    }
+    issues
+}
 
-        if in_unreachable_region && !trimmed.starts_with('}') {
-            issues.push(LintIssue {
-                rule_id: "unreachable-code".to_string(),
-                line: line_number,
-                column: 1,
-                severity: LintSeverity::Warning,
-                message: "Statement appears unreachable after control-flow terminator".to_string(),
-                fix: None,
-            });
+/// Flags `==` and `!=` between operands whose types are known to differ, which always
+/// compare unequal. Literals have known types, and so do variables whose every binding in
+/// the file gives them the same literal type or annotation.
+fn check_suspicious_equality(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let Some(program) = context.program else {
+        return Vec::new();
+    };
+    let mut variable_types: HashMap<&str, Option<&'static str>> = HashMap::new();
+    for binding in bindings(program) {
+        let known = binding
+            .annotation
+            .and_then(annotation_type)
+            .or_else(|| binding.value.and_then(|value| static_type(value, &HashMap::new())));
+        variable_types
+            .entry(binding.name)
+            .and_modify(|existing| {
+                if *existing != known {
+                    *existing = None;
+                }
+            })
+            .or_insert(known);
+    }
+    let variable_types: HashMap<&str, &'static str> = variable_types
+        .into_iter()
+        .filter_map(|(name, known)| known.map(|known| (name, known)))
+        .collect();
+
+    let mut issues = Vec::new();
+    walk_block(program, &mut |node| {
+        let Node::Expr(Expr::BinaryOp { left, op, right }, at) = node else {
+            return;
+        };
+        if op != "==" && op != "!=" {
+            return;
+        }
+        let (Some(left_type), Some(right_type)) =
+            (static_type(left, &variable_types), static_type(right, &variable_types))
+        else {
+            return;
+        };
+        if left_type != right_type {
+            issues.push(LintIssue::warning(
+                "suspicious-equality",
+                context.position(at),
+                format!(
+                    "Comparing {} with {} using `{}` is always {}",
+                    left_type,
+                    right_type,
+                    op,
+                    op == "!="
+                ),
+            ));
         }
+    });
+    issues
+}
 
-        if trimmed.starts_with("return")
-            || trimmed.starts_with("break")
-            || trimmed.starts_with("continue")
+/// The type of value `expr` always produces, as a noun phrase, when it is evident from the
+/// syntax or from `variables`.
+fn static_type(expr: &Expr, variables: &HashMap<&str, &'static str>) -> Option<&'static str> {
+    match expr {
+        Expr::Int(_) | Expr::Float(_) => Some("a number"),
+        Expr::String(_) | Expr::InterpolatedString(_) => Some("a string"),
+        Expr::Bool(_) => Some("a bool"),
+        Expr::ArrayLiteral(_) => Some("an array"),
+        Expr::DictLiteral(_) => Some("a dict"),
+        Expr::UnaryOp { op, operand } if op == "-" => {
+            static_type(operand, variables).filter(|known| *known == "a number")
+        }
+        Expr::UnaryOp { op, .. } if op == "!" || op == "not" => Some("a bool"),
+        Expr::BinaryOp { op, .. }
+            if matches!(op.as_str(), "==" | "!=" | "<" | ">" | "<=" | ">=" | "&&" | "||") =>
         {
-            in_unreachable_region = true;
-            brace_balance = opening - closing;
-            continue;
+            Some("a bool")
         }
+        Expr::Identifier(name) => variables.get(name.as_str()).copied(),
+        _ => None,
+    }
+}
 
-        if in_unreachable_region {
-            brace_balance += opening - closing;
-            if brace_balance <= 0 && trimmed.contains('}') {
-                in_unreachable_region = false;
-                brace_balance = 0;
+fn annotation_type(annotation: &TypeAnnotation) -> Option<&'static str> {
+    match annotation {
+        TypeAnnotation::Int | TypeAnnotation::Float => Some("a number"),
+        TypeAnnotation::String => Some("a string"),
+        TypeAnnotation::Bool => Some("a bool"),
+        TypeAnnotation::Array(_) => Some("an array"),
+        TypeAnnotation::Dict { .. } => Some("a dict"),
+        _ => None,
+    }
+}
+
+/// Flags empty `if`, `else`, loop, `try`, `except`, and `finally` blocks. An empty `else`
+/// written on the line that closes the `if` gets a fix that removes it.
+fn check_empty_blocks(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let Some(program) = context.program else {
+        return Vec::new();
+    };
+    let else_clause = Regex::new(r"\}\s*else\s*\{\s*\}").expect("empty else regex must compile");
+    let mut issues = Vec::new();
+    walk_block(program, &mut |node| {
+        let Node::Stmt(stmt, at) = node else {
+            return;
+        };
+        let mut report = |block: &str, hint: &str| {
+            issues.push(LintIssue::warning(
+                "empty-block",
+                context.position(at),
+                format!("Empty `{}` block; {}", block, hint),
+            ))
+        };
+        match stmt {
+            Stmt::If { then_branch, else_branch, .. } => {
+                if then_branch.is_empty() {
+                    report("if", "negate the condition or remove the statement");
+                }
+                if else_branch.as_ref().is_some_and(Vec::is_empty) {
+                    let mut issue = LintIssue::warning(
+                        "empty-block",
+                        context.position(at),
+                        "Empty `else` block; remove it".to_string(),
+                    );
+                    let closing_line = context.span(at).map(|span| span.end.line);
+                    if let Some((line, text)) =
+                        closing_line.and_then(|line| Some((line, context.line(line)?)))
+                    {
+                        if let Some(found) = else_clause.find_iter(text).last() {
+                            issue.line = line;
+                            issue.column = found.start() + 1;
+                            issue.fix = Some(LintFix {
+                                replacement_line: format!(
+                                    "{}}}{}",
+                                    &text[..found.start()],
+                                    &text[found.end()..]
+                                ),
+                                description: "Remove the empty else block".to_string(),
+                            });
+                        }
+                    }
+                    issues.push(issue);
+                }
+            }
+            Stmt::Loop { body, .. } | Stmt::For { body, .. } | Stmt::While { body, .. }
+                if body.is_empty() =>
+            {
+                report("loop", "add a body or remove the loop")
             }
+            Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+                if try_block.is_empty() {
+                    report("try", "remove the statement");
+                }
+                if except_block.is_empty() {
+                    report("except", "the error is silently discarded; handle or log it");
+                }
+                if finally_block.as_ref().is_some_and(Vec::is_empty) {
+                    report("finally", "remove it");
+                }
+            }
+            _ => {}
+        }
+    });
+    issues
+}
+
+/// A name introduced by a declaration, assignment, function, parameter, loop variable, or
+/// `except` clause.
+struct Binding<'a> {
+    name: &'a str,
+    /// Statement that positions the binding
+    at: &'a Stmt,
+    /// Value bound to a plain `let`, `const`, or assigned identifier
+    value: Option<&'a Expr>,
+    annotation: Option<&'a TypeAnnotation>,
+}
+
+fn bindings<'a>(program: &'a [Stmt]) -> Vec<Binding<'a>> {
+    let mut found = Vec::new();
+    walk_block(program, &mut |node| match node {
+        Node::Stmt(stmt, at) => {
+            let mut bind =
+                |name: &'a str, value: Option<&'a Expr>, annotation: Option<&'a TypeAnnotation>| {
+                    found.push(Binding { name, at, value, annotation })
+                };
+            match stmt {
+                Stmt::Let { pattern, value, type_annotation, .. } => match pattern {
+                    Pattern::Identifier(name) => bind(name, Some(value), type_annotation.as_ref()),
+                    Pattern::Array { elements, rest } => {
+                        pattern_names(elements, &mut |name| bind(name, None, None));
+                        rest.iter().for_each(|name| bind(name, None, None));
+                    }
+                    Pattern::Dict { keys, rest } => {
+                        keys.iter().chain(rest).for_each(|name| bind(name, None, None))
+                    }
+                    Pattern::Ignore => {}
+                },
+                Stmt::Const { name, value, type_annotation } => {
+                    bind(name, Some(value), type_annotation.as_ref())
+                }
+                Stmt::Assign { target: Expr::Identifier(name), value } => {
+                    bind(name, Some(value), None)
+                }
+                Stmt::MultiAssign { targets, .. } => {
+                    for target in targets {
+                        if let Expr::Identifier(name) = target {
+                            bind(name, None, None);
+                        }
+                    }
+                }
+                Stmt::FuncDef { name, params, .. } => {
+                    bind(name, None, None);
+                    params.iter().for_each(|param| bind(param, None, None));
+                }
+                Stmt::For { var, .. } => bind(var, None, None),
+                Stmt::TryExcept { except_var, .. } => bind(except_var, None, None),
+                _ => {}
+            }
+        }
+        Node::Expr(Expr::Function { params, .. }, at) => {
+            for param in params {
+                found.push(Binding { name: param, at, value: None, annotation: None });
+            }
+        }
+        _ => {}
+    });
+    found
+}
+
+fn pattern_names<'a>(patterns: &'a [Pattern], bind: &mut dyn FnMut(&'a str)) {
+    for pattern in patterns {
+        match pattern {
+            Pattern::Identifier(name) => bind(name),
+            Pattern::Array { elements, rest } => {
+                pattern_names(elements, bind);
+                rest.iter().for_each(|name| bind(name));
+            }
+            Pattern::Dict { keys, rest } => keys.iter().chain(rest).for_each(|name| bind(name)),
+            Pattern::Ignore => {}
         }
     }
+}
 
-    issues
+/// A syntax-tree node visited by `walk_block`.
+enum Node<'a> {
+    /// A statement list: a program, a body, or a branch
+    Block(&'a [Stmt]),
+    /// A statement and the statement that positions it. The two differ only for the loop of
+    /// a labeled loop, which has no span of its own.
+    Stmt(&'a Stmt, &'a Stmt),
+    /// An expression and the innermost statement holding it
+    Expr(&'a Expr, &'a Stmt),
+}
+
+/// Visit `body` and everything in it in pre-order, including the bodies of function and
+/// match expressions.
+fn walk_block<'a>(body: &'a [Stmt], visit: &mut dyn FnMut(Node<'a>)) {
+    visit(Node::Block(body));
+    for stmt in body {
+        walk_stmt(stmt, stmt, visit);
+    }
+}
+
+fn walk_stmt<'a>(stmt: &'a Stmt, at: &'a Stmt, visit: &mut dyn FnMut(Node<'a>)) {
+    visit(Node::Stmt(stmt, at));
+    match stmt {
+        Stmt::Let { value, .. } | Stmt::Const { value, .. } => walk_expr(value, at, visit),
+        Stmt::Assign { target, value } => {
+            walk_expr(target, at, visit);
+            walk_expr(value, at, visit);
+        }
+        Stmt::MultiAssign { targets, values } => {
+            targets.iter().chain(values).for_each(|expr| walk_expr(expr, at, visit))
+        }
+        Stmt::FuncDef { param_defaults, body, decorators, .. } => {
+            decorators.iter().for_each(|decorator| walk_expr(decorator, at, visit));
+            param_defaults.iter().flatten().for_each(|default| walk_expr(default, at, visit));
+            walk_block(body, visit);
+        }
+        Stmt::Match { value, cases, default } => {
+            walk_expr(value, at, visit);
+            walk_match_cases(cases, default, at, visit);
+        }
+        Stmt::ExprStmt(expr) | Stmt::Return(Some(expr)) => walk_expr(expr, at, visit),
+        Stmt::If { condition, then_branch, else_branch } => {
+            walk_expr(condition, at, visit);
+            walk_block(then_branch, visit);
+            if let Some(else_branch) = else_branch {
+                walk_block(else_branch, visit);
+            }
+        }
+        Stmt::Loop { condition, body } => {
+            if let Some(condition) = condition {
+                walk_expr(condition, at, visit);
+            }
+            walk_block(body, visit);
+        }
+        Stmt::For { iterable: expr, body, .. } | Stmt::While { condition: expr, body } => {
+            walk_expr(expr, at, visit);
+            walk_block(body, visit);
+        }
+        Stmt::LabeledLoop { loop_stmt, .. } => walk_stmt(loop_stmt, at, visit),
+        Stmt::TryExcept { try_block, except_block, finally_block, .. } => {
+            walk_block(try_block, visit);
+            walk_block(except_block, visit);
+            if let Some(finally_block) = finally_block {
+                walk_block(finally_block, visit);
+            }
+        }
+        Stmt::Export { stmt } => walk_stmt(stmt, stmt, visit),
+        Stmt::StructDef { methods: body, .. }
+        | Stmt::Block(body)
+        | Stmt::Spawn { body }
+        | Stmt::Test { body, .. }
+        | Stmt::TestSetup { body }
+        | Stmt::TestTeardown { body }
+        | Stmt::TestGroup { tests: body, .. } => walk_block(body, visit),
+        Stmt::EnumDef { .. }
+        | Stmt::InterfaceDef { .. }
+        | Stmt::Return(None)
+        | Stmt::Break(_)
+        | Stmt::Continue(_)
+        | Stmt::Import { .. }
+        | Stmt::ImportPath { .. }
+        | Stmt::SourcePos { .. } => {}
+    }
+}
+
+fn walk_match_cases<'a>(
+    cases: &'a [MatchCase],
+    default: &'a Option<Vec<Stmt>>,
+    at: &'a Stmt,
+    visit: &mut dyn FnMut(Node<'a>),
+) {
+    for case in cases {
+        if let Some(guard) = &case.guard {
+            walk_expr(guard, at, visit);
+        }
+        walk_block(&case.body, visit);
+    }
+    if let Some(default) = default {
+        walk_block(default, visit);
+    }
+}
+
+fn walk_expr<'a>(expr: &'a Expr, at: &'a Stmt, visit: &mut dyn FnMut(Node<'a>)) {
+    visit(Node::Expr(expr, at));
+    match expr {
+        Expr::Function { param_defaults, body, .. } => {
+            param_defaults.iter().flatten().for_each(|default| walk_expr(default, at, visit));
+            walk_block(body, visit);
+        }
+        Expr::UnaryOp { operand: inner, .. }
+        | Expr::FieldAccess { object: inner, .. }
+        | Expr::Spread(inner)
+        | Expr::NamedArg { value: inner, .. }
+        | Expr::Ok(inner)
+        | Expr::Err(inner)
+        | Expr::Some(inner)
+        | Expr::Try(inner)
+        | Expr::Await(inner)
+        | Expr::Yield(Some(inner)) => walk_expr(inner, at, visit),
+        Expr::BinaryOp { left, right, .. } => {
+            walk_expr(left, at, visit);
+            walk_expr(right, at, visit);
+        }
+        Expr::IndexAccess { object, index } => {
+            walk_expr(object, at, visit);
+            walk_expr(index, at, visit);
+        }
+        Expr::Slice { object, start, end } => {
+            walk_expr(object, at, visit);
+            [start, end].into_iter().flatten().for_each(|bound| walk_expr(bound, at, visit));
+        }
+        Expr::Call { function: object, args } | Expr::MethodCall { object, args, .. } => {
+            walk_expr(object, at, visit);
+            args.iter().for_each(|arg| walk_expr(arg, at, visit));
+        }
+        Expr::Tag(_, args) => args.iter().for_each(|arg| walk_expr(arg, at, visit)),
+        Expr::StructInstance { fields, .. } => {
+            fields.iter().for_each(|(_, value)| walk_expr(value, at, visit))
+        }
+        Expr::InterpolatedString(parts) => {
+            for part in parts {
+                if let InterpolatedStringPart::Expr(expr) = part {
+                    walk_expr(expr, at, visit);
+                }
+            }
+        }
+        Expr::ArrayLiteral(elements) => {
+            for element in elements {
+                match element {
+                    ArrayElement::Single(expr) | ArrayElement::Spread(expr) => {
+                        walk_expr(expr, at, visit)
+                    }
+                }
+            }
+        }
+        Expr::DictLiteral(elements) => {
+            for element in elements {
+                match element {
+                    DictElement::Pair(key, value) => {
+                        walk_expr(key, at, visit);
+                        walk_expr(value, at, visit);
+                    }
+                    DictElement::Spread(expr) => walk_expr(expr, at, visit),
+                }
+            }
+        }
+        Expr::Ternary { condition, then_expr, else_expr } => {
+            walk_expr(condition, at, visit);
+            walk_expr(then_expr, at, visit);
+            walk_expr(else_expr, at, visit);
+        }
+        Expr::Match { value, cases, default } => {
+            walk_expr(value, at, visit);
+            walk_match_cases(cases, default, at, visit);
+        }
+        Expr::Identifier(_)
+        | Expr::Int(_)
+        | Expr::Float(_)
+        | Expr::String(_)
+        | Expr::Bool(_)
+        | Expr::None
+        | Expr::Yield(None) => {}
+    }
 }
 
-fn check_obvious_type_mismatches(source: &str) -> Vec<LintIssue> {
+fn check_obvious_type_mismatches(context: &LintContext<'_>) -> Vec<LintIssue> {
     let int_string = Regex::new("let\\s+[A-Za-z_][A-Za-z0-9_]*\\s*:\\s*int\\s*:=\\s*\\\"")
         .expect("int-string mismatch regex must compile");
     let float_string = Regex::new("let\\s+[A-Za-z_][A-Za-z0-9_]*\\s*:\\s*float\\s*:=\\s*\\\"")
@@ -220,7 +827,7 @@ fn check_obvious_type_mismatches(source: &str) -> Vec<LintIssue> {
         .expect("bool-numeric mismatch regex must compile");
 
     let mut issues = Vec::new();
-    for (index, line) in source.lines().enumerate() {
+    for (index, line) in context.source.lines().enumerate() {
         let line_number = index + 1;
         if int_string.is_match(line) {
             issues.push(LintIssue {
@@ -260,10 +867,10 @@ fn check_obvious_type_mismatches(source: &str) -> Vec<LintIssue> {
     issues
 }
 
-fn check_missing_error_handling_patterns(source: &str) -> Vec<LintIssue> {
+fn check_missing_error_handling_patterns(context: &LintContext<'_>) -> Vec<LintIssue> {
     let mut issues = Vec::new();
 
-    for (index, line) in source.lines().enumerate() {
+    for (index, line) in context.source.lines().enumerate() {
         let trimmed = line.trim();
         let line_number = index + 1;
 
@@ -280,11 +887,8 @@ fn check_missing_error_handling_patterns(source: &str) -> Vec<LintIssue> {
             continue;
         }
 
-        let previous_line = if line_number > 1 {
-            source.lines().nth(line_number - 2).unwrap_or("").trim()
-        } else {
-            ""
-        };
+        let previous_line =
+            if line_number > 1 { context.line(line_number - 1).unwrap_or("").trim() } else { "" };
         let handled_by_previous = previous_line.starts_with("try");
         if handled_by_previous {
             continue;
@@ -304,9 +908,143 @@ fn check_missing_error_handling_patterns(source: &str) -> Vec<LintIssue> {
     issues
 }
 
+/// Flags `match` statements and expressions with no `default:` or catch-all case that leave
+/// out part of a closed family: `Ok`/`Err`, `Some`/`None`, `true`/`false`, or the variants of
+/// an enum declared in the same file. Matches over open value sets are not reported.
+fn check_non_exhaustive_matches(context: &LintContext<'_>) -> Vec<LintIssue> {
+    let tokens = context.tokens;
+    let enums = declared_enum_variants(tokens);
+
+    let mut issues = Vec::new();
+    for (index, token) in tokens.iter().enumerate() {
+        if !matches!(&token.kind, TokenKind::Keyword(k) if k == "match") {
+            continue;
+        }
+        let Some(close) = match_body_close(tokens, index) else {
+            continue;
+        };
+        let mut match_tokens = tokens[index..=close].to_vec();
+        match_tokens.push(Token { kind: TokenKind::Eof, ..token.clone() });
+        let Some(Stmt::Match { cases, default, .. }) = Parser::new(match_tokens).parse().pop()
+        else {
+            continue;
+        };
+
+        if default.is_some() {
+            continue;
+        }
+        let missing = missing_match_cases(&cases, &enums);
+        if missing.is_empty() {
+            continue;
+        }
+        issues.push(LintIssue {
+            rule_id: "non-exhaustive-match".to_string(),
+            line: token.line,
+            column: token.column.saturating_sub("match".len()),
+            severity: LintSeverity::Warning,
+            message: format!(
+                "Non-exhaustive match: no case for {}; add the missing cases or a default",
+                missing.join(", ")
+            ),
+            fix: None,
+        });
+    }
+
+    issues
+}
+
+/// Variants of every `enum Name { A, B }` declared in the token stream, keyed by enum name.
+fn declared_enum_variants(tokens: &[Token]) -> HashMap<String, Vec<String>> {
+    let mut enums = HashMap::new();
+    for (index, token) in tokens.iter().enumerate() {
+        if !matches!(&token.kind, TokenKind::Keyword(k) if k == "enum") {
+            continue;
+        }
+        let Some(TokenKind::Identifier(name)) = tokens.get(index + 1).map(|t| &t.kind) else {
+            continue;
+        };
+        let variants = tokens
+            .iter()
+            .skip(index + 3)
+            .take_while(|t| t.kind != TokenKind::Punctuation('}'))
+            .filter_map(|t| match &t.kind {
+                TokenKind::Identifier(variant) => Some(variant.clone()),
+                _ => None,
+            })
+            .collect();
+        enums.insert(name.clone(), variants);
+    }
+    enums
+}
+
+/// Index of the `}` closing the case list of the `match` at `start`.
+fn match_body_close(tokens: &[Token], start: usize) -> Option<usize> {
+    let mut nesting = 0i32;
+    let mut braces = 0i32;
+    for (index, token) in tokens.iter().enumerate().skip(start + 1) {
+        match token.kind {
+            TokenKind::Punctuation('(' | '[') => nesting += 1,
+            TokenKind::Punctuation(')' | ']') => nesting -= 1,
+            TokenKind::Punctuation('{') if braces > 0 || nesting == 0 => braces += 1,
+            TokenKind::Punctuation('}') if braces > 0 => {
+                braces -= 1;
+                if braces == 0 {
+                    return Some(index);
+                }
+            }
+            _ => {}
+        }
+    }
+    None
+}
+
+/// Members of the family the first tag or bool case belongs to that no unguarded case
+/// covers. Empty when the family is unknown or an unguarded catch-all case exists.
+fn missing_match_cases(cases: &[MatchCase], enums: &HashMap<String, Vec<String>>) -> Vec<String> {
+    let covering: Vec<&MatchPattern> =
+        cases.iter().filter(|case| case.guard.is_none()).map(|case| &case.pattern).collect();
+    if covering.iter().any(|pattern| pattern.is_irrefutable()) {
+        return Vec::new();
+    }
+
+    let family_key = |pattern: &MatchPattern| -> Option<String> {
+        match pattern {
+            MatchPattern::Tag { tag, payload }
+                if payload.as_deref().map_or(true, MatchPattern::is_irrefutable) =>
+            {
+                Some(tag.trim_start_matches("Result::").trim_start_matches("Option::").to_string())
+            }
+            MatchPattern::Literal(MatchLiteral::Bool(flag)) => Some(flag.to_string()),
+            _ => None,
+        }
+    };
+    let family: Vec<String> = match cases.iter().find_map(|case| match &case.pattern {
+        MatchPattern::Tag { tag, .. } => Some(tag.as_str()),
+        MatchPattern::Literal(MatchLiteral::Bool(_)) => Some("true"),
+        _ => None,
+    }) {
+        Some("Ok" | "Err" | "Result::Ok" | "Result::Err") => vec!["Ok".into(), "Err".into()],
+        Some("Some" | "None" | "Option::Some" | "Option::None") => {
+            vec!["Some".into(), "None".into()]
+        }
+        Some("true") => vec!["true".into(), "false".into()],
+        Some(tag) => match tag.split_once("::").and_then(|(name, _)| enums.get(name)) {
+            Some(variants) => {
+                let name = tag.split_once("::").map(|(name, _)| name).unwrap_or_default();
+                variants.iter().map(|variant| format!("{}::{}", name, variant)).collect()
+            }
+            None => return Vec::new(),
+        },
+        None => return Vec::new(),
+    };
+
+    let covered: Vec<String> = covering.iter().filter_map(|pattern| family_key(pattern)).collect();
+    family.into_iter().filter(|member| !covered.contains(member)).collect()
+}
+
 #[cfg(test)]
 mod tests {
-    use super::{apply_safe_fixes, lint_source};
+    use super::{apply_safe_fixes, lint_source, LintContext, LintIssue, LintRule, Linter};
 
     #[test]
     fn lint_reports_unused_variable_and_offers_fix() {
@@ -325,6 +1063,139 @@ mod tests {
         assert!(issues.iter().any(|issue| issue.rule_id == "unreachable-code"));
     }
 
+    #[test]
+    fn lint_reports_first_statement_after_every_exit() {
+        let source = [
+            "func pick(flag) {",
+            "    if flag {",
+            "        return 1",
+            "    } else {",
+            "        throw \"no\"",
+            "    }",
+            "    print(2)",
+            "    print(3)",
+            "}",
+            "for item in [1] {",
+            "    continue",
+            "    print(item)",
+            "}",
+        ]
+        .join("\n");
+        let lines: Vec<usize> = lint_source(&source)
+            .into_iter()
+            .filter(|issue| issue.rule_id == "unreachable-code")
+            .map(|issue| issue.line)
+            .collect();
+        assert_eq!(lines, vec![7, 12]);
+    }
+
+    #[test]
+    fn lint_reports_shadowed_builtins_once_per_name() {
+        let source = [
+            "let len := 3",
+            "len := 4",
+            "func total(values) {",
+            "    return values",
+            "}",
+            "print(len, total([1]))",
+        ]
+        .join("\n");
+        let messages: Vec<String> = lint_source(&source)
+            .into_iter()
+            .filter(|issue| issue.rule_id == "shadowed-builtin")
+            .map(|issue| format!("{}:{}", issue.line, issue.message))
+            .collect();
+        assert_eq!(messages.len(), 2);
+        assert!(messages[0].starts_with("1:'len'"));
+        assert!(messages[1].starts_with("3:'values'"));
+    }
+
+    #[test]
+    fn lint_reports_equality_between_different_types() {
+        let source = [
+            "let count := 3",
+            "mut label := \"a\"",
+            "label := 1",
+            "if count == \"3\" {",
+            "    print(1)",
+            "}",
+            "print(count != 3, label == 1, [1] == {\"k\": 1})",
+        ]
+        .join("\n");
+        let issues: Vec<_> = lint_source(&source)
+            .into_iter()
+            .filter(|issue| issue.rule_id == "suspicious-equality")
+            .collect();
+        assert_eq!(issues.iter().map(|issue| issue.line).collect::<Vec<_>>(), vec![4, 7]);
+        assert!(issues[0].message.contains("a number with a string"));
+        assert!(issues[1].message.contains("an array with a dict"));
+    }
+
+    #[test]
+    fn lint_reports_empty_blocks_and_removes_empty_else() {
+        let source = [
+            "let ready := true",
+            "if ready {",
+            "    print(1)",
+            "} else {}",
+            "while ready {",
+            "}",
+            "try {",
+            "    print(2)",
+            "} except err {",
+            "}",
+            "",
+        ]
+        .join("\n");
+        let issues: Vec<_> = lint_source(&source)
+            .into_iter()
+            .filter(|issue| issue.rule_id == "empty-block")
+            .collect();
+        assert_eq!(issues.iter().map(|issue| issue.line).collect::<Vec<_>>(), vec![4, 5, 7]);
+        assert!(issues[2].message.contains("`except`"));
+
+        let fixed = apply_safe_fixes(&source, &issues);
+        assert!(fixed.contains("    print(1)\n}\nwhile ready {"));
+    }
+
+    #[test]
+    fn lint_reports_parse_errors_and_skips_syntax_tree_rules() {
+        let issues = lint_source("let value := (1 +\n");
+        assert!(issues.iter().any(|issue| issue.rule_id == "parse-error"));
+        assert!(issues.iter().all(|issue| issue.rule_id != "unreachable-code"));
+    }
+
+    #[test]
+    fn linter_runs_added_rules_and_skips_disabled_ones() {
+        struct NoPrint;
+
+        impl LintRule for NoPrint {
+            fn id(&self) -> &'static str {
+                "no-print"
+            }
+
+            fn check(&self, context: &LintContext<'_>) -> Vec<LintIssue> {
+                context
+                    .source
+                    .lines()
+                    .enumerate()
+                    .filter(|(_, line)| line.contains("print("))
+                    .map(|(index, _)| {
+                        LintIssue::warning("no-print", (index + 1, 1), "print call".to_string())
+                    })
+                    .collect()
+            }
+        }
+
+        let mut linter = Linter::new().with_rule(NoPrint);
+        assert!(linter.disable("unused-variable"));
+        assert!(!linter.disable("no-such-rule"));
+
+        let issues = linter.lint("let value := 1\nprint(2)\n");
+        let rules: Vec<&str> = issues.iter().map(|issue| issue.rule_id.as_str()).collect();
+        assert_eq!(rules, vec!["no-print"]);
+    }
+
     #[test]
     fn lint_reports_obvious_type_mismatch() {
         let source = "let count: int := \"oops\"\n";
@@ -338,4 +1209,31 @@ mod tests {
         let issues = lint_source(source);
         assert!(issues.iter().any(|issue| issue.rule_id == "missing-error-handling-pattern"));
     }
+
+    #[test]
+    fn lint_reports_non_exhaustive_match() {
+        let source = [
+            "enum Status { Active, Paused }",
+            "match Status::Active {",
+            "    case Status::Active: print(1)",
+            "}",
+            "match Ok(1) {",
+            "    case Ok(v): print(v)",
+            "    case Err(e) if e == 0: print(e)",
+            "}",
+            "match Ok(1) {",
+            "    case Ok(v): print(v)",
+            "    default: print(0)",
+            "}",
+        ]
+        .join("\n");
+        let issues: Vec<_> = lint_source(&source)
+            .into_iter()
+            .filter(|issue| issue.rule_id == "non-exhaustive-match")
+            .collect();
+
+        assert_eq!(issues.iter().map(|issue| issue.line).collect::<Vec<_>>(), vec![2, 5]);
+        assert!(issues[0].message.contains("Status::Paused"));
+        assert!(issues[1].message.contains("Err"));
+    }
 }
@@ -146,7 +146,7 @@ fn collect_symbols_from_stmt(
                 collect_symbols_from_stmt(child, function_symbols, variable_symbols);
             }
         }
-        Stmt::TryExcept { try_block, except_var, except_block } => {
+        Stmt::TryExcept { try_block, except_var, except_block, finally_block } => {
             variable_symbols.insert(except_var.clone());
             for child in try_block.iter() {
                 collect_symbols_from_stmt(child, function_symbols, variable_symbols);
@@ -154,13 +154,19 @@ fn collect_symbols_from_stmt(
             for child in except_block.iter() {
                 collect_symbols_from_stmt(child, function_symbols, variable_symbols);
             }
+            for child in finally_block.iter().flatten() {
+                collect_symbols_from_stmt(child, function_symbols, variable_symbols);
+            }
         }
-        Stmt::Export { stmt } => {
+        Stmt::Export { stmt } | Stmt::LabeledLoop { loop_stmt: stmt, .. } => {
             collect_symbols_from_stmt(stmt, function_symbols, variable_symbols);
         }
         Stmt::Match { cases, default, .. } => {
-            for (_, case_stmts) in cases.iter() {
-                for child in case_stmts.iter() {
+            for case in cases.iter() {
+                for name in case.pattern.binding_names() {
+                    variable_symbols.insert(name);
+                }
+                for child in case.body.iter() {
                     collect_symbols_from_stmt(child, function_symbols, variable_symbols);
                 }
             }
@@ -1,12 +1,17 @@
+use crate::ast::{Pattern, Stmt, TypeAnnotation};
 use crate::interpreter::Interpreter;
 use crate::lexer::{self, Token, TokenKind};
 use crate::lsp_definition::{self, DefinitionKind};
+use crate::parser::Parser;
+use crate::type_checker::TypeChecker;
 
 #[derive(Debug, Clone, PartialEq, Eq)]
 pub struct HoverInfo {
     pub symbol: String,
     pub kind: String,
     pub detail: String,
+    /// Declared or inferred type of a variable binding, in source syntax.
+    pub type_name: Option<String>,
     pub line: usize,
     pub column: usize,
 }
@@ -24,13 +29,23 @@ pub fn hover(source: &str, line: usize, column: usize) -> Option<HoverInfo> {
     }
 
     if let Some(definition) = lsp_definition::find_definition(source, line, start_column) {
-        return Some(build_user_symbol_hover(
+        let mut info = build_user_symbol_hover(
             source,
             &definition.name,
-            definition.kind,
+            definition.kind.clone(),
             definition.line,
             definition.column,
-        ));
+        );
+        if definition.kind == DefinitionKind::Variable {
+            info.type_name = variable_binding_type(
+                &tokens,
+                &definition.name,
+                definition.line,
+                definition.column,
+            )
+            .map(|binding_type| binding_type.to_string());
+        }
+        return Some(info);
     }
 
     if Interpreter::get_builtin_names().iter().any(|name| *name == symbol) {
@@ -38,6 +53,7 @@ pub fn hover(source: &str, line: usize, column: usize) -> Option<HoverInfo> {
             symbol: symbol.clone(),
             kind: "builtin".to_string(),
             detail: format!("Built-in symbol: {}", symbol),
+            type_name: None,
             line,
             column: start_column,
         });
@@ -66,7 +82,56 @@ fn build_user_symbol_hover(
         DefinitionKind::Parameter => format!("Function parameter: {}", symbol),
     };
 
-    HoverInfo { symbol: symbol.to_string(), kind: kind.as_str().to_string(), detail, line, column }
+    HoverInfo {
+        symbol: symbol.to_string(),
+        kind: kind.as_str().to_string(),
+        detail,
+        type_name: None,
+        line,
+        column,
+    }
+}
+
+/// Type of a `let`/`mut`/`const` binding: its annotation, or the type the checker infers for
+/// its initializer given the program's top-level bindings.
+fn variable_binding_type(
+    tokens: &[Token],
+    name: &str,
+    line: usize,
+    column: usize,
+) -> Option<TypeAnnotation> {
+    let name_index = tokens.iter().position(|token| {
+        token.line == line
+            && matches!(&token.kind, TokenKind::Identifier(ident) if ident == name)
+            && token.column.saturating_sub(name.chars().count()) == column
+    })?;
+    let keyword = tokens.get(name_index.checked_sub(1)?)?;
+    if !matches!(&keyword.kind, TokenKind::Keyword(k) if k == "let" || k == "mut" || k == "const") {
+        return None;
+    }
+
+    // Parse from the binding keyword; only the first statement matters, so errors later in the
+    // stream (such as the `}` closing an enclosing block) are irrelevant.
+    let binding = Parser::new(tokens[name_index - 1..].to_vec()).parse_with_diagnostics();
+    let (annotation, value) = match binding.stmts.first()? {
+        Stmt::Let { pattern: Pattern::Identifier(bound), type_annotation, value, .. }
+        | Stmt::Const { name: bound, type_annotation, value }
+            if bound == name =>
+        {
+            (type_annotation.clone(), value.clone())
+        }
+        _ => return None,
+    };
+    if annotation.is_some() {
+        return annotation;
+    }
+
+    let mut checker = TypeChecker::new();
+    let program = Parser::new(tokens.to_vec()).parse_with_diagnostics();
+    if program.diagnostics.is_empty() {
+        let _ = checker.check(&program.stmts);
+    }
+    checker.infer_expr_type(&value).filter(|inferred| *inferred != TypeAnnotation::Any)
 }
 
 fn identifier_token_at_cursor<'a>(
@@ -141,6 +206,34 @@ mod tests {
         assert_eq!(info.column, 13);
     }
 
+    #[test]
+    fn hover_reports_annotated_and_inferred_variable_types() {
+        let source = [
+            "let count: int := 1",
+            "func label(n: int) -> string {",
+            "    return \"n\"",
+            "}",
+            "let name := label(count)",
+            "const ratio := 0.5",
+            "let items := [1, 2]",
+            "print(count, name, ratio, items)",
+        ]
+        .join("\n");
+
+        let count = hover(&source, 8, 7).expect("expected hover for count");
+        assert_eq!(count.type_name.as_deref(), Some("int"));
+
+        let name = hover(&source, 8, 14).expect("expected hover for name");
+        assert_eq!(name.type_name.as_deref(), Some("string"));
+
+        let ratio = hover(&source, 8, 20).expect("expected hover for ratio");
+        assert_eq!(ratio.type_name.as_deref(), Some("float"));
+
+        let items = hover(&source, 8, 27).expect("expected hover for items");
+        assert_eq!(items.detail, "Variable: items");
+        assert_eq!(items.type_name.as_deref(), Some("[int]"));
+    }
+
     #[test]
     fn hover_returns_none_when_cursor_not_on_identifier() {
         let source = "let value := 1\n";
@@ -232,10 +232,14 @@ impl LspServer {
                 };
 
                 let result = lsp_hover::hover(&source, line, column).map(|info| {
+                    let value = match &info.type_name {
+                        Some(type_name) => format!("{}\n\nType: `{}`", info.detail, type_name),
+                        None => info.detail.clone(),
+                    };
                     json!({
                         "contents": {
                             "kind": "markdown",
-                            "value": info.detail,
+                            "value": value,
                         },
                         "range": {
                             "start": {
@@ -554,10 +558,12 @@ impl LspServer {
                 let options = formatter_options_from_lsp_params(params);
                 let formatted = formatter::format_source(&source, &options);
 
-                let edits = if formatted == source {
-                    Vec::new()
-                } else {
-                    vec![full_document_text_edit(&source, formatted)]
+                // Unparseable documents are left untouched; diagnostics already report why.
+                let edits = match formatted {
+                    Ok(formatted) if formatted != source => {
+                        vec![full_document_text_edit(&source, formatted)]
+                    }
+                    _ => Vec::new(),
                 };
 
                 json!({
@@ -583,10 +589,11 @@ impl LspServer {
 
                 let options = formatter_options_from_lsp_params(params);
                 let formatted = formatter::format_source(&source, &options);
-                let edits = if formatted == source {
-                    Vec::new()
-                } else {
-                    vec![full_document_text_edit(&source, formatted)]
+                let edits = match formatted {
+                    Ok(formatted) if formatted != source => {
+                        vec![full_document_text_edit(&source, formatted)]
+                    }
+                    _ => Vec::new(),
                 };
 
                 json!({
@@ -1485,6 +1492,44 @@ mod tests {
             .any(|item| { item.get("label").and_then(|value| value.as_str()) == Some("printer") }));
     }
 
+    #[test]
+    fn hover_request_includes_inferred_variable_type() {
+        let mut server = LspServer::new(LspServerConfig::default());
+
+        let _ = server.process_message(&json!({
+            "jsonrpc": "2.0",
+            "method": "textDocument/didOpen",
+            "params": {
+                "textDocument": {
+                    "uri": "file:///tmp/hover.ruff",
+                    "text": "let total := 1.5\nprint(total)\n"
+                }
+            }
+        }));
+
+        let response = server.process_message(&json!({
+            "jsonrpc": "2.0",
+            "id": 4,
+            "method": "textDocument/hover",
+            "params": {
+                "textDocument": {
+                    "uri": "file:///tmp/hover.ruff"
+                },
+                "position": {
+                    "line": 1,
+                    "character": 7
+                }
+            }
+        }));
+
+        assert_eq!(response.len(), 1);
+        let value = response[0]
+            .pointer("/result/contents/value")
+            .and_then(|value| value.as_str())
+            .unwrap_or_default();
+        assert_eq!(value, "Variable: total\n\nType: `float`");
+    }
+
     #[test]
     fn formatting_request_returns_text_edit_when_source_changes() {
         let mut server = LspServer::new(LspServerConfig::default());
@@ -7,12 +7,17 @@
 
 mod ast;
 mod benchmarks;
+mod bigint;
 mod builtins;
+mod bundle;
 mod bytecode;
+mod bytecode_cache;
 mod cli_output;
 mod compiler;
 mod doc_generator;
 mod docgen;
+#[allow(dead_code)] // Embedding API; the binary uses it for playground workers
+mod embed;
 mod errors;
 mod formatter;
 mod http_request_utils;
@@ -23,6 +28,7 @@ mod jit;
 #[path = "jit_disabled.rs"]
 mod jit;
 mod lexer;
+mod line_mode;
 mod linter;
 mod lsp_code_actions;
 mod lsp_completion;
@@ -38,16 +44,22 @@ mod optimizer;
 mod package_workflow;
 mod parser;
 mod path_security;
+mod playground;
 mod repl;
 mod reserved_names;
 mod runtime_limits;
 mod serve_http;
+mod syntax_tree;
 mod type_checker;
 mod vm;
+mod wasm;
+mod watch;
 
 mod workflow_pack;
 
 use crate::interpreter::RuntimeCapabilityPolicy;
+use crate::package_workflow::{DependencySpec, FetchOutcome};
+use crate::runtime_limits::{ExecutionBudget, ExecutionLimits};
 use clap::{Args, Parser as ClapParser, Subcommand, ValueEnum};
 use std::collections::BTreeSet;
 use std::fs;
@@ -68,6 +80,35 @@ const VM_EXECUTION_STACK_SIZE: usize = 64 * 1024 * 1024;
 struct Cli {
     #[command(subcommand)]
     command: Option<Commands>,
+
+    /// Evaluate EXPR for each line of stdin and print the result (awk/sed-style line mode).
+    /// Per-line variables: `line`, `line_number` (1-based), and `fields` (whitespace split).
+    #[arg(short = 'e', long = "eval", value_name = "EXPR")]
+    eval: Option<String>,
+
+    /// Line mode: do not auto-print per-line results.
+    #[arg(short = 'n', long = "no-print", requires = "eval")]
+    no_print: bool,
+
+    /// Line mode: program to run once before the first line.
+    #[arg(long, value_name = "CODE", requires = "eval")]
+    begin: Option<String>,
+
+    /// Line mode: program to run once after the last line; its trailing value is printed.
+    #[arg(long, value_name = "CODE", requires = "eval")]
+    end: Option<String>,
+
+    /// Line mode: abort on the first failing line, or report it on stderr and skip it.
+    #[arg(long, value_enum, default_value_t = LineErrorMode::Abort, requires = "eval")]
+    on_error: LineErrorMode,
+}
+
+#[derive(Clone, Copy, Debug, PartialEq, Eq, ValueEnum)]
+enum LineErrorMode {
+    /// Stop at the first line whose program fails (exit code 4).
+    Abort,
+    /// Print a warning for the failing line and continue.
+    Skip,
 }
 
 #[derive(Args, Clone, Debug, Default)]
@@ -131,6 +172,30 @@ struct CapabilityArgs {
     /// Allow random-number generation APIs.
     #[arg(long, default_value_t = false)]
     allow_random: bool,
+
+    /// Allow loading native shared libraries and raw memory access through `ffi`.
+    #[arg(long, default_value_t = false)]
+    allow_ffi: bool,
+}
+
+#[derive(Args, Clone, Debug, Default)]
+struct ExecutionLimitArgs {
+    /// Run an untrusted script: deny host-effect APIs as with --untrusted (opt back in with
+    /// --allow-* flags) and apply a 10s timeout and 512MB memory limit unless set explicitly.
+    #[arg(long, default_value_t = false)]
+    sandbox: bool,
+
+    /// Stop the script after this much wall-clock time (e.g. 500ms, 5s, 2m).
+    #[arg(long, value_name = "DURATION", value_parser = runtime_limits::parse_duration)]
+    timeout: Option<Duration>,
+
+    /// Stop the script once the process's resident memory exceeds SIZE (e.g. 256MB).
+    #[arg(long, value_name = "SIZE", value_parser = runtime_limits::parse_byte_size)]
+    max_memory: Option<u64>,
+
+    /// Maximum depth of nested function calls, replacing the runtime default.
+    #[arg(long, value_name = "N")]
+    max_call_depth: Option<usize>,
 }
 
 #[derive(Clone, Copy, Debug, PartialEq, Eq, ValueEnum)]
@@ -154,6 +219,10 @@ enum Commands {
         #[arg(long)]
         interpreter: bool,
 
+        /// Run on the bytecode VM explicitly (the default backend)
+        #[arg(long, default_value_t = false, conflicts_with = "interpreter")]
+        vm: bool,
+
         /// Opt in to experimental JIT compilation for JIT-compatible bytecode surfaces.
         #[arg(long, default_value_t = false)]
         jit: bool,
@@ -166,15 +235,37 @@ enum Commands {
         #[arg(long, default_value_t = false)]
         json_runtime_diagnostics: bool,
 
+        /// Write a pprof profile: `cpu=PATH` samples executing Ruff functions,
+        /// `alloc=PATH` counts object allocations per call site. Repeatable.
+        #[arg(long, value_name = "KIND=PATH", conflicts_with = "interpreter")]
+        profile: Vec<String>,
+
+        /// Compile from source even when a cached `.ruffc` entry matches
+        #[arg(long, default_value_t = false)]
+        no_cache: bool,
+
+        /// Serve the Debug Adapter Protocol on `[HOST]:PORT` (e.g. `:4711`) and run the
+        /// script on the interpreter once a client such as VS Code has attached.
+        #[arg(long, value_name = "ADDRESS", conflicts_with_all = ["profile", "jit", "vm"])]
+        dap: Option<String>,
+
+        /// Run the script again whenever it or a `.ruff` file under its directory changes.
+        /// HTTP servers reload changed modules before the next request instead of restarting.
+        #[arg(long, default_value_t = false, conflicts_with = "dap")]
+        watch: bool,
+
         #[command(flatten)]
         capabilities: CapabilityArgs,
 
+        #[command(flatten)]
+        limits: ExecutionLimitArgs,
+
         /// Arguments to pass to the script
         #[arg(trailing_var_arg = true, allow_hyphen_values = true)]
         script_args: Vec<String>,
     },
 
-    /// Validate Ruff source (lex/parse/compile) without executing the program
+    /// Validate Ruff source (lex/parse/compile/type-check) without executing the program
     Check {
         /// Path to the .ruff file
         file: PathBuf,
@@ -190,6 +281,20 @@ enum Commands {
         /// Print validation result as JSON
         #[arg(long, default_value_t = false)]
         json: bool,
+
+        /// Skip static type checking and only lex, parse, and compile
+        #[arg(long, default_value_t = false)]
+        no_types: bool,
+    },
+
+    /// Parse a Ruff source file and print its syntax tree
+    Parse {
+        /// Path to the .ruff file
+        file: PathBuf,
+
+        /// Print the syntax tree as JSON in the documented node schema
+        #[arg(long, default_value_t = false)]
+        json: bool,
     },
 
     /// Serve a directory over HTTP for local preview/testing
@@ -259,11 +364,50 @@ enum Commands {
         max_connections: usize,
     },
 
+    /// Serve a web UI for writing Ruff code in the browser and seeing its output
+    Playground {
+        /// Port to bind
+        #[arg(long, default_value_t = 8090)]
+        port: u16,
+
+        /// Host/interface to bind. Non-loopback hosts require --sandbox.
+        #[arg(long, default_value = "127.0.0.1")]
+        host: String,
+
+        /// Run programs without filesystem, process, environment, or network access, under
+        /// the time and memory limits
+        #[arg(long, default_value_t = false)]
+        sandbox: bool,
+
+        /// Wall-clock limit for each run in milliseconds.
+        #[arg(long, default_value_t = 5000)]
+        time_limit_ms: u64,
+
+        /// Memory limit for each sandboxed run in megabytes.
+        #[arg(long, default_value_t = 256)]
+        memory_limit_mb: u64,
+    },
+
+    /// Evaluate one playground run from stdin (started by `ruff playground`)
+    #[command(name = "playground-worker", hide = true)]
+    PlaygroundWorker {
+        #[arg(long, default_value_t = false)]
+        sandbox: bool,
+
+        #[arg(long, default_value_t = 5000)]
+        time_limit_ms: u64,
+    },
+
     /// Launch interactive Ruff REPL
     Repl,
 
-    /// Run all test scripts in the tests/ directory
+    /// Run fixture snapshots in tests/, or unit tests from *_test.ruff files
     Test {
+        /// Files or directories to search for *_test.ruff unit tests. Without paths,
+        /// tests/ fixtures with .out snapshots run when present; otherwise the current
+        /// directory is searched.
+        paths: Vec<PathBuf>,
+
         /// Regenerate all .out files based on actual output
         #[arg(long)]
         update: bool,
@@ -302,9 +446,14 @@ enum Commands {
         /// Number of warmup runs (default: 2)
         #[arg(short, long, default_value_t = 2)]
         warmup: usize,
+
+        /// Emit results as JSON instead of the human-readable report
+        #[arg(long, default_value_t = false)]
+        json: bool,
     },
 
-    /// Format a Ruff source file
+    /// Format a Ruff source file (alias: `fmt`)
+    #[command(visible_alias = "fmt")]
     Format {
         /// Path to the .ruff file
         file: PathBuf,
@@ -346,6 +495,10 @@ enum Commands {
         /// Print lint issues as JSON
         #[arg(long, default_value_t = false)]
         json: bool,
+
+        /// Skip the rule with this id, such as `shadowed-builtin`. Repeatable.
+        #[arg(long, value_name = "RULE")]
+        ignore: Vec<String>,
     },
 
     /// Initialize a Ruff project with ruff.toml and src/main.ruff
@@ -388,6 +541,21 @@ enum Commands {
         frozen: bool,
     },
 
+    /// Fetch git and path dependencies from ruff.toml and pin them in ruff.lock
+    Get {
+        /// Path to ruff.toml (defaults to ./ruff.toml)
+        #[arg(long)]
+        manifest: Option<PathBuf>,
+
+        /// Path to ruff.lock (defaults to sibling lockfile next to manifest)
+        #[arg(long)]
+        lockfile: Option<PathBuf>,
+
+        /// Fetch exactly the commits pinned in ruff.lock without rewriting it
+        #[arg(long, default_value_t = false)]
+        frozen: bool,
+    },
+
     /// Preview package publish metadata from ruff.toml
     PackagePublish {
         /// Path to ruff.toml (defaults to ./ruff.toml)
@@ -560,6 +728,45 @@ enum Commands {
         python: String,
     },
 
+    /// Run the cross-language benchmark suite and report Ruff against Go, Python, and Node
+    BenchSuite {
+        /// Directory holding bench.ruff and the other languages' bench.* programs
+        #[arg(long, default_value = "benchmarks/cross-language")]
+        dir: PathBuf,
+
+        /// Number of measured runs per language
+        #[arg(long, default_value_t = 5)]
+        runs: usize,
+
+        /// Number of warmup runs per language excluded from the statistics
+        #[arg(long, default_value_t = 0)]
+        warmup_runs: usize,
+
+        /// Python executable to use
+        #[arg(long, default_value = "python3")]
+        python: String,
+
+        /// Print the JSON report instead of the Markdown table
+        #[arg(long, default_value_t = false)]
+        json: bool,
+
+        /// Also write the JSON report to this file
+        #[arg(long)]
+        json_out: Option<PathBuf>,
+
+        /// Also write the Markdown table to this file
+        #[arg(long)]
+        markdown_out: Option<PathBuf>,
+
+        /// JSON report of an earlier run to check Ruff's medians against
+        #[arg(long)]
+        baseline: Option<PathBuf>,
+
+        /// Percent a Ruff median may grow over the baseline before the command fails
+        #[arg(long, default_value_t = 10.0)]
+        max_regression_percent: f64,
+    },
+
     /// Run the async SSG benchmark and optionally compare with Python
     BenchSsg {
         /// Path to Ruff SSG benchmark script
@@ -615,6 +822,34 @@ enum Commands {
         range_spread_warning_threshold: f64,
     },
 
+    /// Compile a Ruff script and the modules it imports into a standalone executable
+    Build {
+        /// Path to the entry .ruff file
+        file: PathBuf,
+
+        /// Path of the executable to write (default: the script name without `.ruff`)
+        #[arg(short = 'o', long = "output", value_name = "PATH")]
+        output: Option<PathBuf>,
+
+        /// Run the bundled script on the tree-walking interpreter instead of the VM
+        #[arg(long)]
+        interpreter: bool,
+    },
+
+    /// Run a Ruff script under the interactive step debugger (tree-walking interpreter)
+    Debug {
+        /// Path to the .ruff file
+        file: PathBuf,
+
+        /// Set a breakpoint at `FILE:LINE` or `LINE` before starting. Repeatable.
+        #[arg(long = "break", short = 'b', value_name = "FILE:LINE")]
+        breakpoints: Vec<String>,
+
+        /// Arguments to pass to the script
+        #[arg(trailing_var_arg = true, allow_hyphen_values = true)]
+        script_args: Vec<String>,
+    },
+
     /// Profile a Ruff script (CPU, memory, JIT stats)
     Profile {
         /// Path to the .ruff file
@@ -846,7 +1081,8 @@ fn build_runtime_capability_policy(args: &CapabilityArgs) -> RuntimeCapabilityPo
         || args.allow_net
         || args.allow_database
         || args.allow_clock
-        || args.allow_random;
+        || args.allow_random
+        || args.allow_ffi;
 
     if !args.untrusted && !has_explicit_allows {
         return RuntimeCapabilityPolicy::trusted();
@@ -865,9 +1101,33 @@ fn build_runtime_capability_policy(args: &CapabilityArgs) -> RuntimeCapabilityPo
     policy.database = args.allow_database;
     policy.clock = args.allow_clock;
     policy.random = args.allow_random;
+    policy.ffi = args.allow_ffi;
     policy
 }
 
+fn build_execution_limits(args: &ExecutionLimitArgs) -> Result<ExecutionLimits, String> {
+    if args.max_memory.is_some() && !runtime_limits::memory_limit_supported() {
+        return Err("--max-memory is not supported on this platform".to_string());
+    }
+    if args.max_call_depth == Some(0) {
+        return Err("--max-call-depth must be greater than 0".to_string());
+    }
+
+    let mut limits = ExecutionLimits {
+        timeout: args.timeout,
+        max_memory_bytes: args.max_memory,
+        max_call_depth: args.max_call_depth,
+    };
+    if args.sandbox {
+        limits.timeout = limits.timeout.or(Some(runtime_limits::SANDBOX_DEFAULT_TIMEOUT));
+        if runtime_limits::memory_limit_supported() {
+            limits.max_memory_bytes =
+                limits.max_memory_bytes.or(Some(runtime_limits::SANDBOX_DEFAULT_MAX_MEMORY_BYTES));
+        }
+    }
+    Ok(limits)
+}
+
 fn apply_untrusted_network_destination_policy_defaults(args: &CapabilityArgs) {
     if args.allow_all || !args.untrusted {
         return;
@@ -885,19 +1145,25 @@ fn apply_untrusted_network_destination_policy_defaults(args: &CapabilityArgs) {
 
 fn report_lexer_diagnostics_and_exit(
     _file_label: &str,
+    source: &str,
     diagnostics: &[lexer::LexerDiagnostic],
 ) -> ! {
-    let converted: Vec<errors::Diagnostic> =
-        diagnostics.iter().map(|diagnostic| diagnostic.to_diagnostic()).collect();
+    let converted: Vec<errors::Diagnostic> = diagnostics
+        .iter()
+        .map(|diagnostic| diagnostic.to_diagnostic().with_source_excerpt(source))
+        .collect();
     report_diagnostics_and_exit(&converted, CliExitCode::LexParseError);
 }
 
 fn report_parser_diagnostics_and_exit(
     file_label: &str,
+    source: &str,
     diagnostics: &[parser::ParseDiagnostic],
 ) -> ! {
-    let converted: Vec<errors::Diagnostic> =
-        diagnostics.iter().map(|diagnostic| diagnostic.to_diagnostic(Some(file_label))).collect();
+    let converted: Vec<errors::Diagnostic> = diagnostics
+        .iter()
+        .map(|diagnostic| diagnostic.to_diagnostic(Some(file_label)).with_source_excerpt(source))
+        .collect();
     report_diagnostics_and_exit(&converted, CliExitCode::LexParseError);
 }
 
@@ -964,6 +1230,53 @@ fn emit_json_or_internal_error<T: serde::Serialize>(value: &T, context: &str) {
     }
 }
 
+/// Print `ruff parse` output: one `Kind [name] line:column` row per statement of a syntax-tree
+/// document, indented under the statement that contains it.
+fn print_syntax_tree_outline(mut statements: Vec<&serde_json::Value>, depth: usize) {
+    statements.sort_by_key(|stmt| stmt["span"]["start_byte"].as_u64().unwrap_or(u64::MAX));
+
+    for stmt in statements {
+        let mut row = format!("{}{}", "  ".repeat(depth), stmt["kind"].as_str().unwrap_or("?"));
+        if let Some(name) = stmt["name"].as_str() {
+            row.push(' ');
+            row.push_str(name);
+        }
+        if let (Some(line), Some(column)) =
+            (stmt["span"]["start"]["line"].as_u64(), stmt["span"]["start"]["column"].as_u64())
+        {
+            row.push_str(&format!(" {}:{}", line, column));
+        }
+        println!("{}", row);
+
+        let mut nested = Vec::new();
+        if let Some(fields) = stmt.as_object() {
+            for (key, field) in fields {
+                if key != "span" {
+                    collect_outline_statements(field, &mut nested);
+                }
+            }
+        }
+        print_syntax_tree_outline(nested, depth + 1);
+    }
+}
+
+/// Statement nodes (objects with a `span` field) in `value`, without descending into them.
+fn collect_outline_statements<'a>(
+    value: &'a serde_json::Value,
+    out: &mut Vec<&'a serde_json::Value>,
+) {
+    match value {
+        serde_json::Value::Array(items) => {
+            items.iter().for_each(|item| collect_outline_statements(item, out))
+        }
+        serde_json::Value::Object(fields) if fields.contains_key("span") => out.push(value),
+        serde_json::Value::Object(fields) => {
+            fields.values().for_each(|field| collect_outline_statements(field, out))
+        }
+        _ => {}
+    }
+}
+
 fn report_run_runtime_diagnostic_and_exit(
     diagnostic: &errors::Diagnostic,
     code: CliExitCode,
@@ -1004,7 +1317,7 @@ fn read_ruff_source_for_parse(file: &Path) -> String {
         if metadata.is_file() && metadata.len() > max_source_bytes as u64 {
             let diagnostic =
                 parser::source_size_limit_diagnostic(metadata.len() as usize, max_source_bytes);
-            report_parser_diagnostics_and_exit(&file_label, &[diagnostic]);
+            report_parser_diagnostics_and_exit(&file_label, "", &[diagnostic]);
         }
     }
 
@@ -1019,27 +1332,157 @@ fn read_ruff_source_for_parse(file: &Path) -> String {
     };
 
     if let Err(diagnostic) = parser::validate_source_size(&code, max_source_bytes) {
-        report_parser_diagnostics_and_exit(&file_label, &[diagnostic]);
+        report_parser_diagnostics_and_exit(&file_label, "", &[diagnostic]);
     }
 
     code
 }
 
-fn parse_ruff_program(file: &Path) -> (String, String, Vec<ast::Stmt>) {
+fn parse_ruff_program(file: &Path, source_positions: bool) -> (String, String, Vec<ast::Stmt>) {
     let code = read_ruff_source_for_parse(file);
+    parse_ruff_source(file, code, source_positions)
+}
+
+fn parse_ruff_source(
+    file: &Path,
+    code: String,
+    source_positions: bool,
+) -> (String, String, Vec<ast::Stmt>) {
     let filename = file.to_string_lossy().to_string();
     let tokens = match lexer::tokenize_with_file(&code, Some(&filename)) {
         Ok(tokens) => tokens,
-        Err(diagnostics) => report_lexer_diagnostics_and_exit(&filename, &diagnostics),
+        Err(diagnostics) => report_lexer_diagnostics_and_exit(&filename, &code, &diagnostics),
     };
     let mut parser = parser::Parser::new(tokens);
+    if source_positions {
+        parser = parser.with_source_positions();
+    }
     let parse_output = parser.parse_with_diagnostics();
     if !parse_output.diagnostics.is_empty() {
-        report_parser_diagnostics_and_exit(&filename, &parse_output.diagnostics);
+        report_parser_diagnostics_and_exit(&filename, &code, &parse_output.diagnostics);
     }
     (code, filename, parse_output.stmts)
 }
 
+/// Output paths requested with `ruff run --profile KIND=PATH`.
+#[derive(Default)]
+struct ProfileTargets {
+    cpu: Option<PathBuf>,
+    alloc: Option<PathBuf>,
+}
+
+impl ProfileTargets {
+    fn parse(specs: &[String]) -> Result<Self, String> {
+        let mut targets = Self::default();
+        for spec in specs {
+            let (kind, path) = spec
+                .split_once('=')
+                .filter(|(_, path)| !path.is_empty())
+                .ok_or_else(|| format!("Invalid --profile '{}': expected KIND=PATH", spec))?;
+            let slot = match kind {
+                "cpu" => &mut targets.cpu,
+                "alloc" => &mut targets.alloc,
+                other => {
+                    return Err(format!(
+                        "Unknown profile kind '{}': expected 'cpu' or 'alloc'",
+                        other
+                    ))
+                }
+            };
+            *slot = Some(PathBuf::from(path));
+        }
+        Ok(targets)
+    }
+
+    fn write(&self, profiler: &benchmarks::SamplingProfiler, filename: &str) -> Result<(), String> {
+        let outputs = [
+            (&self.cpu, profiler.cpu_pprof(filename)),
+            (&self.alloc, profiler.alloc_pprof(filename)),
+        ];
+        for (path, encoded) in outputs {
+            if let (Some(path), Some(encoded)) = (path, encoded) {
+                fs::write(path, encoded).map_err(|error| {
+                    format!("Failed to write profile '{}': {}", path.display(), error)
+                })?;
+            }
+        }
+        Ok(())
+    }
+}
+
+/// Whether `dir` holds `.out` snapshots, which selects fixture mode for a bare `ruff test`.
+fn has_snapshot_fixtures(dir: &Path) -> bool {
+    std::fs::read_dir(dir)
+        .map(|entries| {
+            entries.flatten().any(|entry| entry.path().extension().is_some_and(|ext| ext == "out"))
+        })
+        .unwrap_or(false)
+}
+
+/// Runs every `test_*` function and `test` block in the `*_test.ruff` files under
+/// `paths`, printing per-test timing and a combined summary. Returns the exit code.
+fn run_unit_test_files(paths: &[PathBuf]) -> i32 {
+    let files = interpreter::discover_test_files(paths);
+    if files.is_empty() {
+        println!("No *_test.ruff files found");
+        return CliExitCode::RuntimeError.code();
+    }
+
+    let base_interp = interpreter::Interpreter::new();
+    let mut summary = interpreter::TestReport::default();
+    for file in &files {
+        let (_code, _filename, stmts) = parse_ruff_program(file, false);
+        let mut runner = interpreter::TestRunner::new();
+        runner.collect_module_tests(&stmts);
+
+        let report = runner.run_all(&base_interp);
+        report.print_file_results(file);
+        summary.absorb(report);
+    }
+
+    println!("{}", "=".repeat(60));
+    summary.print_totals();
+    // Same verification-failure code fixture mode uses
+    if summary.failed == 0 {
+        0
+    } else {
+        3
+    }
+}
+
+/// Runs the static type checker over `stmts`, locating each finding in `code` via the
+/// `Stmt::SourcePos` markers the parser emitted.
+fn type_check_diagnostics(
+    file: &Path,
+    filename: &str,
+    code: &str,
+    stmts: &[ast::Stmt],
+) -> Vec<errors::Diagnostic> {
+    let mut type_checker = type_checker::TypeChecker::new();
+    for search_path in entry_script_search_paths(file) {
+        type_checker.add_search_path(search_path);
+    }
+    let Err(type_errors) = type_checker.check(stmts) else {
+        return Vec::new();
+    };
+
+    type_errors
+        .into_iter()
+        .map(|error| {
+            let (line, column) = (error.location.line, error.location.column);
+            let error = if line > 0 {
+                error.with_source_position(filename, code, line, column)
+            } else {
+                error
+            };
+            error
+                .with_diagnostic_code(errors::DIAGNOSTIC_CODE_TYPE)
+                .with_subsystem(errors::DiagnosticSubsystem::Type)
+                .as_diagnostic()
+        })
+        .collect()
+}
+
 fn entry_script_search_paths(entry_file: &Path) -> Vec<PathBuf> {
     let mut search_paths = Vec::new();
 
@@ -1056,20 +1499,70 @@ fn entry_script_search_paths(entry_file: &Path) -> Vec<PathBuf> {
         }
     }
 
+    search_paths.extend(bundle::search_roots().iter().cloned());
     search_paths
 }
 
+/// Dependencies pinned in the lockfile of the project that contains the entry script.
+fn entry_script_dependency_roots(entry_file: &Path) -> Vec<(String, PathBuf)> {
+    let entry_dir = match entry_file.parent() {
+        Some(parent) if !parent.as_os_str().is_empty() => parent,
+        _ => Path::new("."),
+    };
+    package_workflow::locked_dependency_roots(entry_dir)
+}
+
+fn run_line_mode_and_exit(cli: &Cli, expr: String) -> ! {
+    if cli.command.is_some() {
+        report_cli_error_and_exit(
+            "-e/--eval cannot be combined with a subcommand",
+            CliExitCode::UsageError,
+        );
+    }
+
+    let options = line_mode::LineModeOptions {
+        expr,
+        begin: cli.begin.clone(),
+        end: cli.end.clone(),
+        no_print: cli.no_print,
+        on_error: match cli.on_error {
+            LineErrorMode::Abort => line_mode::LineErrorPolicy::Abort,
+            LineErrorMode::Skip => line_mode::LineErrorPolicy::Skip,
+        },
+    };
+
+    let mut interpreter = interpreter::Interpreter::new();
+    let stdin = std::io::stdin();
+    let mut stdout = std::io::stdout();
+    match line_mode::run_line_mode(&options, &mut interpreter, stdin.lock(), &mut stdout) {
+        Ok(_) => std::process::exit(0),
+        Err(line_mode::LineModeError::Parse(message)) => {
+            report_cli_error_and_exit(message, CliExitCode::LexParseError)
+        }
+        Err(line_mode::LineModeError::Runtime(message)) => {
+            report_cli_error_and_exit(message, CliExitCode::RuntimeError)
+        }
+        Err(line_mode::LineModeError::Io(message)) => {
+            report_cli_error_and_exit(message, CliExitCode::IoError)
+        }
+    }
+}
+
 fn is_known_cli_subcommand(name: &str) -> bool {
     matches!(
         name,
         "run"
+            | "build"
             | "check"
             | "serve"
+            | "playground"
+            | "playground-worker"
             | "repl"
             | "test"
             | "test-run"
             | "bench"
             | "format"
+            | "fmt"
             | "lint"
             | "init"
             | "package-add"
@@ -1114,7 +1607,15 @@ fn main() {
 }
 
 async fn async_main() {
-    let cli = Cli::parse();
+    let cli = match bundle::embedded_run_args() {
+        Ok(Some(args)) => Cli::parse_from(args),
+        Ok(None) => Cli::parse(),
+        Err(message) => report_cli_error_and_exit(message, CliExitCode::IoError),
+    };
+
+    if let Some(expr) = cli.eval.clone() {
+        run_line_mode_and_exit(&cli, expr);
+    }
 
     // Handle workflow pack commands via external subcommand routing.
     // When the user runs `ruff acme doctor`, clap sees "acme" as an unknown
@@ -1158,49 +1659,113 @@ async fn async_main() {
         Commands::Run {
             file,
             interpreter,
+            vm: _,
             jit,
             scheduler_timeout_ms,
             json_runtime_diagnostics,
+            profile,
+            no_cache,
+            dap,
+            watch,
             capabilities,
+            limits,
             script_args,
         } => {
+            if watch {
+                let child_args = watch::child_arguments(std::env::args_os().skip(1));
+                if let Err(message) = watch::run_watched(&file, &child_args) {
+                    report_cli_error_and_exit(message, CliExitCode::IoError);
+                }
+                return;
+            }
+            if no_cache {
+                bytecode_cache::disable();
+            }
+            // Breakpoints and stepping are implemented on the interpreter
+            let interpreter = interpreter || dap.is_some();
             let scheduler_timeout = match cooperative_scheduler_timeout(scheduler_timeout_ms) {
                 Ok(timeout) => timeout,
                 Err(error_message) => {
                     report_cli_error_and_exit(error_message, CliExitCode::UsageError);
                 }
             };
+            let profile_targets = match ProfileTargets::parse(&profile) {
+                Ok(targets) => targets,
+                Err(error_message) => {
+                    report_cli_error_and_exit(error_message, CliExitCode::UsageError);
+                }
+            };
+            let execution_limits = match build_execution_limits(&limits) {
+                Ok(execution_limits) => execution_limits,
+                Err(error_message) => {
+                    report_cli_error_and_exit(error_message, CliExitCode::UsageError);
+                }
+            };
+            let capabilities = CapabilityArgs {
+                untrusted: capabilities.untrusted || limits.sandbox,
+                ..capabilities
+            };
             apply_untrusted_network_destination_policy_defaults(&capabilities);
             let capability_policy = build_runtime_capability_policy(&capabilities);
 
             // Store script arguments in environment for args() function to retrieve
-            // We need to prepend the script filename so the filtering logic works correctly
+            // Note: This is a workaround since we can't directly modify env::args()
             if !script_args.is_empty() {
-                // Create a modified args list: [script_name, ...script_args]
-                let mut full_args: Vec<String> = std::env::args().take(3).collect(); // ruff, run, script_name
-                full_args.extend(script_args);
-
-                // Clear current args and set new ones
-                // Note: This is a workaround since we can't directly modify env::args()
-                // Instead, we'll pass these to the interpreter through the environment
-                std::env::set_var("RUFF_SCRIPT_ARGS", full_args[3..].join("\x1f"));
+                std::env::set_var("RUFF_SCRIPT_ARGS", script_args.join("\x1f"));
                 // Use unit separator
             }
 
-            let (code, filename, stmts) = parse_ruff_program(&file);
+            // Bytecode cached for unchanged source skips lexing, parsing, and compiling.
+            let code = read_ruff_source_for_parse(&file);
+            let cached_chunk = if interpreter || std::env::var("DEBUG_AST").is_ok() {
+                None
+            } else {
+                bytecode_cache::load_chunk(&file, &code)
+            };
+            // Record statement positions so runtime errors point at the failing line.
+            let (code, filename, stmts) = match cached_chunk {
+                Some(_) => (code, file.to_string_lossy().to_string(), Vec::new()),
+                None => parse_ruff_source(&file, code, true),
+            };
 
             // Debug: print AST for inspection
             if !interpreter && std::env::var("DEBUG_AST").is_ok() {
                 eprintln!("DEBUG AST: {:#?}", stmts);
             }
 
+            // The clock for --timeout starts once the program is ready to run
+            let execution_budget = (!execution_limits.is_unlimited())
+                .then(|| ExecutionBudget::start(execution_limits));
+            if let Some(budget) = &execution_budget {
+                budget.watch_deadline(move |message| {
+                    let diagnostic = errors::Diagnostic::new(
+                        errors::DIAGNOSTIC_CODE_RUNTIME,
+                        errors::DiagnosticSeverity::Error,
+                        errors::DiagnosticSubsystem::Runtime,
+                        message.to_string(),
+                    );
+                    report_run_runtime_diagnostic_and_exit(
+                        &diagnostic,
+                        CliExitCode::RuntimeError,
+                        json_runtime_diagnostics,
+                    );
+                });
+            }
+
             if !interpreter {
                 // Use bytecode compiler and VM
                 use std::sync::{Arc, Mutex};
 
-                let mut compiler = compiler::Compiler::new();
-                match compiler.compile(&stmts) {
+                let compiled = match cached_chunk {
+                    Some(chunk) => Ok(chunk),
+                    None => compiler::Compiler::new().compile(&stmts).inspect(|chunk| {
+                        bytecode_cache::store_chunk(&file, &code, chunk);
+                    }),
+                };
+                match compiled {
                     Ok(chunk) => {
+                        let (profile_cpu, profile_alloc) =
+                            (profile_targets.cpu.is_some(), profile_targets.alloc.is_some());
                         // Run the VM on a dedicated large-stack thread so deep
                         // value operations do not inherit tokio's smaller default stack.
                         let result = std::thread::Builder::new()
@@ -1211,7 +1776,17 @@ async fn async_main() {
                                 for search_path in entry_script_search_paths(&file) {
                                     vm.add_module_search_path(search_path);
                                 }
-                                let jit_requested = jit && std::env::var("DISABLE_JIT").is_err();
+                                for (name, root) in entry_script_dependency_roots(&file) {
+                                    vm.add_module_dependency_root(&name, root);
+                                }
+                                vm.set_source_file(file.to_string_lossy());
+                                let mut jit_requested =
+                                    jit && std::env::var("DISABLE_JIT").is_err();
+                                if jit_requested && execution_budget.is_some() {
+                                    // Compiled loops do not check the execution budget
+                                    eprintln!("JIT opt-in requested, but execution limits are active. Running VM bytecode without JIT.");
+                                    jit_requested = false;
+                                }
                                 vm.set_jit_enabled(jit_requested);
                                 if jit_requested {
                                     if let Err(reason) = vm.validate_jit_supported_surfaces(&chunk) {
@@ -1223,6 +1798,14 @@ async fn async_main() {
                                     }
                                 }
                                 vm.set_capability_policy(capability_policy.clone());
+                                vm.set_execution_budget(execution_budget);
+                                if profile_cpu || profile_alloc {
+                                    vm.enable_profiling(
+                                        profile_cpu,
+                                        profile_alloc,
+                                        benchmarks::sampling::DEFAULT_SAMPLE_INTERVAL,
+                                    );
+                                }
 
                                 // Set up global environment with built-in functions
                                 // We need to populate it with NativeFunction values for all built-ins
@@ -1267,7 +1850,12 @@ async fn async_main() {
                                     Err(e) => Err(e),
                                 };
 
-                                (exec_result, vm.get_call_stack())
+                                (
+                                    exec_result,
+                                    vm.error_stack_trace(),
+                                    vm.error_source_position(),
+                                    vm.take_profiler(),
+                                )
                             })
                             .unwrap_or_else(|error| {
                                 eprintln!("Error: failed to start Ruff VM thread: {}", error);
@@ -1275,20 +1863,31 @@ async fn async_main() {
                             })
                             .join();
 
+                        if let Ok((_, _, _, Some(profiler))) = &result {
+                            if let Err(error_message) = profile_targets.write(profiler, &filename) {
+                                report_cli_error_and_exit(error_message, CliExitCode::IoError);
+                            }
+                        }
+
                         match result {
-                            Ok((Ok(_result), _)) => {
+                            Ok((Ok(_result), _, _, _)) => {
                                 // Success - program executed cooperatively to completion
                             }
-                            Ok((Err(e), call_stack)) => {
+                            Ok((Err(e), stack_trace, position, _)) => {
                                 // Create a proper error with call stack
                                 use crate::errors::{
                                     DiagnosticSubsystem, RuffError, SourceLocation,
                                     DIAGNOSTIC_CODE_VM,
                                 };
-                                let error = RuffError::runtime_error(e, SourceLocation::unknown())
-                                    .with_diagnostic_code(DIAGNOSTIC_CODE_VM)
-                                    .with_subsystem(DiagnosticSubsystem::Vm)
-                                    .with_call_stack(call_stack);
+                                let mut error =
+                                    RuffError::runtime_error(e, SourceLocation::unknown())
+                                        .with_diagnostic_code(DIAGNOSTIC_CODE_VM)
+                                        .with_subsystem(DiagnosticSubsystem::Vm)
+                                        .with_stack_trace(&filename, &stack_trace);
+                                if let Some((line, column)) = position {
+                                    error =
+                                        error.with_source_position(&filename, &code, line, column);
+                                }
                                 report_run_runtime_error_and_exit(
                                     &error,
                                     CliExitCode::RuntimeError,
@@ -1341,43 +1940,59 @@ async fn async_main() {
 
                 let mut interpreter =
                     interpreter::Interpreter::with_capability_policy(capability_policy);
+                interpreter.set_execution_budget(execution_budget);
                 for search_path in entry_script_search_paths(&file) {
                     interpreter.module_loader.add_search_path(search_path);
                 }
-                interpreter.set_source(filename, &code);
+                for (name, root) in entry_script_dependency_roots(&file) {
+                    interpreter.module_loader.add_dependency_root(&name, root);
+                }
+                interpreter.set_source(filename.clone(), &code);
+                if let Some(address) = &dap {
+                    match interpreter::Debugger::serve_dap(address, &file) {
+                        Ok(debugger) => interpreter.attach_debugger(debugger),
+                        Err(error) => report_cli_error_and_exit(
+                            format!("Debug adapter failed on {}: {}", address, error),
+                            CliExitCode::IoError,
+                        ),
+                    }
+                }
 
                 // Execute statements
                 interpreter.eval_stmts(&stmts);
 
+                if let Some(debugger) = interpreter.detach_debugger() {
+                    match &interpreter.return_value {
+                        Some(interpreter::Value::Error(message))
+                        | Some(interpreter::Value::ErrorObject { message, .. }) => {
+                            debugger.finish(CliExitCode::RuntimeError.code(), Some(message))
+                        }
+                        _ => debugger.finish(0, None),
+                    }
+                }
+
                 // Check for errors in return_value and display with call stack
                 if let Some(ref val) = interpreter.return_value {
                     use crate::errors::RuffError;
-                    match val {
-                        interpreter::Value::Error(msg) => {
-                            let err = RuffError::runtime_error(
-                                msg.clone(),
-                                crate::errors::SourceLocation::unknown(),
-                            )
-                            .with_call_stack(interpreter.get_call_stack());
-                            report_run_runtime_error_and_exit(
-                                &err,
-                                CliExitCode::RuntimeError,
-                                json_runtime_diagnostics,
-                            );
-                        }
-                        interpreter::Value::ErrorObject { message, .. } => {
-                            let err = RuffError::runtime_error(
-                                message.clone(),
-                                crate::errors::SourceLocation::unknown(),
-                            )
-                            .with_call_stack(interpreter.get_call_stack());
-                            report_run_runtime_error_and_exit(
-                                &err,
-                                CliExitCode::RuntimeError,
-                                json_runtime_diagnostics,
-                            );
+                    let message = match val {
+                        interpreter::Value::Error(msg) => Some(msg.clone()),
+                        interpreter::Value::ErrorObject { message, .. } => Some(message.clone()),
+                        _ => None,
+                    };
+                    if let Some(message) = message {
+                        let mut err = RuffError::runtime_error(
+                            message,
+                            crate::errors::SourceLocation::unknown(),
+                        )
+                        .with_stack_trace(&filename, &interpreter.error_stack_trace());
+                        if let Some((line, column)) = interpreter.error_source_position() {
+                            err = err.with_source_position(&filename, &code, line, column);
                         }
-                        _ => {}
+                        report_run_runtime_error_and_exit(
+                            &err,
+                            CliExitCode::RuntimeError,
+                            json_runtime_diagnostics,
+                        );
                     }
                 }
 
@@ -1386,8 +2001,33 @@ async fn async_main() {
             }
         }
 
-        Commands::Check { file, quiet, verbose, json } => {
-            let (_code, filename, stmts) = parse_ruff_program(&file);
+        Commands::Parse { file, json } => {
+            let code = read_ruff_source_for_parse(&file);
+            let filename = file.to_string_lossy().to_string();
+            let tree = match syntax_tree::parse_to_json(&code, Some(&filename)) {
+                Ok(tree) => tree,
+                Err(diagnostics) => {
+                    let converted: Vec<errors::Diagnostic> = diagnostics
+                        .into_iter()
+                        .map(|diagnostic| diagnostic.with_source_excerpt(&code))
+                        .collect();
+                    report_diagnostics_and_exit(&converted, CliExitCode::LexParseError);
+                }
+            };
+
+            if json {
+                emit_json_or_internal_error(&tree, "syntax tree");
+            } else {
+                let mut statements = Vec::new();
+                collect_outline_statements(&tree["body"], &mut statements);
+                print_syntax_tree_outline(statements, 0);
+            }
+        }
+
+        Commands::Check { file, quiet, verbose, json, no_types } => {
+            let (code, filename, stmts) = parse_ruff_program(&file, true);
+            let statement_count =
+                stmts.iter().filter(|stmt| !matches!(stmt, ast::Stmt::SourcePos { .. })).count();
             let mut compiler = compiler::Compiler::new();
             let instruction_count = match compiler.compile(&stmts) {
                 Ok(chunk) => chunk.instructions.len(),
@@ -1402,12 +2042,20 @@ async fn async_main() {
                 }
             };
 
+            // Annotations are ignored at runtime; this is where mismatches become failures
+            if !no_types {
+                let diagnostics = type_check_diagnostics(&file, &filename, &code, &stmts);
+                if !diagnostics.is_empty() {
+                    report_diagnostics_and_exit(&diagnostics, CliExitCode::RuntimeError);
+                }
+            }
+
             if json {
                 let output = serde_json::json!({
                     "command": "check",
                     "file": filename,
                     "status": "ok",
-                    "statement_count": stmts.len(),
+                    "statement_count": statement_count,
                     "bytecode_instruction_count": instruction_count,
                 });
                 match serde_json::to_string_pretty(&output) {
@@ -1427,7 +2075,7 @@ async fn async_main() {
                     println!(
                         "check passed: {} (statements={}, bytecode_instructions={})",
                         file.display(),
-                        stmts.len(),
+                        statement_count,
                         instruction_count
                     );
                 } else {
@@ -1476,6 +2124,27 @@ async fn async_main() {
             }
         }
 
+        Commands::Playground { port, host, sandbox, time_limit_ms, memory_limit_mb } => {
+            let options = playground::PlaygroundOptions {
+                sandbox,
+                time_limit: Duration::from_millis(time_limit_ms),
+                memory_limit_mb,
+            };
+
+            if let Err(message) = playground::run_playground_server(host, port, options) {
+                eprintln!("{}", message);
+                std::process::exit(CliExitCode::RuntimeError.code());
+            }
+        }
+
+        Commands::PlaygroundWorker { sandbox, time_limit_ms } => {
+            if let Err(message) =
+                playground::run_worker(sandbox, Duration::from_millis(time_limit_ms))
+            {
+                report_cli_error_and_exit(message, CliExitCode::IoError);
+            }
+        }
+
         Commands::Repl => match repl::Repl::new() {
             Ok(mut repl) => {
                 if let Err(e) = repl.run() {
@@ -1489,8 +2158,13 @@ async fn async_main() {
             }
         },
 
-        Commands::Test { update, runtime, verbose } => {
+        Commands::Test { paths, update, runtime, verbose } => {
             use std::path::Path;
+            if !paths.is_empty() || !has_snapshot_fixtures(Path::new("tests")) {
+                let search_paths = if paths.is_empty() { vec![PathBuf::from(".")] } else { paths };
+                std::process::exit(run_unit_test_files(&search_paths));
+            }
+
             let runtime_strategy = match runtime {
                 TestRuntimeMode::Interpreter => parser::TestRuntimeStrategy::Interpreter,
                 TestRuntimeMode::Vm => parser::TestRuntimeStrategy::Vm,
@@ -1507,7 +2181,7 @@ async fn async_main() {
 
         Commands::TestRun { file, verbose, capabilities } => {
             apply_untrusted_network_destination_policy_defaults(&capabilities);
-            let (_code, _filename, stmts) = parse_ruff_program(&file);
+            let (_code, _filename, stmts) = parse_ruff_program(&file, false);
 
             // Create base interpreter with standard library loaded
             let base_interp = interpreter::Interpreter::with_capability_policy(
@@ -1533,12 +2207,17 @@ async fn async_main() {
             std::process::exit(report.exit_code());
         }
 
-        Commands::Bench { path, iterations, warmup } => {
+        Commands::Bench { path, iterations, warmup, json } => {
             use benchmarks::{BenchmarkRunner, Reporter};
 
-            let runner = BenchmarkRunner::new().with_iterations(iterations).with_warmup(warmup);
+            let runner = BenchmarkRunner::new()
+                .with_iterations(iterations)
+                .with_warmup(warmup)
+                .with_quiet_output(json);
 
-            Reporter::print_header("Ruff Performance Benchmarks");
+            if !json {
+                Reporter::print_header("Ruff Performance Benchmarks");
+            }
 
             let results = if let Some(p) = path {
                 if p.is_dir() {
@@ -1563,6 +2242,11 @@ async fn async_main() {
                 }
             };
 
+            if json {
+                println!("{}", Reporter::render_json(&results));
+                return;
+            }
+
             // Print individual results
             for (name, bench_results) in &results {
                 println!("\n{}", name);
@@ -1591,7 +2275,20 @@ async fn async_main() {
                 line_length,
                 sort_imports: !no_sort_imports,
             };
-            let formatted = formatter::format_source(&source, &options);
+            let file_label = file.display().to_string();
+            let formatted = match formatter::format_source(&source, &options) {
+                Ok(formatted) => formatted,
+                Err(formatter::FormatError::Lex(diagnostics)) => {
+                    report_lexer_diagnostics_and_exit(&file_label, &source, &diagnostics)
+                }
+                Err(formatter::FormatError::Parse(diagnostics)) => {
+                    report_parser_diagnostics_and_exit(&file_label, &source, &diagnostics)
+                }
+                Err(error @ formatter::FormatError::Unstable(_)) => report_cli_error_and_exit(
+                    format!("Failed to format '{}': {}", file_label, error.message()),
+                    CliExitCode::InternalError,
+                ),
+            };
             let changed = source != formatted;
 
             if write {
@@ -1638,7 +2335,7 @@ async fn async_main() {
                 }
 
                 if check && changed {
-                    std::process::exit(CliExitCode::RuntimeError.code());
+                    std::process::exit(1);
                 }
 
                 return;
@@ -1649,16 +2346,25 @@ async fn async_main() {
                     println!("already formatted");
                 } else {
                     println!("needs formatting");
-                    std::process::exit(CliExitCode::RuntimeError.code());
+                    std::process::exit(1);
                 }
             } else if write {
                 println!("formatted {}", file.display());
             } else {
-                println!("{}", formatted);
+                print!("{}", formatted);
             }
         }
 
-        Commands::Lint { file, fix, json } => {
+        Commands::Lint { file, fix, json, ignore } => {
+            let mut lint = linter::Linter::new();
+            for rule_id in &ignore {
+                if !lint.disable(rule_id) {
+                    report_cli_error_and_exit(
+                        format!("Unknown lint rule '{}' passed to --ignore", rule_id),
+                        CliExitCode::UsageError,
+                    );
+                }
+            }
             let source = match fs::read_to_string(&file) {
                 Ok(content) => content,
                 Err(err) => {
@@ -1666,7 +2372,7 @@ async fn async_main() {
                     std::process::exit(CliExitCode::IoError.code());
                 }
             };
-            let issues = linter::lint_source(&source);
+            let issues = lint.lint(&source);
 
             if fix {
                 let fixed = linter::apply_safe_fixes(&source, &issues);
@@ -1817,7 +2523,13 @@ async fn async_main() {
 
             let lockfile_path =
                 lockfile.unwrap_or_else(|| package_workflow::default_lockfile_path(&manifest_path));
-            let expected_lockfile = package_workflow::lockfile_from_manifest(&parsed);
+            let mut expected_lockfile = package_workflow::lockfile_from_manifest(&parsed);
+            let previous_lockfile = fs::read_to_string(&lockfile_path)
+                .ok()
+                .and_then(|content| package_workflow::parse_lockfile(&content).ok());
+            if let Some(previous) = &previous_lockfile {
+                package_workflow::carry_over_resolved(&mut expected_lockfile, previous);
+            }
 
             if frozen {
                 let lockfile_content = match fs::read_to_string(&lockfile_path) {
@@ -1870,6 +2582,109 @@ async fn async_main() {
             }
         }
 
+        Commands::Get { manifest, lockfile, frozen } => {
+            let manifest_path = manifest.unwrap_or_else(|| PathBuf::from("ruff.toml"));
+            let content = match fs::read_to_string(&manifest_path) {
+                Ok(content) => content,
+                Err(err) => {
+                    eprintln!("Failed to read '{}': {}", manifest_path.display(), err);
+                    std::process::exit(CliExitCode::IoError.code());
+                }
+            };
+            let parsed = match package_workflow::parse_manifest(&content) {
+                Ok(manifest_data) => manifest_data,
+                Err(message) => {
+                    eprintln!("{}", message);
+                    std::process::exit(CliExitCode::RuntimeError.code());
+                }
+            };
+
+            let lockfile_path =
+                lockfile.unwrap_or_else(|| package_workflow::default_lockfile_path(&manifest_path));
+            let previous_lockfile = match fs::read_to_string(&lockfile_path) {
+                Ok(lockfile_content) => match package_workflow::parse_lockfile(&lockfile_content) {
+                    Ok(lockfile_data) => Some(lockfile_data),
+                    Err(message) => {
+                        eprintln!("{}", message);
+                        std::process::exit(CliExitCode::RuntimeError.code());
+                    }
+                },
+                Err(_) if !frozen => None,
+                Err(err) => {
+                    eprintln!("Failed to read '{}': {}", lockfile_path.display(), err);
+                    std::process::exit(CliExitCode::IoError.code());
+                }
+            };
+
+            let mut lockfile_data = package_workflow::lockfile_from_manifest(&parsed);
+            if let Some(previous) = &previous_lockfile {
+                if frozen {
+                    if let Err(message) =
+                        package_workflow::verify_lockfile_matches_manifest(&parsed, previous)
+                    {
+                        eprintln!("{}", message);
+                        std::process::exit(CliExitCode::RuntimeError.code());
+                    }
+                }
+                package_workflow::carry_over_resolved(&mut lockfile_data, previous);
+            }
+
+            for (dependency_name, spec) in parsed.dependencies.iter() {
+                let locked = lockfile_data.resolved.get(dependency_name);
+                if frozen && locked.is_none() && !matches!(spec, DependencySpec::Version(_)) {
+                    eprintln!(
+                        "ruff.lock has no pinned source for '{}'; run `ruff get` without --frozen",
+                        dependency_name
+                    );
+                    std::process::exit(CliExitCode::RuntimeError.code());
+                }
+
+                match package_workflow::fetch_dependency(
+                    &manifest_path,
+                    dependency_name,
+                    spec,
+                    locked,
+                ) {
+                    Ok(FetchOutcome::Fetched(resolved)) => {
+                        println!(
+                            "fetched\t{}\t{}\t{}",
+                            dependency_name,
+                            resolved.source,
+                            resolved.commit.as_deref().unwrap_or("-")
+                        );
+                        lockfile_data.resolved.insert(dependency_name.clone(), resolved);
+                    }
+                    Ok(FetchOutcome::SkippedRegistry(version)) => {
+                        println!(
+                            "skipped\t{}\t{}\tregistry dependencies are not fetched yet",
+                            dependency_name, version
+                        );
+                    }
+                    Err(message) => {
+                        eprintln!("{}", message);
+                        std::process::exit(CliExitCode::RuntimeError.code());
+                    }
+                }
+            }
+
+            if !frozen {
+                let lockfile_text = match package_workflow::serialize_lockfile(&lockfile_data) {
+                    Ok(lockfile_text) => lockfile_text,
+                    Err(message) => {
+                        eprintln!("{}", message);
+                        std::process::exit(CliExitCode::RuntimeError.code());
+                    }
+                };
+
+                if let Err(err) = fs::write(&lockfile_path, lockfile_text) {
+                    eprintln!("Failed to write '{}': {}", lockfile_path.display(), err);
+                    std::process::exit(CliExitCode::IoError.code());
+                }
+
+                println!("lockfile written\t{}", lockfile_path.display());
+            }
+        }
+
         Commands::PackagePublish { manifest, publish } => {
             let manifest_path = manifest.unwrap_or_else(|| PathBuf::from("ruff.toml"));
             let content = match fs::read_to_string(&manifest_path) {
@@ -2220,6 +3035,110 @@ async fn async_main() {
             }
         }
 
+        Commands::BenchSuite {
+            dir,
+            runs,
+            warmup_runs,
+            python,
+            json,
+            json_out,
+            markdown_out,
+            baseline,
+            max_regression_percent,
+        } => {
+            use benchmarks::run_benchmark_suite;
+            use benchmarks::suite::SuiteOptions;
+
+            if runs == 0 {
+                eprintln!("Benchmark suite runs must be >= 1");
+                std::process::exit(CliExitCode::UsageError.code());
+            }
+
+            let ruff_binary = match std::env::current_exe() {
+                Ok(path) => path,
+                Err(e) => {
+                    eprintln!("Failed to determine Ruff binary path: {}", e);
+                    std::process::exit(CliExitCode::InternalError.code());
+                }
+            };
+
+            if !dir.join("bench.ruff").exists() {
+                eprintln!("Ruff benchmark suite not found: {}", dir.join("bench.ruff").display());
+                std::process::exit(CliExitCode::IoError.code());
+            }
+
+            let baseline = baseline.map(|path| {
+                let text = match fs::read_to_string(&path) {
+                    Ok(text) => text,
+                    Err(e) => {
+                        eprintln!("Failed to read baseline '{}': {}", path.display(), e);
+                        std::process::exit(CliExitCode::IoError.code());
+                    }
+                };
+                match serde_json::from_str::<serde_json::Value>(&text) {
+                    Ok(baseline) => baseline,
+                    Err(e) => {
+                        eprintln!("Baseline '{}' is not a JSON report: {}", path.display(), e);
+                        std::process::exit(CliExitCode::UsageError.code());
+                    }
+                }
+            });
+
+            let options = SuiteOptions {
+                dir: dir.as_path(),
+                ruff_binary: ruff_binary.as_path(),
+                python: python.as_str(),
+                runs,
+                warmup_runs,
+            };
+            let mut report = match run_benchmark_suite(&options) {
+                Ok(report) => report,
+                Err(e) => {
+                    eprintln!("Benchmark suite failed: {}", e);
+                    std::process::exit(CliExitCode::RuntimeError.code());
+                }
+            };
+
+            if let Some(baseline) = baseline {
+                if let Err(e) = report.compare_with_baseline(&baseline, max_regression_percent) {
+                    eprintln!("Baseline comparison failed: {}", e);
+                    std::process::exit(CliExitCode::UsageError.code());
+                }
+            }
+
+            let json_report = match serde_json::to_string_pretty(&report.render_json()) {
+                Ok(serialized) => serialized,
+                Err(e) => {
+                    eprintln!("Failed to serialize benchmark suite report: {}", e);
+                    std::process::exit(CliExitCode::InternalError.code());
+                }
+            };
+            let markdown = report.render_markdown();
+            for (path, contents) in [(&json_out, &json_report), (&markdown_out, &markdown)] {
+                if let Some(path) = path {
+                    if let Err(e) = fs::write(path, format!("{}\n", contents.trim_end())) {
+                        eprintln!("Failed to write '{}': {}", path.display(), e);
+                        std::process::exit(CliExitCode::IoError.code());
+                    }
+                }
+            }
+
+            if json {
+                println!("{}", json_report);
+            } else {
+                print!("{}", markdown);
+            }
+
+            if !report.regressions.is_empty() {
+                eprintln!(
+                    "Benchmark suite regression gate failed: {} Ruff benchmark(s) slowed by more than {:.1}%",
+                    report.regressions.len(),
+                    max_regression_percent
+                );
+                std::process::exit(1);
+            }
+        }
+
         Commands::BenchSsg {
             ruff_script,
             warmup_runs,
@@ -2607,6 +3526,73 @@ async fn async_main() {
             }
         }
 
+        Commands::Build { file, output, interpreter } => {
+            // Report syntax errors in the entry script the same way `ruff check` does
+            parse_ruff_program(&file, false);
+            let output = output
+                .unwrap_or_else(|| PathBuf::from(file.file_stem().unwrap_or(file.as_os_str())));
+
+            let mut loader = module::ModuleLoader::new();
+            for search_path in entry_script_search_paths(&file) {
+                loader.add_search_path(search_path);
+            }
+            for (name, root) in entry_script_dependency_roots(&file) {
+                loader.add_dependency_root(&name, root);
+            }
+            match bundle::build(&file, &output, &loader, interpreter) {
+                Ok(summary) => println!(
+                    "Built {} ({} bundled module{})",
+                    summary.output.display(),
+                    summary.modules,
+                    if summary.modules == 1 { "" } else { "s" }
+                ),
+                Err(message) => report_cli_error_and_exit(message, CliExitCode::IoError),
+            }
+        }
+
+        Commands::Debug { file, breakpoints, script_args } => {
+            let mut debugger = interpreter::Debugger::console();
+            for spec in &breakpoints {
+                match interpreter::Breakpoint::parse(spec) {
+                    Ok(breakpoint) => debugger.add_breakpoint(breakpoint),
+                    Err(error_message) => {
+                        report_cli_error_and_exit(error_message, CliExitCode::UsageError);
+                    }
+                }
+            }
+            if !script_args.is_empty() {
+                std::env::set_var("RUFF_SCRIPT_ARGS", script_args.join("\x1f"));
+            }
+
+            // The debugger pauses at positioned statements, so keep statement positions.
+            let (code, filename, stmts) = parse_ruff_program(&file, true);
+            let mut interpreter = interpreter::Interpreter::new();
+            for search_path in entry_script_search_paths(&file) {
+                interpreter.module_loader.add_search_path(search_path);
+            }
+            for (name, root) in entry_script_dependency_roots(&file) {
+                interpreter.module_loader.add_dependency_root(&name, root);
+            }
+            interpreter.set_source(filename.clone(), &code);
+            interpreter.attach_debugger(debugger);
+            interpreter.eval_stmts(&stmts);
+
+            if let Some(message) = match &interpreter.return_value {
+                Some(interpreter::Value::Error(message)) => Some(message.clone()),
+                Some(interpreter::Value::ErrorObject { message, .. }) => Some(message.clone()),
+                _ => None,
+            } {
+                let mut err =
+                    errors::RuffError::runtime_error(message, errors::SourceLocation::unknown())
+                        .with_stack_trace(&filename, &interpreter.error_stack_trace());
+                if let Some((line, column)) = interpreter.error_source_position() {
+                    err = err.with_source_position(&filename, &code, line, column);
+                }
+                report_run_runtime_error_and_exit(&err, CliExitCode::RuntimeError, false);
+            }
+            interpreter.cleanup();
+        }
+
         Commands::Profile { file, cpu, memory, jit, flamegraph } => {
             use benchmarks::{
                 print_profile_report, profiler::generate_flamegraph_data, ProfileConfig, Profiler,
@@ -2630,12 +3616,14 @@ async fn async_main() {
             // Execute the code
             let tokens = match lexer::tokenize_with_file(&code, Some(&filename)) {
                 Ok(tokens) => tokens,
-                Err(diagnostics) => report_lexer_diagnostics_and_exit(&filename, &diagnostics),
+                Err(diagnostics) => {
+                    report_lexer_diagnostics_and_exit(&filename, &code, &diagnostics)
+                }
             };
             let mut parser = parser::Parser::new(tokens);
             let parse_output = parser.parse_with_diagnostics();
             if !parse_output.diagnostics.is_empty() {
-                report_parser_diagnostics_and_exit(&filename, &parse_output.diagnostics);
+                report_parser_diagnostics_and_exit(&filename, &code, &parse_output.diagnostics);
             }
             let stmts = parse_output.stmts;
 
@@ -2771,6 +3759,7 @@ async fn async_main() {
                         "symbol": info.symbol,
                         "kind": info.kind,
                         "detail": info.detail,
+                        "type": info.type_name,
                         "line": info.line,
                         "column": info.column,
                     }),
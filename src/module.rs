@@ -1,9 +1,11 @@
 use crate::ast::{Expr, Pattern, Stmt};
+use crate::bytecode_cache;
 use crate::errors::{ErrorKind, RuffError};
-use crate::interpreter::{Environment, Interpreter, Value};
+use crate::interpreter::{Environment, Interpreter, RuntimeCapabilityPolicy, Value};
 use crate::lexer::tokenize_with_file;
 use crate::parser::Parser;
 use crate::path_security;
+use crate::runtime_limits::ExecutionBudget;
 use std::collections::{HashMap, HashSet};
 use std::fs;
 use std::path::{Path, PathBuf};
@@ -19,6 +21,77 @@ pub struct Module {
     pub exports: HashMap<String, Value>,
 }
 
+/// A cached module whose source changed, evaluated again by
+/// `ModuleLoader::reload_changed_modules`.
+#[derive(Debug, Clone)]
+pub struct ModuleReload {
+    pub previous: Module,
+    /// The re-evaluated module, or why it failed to load. A module that fails keeps its
+    /// previous exports until its source changes again.
+    pub reloaded: Result<Module, String>,
+}
+
+impl ModuleReload {
+    /// What replaces `value` after this reload: the new export of the same name when
+    /// `value` is a function or struct definition the previous module exported, or the new
+    /// namespace when `value` is the previous module's.
+    pub fn replacement_for(&self, value: &Value) -> Option<Value> {
+        let reloaded = self.reloaded.as_ref().ok()?;
+        match value {
+            Value::Module { name, .. } if *name == self.previous.name => Some(Value::Module {
+                name: name.clone(),
+                exports: Arc::new(reloaded.exports.clone()),
+            }),
+            Value::Function(..)
+            | Value::AsyncFunction(..)
+            | Value::GeneratorDef(..)
+            | Value::StructDef { .. } => {
+                let (export_name, _) = self
+                    .previous
+                    .exports
+                    .iter()
+                    .find(|(_, export)| Value::equals(export, value))?;
+                reloaded.exports.get(export_name).cloned()
+            }
+            _ => None,
+        }
+    }
+}
+
+/// Point the global bindings of `env` and the route handlers of a running HTTP server at
+/// the exports of `reloads`, reporting each reload on stderr.
+pub fn apply_module_reloads(
+    reloads: &[ModuleReload],
+    env: &mut Environment,
+    routes: &mut [(String, String, Value)],
+) {
+    for reload in reloads {
+        match &reload.reloaded {
+            Ok(_) => eprintln!("[watch] reloaded module {}", reload.previous.name),
+            Err(message) => eprintln!(
+                "[watch] keeping the previous version of module {}: {}",
+                reload.previous.name, message
+            ),
+        }
+    }
+
+    let replacement =
+        |value: &Value| reloads.iter().find_map(|reload| reload.replacement_for(value));
+    let rebound: Vec<(String, Value)> = env
+        .globals
+        .iter()
+        .filter_map(|(name, value)| Some((name.clone(), replacement(value)?)))
+        .collect();
+    for (name, value) in rebound {
+        env.set(name, value);
+    }
+    for (_, _, handler) in routes.iter_mut() {
+        if let Some(value) = replacement(handler) {
+            *handler = value;
+        }
+    }
+}
+
 #[derive(Debug, Clone, PartialEq, Eq, Hash)]
 struct ModuleCacheKey {
     package_root: PathBuf,
@@ -75,6 +148,13 @@ pub struct ModuleLoader {
     loading_stack_index: HashMap<ModuleCacheKey, usize>,
     /// Search paths for module resolution.
     search_paths: Vec<PathBuf>,
+    /// Locked package dependencies: imports whose first segment names one of these
+    /// resolve inside its source directory.
+    dependency_roots: Vec<(String, PathBuf)>,
+    /// Capabilities and execution budget that module top-level code runs with; the
+    /// owning interpreter keeps them in step with its own.
+    capability_policy: RuntimeCapabilityPolicy,
+    execution_budget: Option<Arc<ExecutionBudget>>,
 }
 
 impl ModuleLoader {
@@ -123,7 +203,8 @@ impl ModuleLoader {
             Stmt::Let { pattern, .. } => Self::collect_pattern_bindings(pattern, names),
             Stmt::Const { name, .. }
             | Stmt::FuncDef { name, .. }
-            | Stmt::StructDef { name, .. } => names.push(name.clone()),
+            | Stmt::StructDef { name, .. }
+            | Stmt::InterfaceDef { name, .. } => names.push(name.clone()),
             Stmt::EnumDef { name, variants } => {
                 for variant in variants {
                     names.push(format!("{}::{}", name, variant));
@@ -134,6 +215,13 @@ impl ModuleLoader {
                     names.push(name.clone());
                 }
             }
+            Stmt::MultiAssign { targets, .. } => {
+                for target in targets {
+                    if let Expr::Identifier(name) = target {
+                        names.push(name.clone());
+                    }
+                }
+            }
             Stmt::ExprStmt(Expr::Identifier(name)) => names.push(name.clone()),
             Stmt::Block(stmts) => {
                 for nested_stmt in stmts {
@@ -198,20 +286,46 @@ impl ModuleLoader {
             loading_stack: Vec::new(),
             loading_stack_index: HashMap::new(),
             search_paths: vec![PathBuf::from("."), PathBuf::from("./modules")],
+            dependency_roots: Vec::new(),
+            capability_policy: RuntimeCapabilityPolicy::trusted(),
+            execution_budget: None,
         }
     }
 
+    pub fn set_capability_policy(&mut self, capability_policy: RuntimeCapabilityPolicy) {
+        self.capability_policy = capability_policy;
+    }
+
+    pub fn set_execution_budget(&mut self, budget: Option<Arc<ExecutionBudget>>) {
+        self.execution_budget = budget;
+    }
+
     /// Adds a search path for module resolution.
     #[allow(dead_code)]
     pub fn add_search_path<P: AsRef<Path>>(&mut self, path: P) {
         self.search_paths.push(path.as_ref().to_path_buf());
     }
 
+    /// Registers a fetched package dependency so `pkg.module` and `"pkg/module"`
+    /// imports resolve inside `root`; a bare `pkg` import loads `root/lib.ruff`.
+    pub fn add_dependency_root<P: AsRef<Path>>(&mut self, name: &str, root: P) {
+        self.dependency_roots.push((name.to_string(), root.as_ref().to_path_buf()));
+    }
+
     fn module_search_roots(&self) -> Vec<PathBuf> {
+        self.module_search_roots_from(
+            self.loading_stack
+                .last()
+                .map(|active_module| active_module.cache_key.package_root.as_path()),
+        )
+    }
+
+    /// Name imports search the importing module's package root, then the search paths.
+    fn module_search_roots_from(&self, importer_root: Option<&Path>) -> Vec<PathBuf> {
         let mut roots = Vec::new();
 
-        if let Some(active_module) = self.loading_stack.last() {
-            roots.push(active_module.cache_key.package_root.clone());
+        if let Some(importer_root) = importer_root {
+            roots.push(importer_root.to_path_buf());
         }
 
         roots.extend(self.search_paths.iter().cloned());
@@ -276,10 +390,136 @@ impl ModuleLoader {
         module_name: &str,
     ) -> Result<Option<ResolvedModulePath>, Box<RuffError>> {
         let resolution_candidates = self.module_resolution_candidates(module_name)?;
+        Self::resolve_in_roots(module_name, &resolution_candidates, self.module_search_roots())
+    }
+
+    /// Resolves an import inside a registered dependency. Local modules win, so this
+    /// runs only after the regular search roots found nothing.
+    fn resolve_dependency_module(
+        &self,
+        module_name: &str,
+        segments: &[&str],
+    ) -> Result<Option<ResolvedModulePath>, Box<RuffError>> {
+        let Some((package, rest)) = segments.split_first() else {
+            return Ok(None);
+        };
+        let Some((_, root)) = self.dependency_roots.iter().find(|(name, _)| name == package) else {
+            return Ok(None);
+        };
+
+        let filename = if rest.is_empty() {
+            "lib.ruff".to_string()
+        } else {
+            format!("{}.ruff", rest.join("/"))
+        };
+        let normalized = path_security::sanitize_relative_path(&filename, "module import")
+            .map_err(|error| {
+                Self::runtime_error(format!("Unsafe module import '{}': {}", module_name, error))
+            })?;
+
+        Self::resolve_in_roots(module_name, &[normalized], vec![root.clone()])
+    }
+
+    /// Search roots for `import "path"`: the importing file's directory, then each
+    /// `RUFF_PATH` entry, then the default search paths.
+    fn path_import_search_roots(&self, importer_file: Option<&Path>) -> Vec<PathBuf> {
+        let mut roots = Vec::new();
+
+        let importer_dir = importer_file.and_then(|file| file.parent()).map(|dir| {
+            if dir.as_os_str().is_empty() {
+                Path::new(".")
+            } else {
+                dir
+            }
+        });
+        if let Some(dir) = importer_dir {
+            roots.push(dir.to_path_buf());
+        }
+
+        if let Some(ruff_path) = std::env::var_os("RUFF_PATH") {
+            roots.extend(
+                std::env::split_paths(&ruff_path).filter(|entry| !entry.as_os_str().is_empty()),
+            );
+        }
+
+        roots.extend(self.search_paths.iter().cloned());
+        roots
+    }
+
+    /// Resolves an `import "path"` string to a file path.
+    fn resolve_import_path(
+        &self,
+        import_path: &str,
+        importer_file: Option<&Path>,
+    ) -> Result<Option<ResolvedModulePath>, Box<RuffError>> {
+        let filename = if import_path.ends_with(".ruff") {
+            import_path.to_string()
+        } else {
+            format!("{}.ruff", import_path)
+        };
+        let normalized = path_security::sanitize_relative_path(&filename, "module import")
+            .map_err(|error| {
+                Self::runtime_error(format!("Unsafe module import '{}': {}", import_path, error))
+            })?;
+
+        let resolved = Self::resolve_in_roots(
+            import_path,
+            &[normalized],
+            self.path_import_search_roots(importer_file),
+        )?;
+        if resolved.is_some() {
+            return Ok(resolved);
+        }
+
+        let stem = import_path.strip_suffix(".ruff").unwrap_or(import_path);
+        let segments: Vec<&str> = stem.split('/').collect();
+        self.resolve_dependency_module(import_path, &segments)
+    }
+
+    /// Resolves `import name` to the file it would load without loading it, for tools
+    /// such as `ruff build` that walk a program's imports statically. `importer_root` is
+    /// the package root returned for the importing module (`None` for the entry script).
+    ///
+    /// Returns the canonical module path and the package root it was found in.
+    pub fn resolve_module_file(
+        &self,
+        module_name: &str,
+        importer_root: Option<&Path>,
+    ) -> Result<Option<(PathBuf, PathBuf)>, Box<RuffError>> {
+        let resolution_candidates = self.module_resolution_candidates(module_name)?;
+        let resolved = match Self::resolve_in_roots(
+            module_name,
+            &resolution_candidates,
+            self.module_search_roots_from(importer_root),
+        )? {
+            Some(resolved) => Some(resolved),
+            None => {
+                let segments: Vec<&str> = module_name.split('.').collect();
+                self.resolve_dependency_module(module_name, &segments)?
+            }
+        };
+        Ok(resolved.map(|resolved| (resolved.module_path, resolved.cache_key.package_root)))
+    }
 
+    /// Resolves `import "path"` from `importer_file` like [`Self::resolve_module_file`].
+    pub fn resolve_path_import_file(
+        &self,
+        import_path: &str,
+        importer_file: &Path,
+    ) -> Result<Option<(PathBuf, PathBuf)>, Box<RuffError>> {
+        Ok(self
+            .resolve_import_path(import_path, Some(importer_file))?
+            .map(|resolved| (resolved.module_path, resolved.cache_key.package_root)))
+    }
+
+    fn resolve_in_roots(
+        module_name: &str,
+        resolution_candidates: &[PathBuf],
+        search_roots: Vec<PathBuf>,
+    ) -> Result<Option<ResolvedModulePath>, Box<RuffError>> {
         let mut visited_roots = HashSet::new();
 
-        for search_path in self.module_search_roots() {
+        for search_path in search_roots {
             let canonical_search_root =
                 match path_security::canonicalize_root(&search_path, "module search path") {
                     Ok(path) => path,
@@ -290,7 +530,7 @@ impl ModuleLoader {
                 continue;
             }
 
-            for normalized_filename in &resolution_candidates {
+            for normalized_filename in resolution_candidates {
                 let full_path = canonical_search_root.join(normalized_filename);
                 if full_path.exists() {
                     let canonical_module_path = fs::canonicalize(&full_path).map_err(|error| {
@@ -333,13 +573,52 @@ impl ModuleLoader {
 
     /// Loads a module by name, returning cached version if available.
     pub fn load_module(&mut self, module_name: &str) -> Result<Module, Box<RuffError>> {
-        let resolved_module = self.resolve_module_path(module_name)?.ok_or_else(|| {
+        let resolved_module = match self.resolve_module_path(module_name)? {
+            Some(resolved) => Some(resolved),
+            None => {
+                let segments: Vec<&str> = module_name.split('.').collect();
+                self.resolve_dependency_module(module_name, &segments)?
+            }
+        };
+        let resolved_module = resolved_module.ok_or_else(|| {
             let help = Self::missing_module_help(module_name);
             Self::runtime_error_with_help(
                 format!("Module not found: {}; {}", module_name, help),
                 help,
             )
         })?;
+        self.load_resolved_module(module_name, resolved_module)
+    }
+
+    /// Loads a module by file path for `import "path"`, resolving it against the
+    /// importing file's directory, then `RUFF_PATH`, then the default search paths.
+    ///
+    /// Path imports share the name-import cache, so a file imported both ways is
+    /// evaluated once.
+    pub fn load_module_path(
+        &mut self,
+        import_path: &str,
+        importer_file: Option<&Path>,
+    ) -> Result<Module, Box<RuffError>> {
+        let resolved_module =
+            self.resolve_import_path(import_path, importer_file)?.ok_or_else(|| {
+                let help = format!(
+                    "Paths are resolved relative to the importing file, then each RUFF_PATH entry, then '.' and './modules'; check that '{}' exists in one of them.",
+                    import_path
+                );
+                Self::runtime_error_with_help(
+                    format!("Module not found: {}; {}", import_path, help),
+                    help,
+                )
+            })?;
+        self.load_resolved_module(import_path, resolved_module)
+    }
+
+    fn load_resolved_module(
+        &mut self,
+        module_name: &str,
+        resolved_module: ResolvedModulePath,
+    ) -> Result<Module, Box<RuffError>> {
         let cache_key = resolved_module.cache_key.clone();
 
         if let Some(cycle_start) = self.loading_stack_index.get(&cache_key).copied() {
@@ -377,41 +656,20 @@ impl ModuleLoader {
                 Self::runtime_error(format!("Failed to read module '{}': {}", module_name, e))
             })?;
 
-            let tokens = tokenize_with_file(&source, Some(&module_path.to_string_lossy()))
-                .map_err(|diagnostics| {
-                    let first = diagnostics
-                        .first()
-                        .map(|diagnostic| {
-                            format!(
-                                "{}:{}: {}",
-                                diagnostic.line, diagnostic.column, diagnostic.message
-                            )
-                        })
-                        .unwrap_or_else(|| "unknown lexer error".to_string());
-                    Self::runtime_error(format!(
-                        "Failed to tokenize module '{}': {}",
-                        module_name, first
-                    ))
-                })?;
-            let mut parser = Parser::new(tokens);
-            let parse_output = parser.parse_with_diagnostics();
-            if !parse_output.diagnostics.is_empty() {
-                let first = parse_output
-                    .diagnostics
-                    .first()
-                    .map(|diagnostic| {
-                        format!("{}:{}: {}", diagnostic.line, diagnostic.column, diagnostic.message)
-                    })
-                    .unwrap_or_else(|| "unknown parser error".to_string());
-                return Err(Self::runtime_error(format!(
-                    "Failed to parse module '{}': {}",
-                    module_name, first
-                )));
-            }
-            let program = parse_output.stmts;
+            // Unchanged modules reuse the statements parsed by an earlier run.
+            let program = match bytecode_cache::load_module_program(&module_path, &source) {
+                Some(program) => program,
+                None => {
+                    let program = Self::parse_module_source(module_name, &module_path, &source)?;
+                    bytecode_cache::store_module_program(&module_path, &source, &program);
+                    program
+                }
+            };
             let export_names = Self::collect_exported_symbol_names(&program);
 
-            let mut interpreter = Interpreter::new();
+            let mut interpreter =
+                Interpreter::with_capability_policy(self.capability_policy.clone());
+            interpreter.set_execution_budget(self.execution_budget.clone());
             let mut active_loader = std::mem::take(self);
             if let Some(parent) = module_path.parent() {
                 active_loader.add_search_path(parent);
@@ -470,6 +728,76 @@ impl ModuleLoader {
         Ok(module)
     }
 
+    fn parse_module_source(
+        module_name: &str,
+        module_path: &Path,
+        source: &str,
+    ) -> Result<Vec<Stmt>, Box<RuffError>> {
+        let tokens = tokenize_with_file(source, Some(&module_path.to_string_lossy())).map_err(
+            |diagnostics| {
+                let first = diagnostics
+                    .first()
+                    .map(|diagnostic| {
+                        format!("{}:{}: {}", diagnostic.line, diagnostic.column, diagnostic.message)
+                    })
+                    .unwrap_or_else(|| "unknown lexer error".to_string());
+                Self::runtime_error(format!(
+                    "Failed to tokenize module '{}': {}",
+                    module_name, first
+                ))
+            },
+        )?;
+        let mut parser = Parser::new(tokens);
+        let parse_output = parser.parse_with_diagnostics();
+        if !parse_output.diagnostics.is_empty() {
+            let first = parse_output
+                .diagnostics
+                .first()
+                .map(|diagnostic| {
+                    format!("{}:{}: {}", diagnostic.line, diagnostic.column, diagnostic.message)
+                })
+                .unwrap_or_else(|| "unknown parser error".to_string());
+            return Err(Self::runtime_error(format!(
+                "Failed to parse module '{}': {}",
+                module_name, first
+            )));
+        }
+        Ok(parse_output.stmts)
+    }
+
+    /// Evaluate again every cached module whose source file changed since it was loaded,
+    /// in module-name order. Modules whose file is gone are left cached.
+    pub fn reload_changed_modules(&mut self) -> Vec<ModuleReload> {
+        let mut stale: Vec<(ModuleCacheKey, Module, ModuleSourceState)> = self
+            .loaded_modules
+            .iter()
+            .filter_map(|(cache_key, cached)| {
+                let state = ModuleSourceState::from_path(&cached.module.path).ok()?;
+                (state != cached.source_state)
+                    .then(|| (cache_key.clone(), cached.module.clone(), state))
+            })
+            .collect();
+        stale.sort_by(|a, b| a.1.name.cmp(&b.1.name));
+
+        stale
+            .into_iter()
+            .map(|(cache_key, previous, source_state)| {
+                let resolved = ResolvedModulePath {
+                    module_path: previous.path.clone(),
+                    cache_key: cache_key.clone(),
+                };
+                let reloaded = self
+                    .load_resolved_module(&previous.name, resolved)
+                    .map_err(|error| error.message);
+                if reloaded.is_err() {
+                    self.loaded_modules
+                        .insert(cache_key, CachedModule { module: previous.clone(), source_state });
+                }
+                ModuleReload { previous, reloaded }
+            })
+            .collect()
+    }
+
     /// Gets a specific symbol from a module.
     pub fn get_symbol(
         &mut self,
@@ -766,6 +1094,55 @@ mod tests {
         fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
     }
 
+    #[test]
+    fn reload_changed_modules_maps_previous_functions_to_reloaded_ones() {
+        let mut loader = ModuleLoader::new();
+        let temp_root = std::env::temp_dir().join(unique_name("ruff_module_hot_reload"));
+        fs::create_dir_all(&temp_root).expect("failed to create temp module dir");
+
+        let module_name = unique_name("handlers");
+        let module_path = temp_root.join(format!("{}.ruff", module_name));
+        fs::write(&module_path, "export func index(req) {\n    return 1\n}\n")
+            .expect("failed to write initial module source");
+        loader.add_search_path(&temp_root);
+
+        let previous_index =
+            loader.get_symbol(&module_name, "index").expect("expected initial module export");
+        assert!(loader.reload_changed_modules().is_empty());
+
+        fs::write(&module_path, "export func index(req) {\n    return 200\n}\n")
+            .expect("failed to update module source");
+        let reloads = loader.reload_changed_modules();
+        assert_eq!(reloads.len(), 1);
+        let reloaded_index =
+            reloads[0].replacement_for(&previous_index).expect("index should be replaced");
+        let current_index =
+            loader.get_symbol(&module_name, "index").expect("expected reloaded module export");
+        assert!(Value::equals(&reloaded_index, &current_index));
+        assert!(!Value::equals(&reloaded_index, &previous_index));
+        assert!(reloads[0].replacement_for(&Value::Int(1)).is_none());
+
+        let mut env = Environment::new();
+        env.set("index".to_string(), previous_index.clone());
+        let mut routes = vec![("GET".to_string(), "/".to_string(), previous_index)];
+        apply_module_reloads(&reloads, &mut env, &mut routes);
+        assert!(Value::equals(&env.get("index").expect("index binding"), &current_index));
+        assert!(Value::equals(&routes[0].2, &current_index));
+
+        fs::write(&module_path, "export func index(req) {\n    return (\n")
+            .expect("failed to write broken module source");
+        let failed = loader.reload_changed_modules();
+        assert_eq!(failed.len(), 1);
+        assert!(failed[0].reloaded.is_err());
+        assert!(loader.reload_changed_modules().is_empty());
+        let kept =
+            loader.get_symbol(&module_name, "index").expect("previous export should be kept");
+        assert!(Value::equals(&kept, &current_index));
+
+        fs::remove_file(&module_path).expect("failed to clean up module file");
+        fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
+    }
+
     #[test]
     fn load_module_resolves_dotted_from_import_name_to_nested_module_path() {
         let mut loader = ModuleLoader::new();
@@ -850,6 +1227,80 @@ mod tests {
         fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
     }
 
+    #[test]
+    fn load_module_path_prefers_importer_directory_then_ruff_path() {
+        let mut loader = ModuleLoader::new();
+        let temp_root = std::env::temp_dir().join(unique_name("ruff_module_path_import"));
+        let app_dir = temp_root.join("app");
+        let lib_dir = temp_root.join("lib");
+        fs::create_dir_all(&app_dir).expect("failed to create importer dir");
+        fs::create_dir_all(lib_dir.join("text")).expect("failed to create RUFF_PATH dir");
+
+        fs::write(app_dir.join("util.ruff"), "export origin := \"app\"\n")
+            .expect("failed to write importer-relative module");
+        fs::write(lib_dir.join("util.ruff"), "export origin := \"lib\"\n")
+            .expect("failed to write shadowed RUFF_PATH module");
+        fs::write(lib_dir.join("text").join("pad.ruff"), "export origin := \"lib\"\n")
+            .expect("failed to write RUFF_PATH module");
+
+        std::env::set_var("RUFF_PATH", &lib_dir);
+        let importer = app_dir.join("main.ruff");
+        let relative = loader.load_module_path("util", Some(&importer));
+        let from_ruff_path = loader.load_module_path("text/pad.ruff", Some(&importer));
+        std::env::remove_var("RUFF_PATH");
+
+        let relative = relative.expect("expected importer-relative module to load");
+        assert!(
+            matches!(relative.exports.get("origin"), Some(Value::Str(s)) if s.as_str() == "app"),
+            "expected importer directory to win over RUFF_PATH, got {:?}",
+            relative.exports.get("origin")
+        );
+        let from_ruff_path = from_ruff_path.expect("expected RUFF_PATH module to load");
+        assert!(
+            matches!(from_ruff_path.exports.get("origin"), Some(Value::Str(s)) if s.as_str() == "lib"),
+            "expected RUFF_PATH fallback, got {:?}",
+            from_ruff_path.exports.get("origin")
+        );
+
+        fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
+    }
+
+    #[test]
+    fn load_module_resolves_registered_dependency_after_local_modules() {
+        let mut loader = ModuleLoader::new();
+        let temp_root = std::env::temp_dir().join(unique_name("ruff_module_dependency"));
+        let local_dir = temp_root.join("app");
+        let dependency_dir = temp_root.join("deps").join("textlib").join("src");
+        fs::create_dir_all(&local_dir).expect("failed to create local module dir");
+        fs::create_dir_all(&dependency_dir).expect("failed to create dependency dir");
+
+        let package = unique_name("textlib");
+        fs::write(dependency_dir.join("lib.ruff"), "export origin := \"dependency\"\n")
+            .expect("failed to write dependency entry module");
+        fs::write(dependency_dir.join("fmt.ruff"), "export width := 8\n")
+            .expect("failed to write dependency module");
+        fs::write(local_dir.join("fmt.ruff"), "export width := 1\n")
+            .expect("failed to write local module");
+
+        loader.add_search_path(&local_dir);
+        loader.add_dependency_root(&package, &dependency_dir);
+
+        let entry = loader.get_symbol(&package, "origin").expect("expected bare dependency import");
+        assert!(matches!(entry, Value::Str(s) if s.as_str() == "dependency"));
+        let nested = loader
+            .get_symbol(&format!("{}.fmt", package), "width")
+            .expect("expected dotted dependency import");
+        assert!(matches!(nested, Value::Int(8)));
+        let by_path = loader
+            .load_module_path(&format!("{}/fmt", package), None)
+            .expect("expected path import into dependency");
+        assert!(matches!(by_path.exports.get("width"), Some(Value::Int(8))));
+        let local = loader.get_symbol("fmt", "width").expect("expected local module import");
+        assert!(matches!(local, Value::Int(1)), "local modules should not resolve to dependencies");
+
+        fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
+    }
+
     #[test]
     fn load_module_rejects_dotted_module_names_with_empty_segments() {
         let mut loader = ModuleLoader::new();
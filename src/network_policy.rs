@@ -230,7 +230,15 @@ pub fn apply_udp_socket_timeouts(socket: &UdpSocket, surface: &str) -> Result<()
 }
 
 pub fn connect_tcp_stream(address: &str, surface: &str) -> Result<TcpStream, String> {
-    let timeout = connect_timeout();
+    connect_tcp_stream_with_timeout(address, surface, connect_timeout())
+}
+
+/// Like `connect_tcp_stream`, with a caller-chosen connect timeout.
+pub fn connect_tcp_stream_with_timeout(
+    address: &str,
+    surface: &str,
+    timeout: Duration,
+) -> Result<TcpStream, String> {
     let addresses = address
         .to_socket_addrs()
         .map_err(|error| format!("{} failed to resolve '{}': {}", surface, address, error))?;
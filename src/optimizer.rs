@@ -5,7 +5,8 @@
 // and other performance improvements.
 
 use crate::bytecode::{BytecodeChunk, Constant, OpCode};
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
+use std::sync::Arc;
 
 /// Main optimizer for bytecode chunks
 pub struct Optimizer {
@@ -17,6 +18,7 @@ pub struct Optimizer {
 #[derive(Debug, Default, Clone)]
 pub struct OptimizationStats {
     pub constants_folded: usize,
+    pub branches_eliminated: usize,
     pub dead_instructions_removed: usize,
     pub peephole_optimizations: usize,
     pub total_instructions_before: usize,
@@ -32,19 +34,22 @@ impl Optimizer {
     pub fn optimize(&mut self, chunk: &mut BytecodeChunk) {
         self.stats.total_instructions_before = chunk.instructions.len();
 
-        // Pass 1: Constant folding
-        self.constant_folding_pass(chunk);
+        // Pass 1: Constant folding, repeated so folded operands feed their enclosing expression
+        while self.constant_folding_pass(chunk) {}
 
-        // Pass 2: Dead code elimination
+        // Pass 2: Constant branch elimination
+        self.constant_branch_pass(chunk);
+
+        // Pass 3: Dead code elimination
         self.dead_code_elimination_pass(chunk);
 
-        // Pass 3: Peephole optimizations
+        // Pass 4: Peephole optimizations
         self.peephole_optimization_pass(chunk);
 
         // Also optimize nested functions in constants
         for constant in &mut chunk.constants {
             if let Constant::Function(func_chunk) = constant {
-                self.optimize(func_chunk);
+                self.optimize(Arc::make_mut(func_chunk));
             }
         }
 
@@ -52,14 +57,23 @@ impl Optimizer {
     }
 
     /// Pass 1: Constant Folding
-    /// Evaluates constant expressions at compile time
-    fn constant_folding_pass(&mut self, chunk: &mut BytecodeChunk) {
+    /// Evaluates constant expressions at compile time; returns whether anything was folded
+    fn constant_folding_pass(&mut self, chunk: &mut BytecodeChunk) -> bool {
+        let targets = Self::jump_targets(chunk);
+        let old_len = chunk.instructions.len();
+        let folded_before = self.stats.constants_folded;
         let mut new_instructions = Vec::new();
+        let mut index_map = HashMap::new();
         let mut i = 0;
 
         while i < chunk.instructions.len() {
+            index_map.insert(i, new_instructions.len());
             // Look for pattern: LoadConst, LoadConst, BinaryOp
-            if i + 2 < chunk.instructions.len() {
+            // (never across a jump target, where another path supplies the operand)
+            if i + 2 < chunk.instructions.len()
+                && !targets.contains(&(i + 1))
+                && !targets.contains(&(i + 2))
+            {
                 if let (OpCode::LoadConst(idx1), OpCode::LoadConst(idx2), binary_op) =
                     (&chunk.instructions[i], &chunk.instructions[i + 1], &chunk.instructions[i + 2])
                 {
@@ -81,7 +95,7 @@ impl Optimizer {
             }
 
             // Look for pattern: LoadConst, UnaryOp (like Negate, Not)
-            if i + 1 < chunk.instructions.len() {
+            if i + 1 < chunk.instructions.len() && !targets.contains(&(i + 1)) {
                 if let (OpCode::LoadConst(idx), unary_op) =
                     (&chunk.instructions[i], &chunk.instructions[i + 1])
                 {
@@ -102,6 +116,131 @@ impl Optimizer {
         }
 
         chunk.instructions = new_instructions;
+        Self::remap_jump_targets(chunk, &index_map, old_len);
+        Self::remap_source_map(chunk, &index_map);
+        self.stats.constants_folded > folded_before
+    }
+
+    /// Pass 2: Constant Branch Elimination
+    /// Resolves conditional jumps on a boolean literal so dead code elimination can
+    /// drop the branch that is never taken
+    fn constant_branch_pass(&mut self, chunk: &mut BytecodeChunk) {
+        let targets = Self::jump_targets(chunk);
+        let old_len = chunk.instructions.len();
+        let mut new_instructions = Vec::new();
+        let mut index_map = HashMap::new();
+        let mut i = 0;
+
+        while i < chunk.instructions.len() {
+            index_map.insert(i, new_instructions.len());
+
+            if i + 1 < chunk.instructions.len() && !targets.contains(&(i + 1)) {
+                if let (OpCode::LoadConst(idx), jump) =
+                    (&chunk.instructions[i], &chunk.instructions[i + 1])
+                {
+                    let branch = match (&chunk.constants[*idx], jump) {
+                        (Constant::Bool(value), OpCode::JumpIfFalse(target)) => {
+                            Some((!value, *target))
+                        }
+                        (Constant::Bool(value), OpCode::JumpIfTrue(target)) => {
+                            Some((*value, *target))
+                        }
+                        _ => None,
+                    };
+
+                    if let Some((taken, target)) = branch {
+                        if !taken {
+                            // Falls through: the condition stays on the stack for the
+                            // branch's own Pop, which the peephole pass then removes
+                            new_instructions.push(OpCode::LoadConst(*idx));
+                        } else if matches!(chunk.instructions.get(target), Some(OpCode::Pop)) {
+                            // The target only discards the condition, so skip both
+                            new_instructions.push(OpCode::Jump(target + 1));
+                        } else {
+                            new_instructions.push(OpCode::LoadConst(*idx));
+                            new_instructions.push(OpCode::Jump(target));
+                        }
+                        self.stats.branches_eliminated += 1;
+                        i += 2;
+                        continue;
+                    }
+                }
+            }
+
+            new_instructions.push(chunk.instructions[i].clone());
+            i += 1;
+        }
+
+        chunk.instructions = new_instructions;
+        Self::remap_jump_targets(chunk, &index_map, old_len);
+        Self::remap_source_map(chunk, &index_map);
+    }
+
+    /// Instruction indices that control can arrive at other than by falling through.
+    fn jump_targets(chunk: &BytecodeChunk) -> HashSet<usize> {
+        let mut targets: HashSet<usize> = chunk
+            .instructions
+            .iter()
+            .filter_map(|instruction| match instruction {
+                OpCode::Jump(target)
+                | OpCode::JumpIfFalse(target)
+                | OpCode::JumpIfTrue(target)
+                | OpCode::JumpBack(target)
+                | OpCode::BeginTry(target) => Some(*target),
+                _ => None,
+            })
+            .collect();
+        for handler in &chunk.exception_handlers {
+            targets.insert(handler.try_start);
+            targets.insert(handler.try_end);
+            targets.insert(handler.catch_start);
+        }
+        targets
+    }
+
+    /// Point jumps and exception handlers at the new indices of their targets.
+    /// A target whose instruction was removed moves to the next surviving one.
+    fn remap_jump_targets(
+        chunk: &mut BytecodeChunk,
+        index_map: &HashMap<usize, usize>,
+        old_len: usize,
+    ) {
+        let new_len = chunk.instructions.len();
+        let resolve = |target: usize| {
+            (target..old_len).find_map(|old| index_map.get(&old).copied()).unwrap_or(new_len)
+        };
+
+        for instruction in &mut chunk.instructions {
+            match instruction {
+                OpCode::Jump(ref mut target)
+                | OpCode::JumpIfFalse(ref mut target)
+                | OpCode::JumpIfTrue(ref mut target)
+                | OpCode::JumpBack(ref mut target)
+                | OpCode::BeginTry(ref mut target) => {
+                    *target = resolve(*target);
+                }
+                _ => {}
+            }
+        }
+
+        for handler in &mut chunk.exception_handlers {
+            handler.try_start = resolve(handler.try_start);
+            handler.try_end = resolve(handler.try_end);
+            handler.catch_start = resolve(handler.catch_start);
+        }
+    }
+
+    /// Move source positions to the new indices of the instructions they mark.
+    /// Positions on removed instructions are dropped; when several land on the same
+    /// instruction, the later statement wins as it would have at compile time.
+    fn remap_source_map(chunk: &mut BytecodeChunk, index_map: &HashMap<usize, usize>) {
+        let mut positions: Vec<_> = chunk.source_map.drain().collect();
+        positions.sort_by_key(|(old_index, _)| *old_index);
+        for (old_index, position) in positions {
+            if let Some(&new_index) = index_map.get(&old_index) {
+                chunk.source_map.insert(new_index, position);
+            }
+        }
     }
 
     /// Try to fold a binary operation on two constants
@@ -233,7 +372,7 @@ impl Optimizer {
         }
     }
 
-    /// Pass 2: Dead Code Elimination
+    /// Pass 3: Dead Code Elimination
     /// Removes unreachable instructions
     fn dead_code_elimination_pass(&mut self, chunk: &mut BytecodeChunk) {
         let mut reachable = vec![false; chunk.instructions.len()];
@@ -261,36 +400,10 @@ impl Optimizer {
             }
         }
 
-        // Update all jump targets to use new indices
-        for instruction in &mut new_instructions {
-            match instruction {
-                OpCode::Jump(ref mut target)
-                | OpCode::JumpIfFalse(ref mut target)
-                | OpCode::JumpIfTrue(ref mut target)
-                | OpCode::JumpBack(ref mut target)
-                | OpCode::BeginTry(ref mut target) => {
-                    if let Some(&new_target) = index_map.get(target) {
-                        *target = new_target;
-                    }
-                }
-                _ => {}
-            }
-        }
-
-        // Update exception handler indices
-        for handler in &mut chunk.exception_handlers {
-            if let Some(&new_start) = index_map.get(&handler.try_start) {
-                handler.try_start = new_start;
-            }
-            if let Some(&new_end) = index_map.get(&handler.try_end) {
-                handler.try_end = new_end;
-            }
-            if let Some(&new_catch) = index_map.get(&handler.catch_start) {
-                handler.catch_start = new_catch;
-            }
-        }
-
+        let old_len = chunk.instructions.len();
         chunk.instructions = new_instructions;
+        Self::remap_jump_targets(chunk, &index_map, old_len);
+        Self::remap_source_map(chunk, &index_map);
     }
 
     /// Mark all reachable instructions starting from a given index
@@ -346,17 +459,21 @@ impl Optimizer {
         }
     }
 
-    /// Pass 3: Peephole Optimizations
+    /// Pass 4: Peephole Optimizations
     /// Optimizes small sequences of instructions
     fn peephole_optimization_pass(&mut self, chunk: &mut BytecodeChunk) {
+        let targets = Self::jump_targets(chunk);
+        let old_len = chunk.instructions.len();
         let mut new_instructions = Vec::new();
+        let mut index_map = HashMap::new();
         let mut i = 0;
 
         while i < chunk.instructions.len() {
+            index_map.insert(i, new_instructions.len());
             let mut optimized = false;
 
             // Pattern 1: LoadConst followed by Pop (useless load)
-            if i + 1 < chunk.instructions.len() {
+            if i + 1 < chunk.instructions.len() && !targets.contains(&(i + 1)) {
                 if matches!(chunk.instructions[i], OpCode::LoadConst(_))
                     && matches!(chunk.instructions[i + 1], OpCode::Pop)
                 {
@@ -368,7 +485,7 @@ impl Optimizer {
             }
 
             // Pattern 2: StoreVar followed by LoadVar of same variable
-            if !optimized && i + 1 < chunk.instructions.len() {
+            if !optimized && i + 1 < chunk.instructions.len() && !targets.contains(&(i + 1)) {
                 if let (OpCode::StoreVar(var1), OpCode::LoadVar(var2)) =
                     (&chunk.instructions[i], &chunk.instructions[i + 1])
                 {
@@ -416,6 +533,8 @@ impl Optimizer {
         }
 
         chunk.instructions = new_instructions;
+        Self::remap_jump_targets(chunk, &index_map, old_len);
+        Self::remap_source_map(chunk, &index_map);
     }
 
     /// Get a summary of optimization results
@@ -433,10 +552,12 @@ impl Optimizer {
         format!(
             "Optimization Summary:\n\
              - Constants folded: {}\n\
+             - Branches eliminated: {}\n\
              - Dead instructions removed: {}\n\
              - Peephole optimizations: {}\n\
              - Instructions: {} -> {} (reduced by {})",
             self.stats.constants_folded,
+            self.stats.branches_eliminated,
             self.stats.dead_instructions_removed,
             self.stats.peephole_optimizations,
             self.stats.total_instructions_before,
@@ -565,4 +686,130 @@ mod tests {
         assert_eq!(chunk.instructions.len(), 3);
         assert_eq!(optimizer.stats.constants_folded, 0);
     }
+
+    #[test]
+    fn test_constant_folding_nested_expression() {
+        let mut chunk = BytecodeChunk::new();
+
+        // Create: 2 * 3 + 4 (the folded product feeds the addition)
+        let idx1 = chunk.add_constant(Constant::Int(2));
+        let idx2 = chunk.add_constant(Constant::Int(3));
+        let idx3 = chunk.add_constant(Constant::Int(4));
+        chunk.emit(OpCode::LoadConst(idx1));
+        chunk.emit(OpCode::LoadConst(idx2));
+        chunk.emit(OpCode::Mul);
+        chunk.emit(OpCode::LoadConst(idx3));
+        chunk.emit(OpCode::Add);
+
+        let mut optimizer = Optimizer::new();
+        optimizer.optimize(&mut chunk);
+
+        assert_eq!(chunk.instructions.len(), 1);
+        let OpCode::LoadConst(idx) = chunk.instructions[0] else {
+            panic!("expected a single LoadConst");
+        };
+        assert_eq!(chunk.constants[idx], Constant::Int(10));
+        assert_eq!(optimizer.stats.constants_folded, 2);
+    }
+
+    #[test]
+    fn test_folding_inside_loop_remaps_jumps() {
+        let mut chunk = BytecodeChunk::new();
+
+        // Create: loop { if x { x = 2 + 3 } } where folding shifts the jump targets
+        let two = chunk.add_constant(Constant::Int(2));
+        let three = chunk.add_constant(Constant::Int(3));
+        chunk.emit(OpCode::LoadVar("x".to_string())); // 0: loop start
+        chunk.emit(OpCode::JumpIfFalse(8)); // 1
+        chunk.emit(OpCode::Pop); // 2
+        chunk.emit(OpCode::LoadConst(two)); // 3
+        chunk.emit(OpCode::LoadConst(three)); // 4
+        chunk.emit(OpCode::Add); // 5
+        chunk.emit(OpCode::StoreVar("x".to_string())); // 6
+        chunk.emit(OpCode::JumpBack(0)); // 7
+        chunk.emit(OpCode::Pop); // 8: loop exit
+        chunk.emit(OpCode::ReturnNone); // 9
+
+        let mut optimizer = Optimizer::new();
+        optimizer.optimize(&mut chunk);
+
+        assert_eq!(optimizer.stats.constants_folded, 1);
+        assert!(matches!(chunk.instructions[1], OpCode::JumpIfFalse(6)));
+        assert!(matches!(chunk.instructions[5], OpCode::JumpBack(0)));
+        assert!(matches!(chunk.instructions[6], OpCode::Pop));
+    }
+
+    #[test]
+    fn test_no_folding_across_jump_target() {
+        let mut chunk = BytecodeChunk::new();
+
+        // The second operand is also reached by a jump carrying its own left operand
+        let one = chunk.add_constant(Constant::Int(1));
+        chunk.emit(OpCode::Jump(2)); // 0
+        chunk.emit(OpCode::LoadConst(one)); // 1
+        chunk.emit(OpCode::LoadConst(one)); // 2: jump target
+        chunk.emit(OpCode::Add); // 3
+        chunk.emit(OpCode::Return); // 4
+
+        let mut optimizer = Optimizer::new();
+        optimizer.constant_folding_pass(&mut chunk);
+
+        assert_eq!(chunk.instructions.len(), 5);
+        assert_eq!(optimizer.stats.constants_folded, 0);
+    }
+
+    #[test]
+    fn test_constant_false_condition_removes_branch() {
+        let mut chunk = BytecodeChunk::new();
+
+        // Create: if false { print 1 } else { print 2 }
+        let cond = chunk.add_constant(Constant::Bool(false));
+        let one = chunk.add_constant(Constant::Int(1));
+        let two = chunk.add_constant(Constant::Int(2));
+        chunk.emit(OpCode::LoadConst(cond)); // 0
+        chunk.emit(OpCode::JumpIfFalse(5)); // 1
+        chunk.emit(OpCode::Pop); // 2
+        chunk.emit(OpCode::LoadConst(one)); // 3
+        chunk.emit(OpCode::Jump(7)); // 4
+        chunk.emit(OpCode::Pop); // 5: else
+        chunk.emit(OpCode::LoadConst(two)); // 6
+        chunk.emit(OpCode::Return); // 7
+
+        let mut optimizer = Optimizer::new();
+        optimizer.optimize(&mut chunk);
+
+        assert_eq!(optimizer.stats.branches_eliminated, 1);
+        assert_eq!(chunk.instructions.len(), 3);
+        assert!(matches!(chunk.instructions[0], OpCode::Jump(1)));
+        assert!(matches!(chunk.instructions[1], OpCode::LoadConst(idx) if idx == two));
+        assert!(matches!(chunk.instructions[2], OpCode::Return));
+    }
+
+    #[test]
+    fn test_constant_true_condition_keeps_only_then_branch() {
+        let mut chunk = BytecodeChunk::new();
+
+        // Create: if true { print 1 } else { print 2 }
+        let cond = chunk.add_constant(Constant::Bool(true));
+        let one = chunk.add_constant(Constant::Int(1));
+        let two = chunk.add_constant(Constant::Int(2));
+        chunk.emit(OpCode::LoadConst(cond)); // 0
+        chunk.emit(OpCode::JumpIfFalse(5)); // 1
+        chunk.emit(OpCode::Pop); // 2
+        chunk.emit(OpCode::LoadConst(one)); // 3
+        chunk.emit(OpCode::Jump(7)); // 4
+        chunk.emit(OpCode::Pop); // 5: else
+        chunk.emit(OpCode::LoadConst(two)); // 6
+        chunk.emit(OpCode::Return); // 7
+
+        let mut optimizer = Optimizer::new();
+        optimizer.optimize(&mut chunk);
+
+        assert_eq!(optimizer.stats.branches_eliminated, 1);
+        assert!(chunk
+            .instructions
+            .iter()
+            .all(|instruction| !matches!(instruction, OpCode::LoadConst(idx) if *idx == two)));
+        assert!(matches!(chunk.instructions[0], OpCode::LoadConst(idx) if idx == one));
+    }
 }
@@ -1,21 +1,80 @@
 use crate::reserved_names;
 use serde::{Deserialize, Serialize};
 use std::collections::BTreeMap;
+use std::fmt;
+use std::fs;
 use std::path::{Path, PathBuf};
+use std::process::Command;
 
 pub const LOCKFILE_SCHEMA_VERSION: u32 = 1;
 
+/// Project-local directory that `ruff get` clones git dependencies into.
+pub const DEPENDENCY_CACHE_DIR: &str = ".ruff/deps";
+
 #[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
 pub struct PackageMetadata {
     pub name: String,
     pub version: String,
 }
 
+/// A `[dependencies]` entry: a bare registry version requirement (`http = "1.2"`)
+/// or a table naming a git repository or local path to fetch from.
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+#[serde(untagged)]
+pub enum DependencySpec {
+    Version(String),
+    Source(DependencySource),
+}
+
+#[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
+#[serde(deny_unknown_fields)]
+pub struct DependencySource {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub version: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub git: Option<String>,
+    /// Branch, tag, or commit to check out; defaults to the remote's HEAD.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub rev: Option<String>,
+    /// Directory relative to the manifest.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub path: Option<String>,
+}
+
+impl fmt::Display for DependencySpec {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            DependencySpec::Version(version) => write!(f, "{}", version),
+            DependencySpec::Source(source) => match (&source.git, &source.path) {
+                (Some(url), _) => match &source.rev {
+                    Some(rev) => write!(f, "git+{}#{}", url, rev),
+                    None => write!(f, "git+{}", url),
+                },
+                (None, Some(path)) => write!(f, "path+{}", path),
+                (None, None) => write!(f, "{}", source.version.as_deref().unwrap_or("*")),
+            },
+        }
+    }
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
 pub struct RuffManifest {
     pub package: PackageMetadata,
     #[serde(default)]
-    pub dependencies: BTreeMap<String, String>,
+    pub dependencies: BTreeMap<String, DependencySpec>,
+}
+
+/// Where `ruff get` placed a dependency, recorded so module resolution and later
+/// fetches use exactly the same checkout.
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq)]
+pub struct ResolvedDependency {
+    /// `git+<url>` or `path+<dir>`.
+    pub source: String,
+    /// Commit checked out for git dependencies.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub commit: Option<String>,
+    /// Checkout directory relative to the lockfile.
+    pub dir: String,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq)]
@@ -23,7 +82,17 @@ pub struct RuffLockfile {
     pub schema_version: u32,
     pub package: PackageMetadata,
     #[serde(default)]
-    pub dependencies: BTreeMap<String, String>,
+    pub dependencies: BTreeMap<String, DependencySpec>,
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub resolved: BTreeMap<String, ResolvedDependency>,
+}
+
+/// Outcome of fetching one dependency with `ruff get`.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum FetchOutcome {
+    Fetched(ResolvedDependency),
+    /// Registry dependencies are declared but not fetched yet.
+    SkippedRegistry(String),
 }
 
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
@@ -65,6 +134,17 @@ pub fn lockfile_from_manifest(manifest: &RuffManifest) -> RuffLockfile {
         schema_version: LOCKFILE_SCHEMA_VERSION,
         package: manifest.package.clone(),
         dependencies: manifest.dependencies.clone(),
+        resolved: BTreeMap::new(),
+    }
+}
+
+/// Keep `resolved` entries from `previous` whose dependency spec is unchanged, so
+/// regenerating the lockfile does not drop pinned commits.
+pub fn carry_over_resolved(lockfile: &mut RuffLockfile, previous: &RuffLockfile) {
+    for (name, resolved) in &previous.resolved {
+        if lockfile.dependencies.get(name) == previous.dependencies.get(name) {
+            lockfile.resolved.insert(name.clone(), resolved.clone());
+        }
     }
 }
 
@@ -102,19 +182,198 @@ pub fn verify_lockfile_matches_manifest(
 }
 
 pub fn default_lockfile_path(manifest_path: &Path) -> PathBuf {
-    manifest_path.parent().unwrap_or_else(|| Path::new(".")).join("ruff.lock")
+    project_dir(manifest_path).join("ruff.lock")
+}
+
+fn project_dir(manifest_path: &Path) -> &Path {
+    match manifest_path.parent() {
+        Some(parent) if !parent.as_os_str().is_empty() => parent,
+        _ => Path::new("."),
+    }
+}
+
+/// Fetch one dependency into the project's cache for `ruff get`.
+///
+/// Git dependencies are cloned into `.ruff/deps/<name>` and checked out at the commit
+/// pinned in `locked` when it is still valid for `spec`, otherwise at `rev` (or the
+/// remote HEAD). Path dependencies are only checked for existence.
+pub fn fetch_dependency(
+    manifest_path: &Path,
+    name: &str,
+    spec: &DependencySpec,
+    locked: Option<&ResolvedDependency>,
+) -> Result<FetchOutcome, String> {
+    let source = match spec {
+        DependencySpec::Version(version) => {
+            return Ok(FetchOutcome::SkippedRegistry(version.clone()));
+        }
+        DependencySpec::Source(source) => source,
+    };
+    validate_dependency(name, spec).map_err(|error| format!("Invalid dependency: {}", error))?;
+    let project_dir = project_dir(manifest_path);
+
+    if let Some(path) = &source.path {
+        if !project_dir.join(path).is_dir() {
+            return Err(format!("Dependency '{}' path '{}' is not a directory", name, path));
+        }
+        return Ok(FetchOutcome::Fetched(ResolvedDependency {
+            source: format!("path+{}", path),
+            commit: None,
+            dir: path.clone(),
+        }));
+    }
+
+    let Some(url) = &source.git else {
+        let version = source.version.clone().unwrap_or_else(|| "*".to_string());
+        return Ok(FetchOutcome::SkippedRegistry(version));
+    };
+
+    // The lockfile is as untrusted as the manifest: its commit is passed to git
+    let locked_commit = locked.and_then(|entry| entry.commit.clone());
+    if let Some(commit) = &locked_commit {
+        if !is_commit_hash(commit) {
+            return Err(format!(
+                "Dependency '{}': locked commit '{}' in ruff.lock is not a commit hash",
+                name, commit
+            ));
+        }
+    }
+
+    let relative_dir = git_checkout_dir(name);
+    let checkout_dir = project_dir.join(&relative_dir);
+    if checkout_dir.join(".git").is_dir() {
+        run_git(&checkout_dir, &["fetch", "--quiet", "--tags", "origin"])?;
+    } else {
+        if let Some(parent) = checkout_dir.parent() {
+            fs::create_dir_all(parent).map_err(|error| {
+                format!("Failed to create dependency cache '{}': {}", parent.display(), error)
+            })?;
+        }
+        let checkout = checkout_dir.to_string_lossy();
+        run_git(project_dir, &["clone", "--quiet", "--", url.as_str(), checkout.as_ref()])?;
+    }
+
+    let target = match (locked_commit, &source.rev) {
+        (Some(commit), _) => commit,
+        (None, Some(rev)) => resolve_git_rev(&checkout_dir, rev)
+            .ok_or_else(|| format!("Dependency '{}': rev '{}' not found in {}", name, rev, url))?,
+        (None, None) => "origin/HEAD".to_string(),
+    };
+    // The trailing `--` makes git read `target` as a revision, never as a path
+    run_git(&checkout_dir, &["checkout", "--quiet", "--detach", target.as_str(), "--"])
+        .map_err(|error| format!("Dependency '{}': {}", name, error))?;
+    let commit = run_git(&checkout_dir, &["rev-parse", "HEAD"])?;
+
+    Ok(FetchOutcome::Fetched(ResolvedDependency {
+        source: format!("git+{}", url),
+        commit: Some(commit),
+        dir: relative_dir,
+    }))
+}
+
+/// Where `ruff get` clones the git dependency `name`, relative to the manifest.
+fn git_checkout_dir(name: &str) -> String {
+    format!("{}/{}", DEPENDENCY_CACHE_DIR, name)
+}
+
+/// Full or abbreviated hexadecimal commit id.
+fn is_commit_hash(commit: &str) -> bool {
+    (7..=64).contains(&commit.len()) && commit.chars().all(|c| c.is_ascii_hexdigit())
+}
+
+/// Branches only exist as `origin/<rev>` in a clone, so try that before tags and commits.
+fn resolve_git_rev(checkout_dir: &Path, rev: &str) -> Option<String> {
+    [format!("origin/{}^{{commit}}", rev), format!("{}^{{commit}}", rev)].iter().find_map(
+        |candidate| {
+            run_git(checkout_dir, &["rev-parse", "--verify", "--quiet", candidate.as_str()]).ok()
+        },
+    )
+}
+
+fn run_git(dir: &Path, args: &[&str]) -> Result<String, String> {
+    let output = Command::new("git")
+        .current_dir(dir)
+        .args(args)
+        .output()
+        .map_err(|error| format!("Failed to run git: {}", error))?;
+    if !output.status.success() {
+        return Err(format!(
+            "git {} failed: {}",
+            args.first().copied().unwrap_or(""),
+            String::from_utf8_lossy(&output.stderr).trim()
+        ));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+/// Module roots for the dependencies pinned in the lockfile of the project that
+/// contains `entry_dir`, as `(dependency name, source directory)` pairs.
+///
+/// A dependency's `src/` directory is used when it has one. Projects without a
+/// manifest or lockfile have no dependency roots. An entry is only used when its
+/// `dir` is where `ruff get` would have put it for the manifest's dependency: the
+/// dependency cache for git sources, or the declared directory for path sources.
+pub fn locked_dependency_roots(entry_dir: &Path) -> Vec<(String, PathBuf)> {
+    let Some(manifest_path) = find_manifest(entry_dir) else {
+        return Vec::new();
+    };
+    let Ok(manifest) = fs::read_to_string(&manifest_path)
+        .map_err(|error| error.to_string())
+        .and_then(|content| parse_manifest_with_trust(&content, PackageTrust::FirstParty))
+    else {
+        return Vec::new();
+    };
+    let lockfile_path = default_lockfile_path(&manifest_path);
+    let Ok(content) = fs::read_to_string(&lockfile_path) else {
+        return Vec::new();
+    };
+    let Ok(lockfile) = parse_lockfile(&content) else {
+        return Vec::new();
+    };
+
+    let project_dir = project_dir(&manifest_path);
+    lockfile
+        .resolved
+        .iter()
+        .filter(|(name, resolved)| {
+            let expected_dir = match manifest.dependencies.get(name.as_str()) {
+                Some(DependencySpec::Source(DependencySource { path: Some(path), .. })) => {
+                    path.clone()
+                }
+                Some(DependencySpec::Source(DependencySource { git: Some(_), .. })) => {
+                    git_checkout_dir(name)
+                }
+                _ => return false,
+            };
+            resolved.dir == expected_dir
+        })
+        .map(|(name, resolved)| {
+            let checkout_dir = project_dir.join(&resolved.dir);
+            let src_dir = checkout_dir.join("src");
+            (name.clone(), if src_dir.is_dir() { src_dir } else { checkout_dir })
+        })
+        .collect()
+}
+
+/// Nearest `ruff.toml` in `start_dir` or one of its ancestors.
+pub fn find_manifest(start_dir: &Path) -> Option<PathBuf> {
+    let start_dir = fs::canonicalize(start_dir).ok()?;
+    start_dir.ancestors().map(|dir| dir.join("ruff.toml")).find(|path| path.is_file())
 }
 
 pub fn add_dependency(content: &str, name: &str, version: &str) -> Result<String, String> {
     if name.trim().is_empty() {
         return Err("Dependency name must not be empty".to_string());
     }
+    if !is_dependency_name(name) {
+        return Err(format!("Dependency name '{}' must be an identifier", name));
+    }
     if version.trim().is_empty() {
         return Err("Dependency version must not be empty".to_string());
     }
 
     let mut manifest = parse_manifest(content)?;
-    manifest.dependencies.insert(name.to_string(), version.to_string());
+    manifest.dependencies.insert(name.to_string(), DependencySpec::Version(version.to_string()));
 
     toml::to_string_pretty(&manifest)
         .map_err(|error| format!("Failed to serialize ruff.toml: {}", error))
@@ -139,16 +398,54 @@ fn validate_manifest(manifest: &RuffManifest, trust: PackageTrust) -> Result<(),
         }
     }
 
+    for (name, spec) in &manifest.dependencies {
+        validate_dependency(name, spec).map_err(|error| format!("Invalid ruff.toml: {}", error))?;
+    }
+
+    Ok(())
+}
+
+/// Dependency names become module names and `.ruff/deps/<name>` directories.
+fn is_dependency_name(name: &str) -> bool {
+    let mut chars = name.chars();
+    matches!(chars.next(), Some(first) if first.is_ascii_alphabetic() || first == '_')
+        && chars.all(|c| c.is_ascii_alphanumeric() || c == '_')
+}
+
+fn validate_dependency(name: &str, spec: &DependencySpec) -> Result<(), String> {
+    if !is_dependency_name(name) {
+        return Err(format!("dependency name '{}' must be an identifier", name));
+    }
+
+    let DependencySpec::Source(source) = spec else {
+        return Ok(());
+    };
+    if source.git.is_some() && source.path.is_some() {
+        return Err(format!("dependency '{}' must set only one of `git` or `path`", name));
+    }
+    if source.rev.is_some() && source.git.is_none() {
+        return Err(format!("dependency '{}' sets `rev` without `git`", name));
+    }
+    // git would read a leading `-` as an option, e.g. `--upload-pack=<command>`
+    if source.git.as_deref().is_some_and(|url| url.starts_with('-')) {
+        return Err(format!("dependency '{}' has a `git` URL starting with '-'", name));
+    }
+    if source.rev.as_deref().is_some_and(|rev| rev.starts_with('-')) {
+        return Err(format!("dependency '{}' has a `rev` starting with '-'", name));
+    }
     Ok(())
 }
 
 #[cfg(test)]
 mod tests {
     use super::{
-        add_dependency, default_lockfile_path, default_manifest, lockfile_from_manifest,
-        parse_lockfile, parse_manifest, parse_manifest_with_trust, serialize_lockfile,
-        verify_lockfile_matches_manifest, PackageTrust,
+        add_dependency, carry_over_resolved, default_lockfile_path, default_manifest,
+        fetch_dependency, locked_dependency_roots, lockfile_from_manifest, parse_lockfile,
+        parse_manifest, parse_manifest_with_trust, serialize_lockfile,
+        verify_lockfile_matches_manifest, DependencySource, DependencySpec, PackageTrust,
+        ResolvedDependency,
     };
+    use std::fs;
     use std::path::Path;
 
     #[test]
@@ -166,7 +463,10 @@ mod tests {
             add_dependency(&content, "http", "1.2.3").expect("dependency update should succeed");
 
         let manifest = parse_manifest(&updated).expect("updated manifest should parse");
-        assert_eq!(manifest.dependencies.get("http").map(|value| value.as_str()), Some("1.2.3"));
+        assert_eq!(
+            manifest.dependencies.get("http"),
+            Some(&DependencySpec::Version("1.2.3".to_string()))
+        );
     }
 
     #[test]
@@ -191,7 +491,7 @@ mod tests {
             .expect("dependency add should succeed");
         let manifest = parse_manifest(&manifest_content).expect("manifest should parse");
         let mut lockfile = lockfile_from_manifest(&manifest);
-        lockfile.dependencies.insert("http".to_string(), "9.9.9".to_string());
+        lockfile.dependencies.insert("http".to_string(), DependencySpec::Version("9.9.9".into()));
 
         let error = verify_lockfile_matches_manifest(&manifest, &lockfile)
             .expect_err("lockfile mismatch should fail");
@@ -226,4 +526,166 @@ version = "0.1.0"
             .expect("first-party manifests can use reserved package names");
         assert_eq!(parsed.package.name, "ruff-kennel");
     }
+
+    const SOURCE_MANIFEST: &str = r#"
+[package]
+name = "demo"
+version = "0.1.0"
+
+[dependencies]
+http = "1.2.3"
+strings = { git = "https://example.com/strings.git", rev = "v1" }
+local = { path = "../local" }
+"#;
+
+    #[test]
+    fn parse_manifest_accepts_git_and_path_dependency_tables() {
+        let manifest = parse_manifest(SOURCE_MANIFEST).expect("manifest should parse");
+        assert_eq!(
+            manifest.dependencies.get("http"),
+            Some(&DependencySpec::Version("1.2.3".to_string()))
+        );
+        let rendered: Vec<String> =
+            manifest.dependencies.values().map(|spec| spec.to_string()).collect();
+        assert_eq!(
+            rendered,
+            vec!["1.2.3", "path+../local", "git+https://example.com/strings.git#v1"]
+        );
+    }
+
+    #[test]
+    fn parse_manifest_rejects_dependency_with_git_and_path() {
+        let content = r#"
+[package]
+name = "demo"
+version = "0.1.0"
+
+[dependencies]
+strings = { git = "https://example.com/strings.git", path = "../strings" }
+"#;
+        let error = parse_manifest(content).expect_err("conflicting sources should be rejected");
+        assert!(error.contains("only one of `git` or `path`"), "unexpected error: {}", error);
+    }
+
+    fn manifest_with_dependency(entry: &str) -> String {
+        format!("[package]\nname = \"demo\"\nversion = \"0.1.0\"\n\n[dependencies]\n{}\n", entry)
+    }
+
+    #[test]
+    fn parse_manifest_rejects_dependency_names_that_are_not_identifiers() {
+        for entry in [
+            r#""../escape" = { git = "https://example.com/escape.git" }"#,
+            r#""a/b" = "1.0.0""#,
+            r#""1st" = "1.0.0""#,
+        ] {
+            let error = parse_manifest(&manifest_with_dependency(entry))
+                .expect_err("non-identifier dependency names should be rejected");
+            assert!(error.contains("must be an identifier"), "unexpected error: {}", error);
+        }
+        assert!(add_dependency(&default_manifest("demo"), "../escape", "1.0.0").is_err());
+    }
+
+    #[test]
+    fn parse_manifest_rejects_git_arguments_that_look_like_options() {
+        let error = parse_manifest(&manifest_with_dependency(
+            r#"evil = { git = "--upload-pack=touch /tmp/pwned" }"#,
+        ))
+        .expect_err("option-like git URLs should be rejected");
+        assert!(error.contains("URL starting with '-'"), "unexpected error: {}", error);
+
+        let error = parse_manifest(&manifest_with_dependency(
+            r#"evil = { git = "https://example.com/evil.git", rev = "--output=/tmp/pwned" }"#,
+        ))
+        .expect_err("option-like revs should be rejected");
+        assert!(error.contains("`rev` starting with '-'"), "unexpected error: {}", error);
+    }
+
+    #[test]
+    fn fetch_dependency_rejects_unsafe_names_urls_and_locked_commits_before_running_git() {
+        let manifest_path = Path::new("/nonexistent-ruff-project/ruff.toml");
+        let git = |url: &str| {
+            DependencySpec::Source(DependencySource {
+                git: Some(url.to_string()),
+                ..Default::default()
+            })
+        };
+
+        let error = fetch_dependency(manifest_path, "../escape", &git("https://a.test/x"), None)
+            .expect_err("path-like names should be rejected");
+        assert!(error.contains("must be an identifier"), "unexpected error: {}", error);
+
+        let error = fetch_dependency(manifest_path, "evil", &git("--upload-pack=id"), None)
+            .expect_err("option-like URLs should be rejected");
+        assert!(error.contains("starting with '-'"), "unexpected error: {}", error);
+
+        let locked = ResolvedDependency {
+            source: "git+https://a.test/x".to_string(),
+            commit: Some("--orphan=pwned".to_string()),
+            dir: ".ruff/deps/strings".to_string(),
+        };
+        let error =
+            fetch_dependency(manifest_path, "strings", &git("https://a.test/x"), Some(&locked))
+                .expect_err("non-hex locked commits should be rejected");
+        assert!(error.contains("is not a commit hash"), "unexpected error: {}", error);
+    }
+
+    #[test]
+    fn locked_dependency_roots_ignore_dirs_outside_the_dependency_cache() {
+        let project =
+            std::env::temp_dir().join(format!("ruff_locked_roots_{}", std::process::id()));
+        let _ = fs::remove_dir_all(&project);
+        fs::create_dir_all(project.join(".ruff/deps/strings")).expect("create dependency cache");
+        let manifest_content = manifest_with_dependency(
+            "strings = { git = \"https://a.test/strings.git\" }\n\
+             other = { git = \"https://a.test/other.git\" }",
+        );
+        fs::write(project.join("ruff.toml"), &manifest_content).expect("write manifest");
+
+        let manifest = parse_manifest(&manifest_content).expect("manifest should parse");
+        let mut lockfile = lockfile_from_manifest(&manifest);
+        for (name, dir) in [("strings", ".ruff/deps/strings"), ("other", "../../outside")] {
+            lockfile.resolved.insert(
+                name.to_string(),
+                ResolvedDependency {
+                    source: format!("git+https://a.test/{}.git", name),
+                    commit: Some("abc1234".to_string()),
+                    dir: dir.to_string(),
+                },
+            );
+        }
+        let lockfile_content = serialize_lockfile(&lockfile).expect("lockfile should serialize");
+        fs::write(project.join("ruff.lock"), lockfile_content).expect("write lockfile");
+
+        let roots = locked_dependency_roots(&project);
+        let names: Vec<&str> = roots.iter().map(|(name, _)| name.as_str()).collect();
+        assert_eq!(names, vec!["strings"]);
+        let _ = fs::remove_dir_all(&project);
+    }
+
+    #[test]
+    fn carry_over_resolved_keeps_pins_only_for_unchanged_dependencies() {
+        let manifest = parse_manifest(SOURCE_MANIFEST).expect("manifest should parse");
+        let mut previous = lockfile_from_manifest(&manifest);
+        for name in ["strings", "local"] {
+            previous.resolved.insert(
+                name.to_string(),
+                ResolvedDependency {
+                    source: format!("{}-source", name),
+                    commit: Some("abc1234".to_string()),
+                    dir: format!(".ruff/deps/{}", name),
+                },
+            );
+        }
+
+        let serialized = serialize_lockfile(&previous).expect("lockfile should serialize");
+        assert_eq!(parse_lockfile(&serialized).expect("lockfile should parse"), previous);
+
+        let mut changed = manifest.clone();
+        changed.dependencies.insert("local".to_string(), DependencySpec::Version("2".to_string()));
+        let mut regenerated = lockfile_from_manifest(&changed);
+        carry_over_resolved(&mut regenerated, &previous);
+
+        assert!(regenerated.resolved.contains_key("strings"));
+        assert!(!regenerated.resolved.contains_key("local"));
+    }
 }
@@ -14,7 +14,7 @@
 // The parser uses a single-token lookahead and advances through the token stream
 // as it builds the AST.
 
-use crate::ast::{Expr, Stmt};
+use crate::ast::{Expr, InterfaceMethod, MatchCase, MatchLiteral, MatchPattern, Stmt};
 use crate::errors::{
     Diagnostic, DiagnosticSeverity, DiagnosticSubsystem, SourceLocation, SourceSpan,
     DIAGNOSTIC_CODE_PARSER,
@@ -47,15 +47,56 @@ impl ParseDiagnostic {
             location.file = Some(file_name.to_string());
         }
 
+        let highlight_width = if self.span.end.line == self.span.start.line {
+            self.span.end.column.saturating_sub(self.span.start.column)
+        } else {
+            1
+        };
+
         Diagnostic::new(
             DIAGNOSTIC_CODE_PARSER,
             DiagnosticSeverity::Error,
             DiagnosticSubsystem::Parser,
             self.message.clone(),
         )
-        .with_help("Fix the parse error and rerun Ruff.")
+        .with_help(parse_error_hint(&self.message))
         .with_location(location.file, location.line, location.column)
+        .with_highlight_width(highlight_width)
+    }
+}
+
+/// Suggest a fix for common parse failures, falling back to a generic hint.
+fn parse_error_hint(message: &str) -> String {
+    let expected = message
+        .strip_prefix("Expected '")
+        .and_then(|rest| rest.split_once('\''))
+        .map(|(token, _)| token);
+
+    if let Some(token) = expected {
+        if message.ends_with("but found Eof") {
+            return format!("The file ended before this was closed; add the missing '{}'.", token);
+        }
+        if message.contains(" but found ") {
+            return format!("Insert '{}' before the highlighted token.", token);
+        }
+    }
+
+    if message.starts_with("Invalid assignment target") {
+        return "Only variables, fields, and index expressions can be assigned to.".to_string();
+    }
+    if message.starts_with("Expected") && message.contains(" name after '") {
+        return "Add an identifier after the keyword.".to_string();
     }
+    if message == "Expected expression" {
+        return "The highlighted token cannot start an expression; add a value before it."
+            .to_string();
+    }
+    if message == "Invalid statement" {
+        return "Start the statement with a keyword such as 'let' or 'func', or an expression."
+            .to_string();
+    }
+
+    "Fix the parse error and rerun Ruff.".to_string()
 }
 
 pub fn source_size_limit_diagnostic(
@@ -126,6 +167,14 @@ impl Default for ParserLimits {
     }
 }
 
+/// Parameters of a `func` definition or expression, as stored on the AST.
+struct ParamList {
+    params: Vec<String>,
+    param_types: Vec<Option<crate::ast::TypeAnnotation>>,
+    param_defaults: Vec<Option<Expr>>,
+    is_variadic: bool,
+}
+
 /// Parser maintains position in token stream and provides methods to parse statements and expressions
 pub struct Parser {
     tokens: Vec<Token>,
@@ -137,6 +186,10 @@ pub struct Parser {
     max_block_depth: usize,
     max_collection_literal_items: usize,
     ast_spans: Vec<AstNodeSpan>,
+    source_positions: bool,
+    /// Whether a `yield` was parsed in the function body being parsed; a plain `func`
+    /// whose body yields is a generator, like `func*`.
+    saw_yield: bool,
 }
 
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
@@ -173,7 +226,80 @@ impl Parser {
             max_block_depth: limits.max_block_depth,
             max_collection_literal_items: limits.max_collection_literal_items,
             ast_spans: Vec::new(),
+            source_positions: false,
+            saw_yield: false,
+        }
+    }
+
+    /// Emit a `Stmt::SourcePos` before every statement in a block so runtime errors
+    /// can be reported at the statement that raised them.
+    pub fn with_source_positions(mut self) -> Self {
+        self.source_positions = true;
+        self
+    }
+
+    /// Parse one statement into `body`, preceded by its `Stmt::SourcePos` when enabled.
+    fn parse_stmt_into(&mut self, body: &mut Vec<Stmt>) -> bool {
+        if matches!(self.peek(), TokenKind::Punctuation('@')) {
+            return self.parse_decorated_func_into(body);
+        }
+
+        let start = self.current_span().start;
+        let Some(stmt) = self.parse_stmt() else {
+            return false;
+        };
+
+        if self.source_positions {
+            body.push(Stmt::SourcePos { line: start.line, column: start.column });
+        }
+        body.push(stmt);
+        true
+    }
+
+    /// `@dec func name(...) {...}`: the decorators are kept on the `FuncDef`, and the
+    /// runtimes apply them through `Stmt::decorator_rebinding`.
+    fn parse_decorated_func_into(&mut self, body: &mut Vec<Stmt>) -> bool {
+        let start_pos = self.pos;
+        let start = self.current_span().start;
+        let mut decorators = Vec::new();
+        while matches!(self.peek(), TokenKind::Punctuation('@')) {
+            self.advance(); // @
+            let Some(decorator) = self.parse_call() else {
+                return false;
+            };
+            decorators.push(decorator);
         }
+
+        let is_func = match self.peek() {
+            TokenKind::Keyword(k) if k == "func" => true,
+            TokenKind::Keyword(k) if k == "async" => matches!(
+                self.tokens.get(self.pos + 1).map(|t| &t.kind),
+                Some(TokenKind::Keyword(k)) if k == "func"
+            ),
+            _ => false,
+        };
+        if !is_func {
+            self.push_diagnostic("Expected 'func' after decorator");
+            return false;
+        }
+
+        let Some(mut func) = self.parse_stmt_inner() else {
+            return false;
+        };
+        let Stmt::FuncDef { decorators: func_decorators, .. } = &mut func else {
+            self.push_diagnostic("Expected 'func' after decorator");
+            return false;
+        };
+        *func_decorators = decorators;
+        // The definition's span starts at its first `@`, so the formatter keeps the
+        // decorator lines with the function.
+        self.record_ast_span(AstNodeSpanKind::Statement, start_pos, self.pos);
+
+        if self.source_positions {
+            body.push(Stmt::SourcePos { line: start.line, column: start.column });
+        }
+        body.push(func);
+        true
     }
 
     /// Peek at the current token without consuming it
@@ -181,6 +307,15 @@ impl Parser {
         self.tokens.get(self.pos).map(|t| &t.kind).unwrap_or(&TokenKind::Eof)
     }
 
+    /// The member name after `.` or `?.`. Keywords are accepted here, so namespaces can
+    /// export names like `regex.match`.
+    fn peek_member_name(&self) -> Option<String> {
+        match self.peek() {
+            TokenKind::Identifier(name) | TokenKind::Keyword(name) => Some(name.clone()),
+            _ => None,
+        }
+    }
+
     /// Consume and return the current token, then advance to the next
     fn advance(&mut self) -> &TokenKind {
         let tok = self.tokens.get(self.pos).map(|t| &t.kind).unwrap_or(&TokenKind::Eof);
@@ -218,7 +353,7 @@ impl Parser {
             };
             let start_column = raw_start_column.max(1);
             let start = SourceLocation::new(token.line, start_column);
-            let end = SourceLocation::new(token.line, start_column.saturating_add(width_chars));
+            let end = SourceLocation::new(token.end_line, start_column.saturating_add(width_chars));
             let start_byte = token.byte_offset;
             let end_byte = start_byte.saturating_add(width_chars);
             SourceSpan::new(start, end, start_byte, end_byte)
@@ -321,9 +456,7 @@ impl Parser {
                     continue;
                 }
 
-                if let Some(stmt) = parser.parse_stmt() {
-                    body.push(stmt);
-                } else {
+                if !parser.parse_stmt_into(&mut body) {
                     break;
                 }
             }
@@ -468,6 +601,29 @@ impl Parser {
         }
     }
 
+    /// Whether the `throw` at the current token starts the statement form `throw value`:
+    /// the next token is on the same line and can start an expression other than a
+    /// parenthesized one, which stays the `throw(value)` call form.
+    fn throw_operand_follows(&self) -> bool {
+        let (Some(throw_token), Some(next)) =
+            (self.tokens.get(self.pos), self.tokens.get(self.pos + 1))
+        else {
+            return false;
+        };
+        next.line == throw_token.line
+            && matches!(
+                &next.kind,
+                TokenKind::Identifier(_)
+                    | TokenKind::String(_)
+                    | TokenKind::InterpolatedString(_)
+                    | TokenKind::Int(_)
+                    | TokenKind::Float(_)
+                    | TokenKind::Bool(_)
+                    | TokenKind::Punctuation('[' | '{')
+            )
+            || matches!(&next.kind, TokenKind::Keyword(keyword) if keyword == "self" || keyword == "null")
+    }
+
     fn is_valid_assignment_target(expr: &Expr) -> bool {
         matches!(expr, Expr::Identifier(_) | Expr::FieldAccess { .. } | Expr::IndexAccess { .. })
     }
@@ -508,9 +664,7 @@ impl Parser {
 
             let diagnostics_before = self.diagnostics.len();
             let pos_before = self.pos;
-            if let Some(stmt) = self.parse_stmt() {
-                stmts.push(stmt);
-            } else {
+            if !self.parse_stmt_into(&mut stmts) {
                 if self.diagnostics.len() == diagnostics_before {
                     self.push_diagnostic("Invalid statement");
                 }
@@ -563,6 +717,7 @@ impl Parser {
             TokenKind::Keyword(k) if k == "func" => self.parse_func_with_async(false),
             TokenKind::Keyword(k) if k == "enum" => self.parse_enum(),
             TokenKind::Keyword(k) if k == "struct" => self.parse_struct(),
+            TokenKind::Keyword(k) if k == "interface" => self.parse_interface(),
             TokenKind::Keyword(k) if k == "import" || k == "from" => self.parse_import(),
             TokenKind::Keyword(k) if k == "export" => self.parse_export(),
             TokenKind::Keyword(k) if k == "return" => {
@@ -571,7 +726,18 @@ impl Parser {
                     self.peek(),
                     TokenKind::Punctuation(';') | TokenKind::Punctuation('}') | TokenKind::Eof
                 ) {
-                    Some(self.parse_expr()?)
+                    let first = self.parse_expr()?;
+                    if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                        // `return a, b` returns the array `[a, b]` for `x, y := f()` to unpack
+                        let mut values = vec![crate::ast::ArrayElement::Single(first)];
+                        while matches!(self.peek(), TokenKind::Punctuation(',')) {
+                            self.advance(); // ,
+                            values.push(crate::ast::ArrayElement::Single(self.parse_expr()?));
+                        }
+                        Some(Expr::ArrayLiteral(values))
+                    } else {
+                        Some(first)
+                    }
                 } else {
                     None
                 };
@@ -589,13 +755,12 @@ impl Parser {
             TokenKind::Keyword(k) if k == "test_teardown" => self.parse_test_teardown(),
             TokenKind::Keyword(k) if k == "test_group" => self.parse_test_group(),
             TokenKind::Keyword(k) if k == "break" => {
-                self.advance();
-                Some(Stmt::Break)
+                Some(Stmt::Break(self.parse_loop_jump_label()))
             }
             TokenKind::Keyword(k) if k == "continue" => {
-                self.advance();
-                Some(Stmt::Continue)
+                Some(Stmt::Continue(self.parse_loop_jump_label()))
             }
+            TokenKind::Identifier(_) if self.starts_labeled_loop() => self.parse_labeled_loop(),
             // Handle destructuring patterns: [a, b] := expr or {x, y} := expr
             TokenKind::Punctuation('[') | TokenKind::Punctuation('{') => {
                 let saved_pos = self.pos;
@@ -622,6 +787,15 @@ impl Parser {
                 // We need to look ahead and parse an expression to see if it's followed by :=
                 let saved_pos = self.pos;
                 if let Some(expr) = self.parse_expr() {
+                    // Multiple assignment: a, b = b, a + b
+                    if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                        if let Some(targets) = self.parse_multi_assignment_targets(expr) {
+                            return self.parse_multi_assignment(targets);
+                        }
+                        self.pos = saved_pos;
+                        return self.parse_expr().map(Stmt::ExprStmt);
+                    }
+
                     // Check if next token is an assignment operator.
                     if let Some(operator) = self.consume_statement_assignment_operator() {
                         let target = expr;
@@ -666,6 +840,69 @@ impl Parser {
         }
     }
 
+    /// Collect the comma-separated targets of a multiple assignment.
+    /// Returns None (without committing) when the list is not followed by `:=` or `=`.
+    fn parse_multi_assignment_targets(&mut self, first: Expr) -> Option<Vec<Expr>> {
+        let mut targets = vec![first];
+        while matches!(self.peek(), TokenKind::Punctuation(',')) {
+            self.advance(); // ,
+            targets.push(self.parse_expr()?);
+        }
+
+        if self.is_simple_assignment_operator() {
+            Some(targets)
+        } else {
+            None
+        }
+    }
+
+    fn parse_multi_assignment(&mut self, targets: Vec<Expr>) -> Option<Stmt> {
+        self.advance(); // := or =
+
+        let mut values = vec![self.parse_expr()?];
+        while matches!(self.peek(), TokenKind::Punctuation(',')) {
+            self.advance(); // ,
+            values.push(self.parse_expr()?);
+        }
+
+        if self.is_assignment_operator() {
+            self.push_diagnostic(
+                "Chained assignment is not supported; split the assignment into separate statements",
+            );
+            return None;
+        }
+
+        let mut assigned_names: Vec<&str> = Vec::new();
+        for target in &targets {
+            if !Self::is_valid_assignment_target(target) {
+                self.push_diagnostic("Invalid assignment target");
+                return None;
+            }
+            if let Expr::Identifier(name) = target {
+                if assigned_names.contains(&name.as_str()) {
+                    self.push_diagnostic(format!(
+                        "Variable '{}' is assigned more than once in a multiple assignment",
+                        name
+                    ));
+                    return None;
+                }
+                assigned_names.push(name.as_str());
+            }
+        }
+
+        // A single value with several targets unpacks an array: `q, r := divmod(a, b)`
+        if values.len() != 1 && values.len() != targets.len() {
+            self.push_diagnostic(format!(
+                "Multiple assignment has {} targets but {} values",
+                targets.len(),
+                values.len()
+            ));
+            return None;
+        }
+
+        Some(Stmt::MultiAssign { targets, values })
+    }
+
     fn parse_enum(&mut self) -> Option<Stmt> {
         self.advance(); // enum
         let name = match self.advance() {
@@ -704,6 +941,26 @@ impl Parser {
             }
         };
 
+        // Optional `implements Iface1, Iface2` clause
+        let mut implements = Vec::new();
+        if matches!(self.peek(), TokenKind::Identifier(word) if word == "implements") {
+            self.advance();
+            loop {
+                match self.advance() {
+                    TokenKind::Identifier(interface) => implements.push(interface.clone()),
+                    _ => {
+                        self.push_diagnostic("Expected interface name after 'implements'");
+                        return None;
+                    }
+                }
+                if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                    self.advance();
+                } else {
+                    break;
+                }
+            }
+        }
+
         if !self.expect_punctuation('{', "to start struct body") {
             return None;
         }
@@ -714,6 +971,7 @@ impl Parser {
             && !matches!(self.peek(), TokenKind::Eof)
         {
             // Check if this is a method definition (async func or func)
+            let start_pos = self.pos;
             let is_async = if matches!(self.peek(), TokenKind::Keyword(k) if k == "async") {
                 self.advance(); // consume 'async'
                 true
@@ -723,6 +981,7 @@ impl Parser {
 
             if matches!(self.peek(), TokenKind::Keyword(k) if k == "func") {
                 if let Some(method) = self.parse_func_with_async(is_async) {
+                    self.record_ast_span(AstNodeSpanKind::Statement, start_pos, self.pos);
                     methods.push(method);
                 }
             } else if let TokenKind::Identifier(field_name) = self.peek() {
@@ -747,7 +1006,79 @@ impl Parser {
         if !self.expect_punctuation('}', "to close struct body") {
             return None;
         }
-        Some(Stmt::StructDef { name, fields, methods })
+        Some(Stmt::StructDef { name, fields, methods, implements })
+    }
+
+    /// Parses `interface Name { func method(self, arg) ... }`; methods have no body.
+    fn parse_interface(&mut self) -> Option<Stmt> {
+        self.advance(); // interface
+        let name = match self.advance() {
+            TokenKind::Identifier(n) => n.clone(),
+            _ => {
+                self.push_diagnostic("Expected interface name after 'interface'");
+                return None;
+            }
+        };
+
+        if !self.expect_punctuation('{', "to start interface body") {
+            return None;
+        }
+        let mut methods = Vec::new();
+
+        while !matches!(self.peek(), TokenKind::Punctuation('}') | TokenKind::Eof) {
+            if !matches!(self.peek(), TokenKind::Keyword(k) if k == "func") {
+                self.push_diagnostic("Expected 'func' method signature in interface body");
+                return None;
+            }
+            self.advance(); // func
+
+            let method_name = match self.advance() {
+                TokenKind::Identifier(n) => n.clone(),
+                _ => {
+                    self.push_diagnostic("Expected method name after 'func'");
+                    return None;
+                }
+            };
+            if !self.expect_punctuation('(', "after interface method name") {
+                return None;
+            }
+            let mut params = Vec::new();
+            let mut param_types = Vec::new();
+            loop {
+                match self.peek() {
+                    TokenKind::Identifier(p) => params.push(p.clone()),
+                    TokenKind::Keyword(k) if k == "self" => params.push("self".to_string()),
+                    _ => break,
+                }
+                self.advance();
+                param_types.push(self.parse_type_annotation());
+                if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                    self.advance();
+                } else {
+                    break;
+                }
+            }
+            if !self.expect_punctuation(')', "to close interface method parameter list") {
+                return None;
+            }
+
+            let return_type = if matches!(self.peek(), TokenKind::Operator(op) if op == "->") {
+                self.advance();
+                self.parse_type_annotation_inner()
+            } else {
+                None
+            };
+            if matches!(self.peek(), TokenKind::Punctuation(',') | TokenKind::Punctuation(';')) {
+                self.advance();
+            }
+
+            methods.push(InterfaceMethod { name: method_name, params, param_types, return_type });
+        }
+
+        if !self.expect_punctuation('}', "to close interface body") {
+            return None;
+        }
+        Some(Stmt::InterfaceDef { name, methods })
     }
 
     fn parse_let(&mut self) -> Option<Stmt> {
@@ -935,33 +1266,9 @@ impl Parser {
         if !self.expect_punctuation('(', "after function name") {
             return None;
         }
-        let mut params = Vec::new();
-        let mut param_types = Vec::new();
-
         // Parse parameters - handle both identifiers and 'self' keyword
-        loop {
-            match self.peek() {
-                TokenKind::Identifier(p) => {
-                    params.push(p.clone());
-                    self.advance();
-                }
-                TokenKind::Keyword(k) if k == "self" => {
-                    params.push("self".to_string());
-                    self.advance();
-                }
-                _ => break, // No more parameters
-            }
-
-            // Parse optional type annotation for parameter
-            let param_type = self.parse_type_annotation();
-            param_types.push(param_type);
-
-            if matches!(self.peek(), TokenKind::Punctuation(',')) {
-                self.advance();
-            } else {
-                break;
-            }
-        }
+        let ParamList { params, param_types, param_defaults, is_variadic } =
+            self.parse_param_list(true);
         if !self.expect_punctuation(')', "to close function parameter list") {
             return None;
         }
@@ -992,12 +1299,113 @@ impl Parser {
             None
         };
 
+        let enclosing_saw_yield = std::mem::replace(&mut self.saw_yield, false);
         let body = self.parse_statement_block(
             "to start function body",
             "to close function body",
             "function body",
-        )?;
-        Some(Stmt::FuncDef { name, param_types, return_type, params, body, is_generator, is_async })
+        );
+        let is_generator = is_generator || self.saw_yield;
+        self.saw_yield = enclosing_saw_yield;
+        let body = body?;
+        Some(Stmt::FuncDef {
+            name,
+            param_types,
+            param_defaults,
+            is_variadic,
+            return_type,
+            params,
+            body,
+            is_generator,
+            is_async,
+            decorators: Vec::new(),
+        })
+    }
+
+    /// Parse parameters up to the closing `)`: `name`, `name: type`, `name = literal`,
+    /// and a final `...rest` that collects extra positional arguments. `self` is only
+    /// accepted in `func` definitions, where it names a method's receiver.
+    fn parse_param_list(&mut self, allow_self: bool) -> ParamList {
+        let mut list = ParamList {
+            params: Vec::new(),
+            param_types: Vec::new(),
+            param_defaults: Vec::new(),
+            is_variadic: false,
+        };
+
+        loop {
+            let follows_rest = list.is_variadic;
+            let is_rest = matches!(self.peek(), TokenKind::Operator(op) if op == "...");
+            if is_rest {
+                self.advance(); // ...
+            }
+            match self.peek() {
+                TokenKind::Identifier(p) => {
+                    list.params.push(p.clone());
+                    self.advance();
+                }
+                TokenKind::Keyword(k) if k == "self" && allow_self && !is_rest => {
+                    list.params.push("self".to_string());
+                    self.advance();
+                }
+                _ => {
+                    if is_rest {
+                        self.push_diagnostic("Expected a parameter name after '...'");
+                    }
+                    break; // No more parameters
+                }
+            }
+            if follows_rest {
+                self.push_diagnostic(format!(
+                    "Rest parameter '...{}' must be the last parameter",
+                    list.params[list.params.len() - 2]
+                ));
+            }
+            list.is_variadic = is_rest;
+
+            // Parse optional type annotation for parameter
+            let param_type = self.parse_type_annotation();
+            list.param_types.push(param_type);
+
+            let default = if matches!(self.peek(), TokenKind::Operator(op) if op == "=") {
+                self.advance(); // =
+                let default = self.parse_expr();
+                let name = list.params.last().cloned().unwrap_or_default();
+                if is_rest {
+                    self.push_diagnostic(format!(
+                        "Rest parameter '...{}' cannot have a default value",
+                        name
+                    ));
+                } else if default.as_ref().is_some_and(|expr| !expr.is_literal_default()) {
+                    self.push_diagnostic(format!(
+                        "Default value of parameter '{}' must be a literal",
+                        name
+                    ));
+                }
+                default
+            } else {
+                if !is_rest && list.param_defaults.iter().any(Option::is_some) {
+                    self.push_diagnostic(format!(
+                        "Parameter '{}' without a default cannot follow one with a default",
+                        list.params.last().map(String::as_str).unwrap_or_default()
+                    ));
+                }
+                None
+            };
+            list.param_defaults.push(default);
+
+            if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                self.advance();
+            } else {
+                break;
+            }
+        }
+
+        // Functions without defaults keep an empty list, like before defaults existed
+        if list.param_defaults.iter().all(Option::is_none) {
+            list.param_defaults.clear();
+        }
+        list
     }
 
     /// Parse a function expression (anonymous function)
@@ -1015,23 +1423,8 @@ impl Parser {
         if !self.expect_punctuation('(', "after 'func' in function expression") {
             return None;
         }
-        let mut params = Vec::new();
-        let mut param_types = Vec::new();
-
-        while let TokenKind::Identifier(p) = self.peek() {
-            params.push(p.clone());
-            self.advance();
-
-            // Parse optional type annotation for parameter
-            let param_type = self.parse_type_annotation();
-            param_types.push(param_type);
-
-            if matches!(self.peek(), TokenKind::Punctuation(',')) {
-                self.advance();
-            } else {
-                break;
-            }
-        }
+        let ParamList { params, param_types, param_defaults, is_variadic } =
+            self.parse_param_list(false);
         if !self.expect_punctuation(')', "to close function expression parameter list") {
             return None;
         }
@@ -1062,18 +1455,40 @@ impl Parser {
             None
         };
 
+        let enclosing_saw_yield = std::mem::replace(&mut self.saw_yield, false);
         let body = self.parse_statement_block(
             "to start function expression body",
             "to close function expression body",
             "function expression body",
-        )?;
-        Some(Expr::Function { params, param_types, return_type, body, is_generator, is_async })
+        );
+        let is_generator = is_generator || self.saw_yield;
+        self.saw_yield = enclosing_saw_yield;
+        let body = body?;
+        Some(Expr::Function {
+            params,
+            param_types,
+            param_defaults,
+            is_variadic,
+            return_type,
+            body,
+            is_generator,
+            is_async,
+        })
     }
 
     fn parse_match(&mut self) -> Option<Stmt> {
+        let (value, cases, default) = self.parse_match_arms()?;
+        Some(Stmt::Match { value, cases, default })
+    }
+
+    /// Parses `match value { case pattern [if guard]: body ... default: body }`, shared by
+    /// the statement and expression forms. A body is a `{ ... }` block or a single statement.
+    fn parse_match_arms(&mut self) -> Option<(Expr, Vec<MatchCase>, Option<Vec<Stmt>>)> {
         self.advance(); // match
         let value = self.parse_expr()?;
-        self.advance(); // {
+        if !self.expect_punctuation('{', "to start match cases") {
+            return None;
+        }
         let mut cases = Vec::new();
         let mut default = None;
 
@@ -1081,74 +1496,253 @@ impl Parser {
             match self.peek() {
                 TokenKind::Keyword(k) if k == "case" => {
                     self.advance(); // case
+                    let pattern = self.parse_match_pattern()?;
+                    let guard = if matches!(self.peek(), TokenKind::Keyword(k) if k == "if") {
+                        self.advance(); // if
+                        Some(self.parse_expr()?)
+                    } else {
+                        None
+                    };
+                    if !self.expect_punctuation(':', "after match case pattern") {
+                        return None;
+                    }
+                    let body = self.parse_match_case_body("match case block")?;
+                    cases.push(MatchCase { pattern, guard, body });
+                }
+                TokenKind::Keyword(k) if k == "default" => {
+                    self.advance(); // default
+                    if !self.expect_punctuation(':', "after match default") {
+                        return None;
+                    }
+                    default = Some(self.parse_match_case_body("match default block")?);
+                }
+                _ => break,
+            }
+        }
+
+        if !self.expect_punctuation('}', "to close match cases") {
+            return None;
+        }
+        Some((value, cases, default))
+    }
+
+    fn parse_match_case_body(&mut self, context: &str) -> Option<Vec<Stmt>> {
+        if matches!(self.peek(), TokenKind::Punctuation('{')) {
+            return self.parse_statement_block(
+                &format!("to start {}", context),
+                &format!("to close {}", context),
+                context,
+            );
+        }
 
-                    // Parse pattern which might be Base::Variant or Base::Variant(var)
-                    let pat = match self.advance() {
-                        TokenKind::Identifier(s) => s.clone(),
-                        _tok => {
+        let mut body = Vec::new();
+        if !self.parse_stmt_into(&mut body) {
+            return None;
+        }
+        if matches!(self.peek(), TokenKind::Punctuation(';')) {
+            self.advance();
+        }
+        Some(body)
+    }
+
+    /// Parses one `match` case pattern. Lowercase identifiers bind; capitalized ones
+    /// (and `Ok`/`Err`/`Some`/`None`) name a variant tag, optionally with a payload.
+    fn parse_match_pattern(&mut self) -> Option<MatchPattern> {
+        match self.peek().clone() {
+            TokenKind::Int(n) => {
+                self.advance();
+                Some(MatchPattern::Literal(MatchLiteral::Int(n)))
+            }
+            TokenKind::Float(n) => {
+                self.advance();
+                Some(MatchPattern::Literal(MatchLiteral::Float(n)))
+            }
+            TokenKind::Operator(op) if op == "-" => {
+                self.advance(); // -
+                match self.advance().clone() {
+                    TokenKind::Int(n) => Some(MatchPattern::Literal(MatchLiteral::Int(-n))),
+                    TokenKind::Float(n) => Some(MatchPattern::Literal(MatchLiteral::Float(-n))),
+                    _ => {
+                        self.push_diagnostic("Expected a number after '-' in match pattern");
+                        None
+                    }
+                }
+            }
+            TokenKind::String(text) => {
+                self.advance();
+                Some(MatchPattern::Literal(MatchLiteral::Str(text)))
+            }
+            TokenKind::Bool(flag) => {
+                self.advance();
+                Some(MatchPattern::Literal(MatchLiteral::Bool(flag)))
+            }
+            TokenKind::Keyword(k) if k == "null" => {
+                self.advance();
+                Some(MatchPattern::Literal(MatchLiteral::Null))
+            }
+            TokenKind::Identifier(name) if name == "_" => {
+                self.advance();
+                Some(MatchPattern::Wildcard)
+            }
+            TokenKind::Identifier(name) => {
+                self.advance();
+                let mut tag = name;
+                if matches!(self.peek(), TokenKind::Operator(op) if op == "::") {
+                    self.advance(); // ::
+                    match self.advance().clone() {
+                        TokenKind::Identifier(variant) => tag = format!("{}::{}", tag, variant),
+                        _ => {
+                            self.push_diagnostic(
+                                "Expected variant name after '::' in match pattern",
+                            );
                             return None;
                         }
-                    };
+                    }
+                }
 
-                    // Check for :: operator (enum variant)
-                    let pat_str = if matches!(self.peek(), TokenKind::Operator(op) if op == "::") {
-                        self.advance(); // ::
-                        let variant = match self.advance() {
-                            TokenKind::Identifier(v) => v.clone(),
-                            _ => return None,
-                        };
-                        let full_tag = format!("{}::{}", pat, variant);
+                let payload = if matches!(self.peek(), TokenKind::Punctuation('(')) {
+                    self.advance(); // (
+                    let payload = self.parse_match_pattern()?;
+                    if !self.expect_punctuation(')', "to close match pattern payload") {
+                        return None;
+                    }
+                    Some(Box::new(payload))
+                } else {
+                    None
+                };
 
-                        // Check for parameter binding like Result::Ok(msg)
-                        if matches!(self.peek(), TokenKind::Punctuation('(')) {
-                            self.advance(); // (
-                            let var = match self.advance() {
-                                TokenKind::Identifier(v) => v.clone(),
-                                _ => return None,
-                            };
-                            self.advance(); // )
-                            format!("{}({})", full_tag, var)
-                        } else {
-                            full_tag
-                        }
+                let is_tag = payload.is_some()
+                    || tag.contains("::")
+                    || tag.starts_with(|c: char| c.is_ascii_uppercase());
+                if is_tag {
+                    Some(MatchPattern::Tag { tag, payload })
+                } else {
+                    Some(MatchPattern::Binding(tag))
+                }
+            }
+            TokenKind::Punctuation('[') => {
+                self.advance(); // [
+                let mut elements = Vec::new();
+                let mut rest = None;
+                while !matches!(self.peek(), TokenKind::Punctuation(']')) {
+                    if matches!(self.peek(), TokenKind::Operator(op) if op == "...") {
+                        self.advance(); // ...
+                        rest = Some(self.parse_match_rest_name()?);
                     } else {
-                        // Plain identifier pattern
-                        if matches!(self.peek(), TokenKind::Punctuation('(')) {
-                            self.advance(); // (
-                            let var = match self.advance() {
-                                TokenKind::Identifier(v) => v.clone(),
-                                _ => return None,
-                            };
-                            self.advance(); // )
-                            format!("{}({})", pat, var)
-                        } else {
-                            pat
+                        elements.push(self.parse_match_pattern()?);
+                    }
+                    if !matches!(self.peek(), TokenKind::Punctuation(',')) || rest.is_some() {
+                        break;
+                    }
+                    self.advance(); // ,
+                }
+                if !self.expect_punctuation(']', "to close array match pattern") {
+                    return None;
+                }
+                Some(MatchPattern::Array { elements, rest })
+            }
+            TokenKind::Punctuation('{') => {
+                self.advance(); // {
+                let mut entries = Vec::new();
+                let mut rest = None;
+                while !matches!(self.peek(), TokenKind::Punctuation('}')) {
+                    match self.advance().clone() {
+                        TokenKind::Operator(op) if op == "..." => {
+                            rest = Some(self.parse_match_rest_name()?);
                         }
-                    };
-
-                    self.advance(); // :
-                    let body = self.parse_statement_block(
-                        "to start match case block",
-                        "to close match case block",
-                        "match case block",
-                    )?;
-                    cases.push((pat_str, body));
+                        TokenKind::String(key) | TokenKind::Identifier(key) => {
+                            if matches!(self.peek(), TokenKind::Punctuation(':')) {
+                                self.advance(); // :
+                                entries.push((key, self.parse_match_pattern()?));
+                            } else {
+                                entries.push((key.clone(), MatchPattern::Binding(key)));
+                            }
+                        }
+                        found => {
+                            self.push_diagnostic(format!(
+                                "Expected a key in dict match pattern, found {:?}",
+                                found
+                            ));
+                            return None;
+                        }
+                    }
+                    if !matches!(self.peek(), TokenKind::Punctuation(',')) || rest.is_some() {
+                        break;
+                    }
+                    self.advance(); // ,
                 }
-                TokenKind::Keyword(k) if k == "default" => {
-                    self.advance(); // default
-                    self.advance(); // :
-                    default = Some(self.parse_statement_block(
-                        "to start match default block",
-                        "to close match default block",
-                        "match default block",
-                    )?);
+                if !self.expect_punctuation('}', "to close dict match pattern") {
+                    return None;
                 }
-                _ => break,
+                Some(MatchPattern::Dict { entries, rest })
+            }
+            found => {
+                self.push_diagnostic(format!("Expected a match pattern, found {:?}", found));
+                None
             }
         }
+    }
 
-        self.advance(); // }
-        Some(Stmt::Match { value, cases, default })
+    fn parse_match_rest_name(&mut self) -> Option<String> {
+        match self.advance().clone() {
+            TokenKind::Identifier(name) => Some(name),
+            found => {
+                self.push_diagnostic(format!(
+                    "Expected a name after '...' in match pattern, found {:?}",
+                    found
+                ));
+                None
+            }
+        }
+    }
+
+    /// `label:` followed by `loop`, `while`, or `for` starts a labeled loop.
+    fn starts_labeled_loop(&self) -> bool {
+        matches!(self.tokens.get(self.pos + 1).map(|t| &t.kind), Some(TokenKind::Punctuation(':')))
+            && matches!(
+                self.tokens.get(self.pos + 2).map(|t| &t.kind),
+                Some(TokenKind::Keyword(k)) if k == "loop" || k == "while" || k == "for"
+            )
+    }
+
+    fn parse_labeled_loop(&mut self) -> Option<Stmt> {
+        let label = match self.advance() {
+            TokenKind::Identifier(name) => name.clone(),
+            _ => return None,
+        };
+        self.advance(); // :
+        let loop_stmt = match self.peek() {
+            TokenKind::Keyword(k) if k == "loop" => self.parse_loop()?,
+            TokenKind::Keyword(k) if k == "while" => self.parse_while()?,
+            _ => self.parse_for()?,
+        };
+        Some(Stmt::LabeledLoop { label, loop_stmt: Box::new(loop_stmt) })
+    }
+
+    /// Whether the current token sits on the line where the previous token ended.
+    fn continues_previous_line(&self) -> bool {
+        match (
+            self.pos.checked_sub(1).and_then(|pos| self.tokens.get(pos)),
+            self.tokens.get(self.pos),
+        ) {
+            (Some(previous), Some(current)) => current.line == previous.end_line,
+            _ => true,
+        }
+    }
+
+    /// Consume `break`/`continue` and an optional target label on the same line.
+    fn parse_loop_jump_label(&mut self) -> Option<String> {
+        let keyword_line = self.tokens.get(self.pos).map(|t| t.line);
+        self.advance(); // break / continue
+        let next = self.tokens.get(self.pos)?;
+        match &next.kind {
+            TokenKind::Identifier(label) if Some(next.line) == keyword_line => {
+                let label = label.clone();
+                self.advance();
+                Some(label)
+            }
+            _ => None,
+        }
     }
 
     fn parse_loop(&mut self) -> Option<Stmt> {
@@ -1198,6 +1792,17 @@ impl Parser {
 
     fn parse_spawn(&mut self) -> Option<Stmt> {
         self.advance(); // spawn
+        if !matches!(self.peek(), TokenKind::Punctuation('{')) {
+            // `spawn worker(a, b)` runs a single call on the new thread.
+            let call = self.parse_expr()?;
+            if !matches!(call, Expr::Call { .. } | Expr::MethodCall { .. }) {
+                self.push_diagnostic(
+                    "Expected a block or a function call after 'spawn'".to_string(),
+                );
+                return None;
+            }
+            return Some(Stmt::Spawn { body: vec![Stmt::ExprStmt(call)] });
+        }
         let body = self.parse_statement_block(
             "to start spawn block",
             "to close spawn block",
@@ -1263,9 +1868,16 @@ impl Parser {
         self.advance(); // try
         let try_block =
             self.parse_statement_block("to start try block", "to close try block", "try block")?;
-        if !self.expect_keyword("except", "after try block") {
+        // `catch` is accepted as a contextual alias so it stays usable as an identifier
+        if matches!(self.peek(), TokenKind::Identifier(name) if name == "catch") {
+            self.advance();
+        } else if !self.expect_keyword("except", "after try block") {
             return None;
         }
+        let parenthesized = matches!(self.peek(), TokenKind::Punctuation('('));
+        if parenthesized {
+            self.advance();
+        }
         let except_var = match self.advance() {
             TokenKind::Identifier(v) => v.clone(),
             _ => {
@@ -1273,18 +1885,33 @@ impl Parser {
                 return None;
             }
         };
+        if parenthesized && !self.expect_punctuation(')', "to close exception variable") {
+            return None;
+        }
         let except_block = self.parse_statement_block(
             "to start except block",
             "to close except block",
             "except block",
         )?;
-        Some(Stmt::TryExcept { try_block, except_var, except_block })
+        let finally_block = if matches!(self.peek(), TokenKind::Identifier(name) if name == "finally")
+        {
+            self.advance();
+            Some(self.parse_statement_block(
+                "to start finally block",
+                "to close finally block",
+                "finally block",
+            )?)
+        } else {
+            None
+        };
+        Some(Stmt::TryExcept { try_block, except_var, except_block, finally_block })
     }
 
     fn parse_import(&mut self) -> Option<Stmt> {
-        // Two forms:
+        // Three forms:
         // 1. import module
-        // 2. from module import symbol1, symbol2
+        // 2. import "path/to/module" (namespace import)
+        // 3. from module import symbol1, symbol2
 
         let is_from = matches!(self.peek(), TokenKind::Keyword(k) if k == "from");
         self.advance(); // import or from
@@ -1321,6 +1948,17 @@ impl Parser {
             // import module
             let module = match self.advance() {
                 TokenKind::Identifier(m) => m.clone(),
+                TokenKind::String(path) => {
+                    let path = path.clone();
+                    let namespace = Self::import_path_namespace(&path);
+                    if namespace.is_none() {
+                        self.push_diagnostic(format!(
+                            "Import path '{}' does not end in a file name usable as a namespace",
+                            path
+                        ));
+                    }
+                    return Some(Stmt::ImportPath { path, namespace: namespace? });
+                }
                 _ => {
                     self.push_diagnostic("Expected module name after 'import'");
                     return None;
@@ -1331,6 +1969,20 @@ impl Parser {
         }
     }
 
+    /// Namespace name for `import "dir/name.ruff"`: the file name without its extension.
+    fn import_path_namespace(path: &str) -> Option<String> {
+        let file_name = path.rsplit(['/', '\\']).next()?;
+        let stem = file_name.strip_suffix(".ruff").unwrap_or(file_name);
+        let mut chars = stem.chars();
+        let starts_like_identifier =
+            chars.next().is_some_and(|first| first.is_alphabetic() || first == '_');
+        if starts_like_identifier && chars.all(|c| c.is_alphanumeric() || c == '_') {
+            Some(stem.to_string())
+        } else {
+            None
+        }
+    }
+
     fn parse_from_import_module_path(&mut self) -> Option<String> {
         let mut module = match self.advance() {
             TokenKind::Identifier(m) => m.clone(),
@@ -1377,7 +2029,9 @@ impl Parser {
         let else_branch = if matches!(self.peek(), TokenKind::Keyword(k) if k == "else") {
             self.advance(); // else
             if matches!(self.peek(), TokenKind::Keyword(k) if k == "if") {
+                let start_pos = self.pos;
                 let nested_if = self.parse_if()?;
+                self.record_ast_span(AstNodeSpanKind::Statement, start_pos, self.pos);
                 Some(vec![nested_if])
             } else {
                 self.parse_statement_block(
@@ -1441,6 +2095,11 @@ impl Parser {
         // Check for throw - still uses Tag since it's a control-flow primitive
         if let TokenKind::Identifier(name) = self.peek() {
             let name_clone = name.clone();
+            if name_clone.as_str() == "throw" && self.throw_operand_follows() {
+                self.advance(); // throw
+                let operand = self.parse_expr()?;
+                return Some(Expr::Tag(name_clone, vec![operand]));
+            }
             if name_clone.as_str() == "throw"
                 && self.tokens.get(self.pos + 1).map(|t| &t.kind)
                     == Some(&TokenKind::Punctuation('('))
@@ -1467,24 +2126,151 @@ impl Parser {
             }
         }
 
-        self.parse_pipe()
+        self.parse_ternary()
     }
 
+    /// Conditional expression `cond ? a : b`, the lowest-precedence expression form.
+    /// Both branches are full expressions, which makes chains right-associative:
+    /// `a ? b : c ? d : e` parses as `a ? b : (c ? d : e)`.
+    fn parse_ternary(&mut self) -> Option<Expr> {
+        let condition = self.parse_pipe()?;
+
+        if !matches!(self.peek(), TokenKind::Operator(op) if op == "?") {
+            return Some(condition);
+        }
+        self.advance(); // ?
+
+        let then_expr = self.parse_expr()?;
+        if !self.expect_punctuation(':', "to separate conditional expression branches") {
+            return None;
+        }
+        let else_expr = self.parse_expr()?;
+
+        Some(Expr::Ternary {
+            condition: Box::new(condition),
+            then_expr: Box::new(then_expr),
+            else_expr: Box::new(else_expr),
+        })
+    }
+
+    /// Decide whether the `?` at the current position opens a conditional expression
+    /// rather than applying the postfix try operator.
+    ///
+    /// It is a conditional when an expression follows the `?` and a matching `:` appears
+    /// at the same bracket depth before the enclosing expression ends.
+    fn question_starts_ternary(&self) -> bool {
+        let starts_expression = match self.tokens.get(self.pos + 1).map(|t| &t.kind) {
+            Some(TokenKind::Identifier(_))
+            | Some(TokenKind::Int(_))
+            | Some(TokenKind::Float(_))
+            | Some(TokenKind::String(_))
+            | Some(TokenKind::InterpolatedString(_))
+            | Some(TokenKind::Bool(_))
+            | Some(TokenKind::Punctuation('(' | '[' | '{')) => true,
+            Some(TokenKind::Operator(op)) => matches!(op.as_str(), "-" | "!"),
+            Some(TokenKind::Keyword(keyword)) => {
+                matches!(keyword.as_str(), "null" | "self" | "func" | "await")
+            }
+            _ => false,
+        };
+        if !starts_expression {
+            return false;
+        }
+
+        // A line break at depth 0 ends the statement unless the next line continues the
+        // ternary with its `:`, so `f()?` followed by `outer: for ...` stays a try.
+        let mut depth = 0usize;
+        let mut previous_line = self.tokens[self.pos].end_line;
+        for token in &self.tokens[self.pos + 1..] {
+            let starts_line = token.line > previous_line;
+            previous_line = token.end_line;
+            if depth == 0 && starts_line && token.kind != TokenKind::Punctuation(':') {
+                return false;
+            }
+            match &token.kind {
+                TokenKind::Punctuation('(' | '[' | '{') => depth += 1,
+                TokenKind::Punctuation(')' | ']' | '}') => {
+                    if depth == 0 {
+                        return false;
+                    }
+                    depth -= 1;
+                }
+                TokenKind::Punctuation(':') if depth == 0 => return true,
+                TokenKind::Punctuation(',' | ';') if depth == 0 => return false,
+                TokenKind::Operator(op)
+                    if depth == 0
+                        && matches!(op.as_str(), ":=" | "=" | "+=" | "-=" | "*=" | "/=" | "%=") =>
+                {
+                    return false;
+                }
+                TokenKind::Keyword(keyword)
+                    if depth == 0
+                        && !matches!(keyword.as_str(), "null" | "self" | "func" | "await") =>
+                {
+                    return false;
+                }
+                TokenKind::Eof => return false,
+                _ => {}
+            }
+        }
+
+        false
+    }
+
+    /// Pipe `value |> f`, desugared here into the call it stands for, so the runtimes
+    /// never see the operator. `value |> f` becomes `f(value)` and `value |> f(a)` becomes
+    /// `f(value, a)`; a `_` argument marks another slot: `value |> f(a, _)` is `f(a, value)`.
     fn parse_pipe(&mut self) -> Option<Expr> {
         let mut left = self.parse_null_coalescing()?;
 
         while matches!(self.peek(), TokenKind::Operator(op) if op == "|>") {
-            let op = match self.advance() {
-                TokenKind::Operator(o) => o.clone(),
-                _ => break,
-            };
+            self.advance(); // |>
             let right = self.parse_null_coalescing()?;
-            left = Expr::BinaryOp { left: Box::new(left), op, right: Box::new(right) };
+            left = self.pipe_into(left, right);
         }
 
         Some(left)
     }
 
+    /// The call `right` makes when `value` is piped into it.
+    fn pipe_into(&mut self, value: Expr, right: Expr) -> Expr {
+        match right {
+            Expr::Call { function, args } => {
+                Expr::Call { function, args: self.pipe_args(value, args) }
+            }
+            Expr::MethodCall { object, method, args } => {
+                Expr::MethodCall { object, method, args: self.pipe_args(value, args) }
+            }
+            function => Expr::Call { function: Box::new(function), args: vec![value] },
+        }
+    }
+
+    /// Put a piped value into the `_` argument slot, or in front of the arguments when no
+    /// argument is `_`.
+    fn pipe_args(&mut self, value: Expr, mut args: Vec<Expr>) -> Vec<Expr> {
+        fn slot(arg: &mut Expr) -> Option<&mut Expr> {
+            let inner = match arg {
+                Expr::Spread(inner) => inner.as_mut(),
+                Expr::NamedArg { value, .. } => value.as_mut(),
+                other => other,
+            };
+            matches!(inner, Expr::Identifier(name) if name == "_").then_some(inner)
+        }
+
+        let placeholders = args.iter_mut().filter_map(slot).count();
+        if placeholders == 0 {
+            args.insert(0, value);
+            return args;
+        }
+        if placeholders > 1 {
+            self.push_diagnostic("Pipe placeholder '_' can appear only once in a call");
+        }
+        if let Some(placeholder) = args.iter_mut().find_map(slot) {
+            *placeholder = value;
+        }
+        args
+    }
+
     fn parse_null_coalescing(&mut self) -> Option<Expr> {
         let mut left = self.parse_or()?;
 
@@ -1610,6 +2396,59 @@ impl Parser {
         self.parse_call()
     }
 
+    /// Parse call arguments after `(` through the closing `)`. An argument is an
+    /// expression, a spread `...items`, or a named `label: value`; named arguments
+    /// must come after all positional ones.
+    fn parse_call_args(&mut self, closing_context: &str) -> Option<Vec<Expr>> {
+        let mut args = Vec::new();
+        while !matches!(self.peek(), TokenKind::Punctuation(')'))
+            && !matches!(self.peek(), TokenKind::Eof)
+        {
+            let is_named = matches!(self.peek(), TokenKind::Identifier(_))
+                && matches!(
+                    self.tokens.get(self.pos + 1).map(|t| &t.kind),
+                    Some(TokenKind::Punctuation(':'))
+                );
+            let arg = if is_named {
+                let name = match self.advance() {
+                    TokenKind::Identifier(name) => name.clone(),
+                    _ => return None,
+                };
+                self.advance(); // :
+                self.parse_expr().map(|value| Expr::NamedArg { name, value: Box::new(value) })
+            } else if matches!(self.peek(), TokenKind::Operator(op) if op == "...") {
+                self.advance(); // ...
+                self.parse_expr().map(|value| Expr::Spread(Box::new(value)))
+            } else {
+                self.parse_expr()
+            };
+            if let Some(arg) = arg {
+                if !matches!(arg, Expr::NamedArg { .. })
+                    && args.iter().any(|arg| matches!(arg, Expr::NamedArg { .. }))
+                {
+                    self.push_diagnostic("Positional argument cannot follow a named argument");
+                }
+                if let Expr::NamedArg { name, .. } = &arg {
+                    if args.iter().any(
+                        |arg| matches!(arg, Expr::NamedArg { name: earlier, .. } if earlier == name),
+                    ) {
+                        self.push_diagnostic(format!("Argument '{}' is passed more than once", name));
+                    }
+                }
+                args.push(arg);
+            }
+            if matches!(self.peek(), TokenKind::Punctuation(',')) {
+                self.advance();
+            } else {
+                break;
+            }
+        }
+        if !self.expect_punctuation(')', closing_context) {
+            return None;
+        }
+        Some(args)
+    }
+
     fn parse_call(&mut self) -> Option<Expr> {
         let mut expr = self.parse_primary()?;
 
@@ -1618,50 +2457,19 @@ impl Parser {
                 // Handle function calls
                 TokenKind::Punctuation('(') => {
                     self.advance(); // (
-                    let mut args = Vec::new();
-                    while !matches!(self.peek(), TokenKind::Punctuation(')'))
-                        && !matches!(self.peek(), TokenKind::Eof)
-                    {
-                        if let Some(arg) = self.parse_expr() {
-                            args.push(arg);
-                        }
-                        if matches!(self.peek(), TokenKind::Punctuation(',')) {
-                            self.advance();
-                        } else {
-                            break;
-                        }
-                    }
-                    if !self.expect_punctuation(')', "to close function call arguments") {
-                        return None;
-                    }
+                    let args = self.parse_call_args("to close function call arguments")?;
                     expr = Expr::Call { function: Box::new(expr), args };
                 }
                 // Handle field access and method calls
                 TokenKind::Punctuation('.') => {
                     self.advance(); // .
-                    if let TokenKind::Identifier(field) = self.peek() {
-                        let field_name = field.clone();
+                    if let Some(field_name) = self.peek_member_name() {
                         self.advance();
 
                         // Check if this is a method call (field access followed by ())
                         if matches!(self.peek(), TokenKind::Punctuation('(')) {
                             self.advance(); // (
-                            let mut args = Vec::new();
-                            while !matches!(self.peek(), TokenKind::Punctuation(')'))
-                                && !matches!(self.peek(), TokenKind::Eof)
-                            {
-                                if let Some(arg) = self.parse_expr() {
-                                    args.push(arg);
-                                }
-                                if matches!(self.peek(), TokenKind::Punctuation(',')) {
-                                    self.advance();
-                                } else {
-                                    break;
-                                }
-                            }
-                            if !self.expect_punctuation(')', "to close method call arguments") {
-                                return None;
-                            }
+                            let args = self.parse_call_args("to close method call arguments")?;
                             expr = Expr::MethodCall {
                                 object: Box::new(expr),
                                 method: field_name,
@@ -1678,8 +2486,7 @@ impl Parser {
                 // Handle optional chaining: obj?.field
                 TokenKind::Operator(op) if op == "?." => {
                     self.advance(); // ?.
-                    if let TokenKind::Identifier(field) = self.peek() {
-                        let field_name = field.clone();
+                    if let Some(field_name) = self.peek_member_name() {
                         self.advance();
                         // Optional chaining returns null if object is null, otherwise accesses field
                         // We'll represent this as a BinaryOp with special handling in the interpreter
@@ -1692,19 +2499,40 @@ impl Parser {
                         break;
                     }
                 }
-                // Handle try operator: expr?
-                TokenKind::Operator(op) if op == "?" => {
+                // Handle try operator: expr? (a `?` that opens `cond ? a : b` is left for
+                // parse_ternary)
+                TokenKind::Operator(op) if op == "?" && !self.question_starts_ternary() => {
                     self.advance(); // ?
                     expr = Expr::Try(Box::new(expr));
                 }
-                // Handle index access: arr[index]
-                TokenKind::Punctuation('[') => {
+                // Handle index access: arr[index], and slices: arr[start:end]. A `[` that
+                // starts a new line opens an array literal such as `[x, y] := pair` instead.
+                TokenKind::Punctuation('[') if self.continues_previous_line() => {
                     self.advance(); // [
-                    let index = self.parse_expr()?;
-                    if !self.expect_punctuation(']', "to close index expression") {
-                        return None;
+                    let start = if matches!(self.peek(), TokenKind::Punctuation(':')) {
+                        None
+                    } else {
+                        Some(self.parse_expr()?)
+                    };
+                    if matches!(self.peek(), TokenKind::Punctuation(':')) {
+                        self.advance(); // :
+                        let end = if matches!(self.peek(), TokenKind::Punctuation(']')) {
+                            None
+                        } else {
+                            Some(Box::new(self.parse_expr()?))
+                        };
+                        if !self.expect_punctuation(']', "to close slice expression") {
+                            return None;
+                        }
+                        expr =
+                            Expr::Slice { object: Box::new(expr), start: start.map(Box::new), end };
+                    } else {
+                        let index = start?;
+                        if !self.expect_punctuation(']', "to close index expression") {
+                            return None;
+                        }
+                        expr = Expr::IndexAccess { object: Box::new(expr), index: Box::new(index) };
                     }
-                    expr = Expr::IndexAccess { object: Box::new(expr), index: Box::new(index) };
                 }
                 // Handle struct instantiation: Struct { field1: val1, field2: val2 }
                 TokenKind::Punctuation('{') if matches!(expr, Expr::Identifier(_)) => {
@@ -1801,7 +2629,8 @@ impl Parser {
             TokenKind::Keyword(k) if k == "func" => self.parse_func_expr_with_async(false),
             TokenKind::Keyword(k) if k == "yield" => {
                 self.advance(); // consume yield
-                                // yield can have an optional value
+                self.saw_yield = true;
+                // yield can have an optional value
                 let value = if !matches!(
                     self.peek(),
                     TokenKind::Punctuation(';') | TokenKind::Punctuation('}')
@@ -1822,11 +2651,28 @@ impl Parser {
                 self.advance();
                 Some(Expr::Identifier("null".to_string()))
             }
+            TokenKind::Keyword(k) if k == "match" => {
+                let (value, cases, default) = self.parse_match_arms()?;
+                Some(Expr::Match { value: Box::new(value), cases, default })
+            }
             TokenKind::Keyword(k) if k == "self" => {
                 // Treat 'self' as an identifier in expression context
                 self.advance();
                 Some(Expr::Identifier("self".to_string()))
             }
+            TokenKind::Keyword(k)
+                if (k == "int" || k == "float")
+                    && matches!(
+                        self.tokens.get(self.pos + 1).map(|t| &t.kind),
+                        Some(TokenKind::Punctuation('('))
+                    ) =>
+            {
+                // `int(x)` / `float(x)` are conversion calls; the bare keywords stay
+                // reserved for type annotations.
+                let name = k.clone();
+                self.advance();
+                Some(Expr::Identifier(name))
+            }
             TokenKind::Identifier(id) if id == "None" => {
                 // Handle None (no arguments)
                 self.advance();
@@ -2004,8 +2850,9 @@ impl Parser {
             }
             self.advance(); // consume :
 
-            // Parse value - use parse_equality to avoid recursion
-            let value = self.parse_equality()?;
+            // Parse value as a full expression so `{k: ok ? a : b}` and `{k: a || b}` work;
+            // the value is always terminated by ',' or '}'
+            let value = self.parse_expr()?;
             pairs.push(crate::ast::DictElement::Pair(key, value));
             if pairs.len() > self.max_collection_literal_items {
                 self.push_diagnostic(format!(
@@ -0,0 +1,572 @@
+// File: src/playground.rs
+//
+// `ruff playground`: a local web UI for typing Ruff code and seeing its output.
+//
+// The server never evaluates code itself. Each run starts a fresh
+// `ruff playground-worker` process that reads the source from stdin, evaluates it
+// in a `BrowserSession`, and writes the `EvalResponse` JSON to stdout; the server
+// forwards that JSON to the page. A runaway or crashing script therefore only takes
+// its own worker down.
+//
+// In `--sandbox` mode the worker gets the browser capability set (clock and random
+// numbers, no filesystem, processes, environment, or network) and, on Unix, runs
+// under `ulimit` data-segment and CPU-time limits. That is the mode meant for
+// exposing the playground beyond loopback; without it the worker has the same
+// capabilities as `ruff run` and the server refuses non-loopback hosts.
+
+use crate::interpreter::RuntimeCapabilityPolicy;
+use crate::wasm::{BrowserSession, EvalResponse};
+use serde_json::json;
+use std::io::{Read, Write};
+use std::net::IpAddr;
+use std::process::{Child, Command, ExitStatus, Stdio};
+use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
+use std::sync::Arc;
+use std::thread;
+use std::time::{Duration, Instant};
+use tiny_http::{Header, Method, Request, Response, Server, StatusCode};
+
+const INDEX_HTML: &str = include_str!("../tools/ruff-playground/index.html");
+const PLAYGROUND_JS: &str = include_str!("../tools/ruff-playground/playground.js");
+const PLAYGROUND_CSS: &str = include_str!("../tools/ruff-playground/playground.css");
+
+/// Largest program accepted by `POST /run`.
+pub const MAX_SOURCE_BYTES: usize = 64 * 1024;
+/// Largest worker response forwarded to the page; more output fails the run.
+const MAX_RESPONSE_BYTES: usize = 1024 * 1024;
+/// Runs evaluated at once; further requests get `503`.
+const MAX_CONCURRENT_RUNS: usize = 8;
+/// Time a worker gets past its own limit to report before the server kills it.
+const WORKER_GRACE: Duration = Duration::from_secs(2);
+const RECEIVE_POLL_INTERVAL: Duration = Duration::from_millis(500);
+const WORKER_POLL_INTERVAL: Duration = Duration::from_millis(10);
+/// Header `playground.js` sends with every run. Setting it makes a cross-site `fetch`
+/// non-simple, so browsers refuse to send it without a CORS preflight we never answer.
+const RUN_HEADER: &str = "X-Ruff-Playground";
+
+#[derive(Debug, Clone)]
+pub struct PlaygroundOptions {
+    pub sandbox: bool,
+    pub time_limit: Duration,
+    pub memory_limit_mb: u64,
+}
+
+#[derive(Debug, PartialEq)]
+enum Route {
+    Asset(&'static str, &'static str),
+    Info,
+    Run,
+    NotFound,
+    MethodNotAllowed(&'static str),
+    Forbidden,
+}
+
+pub fn run_playground_server(
+    host: String,
+    port: u16,
+    options: PlaygroundOptions,
+) -> Result<(), String> {
+    if options.time_limit.is_zero() {
+        return Err("playground time limit must be greater than 0ms".to_string());
+    }
+    if options.sandbox && 0 == options.memory_limit_mb {
+        return Err("playground memory limit must be greater than 0MB".to_string());
+    }
+    if !options.sandbox && !is_loopback_host(&host) {
+        return Err(format!(
+            "Refusing to expose the playground on '{}' without --sandbox; \
+             unsandboxed runs can read and write local files",
+            host
+        ));
+    }
+
+    let bind_addr = format!("{}:{}", host, port);
+    let listener = std::net::TcpListener::bind(&bind_addr)
+        .map_err(|err| format!("Failed to bind {}: {}", bind_addr, err))?;
+    // Loopback servers only answer requests addressed to loopback, which stops DNS rebinding
+    let loopback_port = match listener.local_addr() {
+        Ok(addr) if is_loopback_host(&host) => Some(addr.port()),
+        _ => None,
+    };
+    let server = Server::from_listener(listener, None)
+        .map_err(|err| format!("Failed to start playground on {}: {}", bind_addr, err))?;
+
+    println!("Ruff playground on http://{}", bind_addr);
+    if options.sandbox {
+        println!(
+            "Sandboxed runs: {} ms time limit, {} MB memory limit, no filesystem or network access",
+            options.time_limit.as_millis(),
+            options.memory_limit_mb
+        );
+    } else {
+        println!(
+            "Runs have local capabilities and a {} ms time limit",
+            options.time_limit.as_millis()
+        );
+    }
+    println!("Press Ctrl+C to stop");
+
+    let options = Arc::new(options);
+    let active_runs = Arc::new(AtomicUsize::new(0));
+
+    loop {
+        let request = match server.recv_timeout(RECEIVE_POLL_INTERVAL) {
+            Ok(Some(request)) => request,
+            Ok(None) => continue,
+            Err(error) => return Err(format!("HTTP server receive error: {}", error)),
+        };
+
+        let options = Arc::clone(&options);
+        let active_runs = Arc::clone(&active_runs);
+        thread::spawn(move || handle_request(request, &options, &active_runs, loopback_port));
+    }
+}
+
+fn handle_request(
+    mut request: Request,
+    options: &PlaygroundOptions,
+    active_runs: &AtomicUsize,
+    loopback_port: Option<u16>,
+) {
+    let planned = match route(request.method(), request.url()) {
+        planned if !is_allowed_request(request.headers(), &planned, loopback_port) => {
+            Route::Forbidden
+        }
+        planned => planned,
+    };
+    let allow = match planned {
+        Route::MethodNotAllowed(allow) => Some(allow),
+        _ => None,
+    };
+    let (status, content_type, body) = match planned {
+        Route::Asset(content_type, body) => (200, content_type, body.as_bytes().to_vec()),
+        Route::Info => (
+            200,
+            "application/json",
+            json!({
+                "sandbox": options.sandbox,
+                "time_limit_ms": options.time_limit.as_millis() as u64,
+                "memory_limit_mb": options.memory_limit_mb,
+                "max_source_bytes": MAX_SOURCE_BYTES,
+            })
+            .to_string()
+            .into_bytes(),
+        ),
+        Route::Run => match read_source(&mut request) {
+            Err((status, message)) => (status, "application/json", error_response(&message)),
+            Ok(source) => {
+                if active_runs.fetch_add(1, Ordering::AcqRel) >= MAX_CONCURRENT_RUNS {
+                    active_runs.fetch_sub(1, Ordering::AcqRel);
+                    (
+                        503,
+                        "application/json",
+                        error_response("The playground is busy; try again shortly"),
+                    )
+                } else {
+                    let response = execute(&source, options);
+                    active_runs.fetch_sub(1, Ordering::AcqRel);
+                    (200, "application/json", response)
+                }
+            }
+        },
+        Route::NotFound => (404, "text/plain; charset=utf-8", b"Not Found".to_vec()),
+        Route::MethodNotAllowed(_) => {
+            (405, "text/plain; charset=utf-8", b"Method Not Allowed".to_vec())
+        }
+        Route::Forbidden => (403, "text/plain; charset=utf-8", b"Forbidden".to_vec()),
+    };
+
+    let mut headers = vec![
+        ("Content-Type", content_type),
+        ("Cache-Control", "no-store"),
+        ("X-Content-Type-Options", "nosniff"),
+        ("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'"),
+    ];
+    if let Some(allow) = allow {
+        headers.push(("Allow", allow));
+    }
+
+    let length = body.len();
+    let mut response = Response::new(
+        StatusCode(status),
+        Vec::new(),
+        std::io::Cursor::new(body),
+        Some(length),
+        None,
+    );
+    for (name, value) in headers {
+        if let Ok(header) = Header::from_bytes(name.as_bytes(), value.as_bytes()) {
+            response.add_header(header);
+        }
+    }
+    let _ = request.respond(response);
+}
+
+fn route(method: &Method, url: &str) -> Route {
+    let path = url.split(['?', '#']).next().unwrap_or("");
+    let route = match path {
+        "/" | "/index.html" => Route::Asset("text/html; charset=utf-8", INDEX_HTML),
+        "/playground.js" => Route::Asset("text/javascript; charset=utf-8", PLAYGROUND_JS),
+        "/playground.css" => Route::Asset("text/css; charset=utf-8", PLAYGROUND_CSS),
+        "/info" => Route::Info,
+        "/run" => Route::Run,
+        _ => return Route::NotFound,
+    };
+    match (&route, method) {
+        (Route::Run, Method::Post) => route,
+        (Route::Run, _) => Route::MethodNotAllowed("POST"),
+        (_, Method::Get) => route,
+        _ => Route::MethodNotAllowed("GET"),
+    }
+}
+
+/// Whether a request may reach `route`. Every request to a loopback server must name
+/// that server in `Host`; runs must also carry `RUN_HEADER` and, when the browser sends
+/// an `Origin`, come from the playground's own origin.
+fn is_allowed_request(headers: &[Header], route: &Route, loopback_port: Option<u16>) -> bool {
+    let header = |name: &'static str| {
+        headers.iter().find(|header| header.field.equiv(name)).map(|header| header.value.as_str())
+    };
+    let host = header("Host");
+    if let Some(port) = loopback_port {
+        if !host.is_some_and(|host| is_loopback_authority(host, port)) {
+            return false;
+        }
+    }
+    if *route != Route::Run {
+        return true;
+    }
+    let same_origin = match (header("Origin"), host) {
+        (None, _) => true,
+        // A playground behind a TLS-terminating proxy is loaded over `https://`
+        (Some(origin), Some(host)) => origin
+            .strip_prefix("https://")
+            .or_else(|| origin.strip_prefix("http://"))
+            .is_some_and(|origin| origin.eq_ignore_ascii_case(host)),
+        (Some(_), None) => false,
+    };
+    same_origin && header(RUN_HEADER) == Some("1")
+}
+
+/// Whether a `Host` header value names a loopback host on `port`.
+fn is_loopback_authority(authority: &str, port: u16) -> bool {
+    let (host, authority_port) = match authority.rsplit_once(':') {
+        Some((host, authority_port)) if !authority_port.contains(']') => {
+            (host, authority_port.parse::<u16>().ok())
+        }
+        _ => (authority, Some(80)),
+    };
+    authority_port == Some(port) && is_loopback_host(host)
+}
+
+fn read_source(request: &mut Request) -> Result<String, (u16, String)> {
+    let too_large = || (413, format!("Programs are limited to {} bytes", MAX_SOURCE_BYTES));
+    if request.body_length().is_some_and(|length| length > MAX_SOURCE_BYTES) {
+        return Err(too_large());
+    }
+    let mut body = Vec::new();
+    request
+        .as_reader()
+        .take(MAX_SOURCE_BYTES as u64 + 1)
+        .read_to_end(&mut body)
+        .map_err(|err| (400, format!("Failed to read request body: {}", err)))?;
+    if body.len() > MAX_SOURCE_BYTES {
+        return Err(too_large());
+    }
+    String::from_utf8(body).map_err(|_| (400, "Programs must be UTF-8 text".to_string()))
+}
+
+fn error_response(message: &str) -> Vec<u8> {
+    let response =
+        EvalResponse { events: Vec::new(), value: None, error: Some(message.to_string()) };
+    serde_json::to_vec(&response).unwrap_or_default()
+}
+
+fn is_loopback_host(host: &str) -> bool {
+    host.eq_ignore_ascii_case("localhost")
+        || host.trim_matches(['[', ']']).parse::<IpAddr>().is_ok_and(|ip| ip.is_loopback())
+}
+
+/// Evaluates `source` in a worker process and returns the JSON body for the page.
+fn execute(source: &str, options: &PlaygroundOptions) -> Vec<u8> {
+    let mut child = match worker_command(options)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+    {
+        Ok(child) => child,
+        Err(err) => return error_response(&format!("Failed to start playground worker: {}", err)),
+    };
+
+    if let Some(mut stdin) = child.stdin.take() {
+        // A worker that exits before reading everything is reported by its status
+        let _ = stdin.write_all(source.as_bytes());
+    }
+    let stdout = child.stdout.take().map(|pipe| thread::spawn(move || read_capped(pipe)));
+    let stderr = child.stderr.take().map(|pipe| thread::spawn(move || read_capped(pipe)));
+
+    let status = wait_with_deadline(&mut child, options.time_limit + WORKER_GRACE);
+    let (stdout, truncated) =
+        stdout.and_then(|reader| reader.join().ok()).unwrap_or((Vec::new(), false));
+    let (stderr, _) = stderr.and_then(|reader| reader.join().ok()).unwrap_or((Vec::new(), false));
+
+    if truncated {
+        return error_response(&format!("Output exceeded {} bytes", MAX_RESPONSE_BYTES));
+    }
+    if status.is_some_and(|status| status.success())
+        && serde_json::from_slice::<serde_json::Value>(&stdout).is_ok_and(|json| json.is_object())
+    {
+        return stdout;
+    }
+
+    let stderr = String::from_utf8_lossy(&stderr);
+    let message = match status {
+        None => time_limit_message(options.time_limit),
+        Some(_) if stderr.contains("memory allocation") => {
+            format!("Memory limit of {} MB exceeded", options.memory_limit_mb)
+        }
+        Some(status) if cpu_limit_exceeded(&status) => time_limit_message(options.time_limit),
+        Some(status) => format!("The run ended unexpectedly ({})", status),
+    };
+    error_response(&message)
+}
+
+fn worker_command(options: &PlaygroundOptions) -> Command {
+    let executable = std::env::current_exe().unwrap_or_else(|_| "ruff".into());
+    let time_limit_ms = options.time_limit.as_millis().to_string();
+    let mut worker_args = vec!["playground-worker".to_string(), "--time-limit-ms".to_string()];
+    worker_args.push(time_limit_ms);
+    if !options.sandbox {
+        let mut command = Command::new(executable);
+        command.args(worker_args);
+        return command;
+    }
+    worker_args.push("--sandbox".to_string());
+
+    #[cfg(unix)]
+    let mut command = {
+        // `ulimit -d` bounds the heap (and every other private writable mapping);
+        // `ulimit -t` stops a worker that spins past the wall-clock watchdog
+        let cpu_seconds = options.time_limit.as_secs() + 1;
+        let mut command = Command::new("/bin/sh");
+        command
+            .arg("-c")
+            .arg(r#"ulimit -d "$1" && ulimit -t "$2" && shift 2 && exec "$@""#)
+            .arg("ruff-playground")
+            .arg((options.memory_limit_mb * 1024).to_string())
+            .arg(cpu_seconds.to_string())
+            .arg(executable)
+            .args(worker_args);
+        command
+    };
+    #[cfg(not(unix))]
+    let mut command = {
+        let mut command = Command::new(executable);
+        command.args(worker_args);
+        command
+    };
+
+    // Nothing from the server's environment or working directory reaches the script
+    command.env_clear().current_dir(std::env::temp_dir());
+    command
+}
+
+fn wait_with_deadline(child: &mut Child, limit: Duration) -> Option<ExitStatus> {
+    let deadline = Instant::now() + limit;
+    loop {
+        match child.try_wait() {
+            Ok(Some(status)) => return Some(status),
+            Ok(None) if Instant::now() < deadline => thread::sleep(WORKER_POLL_INTERVAL),
+            _ => {
+                let _ = child.kill();
+                let _ = child.wait();
+                return None;
+            }
+        }
+    }
+}
+
+/// Reads a pipe to the end, keeping at most `MAX_RESPONSE_BYTES`.
+fn read_capped(mut pipe: impl Read) -> (Vec<u8>, bool) {
+    let mut kept = Vec::new();
+    let mut truncated = false;
+    let mut buffer = [0u8; 8192];
+    while let Ok(read) = pipe.read(&mut buffer) {
+        if 0 == read {
+            break;
+        }
+        let room = MAX_RESPONSE_BYTES - kept.len();
+        kept.extend_from_slice(&buffer[..read.min(room)]);
+        truncated |= read > room;
+    }
+    (kept, truncated)
+}
+
+fn time_limit_message(limit: Duration) -> String {
+    format!("Time limit of {} ms exceeded", limit.as_millis())
+}
+
+#[cfg(unix)]
+fn cpu_limit_exceeded(status: &ExitStatus) -> bool {
+    use std::os::unix::process::ExitStatusExt;
+    status.signal().is_some_and(|signal| signal == libc::SIGXCPU || signal == libc::SIGKILL)
+}
+
+#[cfg(not(unix))]
+fn cpu_limit_exceeded(_status: &ExitStatus) -> bool {
+    false
+}
+
+/// Body of `ruff playground-worker`: evaluates stdin and prints the response JSON.
+///
+/// A watchdog thread reports the output produced so far and exits once
+/// `time_limit` passes, so a script that loops forever still shows what it printed.
+pub fn run_worker(sandbox: bool, time_limit: Duration) -> Result<(), String> {
+    let mut source = String::new();
+    std::io::stdin()
+        .take(MAX_SOURCE_BYTES as u64 + 1)
+        .read_to_string(&mut source)
+        .map_err(|err| format!("Failed to read program from stdin: {}", err))?;
+    if source.len() > MAX_SOURCE_BYTES {
+        return Err(format!("Programs are limited to {} bytes", MAX_SOURCE_BYTES));
+    }
+
+    let mut session = if sandbox {
+        BrowserSession::new()
+    } else {
+        BrowserSession::with_capability_policy(RuntimeCapabilityPolicy::trusted())
+    };
+    let reported = Arc::new(AtomicBool::new(false));
+
+    let log = session.output_log();
+    let watchdog_reported = Arc::clone(&reported);
+    thread::spawn(move || {
+        thread::sleep(time_limit);
+        if !watchdog_reported.swap(true, Ordering::AcqRel) {
+            let response = EvalResponse {
+                events: log.take(),
+                value: None,
+                error: Some(time_limit_message(time_limit)),
+            };
+            write_worker_response(&response);
+            std::process::exit(0);
+        }
+    });
+
+    let response = session.eval(&source);
+    if !reported.swap(true, Ordering::AcqRel) {
+        write_worker_response(&response);
+    }
+    Ok(())
+}
+
+fn write_worker_response(response: &EvalResponse) {
+    let mut stdout = std::io::stdout().lock();
+    let _ = serde_json::to_writer(&mut stdout, response);
+    let _ = stdout.flush();
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn routes_serve_the_page_and_accept_runs_only_by_post() {
+        assert!(matches!(route(&Method::Get, "/"), Route::Asset("text/html; charset=utf-8", _)));
+        assert!(matches!(route(&Method::Get, "/playground.js?v=1"), Route::Asset(_, _)));
+        assert_eq!(route(&Method::Get, "/info"), Route::Info);
+        assert_eq!(route(&Method::Post, "/run"), Route::Run);
+        assert_eq!(route(&Method::Get, "/run"), Route::MethodNotAllowed("POST"));
+        assert_eq!(route(&Method::Post, "/"), Route::MethodNotAllowed("GET"));
+        assert_eq!(route(&Method::Get, "/../etc/passwd"), Route::NotFound);
+    }
+
+    fn headers(pairs: &[(&str, &str)]) -> Vec<Header> {
+        pairs
+            .iter()
+            .map(|(name, value)| Header::from_bytes(name.as_bytes(), value.as_bytes()).unwrap())
+            .collect()
+    }
+
+    #[test]
+    fn runs_require_the_playground_header_and_origin() {
+        let page = [("Host", "127.0.0.1:8000"), ("X-Ruff-Playground", "1")];
+        assert!(is_allowed_request(&headers(&page), &Route::Run, Some(8000)));
+        let same_origin = [page[0], page[1], ("Origin", "http://127.0.0.1:8000")];
+        assert!(is_allowed_request(&headers(&same_origin), &Route::Run, Some(8000)));
+        let proxied = [("Host", "play.example"), page[1], ("Origin", "https://play.example")];
+        assert!(is_allowed_request(&headers(&proxied), &Route::Run, None));
+        let proxied_foreign = [proxied[0], page[1], ("Origin", "https://evil.example")];
+        assert!(!is_allowed_request(&headers(&proxied_foreign), &Route::Run, None));
+
+        let foreign_origin = [page[0], page[1], ("Origin", "https://evil.example")];
+        assert!(!is_allowed_request(&headers(&foreign_origin), &Route::Run, Some(8000)));
+        let simple_post = [page[0], ("Content-Type", "text/plain")];
+        assert!(!is_allowed_request(&headers(&simple_post), &Route::Run, Some(8000)));
+        let rebound = [("Host", "evil.example:8000"), page[1]];
+        assert!(!is_allowed_request(&headers(&rebound), &Route::Run, Some(8000)));
+        assert!(!is_allowed_request(&headers(&rebound), &Route::Info, Some(8000)));
+        let other_port = [("Host", "localhost:9000"), page[1]];
+        assert!(!is_allowed_request(&headers(&other_port), &Route::Run, Some(8000)));
+
+        // Sandboxed public servers cannot know their own host name, only loopback ones check it
+        assert!(is_allowed_request(&headers(&rebound), &Route::Run, None));
+        assert!(is_loopback_authority("[::1]:8000", 8000));
+        assert!(is_loopback_authority("localhost", 80));
+    }
+
+    #[test]
+    fn foreign_origin_runs_get_403() {
+        let Ok(listener) = std::net::TcpListener::bind("127.0.0.1:0") else {
+            return;
+        };
+        let port = listener.local_addr().unwrap().port();
+        let server = Server::from_listener(listener, None).unwrap();
+        let client = thread::spawn(move || {
+            let mut stream = std::net::TcpStream::connect(("127.0.0.1", port)).unwrap();
+            let request = format!(
+                "POST /run HTTP/1.1\r\nHost: 127.0.0.1:{}\r\nOrigin: https://evil.example\r\n\
+                 X-Ruff-Playground: 1\r\nContent-Type: text/plain\r\nContent-Length: 9\r\n\
+                 Connection: close\r\n\r\nprint(1)\n",
+                port
+            );
+            stream.write_all(request.as_bytes()).unwrap();
+            let mut response = String::new();
+            let _ = stream.read_to_string(&mut response);
+            response
+        });
+
+        let request = server.recv().unwrap();
+        let options = PlaygroundOptions {
+            sandbox: false,
+            time_limit: Duration::from_secs(1),
+            memory_limit_mb: 64,
+        };
+        handle_request(request, &options, &AtomicUsize::new(0), Some(port));
+        let response = client.join().unwrap();
+        assert!(response.starts_with("HTTP/1.1 403"), "{}", response);
+    }
+
+    #[test]
+    fn only_loopback_hosts_count_as_local() {
+        for host in ["127.0.0.1", "localhost", "::1", "[::1]", "127.0.0.2"] {
+            assert!(is_loopback_host(host), "{}", host);
+        }
+        for host in ["0.0.0.0", "192.168.1.10", "::", "example.com"] {
+            assert!(!is_loopback_host(host), "{}", host);
+        }
+    }
+
+    #[test]
+    fn unsandboxed_servers_refuse_public_hosts() {
+        let options = PlaygroundOptions {
+            sandbox: false,
+            time_limit: Duration::from_secs(1),
+            memory_limit_mb: 64,
+        };
+        let error = run_playground_server("0.0.0.0".to_string(), 0, options)
+            .expect_err("public host without --sandbox should be refused");
+        assert!(error.contains("--sandbox"), "{}", error);
+    }
+}
@@ -3,9 +3,9 @@
 // Interactive REPL (Read-Eval-Print Loop) for the Ruff programming language.
 // Provides an interactive shell for executing Ruff code with features like:
 // - Multi-line input support for functions, loops, and control structures
-// - Command history with up/down arrow navigation
+// - Command history with up/down arrow navigation and Ctrl+R search, persisted across sessions
 // - Line editing capabilities
-// - Special commands (:help, :clear, :quit, :vars)
+// - Special commands (:help, :clear, :quit, :vars, :load)
 // - Persistent state across inputs
 // - Proper error handling and display
 
@@ -23,8 +23,16 @@ use rustyline::validate::{ValidationContext, ValidationResult, Validator};
 use rustyline::{Context, Editor, Helper};
 use std::borrow::Cow;
 use std::collections::HashMap;
+use std::ffi::OsString;
+use std::fs;
+use std::path::PathBuf;
 use std::sync::Arc;
 
+/// Environment variable that overrides the history file location; an empty value disables
+/// persistent history.
+const HISTORY_ENV_VAR: &str = "RUFF_REPL_HISTORY";
+const HISTORY_FILE_NAME: &str = ".ruff_history";
+
 struct ReplHelper {
     completion_items: Vec<String>,
 }
@@ -37,6 +45,7 @@ impl ReplHelper {
             ":clear".to_string(),
             ":vars".to_string(),
             ":reset".to_string(),
+            ":load".to_string(),
             ".help".to_string(),
         ];
 
@@ -110,6 +119,7 @@ impl Validator for ReplHelper {
 pub struct Repl {
     interpreter: Interpreter,
     editor: Editor<ReplHelper, DefaultHistory>,
+    history_path: Option<PathBuf>,
 }
 
 impl Repl {
@@ -136,11 +146,13 @@ impl Repl {
             "  :clear or :c     Clear the screen",
             "  :vars or :v      Show defined variables",
             "  :reset or :r     Reset environment",
+            "  :load <file>     Run a .ruff file in this session",
             "  .help <function> Show builtin docs",
             "",
             "Navigation:",
             "",
             "  ↑/↓ arrows  Navigate command history",
+            "  Ctrl+R      Search command history",
             "  Ctrl+C      Interrupt current input",
             "  Ctrl+D      Exit REPL",
             "",
@@ -165,7 +177,17 @@ impl Repl {
     pub fn new() -> Result<Self, Box<dyn std::error::Error>> {
         let mut editor = Editor::<ReplHelper, DefaultHistory>::new()?;
         editor.set_helper(Some(ReplHelper::new()));
-        Ok(Repl { interpreter: Interpreter::new(), editor })
+
+        let history_path = history_path_from(
+            std::env::var_os(HISTORY_ENV_VAR),
+            std::env::var_os("HOME").or_else(|| std::env::var_os("USERPROFILE")),
+        );
+        if let Some(path) = &history_path {
+            // A missing history file just means this is the first session.
+            let _ = editor.load_history(path);
+        }
+
+        Ok(Repl { interpreter: Interpreter::new(), editor, history_path })
     }
 
     /// Displays the welcome banner with version and help information
@@ -246,6 +268,17 @@ impl Repl {
             }
         }
 
+        if let Some(path) = &self.history_path {
+            if let Err(err) = self.editor.save_history(path) {
+                eprintln!(
+                    "{} could not save history to {}: {}",
+                    "Warning:".bright_yellow(),
+                    path.display(),
+                    err
+                );
+            }
+        }
+
         Ok(())
     }
 
@@ -267,6 +300,19 @@ impl Repl {
             return true;
         }
 
+        if let Some(argument) = load_command_argument(cmd) {
+            match argument {
+                Some(path) => self.load_file(path),
+                None => println!(
+                    "{} Use {}{}",
+                    "Error:".bright_red(),
+                    ":load".bright_yellow(),
+                    " <file.ruff>".bright_blue()
+                ),
+            }
+            return true;
+        }
+
         match cmd {
             ":help" | ":h" => {
                 self.show_help();
@@ -324,11 +370,17 @@ impl Repl {
         println!("  {}{}  Clear the screen", ":clear".bright_yellow(), " or :c    ".dimmed());
         println!("  {}{}  Show defined variables", ":vars".bright_yellow(), " or :v    ".dimmed());
         println!("  {}{}  Reset environment", ":reset".bright_yellow(), " or :r   ".dimmed());
+        println!(
+            "  {}{}  Run a .ruff file in this session",
+            ":load".bright_yellow(),
+            " <file>    ".dimmed()
+        );
         println!("  {}{}  Show builtin docs", ".help".bright_yellow(), " <function>".dimmed());
         println!();
         println!("{}", "Navigation:".bright_cyan().bold());
         println!();
         println!("  {}  Navigate command history", "↑/↓ arrows".bright_blue());
+        println!("  {}  Search command history", "Ctrl+R    ".bright_blue());
         println!("  {}  Interrupt current input", "Ctrl+C    ".bright_blue());
         println!("  {}  Exit REPL", "Ctrl+D    ".bright_blue());
         println!();
@@ -367,6 +419,21 @@ impl Repl {
         println!();
     }
 
+    /// Runs a source file in the current session, so its definitions stay available
+    fn load_file(&mut self, path: &str) {
+        let source = match fs::read_to_string(path) {
+            Ok(source) => source,
+            Err(err) => {
+                println!("{} Failed to read '{}': {}", "Error:".bright_red(), path, err);
+                return;
+            }
+        };
+
+        self.interpreter.set_source(path.to_string(), &source);
+        self.eval_input(&source);
+        println!("{} {}", "✓ Loaded".bright_green(), path.bright_white());
+    }
+
     /// Displays all currently defined variables in the environment
     fn show_variables(&self) {
         println!();
@@ -467,9 +534,12 @@ impl Repl {
             Value::Int(n) => {
                 println!("{} {}", "=>".bright_blue(), n.to_string().bright_white());
             }
-            Value::Float(n) => {
+            Value::BigInt(n) => {
                 println!("{} {}", "=>".bright_blue(), n.to_string().bright_white());
             }
+            Value::Float(n) => {
+                println!("{} {}", "=>".bright_blue(), Value::format_float(*n).bright_white());
+            }
             Value::Str(s) => {
                 println!("{} {}", "=>".bright_blue(), format!("\"{}\"", s).bright_green());
             }
@@ -546,7 +616,8 @@ impl Repl {
     fn format_value_inline(&self, value: &Value) -> String {
         match value {
             Value::Int(n) => n.to_string(),
-            Value::Float(n) => n.to_string(),
+            Value::BigInt(n) => n.to_string(),
+            Value::Float(n) => Value::format_float(*n),
             Value::Str(s) => format!("\"{}\"", s),
             Value::Bool(b) => b.to_string(),
             Value::Array(_) => "[...]".to_string(),
@@ -569,6 +640,28 @@ impl Default for Repl {
     }
 }
 
+/// Resolves the history file: the override variable wins (empty disables history), otherwise
+/// `~/.ruff_history`.
+fn history_path_from(env_override: Option<OsString>, home: Option<OsString>) -> Option<PathBuf> {
+    match env_override {
+        Some(path) if path.is_empty() => None,
+        Some(path) => Some(PathBuf::from(path)),
+        None => home
+            .filter(|home| !home.is_empty())
+            .map(|home| PathBuf::from(home).join(HISTORY_FILE_NAME)),
+    }
+}
+
+/// For `:load` commands, returns the trimmed file argument (`None` when it is missing).
+fn load_command_argument(cmd: &str) -> Option<Option<&str>> {
+    let rest = cmd.strip_prefix(":load")?;
+    if !rest.is_empty() && !rest.starts_with(char::is_whitespace) {
+        return None;
+    }
+    let path = rest.trim();
+    Some((!path.is_empty()).then_some(path))
+}
+
 fn is_input_complete_text(input: &str) -> bool {
     let trimmed = input.trim();
 
@@ -587,7 +680,8 @@ fn is_input_complete_text(input: &str) -> bool {
     let mut escape_next = false;
     let mut in_comment = false;
 
-    for ch in trimmed.chars() {
+    let mut chars = trimmed.chars().peekable();
+    while let Some(ch) = chars.next() {
         if in_comment {
             if ch == '\n' {
                 in_comment = false;
@@ -604,6 +698,7 @@ fn is_input_complete_text(input: &str) -> bool {
             '\\' if in_string => escape_next = true,
             '"' => in_string = !in_string,
             '#' if !in_string => in_comment = true,
+            '/' if !in_string && chars.peek() == Some(&'/') => in_comment = true,
             '{' if !in_string => brace_count += 1,
             '}' if !in_string => brace_count -= 1,
             '[' if !in_string => bracket_count += 1,
@@ -619,12 +714,17 @@ fn is_input_complete_text(input: &str) -> bool {
 
 #[cfg(test)]
 mod tests {
-    use super::{is_input_complete_text, Repl};
+    use super::{
+        history_path_from, is_input_complete_text, load_command_argument, Repl, HISTORY_FILE_NAME,
+    };
+    use std::ffi::OsString;
+    use std::path::PathBuf;
 
     #[test]
     fn multiline_validator_detects_unclosed_delimiters() {
         assert!(!is_input_complete_text("func test() {\n"));
         assert!(!is_input_complete_text("print((1 + 2)\n"));
+        assert!(!is_input_complete_text("if ok { // open {\n"));
     }
 
     #[test]
@@ -636,6 +736,34 @@ mod tests {
     fn multiline_validator_accepts_complete_input() {
         assert!(is_input_complete_text("let x := 1\n"));
         assert!(is_input_complete_text("print(1)\n"));
+        assert!(is_input_complete_text("print(1) // trailing {\n"));
+    }
+
+    #[test]
+    fn load_command_argument_requires_a_path() {
+        assert_eq!(
+            load_command_argument(":load examples/hello.ruff"),
+            Some(Some("examples/hello.ruff"))
+        );
+        assert_eq!(load_command_argument(":load   spaced.ruff  "), Some(Some("spaced.ruff")));
+        assert_eq!(load_command_argument(":load"), Some(None));
+        assert_eq!(load_command_argument(":loader"), None);
+        assert_eq!(load_command_argument(":vars"), None);
+    }
+
+    #[test]
+    fn history_path_prefers_override_and_falls_back_to_home() {
+        let home = Some(OsString::from("/home/dev"));
+        assert_eq!(
+            history_path_from(None, home.clone()),
+            Some(PathBuf::from("/home/dev").join(HISTORY_FILE_NAME))
+        );
+        assert_eq!(
+            history_path_from(Some(OsString::from("/tmp/h.txt")), home.clone()),
+            Some(PathBuf::from("/tmp/h.txt"))
+        );
+        assert_eq!(history_path_from(Some(OsString::new()), home), None);
+        assert_eq!(history_path_from(None, None), None);
     }
 
     #[test]
@@ -653,6 +781,8 @@ mod tests {
         assert!(text.contains(":help or :h"));
         assert!(text.contains("Multi-line Input:"));
         assert!(text.contains("ruff> let x := 42"));
+        assert!(text.contains(":load <file>"));
+        assert!(text.contains("Ctrl+R"));
         assert!(!text.contains("\u{1b}["), "snapshot text should not include ANSI escapes");
     }
 }
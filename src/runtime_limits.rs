@@ -1,6 +1,12 @@
 // File: src/runtime_limits.rs
 //
-// Centralized default resource limits for parser/runtime/native operations.
+// Centralized default resource limits for parser/runtime/native operations, plus the
+// per-run execution limits (`ruff run --timeout/--max-memory/--max-call-depth`) that
+// both runtimes enforce through a shared `ExecutionBudget`.
+
+use std::sync::atomic::{AtomicU32, Ordering};
+use std::sync::{Arc, OnceLock};
+use std::time::{Duration, Instant};
 
 pub const DEFAULT_MAX_SOURCE_BYTES: usize = 1_048_576;
 pub const DEFAULT_MAX_STRING_LITERAL_LENGTH: usize = 8_192;
@@ -15,3 +21,250 @@ pub const DEFAULT_MAX_VM_CALL_DEPTH: usize = 256;
 
 pub const MAX_FILE_IO_BYTES: usize = 8 * 1024 * 1024;
 pub const MAX_NETWORK_BODY_BYTES: usize = 8 * 1024 * 1024;
+
+/// Limits applied by `ruff run --sandbox` when no explicit value is given.
+pub const SANDBOX_DEFAULT_TIMEOUT: Duration = Duration::from_secs(10);
+pub const SANDBOX_DEFAULT_MAX_MEMORY_BYTES: u64 = 512 * 1024 * 1024;
+
+/// Statements or instructions between deadline checks.
+const CLOCK_CHECK_INTERVAL: u32 = 1 << 10;
+/// Statements or instructions between resident-memory samples.
+const MEMORY_CHECK_INTERVAL: u32 = 1 << 16;
+/// Time the runtime gets past the deadline to report the timeout itself before the
+/// watchdog does.
+const DEADLINE_WATCHDOG_GRACE: Duration = Duration::from_millis(250);
+
+/// Resource limits for one run of an untrusted script; `None` means unlimited.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct ExecutionLimits {
+    /// Wall-clock time from the start of the run.
+    pub timeout: Option<Duration>,
+    /// Resident memory of the whole process.
+    pub max_memory_bytes: Option<u64>,
+    /// Nested function calls, replacing the runtime's default depth.
+    pub max_call_depth: Option<usize>,
+}
+
+impl ExecutionLimits {
+    pub fn is_unlimited(&self) -> bool {
+        *self == Self::default()
+    }
+}
+
+/// Enforces `ExecutionLimits` for a run. Every interpreter and VM that executes part
+/// of the run (modules, spawned tasks, callbacks) shares one budget, so the deadline
+/// is global to the run rather than per runtime.
+///
+/// Once a limit is exceeded the budget stays exhausted: every later `tick` fails with
+/// the same message, and `try`/`except` does not catch it.
+#[derive(Debug)]
+pub struct ExecutionBudget {
+    limits: ExecutionLimits,
+    deadline: Option<Instant>,
+    ticks: AtomicU32,
+    exceeded: OnceLock<String>,
+}
+
+impl ExecutionBudget {
+    /// Starts the clock for a run.
+    pub fn start(limits: ExecutionLimits) -> Arc<Self> {
+        Arc::new(Self {
+            limits,
+            deadline: limits.timeout.map(|timeout| Instant::now() + timeout),
+            ticks: AtomicU32::new(0),
+            exceeded: OnceLock::new(),
+        })
+    }
+
+    pub fn max_call_depth(&self) -> Option<usize> {
+        self.limits.max_call_depth
+    }
+
+    pub fn is_exhausted(&self) -> bool {
+        self.exceeded.get().is_some()
+    }
+
+    /// Accounts for one statement or instruction; fails once a limit is exceeded.
+    ///
+    /// The clock is read every `CLOCK_CHECK_INTERVAL` ticks and memory is sampled
+    /// every `MEMORY_CHECK_INTERVAL`, so a single long native call or allocation can
+    /// overshoot a limit before it is noticed.
+    pub fn tick(&self) -> Result<(), String> {
+        if let Some(message) = self.exceeded.get() {
+            return Err(message.clone());
+        }
+        let tick = self.ticks.fetch_add(1, Ordering::Relaxed);
+        if 0 != tick % CLOCK_CHECK_INTERVAL {
+            return Ok(());
+        }
+
+        if let (Some(deadline), Some(timeout)) = (self.deadline, self.limits.timeout) {
+            if Instant::now() >= deadline {
+                return Err(self.exhaust(format!(
+                    "Execution time limit of {} exceeded",
+                    format_duration(timeout)
+                )));
+            }
+        }
+        if let Some(max_memory) = self.limits.max_memory_bytes {
+            if 0 == tick % MEMORY_CHECK_INTERVAL {
+                if let Some(resident) = resident_memory_bytes().filter(|used| *used > max_memory) {
+                    return Err(self.exhaust(format!(
+                        "Memory limit of {} exceeded ({} resident)",
+                        format_byte_size(max_memory),
+                        format_byte_size(resident)
+                    )));
+                }
+            }
+        }
+        Ok(())
+    }
+
+    /// Runs `on_timeout` with the limit message on a background thread once the deadline
+    /// passes, unless the run has already exhausted the budget. A blocking native call
+    /// (`sleep`, a socket read, a child process) never reaches a `tick`, so without the
+    /// watchdog it could outlive `--timeout`. `on_timeout` is expected to end the process.
+    pub fn watch_deadline(self: &Arc<Self>, on_timeout: impl FnOnce(&str) + Send + 'static) {
+        let (Some(deadline), Some(timeout)) = (self.deadline, self.limits.timeout) else {
+            return;
+        };
+        let budget = Arc::clone(self);
+        std::thread::spawn(move || {
+            std::thread::sleep(
+                deadline.saturating_duration_since(Instant::now()) + DEADLINE_WATCHDOG_GRACE,
+            );
+            if budget.is_exhausted() {
+                return;
+            }
+            let message = budget
+                .exhaust(format!("Execution time limit of {} exceeded", format_duration(timeout)));
+            on_timeout(&message);
+        });
+    }
+
+    fn exhaust(&self, message: String) -> String {
+        self.exceeded.get_or_init(|| message).clone()
+    }
+}
+
+/// Whether `--max-memory` can be enforced on this platform.
+pub fn memory_limit_supported() -> bool {
+    resident_memory_bytes().is_some()
+}
+
+/// Resident set size of this process, from `/proc/self/status`.
+#[cfg(target_os = "linux")]
+fn resident_memory_bytes() -> Option<u64> {
+    let status = std::fs::read_to_string("/proc/self/status").ok()?;
+    let line = status.lines().find(|line| line.starts_with("VmRSS:"))?;
+    let kilobytes: u64 =
+        line.trim_start_matches("VmRSS:").trim().trim_end_matches("kB").trim().parse().ok()?;
+    Some(kilobytes * 1024)
+}
+
+#[cfg(not(target_os = "linux"))]
+fn resident_memory_bytes() -> Option<u64> {
+    None
+}
+
+/// Parses `--timeout` values: `500ms`, `5s`, `2m`, or a bare number of seconds.
+pub fn parse_duration(value: &str) -> Result<Duration, String> {
+    let value = value.trim();
+    let split = value.find(|c: char| !c.is_ascii_digit() && c != '.').unwrap_or(value.len());
+    let (number, unit) = value.split_at(split);
+    let number: f64 = number
+        .parse()
+        .map_err(|_| format!("invalid duration '{}'; expected e.g. 500ms, 5s, or 2m", value))?;
+    let seconds = match unit.trim() {
+        "ms" => number / 1000.0,
+        "" | "s" => number,
+        "m" => number * 60.0,
+        "h" => number * 3600.0,
+        other => return Err(format!("unknown duration unit '{}'; use ms, s, m, or h", other)),
+    };
+    if seconds <= 0.0 || !seconds.is_finite() {
+        return Err(format!("duration '{}' must be greater than zero", value));
+    }
+    Ok(Duration::from_secs_f64(seconds))
+}
+
+/// Parses `--max-memory` values: `512KB`, `256MB`, `1GB`, or a bare number of bytes.
+pub fn parse_byte_size(value: &str) -> Result<u64, String> {
+    let value = value.trim();
+    let split = value.find(|c: char| !c.is_ascii_digit()).unwrap_or(value.len());
+    let (number, unit) = value.split_at(split);
+    let number: u64 = number
+        .parse()
+        .map_err(|_| format!("invalid size '{}'; expected e.g. 256MB or 1GB", value))?;
+    let multiplier: u64 = match unit.trim().to_ascii_uppercase().as_str() {
+        "" | "B" => 1,
+        "K" | "KB" | "KIB" => 1024,
+        "M" | "MB" | "MIB" => 1024 * 1024,
+        "G" | "GB" | "GIB" => 1024 * 1024 * 1024,
+        other => return Err(format!("unknown size unit '{}'; use KB, MB, or GB", other)),
+    };
+    match number.checked_mul(multiplier) {
+        Some(0) => Err(format!("size '{}' must be greater than zero", value)),
+        Some(bytes) => Ok(bytes),
+        None => Err(format!("size '{}' is too large", value)),
+    }
+}
+
+fn format_duration(duration: Duration) -> String {
+    let millis = duration.as_millis();
+    if 0 == millis % 1000 {
+        format!("{}s", millis / 1000)
+    } else {
+        format!("{}ms", millis)
+    }
+}
+
+fn format_byte_size(bytes: u64) -> String {
+    const MIB: u64 = 1024 * 1024;
+    if bytes >= MIB {
+        format!("{} MB", bytes / MIB)
+    } else {
+        format!("{} KB", bytes.div_ceil(1024))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn limit_values_parse_with_units() {
+        assert_eq!(parse_duration("5s"), Ok(Duration::from_secs(5)));
+        assert_eq!(parse_duration("250ms"), Ok(Duration::from_millis(250)));
+        assert_eq!(parse_duration("2m"), Ok(Duration::from_secs(120)));
+        assert_eq!(parse_duration("1.5"), Ok(Duration::from_millis(1500)));
+        assert!(parse_duration("0s").is_err());
+        assert!(parse_duration("5 parsecs").is_err());
+
+        assert_eq!(parse_byte_size("256MB"), Ok(256 * 1024 * 1024));
+        assert_eq!(parse_byte_size("1gb"), Ok(1024 * 1024 * 1024));
+        assert_eq!(parse_byte_size("4096"), Ok(4096));
+        assert!(parse_byte_size("0MB").is_err());
+        assert!(parse_byte_size("lots").is_err());
+    }
+
+    #[test]
+    fn exhausted_budgets_keep_failing_with_the_first_message() {
+        let budget = ExecutionBudget::start(ExecutionLimits {
+            timeout: Some(Duration::from_millis(1)),
+            ..ExecutionLimits::default()
+        });
+        std::thread::sleep(Duration::from_millis(5));
+        let error = budget.tick().expect_err("deadline has passed");
+        assert_eq!(error, "Execution time limit of 1ms exceeded");
+        assert!(budget.is_exhausted());
+        for _ in 0..3 {
+            assert_eq!(budget.tick(), Err(error.clone()));
+        }
+
+        let unlimited = ExecutionBudget::start(ExecutionLimits::default());
+        for _ in 0..(3 * CLOCK_CHECK_INTERVAL) {
+            assert!(unlimited.tick().is_ok());
+        }
+    }
+}
@@ -0,0 +1,548 @@
+// File: src/syntax_tree.rs
+//
+// Syntax-tree export behind `ruff parse --json`.
+//
+// Tools such as linters, code generators, and editor plugins read Ruff programs through this
+// module instead of re-implementing the parser. The AST is described in a JSON schema that is
+// versioned separately from the Rust types (see docs/SYNTAX_TREE_JSON.md): every node is an
+// object whose `kind` names it, and renaming or restructuring an AST variant must keep the
+// exported shape or bump `SCHEMA_VERSION`. Statements carry the source span the parser
+// recorded for them; expressions are positioned by their enclosing statement.
+
+use crate::ast::{
+    ArrayElement, DictElement, Expr, InterpolatedStringPart, MatchCase, MatchLiteral, MatchPattern,
+    Pattern, Stmt, TypeAnnotation,
+};
+use crate::errors::{Diagnostic, SourceSpan};
+use crate::formatter::recorded_statement_spans;
+use crate::lexer;
+use crate::parser::Parser;
+use serde_json::{json, Value};
+use std::collections::HashMap;
+
+/// Version of the exported node schema, raised whenever a node changes shape.
+pub const SCHEMA_VERSION: u32 = 1;
+
+/// Parse `source` and describe the program as a syntax-tree document:
+/// `{"schema": "ruff-ast", "schema_version", "file", "body"}`, where `body` holds the
+/// top-level statements. Lexer and parser diagnostics are returned instead when the
+/// source does not parse; `file` labels both.
+pub fn parse_to_json(source: &str, file: Option<&str>) -> Result<Value, Vec<Diagnostic>> {
+    let tokens = lexer::tokenize_with_file(source, file).map_err(|diagnostics| {
+        diagnostics.iter().map(|diagnostic| diagnostic.to_diagnostic()).collect::<Vec<_>>()
+    })?;
+    let parsed = Parser::new(tokens).parse_with_diagnostics();
+    if !parsed.diagnostics.is_empty() {
+        return Err(parsed
+            .diagnostics
+            .iter()
+            .map(|diagnostic| diagnostic.to_diagnostic(file))
+            .collect());
+    }
+
+    let exporter = Exporter {
+        spans: recorded_statement_spans(&parsed.stmts, &parsed.ast_spans).unwrap_or_default(),
+    };
+    Ok(json!({
+        "schema": "ruff-ast",
+        "schema_version": SCHEMA_VERSION,
+        "file": file,
+        "body": exporter.block(&parsed.stmts),
+    }))
+}
+
+/// A statement node in the export schema, with a `null` span.
+#[allow(dead_code)]
+// Embedding API for tools that already hold an AST; `ruff parse` exports whole files.
+pub fn stmt_to_json(stmt: &Stmt) -> Value {
+    Exporter { spans: HashMap::new() }.stmt(stmt)
+}
+
+/// An expression node in the export schema.
+#[allow(dead_code)]
+// Embedding API for tools that already hold an AST; `ruff parse` exports whole files.
+pub fn expr_to_json(expr: &Expr) -> Value {
+    Exporter { spans: HashMap::new() }.expr(expr)
+}
+
+struct Exporter {
+    spans: HashMap<*const Stmt, SourceSpan>,
+}
+
+impl Exporter {
+    fn block(&self, body: &[Stmt]) -> Value {
+        Value::Array(body.iter().map(|stmt| self.stmt(stmt)).collect())
+    }
+
+    fn optional_block(&self, body: &Option<Vec<Stmt>>) -> Value {
+        body.as_ref().map_or(Value::Null, |body| self.block(body))
+    }
+
+    fn exprs(&self, exprs: &[Expr]) -> Value {
+        Value::Array(exprs.iter().map(|expr| self.expr(expr)).collect())
+    }
+
+    fn optional_expr(&self, expr: Option<&Expr>) -> Value {
+        expr.map_or(Value::Null, |expr| self.expr(expr))
+    }
+
+    fn span(&self, stmt: &Stmt) -> Value {
+        match self.spans.get(&(stmt as *const Stmt)) {
+            Some(span) => json!({
+                "start": { "line": span.start.line, "column": span.start.column },
+                "end": { "line": span.end.line, "column": span.end.column },
+                "start_byte": span.start_byte,
+                "end_byte": span.end_byte,
+            }),
+            None => Value::Null,
+        }
+    }
+
+    fn stmt(&self, stmt: &Stmt) -> Value {
+        let (kind, mut node) = match stmt {
+            Stmt::Let { pattern, value, mutable, type_annotation } => (
+                "Let",
+                json!({
+                    "pattern": pattern_json(pattern),
+                    "value": self.expr(value),
+                    "mutable": mutable,
+                    "type": type_json(type_annotation.as_ref()),
+                }),
+            ),
+            Stmt::Const { name, value, type_annotation } => (
+                "Const",
+                json!({
+                    "name": name,
+                    "value": self.expr(value),
+                    "type": type_json(type_annotation.as_ref()),
+                }),
+            ),
+            Stmt::Assign { target, value } => {
+                ("Assign", json!({ "target": self.expr(target), "value": self.expr(value) }))
+            }
+            Stmt::MultiAssign { targets, values } => (
+                "MultiAssign",
+                json!({ "targets": self.exprs(targets), "values": self.exprs(values) }),
+            ),
+            Stmt::FuncDef {
+                name,
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                is_async,
+                return_type,
+                body,
+                is_generator,
+                decorators,
+            } => (
+                "FuncDef",
+                json!({
+                    "name": name,
+                    "decorators": self.exprs(decorators),
+                    "params": self.params(params, param_types, param_defaults, *is_variadic),
+                    "return_type": type_json(return_type.as_ref()),
+                    "body": self.block(body),
+                    "is_generator": is_generator,
+                    "is_async": is_async,
+                }),
+            ),
+            Stmt::EnumDef { name, variants } => {
+                ("EnumDef", json!({ "name": name, "variants": variants }))
+            }
+            Stmt::Match { value, cases, default } => (
+                "Match",
+                json!({
+                    "value": self.expr(value),
+                    "cases": self.cases(cases),
+                    "default": self.optional_block(default),
+                }),
+            ),
+            Stmt::ExprStmt(expr) => ("ExprStmt", json!({ "expr": self.expr(expr) })),
+            Stmt::Return(value) => {
+                ("Return", json!({ "value": self.optional_expr(value.as_ref()) }))
+            }
+            Stmt::If { condition, then_branch, else_branch } => (
+                "If",
+                json!({
+                    "condition": self.expr(condition),
+                    "then_branch": self.block(then_branch),
+                    "else_branch": self.optional_block(else_branch),
+                }),
+            ),
+            Stmt::Loop { condition, body } => (
+                "Loop",
+                json!({
+                    "condition": self.optional_expr(condition.as_ref()),
+                    "body": self.block(body),
+                }),
+            ),
+            Stmt::For { var, iterable, body } => (
+                "For",
+                json!({ "var": var, "iterable": self.expr(iterable), "body": self.block(body) }),
+            ),
+            Stmt::While { condition, body } => {
+                ("While", json!({ "condition": self.expr(condition), "body": self.block(body) }))
+            }
+            Stmt::LabeledLoop { label, loop_stmt } => {
+                ("LabeledLoop", json!({ "label": label, "loop": self.stmt(loop_stmt) }))
+            }
+            Stmt::Break(label) => ("Break", json!({ "label": label })),
+            Stmt::Continue(label) => ("Continue", json!({ "label": label })),
+            Stmt::TryExcept { try_block, except_var, except_block, finally_block } => (
+                "TryExcept",
+                json!({
+                    "try_block": self.block(try_block),
+                    "except_var": except_var,
+                    "except_block": self.block(except_block),
+                    "finally_block": self.optional_block(finally_block),
+                }),
+            ),
+            Stmt::Block(body) => ("Block", json!({ "body": self.block(body) })),
+            Stmt::Import { module, symbols } => {
+                ("Import", json!({ "module": module, "symbols": symbols }))
+            }
+            Stmt::ImportPath { path, namespace } => {
+                ("ImportPath", json!({ "path": path, "namespace": namespace }))
+            }
+            Stmt::Export { stmt } => ("Export", json!({ "stmt": self.stmt(stmt) })),
+            Stmt::StructDef { name, fields, methods, implements } => (
+                "StructDef",
+                json!({
+                    "name": name,
+                    "fields": fields
+                        .iter()
+                        .map(|(name, annotation)| {
+                            json!({ "name": name, "type": type_json(annotation.as_ref()) })
+                        })
+                        .collect::<Vec<_>>(),
+                    "methods": self.block(methods),
+                    "implements": implements,
+                }),
+            ),
+            Stmt::InterfaceDef { name, methods } => (
+                "InterfaceDef",
+                json!({
+                    "name": name,
+                    "methods": methods
+                        .iter()
+                        .map(|method| {
+                            json!({
+                                "name": method.name,
+                                "params": self.params(
+                                    &method.params,
+                                    &method.param_types,
+                                    &[],
+                                    false,
+                                ),
+                                "return_type": type_json(method.return_type.as_ref()),
+                            })
+                        })
+                        .collect::<Vec<_>>(),
+                }),
+            ),
+            Stmt::Spawn { body } => ("Spawn", json!({ "body": self.block(body) })),
+            Stmt::Test { name, body } => {
+                ("Test", json!({ "name": name, "body": self.block(body) }))
+            }
+            Stmt::TestSetup { body } => ("TestSetup", json!({ "body": self.block(body) })),
+            Stmt::TestTeardown { body } => ("TestTeardown", json!({ "body": self.block(body) })),
+            Stmt::TestGroup { name, tests } => {
+                ("TestGroup", json!({ "name": name, "tests": self.block(tests) }))
+            }
+            Stmt::SourcePos { line, column } => {
+                ("SourcePos", json!({ "line": line, "column": column }))
+            }
+        };
+        node["kind"] = json!(kind);
+        node["span"] = self.span(stmt);
+        node
+    }
+
+    fn expr(&self, expr: &Expr) -> Value {
+        let (kind, mut node) = match expr {
+            Expr::Identifier(name) => ("Identifier", json!({ "name": name })),
+            Expr::Int(value) => ("Int", json!({ "value": value })),
+            Expr::Float(value) => ("Float", json!({ "value": value })),
+            Expr::String(value) => ("String", json!({ "value": value })),
+            Expr::Bool(value) => ("Bool", json!({ "value": value })),
+            Expr::InterpolatedString(parts) => (
+                "InterpolatedString",
+                json!({
+                    "parts": parts
+                        .iter()
+                        .map(|part| match part {
+                            InterpolatedStringPart::Text(text) => {
+                                json!({ "kind": "Text", "value": text })
+                            }
+                            InterpolatedStringPart::Expr(expr) => self.expr(expr),
+                        })
+                        .collect::<Vec<_>>(),
+                }),
+            ),
+            Expr::Function {
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                return_type,
+                body,
+                is_generator,
+                is_async,
+            } => (
+                "Function",
+                json!({
+                    "params": self.params(params, param_types, param_defaults, *is_variadic),
+                    "return_type": type_json(return_type.as_ref()),
+                    "body": self.block(body),
+                    "is_generator": is_generator,
+                    "is_async": is_async,
+                }),
+            ),
+            Expr::UnaryOp { op, operand } => {
+                ("UnaryOp", json!({ "op": op, "operand": self.expr(operand) }))
+            }
+            Expr::BinaryOp { left, op, right } => (
+                "BinaryOp",
+                json!({ "op": op, "left": self.expr(left), "right": self.expr(right) }),
+            ),
+            Expr::Call { function, args } => {
+                ("Call", json!({ "function": self.expr(function), "args": self.exprs(args) }))
+            }
+            Expr::Tag(tag, args) => ("Tag", json!({ "tag": tag, "args": self.exprs(args) })),
+            Expr::StructInstance { name, fields } => (
+                "StructInstance",
+                json!({
+                    "name": name,
+                    "fields": fields
+                        .iter()
+                        .map(|(name, value)| json!({ "name": name, "value": self.expr(value) }))
+                        .collect::<Vec<_>>(),
+                }),
+            ),
+            Expr::FieldAccess { object, field } => {
+                ("FieldAccess", json!({ "object": self.expr(object), "field": field }))
+            }
+            Expr::ArrayLiteral(elements) => (
+                "ArrayLiteral",
+                json!({
+                    "elements": elements
+                        .iter()
+                        .map(|element| match element {
+                            ArrayElement::Single(expr) => self.expr(expr),
+                            ArrayElement::Spread(expr) => self.spread(expr),
+                        })
+                        .collect::<Vec<_>>(),
+                }),
+            ),
+            Expr::DictLiteral(elements) => (
+                "DictLiteral",
+                json!({
+                    "entries": elements
+                        .iter()
+                        .map(|element| match element {
+                            DictElement::Pair(key, value) => json!({
+                                "kind": "Pair",
+                                "key": self.expr(key),
+                                "value": self.expr(value),
+                            }),
+                            DictElement::Spread(expr) => self.spread(expr),
+                        })
+                        .collect::<Vec<_>>(),
+                }),
+            ),
+            Expr::IndexAccess { object, index } => {
+                ("IndexAccess", json!({ "object": self.expr(object), "index": self.expr(index) }))
+            }
+            Expr::Slice { object, start, end } => (
+                "Slice",
+                json!({
+                    "object": self.expr(object),
+                    "start": self.optional_expr(start.as_deref()),
+                    "end": self.optional_expr(end.as_deref()),
+                }),
+            ),
+            Expr::Spread(expr) => return self.spread(expr),
+            Expr::NamedArg { name, value } => {
+                ("NamedArg", json!({ "name": name, "value": self.expr(value) }))
+            }
+            Expr::Ok(value) => ("Ok", json!({ "value": self.expr(value) })),
+            Expr::Err(value) => ("Err", json!({ "value": self.expr(value) })),
+            Expr::Some(value) => ("Some", json!({ "value": self.expr(value) })),
+            Expr::None => ("None", json!({})),
+            Expr::Try(value) => ("Try", json!({ "value": self.expr(value) })),
+            Expr::Ternary { condition, then_expr, else_expr } => (
+                "Ternary",
+                json!({
+                    "condition": self.expr(condition),
+                    "then_expr": self.expr(then_expr),
+                    "else_expr": self.expr(else_expr),
+                }),
+            ),
+            Expr::Yield(value) => {
+                ("Yield", json!({ "value": self.optional_expr(value.as_deref()) }))
+            }
+            Expr::Await(value) => ("Await", json!({ "value": self.expr(value) })),
+            Expr::MethodCall { object, method, args } => (
+                "MethodCall",
+                json!({
+                    "object": self.expr(object),
+                    "method": method,
+                    "args": self.exprs(args),
+                }),
+            ),
+            Expr::Match { value, cases, default } => (
+                "Match",
+                json!({
+                    "value": self.expr(value),
+                    "cases": self.cases(cases),
+                    "default": self.optional_block(default),
+                }),
+            ),
+        };
+        node["kind"] = json!(kind);
+        node
+    }
+
+    /// `...value` in a call, array literal, or dict literal.
+    fn spread(&self, value: &Expr) -> Value {
+        json!({ "kind": "Spread", "value": self.expr(value) })
+    }
+
+    fn params(
+        &self,
+        params: &[String],
+        param_types: &[Option<TypeAnnotation>],
+        param_defaults: &[Option<Expr>],
+        is_variadic: bool,
+    ) -> Value {
+        Value::Array(
+            params
+                .iter()
+                .enumerate()
+                .map(|(index, name)| {
+                    json!({
+                        "name": name,
+                        "type": type_json(param_types.get(index).and_then(Option::as_ref)),
+                        "default": self.optional_expr(
+                            param_defaults.get(index).and_then(Option::as_ref),
+                        ),
+                        "rest": is_variadic && index + 1 == params.len(),
+                    })
+                })
+                .collect(),
+        )
+    }
+
+    fn cases(&self, cases: &[MatchCase]) -> Value {
+        Value::Array(
+            cases
+                .iter()
+                .map(|case| {
+                    json!({
+                        "pattern": match_pattern_json(&case.pattern),
+                        "guard": self.optional_expr(case.guard.as_ref()),
+                        "body": self.block(&case.body),
+                    })
+                })
+                .collect(),
+        )
+    }
+}
+
+/// Type annotations are exported in source syntax, such as `"[int]"` or `"Result<int, string>"`.
+fn type_json(annotation: Option<&TypeAnnotation>) -> Value {
+    annotation.map_or(Value::Null, |annotation| json!(annotation.to_string()))
+}
+
+fn pattern_json(pattern: &Pattern) -> Value {
+    match pattern {
+        Pattern::Identifier(name) => json!({ "kind": "Identifier", "name": name }),
+        Pattern::Array { elements, rest } => json!({
+            "kind": "Array",
+            "elements": elements.iter().map(pattern_json).collect::<Vec<_>>(),
+            "rest": rest,
+        }),
+        Pattern::Dict { keys, rest } => json!({ "kind": "Dict", "keys": keys, "rest": rest }),
+        Pattern::Ignore => json!({ "kind": "Ignore" }),
+    }
+}
+
+fn match_pattern_json(pattern: &MatchPattern) -> Value {
+    match pattern {
+        MatchPattern::Wildcard => json!({ "kind": "Wildcard" }),
+        MatchPattern::Binding(name) => json!({ "kind": "Binding", "name": name }),
+        MatchPattern::Literal(literal) => {
+            let value = match literal {
+                MatchLiteral::Int(value) => json!(value),
+                MatchLiteral::Float(value) => json!(value),
+                MatchLiteral::Str(value) => json!(value),
+                MatchLiteral::Bool(value) => json!(value),
+                MatchLiteral::Null => Value::Null,
+            };
+            json!({ "kind": "Literal", "value": value })
+        }
+        MatchPattern::Tag { tag, payload } => json!({
+            "kind": "Tag",
+            "tag": tag,
+            "payload": payload.as_deref().map_or(Value::Null, match_pattern_json),
+        }),
+        MatchPattern::Array { elements, rest } => json!({
+            "kind": "Array",
+            "elements": elements.iter().map(match_pattern_json).collect::<Vec<_>>(),
+            "rest": rest,
+        }),
+        MatchPattern::Dict { entries, rest } => json!({
+            "kind": "Dict",
+            "entries": entries
+                .iter()
+                .map(|(key, pattern)| json!({ "key": key, "pattern": match_pattern_json(pattern) }))
+                .collect::<Vec<_>>(),
+            "rest": rest,
+        }),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn parse_to_json_exports_statement_kinds_spans_and_nested_expressions() {
+        let source = "func add(a, b = 1) {\n    return a + b\n}\nlet total := add(2)\n";
+        let document = parse_to_json(source, Some("add.ruff")).expect("source should parse");
+
+        assert_eq!(document["schema"], "ruff-ast");
+        assert_eq!(document["schema_version"], SCHEMA_VERSION);
+        assert_eq!(document["file"], "add.ruff");
+
+        let func = &document["body"][0];
+        assert_eq!(func["kind"], "FuncDef");
+        assert_eq!(func["name"], "add");
+        assert_eq!(func["params"][1]["name"], "b");
+        assert_eq!(func["params"][1]["default"], json!({ "kind": "Int", "value": 1 }));
+        assert_eq!(func["span"]["start"]["line"], 1);
+        assert_eq!(func["span"]["end"]["line"], 3);
+
+        let returned = &func["body"][0];
+        assert_eq!(returned["kind"], "Return");
+        assert_eq!(returned["span"]["start"]["line"], 2);
+        assert_eq!(returned["value"]["kind"], "BinaryOp");
+        assert_eq!(returned["value"]["op"], "+");
+
+        let binding = &document["body"][1];
+        assert_eq!(binding["kind"], "Let");
+        assert_eq!(binding["pattern"], json!({ "kind": "Identifier", "name": "total" }));
+        assert_eq!(binding["value"]["kind"], "Call");
+        assert_eq!(binding["value"]["function"]["name"], "add");
+    }
+
+    #[test]
+    fn parse_to_json_returns_diagnostics_for_invalid_source() {
+        let diagnostics =
+            parse_to_json("let := 1\n", Some("bad.ruff")).expect_err("source should not parse");
+
+        assert!(!diagnostics.is_empty());
+        assert_eq!(diagnostics[0].file.as_deref(), Some("bad.ruff"));
+    }
+}
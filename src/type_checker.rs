@@ -13,8 +13,9 @@
 // 1. First pass: Collect function signatures
 // 2. Second pass: Check statements and infer types
 
-use crate::ast::{Expr, Pattern, Stmt, TypeAnnotation};
+use crate::ast::{Expr, MatchCase, Pattern, Stmt, TypeAnnotation};
 use crate::errors::{ErrorKind, RuffError, SourceLocation};
+use crate::interpreter::Interpreter;
 use crate::lexer::tokenize_with_file;
 use crate::parser::Parser;
 use crate::path_security;
@@ -35,10 +36,16 @@ pub struct TypeChecker {
     variables: HashMap<String, Option<TypeAnnotation>>,
     /// Function signatures mapping function names to their types
     functions: HashMap<String, FunctionSignature>,
+    /// Variables whose type comes from an annotation; only these reject reassignment
+    declared: HashSet<String>,
     /// Stack of scopes for nested blocks
-    scope_stack: Vec<HashMap<String, Option<TypeAnnotation>>>,
+    scope_stack: Vec<(HashMap<String, Option<TypeAnnotation>>, HashSet<String>)>,
     /// Current function return type (for checking return statements)
     current_function_return: Option<TypeAnnotation>,
+    /// Position of the statement being checked, taken from `Stmt::SourcePos` markers
+    current_location: SourceLocation,
+    /// Runtime native names, so calls to natives without a signature are not undefined
+    builtin_names: HashSet<&'static str>,
     /// Collect errors instead of failing immediately
     errors: Vec<RuffError>,
     /// Recursion depth counter to prevent infinite loops
@@ -58,8 +65,11 @@ impl TypeChecker {
         let mut checker = TypeChecker {
             variables: HashMap::new(),
             functions: HashMap::new(),
+            declared: HashSet::new(),
             scope_stack: Vec::new(),
             current_function_return: None,
+            current_location: SourceLocation::unknown(),
+            builtin_names: Interpreter::get_builtin_names().into_iter().collect(),
             errors: Vec::new(),
             recursion_depth: 0,
             module_search_paths: vec![PathBuf::from("."), PathBuf::from("./modules")],
@@ -109,7 +119,7 @@ impl TypeChecker {
         self.functions.insert(
             "len".to_string(),
             FunctionSignature {
-                param_types: vec![Some(TypeAnnotation::String)],
+                param_types: vec![None], // strings, arrays, dicts, and bytes
                 return_type: Some(TypeAnnotation::Int),
             },
         );
@@ -205,7 +215,8 @@ impl TypeChecker {
         self.functions.insert(
             "contains".to_string(),
             FunctionSignature {
-                param_types: vec![Some(TypeAnnotation::String), Some(TypeAnnotation::String)],
+                // String or array haystack
+                param_types: vec![Some(TypeAnnotation::Any), Some(TypeAnnotation::Any)],
                 return_type: Some(TypeAnnotation::Bool),
             },
         );
@@ -266,7 +277,8 @@ impl TypeChecker {
         self.functions.insert(
             "index_of".to_string(),
             FunctionSignature {
-                param_types: vec![Some(TypeAnnotation::String), Some(TypeAnnotation::String)],
+                // String or array haystack
+                param_types: vec![Some(TypeAnnotation::Any), Some(TypeAnnotation::Any)],
                 return_type: Some(TypeAnnotation::Int),
             },
         );
@@ -490,8 +502,8 @@ impl TypeChecker {
         self.functions.insert(
             "sort".to_string(),
             FunctionSignature {
-                param_types: vec![None], // Array
-                return_type: None,       // Returns sorted array
+                param_types: vec![None, None], // Array and optional comparator
+                return_type: None,             // Returns sorted array
             },
         );
 
@@ -568,6 +580,14 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "next".to_string(),
+            FunctionSignature {
+                param_types: vec![None], // Generator
+                return_type: None,       // Returns Some(value) or None
+            },
+        );
+
         self.functions.insert(
             "take".to_string(),
             FunctionSignature {
@@ -593,6 +613,14 @@ impl TypeChecker {
         );
 
         // Dict/Map methods
+        self.functions.insert(
+            "ordmap".to_string(),
+            FunctionSignature {
+                param_types: vec![None], // Optional array of [key, value] pairs
+                return_type: None,       // Returns insertion-ordered map
+            },
+        );
+
         self.functions.insert(
             "keys".to_string(),
             FunctionSignature {
@@ -666,6 +694,22 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "get_path".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None, None], // Data, path, default (optional)
+                return_type: None,                   // Returns nested value or default
+            },
+        );
+
+        self.functions.insert(
+            "set_path".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None, None], // Data, path, value
+                return_type: None,                   // Returns updated copy of data
+            },
+        );
+
         // Array generation functions
         self.functions.insert(
             "range".to_string(),
@@ -684,6 +728,14 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "print_f".to_string(),
+            FunctionSignature {
+                param_types: vec![], // Variadic: template + args
+                return_type: None,   // Returns null
+            },
+        );
+
         // JSON functions
         self.functions.insert(
             "parse_json".to_string(),
@@ -777,6 +829,22 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "int".to_string(),
+            FunctionSignature {
+                param_types: vec![None], // Alias of to_int
+                return_type: Some(TypeAnnotation::Int),
+            },
+        );
+
+        self.functions.insert(
+            "float".to_string(),
+            FunctionSignature {
+                param_types: vec![None], // Alias of to_float
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
         self.functions.insert(
             "to_string".to_string(),
             FunctionSignature {
@@ -821,6 +889,14 @@ impl TypeChecker {
             );
         }
 
+        self.functions.insert(
+            "implements".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None], // value, interface
+                return_type: Some(TypeAnnotation::Bool),
+            },
+        );
+
         // Assert & Debug functions
         self.functions.insert(
             "assert".to_string(),
@@ -960,6 +1036,72 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "time_now".to_string(),
+            FunctionSignature { param_types: vec![], return_type: Some(TypeAnnotation::Float) },
+        );
+
+        self.functions.insert(
+            "time_monotonic".to_string(),
+            FunctionSignature { param_types: vec![], return_type: Some(TypeAnnotation::Float) },
+        );
+
+        self.functions.insert(
+            "time_format".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None, None],
+                return_type: Some(TypeAnnotation::String),
+            },
+        );
+
+        self.functions.insert(
+            "time_parse".to_string(),
+            FunctionSignature {
+                param_types: vec![Some(TypeAnnotation::String), None, None],
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
+        self.functions.insert(
+            "time_parts".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None],
+                return_type: Some(TypeAnnotation::Any),
+            },
+        );
+
+        self.functions.insert(
+            "time_add_days".to_string(),
+            FunctionSignature {
+                param_types: vec![None, Some(TypeAnnotation::Int), None],
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
+        self.functions.insert(
+            "time_add_months".to_string(),
+            FunctionSignature {
+                param_types: vec![None, Some(TypeAnnotation::Int), None],
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
+        self.functions.insert(
+            "time_diff".to_string(),
+            FunctionSignature {
+                param_types: vec![None, None, None],
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
+        self.functions.insert(
+            "time_duration".to_string(),
+            FunctionSignature {
+                param_types: vec![Some(TypeAnnotation::String)],
+                return_type: Some(TypeAnnotation::Float),
+            },
+        );
+
         // System operation functions
         self.functions.insert(
             "env".to_string(),
@@ -1023,7 +1165,7 @@ impl TypeChecker {
         self.functions.insert(
             "join_path".to_string(),
             FunctionSignature {
-                param_types: vec![None], // Variadic string arguments
+                param_types: vec![], // Variadic string arguments
                 return_type: Some(TypeAnnotation::String),
             },
         );
@@ -1987,6 +2129,18 @@ impl TypeChecker {
             },
         );
 
+        self.functions.insert(
+            "io_open".to_string(),
+            FunctionSignature {
+                param_types: vec![
+                    Some(TypeAnnotation::String), // path
+                    Some(TypeAnnotation::String), // mode: "r", "w", or "a"
+                    None,                         // overwrite (optional bool, mode "w" only)
+                ],
+                return_type: None, // Returns FileHandle object
+            },
+        );
+
         // Concurrency functions
         self.functions.insert(
             "channel".to_string(),
@@ -1995,6 +2149,45 @@ impl TypeChecker {
                 return_type: None, // Returns Channel object
             },
         );
+        self.functions.insert(
+            "chan".to_string(),
+            FunctionSignature {
+                param_types: vec![],
+                return_type: None, // Returns Channel object
+            },
+        );
+        self.functions.insert(
+            "wait_group".to_string(),
+            FunctionSignature {
+                param_types: vec![],
+                return_type: None, // Returns WaitGroup object
+            },
+        );
+        self.functions.insert(
+            "select".to_string(),
+            FunctionSignature {
+                param_types: vec![None, Some(TypeAnnotation::Int)],
+                return_type: None, // Returns [index, value]
+            },
+        );
+
+        // Async I/O functions (each returns a Promise)
+        let async_signatures: [(&str, Vec<Option<TypeAnnotation>>); 8] = [
+            ("async_sleep", vec![Some(TypeAnnotation::Int)]), // milliseconds
+            ("async_timeout", vec![None, Some(TypeAnnotation::Int)]), // promise, timeout_ms
+            ("async_http_get", vec![Some(TypeAnnotation::String)]), // url
+            ("async_http_post", vec![Some(TypeAnnotation::String), None, None]), // url, body, headers?
+            ("async_read_file", vec![Some(TypeAnnotation::String)]),             // path
+            ("async_read_files", vec![None, None]), // paths, concurrency_limit?
+            ("async_write_file", vec![Some(TypeAnnotation::String), None]), // path, content
+            ("async_write_files", vec![None, None, None]), // paths, contents, concurrency_limit?
+        ];
+        for (name, param_types) in async_signatures {
+            self.functions.insert(
+                name.to_string(),
+                FunctionSignature { param_types, return_type: None }, // Returns Promise
+            );
+        }
 
         self.functions.insert(
             "parallel_http".to_string(),
@@ -2154,17 +2347,30 @@ impl TypeChecker {
         None
     }
 
+    /// Call signature of a user function. Parameters with a default lose their type so
+    /// they count as optional, and a `...rest` function gets the unchecked variadic
+    /// signature (empty `param_types`).
     fn function_signature_from_params(
         params: &[String],
         param_types: &[Option<TypeAnnotation>],
+        param_defaults: &[Option<Expr>],
+        is_variadic: bool,
         return_type: &Option<TypeAnnotation>,
     ) -> FunctionSignature {
+        if is_variadic {
+            return FunctionSignature { param_types: vec![], return_type: return_type.clone() };
+        }
         FunctionSignature {
             param_types: param_types
                 .iter()
                 .cloned()
                 .chain(std::iter::repeat(None))
                 .take(params.len())
+                .enumerate()
+                .map(|(index, param_type)| match param_defaults.get(index) {
+                    Some(Some(_)) => None,
+                    _ => param_type,
+                })
                 .collect(),
             return_type: return_type.clone(),
         }
@@ -2176,9 +2382,20 @@ impl TypeChecker {
     ) -> Option<FunctionSignature> {
         match value {
             Expr::Identifier(name) => known_functions.get(name).cloned(),
-            Expr::Function { params, param_types, return_type, .. } => {
-                Some(Self::function_signature_from_params(params, param_types, return_type))
-            }
+            Expr::Function {
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                return_type,
+                ..
+            } => Some(Self::function_signature_from_params(
+                params,
+                param_types,
+                param_defaults,
+                *is_variadic,
+                return_type,
+            )),
             _ => None,
         }
     }
@@ -2190,10 +2407,24 @@ impl TypeChecker {
         active_modules: &mut Vec<PathBuf>,
     ) {
         match stmt {
-            Stmt::FuncDef { name, params, param_types, return_type, .. } => {
+            Stmt::FuncDef {
+                name,
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                return_type,
+                ..
+            } => {
                 binding_signatures.insert(
                     name.clone(),
-                    Self::function_signature_from_params(params, param_types, return_type),
+                    Self::function_signature_from_params(
+                        params,
+                        param_types,
+                        param_defaults,
+                        *is_variadic,
+                        return_type,
+                    ),
                 );
             }
             Stmt::Import { module, symbols: Some(symbols) } => {
@@ -2302,10 +2533,24 @@ impl TypeChecker {
         active_modules: &mut Vec<PathBuf>,
     ) {
         match stmt {
-            Stmt::FuncDef { name, params, param_types, return_type, .. } => {
+            Stmt::FuncDef {
+                name,
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                return_type,
+                ..
+            } => {
                 export_signatures.insert(
                     name.clone(),
-                    Self::function_signature_from_params(params, param_types, return_type),
+                    Self::function_signature_from_params(
+                        params,
+                        param_types,
+                        param_defaults,
+                        *is_variadic,
+                        return_type,
+                    ),
                 );
             }
             Stmt::Const { name, value, .. } => {
@@ -2367,17 +2612,7 @@ impl TypeChecker {
     /// Returns Ok(()) if type checking succeeds, or Err with collected errors
     pub fn check(&mut self, stmts: &[Stmt]) -> Result<(), Vec<RuffError>> {
         // First pass: collect function signatures
-        for stmt in stmts {
-            if let Stmt::FuncDef { name, param_types, return_type, .. } = stmt {
-                self.functions.insert(
-                    name.clone(),
-                    FunctionSignature {
-                        param_types: param_types.clone(),
-                        return_type: return_type.clone(),
-                    },
-                );
-            }
-        }
+        self.collect_signatures(stmts);
 
         // Second pass: check statements
         for stmt in stmts {
@@ -2391,6 +2626,74 @@ impl TypeChecker {
         }
     }
 
+    /// Registers the functions and struct constructors declared directly in `stmts`,
+    /// so calls that appear before a definition in the same block resolve
+    fn collect_signatures(&mut self, stmts: &[Stmt]) {
+        for stmt in stmts {
+            if let Stmt::FuncDef {
+                name,
+                params,
+                param_types,
+                param_defaults,
+                is_variadic,
+                return_type,
+                ..
+            } = stmt
+            {
+                self.functions.insert(
+                    name.clone(),
+                    Self::function_signature_from_params(
+                        params,
+                        param_types,
+                        param_defaults,
+                        *is_variadic,
+                        return_type,
+                    ),
+                );
+            }
+            // A struct name is callable as a positional constructor over its fields
+            if let Stmt::StructDef { name, fields, .. } = stmt {
+                self.functions.insert(
+                    name.clone(),
+                    FunctionSignature {
+                        param_types: fields
+                            .iter()
+                            .map(|(_, field_type)| field_type.clone())
+                            .collect(),
+                        return_type: None,
+                    },
+                );
+            }
+        }
+    }
+
+    /// Infers the static type of `expr` against the bindings collected by earlier `check` calls.
+    /// Used by editor tooling; any diagnostics raised while inferring are discarded.
+    pub fn infer_expr_type(&mut self, expr: &Expr) -> Option<TypeAnnotation> {
+        let error_count = self.errors.len();
+        let inferred = self.infer_expr(expr);
+        self.errors.truncate(error_count);
+        inferred
+    }
+
+    /// Check `match` arms; names bound by case patterns have unknown types.
+    fn check_match_arms(&mut self, cases: &[MatchCase], default: &Option<Vec<Stmt>>) {
+        for case in cases {
+            for name in case.pattern.binding_names() {
+                self.variables.insert(name, None);
+            }
+            if let Some(guard) = &case.guard {
+                self.infer_expr(guard);
+            }
+            for s in &case.body {
+                self.check_stmt(s);
+            }
+        }
+        for s in default.iter().flatten() {
+            self.check_stmt(s);
+        }
+    }
+
     /// Check a single statement
     fn check_stmt(&mut self, stmt: &Stmt) {
         // Check for excessive recursion depth
@@ -2398,7 +2701,7 @@ impl TypeChecker {
             self.errors.push(RuffError::new(
                 ErrorKind::TypeError,
                 format!("Type checker recursion depth exceeded (max: {}). Possible infinite loop in type checking.", MAX_RECURSION_DEPTH),
-                SourceLocation::unknown(),
+                self.current_location.clone(),
             ));
             return;
         }
@@ -2421,7 +2724,7 @@ impl TypeChecker {
                                         "Type mismatch: variable '{}' declared as {:?} but assigned {:?}",
                                         name, annotated_type, inferred
                                     ),
-                                    SourceLocation::unknown(),
+                                    self.current_location.clone(),
                                 )
                                 .with_help("Try removing the type annotation or converting the value to the correct type".to_string());
 
@@ -2430,9 +2733,11 @@ impl TypeChecker {
                         }
                         // Store the annotated type
                         self.variables.insert(name.clone(), Some(annotated_type.clone()));
+                        self.declared.insert(name.clone());
                     } else {
                         // Store the inferred type
                         self.variables.insert(name.clone(), inferred_type);
+                        self.declared.remove(name);
                     }
                 }
                 // For destructuring patterns, we skip type checking for now
@@ -2452,7 +2757,7 @@ impl TypeChecker {
 									"Type mismatch: constant '{}' declared as {:?} but assigned {:?}",
 									name, annotated_type, inferred
 								),
-                                SourceLocation::unknown(),
+                                self.current_location.clone(),
                             )
                             .with_help("Constants must be initialized with a value matching their declared type".to_string());
 
@@ -2461,9 +2766,11 @@ impl TypeChecker {
                     }
                     // Store the annotated type
                     self.variables.insert(name.clone(), Some(annotated_type.clone()));
+                    self.declared.insert(name.clone());
                 } else {
                     // Store the inferred type
                     self.variables.insert(name.clone(), inferred_type);
+                    self.declared.remove(name);
                 }
             }
 
@@ -2471,10 +2778,13 @@ impl TypeChecker {
                 name: _,
                 params,
                 param_types,
+                param_defaults,
+                is_variadic,
                 return_type,
                 body,
                 is_generator: _,
                 is_async: _,
+                decorators: _,
             } => {
                 // Enter function scope
                 let saved_return_type = self.current_function_return.clone();
@@ -2484,10 +2794,17 @@ impl TypeChecker {
                 // Add parameters to scope
                 for (i, param) in params.iter().enumerate() {
                     let param_type = param_types.get(i).and_then(|t| t.clone());
+                    if param_type.is_some() {
+                        self.declared.insert(param.clone());
+                    } else {
+                        self.declared.remove(param);
+                    }
                     self.variables.insert(param.clone(), param_type);
                 }
+                self.check_param_defaults(params, param_types, param_defaults, *is_variadic);
 
                 // Check function body
+                self.collect_signatures(body);
                 for stmt in body {
                     self.check_stmt(stmt);
                 }
@@ -2495,6 +2812,10 @@ impl TypeChecker {
                 // Exit function scope
                 self.pop_scope();
                 self.current_function_return = saved_return_type;
+
+                if let Some(rebinding) = stmt.decorator_rebinding() {
+                    self.check_stmt(&rebinding);
+                }
             }
 
             Stmt::Return(expr) => {
@@ -2510,7 +2831,7 @@ impl TypeChecker {
                                     "Return type mismatch: expected {:?} but got {:?}",
                                     expected, actual
                                 ),
-                                SourceLocation::unknown(),
+                                self.current_location.clone(),
                             )
                             .with_help("Make sure the return value matches the function's declared return type".to_string())
                             .with_note(format!("Function expects to return {:?}", expected));
@@ -2546,11 +2867,15 @@ impl TypeChecker {
                 }
             }
 
-            Stmt::Break => {
+            Stmt::LabeledLoop { loop_stmt, .. } => {
+                self.check_stmt(loop_stmt);
+            }
+
+            Stmt::Break(_) => {
                 // No type checking needed for break
             }
 
-            Stmt::Continue => {
+            Stmt::Continue(_) => {
                 // No type checking needed for continue
             }
 
@@ -2591,25 +2916,19 @@ impl TypeChecker {
 
             Stmt::Match { value, cases, default } => {
                 self.infer_expr(value);
-                for (_, case_body) in cases {
-                    for s in case_body {
-                        self.check_stmt(s);
-                    }
-                }
-                if let Some(default_body) = default {
-                    for s in default_body {
-                        self.check_stmt(s);
-                    }
-                }
+                self.check_match_arms(cases, default);
             }
 
-            Stmt::TryExcept { try_block, except_var: _, except_block } => {
+            Stmt::TryExcept { try_block, except_var: _, except_block, finally_block } => {
                 for s in try_block {
                     self.check_stmt(s);
                 }
                 for s in except_block {
                     self.check_stmt(s);
                 }
+                for s in finally_block.iter().flatten() {
+                    self.check_stmt(s);
+                }
             }
 
             Stmt::ExprStmt(expr) => {
@@ -2622,8 +2941,10 @@ impl TypeChecker {
                 // Check based on assignment target
                 match target {
                     Expr::Identifier(name) => {
-                        // Check if variable exists and types are compatible
-                        if let Some(Some(expected)) = self.variables.get(name) {
+                        // Annotated variables keep their declared type; others rebind freely
+                        if !self.declared.contains(name) {
+                            self.variables.insert(name.clone(), inferred_type);
+                        } else if let Some(Some(expected)) = self.variables.get(name) {
                             if let Some(actual) = &inferred_type {
                                 if !expected.matches(actual) {
                                     let error = RuffError::new(
@@ -2632,7 +2953,7 @@ impl TypeChecker {
                                             "Type mismatch: cannot assign {:?} to variable '{}' of type {:?}",
                                             actual, name, expected
                                         ),
-                                        SourceLocation::unknown(),
+                                        self.current_location.clone(),
                                     )
                                     .with_help("Try converting the value with to_int(), to_float(), to_string(), or to_bool()".to_string())
                                     .with_note(format!("Variable '{}' was declared with type {:?}", name, expected));
@@ -2652,14 +2973,39 @@ impl TypeChecker {
                 }
             }
 
+            Stmt::MultiAssign { targets, values } if values.len() == 1 && targets.len() > 1 => {
+                // Unpacked targets take the array's element type
+                let element_type = match self.infer_expr(&values[0]) {
+                    Some(TypeAnnotation::Array(element)) => Some(*element),
+                    _ => Some(TypeAnnotation::Any),
+                };
+                for target in targets {
+                    if let Expr::Identifier(name) = target {
+                        if !self.declared.contains(name) {
+                            self.variables.insert(name.clone(), element_type.clone());
+                        }
+                    }
+                }
+            }
+
+            Stmt::MultiAssign { targets, values } => {
+                for (target, value) in targets.iter().zip(values.iter()) {
+                    self.check_stmt(&Stmt::Assign { target: target.clone(), value: value.clone() });
+                }
+            }
+
             Stmt::Block(stmts) => {
                 for s in stmts {
                     self.check_stmt(s);
                 }
             }
 
-            Stmt::EnumDef { .. } => {
-                // Enums don't require type checking
+            Stmt::SourcePos { line, column } => {
+                self.current_location = SourceLocation::new(*line, *column);
+            }
+
+            Stmt::EnumDef { .. } | Stmt::InterfaceDef { .. } => {
+                // Enums and interfaces don't require type checking
             }
 
             Stmt::Import { module, symbols } => {
@@ -2683,12 +3029,16 @@ impl TypeChecker {
                 }
             }
 
+            Stmt::ImportPath { namespace, .. } => {
+                self.variables.insert(namespace.clone(), Some(TypeAnnotation::Any));
+            }
+
             Stmt::Export { stmt } => {
                 // Type check the exported statement
                 self.check_stmt(stmt);
             }
 
-            Stmt::StructDef { name: _, fields: _, methods } => {
+            Stmt::StructDef { methods, .. } => {
                 // Type check methods
                 for method in methods {
                     self.check_stmt(method);
@@ -2706,7 +3056,7 @@ impl TypeChecker {
             self.errors.push(RuffError::new(
                 ErrorKind::TypeError,
                 format!("Type checker recursion depth exceeded (max: {}). Possible infinite loop in type inference.", MAX_RECURSION_DEPTH),
-                SourceLocation::unknown(),
+                self.current_location.clone(),
             ));
             return None;
         }
@@ -2734,10 +3084,13 @@ impl TypeChecker {
             Expr::Bool(_) => Some(TypeAnnotation::Bool),
 
             Expr::Identifier(name) => {
-                // Look up variable type in symbol table
-                self.variables.get(name).cloned().flatten().or_else(|| {
-                    self.functions.get(name).map(Self::function_signature_to_type_annotation)
-                })
+                // A variable shadows a function of the same name, even when its type is unknown
+                match self.variables.get(name) {
+                    Some(binding) => binding.clone(),
+                    None => {
+                        self.functions.get(name).map(Self::function_signature_to_type_annotation)
+                    }
+                }
             }
 
             Expr::UnaryOp { op, operand } => {
@@ -2779,7 +3132,7 @@ impl TypeChecker {
                                         "Comparison '{}' between incompatible types: {:?} and {:?}",
                                         op, l, r
                                     ),
-                                    SourceLocation::unknown(),
+                                    self.current_location.clone(),
                                 )
                                 .with_help("Convert one value to match the type of the other".to_string())
                                 .with_note("Comparison operators require both operands to have compatible types".to_string());
@@ -2808,15 +3161,19 @@ impl TypeChecker {
                             {
                                 Some(TypeAnnotation::String)
                             }
-                            // Incompatible types
-                            (Some(l), Some(r)) if l != r => {
+                            // Incompatible types; `Any` is gradual and never conflicts
+                            (Some(l), Some(r))
+                                if l != r
+                                    && *l != TypeAnnotation::Any
+                                    && *r != TypeAnnotation::Any =>
+                            {
                                 self.errors.push(RuffError::new(
                                     ErrorKind::TypeError,
                                     format!(
 										"Binary operation '{}' with incompatible types: {:?} and {:?}",
 										op, l, r
 									),
-                                    SourceLocation::unknown(),
+                                    self.current_location.clone(),
                                 ));
                                 None
                             }
@@ -2833,7 +3190,17 @@ impl TypeChecker {
                     // Clone the signature to avoid borrow conflicts
                     let sig = self.functions.get(func_name).cloned();
 
-                    if let Some(sig) = sig {
+                    // Spread and named arguments do not line up with parameter positions
+                    let positional_only = !args
+                        .iter()
+                        .any(|arg| matches!(arg, Expr::Spread(_) | Expr::NamedArg { .. }));
+
+                    if let Some(sig) = sig.as_ref().filter(|_| !positional_only) {
+                        for arg in args {
+                            self.infer_expr(arg);
+                        }
+                        return sig.return_type.clone();
+                    } else if let Some(sig) = sig {
                         // Skip type checking for variadic functions (empty param_types means variadic)
                         let is_variadic = sig.param_types.is_empty();
 
@@ -2853,7 +3220,7 @@ impl TypeChecker {
                                         max_allowed,
                                         args.len()
                                     ),
-                                    SourceLocation::unknown(),
+                                    self.current_location.clone(),
                                 ));
                             }
                         }
@@ -2871,7 +3238,7 @@ impl TypeChecker {
 												"Function '{}' parameter {} expects {:?} but got {:?}",
 												func_name, i + 1, expected, actual
 											),
-                                            SourceLocation::unknown(),
+                                            self.current_location.clone(),
                                         ));
                                     }
                                 }
@@ -2880,6 +3247,13 @@ impl TypeChecker {
 
                         // Return the function's return type
                         return sig.return_type.clone();
+                    } else if self.variables.contains_key(func_name)
+                        || self.builtin_names.contains(func_name.as_str())
+                    {
+                        // Callable values and natives without a registered signature go unchecked
+                        for arg in args {
+                            self.infer_expr(arg);
+                        }
                     } else {
                         // Function not found - suggest similar functions
                         let available_functions = self.get_available_functions();
@@ -2889,7 +3263,7 @@ impl TypeChecker {
                         let mut error = RuffError::new(
                             ErrorKind::UndefinedFunction,
                             format!("Undefined function '{}'", func_name),
-                            SourceLocation::unknown(),
+                            self.current_location.clone(),
                         );
 
                         if let Some(suggested) = suggestion {
@@ -2941,7 +3315,7 @@ impl TypeChecker {
                                             "Array spread expects Array value, got {:?}",
                                             other
                                         ),
-                                        SourceLocation::unknown(),
+                                        self.current_location.clone(),
                                     ));
                                     Some(TypeAnnotation::Any)
                                 }
@@ -2991,7 +3365,7 @@ impl TypeChecker {
                                     self.errors.push(RuffError::new(
                                         ErrorKind::TypeError,
                                         format!("Dict spread expects Dict value, got {:?}", other),
-                                        SourceLocation::unknown(),
+                                        self.current_location.clone(),
                                     ));
                                     inferred_key_type = Self::merge_inferred_types(
                                         inferred_key_type,
@@ -3040,7 +3414,7 @@ impl TypeChecker {
                             self.errors.push(RuffError::new(
                                 ErrorKind::TypeError,
                                 "String index access expects integer index".to_string(),
-                                SourceLocation::unknown(),
+                                self.current_location.clone(),
                             ));
                             Some(TypeAnnotation::Any)
                         }
@@ -3049,9 +3423,36 @@ impl TypeChecker {
                 }
             }
 
+            Expr::Slice { object, start, end } => {
+                let object_type = self.infer_expr(object);
+                for bound in [start, end].into_iter().flatten() {
+                    let bound_type = self.infer_expr(bound);
+                    if matches!(
+                        bound_type,
+                        Some(TypeAnnotation::String)
+                            | Some(TypeAnnotation::Bool)
+                            | Some(TypeAnnotation::Array(_))
+                            | Some(TypeAnnotation::Dict { .. })
+                    ) {
+                        self.errors.push(RuffError::new(
+                            ErrorKind::TypeError,
+                            "Slice bounds expect integers".to_string(),
+                            self.current_location.clone(),
+                        ));
+                    }
+                }
+
+                match object_type {
+                    Some(TypeAnnotation::Array(_)) | Some(TypeAnnotation::String) => object_type,
+                    Some(_) | None => Some(TypeAnnotation::Any),
+                }
+            }
+
             Expr::Function {
-                params: _,
+                params,
                 param_types,
+                param_defaults,
+                is_variadic,
                 return_type,
                 body,
                 is_generator: _,
@@ -3059,26 +3460,34 @@ impl TypeChecker {
             } => {
                 // Type check function expression (anonymous function)
                 // Enter function scope
+                let saved_return_type = self.current_function_return.clone();
+                self.current_function_return = return_type.clone();
                 self.push_scope();
 
                 // Add parameters to scope
-                for t in param_types.iter().flatten() {
-                    // We would need the param name here, but it's not available in this context
-                    // For now, just validate the function body
-                    let _ = t;
+                for (i, param) in params.iter().enumerate() {
+                    let param_type = param_types.get(i).and_then(|t| t.clone());
+                    if param_type.is_some() {
+                        self.declared.insert(param.clone());
+                    } else {
+                        self.declared.remove(param);
+                    }
+                    self.variables.insert(param.clone(), param_type);
                 }
+                self.check_param_defaults(params, param_types, param_defaults, *is_variadic);
 
                 // Check function body
+                self.collect_signatures(body);
                 for stmt in body {
                     self.check_stmt(stmt);
                 }
 
                 // Exit function scope
                 self.pop_scope();
+                self.current_function_return = saved_return_type;
 
                 // Return function type annotation if available
                 // For now, just return None since we don't have full function types yet
-                let _ = return_type;
                 None
             }
 
@@ -3115,18 +3524,39 @@ impl TypeChecker {
                 // Try operator unwraps Result<T, E> to T
                 match expr_type {
                     Some(TypeAnnotation::Result { ok_type, .. }) => Some(*ok_type),
+                    Some(TypeAnnotation::Option { inner_type }) => Some(*inner_type),
+                    // Unannotated results are unknown, not wrong
+                    None | Some(TypeAnnotation::Any) => None,
                     _ => {
                         // Type error: try operator on non-Result value
                         self.errors.push(RuffError::new(
                             ErrorKind::TypeError,
                             "Try operator (?) can only be used on Result values".to_string(),
-                            SourceLocation::unknown(),
+                            self.current_location.clone(),
                         ));
                         None
                     }
                 }
             }
 
+            Expr::Match { value, cases, default } => {
+                self.infer_expr(value);
+                self.check_match_arms(cases, default);
+                None
+            }
+
+            Expr::Ternary { condition, then_expr, else_expr } => {
+                self.infer_expr(condition);
+                let then_type = self.infer_expr(then_expr);
+                let else_type = self.infer_expr(else_expr);
+                // Branches of different types widen to Any
+                if then_type == else_type {
+                    then_type
+                } else {
+                    Some(TypeAnnotation::Any)
+                }
+            }
+
             Expr::Yield(value_expr) => {
                 // Yield expressions can return any type
                 if let Some(expr) = value_expr {
@@ -3152,6 +3582,8 @@ impl TypeChecker {
                 None
             }
 
+            Expr::NamedArg { value, .. } => self.infer_expr(value),
+
             Expr::Await(promise_expr) => {
                 // Type check the promise expression
                 self.infer_expr(promise_expr);
@@ -3165,15 +3597,53 @@ impl TypeChecker {
         result
     }
 
+    /// Checks literal defaults against their parameter annotations and types a
+    /// `...rest` parameter as an array, once the parameters are in scope.
+    fn check_param_defaults(
+        &mut self,
+        params: &[String],
+        param_types: &[Option<TypeAnnotation>],
+        param_defaults: &[Option<Expr>],
+        is_variadic: bool,
+    ) {
+        for (i, default) in param_defaults.iter().enumerate() {
+            let Some(default) = default else { continue };
+            let actual = self.infer_expr(default);
+            if let (Some(Some(expected)), Some(actual)) = (param_types.get(i), &actual) {
+                if !expected.matches(actual) {
+                    self.errors.push(RuffError::new(
+                        ErrorKind::TypeError,
+                        format!(
+                            "Default value of parameter '{}' expects {:?} but got {:?}",
+                            params[i], expected, actual
+                        ),
+                        self.current_location.clone(),
+                    ));
+                }
+            }
+        }
+
+        if is_variadic {
+            if let Some(rest) = params.last() {
+                self.declared.remove(rest);
+                self.variables.insert(
+                    rest.clone(),
+                    Some(TypeAnnotation::Array(Box::new(TypeAnnotation::Any))),
+                );
+            }
+        }
+    }
+
     /// Push a new scope onto the scope stack
     fn push_scope(&mut self) {
-        self.scope_stack.push(self.variables.clone());
+        self.scope_stack.push((self.variables.clone(), self.declared.clone()));
     }
 
     /// Pop a scope from the scope stack
     fn pop_scope(&mut self) {
-        if let Some(prev_scope) = self.scope_stack.pop() {
-            self.variables = prev_scope;
+        if let Some((prev_variables, prev_declared)) = self.scope_stack.pop() {
+            self.variables = prev_variables;
+            self.declared = prev_declared;
         }
     }
 
@@ -3215,9 +3685,13 @@ impl TypeChecker {
                 "split" => Some(TypeAnnotation::Array(Box::new(TypeAnnotation::String))),
                 _ => None,
             },
-            Some(TypeAnnotation::Array(_)) => match method {
-                "len" => Some(TypeAnnotation::Int),
+            array @ Some(TypeAnnotation::Array(_)) => match method {
+                "len" | "index_of" => Some(TypeAnnotation::Int),
                 "is_empty" | "contains" => Some(TypeAnnotation::Bool),
+                "join" => Some(TypeAnnotation::String),
+                "push" | "insert" | "remove" | "slice" | "sort" | "reverse" | "unique" => {
+                    array.cloned()
+                }
                 _ => None,
             },
             Some(TypeAnnotation::Dict { .. }) => match method {
@@ -3631,4 +4105,35 @@ mod tests {
         std::fs::remove_file(&module_path).expect("failed to remove temp module");
         std::fs::remove_dir_all(&temp_root).expect("failed to clean up temp module dir");
     }
+
+    fn check_source(source: &str) -> Result<(), Vec<RuffError>> {
+        let tokens = tokenize_with_file(source, None).expect("source should tokenize");
+        let stmts = Parser::new(tokens).with_source_positions().parse();
+        TypeChecker::new().check(&stmts)
+    }
+
+    #[test]
+    fn test_errors_carry_statement_source_positions() {
+        let errors = check_source("x := 1\nfunc f(a: int) -> int {\n    return \"no\"\n}\n")
+            .expect_err("return mismatch should be reported");
+        assert_eq!(errors.len(), 1);
+        assert_eq!((errors[0].location.line, errors[0].location.column), (3, 5));
+    }
+
+    #[test]
+    fn test_unannotated_variables_rebind_but_annotated_ones_do_not() {
+        assert!(check_source("value := true\nvalue := 2.5\n").is_ok());
+
+        let errors = check_source("mut count: int := 0\ncount := \"many\"\n")
+            .expect_err("annotated variable should keep its type");
+        assert!(errors[0].message.contains("cannot assign String to variable 'count'"));
+    }
+
+    #[test]
+    fn test_callable_variables_and_unregistered_natives_are_not_undefined() {
+        let source = "func make_adder(n) {\n    return func(x) { return x + n }\n}\n\
+                      add5 := make_adder(5)\nprint(add5(1))\nlog := \"text\"\nprint(len(log))\n";
+        assert!(check_source(source).is_ok());
+        assert!(check_source("missing_helper(1)\n").is_err());
+    }
 }
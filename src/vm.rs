@@ -4,11 +4,14 @@
 // Stack-based VM with support for function calls, closures, and all Ruff features.
 
 use crate::ast::Pattern;
+use crate::benchmarks::SamplingProfiler;
 use crate::bytecode::{BytecodeBindingKind, BytecodeChunk, Constant, OpCode};
+use crate::errors::StackFrame;
 use crate::http_request_utils;
 use crate::interpreter::{
-    BindingKind, CallableArity, DenseIntDict, DenseIntDictInt, DictMap, Environment, IntDictMap,
-    Interpreter, NativeCapability, RuntimeCapabilityPolicy, Value,
+    arrange_call_args, BindingKind, CallArgs, CallableArity, DecoratorHost, DenseIntDict,
+    DenseIntDictInt, DictMap, Environment, IntDictMap, Interpreter, NativeCapability,
+    PromiseCallback, RuntimeCapabilityPolicy, SequenceHost, Value,
 };
 use crate::jit::{
     invoke_compiled_fn, invoke_compiled_fn_with_arg, CompiledFn, CompiledFnInfo, JitCompiler,
@@ -25,6 +28,10 @@ use std::sync::{Arc, Mutex};
 /// A function will be JIT-compiled after being called this many times
 const JIT_FUNCTION_THRESHOLD: usize = 100;
 const DENSE_INT_DICT_MIN_CAPACITY: usize = 131072;
+/// Returned frames whose storage is kept for reuse; deeper recursion allocates as before.
+const FRAME_POOL_LIMIT: usize = 64;
+/// Frames whose locals grew past this many entries free their storage instead of pooling it.
+const FRAME_POOL_MAX_LOCALS: usize = 64;
 
 /// Stable identifier for a suspendable VM execution context.
 pub type VmContextId = u64;
@@ -74,8 +81,8 @@ pub struct VM {
     /// Current instruction pointer
     ip: usize,
 
-    /// Current bytecode chunk
-    chunk: BytecodeChunk,
+    /// Current bytecode chunk, shared with the function values and frames that hold it
+    chunk: Arc<BytecodeChunk>,
 
     /// Interpreter instance for calling native functions
     interpreter: Interpreter,
@@ -124,12 +131,26 @@ pub struct VM {
     /// Value: Cached function pointer and metadata for fast dispatch
     inline_cache: HashMap<CallSiteId, InlineCacheEntry>,
 
+    /// Inline cache for globals read by `LoadVar` and for struct methods resolved
+    /// by `FieldGet`, keyed by the reading instruction
+    global_cache: HashMap<CallSiteId, GlobalCacheEntry>,
+
+    /// `CallSiteId` hash of the current chunk's name, refreshed whenever the chunk
+    /// changes so call sites don't rehash it
+    chunk_id: u64,
+
+    /// Sampling profiler fed by the dispatch loop (`ruff run --profile`)
+    profiler: Option<Box<SamplingProfiler>>,
+
     /// Cache of integer keys converted to strings for dict operations
     int_key_cache: HashMap<i64, Arc<str>>,
 
     /// Object stack for JIT non-int values (strings, dicts)
     jit_obj_stack: Vec<Value>,
 
+    /// Storage recycled from returned call frames
+    frame_pool: Vec<FrameStorage>,
+
     /// Tokio runtime handle for spawning async tasks
     /// This allows the VM to spawn truly concurrent async tasks
     runtime_handle: tokio::runtime::Handle,
@@ -148,6 +169,12 @@ pub struct VM {
 
     /// Skip reset branch on the next execute() call (used for resume paths).
     skip_execute_reset_once: bool,
+
+    /// Number of generator bodies currently being resumed; `Yield` is only valid inside one.
+    generator_depth: usize,
+
+    /// Value handed out by the `Yield` that suspended the innermost resumed generator.
+    generator_yield: Option<Value>,
 }
 
 /// Unique identifier for a call site (location in bytecode where a Call occurs)
@@ -171,6 +198,13 @@ static HASHMAP_SET_DENSE: AtomicU64 = AtomicU64::new(0);
 static HASHMAP_SET_DENSE_INT: AtomicU64 = AtomicU64::new(0);
 static HASHMAP_SET_DICT_INTKEY: AtomicU64 = AtomicU64::new(0);
 
+static DEBUG_VM_ENABLED: OnceLock<bool> = OnceLock::new();
+
+/// `DEBUG_VM` tracing, read once rather than on every variable access.
+fn debug_vm_enabled() -> bool {
+    *DEBUG_VM_ENABLED.get_or_init(|| std::env::var("DEBUG_VM").is_ok())
+}
+
 fn hashmap_profile_enabled() -> bool {
     *HASHMAP_PROFILE_ENABLED.get_or_init(|| std::env::var("RUFF_HASHMAP_PROFILE").is_ok())
 }
@@ -248,23 +282,38 @@ impl Drop for HashMapProfileGuard {
 }
 
 impl CallSiteId {
-    fn new(chunk_name: Option<&str>, ip: usize) -> Self {
+    fn new(chunk_id: u64, ip: usize) -> Self {
+        Self { chunk_id, ip }
+    }
+
+    /// Stable id for a chunk, computed once per chunk switch
+    fn chunk_id(chunk_name: Option<&str>) -> u64 {
         use std::collections::hash_map::DefaultHasher;
         use std::hash::{Hash, Hasher};
 
-        let chunk_id = match chunk_name {
+        match chunk_name {
             Some(name) => {
                 let mut hasher = DefaultHasher::new();
                 name.hash(&mut hasher);
                 hasher.finish()
             }
             None => 0, // Anonymous/top-level chunk
-        };
-
-        Self { chunk_id, ip }
+        }
     }
 }
 
+/// A global (or `Struct.method`) resolved at one instruction. Chunks can share a
+/// name, so the entry keeps the name it resolved to guard against collisions.
+struct GlobalCacheEntry {
+    /// Variable name, or the struct name for a method lookup
+    owner: String,
+    /// Method name for `FieldGet` lookups
+    member: Option<String>,
+    /// `Environment::globals_version` the value was read at
+    version: u64,
+    value: Value,
+}
+
 /// Cached information for a call site to enable fast function dispatch
 #[derive(Clone)]
 struct InlineCacheEntry {
@@ -325,7 +374,7 @@ pub struct VmExecutionSnapshot {
     ip: usize,
     stack: Vec<Value>,
     call_frames: Vec<CallFrame>,
-    chunk: BytecodeChunk,
+    chunk: Arc<BytecodeChunk>,
     upvalues: Vec<Upvalue>,
     exception_handlers: Vec<ExceptionHandlerFrame>,
     function_call_stack: Vec<String>,
@@ -337,10 +386,7 @@ pub struct VmExecutionSnapshot {
     globals: Arc<Mutex<Environment>>,
 }
 
-/// Generator state for suspended execution
-/// Infrastructure for generator resume functionality
-// Deferred post-v1 runtime backlog: full generator-state restoration (see docs/V1_SCOPE.md deferred runtime execution section)
-#[allow(dead_code)] // Generator resume metadata is kept for parity with the future restoration path.
+/// Execution state of a bytecode generator, parked between resumptions.
 #[derive(Debug, Clone)]
 pub struct GeneratorState {
     /// Instruction pointer where generator yielded
@@ -349,37 +395,34 @@ pub struct GeneratorState {
     /// Stack snapshot at yield point
     pub stack: Vec<Value>,
 
-    /// Call frame stack at yield point (stored as separate values to avoid circular dependency)
-    pub call_frames_data: Vec<CallFrameData>,
-
-    /// Bytecode chunk being executed
-    pub chunk: BytecodeChunk,
-
-    /// Local variables at yield point
-    pub locals: HashMap<String, Value>,
+    /// Call frames at the yield point; the first one is the generator body itself.
+    pub(crate) call_frames: Vec<CallFrame>,
 
-    /// Captured variables at yield point
-    pub captured: HashMap<String, Arc<Mutex<Value>>>,
+    /// Try blocks open inside the generator at the yield point.
+    exception_handlers: Vec<ExceptionHandlerFrame>,
 
-    /// Binding mutability metadata for captured variables.
-    pub captured_binding_kinds: HashMap<String, BytecodeBindingKind>,
+    /// Bytecode chunk being executed
+    pub chunk: Arc<BytecodeChunk>,
 
     /// Whether the generator has finished
     pub is_exhausted: bool,
+
+    /// Whether the generator body is executing right now.
+    pub is_running: bool,
+
+    /// A value already pulled from the generator that the next resumption hands out first.
+    pub peeked: Option<Value>,
 }
 
-/// Serializable call frame data for generator state
-#[derive(Debug, Clone)]
-pub struct CallFrameData {
-    pub return_ip: usize,
-    pub stack_offset: usize,
-    pub locals: HashMap<String, Value>,
-    pub locals_binding_kinds: HashMap<String, BytecodeBindingKind>,
-    pub local_slots: Vec<Value>,
-    pub local_slot_binding_kinds: Vec<BytecodeBindingKind>,
-    pub local_slot_initialized: Vec<bool>,
-    pub captured: HashMap<String, Arc<Mutex<Value>>>,
-    pub captured_binding_kinds: HashMap<String, BytecodeBindingKind>,
+/// The per-call collections of a frame, emptied and kept across calls so a hot call
+/// path reuses their allocations instead of building new ones every time.
+#[derive(Default)]
+struct FrameStorage {
+    locals: HashMap<String, Value>,
+    locals_binding_kinds: HashMap<String, BytecodeBindingKind>,
+    local_slots: Vec<Value>,
+    local_slot_binding_kinds: Vec<BytecodeBindingKind>,
+    local_slot_initialized: Vec<bool>,
 }
 
 /// Call frame for function calls
@@ -412,8 +455,12 @@ pub(crate) struct CallFrame {
     /// Binding mutability metadata for captured variables.
     captured_binding_kinds: HashMap<String, BytecodeBindingKind>,
 
+    /// Cells handed to closures for captured locals this frame has not declared yet.
+    /// `DefineLocal` fills the cell in and moves it to `captured`.
+    pending_captured: HashMap<String, Arc<Mutex<Value>>>,
+
     /// Previous chunk (for returning)
-    prev_chunk: Option<BytecodeChunk>,
+    prev_chunk: Option<Arc<BytecodeChunk>>,
 
     /// Whether this function is async (for wrapping return values in Promises)
     is_async: bool,
@@ -468,40 +515,58 @@ impl VM {
         )
     }
 
-    fn throw_runtime_value(&mut self, error_value: Value) -> Result<(), String> {
-        let throw_stack = self.function_call_stack.clone();
+    /// Push a settled promise value for `await`, rethrowing it if the async body failed.
+    fn push_awaited_value(&mut self, value: Value) -> Result<(), String> {
+        if matches!(value, Value::Error(_) | Value::ErrorObject { .. }) {
+            self.throw_runtime_value(value)
+        } else {
+            self.stack.push(value);
+            Ok(())
+        }
+    }
 
-        let normalized_error = match error_value {
-            Value::ErrorObject { message, mut stack, line, cause } => {
-                if stack.is_empty() && !throw_stack.is_empty() {
-                    stack = throw_stack;
-                }
-                Value::ErrorObject { message, stack, line, cause }
-            }
-            Value::Str(message) => Value::ErrorObject {
-                message: message.as_ref().clone(),
-                stack: throw_stack,
-                line: None,
-                cause: None,
-            },
-            Value::Error(message) => {
-                Value::ErrorObject { message, stack: throw_stack, line: None, cause: None }
+    fn throw_runtime_value(&mut self, error_value: Value) -> Result<(), String> {
+        let mut normalized_error = error_value.into_thrown_error(self.function_call_stack.clone());
+        if let Value::ErrorObject { line, .. } = &mut normalized_error {
+            if line.is_none() {
+                *line = self.error_source_position().map(|(line, _)| line);
             }
-            Value::Struct { name, fields } => {
-                let message = match fields.get("message") {
-                    Some(Value::Str(msg)) => msg.as_ref().clone(),
-                    _ => format!("{name} error"),
-                };
-                let cause = fields.get("cause").cloned().map(Box::new);
-                Value::ErrorObject { message, stack: throw_stack, line: None, cause }
-            }
-            other => Value::ErrorObject {
-                message: format!("{:?}", other),
-                stack: throw_stack,
-                line: None,
-                cause: None,
-            },
-        };
+        }
+
+        // An error escaping an async function body rejects that call's promise instead.
+        let handler_frame_offset =
+            self.exception_handlers.last().map(|handler| handler.frame_offset).unwrap_or(0);
+        if let Some(async_frame_index) = self
+            .call_frames
+            .iter()
+            .rposition(|frame| frame.is_async)
+            .filter(|index| *index >= handler_frame_offset)
+        {
+            let mut async_frame = None;
+            while self.call_frames.len() > async_frame_index {
+                async_frame = self.call_frames.pop();
+                self.function_call_stack.pop();
+                if self.recursion_depth > 0 {
+                    self.recursion_depth -= 1;
+                }
+            }
+            let frame = async_frame.expect("async frame index is within call_frames");
+            self.ip = frame.return_ip;
+            if let Some(prev_chunk) = frame.prev_chunk {
+                self.set_chunk(prev_chunk);
+            }
+            self.stack.truncate(frame.stack_offset);
+
+            let (tx, rx) = tokio::sync::oneshot::channel();
+            tx.send(Ok(normalized_error)).map_err(|_| "Failed to send to promise channel")?;
+            self.stack.push(Value::Promise {
+                receiver: Arc::new(Mutex::new(rx)),
+                is_polled: Arc::new(Mutex::new(false)),
+                cached_result: Arc::new(Mutex::new(None)),
+                task_handle: None,
+            });
+            return Ok(());
+        }
 
         if let Some(handler) = self.exception_handlers.pop() {
             while self.call_frames.len() > handler.frame_offset {
@@ -539,7 +604,7 @@ impl VM {
             call_frames: Vec::new(),
             globals: Arc::new(Mutex::new(Environment::new())),
             ip: 0,
-            chunk: BytecodeChunk::new(),
+            chunk: Arc::new(BytecodeChunk::new()),
             interpreter: Interpreter::new(),
             upvalues: Vec::new(),
             exception_handlers: Vec::new(),
@@ -557,8 +622,12 @@ impl VM {
             recursion_depth: 0,
             max_recursion_depth: 0,
             inline_cache: HashMap::new(),
+            global_cache: HashMap::new(),
+            chunk_id: 0,
+            profiler: None,
             int_key_cache: HashMap::new(),
             jit_obj_stack: Vec::new(),
+            frame_pool: Vec::new(),
             runtime_handle: tokio::runtime::Handle::try_current().unwrap_or_else(|_| {
                 // If not in a tokio runtime, create one
                 crate::interpreter::AsyncRuntime::runtime().handle().clone()
@@ -568,6 +637,8 @@ impl VM {
             next_execution_context_id: 1,
             cooperative_suspend_enabled: true,
             skip_execute_reset_once: false,
+            generator_depth: 0,
+            generator_yield: None,
         };
 
         vm
@@ -577,8 +648,122 @@ impl VM {
         self.interpreter.set_capability_policy(capability_policy);
     }
 
-    fn set_chunk(&mut self, chunk: BytecodeChunk) {
-        self.chunk = chunk;
+    /// Enforces `budget` on this VM, the natives and modules it runs, and detached calls.
+    pub fn set_execution_budget(&mut self, budget: Option<Arc<runtime_limits::ExecutionBudget>>) {
+        self.interpreter.set_execution_budget(budget);
+    }
+
+    /// Samples the Ruff call stack while this VM runs; see `take_profiler`.
+    pub fn enable_profiling(
+        &mut self,
+        cpu: bool,
+        allocations: bool,
+        interval: std::time::Duration,
+    ) {
+        self.profiler = Some(Box::new(SamplingProfiler::start(cpu, allocations, interval)));
+    }
+
+    /// Stops profiling and hands back what was collected.
+    pub fn take_profiler(&mut self) -> Option<SamplingProfiler> {
+        let mut profiler = self.profiler.take()?;
+        profiler.finish();
+        Some(*profiler)
+    }
+
+    /// Routes `print` output into `output` instead of stdout.
+    pub fn set_output(&mut self, output: Arc<Mutex<Vec<u8>>>) {
+        self.interpreter.set_output(output);
+    }
+
+    /// Storage for a frame of `chunk`, taken from the pool when one is available.
+    fn acquire_frame_storage(&mut self, chunk: &BytecodeChunk) -> FrameStorage {
+        let mut storage = self.frame_pool.pop().unwrap_or_default();
+        storage.local_slots.resize(chunk.local_count, Value::Null);
+        storage.local_slot_binding_kinds.extend_from_slice(&chunk.local_binding_kinds);
+        if storage.local_slot_binding_kinds.len() < chunk.local_count {
+            storage
+                .local_slot_binding_kinds
+                .resize(chunk.local_count, BytecodeBindingKind::Mutable);
+        }
+        storage.local_slot_initialized.resize(chunk.local_count, false);
+        storage
+    }
+
+    /// Return a finished frame's collections to the pool. Their values are dropped now,
+    /// exactly as they would be if the frame itself were dropped.
+    fn release_frame_storage(&mut self, frame: CallFrame) {
+        if self.frame_pool.len() >= FRAME_POOL_LIMIT || frame.locals.len() > FRAME_POOL_MAX_LOCALS {
+            return;
+        }
+        let mut storage = FrameStorage {
+            locals: frame.locals,
+            locals_binding_kinds: frame.locals_binding_kinds,
+            local_slots: frame.local_slots,
+            local_slot_binding_kinds: frame.local_slot_binding_kinds,
+            local_slot_initialized: frame.local_slot_initialized,
+        };
+        storage.locals.clear();
+        storage.locals_binding_kinds.clear();
+        storage.local_slots.clear();
+        storage.local_slot_binding_kinds.clear();
+        storage.local_slot_initialized.clear();
+        self.frame_pool.push(storage);
+    }
+
+    fn set_chunk(&mut self, chunk: Arc<BytecodeChunk>) {
+        self.replace_chunk(chunk);
+    }
+
+    /// Switch to `chunk` and return the one it replaces.
+    fn replace_chunk(&mut self, chunk: Arc<BytecodeChunk>) -> Arc<BytecodeChunk> {
+        self.chunk_id = CallSiteId::chunk_id(chunk.name.as_deref());
+        std::mem::replace(&mut self.chunk, chunk)
+    }
+
+    /// Read global `owner`, or the struct method `owner.member`, through the inline
+    /// cache for the current instruction. A write to any global invalidates the
+    /// entry; while nested scopes are pushed the environment is read directly.
+    fn cached_global(&mut self, owner: &str, member: Option<&str>) -> Option<Value> {
+        let site = CallSiteId::new(self.chunk_id, self.ip);
+        let globals = self.globals.lock().unwrap();
+        let lookup = |globals: &Environment| match member {
+            Some(member) => globals.get(&format!("{}.{}", owner, member)),
+            None => globals.get(owner),
+        };
+        let Some(version) = globals.globals_version() else {
+            return lookup(&globals);
+        };
+
+        let entry = self.global_cache.get_mut(&site);
+        let same_name = entry
+            .as_ref()
+            .is_some_and(|entry| entry.owner == owner && entry.member.as_deref() == member);
+        if let Some(entry) = entry.filter(|_| same_name) {
+            if entry.version != version {
+                entry.value = lookup(&globals)?;
+                entry.version = version;
+            }
+            return Some(entry.value.clone());
+        }
+
+        let value = lookup(&globals)?;
+        drop(globals);
+        self.global_cache.insert(
+            site,
+            GlobalCacheEntry {
+                owner: owner.to_string(),
+                member: member.map(str::to_string),
+                version,
+                value: value.clone(),
+            },
+        );
+        Some(value)
+    }
+
+    /// Invalidate cached globals after a JIT call that may have stored into them through
+    /// `globals_ptr`, which bypasses `Environment`'s version counter.
+    fn mark_jit_globals_changed(&self) {
+        self.globals.lock().unwrap().mark_globals_changed();
     }
 
     /// Capture a full execution snapshot for later restoration.
@@ -612,7 +797,7 @@ impl VM {
         self.ip = snapshot.ip;
         self.stack = snapshot.stack;
         self.call_frames = snapshot.call_frames;
-        self.chunk = snapshot.chunk;
+        self.set_chunk(snapshot.chunk);
         self.upvalues = snapshot.upvalues;
         self.exception_handlers = snapshot.exception_handlers;
         self.function_call_stack = snapshot.function_call_stack;
@@ -622,6 +807,7 @@ impl VM {
         self.int_key_cache = snapshot.int_key_cache;
         self.jit_obj_stack = snapshot.jit_obj_stack;
         self.globals = snapshot.globals;
+        self.global_cache.clear();
         self.interpreter.set_env(Arc::clone(&self.globals));
     }
 
@@ -719,6 +905,7 @@ impl VM {
     /// Set the global environment (for accessing built-in functions)
     pub fn set_globals(&mut self, env: Arc<Mutex<Environment>>) {
         self.globals = env.clone();
+        self.global_cache.clear();
         // Also set the interpreter's environment so it can resolve native functions
         self.interpreter.set_env(env);
     }
@@ -728,6 +915,60 @@ impl VM {
         self.interpreter.module_loader.add_search_path(path);
     }
 
+    /// Registers a locked package dependency for VM import helpers.
+    pub fn add_module_dependency_root<P: AsRef<Path>>(&mut self, name: &str, root: P) {
+        self.interpreter.module_loader.add_dependency_root(name, root);
+    }
+
+    /// Records the entry script path so `import "path"` resolves relative to it.
+    pub fn set_source_file(&mut self, file: impl Into<String>) {
+        self.interpreter.source_file = Some(file.into());
+    }
+
+    /// Source position of the statement whose instruction raised the last error, when
+    /// the program was parsed `with_source_positions`.
+    pub fn error_source_position(&self) -> Option<(usize, usize)> {
+        self.chunk.source_position_at(self.ip.saturating_sub(1))
+    }
+
+    /// Call stack (outermost first) at the instruction that raised the last error.
+    ///
+    /// Each caller's position comes from the call instruction just before the return
+    /// address saved in its callee's frame. Empty when the error was raised outside any
+    /// function call.
+    pub fn error_stack_trace(&self) -> Vec<StackFrame> {
+        if self.call_frames.is_empty() {
+            return Vec::new();
+        }
+        self.current_stack_trace()
+    }
+
+    /// Ruff frames currently executing, `<main>` first, each positioned at the
+    /// statement it is running or calling from.
+    fn current_stack_trace(&self) -> Vec<StackFrame> {
+        // Frames restored for a generator resume have no entry in `function_call_stack`,
+        // so names are matched from the innermost frame outwards.
+        let unnamed_frames = self.call_frames.len().saturating_sub(self.function_call_stack.len());
+        let named_from = self.function_call_stack.len() + unnamed_frames - self.call_frames.len();
+
+        let mut trace = Vec::with_capacity(self.call_frames.len() + 1);
+        let mut caller = "<main>".to_string();
+        for (index, frame) in self.call_frames.iter().enumerate() {
+            let call_site = frame
+                .prev_chunk
+                .as_ref()
+                .and_then(|chunk| chunk.source_position_at(frame.return_ip.saturating_sub(1)));
+            trace.push(StackFrame::new(caller, call_site));
+            caller = index
+                .checked_sub(unnamed_frames)
+                .and_then(|named_index| self.function_call_stack.get(named_from + named_index))
+                .cloned()
+                .unwrap_or_else(|| "<anonymous>".to_string());
+        }
+        trace.push(StackFrame::new(caller, self.error_source_position()));
+        trace
+    }
+
     /// Enable or disable JIT compilation
     pub fn set_jit_enabled(&mut self, enabled: bool) {
         self.jit_enabled = enabled;
@@ -862,6 +1103,11 @@ impl VM {
                         stack.push((nested, depth + 1));
                     }
                 }
+                Value::OrderedDict(map) => {
+                    for nested in map.values() {
+                        stack.push((nested, depth + 1));
+                    }
+                }
                 Value::DenseIntDict(values) => {
                     for nested in values.iter() {
                         stack.push((nested, depth + 1));
@@ -892,6 +1138,7 @@ impl VM {
                 }
                 Value::HttpResponse { .. }
                 | Value::Int(_)
+                | Value::BigInt(_)
                 | Value::Float(_)
                 | Value::Str(_)
                 | Value::Bool(_)
@@ -912,16 +1159,30 @@ impl VM {
                 | Value::DatabasePool { .. }
                 | Value::Image { .. }
                 | Value::ZipArchive { .. }
+                | Value::FileHandle { .. }
                 | Value::TcpListener { .. }
                 | Value::TcpStream { .. }
                 | Value::UdpSocket { .. }
                 | Value::Channel(_)
+                | Value::WaitGroup(_)
+                | Value::Mutex(_)
+                | Value::Atomic(_)
+                | Value::SyncMap(_)
+                | Value::Decorated(_)
+                | Value::Router(_)
+                | Value::StringBuilder(_)
+                | Value::NativeLibrary(_)
+                | Value::Process(_)
+                | Value::Socket(_)
+                | Value::Sequence(_)
                 | Value::GeneratorDef(_, _)
                 | Value::Generator { .. }
                 | Value::Iterator { .. }
                 | Value::Promise { .. }
                 | Value::TaskHandle { .. }
-                | Value::StructDef { .. } => {}
+                | Value::StructDef { .. }
+                | Value::Interface { .. }
+                | Value::Module { .. } => {}
             }
         }
 
@@ -952,7 +1213,31 @@ impl VM {
         Ok(true)
     }
 
+    /// Replaces the top `count` values with their concatenated display forms, honoring
+    /// struct `to_string` methods like `to_string()` does.
+    fn build_string(&mut self, count: usize) -> Result<(), String> {
+        let start = self.stack.len().checked_sub(count).ok_or("Stack underflow")?;
+        let parts = self.stack.split_off(start);
+        let mut text = String::new();
+        for part in parts {
+            let rendered = match self.try_call_vm_to_string_method(&part) {
+                Some(rendered) => rendered?,
+                None => part,
+            };
+            match rendered {
+                Value::Str(part) => text.push_str(&part),
+                other => text.push_str(&Interpreter::stringify_value(&other)),
+            }
+        }
+        self.stack.push(Value::Str(Arc::new(text)));
+        Ok(())
+    }
+
     fn get_indexed_value(object: &Value, index: &Value) -> Result<Value, String> {
+        if let Some(key) = Value::map_key_index(object, index) {
+            return Self::get_indexed_value(object, &key?);
+        }
+
         match (object, index) {
             (Value::Array(arr), Value::Int(i)) => {
                 let idx = if *i < 0 { (arr.len() as i64 + i) as usize } else { *i as usize };
@@ -969,6 +1254,10 @@ impl VM {
             (Value::Dict(dict), Value::Str(key)) => {
                 dict.get(key.as_str()).cloned().ok_or_else(|| Self::missing_map_key_error(index))
             }
+            (Value::OrderedDict(map), Value::Str(_) | Value::Int(_)) => Value::dict_key(index)
+                .ok()
+                .and_then(|key| map.get(&key).cloned())
+                .ok_or_else(|| Self::missing_map_key_error(index)),
             (Value::FixedDict { keys, values }, Value::Str(key)) => {
                 let idx = keys.iter().position(|k| k.as_ref() == key.as_str());
                 idx.and_then(|i| values.get(i).cloned())
@@ -1052,7 +1341,7 @@ impl VM {
                 Ok(_) => Err(Self::missing_map_key_error(index)),
                 Err(_) => Err("Invalid index operation".to_string()),
             },
-            _ => Err("Invalid index operation".to_string()),
+            _ => Err(Value::invalid_index_error(index)),
         }
     }
 
@@ -1306,7 +1595,7 @@ impl VM {
         if self.skip_execute_reset_once {
             self.skip_execute_reset_once = false;
         } else {
-            self.set_chunk(chunk);
+            self.set_chunk(Arc::new(chunk));
             self.ip = 0;
             self.stack.clear();
         }
@@ -1366,7 +1655,10 @@ impl VM {
                         let stack_ptr: *mut Vec<Value> = &mut self.stack;
 
                         let mut globals_guard = self.globals.lock().unwrap();
-                        let globals_ptr: *mut HashMap<String, Value> = &mut globals_guard.scopes[0];
+                        // JIT stores write the globals through the pointer below; the lock is
+                        // held for the whole run, so invalidating cached globals up front suffices
+                        globals_guard.mark_globals_changed();
+                        let globals_ptr: *mut HashMap<String, Value> = &mut globals_guard.globals;
 
                         // For top-level scripts, globals = locals
                         let locals_ptr: *mut HashMap<String, Value> = globals_ptr;
@@ -1445,6 +1737,11 @@ impl VM {
         }
 
         // Interpreter fallback or JIT disabled
+        self.run_catching()
+    }
+
+    /// Run the current chunk, routing catchable runtime errors to the innermost try block.
+    fn run_catching(&mut self) -> Result<Value, String> {
         let contains_map_fusion_op = self.chunk.instructions.iter().any(|instruction| {
             matches!(
                 instruction,
@@ -1453,6 +1750,28 @@ impl VM {
             )
         });
 
+        loop {
+            match self.run_instructions(contains_map_fusion_op) {
+                // Runtime errors from instructions and natives are catchable like `throw`,
+                // except an exceeded execution limit
+                Err(message)
+                    if (!self.exception_handlers.is_empty()
+                        || self.call_frames.iter().any(|frame| frame.is_async))
+                        && Self::parse_suspend_error(&message).is_none()
+                        && !self
+                            .interpreter
+                            .execution_budget()
+                            .is_some_and(|budget| budget.is_exhausted()) =>
+                {
+                    self.throw_runtime_value(Value::Error(message))?;
+                }
+                outcome => return outcome,
+            }
+        }
+    }
+
+    /// Dispatch instructions until the program finishes or an instruction fails.
+    fn run_instructions(&mut self, contains_map_fusion_op: bool) -> Result<Value, String> {
         loop {
             if self.ip >= self.chunk.instructions.len() {
                 // Reached end of program
@@ -1661,8 +1980,10 @@ impl VM {
 
                                         // Get globals - lock and get mutable reference to the first scope
                                         let mut globals_guard = self.globals.lock().unwrap();
+                                        // At top level the loop stores into globals directly
+                                        globals_guard.mark_globals_changed();
                                         let globals_ptr: *mut HashMap<String, Value> =
-                                            &mut globals_guard.scopes[0];
+                                            &mut globals_guard.globals;
 
                                         // Get locals from current call frame, or use globals if at top level
                                         let locals_ptr: *mut HashMap<String, Value> =
@@ -1774,9 +2095,17 @@ impl VM {
                 }
             }
 
+            if let Some(budget) = self.interpreter.execution_budget() {
+                budget.tick()?;
+            }
+
             let instruction = self.chunk.instructions[self.ip].clone();
             self.ip += 1;
 
+            if self.profiler.is_some() {
+                self.record_profile_events(&instruction);
+            }
+
             match instruction {
                 OpCode::LoadConst(index) => {
                     let constant = &self.chunk.constants[index];
@@ -1797,14 +2126,14 @@ impl VM {
                 OpCode::LoadVar(name) => {
                     // Look in current call frame first - check captured variables (Arc<Mutex<Value>>) first, then locals
                     let value = if let Some(frame) = self.call_frames.last() {
-                        if std::env::var("DEBUG_VM").is_ok() {
+                        if debug_vm_enabled() {
                             eprintln!("LoadVar('{}'):  checking frame captured ({} entries) and locals ({} entries)", 
                                 name, frame.captured.len(), frame.locals.len());
                         }
 
                         // Check captured variables first (these are shared mutable references)
                         if let Some(captured_ref) = frame.captured.get(&name) {
-                            if std::env::var("DEBUG_VM").is_ok() {
+                            if debug_vm_enabled() {
                                 eprintln!("LoadVar('{}'): found in captured", name);
                             }
                             Some(captured_ref.lock().unwrap().clone())
@@ -1813,16 +2142,17 @@ impl VM {
                             frame.locals.get(&name).cloned()
                         }
                     } else {
-                        if std::env::var("DEBUG_VM").is_ok() {
+                        if debug_vm_enabled() {
                             eprintln!("LoadVar('{}'): no call frame", name);
                         }
                         None
                     };
 
-                    let value = value
-                        .or_else(|| {
-                            let global_val = self.globals.lock().unwrap().get(&name);
-                            if std::env::var("DEBUG_VM").is_ok() {
+                    let value = match value {
+                        Some(value) => Some(value),
+                        None => {
+                            let global_val = self.cached_global(&name, None);
+                            if debug_vm_enabled() {
                                 eprintln!(
                                     "LoadVar('{}'): checking globals -> {:?}",
                                     name,
@@ -1830,28 +2160,29 @@ impl VM {
                                 );
                             }
                             global_val
-                        })
-                        .ok_or_else(|| {
-                            if std::env::var("DEBUG_VM").is_ok() {
-                                eprintln!(
-                                    "LoadVar('{}'): FAILED - not in captured, locals or globals",
-                                    name
-                                );
-                                eprintln!(
-                                    "  Current frame captured: {:?}",
-                                    self.call_frames
-                                        .last()
-                                        .map(|f| f.captured.keys().collect::<Vec<_>>())
-                                );
-                                eprintln!(
-                                    "  Current frame locals: {:?}",
-                                    self.call_frames
-                                        .last()
-                                        .map(|f| f.locals.keys().collect::<Vec<_>>())
-                                );
-                            }
-                            Self::undefined_variable_message(&name)
-                        })?;
+                        }
+                    };
+                    let value = value.ok_or_else(|| {
+                        if debug_vm_enabled() {
+                            eprintln!(
+                                "LoadVar('{}'): FAILED - not in captured, locals or globals",
+                                name
+                            );
+                            eprintln!(
+                                "  Current frame captured: {:?}",
+                                self.call_frames
+                                    .last()
+                                    .map(|f| f.captured.keys().collect::<Vec<_>>())
+                            );
+                            eprintln!(
+                                "  Current frame locals: {:?}",
+                                self.call_frames
+                                    .last()
+                                    .map(|f| f.locals.keys().collect::<Vec<_>>())
+                            );
+                        }
+                        Self::undefined_variable_message(&name)
+                    })?;
 
                     self.stack.push(value);
                 }
@@ -1879,7 +2210,7 @@ impl VM {
                                     return Err(Self::local_reassignment_error(kind, &name));
                                 }
                             }
-                            if std::env::var("DEBUG_VM").is_ok() {
+                            if debug_vm_enabled() {
                                 eprintln!("StoreVar('{}'): updating captured variable", name);
                             }
                             *captured_ref.lock().unwrap() = value.clone();
@@ -1889,19 +2220,19 @@ impl VM {
                                     return Err(Self::local_reassignment_error(kind, &name));
                                 }
                             }
-                            if std::env::var("DEBUG_VM").is_ok() {
+                            if debug_vm_enabled() {
                                 eprintln!("StoreVar('{}'): updating frame local", name);
                             }
                             frame.locals.insert(name.clone(), value.clone());
                         } else if global_exists {
-                            if std::env::var("DEBUG_VM").is_ok() {
+                            if debug_vm_enabled() {
                                 eprintln!("StoreVar('{}'): updating global binding", name);
                             }
                             assign_global = true;
                         } else {
                             // Assignment to an unresolved name inside a frame defines
                             // a new mutable local, mirroring interpreter assign_checked.
-                            if std::env::var("DEBUG_VM").is_ok() {
+                            if debug_vm_enabled() {
                                 eprintln!("StoreVar('{}'): storing in frame locals", name);
                             }
                             frame
@@ -1912,7 +2243,7 @@ impl VM {
                         }
                     } else {
                         assign_global = true;
-                        if std::env::var("DEBUG_VM").is_ok() {
+                        if debug_vm_enabled() {
                             eprintln!("StoreVar('{}'): storing in globals (no frame)", name);
                         }
                     }
@@ -1967,6 +2298,34 @@ impl VM {
                     )?;
                 }
 
+                OpCode::DefineLocal(name, kind) => {
+                    let value = self.stack.last().ok_or("Stack underflow")?.clone();
+                    if let Some(frame) = self.call_frames.last_mut() {
+                        // Closures created before the declaration already hold its cell
+                        if let Some(cell) = frame.pending_captured.remove(&name) {
+                            *cell.lock().unwrap() = value;
+                            frame.locals.remove(&name);
+                            frame.locals_binding_kinds.remove(&name);
+                            frame.captured.insert(name.clone(), cell);
+                            frame.captured_binding_kinds.insert(name, kind);
+                        } else {
+                            // A fresh declaration starts a new binding: closures created for
+                            // an earlier declaration (e.g. a previous loop iteration) keep
+                            // their cell.
+                            frame.captured.remove(&name);
+                            frame.captured_binding_kinds.remove(&name);
+                            frame.locals.insert(name.clone(), value);
+                            frame.locals_binding_kinds.insert(name, kind);
+                        }
+                    } else {
+                        self.globals.lock().unwrap().define_with_kind_checked(
+                            name,
+                            value,
+                            Self::env_binding_kind(kind),
+                        )?;
+                    }
+                }
+
                 OpCode::EnsureMutableGlobalForMutation(name) => {
                     self.globals.lock().unwrap().ensure_mutable_for_mutation(name.as_str())?;
                 }
@@ -2013,13 +2372,14 @@ impl VM {
                     self.stack.push(result);
                 }
 
+                OpCode::BuildString(count) => self.build_string(count)?,
+
                 OpCode::AddInPlace(slot) => {
                     let rhs = self.stack.pop().ok_or("Stack underflow")?;
                     let apply_add = |target: &mut Value| -> Result<(), String> {
                         match (target, &rhs) {
-                            (Value::Int(left), Value::Int(right)) => {
-                                *left = Value::checked_int_arithmetic(*left, "+", *right)?;
-                                Ok(())
+                            (target @ Value::Int(_), Value::Int(right)) => {
+                                Value::add_int_in_place(target, *right)
                             }
                             (Value::Float(left), Value::Float(right)) => {
                                 *left += *right;
@@ -2397,7 +2757,7 @@ impl VM {
                     };
 
                     let mut running_sum = match frame.local_slots.get(sum_slot) {
-                        Some(Value::Int(value)) => *value,
+                        Some(value @ (Value::Int(_) | Value::BigInt(_))) => value.clone(),
                         Some(_) => {
                             return Err(
                                 "Type mismatch in SumIntMapUntilLocalInPlace sum".to_string()
@@ -2427,8 +2787,7 @@ impl VM {
                                 }
 
                                 for value in values[start..end].iter() {
-                                    running_sum =
-                                        Value::checked_int_arithmetic(running_sum, "+", *value)?;
+                                    Value::add_int_in_place(&mut running_sum, *value)?;
                                 }
                             }
                             Value::DenseIntDictInt(values) => {
@@ -2439,11 +2798,7 @@ impl VM {
                                 for value in values[start..end].iter() {
                                     match value {
                                         Some(int_value) => {
-                                            running_sum = Value::checked_int_arithmetic(
-                                                running_sum,
-                                                "+",
-                                                *int_value,
-                                            )?;
+                                            Value::add_int_in_place(&mut running_sum, *int_value)?;
                                         }
                                         None => {
                                             return Err(
@@ -2461,11 +2816,7 @@ impl VM {
                                 for value in values[start..end].iter() {
                                     match value {
                                         Value::Int(int_value) => {
-                                            running_sum = Value::checked_int_arithmetic(
-                                                running_sum,
-                                                "+",
-                                                *int_value,
-                                            )?;
+                                            Value::add_int_in_place(&mut running_sum, *int_value)?;
                                         }
                                         _ => {
                                             return Err(
@@ -2479,11 +2830,7 @@ impl VM {
                                 for key in current_index..limit_index {
                                     match dict.get(&key) {
                                         Some(Value::Int(int_value)) => {
-                                            running_sum = Value::checked_int_arithmetic(
-                                                running_sum,
-                                                "+",
-                                                *int_value,
-                                            )?;
+                                            Value::add_int_in_place(&mut running_sum, *int_value)?;
                                         }
                                         _ => {
                                             return Err(
@@ -2498,11 +2845,7 @@ impl VM {
                                     let key_string = key.to_string();
                                     match dict.get(key_string.as_str()) {
                                         Some(Value::Int(int_value)) => {
-                                            running_sum = Value::checked_int_arithmetic(
-                                                running_sum,
-                                                "+",
-                                                *int_value,
-                                            )?;
+                                            Value::add_int_in_place(&mut running_sum, *int_value)?;
                                         }
                                         _ => {
                                             return Err(
@@ -2521,11 +2864,7 @@ impl VM {
 
                                     match match_index.and_then(|idx| values.get(idx)) {
                                         Some(Value::Int(int_value)) => {
-                                            running_sum = Value::checked_int_arithmetic(
-                                                running_sum,
-                                                "+",
-                                                *int_value,
-                                            )?;
+                                            Value::add_int_in_place(&mut running_sum, *int_value)?;
                                         }
                                         _ => {
                                             return Err(
@@ -2544,7 +2883,7 @@ impl VM {
                     }
 
                     if let Some(sum_value) = frame.local_slots.get_mut(sum_slot) {
-                        *sum_value = Value::Int(running_sum);
+                        *sum_value = running_sum;
                     } else {
                         return Err(format!("Invalid local slot: {}", sum_slot));
                     }
@@ -2594,14 +2933,14 @@ impl VM {
                 OpCode::Equal => {
                     let right = self.stack.pop().ok_or("Stack underflow")?;
                     let left = self.stack.pop().ok_or("Stack underflow")?;
-                    let result = Value::Bool(self.values_equal(&left, &right));
+                    let result = self.equality_op(&left, "==", &right)?;
                     self.stack.push(result);
                 }
 
                 OpCode::NotEqual => {
                     let right = self.stack.pop().ok_or("Stack underflow")?;
                     let left = self.stack.pop().ok_or("Stack underflow")?;
-                    let result = Value::Bool(!self.values_equal(&left, &right));
+                    let result = self.equality_op(&left, "!=", &right)?;
                     self.stack.push(result);
                 }
 
@@ -2681,11 +3020,13 @@ impl VM {
                 OpCode::Call(arg_count) => {
                     // Create call site ID for inline cache lookup
                     // This identifies where in the bytecode this call occurs
-                    let call_site_id = CallSiteId::new(self.chunk.name.as_deref(), self.ip);
+                    let call_site_id = CallSiteId::new(self.chunk_id, self.ip);
 
                     // Function is on top of stack, then arguments below it
                     // Stack layout: [... arg1, arg2, ..., argN, function]
-                    let function = self.stack.pop().ok_or("Stack underflow in Call")?;
+                    let function = crate::builtins::callable_namespace(
+                        self.stack.pop().ok_or("Stack underflow in Call")?,
+                    );
 
                     // Collect arguments
                     let mut args = Vec::new();
@@ -2761,7 +3102,7 @@ impl VM {
                                             let globals_ptr: *mut HashMap<String, Value> = {
                                                 let mut globals_guard =
                                                     self.globals.lock().unwrap();
-                                                let ptr = &mut globals_guard.scopes[0]
+                                                let ptr = &mut globals_guard.globals
                                                     as *mut HashMap<String, Value>;
                                                 drop(globals_guard);
                                                 ptr
@@ -2831,6 +3172,7 @@ impl VM {
 
                                             let result_code =
                                                 invoke_compiled_fn(compiled_fn, &mut vm_context);
+                                            self.mark_jit_globals_changed();
 
                                             if result_code != 0 {
                                                 return Err(format!(
@@ -2876,7 +3218,7 @@ impl VM {
                                                 let globals_ptr: *mut HashMap<String, Value> = {
                                                     let mut globals_guard =
                                                         self.globals.lock().unwrap();
-                                                    let ptr = &mut globals_guard.scopes[0]
+                                                    let ptr = &mut globals_guard.globals
                                                         as *mut HashMap<String, Value>;
                                                     drop(globals_guard);
                                                     ptr
@@ -2917,6 +3259,7 @@ impl VM {
                                                     &mut vm_context,
                                                     arg_val,
                                                 );
+                                                self.mark_jit_globals_changed();
 
                                                 if std::env::var("DEBUG_JIT").is_ok() {
                                                     eprintln!("JIT: Interpreter direct-arg call to '{}' with arg {} returned {}", 
@@ -3006,7 +3349,7 @@ impl VM {
                                     // Get globals - drop lock before execution to avoid deadlock on recursive calls
                                     let globals_ptr: *mut HashMap<String, Value> = {
                                         let mut globals_guard = self.globals.lock().unwrap();
-                                        let ptr = &mut globals_guard.scopes[0]
+                                        let ptr = &mut globals_guard.globals
                                             as *mut HashMap<String, Value>;
                                         drop(globals_guard);
                                         ptr
@@ -3089,6 +3432,7 @@ impl VM {
                                     // Lock is NOT held during execution to allow recursive calls
                                     let result_code =
                                         invoke_compiled_fn(*compiled_fn, &mut vm_context);
+                                    self.mark_jit_globals_changed();
 
                                     if result_code != 0 {
                                         return Err(format!(
@@ -3139,9 +3483,17 @@ impl VM {
                                     )
                                 });
 
+                                // Compiled code calls itself directly when it recurses, which
+                                // would skip a decorator rebound to its name (`@memo func fib`)
+                                let is_decorated = matches!(
+                                    self.globals.lock().unwrap().get(func_name),
+                                    Some(Value::Decorated(_))
+                                );
+
                                 let allow_function_jit = std::env::var("DISABLE_FUNCTION_JIT")
                                     .is_err()
-                                    && !has_map_fusion_op;
+                                    && !has_map_fusion_op
+                                    && !is_decorated;
                                 if allow_function_jit
                                     && (*count == JIT_FUNCTION_THRESHOLD
                                         || (has_loop && *count == 1))
@@ -3196,6 +3548,12 @@ impl VM {
 
                             self.call_bytecode_function(function.clone(), raw_args, args)?;
                         }
+                        // `error(message, payload)` raises its error object whole, so the
+                        // payload reaches the catch block
+                        Value::NativeFunction(name) if name == "error" => {
+                            let error = self.interpreter.call_native_function_impl(&name, &args);
+                            self.throw_runtime_value(error)?;
+                        }
                         Value::NativeFunction(_) => {
                             match self.call_native_function_vm(function.clone(), args) {
                                 Ok(result) => self.stack.push(result),
@@ -3208,6 +3566,83 @@ impl VM {
                             let result = self.call_interpreter_callable(&function, &args)?;
                             self.stack.push(result);
                         }
+                        Value::Decorated(decorated) => {
+                            match Interpreter::call_decorated_impl(self, decorated, &args) {
+                                error @ (Value::Error(_) | Value::ErrorObject { .. }) => {
+                                    self.throw_runtime_value(error)?;
+                                }
+                                result => self.stack.push(result),
+                            }
+                        }
+                        Value::StructDef { name, field_names, .. } => {
+                            match Value::construct_struct(&name, &field_names, args) {
+                                Ok(instance) => self.stack.push(instance),
+                                Err(err) => {
+                                    self.throw_runtime_value(Value::Error(err))?;
+                                }
+                            }
+                        }
+                        _ => {
+                            return Err(Self::non_callable_error_message(
+                                "the value being called is not callable",
+                            ));
+                        }
+                    }
+                }
+
+                OpCode::CallNamed(names) => {
+                    // Stack layout: [... positional array, named values..., function]
+                    let function = crate::builtins::callable_namespace(
+                        self.stack.pop().ok_or("Stack underflow in CallNamed")?,
+                    );
+                    let mut named = Vec::with_capacity(names.len());
+                    for name in names.iter().rev() {
+                        let value = self.stack.pop().ok_or("Stack underflow in CallNamed args")?;
+                        named.push((name.clone(), value));
+                    }
+                    named.reverse();
+                    let args = match self.stack.pop() {
+                        Some(Value::Array(args)) => args.as_ref().clone(),
+                        _ => return Err("Missing argument array in CallNamed".to_string()),
+                    };
+
+                    match &function {
+                        Value::BytecodeFunction { chunk, .. } => {
+                            let call_args =
+                                self.prepare_bytecode_call_args_named(chunk, args.clone(), named)?;
+                            self.call_bytecode_function(function.clone(), args, call_args)?;
+                        }
+                        Value::Function(..) | Value::GeneratorDef(..) => {
+                            let result =
+                                self.call_interpreter_callable_with_args(&function, args, named)?;
+                            self.stack.push(result);
+                        }
+                        Value::NativeFunction(name) | Value::StructDef { name, .. }
+                            if !named.is_empty() =>
+                        {
+                            let message = format!("{}() does not accept named arguments", name);
+                            self.throw_runtime_value(Value::Error(message))?;
+                        }
+                        Value::NativeFunction(name) if name == "error" => {
+                            let error = self.interpreter.call_native_function_impl(name, &args);
+                            self.throw_runtime_value(error)?;
+                        }
+                        Value::NativeFunction(_) => {
+                            match self.call_native_function_vm(function.clone(), args) {
+                                Ok(result) => self.stack.push(result),
+                                Err(err) => {
+                                    self.throw_runtime_value(Value::Error(err))?;
+                                }
+                            }
+                        }
+                        Value::StructDef { name, field_names, .. } => {
+                            match Value::construct_struct(name, field_names, args) {
+                                Ok(instance) => self.stack.push(instance),
+                                Err(err) => {
+                                    self.throw_runtime_value(Value::Error(err))?;
+                                }
+                            }
+                        }
                         _ => {
                             return Err(Self::non_callable_error_message(
                                 "the value being called is not callable",
@@ -3219,7 +3654,7 @@ impl VM {
                 OpCode::Return => {
                     let return_value = self.stack.pop().ok_or("Stack underflow in return")?;
 
-                    if let Some(frame) = self.call_frames.pop() {
+                    if let Some(mut frame) = self.call_frames.pop() {
                         // Pop from function call stack for error reporting
                         self.function_call_stack.pop();
 
@@ -3230,15 +3665,17 @@ impl VM {
 
                         // Restore previous state
                         self.ip = frame.return_ip;
-                        if let Some(prev_chunk) = frame.prev_chunk {
+                        if let Some(prev_chunk) = frame.prev_chunk.take() {
                             self.set_chunk(prev_chunk);
                         }
 
                         // Clear stack to frame offset
                         self.stack.truncate(frame.stack_offset);
+                        let is_async = frame.is_async;
+                        self.release_frame_storage(frame);
 
                         // If this was an async function, wrap the return value in a Promise
-                        let value_to_push = if frame.is_async {
+                        let value_to_push = if is_async {
                             // Create a tokio oneshot channel with the result already available
                             let (tx, rx) = tokio::sync::oneshot::channel();
                             tx.send(Ok(return_value))
@@ -3263,20 +3700,25 @@ impl VM {
                 }
 
                 OpCode::ReturnNone => {
-                    if let Some(frame) = self.call_frames.pop() {
+                    if let Some(mut frame) = self.call_frames.pop() {
+                        // Pop from function call stack for error reporting
+                        self.function_call_stack.pop();
+
                         // Decrement recursion depth
                         if self.recursion_depth > 0 {
                             self.recursion_depth -= 1;
                         }
 
                         self.ip = frame.return_ip;
-                        if let Some(prev_chunk) = frame.prev_chunk {
+                        if let Some(prev_chunk) = frame.prev_chunk.take() {
                             self.set_chunk(prev_chunk);
                         }
                         self.stack.truncate(frame.stack_offset);
+                        let is_async = frame.is_async;
+                        self.release_frame_storage(frame);
 
                         // If this was an async function, wrap None in a Promise
-                        let value_to_push = if frame.is_async {
+                        let value_to_push = if is_async {
                             let (tx, rx) = tokio::sync::oneshot::channel();
                             tx.send(Ok(Value::Null))
                                 .map_err(|_| "Failed to send to promise channel")?;
@@ -3304,7 +3746,7 @@ impl VM {
                         let mut captured = HashMap::new();
                         let mut captured_binding_kinds = HashMap::new();
 
-                        if std::env::var("DEBUG_VM").is_ok() {
+                        if debug_vm_enabled() {
                             eprintln!(
                                 "MakeClosure: function has {} upvalues: {:?}",
                                 chunk.upvalues.len(),
@@ -3326,75 +3768,90 @@ impl VM {
 
                         for upvalue_name in &chunk.upvalues {
                             // Find the variable in current scope (locals only - NOT globals)
-                            // Prefer local slots (authoritative for locals) and fall back to locals map
-                            let capture_entry = if let Some(frame) = self.call_frames.last() {
-                                if let Some(existing) = frame.captured.get(upvalue_name) {
-                                    let value = existing.lock().unwrap().clone();
-                                    let kind = frame
-                                        .captured_binding_kinds
-                                        .get(upvalue_name)
-                                        .copied()
-                                        .unwrap_or(BytecodeBindingKind::Mutable);
-                                    Some((value, kind))
-                                } else if let Some(slot) = self
-                                    .chunk
-                                    .local_names
-                                    .iter()
-                                    .position(|name| name == upvalue_name)
-                                {
-                                    let value = frame
-                                        .local_slots
-                                        .get(slot)
-                                        .cloned()
-                                        .or_else(|| frame.locals.get(upvalue_name).cloned());
-                                    let kind = frame
-                                        .local_slot_binding_kinds
-                                        .get(slot)
-                                        .copied()
-                                        .or_else(|| {
-                                            frame.locals_binding_kinds.get(upvalue_name).copied()
-                                        })
-                                        .unwrap_or(BytecodeBindingKind::Mutable);
-                                    value.map(|value| (value, kind))
-                                } else {
-                                    frame.locals.get(upvalue_name).cloned().map(|value| {
-                                        let kind = frame
-                                            .locals_binding_kinds
-                                            .get(upvalue_name)
-                                            .copied()
-                                            .unwrap_or(BytecodeBindingKind::Mutable);
-                                        (value, kind)
-                                    })
-                                }
-                            } else {
-                                None
+                            let Some(frame) = self.call_frames.last_mut() else {
+                                // Top-level closures resolve globals at runtime
+                                continue;
                             };
 
-                            if let Some((val, binding_kind)) = capture_entry {
-                                if std::env::var("DEBUG_VM").is_ok() {
+                            // Already shared with another closure: alias the same cell
+                            if let Some(existing) = frame.captured.get(upvalue_name) {
+                                let kind = frame
+                                    .captured_binding_kinds
+                                    .get(upvalue_name)
+                                    .copied()
+                                    .unwrap_or(BytecodeBindingKind::Mutable);
+                                captured.insert(upvalue_name.clone(), existing.clone());
+                                captured_binding_kinds.insert(upvalue_name.clone(), kind);
+                                continue;
+                            }
+
+                            // Named locals are the ones the compiler's escape analysis kept out
+                            // of slots. Promote them into a cell owned by the enclosing frame,
+                            // so the frame, this closure, and any sibling closure share it.
+                            if let Some(value) = frame.locals.remove(upvalue_name) {
+                                let kind = frame
+                                    .locals_binding_kinds
+                                    .remove(upvalue_name)
+                                    .unwrap_or(BytecodeBindingKind::Mutable);
+                                if debug_vm_enabled() {
                                     eprintln!(
                                         "  Captured '{}' from locals = {:?}",
-                                        upvalue_name, val
+                                        upvalue_name, value
                                     );
                                 }
-                                // Wrap in Arc<Mutex<>> for shared mutable state
-                                captured.insert(upvalue_name.clone(), Arc::new(Mutex::new(val)));
-                                captured_binding_kinds.insert(upvalue_name.clone(), binding_kind);
-                            } else {
-                                if std::env::var("DEBUG_VM").is_ok() {
-                                    eprintln!(
-                                        "  Skipped '{}' (not in locals, will resolve at runtime)",
-                                        upvalue_name
-                                    );
+                                let cell = Arc::new(Mutex::new(value));
+                                frame.captured.insert(upvalue_name.clone(), cell.clone());
+                                frame.captured_binding_kinds.insert(upvalue_name.clone(), kind);
+                                captured.insert(upvalue_name.clone(), cell);
+                                captured_binding_kinds.insert(upvalue_name.clone(), kind);
+                                continue;
+                            }
+
+                            // Slot locals are not shared with the frame; capture their value
+                            if let Some(slot) =
+                                self.chunk.local_names.iter().position(|name| name == upvalue_name)
+                            {
+                                if let Some(value) = frame.local_slots.get(slot).cloned() {
+                                    let kind = frame
+                                        .local_slot_binding_kinds
+                                        .get(slot)
+                                        .copied()
+                                        .unwrap_or(BytecodeBindingKind::Mutable);
+                                    captured
+                                        .insert(upvalue_name.clone(), Arc::new(Mutex::new(value)));
+                                    captured_binding_kinds.insert(upvalue_name.clone(), kind);
+                                    continue;
                                 }
-                                // Variable not in locals - it's either a global or undefined
-                                // Don't capture it - let it be resolved at runtime
+                            }
+
+                            // A captured local this function declares further down, such as
+                            // a later sibling closure: share a cell that `DefineLocal` fills
+                            if let Some(kind) =
+                                self.chunk.captured_local_kinds.get(upvalue_name).copied()
+                            {
+                                let cell = frame
+                                    .pending_captured
+                                    .entry(upvalue_name.clone())
+                                    .or_insert_with(|| Arc::new(Mutex::new(Value::Null)))
+                                    .clone();
+                                captured.insert(upvalue_name.clone(), cell);
+                                captured_binding_kinds.insert(upvalue_name.clone(), kind);
+                                continue;
+                            }
+
+                            // Variable not in locals - it's either a global or undefined
+                            // Don't capture it - let it be resolved at runtime
+                            if debug_vm_enabled() {
+                                eprintln!(
+                                    "  Skipped '{}' (not in locals, will resolve at runtime)",
+                                    upvalue_name
+                                );
                             }
                         }
 
                         // Create a closure value with captured variables
                         let value = Value::BytecodeFunction {
-                            chunk: (**chunk).clone(),
+                            chunk: chunk.clone(),
                             captured,
                             captured_binding_kinds,
                         };
@@ -3472,10 +3929,7 @@ impl VM {
                         let value = self.stack.pop().ok_or("Stack underflow")?;
                         let key = self.stack.pop().ok_or("Stack underflow")?;
 
-                        let key_str = match key {
-                            Value::Str(s) => Arc::from(s.as_str()),
-                            _ => return Err("Dict keys must be strings".to_string()),
-                        };
+                        let key_str = Value::dict_key(&key)?;
 
                         entries.push((key_str, value));
                     }
@@ -3508,10 +3962,7 @@ impl VM {
                             break;
                         }
 
-                        let key_str = match key {
-                            Value::Str(s) => Arc::from(s.as_str()),
-                            _ => return Err("Dict keys must be strings".to_string()),
-                        };
+                        let key_str = Value::dict_key(&key)?;
 
                         entries.push((key_str, value));
                     }
@@ -3547,7 +3998,7 @@ impl VM {
                 OpCode::IndexGet => {
                     let index = self.stack.pop().ok_or("Stack underflow")?;
                     let object = self.stack.pop().ok_or("Stack underflow")?;
-                    let result = Self::get_indexed_value(&object, &index)?;
+                    let result = self.index_value_vm(&object, &index)?;
 
                     self.stack.push(result);
                 }
@@ -3556,6 +4007,10 @@ impl VM {
                     let index = self.stack.pop().ok_or("Stack underflow")?;
                     let object = self.stack.pop().ok_or("Stack underflow")?;
                     let value = self.stack.pop().ok_or("Stack underflow")?;
+                    let index = match Value::map_key_index(&object, &index) {
+                        Some(key) => key?,
+                        None => index,
+                    };
 
                     match (object, index) {
                         (Value::Array(arr), Value::Int(i)) => {
@@ -3590,6 +4045,15 @@ impl VM {
                                 continue;
                             }
                         }
+                        (Value::OrderedDict(mut map), index @ (Value::Str(_) | Value::Int(_))) => {
+                            Arc::make_mut(&mut map).insert(Value::dict_key(&index)?, value);
+                            if !self.push_checked_value(
+                                Value::OrderedDict(map),
+                                "updating ordered map entry",
+                            )? {
+                                continue;
+                            }
+                        }
                         (Value::FixedDict { keys, mut values }, Value::Str(key)) => {
                             if let Some(idx) = keys.iter().position(|k| k.as_ref() == key.as_str())
                             {
@@ -4211,7 +4675,7 @@ impl VM {
                         .get(slot)
                         .cloned()
                         .ok_or_else(|| format!("Invalid local slot: {}", slot))?;
-                    let result = Self::get_indexed_value(&object, &index)?;
+                    let result = self.index_value_vm(&object, &index)?;
 
                     self.stack.push(result);
                 }
@@ -4245,6 +4709,10 @@ impl VM {
                             .local_slots
                             .get_mut(slot)
                             .ok_or_else(|| format!("Invalid local slot: {}", slot))?;
+                        let index = match Value::map_key_index(object, &index) {
+                            Some(key) => key?,
+                            None => index,
+                        };
 
                         match index {
                             Value::Int(i) => match object {
@@ -4261,6 +4729,9 @@ impl VM {
                                         return Err(format!("Index out of bounds: {}", i));
                                     }
                                 }
+                                Value::OrderedDict(map) => {
+                                    Arc::make_mut(map).insert(Arc::from(i.to_string()), value);
+                                }
                                 Value::Dict(dict) => {
                                     hashmap_profile_bump(&HASHMAP_SET_DICT_INTKEY);
                                     if dict.is_empty() {
@@ -4495,6 +4966,9 @@ impl VM {
                                     let dict_mut = Arc::make_mut(dict);
                                     dict_mut.insert(Arc::from(key.as_str()), value);
                                 }
+                                Value::OrderedDict(map) => {
+                                    Arc::make_mut(map).insert(Arc::from(key.as_str()), value);
+                                }
                                 Value::FixedDict { keys, values } => {
                                     if let Some(idx) =
                                         keys.iter().position(|k| k.as_ref() == key.as_str())
@@ -4685,25 +5159,22 @@ impl VM {
                                         if let Some(value) = fields.get(&field) {
                                             value.clone()
                                         } else {
-                                            let method_name = format!("{}.{}", name, field);
-                                            let global =
-                                                self.globals.lock().unwrap().get(&method_name);
-                                            global
-                                                .ok_or_else(|| {
-                                                    format!("Field not found: {}", field)
-                                                })?
-                                                .clone()
+                                            self.cached_global(name, Some(&field)).ok_or_else(
+                                                || format!("Field not found: {}", field),
+                                            )?
                                         }
                                     }
                                 }
                             } else if let Some(value) = fields.get(&field) {
                                 value.clone()
                             } else {
-                                let method_name = format!("{}.{}", name, field);
-                                let global = self.globals.lock().unwrap().get(&method_name);
-                                global.ok_or_else(|| format!("Field not found: {}", field))?.clone()
+                                self.cached_global(name, Some(&field))
+                                    .ok_or_else(|| format!("Field not found: {}", field))?
                             }
                         }
+                        Value::Module { name, exports } => {
+                            self.module_member_value(name, exports, &field)?
+                        }
                         Value::Dict(dict) => {
                             dict.get(field.as_str()).cloned().unwrap_or(Value::Null)
                         }
@@ -4732,12 +5203,63 @@ impl VM {
                             self.stack.push(object.clone());
                             Value::NativeFunction(format!("__channel_method_{}", field))
                         }
+                        Value::WaitGroup(_) => {
+                            // Same receiver-marker dispatch as channels.
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__wait_group_method_{}", field))
+                        }
+                        Value::Router(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__router_method_{}", field))
+                        }
+                        Value::Promise { .. } if Interpreter::is_promise_chain_method(&field) => {
+                            // Same receiver-marker dispatch as channels.
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__promise_method_{}", field))
+                        }
                         Value::Image { .. } => {
                             // Mirror channel method marker behavior for image method dispatch.
                             self.stack.push(object.clone());
                             Value::NativeFunction(format!("__image_method_{}", field))
                         }
-                        Value::HttpServer { .. } => match field.as_str() {
+                        Value::FileHandle { .. } => {
+                            // Mirror image method marker behavior for file handle dispatch.
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__file_handle_method_{}", field))
+                        }
+                        Value::StringBuilder(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__string_builder_method_{}", field))
+                        }
+                        Value::Mutex(_) | Value::SyncMap(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__sync_method_{}", field))
+                        }
+                        Value::NativeLibrary(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__native_library_method_{}", field))
+                        }
+                        Value::Process(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__process_method_{}", field))
+                        }
+                        Value::Socket(_) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__socket_method_{}", field))
+                        }
+                        Value::Database { .. } => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__database_method_{}", field))
+                        }
+                        Value::BytecodeGenerator { .. } => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__generator_method_{}", field))
+                        }
+                        Value::Array(_) if Interpreter::is_array_method(&field) => {
+                            self.stack.push(object.clone());
+                            Value::NativeFunction(format!("__array_method_{}", field))
+                        }
+                        Value::HttpServer { .. } => match field.as_str() {
                             "route" | "listen" | "start" => {
                                 // Mirror method marker behavior used by channel/image dispatch.
                                 self.stack.push(object.clone());
@@ -4933,9 +5455,37 @@ impl VM {
                     }
                 }
 
-                OpCode::MatchCasePattern(pattern) => {
+                OpCode::UnpackArray(count) => {
+                    let value = self.stack.pop().ok_or("Stack underflow")?;
+                    match Interpreter::unpack_assignment_values(&value, count) {
+                        Ok(values) => self.stack.extend(values),
+                        Err(message) => self.throw_runtime_value(Value::Error(message))?,
+                    }
+                }
+
+                OpCode::DestructurePattern(pattern_index, binding_kind) => {
+                    let Constant::Pattern(pattern) = self.chunk.constants[pattern_index].clone()
+                    else {
+                        return Err("Expected pattern constant".to_string());
+                    };
                     let value = self.stack.last().ok_or("Stack underflow")?.clone();
-                    let success = self.match_case_pattern(pattern.as_str(), &value);
+                    if let Err(message) = self.destructure_pattern(&pattern, &value, binding_kind) {
+                        self.throw_runtime_value(Value::Error(message))?;
+                    }
+                }
+
+                OpCode::MatchCasePattern(pattern_index) => {
+                    let Constant::MatchCase(pattern) = &self.chunk.constants[pattern_index] else {
+                        return Err("Expected match case pattern constant".to_string());
+                    };
+                    let value = self.stack.last().ok_or("Stack underflow")?;
+                    let mut bindings = Vec::new();
+                    let success = Value::match_pattern(pattern, value, &mut bindings);
+                    if success {
+                        for (name, bound) in bindings {
+                            self.bind_pattern_name(&name, bound, BytecodeBindingKind::Mutable);
+                        }
+                    }
                     self.stack.push(Value::Bool(success));
                 }
 
@@ -4983,6 +5533,17 @@ impl VM {
                     }
                 }
 
+                OpCode::MakeTagged(tag, arg_count) => {
+                    let start = self.stack.len().checked_sub(arg_count).ok_or("Stack underflow")?;
+                    let fields = self
+                        .stack
+                        .drain(start..)
+                        .enumerate()
+                        .map(|(index, value)| (format!("${}", index), value))
+                        .collect();
+                    self.stack.push(Value::Tagged { tag, fields });
+                }
+
                 OpCode::TryUnwrap => {
                     let value = self.stack.pop().ok_or("Stack underflow")?;
 
@@ -5053,6 +5614,42 @@ impl VM {
                     }
                 }
 
+                OpCode::MakeStructDef(name, field_names, method_names) => {
+                    let mut methods = HashMap::with_capacity(method_names.len());
+                    {
+                        let globals = self.globals.lock().unwrap();
+                        for method_name in method_names {
+                            if let Some(method) = globals.get(&format!("{}.{}", name, method_name))
+                            {
+                                methods.insert(method_name, method);
+                            }
+                        }
+                    }
+                    self.stack.push(Value::StructDef { name, field_names, methods });
+                }
+
+                OpCode::CheckImplements(interface_names) => {
+                    let Some(Value::StructDef { name, methods, .. }) = self.stack.last() else {
+                        return Err("Expected struct definition for implements check".to_string());
+                    };
+                    let mut failure = None;
+                    for interface_name in &interface_names {
+                        let interface = self.globals.lock().unwrap().get(interface_name);
+                        if let Err(message) = Value::check_implements(
+                            name,
+                            methods,
+                            interface_name,
+                            interface.as_ref(),
+                        ) {
+                            failure = Some(message);
+                            break;
+                        }
+                    }
+                    if let Some(message) = failure {
+                        self.throw_runtime_value(Value::Error(message))?;
+                    }
+                }
+
                 // Environment management
                 OpCode::PushScope => {
                     self.globals.lock().unwrap().push_scope();
@@ -5101,15 +5698,32 @@ impl VM {
                         function
                     {
                         // Create initial generator state (not yet started)
+                        let frame = CallFrame {
+                            return_ip: 0,
+                            stack_offset: 0,
+                            locals: HashMap::new(),
+                            locals_binding_kinds: HashMap::new(),
+                            local_slots: vec![Value::Null; chunk.local_count],
+                            local_slot_binding_kinds: vec![
+                                BytecodeBindingKind::Mutable;
+                                chunk.local_count
+                            ],
+                            local_slot_initialized: vec![false; chunk.local_count],
+                            captured,
+                            captured_binding_kinds,
+                            pending_captured: HashMap::new(),
+                            prev_chunk: None,
+                            is_async: false,
+                        };
                         let state = GeneratorState {
                             ip: 0,
                             stack: Vec::new(),
-                            call_frames_data: Vec::new(),
-                            chunk: chunk.clone(),
-                            locals: HashMap::new(),
-                            captured: captured.clone(),
-                            captured_binding_kinds: captured_binding_kinds.clone(),
+                            call_frames: vec![frame],
+                            exception_handlers: Vec::new(),
+                            chunk,
                             is_exhausted: false,
+                            is_running: false,
+                            peeked: None,
                         };
 
                         let generator =
@@ -5122,10 +5736,15 @@ impl VM {
                 }
 
                 OpCode::Yield => {
-                    // Yield is handled specially in generator_next() method
-                    // This opcode serves as a marker for the generator execution loop
-                    // When we reach here in normal execution, it's an error
-                    return Err("Yield can only be used inside generator functions".to_string());
+                    if self.generator_depth == 0 {
+                        return Err("Yield can only be used inside generator functions".to_string());
+                    }
+                    // Suspend the generator being resumed by generator_next(). The value
+                    // stays on the stack: expression-statement lowering emits a following
+                    // Pop that runs when the generator resumes.
+                    let yielded = self.stack.last().cloned().ok_or("Stack underflow in Yield")?;
+                    self.generator_yield = Some(yielded);
+                    return Ok(Value::Null);
                 }
 
                 OpCode::ResumeGenerator => {
@@ -5152,7 +5771,10 @@ impl VM {
                                     // Use cached result
                                     match cached.as_ref() {
                                         Some(Ok(val)) => {
-                                            self.stack.push(val.clone());
+                                            let val = val.clone();
+                                            drop(cached);
+                                            drop(polled);
+                                            self.push_awaited_value(val)?;
                                             continue;
                                         }
                                         Some(Err(err)) => {
@@ -5179,7 +5801,9 @@ impl VM {
                                         let mut cached = cached_result.lock().unwrap();
                                         *cached = Some(Ok(value.clone()));
                                         *polled = true;
-                                        self.stack.push(value);
+                                        drop(cached);
+                                        drop(polled);
+                                        self.push_awaited_value(value)?;
                                     }
                                     Ok(Err(error)) => {
                                         let mut polled = is_polled.lock().unwrap();
@@ -5269,7 +5893,9 @@ impl VM {
                                 Ok(Ok(value)) => {
                                     *cached = Some(Ok(value.clone()));
                                     *polled = true;
-                                    self.stack.push(value);
+                                    drop(cached);
+                                    drop(polled);
+                                    self.push_awaited_value(value)?;
                                 }
                                 Ok(Err(error)) => {
                                     *cached = Some(Err(error.clone()));
@@ -5344,51 +5970,7 @@ impl VM {
                     let error_value = self.stack.pop().ok_or("Stack underflow in BeginCatch")?;
 
                     // Convert error to structured error object if needed
-                    let error_obj = match error_value {
-                        Value::Str(msg) => {
-                            // Simple string error - wrap in error struct
-                            let mut fields = HashMap::new();
-                            fields.insert("message".to_string(), Value::Str(msg));
-                            fields.insert("stack".to_string(), Value::Array(Arc::new(Vec::new())));
-                            fields.insert("line".to_string(), Value::Int(0));
-                            Value::Struct { name: "Error".to_string(), fields }
-                        }
-                        Value::Error(msg) => {
-                            // Legacy Error type - wrap in struct
-                            let mut fields = HashMap::new();
-                            fields.insert("message".to_string(), Value::Str(Arc::new(msg)));
-                            fields.insert("stack".to_string(), Value::Array(Arc::new(Vec::new())));
-                            fields.insert("line".to_string(), Value::Int(0));
-                            Value::Struct { name: "Error".to_string(), fields }
-                        }
-                        Value::ErrorObject { message, stack, line, cause } => {
-                            // Full error object - convert to struct
-                            let mut fields = HashMap::new();
-                            fields.insert("message".to_string(), Value::Str(Arc::new(message)));
-                            fields.insert(
-                                "stack".to_string(),
-                                Value::Array(Arc::new(
-                                    stack.iter().map(|s| Value::Str(Arc::new(s.clone()))).collect(),
-                                )),
-                            );
-                            fields.insert("line".to_string(), Value::Int(line.unwrap_or(0) as i64));
-                            if let Some(cause_val) = cause {
-                                fields.insert("cause".to_string(), *cause_val);
-                            }
-                            Value::Struct { name: "Error".to_string(), fields }
-                        }
-                        other => {
-                            // Any other value - wrap as message
-                            let mut fields = HashMap::new();
-                            fields.insert(
-                                "message".to_string(),
-                                Value::Str(Arc::new(format!("{:?}", other))),
-                            );
-                            fields.insert("stack".to_string(), Value::Array(Arc::new(Vec::new())));
-                            fields.insert("line".to_string(), Value::Int(0));
-                            Value::Struct { name: "Error".to_string(), fields }
-                        }
-                    };
+                    let error_obj = error_value.into_caught_error_binding();
 
                     // Bind error to variable in current frame
                     if let Some(frame) = self.call_frames.last_mut() {
@@ -5413,16 +5995,30 @@ impl VM {
                     }
                     args.reverse();
 
-                    let result: Result<Value, Value> = match name.as_str() {
-                        "__vm_import_all" => self.vm_import_all(&args).map_err(Value::Error),
-                        "__vm_import_symbol" => self.vm_import_symbol(&args).map_err(Value::Error),
-                        _ => {
-                            let native_result =
-                                self.interpreter.call_native_function_impl(&name, &args);
-                            match native_result {
-                                Value::Error(msg) => Err(Value::Error(msg)),
-                                Value::ErrorObject { .. } => Err(native_result),
-                                other => Ok(other),
+                    // `iter.map(xs, f)` compiles like the `xs.map(f)` sugar; when the receiver
+                    // is a module exporting the name, call that export with the rest.
+                    let module_export = match args.first() {
+                        Some(Value::Module { exports, .. }) => exports.get(&name).cloned(),
+                        _ => None,
+                    };
+
+                    let result: Result<Value, Value> = if let Some(export) = module_export {
+                        self.call_function_from_jit(export, args.split_off(1)).map_err(Value::Error)
+                    } else {
+                        match name.as_str() {
+                            "__vm_import_all" => self.vm_import_all(&args).map_err(Value::Error),
+                            "__vm_import_symbol" => {
+                                self.vm_import_symbol(&args).map_err(Value::Error)
+                            }
+                            "__vm_import_path" => self.vm_import_path(&args).map_err(Value::Error),
+                            _ => {
+                                let native_result =
+                                    self.interpreter.call_native_function_impl(&name, &args);
+                                match native_result {
+                                    Value::Error(msg) => Err(Value::Error(msg)),
+                                    Value::ErrorObject { .. } => Err(native_result),
+                                    other => Ok(other),
+                                }
                             }
                         }
                     };
@@ -5489,6 +6085,11 @@ impl VM {
                     // until they go out of scope, then move them to the heap.
                 }
 
+                OpCode::SpawnThread => {
+                    let entry = self.stack.pop().ok_or("Stack underflow in SpawnThread")?;
+                    self.spawn_thread(entry);
+                }
+
                 // Channel operations
                 OpCode::MakeChannel | OpCode::ChannelSend | OpCode::ChannelRecv => {
                     // Channels require concurrent runtime support
@@ -5545,10 +6146,37 @@ impl VM {
                 method_params.extend(params.iter().cloned());
                 Value::GeneratorDef(method_params, body.clone())
             }
+            // Natives have no receiver parameter; the marker drops it before dispatch.
+            Value::NativeFunction(name) => {
+                Value::NativeFunction(format!("__module_native_method_{}", name))
+            }
             other => other.clone(),
         }
     }
 
+    /// Reads `ns.member` from a module namespace. Method-call sugar compiles
+    /// `ns.f(x)` as a receiver-first call immediately after this `FieldGet`, so
+    /// callees get the receiver parameter; plain reads return the export as-is.
+    fn module_member_value(
+        &self,
+        name: &str,
+        exports: &HashMap<String, Value>,
+        field: &str,
+    ) -> Result<Value, String> {
+        let value = exports
+            .get(field)
+            .ok_or_else(|| format!("Module '{}' has no export '{}'", name, field))?;
+        let feeds_method_call = matches!(
+            self.chunk.instructions.get(self.ip),
+            Some(OpCode::Call(_) | OpCode::CallNamed(_))
+        );
+        if feeds_method_call {
+            Ok(Self::wrap_module_export_for_method_call(value))
+        } else {
+            Ok(value.clone())
+        }
+    }
+
     fn module_namespace_value(module_name: &str, exports: &HashMap<String, Value>) -> Value {
         let mut module_fields = HashMap::with_capacity(exports.len());
         for (name, value) in exports {
@@ -5615,6 +6243,32 @@ impl VM {
         Ok(Value::Null)
     }
 
+    fn vm_import_path(&mut self, args: &[Value]) -> Result<Value, String> {
+        if args.len() != 2 {
+            return Err(format!("__vm_import_path expects 2 arguments, got {}", args.len()));
+        }
+
+        let import_path = match args.first() {
+            Some(Value::Str(path)) => path.as_ref().clone(),
+            _ => return Err("__vm_import_path expects module path as string".to_string()),
+        };
+        let namespace = match args.get(1) {
+            Some(Value::Str(name)) => name.as_ref().clone(),
+            _ => return Err("__vm_import_path expects namespace name as string".to_string()),
+        };
+
+        let importer_file = self.interpreter.source_file.as_deref().map(Path::new);
+        let module = self
+            .interpreter
+            .module_loader
+            .load_module_path(&import_path, importer_file)
+            .map_err(|err| err.message)?;
+
+        let module_value = Value::Module { name: module.name, exports: Arc::new(module.exports) };
+        self.define_import_binding_in_current_scope(namespace, module_value);
+        Ok(Value::Null)
+    }
+
     fn normalize_value_for_interpreter(value: Value) -> Value {
         match value {
             Value::Array(items) => {
@@ -5680,6 +6334,13 @@ impl VM {
                 }
                 Value::Struct { name, fields: normalized_fields }
             }
+            Value::OrderedDict(map) => Value::OrderedDict(Arc::new(
+                map.iter()
+                    .map(|(key, entry)| {
+                        (key.clone(), Self::normalize_value_for_interpreter(entry.clone()))
+                    })
+                    .collect(),
+            )),
             other => other,
         }
     }
@@ -5699,6 +6360,28 @@ impl VM {
         }
     }
 
+    /// `call_interpreter_callable` for a call that also passes named arguments.
+    fn call_interpreter_callable_with_args(
+        &mut self,
+        function: &Value,
+        args: Vec<Value>,
+        named: Vec<(String, Value)>,
+    ) -> Result<Value, String> {
+        let call_args = CallArgs {
+            positional: args.into_iter().map(Self::normalize_value_for_interpreter).collect(),
+            named: named
+                .into_iter()
+                .map(|(name, value)| (name, Self::normalize_value_for_interpreter(value)))
+                .collect(),
+        };
+        let result = self.interpreter.call_user_function_with_args(function, call_args);
+        match result {
+            Value::Error(message) => Err(message),
+            Value::ErrorObject { message, .. } => Err(message),
+            other => Ok(other),
+        }
+    }
+
     /// Convert a constant to a runtime value
     fn constant_to_value(&self, constant: &Constant) -> Result<Value, String> {
         match constant {
@@ -5708,12 +6391,17 @@ impl VM {
             Constant::Bool(b) => Ok(Value::Bool(*b)),
             Constant::None => Ok(Value::Null),
             Constant::Function(chunk) => Ok(Value::BytecodeFunction {
-                chunk: (**chunk).clone(),
+                chunk: chunk.clone(),
                 captured: HashMap::new(),
                 captured_binding_kinds: HashMap::new(),
             }),
-            Constant::Pattern(_) => Err("Cannot convert pattern to value".to_string()),
+            Constant::Pattern(_) | Constant::MatchCase(_) => {
+                Err("Cannot convert pattern to value".to_string())
+            }
             Constant::Type(_) => Err("Cannot convert type annotation to value".to_string()),
+            Constant::Interface(name, methods) => {
+                Ok(Value::Interface { name: name.clone(), methods: methods.clone() })
+            }
             Constant::Array(elements) => {
                 let mut array = Vec::new();
                 for elem in elements {
@@ -5740,9 +6428,20 @@ impl VM {
     }
 
     fn prepare_bytecode_call_args(
+        &self,
+        chunk: &BytecodeChunk,
+        args: Vec<Value>,
+    ) -> Result<Vec<Value>, String> {
+        self.prepare_bytecode_call_args_named(chunk, args, Vec::new())
+    }
+
+    /// Like `prepare_bytecode_call_args`, also binding `name: value` arguments and
+    /// filling parameter defaults and the rest parameter.
+    fn prepare_bytecode_call_args_named(
         &self,
         chunk: &BytecodeChunk,
         mut args: Vec<Value>,
+        named: Vec<(String, Value)>,
     ) -> Result<Vec<Value>, String> {
         let param_names = &chunk.params;
 
@@ -5750,6 +6449,26 @@ impl VM {
         let is_method = chunk.name.as_deref().map(|name| name.contains('.')).unwrap_or(false)
             && matches!(args.first(), Some(Value::Struct { .. }));
 
+        if chunk.is_variadic || !chunk.param_defaults.is_empty() || !named.is_empty() {
+            let has_self_param = param_names.first().map(|p| p == "self").unwrap_or(false);
+            // Legacy methods without `self` still receive the instance first.
+            let receiver =
+                if is_method { Some(args.remove(0)).filter(|_| has_self_param) } else { None };
+            let skip = usize::from(receiver.is_some());
+            let mut arranged = arrange_call_args(
+                &callable_name,
+                &param_names[skip..],
+                chunk.param_defaults.get(skip..).unwrap_or_default(),
+                chunk.is_variadic,
+                CallArgs { positional: args, named },
+                |constant| self.constant_to_value(constant).unwrap_or(Value::Null),
+            )?;
+            if let Some(receiver) = receiver {
+                arranged.insert(0, receiver);
+            }
+            return Ok(arranged);
+        }
+
         if is_method {
             let has_self_param = param_names.first().map(|p| p == "self").unwrap_or(false);
             let external_params: Vec<String> = if has_self_param {
@@ -5783,7 +6502,11 @@ impl VM {
         call_args: Vec<Value>,
     ) -> Result<(), String> {
         if let Value::BytecodeFunction { chunk, captured, captured_binding_kinds } = function {
-            let max_depth = runtime_limits::DEFAULT_MAX_VM_CALL_DEPTH;
+            let max_depth = self
+                .interpreter
+                .execution_budget()
+                .and_then(|budget| budget.max_call_depth())
+                .unwrap_or(runtime_limits::DEFAULT_MAX_VM_CALL_DEPTH);
             if self.call_frames.len() >= max_depth || self.recursion_depth >= max_depth {
                 let callable = chunk.name.as_deref().unwrap_or("<anonymous>");
                 return Err(format!(
@@ -5793,8 +6516,13 @@ impl VM {
             }
 
             // Create new call frame with parameters bound
-            let mut locals = HashMap::new();
-            let mut locals_binding_kinds = HashMap::new();
+            let FrameStorage {
+                mut locals,
+                mut locals_binding_kinds,
+                mut local_slots,
+                mut local_slot_binding_kinds,
+                mut local_slot_initialized,
+            } = self.acquire_frame_storage(&chunk);
 
             // Backward-compat method support:
             // For methods compiled without explicit `self`, interpreter mode exposes
@@ -5823,17 +6551,6 @@ impl VM {
                 };
             let param_names = &chunk.params;
 
-            let mut local_slots = vec![Value::Null; chunk.local_count];
-            let mut local_slot_binding_kinds =
-                if chunk.local_binding_kinds.len() < chunk.local_count {
-                    let mut kinds = chunk.local_binding_kinds.clone();
-                    kinds.resize(chunk.local_count, BytecodeBindingKind::Mutable);
-                    kinds
-                } else {
-                    chunk.local_binding_kinds.clone()
-                };
-            let mut local_slot_initialized = vec![false; chunk.local_count];
-
             if let Some(fields) = compat_receiver_fields {
                 for (field_name, field_value) in fields {
                     locals.insert(field_name.clone(), field_value.clone());
@@ -5870,7 +6587,7 @@ impl VM {
             }
             let captured_binding_kinds_map = captured_binding_kinds.clone();
 
-            if std::env::var("DEBUG_VM").is_ok() {
+            if debug_vm_enabled() {
                 eprintln!(
                     "CallFrame has {} captured variables: {:?}",
                     captured_map.len(),
@@ -5879,27 +6596,30 @@ impl VM {
             }
 
             if chunk.is_generator {
-                let frame_data = CallFrameData {
+                let frame = CallFrame {
                     return_ip: 0,
                     stack_offset: 0,
-                    locals: locals.clone(),
-                    locals_binding_kinds: locals_binding_kinds.clone(),
-                    local_slots: local_slots.clone(),
-                    local_slot_binding_kinds: local_slot_binding_kinds.clone(),
-                    local_slot_initialized: local_slot_initialized.clone(),
-                    captured: captured_map.clone(),
-                    captured_binding_kinds: captured_binding_kinds_map.clone(),
+                    locals,
+                    locals_binding_kinds,
+                    local_slots,
+                    local_slot_binding_kinds,
+                    local_slot_initialized,
+                    captured: captured_map,
+                    captured_binding_kinds: captured_binding_kinds_map,
+                    pending_captured: HashMap::new(),
+                    prev_chunk: None,
+                    is_async: false,
                 };
 
                 let state = GeneratorState {
                     ip: 0,
                     stack: Vec::new(),
-                    call_frames_data: vec![frame_data],
+                    call_frames: vec![frame],
+                    exception_handlers: Vec::new(),
                     chunk,
-                    locals,
-                    captured: captured_map,
-                    captured_binding_kinds: captured_binding_kinds_map,
                     is_exhausted: false,
+                    is_running: false,
+                    peeked: None,
                 };
 
                 self.stack.push(Value::BytecodeGenerator { state: Arc::new(Mutex::new(state)) });
@@ -5916,6 +6636,7 @@ impl VM {
                 local_slot_initialized,
                 captured: captured_map,
                 captured_binding_kinds: captured_binding_kinds_map,
+                pending_captured: HashMap::new(),
                 prev_chunk: Some(self.chunk.clone()),
                 is_async: chunk.is_async,
             };
@@ -6028,6 +6749,7 @@ impl VM {
                 let mut temp_vm = VM::new();
                 temp_vm.jit_enabled = false;
                 temp_vm.set_capability_policy(self.interpreter.capability_policy().clone());
+                temp_vm.set_execution_budget(self.interpreter.execution_budget().cloned());
                 temp_vm.set_globals(Arc::clone(&self.globals));
                 let result = temp_vm.execute(wrapper_chunk);
 
@@ -6044,11 +6766,100 @@ impl VM {
         }
     }
 
+    /// Run `entry` (the closure compiled from a `spawn` body) on a new OS thread.
+    ///
+    /// The thread gets its own VM over a snapshot of the globals and of the closure's
+    /// captured cells, so its assignments never write back to the spawning scope.
+    /// Channels, wait groups, and shared-store entries are the way to hand results back.
+    fn spawn_thread(&mut self, entry: Value) {
+        let run = self.detached_call(entry, "<spawn>");
+        std::thread::spawn(move || {
+            let _ = run(Vec::new());
+        });
+    }
+
+    /// Package `entry` to be called later on another thread by a fresh VM.
+    ///
+    /// Used by `spawn` and by promise `.then`/`.catch`/`.finally` callbacks. The callee
+    /// sees the snapshot semantics documented on `spawn_thread`; a runtime error comes
+    /// back as a `Value::Error`.
+    fn detached_call(&self, entry: Value, label: &str) -> PromiseCallback {
+        let entry = match entry {
+            Value::BytecodeFunction { chunk, captured, captured_binding_kinds } => {
+                let captured = captured
+                    .iter()
+                    .map(|(name, cell)| {
+                        let value = cell.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+                        (name.clone(), Arc::new(Mutex::new(value.clone())))
+                    })
+                    .collect();
+                Value::BytecodeFunction { chunk, captured, captured_binding_kinds }
+            }
+            other => other,
+        };
+        let globals_snapshot =
+            self.globals.lock().unwrap_or_else(|poisoned| poisoned.into_inner()).clone();
+        let capability_policy = self.interpreter.capability_policy().clone();
+        let execution_budget = self.interpreter.execution_budget().cloned();
+        let label = label.to_string();
+
+        Box::new(move |args: Vec<Value>| {
+            let entry_name = "__detached_entry".to_string();
+            let globals = Arc::new(Mutex::new(globals_snapshot));
+            let mut entry_chunk = BytecodeChunk::new();
+            entry_chunk.name = Some(label);
+            {
+                let mut globals = globals.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+                // `Call` expects the arguments below the callee on the stack.
+                for (index, arg) in args.iter().enumerate() {
+                    let arg_name = format!("__detached_arg_{}", index);
+                    globals.set(arg_name.clone(), arg.clone());
+                    entry_chunk.emit(OpCode::LoadGlobal(arg_name));
+                }
+                globals.set(entry_name.clone(), entry);
+                entry_chunk.emit(OpCode::LoadGlobal(entry_name));
+            }
+            entry_chunk.emit(OpCode::Call(args.len()));
+            entry_chunk.emit(OpCode::Return);
+
+            let mut thread_vm = VM::new();
+            thread_vm.jit_enabled = false;
+            // Nothing else is scheduled on this thread, so `await` may simply block.
+            thread_vm.cooperative_suspend_enabled = false;
+            thread_vm.set_capability_policy(capability_policy.clone());
+            thread_vm.set_execution_budget(execution_budget.clone());
+            thread_vm.set_globals(globals);
+            thread_vm.execute(entry_chunk).unwrap_or_else(Value::Error)
+        })
+    }
+
+    /// Runs `http.serve`; each request's handler is a detached call, so it runs in its own VM
+    /// over a snapshot of the globals taken when the request arrives.
+    fn serve_http_module_vm(&mut self, args: &[Value]) -> Result<Value, String> {
+        if let Err(error) =
+            self.interpreter.require_capability(NativeCapability::NetworkServer, "http.serve")
+        {
+            return Err(match error {
+                Value::Error(message) => message,
+                _ => "Capability denied: network-server required for http.serve; rerun with --allow-net-server".to_string(),
+            });
+        }
+        if let Some(arity) = Interpreter::native_function_arity("http.serve") {
+            arity.validate(args.len())?;
+        }
+        match Interpreter::serve_http_module_impl(args, |handler| {
+            self.detached_call(handler, "<http handler>")
+        }) {
+            Value::Error(message) => Err(message),
+            other => Ok(other),
+        }
+    }
+
     fn start_http_server_vm(
         &mut self,
         host: String,
         port: u16,
-        routes: Vec<(String, String, Value)>,
+        mut routes: Vec<(String, String, Value)>,
     ) -> Result<Value, String> {
         use tiny_http::{Response, Server};
         if let Err(error) = self
@@ -6067,8 +6878,16 @@ impl VM {
 
         println!("Server listening on http://{}:{}", host, port);
         println!("Press Ctrl+C to stop");
+        let hot_reload = crate::watch::enable_hot_reload();
 
         for mut request in server.incoming_requests() {
+            if hot_reload {
+                let reloads = self.interpreter.module_loader.reload_changed_modules();
+                if !reloads.is_empty() {
+                    let mut globals = self.globals.lock().unwrap();
+                    crate::module::apply_module_reloads(&reloads, &mut globals, &mut routes);
+                }
+            }
             let method = request.method().to_string();
             let request_url = request.url().to_string();
             let (url_path, query_params, decoded_query_params, raw_query) =
@@ -6178,6 +6997,60 @@ impl VM {
         mut args: Vec<Value>,
     ) -> Result<Value, String> {
         if let Value::NativeFunction(name) = function {
+            if let Some(native_name) = name.strip_prefix("__module_native_method_") {
+                if !args.is_empty() {
+                    args.remove(0);
+                }
+                return self
+                    .call_native_function_vm(Value::NativeFunction(native_name.to_string()), args);
+            }
+
+            if name == "http.serve" {
+                return self.serve_http_module_vm(&args);
+            }
+
+            if let Some(method_name) = name.strip_prefix("__router_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+                let receiver = self.stack.pop().ok_or("Stack underflow getting router")?;
+                let Value::Router(router) = receiver else {
+                    return Err("Expected Router for router method call".to_string());
+                };
+                let result = Interpreter::call_router_method_impl(
+                    &router,
+                    method_name,
+                    &args,
+                    |handler, request| {
+                        self.call_http_handler_vm(handler, request).unwrap_or_else(Value::Error)
+                    },
+                );
+                return match result {
+                    Value::Error(message) => Err(message),
+                    other => Ok(other),
+                };
+            }
+
+            // The for-loop bound over a generator cursor: resume the generator and hold
+            // the value it yields for the element read that follows.
+            if name == "len" {
+                if let [Value::Iterator { source, .. }] = args.as_slice() {
+                    if let Value::BytecodeGenerator { state } = source.as_ref() {
+                        let state = state.clone();
+                        let step =
+                            self.generator_next(Value::BytecodeGenerator { state: state.clone() })?;
+                        return Ok(match step {
+                            Value::Option { is_some: true, value } => {
+                                state.lock().unwrap().peeked = Some(*value);
+                                Value::Int(i64::MAX)
+                            }
+                            _ => Value::Int(0),
+                        });
+                    }
+                }
+            }
+
             if name == "__vm_for_iterable" {
                 if args.len() != 1 {
                     return Err(format!(
@@ -6185,73 +7058,59 @@ impl VM {
                         args.len()
                     ));
                 }
+                // A generator may never finish, so the loop walks it through a cursor
+                // instead of draining it; `len` and indexing on the cursor resume it.
                 if let Value::BytecodeGenerator { .. } = &args[0] {
-                    let mut values = Vec::new();
-                    let generator_value = args[0].clone();
-                    loop {
-                        let step = self.generator_next(generator_value.clone())?;
-                        match step {
-                            Value::Option { is_some: true, value } => values.push(*value),
-                            Value::Option { is_some: false, .. } => break,
-                            other => {
-                                return Err(format!(
-                                    "__vm_for_iterable expected generator step to return Option, got {:?}",
-                                    other
-                                ));
-                            }
-                        }
-                    }
-                    return Ok(Value::Array(Arc::new(values)));
+                    return Ok(Value::Iterator {
+                        source: Box::new(args.remove(0)),
+                        index: 0,
+                        transformer: None,
+                        filter_fn: None,
+                        take_count: None,
+                    });
+                }
+                // The counted loop needs a length, so a sequence is drained up front; its
+                // stages still stream into this one array without intermediates.
+                if let Value::Sequence(sequence) = &args[0] {
+                    let sequence = sequence.clone();
+                    return sequence.collect(self).map(|values| Value::Array(Arc::new(values)));
                 }
             }
 
-            // Handle channel method calls
-            if name.starts_with("__channel_method_") {
-                let method_name = name.strip_prefix("__channel_method_").unwrap();
-                // The channel object was pushed onto the stack before the function marker
-                // So it's at the bottom of our "args" - but actually, it's still on the stack
-                // because FieldGet pushed it back. We need to pop it from the stack!
-                let channel = self.stack.pop().ok_or("Stack underflow getting channel")?;
+            // Handle channel and wait group method calls.
+            if let Some(method_name) = name
+                .strip_prefix("__channel_method_")
+                .or_else(|| name.strip_prefix("__wait_group_method_"))
+            {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let receiver = self.stack.pop().ok_or("Stack underflow getting channel")?;
 
-                if let Value::Channel(chan) = channel {
-                    return match method_name {
-                        "send" => {
-                            if args.len() != 1 {
-                                return Err(format!(
-                                    "Channel.send expects 1 arguments, got {}",
-                                    args.len()
-                                ));
-                            }
-                            let value = args.remove(0);
-                            let chan_lock = chan.lock().unwrap();
-                            let (sender, _) = &*chan_lock;
-                            match sender.send(value) {
-                                Ok(_) => Ok(Value::Bool(true)),
-                                Err(_) => Err("Failed to send to channel".to_string()),
-                            }
-                        }
-                        "receive" => {
-                            if !args.is_empty() {
-                                return Err(format!(
-                                    "Channel.receive expects 0 arguments, got {}",
-                                    args.len()
-                                ));
-                            }
-                            let chan_lock = chan.lock().unwrap();
-                            let (_, receiver) = &*chan_lock;
-                            match receiver.try_recv() {
-                                Ok(value) => Ok(value),
-                                Err(std::sync::mpsc::TryRecvError::Empty) => Ok(Value::Null),
-                                Err(std::sync::mpsc::TryRecvError::Disconnected) => {
-                                    Err("Channel disconnected".to_string())
-                                }
-                            }
-                        }
-                        _ => Err(format!("Channel has no method '{}'", method_name)),
-                    };
-                } else {
-                    return Err("Expected Channel for channel method call".to_string());
+                match Interpreter::call_concurrency_method_impl(&receiver, method_name, &args) {
+                    Some(Value::Error(msg)) => return Err(msg),
+                    Some(other) => return Ok(other),
+                    None => return Err("Expected Channel for channel method call".to_string()),
+                }
+            }
+
+            // Handle promise chaining (`then`, `catch`, `finally`).
+            if let Some(method_name) = name.strip_prefix("__promise_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
                 }
+
+                let receiver = self.stack.pop().ok_or("Stack underflow getting promise")?;
+                let callback = match Interpreter::promise_chain_callback_arg(method_name, args) {
+                    Ok(callback) => callback,
+                    Err(Value::Error(msg)) => return Err(msg),
+                    Err(other) => return Ok(other),
+                };
+                let callback = self.detached_call(callback, "<promise callback>");
+                return Ok(Interpreter::chain_promise_impl(&receiver, method_name, callback));
             }
 
             // Handle image method calls.
@@ -6285,48 +7144,210 @@ impl VM {
                 }
             }
 
-            // Handle HttpServer method calls.
-            if name.starts_with("__http_server_method_") {
-                let method_name = name.strip_prefix("__http_server_method_").unwrap();
+            // Handle file handle method calls.
+            if name.starts_with("__file_handle_method_") {
+                let method_name = name.strip_prefix("__file_handle_method_").unwrap();
 
-                // Remove duplicate receiver argument emitted by MethodCall compilation.
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
                 if !args.is_empty() {
                     args.pop();
                 }
 
-                let server = self.stack.pop().ok_or("Stack underflow getting HTTP server")?;
+                let handle = self.stack.pop().ok_or("Stack underflow getting file handle")?;
 
-                if let Value::HttpServer { host, port, routes } = server {
-                    return match method_name {
-                        "route" => {
-                            if args.len() != 3 {
-                                Err("route() requires (method, path, handler_function)".to_string())
-                            } else {
-                                match (&args[0], &args[1], &args[2]) {
-                                    (
-                                        Value::Str(method),
-                                        Value::Str(path),
-                                        Value::BytecodeFunction { .. } | Value::NativeFunction(_),
-                                    ) => {
-                                        let mut new_routes = routes.clone();
-                                        new_routes.push((
-                                            method.as_ref().clone(),
-                                            path.as_ref().clone(),
-                                            args[2].clone(),
-                                        ));
-                                        Ok(Value::HttpServer { host, port, routes: new_routes })
-                                    }
-                                    _ => Err("route() requires (method, path, handler_function)"
-                                        .to_string()),
-                                }
-                            }
-                        }
-                        "listen" | "start" => {
-                            if !args.is_empty() {
-                                Err(format!(
-                                    "HttpServer.{} expects 0 arguments, got {}",
-                                    method_name,
-                                    args.len()
+                match Interpreter::call_file_handle_method_impl(&handle, method_name, &args) {
+                    Some(Value::Error(msg)) => return Err(msg),
+                    Some(other) => return Ok(other),
+                    None => {
+                        return Err("Expected FileHandle for file handle method call".to_string())
+                    }
+                }
+            }
+
+            // Handle mutex and sync map method calls; `with_lock` runs its function here.
+            if let Some(method_name) = name.strip_prefix("__sync_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let receiver = self.stack.pop().ok_or("Stack underflow getting sync value")?;
+                let result =
+                    Interpreter::call_sync_method_impl(&receiver, method_name, &args, |function| {
+                        self.call_vm_operator_method(function.clone(), Vec::new())
+                            .unwrap_or_else(Value::Error)
+                    });
+                return match result {
+                    Some(Value::Error(message)) => Err(message),
+                    Some(other) => Ok(other),
+                    None => Err("Expected Mutex or SyncMap for sync method call".to_string()),
+                };
+            }
+
+            // Handle string builder method calls.
+            if let Some(method_name) = name.strip_prefix("__string_builder_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let builder = self.stack.pop().ok_or("Stack underflow getting string builder")?;
+
+                match Interpreter::call_string_builder_method_impl(&builder, method_name, &args) {
+                    Some(Value::Error(msg)) => return Err(msg),
+                    Some(other) => return Ok(other),
+                    None => {
+                        return Err(
+                            "Expected StringBuilder for string builder method call".to_string()
+                        )
+                    }
+                }
+            }
+
+            // Handle native library method calls.
+            if let Some(method_name) = name.strip_prefix("__native_library_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let library = self.stack.pop().ok_or("Stack underflow getting native library")?;
+
+                match Interpreter::call_native_library_method_impl(&library, method_name, &args) {
+                    Some(Value::Error(msg)) => return Err(msg),
+                    Some(other) => return Ok(other),
+                    None => {
+                        return Err(
+                            "Expected NativeLibrary for native library method call".to_string()
+                        )
+                    }
+                }
+            }
+
+            // Handle process method calls.
+            if let Some(method_name) = name.strip_prefix("__process_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let process = self.stack.pop().ok_or("Stack underflow getting process")?;
+
+                match Interpreter::call_process_method_impl(&process, method_name, &args) {
+                    Some(Value::Error(msg)) | Some(Value::ErrorObject { message: msg, .. }) => {
+                        return Err(msg)
+                    }
+                    Some(other) => return Ok(other),
+                    None => return Err("Expected Process for process method call".to_string()),
+                }
+            }
+
+            // Handle socket method calls.
+            if let Some(method_name) = name.strip_prefix("__socket_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let socket = self.stack.pop().ok_or("Stack underflow getting socket")?;
+
+                match Interpreter::call_socket_method_impl(&socket, method_name, &args) {
+                    Some(Value::Error(msg)) | Some(Value::ErrorObject { message: msg, .. }) => {
+                        return Err(msg)
+                    }
+                    Some(other) => return Ok(other),
+                    None => return Err("Expected socket for socket method call".to_string()),
+                }
+            }
+
+            // Handle database method calls.
+            if let Some(method_name) = name.strip_prefix("__database_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let database = self.stack.pop().ok_or("Stack underflow getting database")?;
+
+                match Interpreter::call_database_method_impl(self, &database, method_name, &args) {
+                    Some(Value::Error(msg)) | Some(Value::ErrorObject { message: msg, .. }) => {
+                        return Err(msg)
+                    }
+                    Some(other) => return Ok(other),
+                    None => return Err("Expected Database for database method call".to_string()),
+                }
+            }
+
+            if let Some(method_name) = name.strip_prefix("__array_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let array = self.stack.pop().ok_or("Stack underflow getting array")?;
+                args.insert(0, array);
+                return self
+                    .call_native_function_vm(Value::NativeFunction(method_name.to_string()), args);
+            }
+
+            if let Some(method_name) = name.strip_prefix("__generator_method_") {
+                // Remove the duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let generator = self.stack.pop().ok_or("Stack underflow getting generator")?;
+                return match method_name {
+                    "next" if args.is_empty() => {
+                        Interpreter::generator_step_impl(self, &[generator])
+                    }
+                    "next" => Err(format!("next() expects 0 arguments, got {}", args.len())),
+                    _ => Err(format!("Generator has no method '{}'", method_name)),
+                };
+            }
+
+            // Handle HttpServer method calls.
+            if name.starts_with("__http_server_method_") {
+                let method_name = name.strip_prefix("__http_server_method_").unwrap();
+
+                // Remove duplicate receiver argument emitted by MethodCall compilation.
+                if !args.is_empty() {
+                    args.pop();
+                }
+
+                let server = self.stack.pop().ok_or("Stack underflow getting HTTP server")?;
+
+                if let Value::HttpServer { host, port, routes } = server {
+                    return match method_name {
+                        "route" => {
+                            if args.len() != 3 {
+                                Err("route() requires (method, path, handler_function)".to_string())
+                            } else {
+                                match (&args[0], &args[1], &args[2]) {
+                                    (
+                                        Value::Str(method),
+                                        Value::Str(path),
+                                        Value::BytecodeFunction { .. } | Value::NativeFunction(_),
+                                    ) => {
+                                        let mut new_routes = routes.clone();
+                                        new_routes.push((
+                                            method.as_ref().clone(),
+                                            path.as_ref().clone(),
+                                            args[2].clone(),
+                                        ));
+                                        Ok(Value::HttpServer { host, port, routes: new_routes })
+                                    }
+                                    _ => Err("route() requires (method, path, handler_function)"
+                                        .to_string()),
+                                }
+                            }
+                        }
+                        "listen" | "start" => {
+                            if !args.is_empty() {
+                                Err(format!(
+                                    "HttpServer.{} expects 0 arguments, got {}",
+                                    method_name,
+                                    args.len()
                                 ))
                             } else {
                                 if let Err(error) = self.interpreter.require_capability(
@@ -6579,6 +7600,16 @@ impl VM {
                 }
             }
 
+            if name == "implements" && args.len() == 2 {
+                // Struct definitions live in VM globals, which the interpreter cannot see
+                let struct_def = match &args[0] {
+                    Value::StructDef { .. } => Some(args[0].clone()),
+                    Value::Struct { name, .. } => self.globals.lock().unwrap().get(name),
+                    _ => None,
+                };
+                return Value::satisfies_interface(struct_def.as_ref(), &args[1]).map(Value::Bool);
+            }
+
             if name == "dict" {
                 if !args.is_empty() {
                     return Err("dict() expects 0 arguments".to_string());
@@ -6590,6 +7621,17 @@ impl VM {
                 return result;
             }
 
+            if Interpreter::renders_with_to_string_hook(&name) {
+                for arg in args.iter_mut() {
+                    if let Some(rendered) = self.try_call_vm_to_string_method(arg) {
+                        *arg = match rendered? {
+                            text @ Value::Str(_) => text,
+                            other => Value::Str(Arc::new(Interpreter::stringify_value(&other))),
+                        };
+                    }
+                }
+            }
+
             // Use the interpreter's native function implementation
             // This gives us access to ALL 100+ built-in functions automatically
             let result = self.interpreter.call_native_function_impl(&name, &args);
@@ -6612,6 +7654,101 @@ impl VM {
         args: &[Value],
     ) -> Option<Result<Value, String>> {
         match name {
+            name if name.starts_with("iter.") => {
+                if let Some(arity) = Interpreter::native_function_arity(name) {
+                    if let Err(message) = arity.validate(args.len()) {
+                        return Some(Err(message));
+                    }
+                }
+                Some(Interpreter::call_iter_module_impl(self, &name["iter.".len()..], args))
+            }
+            "next" => Some(Interpreter::generator_step_impl(self, args)),
+            "sort" => {
+                let (array, compare) = match args {
+                    [Value::Array(arr), compare @ Value::BytecodeFunction { .. }] => {
+                        (arr.clone(), compare.clone())
+                    }
+                    _ => return None,
+                };
+                Some(
+                    Interpreter::sort_with_comparator_impl(self, &array, &compare)
+                        .map(|sorted| Value::Array(Arc::new(sorted))),
+                )
+            }
+            "fs.walk" => {
+                let (root, callback) = match (args.first(), args.get(1)) {
+                    (Some(Value::Str(root)), Some(callback @ Value::BytecodeFunction { .. })) => {
+                        (root.clone(), callback.clone())
+                    }
+                    _ => return None,
+                };
+                if let Err(error) =
+                    self.interpreter.require_capability(NativeCapability::FilesystemRead, name)
+                {
+                    return Some(Err(match error {
+                        Value::Error(message) => message,
+                        _ => "Capability denied: filesystem-read required for fs.walk".to_string(),
+                    }));
+                }
+
+                let mut visit = |entry: Value| {
+                    self.call_http_handler_vm(callback.clone(), entry).unwrap_or_else(Value::Error)
+                };
+                Some(match Interpreter::walk_fs_impl(&root, &mut visit) {
+                    Ok(count) => Ok(Value::Int(count)),
+                    Err(Value::ErrorObject { message, .. }) | Err(Value::Error(message)) => {
+                        Err(message)
+                    }
+                    Err(_) => Err("fs.walk() failed".to_string()),
+                })
+            }
+            "assert_raises" => {
+                let callback = match args.first() {
+                    Some(callback @ Value::BytecodeFunction { .. }) => callback.clone(),
+                    _ => return None,
+                };
+                if let Some(arity) = Interpreter::native_function_arity(name) {
+                    if let Err(message) = arity.validate(args.len()) {
+                        return Some(Err(message));
+                    }
+                }
+
+                let raised = match self.call_vm_operator_method(callback, Vec::new()) {
+                    Err(message) => Some(message),
+                    Ok(Value::Error(message)) | Ok(Value::ErrorObject { message, .. }) => {
+                        Some(message)
+                    }
+                    Ok(_) => None,
+                };
+                Some(match Interpreter::assert_raises_outcome(raised, args.get(1)) {
+                    Value::Error(message) => Err(message),
+                    outcome => Ok(outcome),
+                })
+            }
+            "bench" => {
+                if !matches!(args.get(1), Some(Value::BytecodeFunction { .. })) {
+                    return None;
+                }
+                if let Some(arity) = Interpreter::native_function_arity(name) {
+                    if let Err(message) = arity.validate(args.len()) {
+                        return Some(Err(message));
+                    }
+                }
+
+                let report = Interpreter::run_bench(args, |func| {
+                    match self.call_vm_operator_method(func.clone(), Vec::new()) {
+                        Err(message) => Err(message),
+                        Ok(Value::Error(message)) | Ok(Value::ErrorObject { message, .. }) => {
+                            Err(message)
+                        }
+                        Ok(_) => Ok(()),
+                    }
+                });
+                Some(match report {
+                    Value::Error(message) => Err(message),
+                    report => Ok(report),
+                })
+            }
             "map" => {
                 if args.len() < 2 {
                     return Some(Err("map requires two arguments: array and function".to_string()));
@@ -6850,6 +7987,7 @@ impl VM {
         function: Value,
         args: Vec<Value>,
     ) -> Result<Value, String> {
+        let function = crate::builtins::callable_namespace(function);
         match &function {
             Value::BytecodeFunction { chunk, captured: _, captured_binding_kinds: _ } => {
                 // OPTIMIZATION: Check if target function is JIT-compiled
@@ -6877,7 +8015,7 @@ impl VM {
                                     // Get globals pointer
                                     let globals_ptr: *mut HashMap<String, Value> = {
                                         let mut globals_guard = self.globals.lock().unwrap();
-                                        let ptr = &mut globals_guard.scopes[0]
+                                        let ptr = &mut globals_guard.globals
                                             as *mut HashMap<String, Value>;
                                         drop(globals_guard);
                                         ptr
@@ -6918,6 +8056,7 @@ impl VM {
                                         &mut vm_context,
                                         arg_val,
                                     );
+                                    self.mark_jit_globals_changed();
 
                                     if std::env::var("DEBUG_JIT").is_ok() {
                                         eprintln!(
@@ -7024,7 +8163,7 @@ impl VM {
                         // is single-threaded, we can safely use a raw pointer.
                         let globals_ptr: *mut HashMap<String, Value> = {
                             let mut globals_guard = self.globals.lock().unwrap();
-                            let ptr = &mut globals_guard.scopes[0] as *mut HashMap<String, Value>;
+                            let ptr = &mut globals_guard.globals as *mut HashMap<String, Value>;
                             // Explicitly drop to release lock before JIT execution
                             drop(globals_guard);
                             ptr
@@ -7089,6 +8228,7 @@ impl VM {
                         // Execute the compiled function!
                         // Lock is NOT held during execution to allow recursive calls
                         let result_code = invoke_compiled_fn(compiled_fn, &mut vm_context);
+                        self.mark_jit_globals_changed();
 
                         if result_code != 0 {
                             return Err(format!("JIT execution failed with code: {}", result_code));
@@ -7300,6 +8440,8 @@ impl VM {
                             self.stack.push(result);
                         }
 
+                        OpCode::BuildString(count) => self.build_string(count)?,
+
                         OpCode::Sub => {
                             let right = self.stack.pop().ok_or("Stack underflow")?;
                             let left = self.stack.pop().ok_or("Stack underflow")?;
@@ -7387,14 +8529,14 @@ impl VM {
                         OpCode::Equal => {
                             let right = self.stack.pop().ok_or("Stack underflow")?;
                             let left = self.stack.pop().ok_or("Stack underflow")?;
-                            let result = Value::Bool(self.values_equal(&left, &right));
+                            let result = self.equality_op(&left, "==", &right)?;
                             self.stack.push(result);
                         }
 
                         OpCode::NotEqual => {
                             let right = self.stack.pop().ok_or("Stack underflow")?;
                             let left = self.stack.pop().ok_or("Stack underflow")?;
-                            let result = Value::Bool(!self.values_equal(&left, &right));
+                            let result = self.equality_op(&left, "!=", &right)?;
                             self.stack.push(result);
                         }
 
@@ -7405,6 +8547,9 @@ impl VM {
                             if let Some(frame) = self.call_frames.pop() {
                                 // Pop from function call stack
                                 self.function_call_stack.pop();
+                                if self.recursion_depth > 0 {
+                                    self.recursion_depth -= 1;
+                                }
 
                                 // Restore saved state
                                 self.ip = saved_ip;
@@ -7423,6 +8568,9 @@ impl VM {
                         OpCode::ReturnNone => {
                             if let Some(frame) = self.call_frames.pop() {
                                 self.function_call_stack.pop();
+                                if self.recursion_depth > 0 {
+                                    self.recursion_depth -= 1;
+                                }
                                 self.ip = saved_ip;
                                 self.set_chunk(saved_chunk);
                                 self.stack.truncate(frame.stack_offset);
@@ -7454,9 +8602,19 @@ impl VM {
                             }
                             args.reverse();
 
+                            if let Some(Value::Module { exports, .. }) = args.first() {
+                                if let Some(export) = exports.get(&name).cloned() {
+                                    let result =
+                                        self.call_function_from_jit(export, args.split_off(1))?;
+                                    self.stack.push(result);
+                                    continue;
+                                }
+                            }
+
                             let result = match name.as_str() {
                                 "__vm_import_all" => self.vm_import_all(&args),
                                 "__vm_import_symbol" => self.vm_import_symbol(&args),
+                                "__vm_import_path" => self.vm_import_path(&args),
                                 _ => {
                                     let native_result =
                                         self.interpreter.call_native_function_impl(&name, &args);
@@ -7486,6 +8644,9 @@ impl VM {
                                             .ok_or_else(|| format!("Field not found: {}", field))?
                                     }
                                 }
+                                Value::Module { name, exports } => {
+                                    self.module_member_value(name, exports, &field)?
+                                }
                                 Value::Dict(dict) => {
                                     dict.get(field.as_str()).cloned().unwrap_or(Value::Null)
                                 }
@@ -7563,7 +8724,7 @@ impl VM {
                                 .get(slot)
                                 .cloned()
                                 .ok_or_else(|| format!("Invalid local slot: {}", slot))?;
-                            let result = Self::get_indexed_value(&object, &index)?;
+                            let result = self.index_value_vm(&object, &index)?;
 
                             self.stack.push(result);
                         }
@@ -7588,6 +8749,15 @@ impl VM {
             Value::Function(..) | Value::GeneratorDef(..) => {
                 self.call_interpreter_callable(&function, &args)
             }
+            Value::Decorated(decorated) => {
+                match Interpreter::call_decorated_impl(self, decorated, &args) {
+                    Value::Error(message) | Value::ErrorObject { message, .. } => Err(message),
+                    result => Ok(result),
+                }
+            }
+            Value::StructDef { name, field_names, .. } => {
+                Value::construct_struct(&name, &field_names, args)
+            }
             _ => Err(Self::non_callable_error_message("the value being called is not callable")),
         }
     }
@@ -7625,7 +8795,8 @@ impl VM {
     fn value_to_string(value: &Value) -> String {
         match value {
             Value::Int(n) => n.to_string(),
-            Value::Float(f) => f.to_string(),
+            Value::BigInt(n) => n.to_string(),
+            Value::Float(f) => Value::format_float(*f),
             Value::Str(s) => s.as_ref().clone(),
             Value::Bool(b) => b.to_string(),
             Value::Null => "null".to_string(),
@@ -7670,6 +8841,13 @@ impl VM {
                     .collect();
                 format!("{{{}}}", items.join(", "))
             }
+            Value::OrderedDict(map) => {
+                let items: Vec<String> = map
+                    .iter()
+                    .map(|(k, v)| format!("{}: {}", k, Self::value_to_string(v)))
+                    .collect();
+                format!("{{{}}}", items.join(", "))
+            }
             _ => format!("{:?}", value),
         }
     }
@@ -7683,7 +8861,7 @@ impl VM {
             Value::Array(_) => "array",
             Value::Dict(_) => "dict",
             Value::Struct { .. } => "struct",
-            Value::Function(..) => "function",
+            Value::Function(..) | Value::Decorated(_) => "function",
             Value::NativeFunction(_) => "native_function",
             Value::Null => "null",
             Value::Error(_) | Value::ErrorObject { .. } => "error",
@@ -7715,7 +8893,7 @@ impl VM {
         let method_global_name = format!("{}.{}", struct_name, method_name);
         let method_value = self.globals.lock().unwrap().get(&method_global_name)?;
 
-        Some(self.call_function_from_jit(method_value, vec![left.clone(), right.clone()]))
+        Some(self.call_vm_operator_method(method_value, vec![left.clone(), right.clone()]))
     }
 
     fn try_call_vm_unary_operator_method(
@@ -7731,22 +8909,144 @@ impl VM {
 
         let method_global_name = format!("{}.{}", struct_name, method_name);
         let method_value = self.globals.lock().unwrap().get(&method_global_name)?;
-        Some(self.call_function_from_jit(method_value, vec![value.clone()]))
+        Some(self.call_vm_operator_method(method_value, vec![value.clone()]))
+    }
+
+    /// Calls a struct's `to_string` method, if it defines one, for display natives.
+    fn try_call_vm_to_string_method(&mut self, value: &Value) -> Option<Result<Value, String>> {
+        let struct_name = match value {
+            Value::Struct { name, .. } => name,
+            _ => return None,
+        };
+
+        let method_global_name =
+            format!("{}.{}", struct_name, crate::ast::operator_methods::TO_STRING);
+        let method_value = self.globals.lock().unwrap().get(&method_global_name)?;
+        Some(self.call_vm_operator_method(method_value, vec![value.clone()]))
+    }
+
+    /// Feeds the profiler: due CPU samples go to the current Ruff stack, as does
+    /// each instruction that constructs an object.
+    fn record_profile_events(&mut self, instruction: &OpCode) {
+        let Some(profiler) = self.profiler.as_ref() else {
+            return;
+        };
+        let allocation = if profiler.tracks_allocations() {
+            Self::allocated_object_kind(instruction)
+        } else {
+            None
+        };
+        if !profiler.samples_due() && allocation.is_none() {
+            return;
+        }
+
+        let stack = self.current_stack_trace();
+        if let Some(profiler) = self.profiler.as_mut() {
+            profiler.record_cpu_samples(&stack);
+            if let Some(kind) = allocation {
+                profiler.record_allocation(&stack, kind);
+            }
+        }
+    }
+
+    fn allocated_object_kind(instruction: &OpCode) -> Option<&'static str> {
+        match instruction {
+            OpCode::MakeArray(_) | OpCode::MakeArrayFromMarker => Some("array"),
+            OpCode::MakeDict(_) | OpCode::MakeDictFromMarker | OpCode::MakeDictWithKeys(_) => {
+                Some("dict")
+            }
+            OpCode::MakeStruct(..) => Some("struct"),
+            OpCode::MakeClosure(_) => Some("closure"),
+            OpCode::MakeTagged(..) => Some("enum"),
+            OpCode::MakeIterator => Some("iterator"),
+            OpCode::MakeGenerator => Some("generator"),
+            OpCode::MakePromise => Some("promise"),
+            OpCode::MakeChannel => Some("channel"),
+            _ => None,
+        }
+    }
+
+    /// Runs a struct operator method to completion on the main dispatch loop.
+    /// `call_function_from_jit` only interprets a small opcode subset, so method
+    /// bodies that build collections, loop, or open block scopes need the full VM.
+    fn call_vm_operator_method(
+        &mut self,
+        method: Value,
+        args: Vec<Value>,
+    ) -> Result<Value, String> {
+        if !matches!(method, Value::BytecodeFunction { .. }) {
+            return self.call_function_from_jit(method, args);
+        }
+
+        let mut wrapper_chunk = BytecodeChunk::new();
+        wrapper_chunk.name = Some("__operator_method_wrapper".to_string());
+        wrapper_chunk.emit(OpCode::Call(args.len()));
+        wrapper_chunk.emit(OpCode::Return);
+
+        let saved_ip = self.ip;
+        let saved_chunk = self.replace_chunk(Arc::new(wrapper_chunk));
+        let saved_stack = std::mem::take(&mut self.stack);
+        let saved_call_frames = std::mem::take(&mut self.call_frames);
+        let saved_exception_handlers = std::mem::take(&mut self.exception_handlers);
+        let saved_recursion_depth = self.recursion_depth;
+
+        self.ip = 0;
+        self.stack.extend(args);
+        self.stack.push(method);
+        let result = self.run_instructions(false);
+
+        self.ip = saved_ip;
+        self.set_chunk(saved_chunk);
+        self.stack = saved_stack;
+        self.call_frames = saved_call_frames;
+        self.exception_handlers = saved_exception_handlers;
+        self.recursion_depth = saved_recursion_depth;
+
+        result
+    }
+
+    /// Index access that honours a struct's `op_index`/`__index__` method;
+    /// everything else goes straight to `get_indexed_value`.
+    fn index_value_vm(&mut self, object: &Value, index: &Value) -> Result<Value, String> {
+        if let Value::Iterator { source, .. } = object {
+            if let Value::BytecodeGenerator { .. } = source.as_ref() {
+                // A for-loop element read on a generator cursor takes the value `len` held
+                return match self.generator_next(source.as_ref().clone())? {
+                    Value::Option { is_some: true, value } => Ok(*value),
+                    _ => Err("generator exhausted during iteration".to_string()),
+                };
+            }
+        }
+        if let Value::Struct { name, .. } = object {
+            let method_global_name = format!("{}.{}", name, crate::ast::operator_methods::INDEX);
+            let method_value = self.globals.lock().unwrap().get(&method_global_name);
+            if let Some(method_value) = method_value {
+                return self
+                    .call_vm_operator_method(method_value, vec![object.clone(), index.clone()]);
+            }
+        }
+        Self::get_indexed_value(object, index)
     }
 
     /// Binary operation
     fn binary_op(&mut self, left: &Value, op: &str, right: &Value) -> Result<Value, String> {
+        // Small-int fast path: skip operator-method lookup; promotion to
+        // BigInt only happens once the checked i64 operation overflows.
+        if let (Value::Int(a), Value::Int(b)) = (left, right) {
+            if matches!(op, "+" | "-" | "*" | "/" | "%") {
+                return Value::promoting_int_arithmetic(*a, op, *b);
+            }
+        }
+
         if let Some(result) = self.try_call_vm_binary_operator_method(left, op, right) {
             return result;
         }
 
         match (left, right) {
-            (Value::Int(a), Value::Int(b)) => match op {
-                "+" | "-" | "*" | "/" | "%" => {
-                    Value::checked_int_arithmetic(*a, op, *b).map(Value::Int)
-                }
-                _ => Err(Self::invalid_binary_operation(op, left, right)),
-            },
+            (Value::BigInt(_), _) | (_, Value::BigInt(_)) => {
+                Value::big_int_binary_op(left, op, right)
+                    .unwrap_or_else(|| Err(Self::invalid_binary_operation(op, left, right)))
+            }
             (Value::Float(a), Value::Float(b)) => match op {
                 "+" | "-" | "*" | "/" | "%" => {
                     Value::checked_float_arithmetic(*a, op, *b).map(Value::Float)
@@ -7782,9 +9082,8 @@ impl VM {
         }
 
         match (op, value) {
-            ("-", Value::Int(n)) => {
-                n.checked_neg().map(Value::Int).ok_or_else(|| format!("Integer overflow: -({})", n))
-            }
+            ("-", Value::Int(_) | Value::BigInt(_)) => Value::negate_int(value)
+                .ok_or_else(|| format!("Invalid unary operation: {} {:?}", op, value)),
             ("-", Value::Float(f)) => Ok(Value::Float(-f)),
             ("!", Value::Bool(b)) => Ok(Value::Bool(!b)),
             _ => Err(format!("Invalid unary operation: {} {:?}", op, value)),
@@ -7792,10 +9091,32 @@ impl VM {
     }
 
     /// Comparison operation
-    fn compare_op(&self, left: &Value, op: &str, right: &Value) -> Result<Value, String> {
+    fn compare_op(&mut self, left: &Value, op: &str, right: &Value) -> Result<Value, String> {
+        if let Value::Struct { .. } = left {
+            if let Some(result) = self.try_call_vm_binary_operator_method(left, op, right) {
+                return result;
+            }
+        }
         Value::compare_order(left, op, right).map(Value::Bool)
     }
 
+    /// `==`/`!=` that consult a struct's `op_eq`/`op_ne` methods before falling
+    /// back to structural equality; `!=` negates `op_eq` when only that exists.
+    fn equality_op(&mut self, left: &Value, op: &str, right: &Value) -> Result<Value, String> {
+        if let Value::Struct { .. } = left {
+            if let Some(result) = self.try_call_vm_binary_operator_method(left, op, right) {
+                return result;
+            }
+            if op == "!=" {
+                if let Some(result) = self.try_call_vm_binary_operator_method(left, "==", right) {
+                    return result.map(|equal| Value::Bool(!equal.is_truthy()));
+                }
+            }
+        }
+        let equal = self.values_equal(left, right);
+        Ok(Value::Bool(if op == "==" { equal } else { !equal }))
+    }
+
     /// Check if value is truthy
     fn is_truthy(&self, value: &Value) -> bool {
         value.is_truthy()
@@ -7908,78 +9229,104 @@ impl VM {
         }
     }
 
-    fn match_case_pattern(&mut self, pattern: &str, value: &Value) -> bool {
-        let (case_tag, binding_name) = if let Some(open_paren) = pattern.find('(') {
-            if pattern.ends_with(')') {
-                let tag = pattern[..open_paren].trim();
-                let binding = pattern[open_paren + 1..pattern.len() - 1].trim();
-                if !binding.is_empty() {
-                    (tag.to_string(), Some(binding.to_string()))
-                } else {
-                    (tag.to_string(), None)
-                }
-            } else {
-                (pattern.trim().to_string(), None)
+    /// Bind a `let` destructuring pattern, mirroring the interpreter's `bind_pattern`:
+    /// array patterns without `...rest` require an exact length, and dict patterns bind
+    /// missing keys to null.
+    fn destructure_pattern(
+        &mut self,
+        pattern: &Pattern,
+        value: &Value,
+        binding_kind: BytecodeBindingKind,
+    ) -> Result<(), String> {
+        match pattern {
+            Pattern::Identifier(name) => {
+                self.bind_pattern_name(name, value.clone(), binding_kind);
+                Ok(())
             }
-        } else {
-            (pattern.trim().to_string(), None)
-        };
 
-        match value {
-            Value::Result { is_ok, value } => {
-                let short_tag = if *is_ok { "Ok" } else { "Err" };
-                let full_tag = format!("Result::{}", short_tag);
-                if case_tag == short_tag || case_tag == full_tag {
-                    if let Some(name) = binding_name {
-                        self.bind_pattern_name(
-                            name.as_str(),
-                            (**value).clone(),
-                            BytecodeBindingKind::Mutable,
-                        );
-                    }
-                    true
-                } else {
-                    false
-                }
-            }
-            Value::Option { is_some, value } => {
-                let short_tag = if *is_some { "Some" } else { "None" };
-                let full_tag = format!("Option::{}", short_tag);
-                if case_tag == short_tag || case_tag == full_tag {
-                    if let Some(name) = binding_name {
-                        if *is_some {
-                            self.bind_pattern_name(
-                                name.as_str(),
-                                (**value).clone(),
-                                BytecodeBindingKind::Mutable,
-                            );
-                        }
+            Pattern::Ignore => Ok(()),
+
+            Pattern::Array { elements, rest } => {
+                let Value::Array(arr) = value else {
+                    return Err(format!(
+                        "Cannot destructure {} with an array pattern",
+                        Self::value_type_name(value)
+                    ));
+                };
+
+                if rest.is_none() && arr.len() != elements.len() {
+                    return Err(format!(
+                        "Array destructuring expected {} elements but got {}",
+                        elements.len(),
+                        arr.len()
+                    ));
+                }
+                if arr.len() < elements.len() {
+                    return Err(format!(
+                        "Array destructuring expected at least {} elements but got {}",
+                        elements.len(),
+                        arr.len()
+                    ));
+                }
+
+                for (element_pattern, element) in elements.iter().zip(arr.iter()) {
+                    self.destructure_pattern(element_pattern, element, binding_kind)?;
+                }
+
+                if let Some(rest_name) = rest {
+                    let rest_values = arr[elements.len()..].to_vec();
+                    self.bind_pattern_name(
+                        rest_name,
+                        Value::Array(Arc::new(rest_values)),
+                        binding_kind,
+                    );
+                }
+                Ok(())
+            }
+
+            Pattern::Dict { keys, rest } => {
+                let entries = match value {
+                    Value::Dict(dict) => (**dict).clone(),
+                    Value::FixedDict { keys: dict_keys, values } => {
+                        dict_keys.iter().cloned().zip(values.iter().cloned()).collect()
+                    }
+                    Value::IntDict(dict) => dict
+                        .iter()
+                        .map(|(key, value)| (Arc::from(key.to_string().as_str()), value.clone()))
+                        .collect(),
+                    Value::DenseIntDict(values) => Self::dense_int_dict_to_dict(values),
+                    Value::DenseIntDictInt(values) => Self::dense_int_dict_int_to_dict(values),
+                    Value::DenseIntDictIntFull(values) => Self::dense_int_dict_to_dict(
+                        &Self::dense_int_dict_int_full_to_dense(values),
+                    ),
+                    other => {
+                        return Err(format!(
+                            "Cannot destructure {} with a dict pattern",
+                            Self::value_type_name(other)
+                        ));
                     }
-                    true
-                } else {
-                    false
+                };
+
+                for key in keys {
+                    let dict_value = entries.get(key.as_str()).cloned().unwrap_or(Value::Null);
+                    self.bind_pattern_name(key, dict_value, binding_kind);
                 }
-            }
-            Value::Tagged { tag, fields } => {
-                if case_tag == *tag {
-                    if let Some(name) = binding_name {
-                        if let Some(bound) = fields.get("$0") {
-                            self.bind_pattern_name(
-                                name.as_str(),
-                                bound.clone(),
-                                BytecodeBindingKind::Mutable,
-                            );
+
+                if let Some(rest_name) = rest {
+                    let mut rest_dict = DictMap::default();
+                    for (key, dict_value) in entries.iter() {
+                        if !keys.iter().any(|existing| existing.as_str() == key.as_ref()) {
+                            rest_dict.insert(key.clone(), dict_value.clone());
                         }
                     }
-                    true
-                } else {
-                    false
+                    self.bind_pattern_name(
+                        rest_name,
+                        Value::Dict(Arc::new(rest_dict)),
+                        binding_kind,
+                    );
                 }
+                Ok(())
             }
-            Value::Enum(enum_name) => case_tag == *enum_name,
-            Value::Str(s) => case_tag == s.as_ref().as_str(),
-            Value::Float(n) => case_tag == n.to_string(),
-            _ => false,
         }
     }
 
@@ -8001,332 +9348,105 @@ impl VM {
     /// Execute generator until next yield or completion
     /// Returns Some(value) if yielded, None if exhausted
     pub fn generator_next(&mut self, generator: Value) -> Result<Value, String> {
-        if let Value::BytecodeGenerator { state } = generator {
-            let gen_state = state.lock().unwrap();
-
-            // Check if generator is exhausted
-            if gen_state.is_exhausted {
-                return Ok(Value::Option { is_some: false, value: Box::new(Value::Null) });
-            }
-
-            // Save current VM state
-            let saved_ip = self.ip;
-            let saved_chunk = self.chunk.clone();
-            let saved_stack = self.stack.clone();
-            let saved_frames = self.call_frames.clone();
-
-            // Restore generator state
-            self.ip = gen_state.ip;
-            self.set_chunk(gen_state.chunk.clone());
-            self.stack = gen_state.stack.clone();
-
-            // Restore call frames
-            self.call_frames.clear();
-            for frame_data in &gen_state.call_frames_data {
-                self.call_frames.push(CallFrame {
-                    return_ip: frame_data.return_ip,
-                    stack_offset: frame_data.stack_offset,
-                    locals: frame_data.locals.clone(),
-                    locals_binding_kinds: frame_data.locals_binding_kinds.clone(),
-                    local_slots: frame_data.local_slots.clone(),
-                    local_slot_binding_kinds: frame_data.local_slot_binding_kinds.clone(),
-                    local_slot_initialized: frame_data.local_slot_initialized.clone(),
-                    captured: frame_data.captured.clone(),
-                    captured_binding_kinds: frame_data.captured_binding_kinds.clone(),
-                    prev_chunk: None,
-                    is_async: false, // Generators are not async
-                });
-            }
-
-            // Drop the lock before executing
-            drop(gen_state);
+        let Value::BytecodeGenerator { state } = generator else {
+            return Err("generator_next() requires a BytecodeGenerator".to_string());
+        };
 
-            // Execute until yield or completion
-            let result = loop {
-                if self.ip >= self.chunk.instructions.len() {
-                    // Generator completed without explicit return
-                    break Ok(Value::Option { is_some: false, value: Box::new(Value::Null) });
-                }
+        let mut gen_state = state.lock().unwrap();
+        if let Some(value) = gen_state.peeked.take() {
+            return Ok(Value::Option { is_some: true, value: Box::new(value) });
+        }
+        if gen_state.is_exhausted {
+            return Ok(Value::Option { is_some: false, value: Box::new(Value::Null) });
+        }
+        if gen_state.is_running {
+            return Err("generator is already running".to_string());
+        }
+        gen_state.is_running = true;
+
+        // The generator body runs as its own program: returning from its first frame
+        // runs off the end of the chunk instead of jumping back into the caller.
+        let chunk = gen_state.chunk.clone();
+        let mut frames = std::mem::take(&mut gen_state.call_frames);
+        if let Some(body_frame) = frames.first_mut() {
+            body_frame.return_ip = chunk.instructions.len();
+            body_frame.prev_chunk = None;
+        }
+        let ip = gen_state.ip;
+        let stack = std::mem::take(&mut gen_state.stack);
+        let handlers = std::mem::take(&mut gen_state.exception_handlers);
+        // Drop the lock before executing so the body can see the generator's own state
+        drop(gen_state);
 
-                let instruction = self.chunk.instructions[self.ip].clone();
-                self.ip += 1;
+        let saved_ip = self.ip;
+        let saved_chunk = self.chunk.clone();
+        let saved_stack = std::mem::replace(&mut self.stack, stack);
+        let saved_call_frames = std::mem::replace(&mut self.call_frames, frames);
+        let saved_exception_handlers = std::mem::replace(&mut self.exception_handlers, handlers);
+        let mut function_call_stack = self.function_call_stack.clone();
+        function_call_stack.push(chunk.name.clone().unwrap_or_else(|| "<generator>".to_string()));
+        let saved_function_call_stack =
+            std::mem::replace(&mut self.function_call_stack, function_call_stack);
+        let saved_recursion_depth = self.recursion_depth;
+        let saved_max_recursion_depth = self.max_recursion_depth;
+        let saved_yield = self.generator_yield.take();
+
+        self.ip = ip;
+        self.set_chunk(chunk);
+        self.generator_depth += 1;
+        let result = self.run_catching();
+        self.generator_depth -= 1;
+        let yielded = std::mem::replace(&mut self.generator_yield, saved_yield);
+
+        let mut gen_state = state.lock().unwrap();
+        gen_state.is_running = false;
+        if yielded.is_some() && result.is_ok() {
+            gen_state.ip = self.ip;
+            gen_state.stack = std::mem::take(&mut self.stack);
+            gen_state.call_frames = std::mem::take(&mut self.call_frames);
+            gen_state.exception_handlers = std::mem::take(&mut self.exception_handlers);
+        } else {
+            gen_state.is_exhausted = true;
+        }
+        drop(gen_state);
 
-                // Check for Yield opcode
-                if matches!(instruction, OpCode::Yield) {
-                    // Peek the yielded value but keep it on the stack.
-                    // Expression-statement lowering emits a following Pop; preserving the
-                    // value here keeps stack shape aligned for the resumed instruction stream.
-                    let yielded_value =
-                        self.stack.last().cloned().ok_or("Stack underflow in Yield")?;
-                    let has_loop_backedge =
-                        self.chunk.instructions.iter().any(|op| matches!(op, OpCode::JumpBack(_)));
+        self.ip = saved_ip;
+        self.set_chunk(saved_chunk);
+        self.stack = saved_stack;
+        self.call_frames = saved_call_frames;
+        self.exception_handlers = saved_exception_handlers;
+        self.function_call_stack = saved_function_call_stack;
+        self.recursion_depth = saved_recursion_depth;
+        self.max_recursion_depth = saved_max_recursion_depth;
 
-                    // Preserve current interpreter parity for loop-bodied generators:
-                    // generator state advances at statement granularity, so a yield inside
-                    // a loop body exhausts after the first yielded value.
-                    if has_loop_backedge {
-                        let mut gen_state = state.lock().unwrap();
-                        gen_state.is_exhausted = true;
-                    } else {
-                        // Save current state back to generator
-                        let mut gen_state = state.lock().unwrap();
-                        gen_state.ip = self.ip;
-                        gen_state.stack = self.stack.clone();
-
-                        // Save call frames
-                        gen_state.call_frames_data.clear();
-                        for frame in &self.call_frames {
-                            gen_state.call_frames_data.push(CallFrameData {
-                                return_ip: frame.return_ip,
-                                stack_offset: frame.stack_offset,
-                                locals: frame.locals.clone(),
-                                locals_binding_kinds: frame.locals_binding_kinds.clone(),
-                                local_slots: frame.local_slots.clone(),
-                                local_slot_binding_kinds: frame.local_slot_binding_kinds.clone(),
-                                local_slot_initialized: frame.local_slot_initialized.clone(),
-                                captured: frame.captured.clone(),
-                                captured_binding_kinds: frame.captured_binding_kinds.clone(),
-                            });
-                        }
-                    }
-
-                    // Restore original VM state
-                    self.ip = saved_ip;
-                    self.set_chunk(saved_chunk);
-                    self.stack = saved_stack;
-                    self.call_frames = saved_frames;
-
-                    // Return the yielded value
-                    break Ok(Value::Option { is_some: true, value: Box::new(yielded_value) });
-                }
-
-                // Check for Return opcodes (generator completed)
-                if matches!(instruction, OpCode::Return | OpCode::ReturnNone) {
-                    let mut gen_state = state.lock().unwrap();
-                    gen_state.is_exhausted = true;
-                    drop(gen_state);
-
-                    // Restore original VM state
-                    self.ip = saved_ip;
-                    self.set_chunk(saved_chunk);
-                    self.stack = saved_stack;
-                    self.call_frames = saved_frames;
-
-                    break Ok(Value::Option { is_some: false, value: Box::new(Value::Null) });
-                }
-
-                // Execute the instruction normally (by backing up IP and calling execute on single instruction)
-                // This is inefficient but simple - a better approach would be to extract instruction execution
-                // For now, we'll manually handle key instructions
-                match instruction {
-                    OpCode::LoadConst(index) => {
-                        let constant = &self.chunk.constants[index];
-                        let value = self.constant_to_value(constant)?;
-                        self.stack.push(value);
-                    }
-                    OpCode::LoadVar(name) => {
-                        let value = if let Some(frame) = self.call_frames.last() {
-                            frame
-                                .captured
-                                .get(&name)
-                                .map(|r| r.lock().unwrap().clone())
-                                .or_else(|| frame.locals.get(&name).cloned())
-                        } else {
-                            None
-                        };
+        result?;
+        Ok(match yielded {
+            Some(value) => Value::Option { is_some: true, value: Box::new(value) },
+            None => Value::Option { is_some: false, value: Box::new(Value::Null) },
+        })
+    }
+}
 
-                        let value = value
-                            .or_else(|| self.globals.lock().unwrap().get(&name))
-                            .ok_or_else(|| Self::undefined_variable_message(&name))?;
-                        self.stack.push(value);
-                    }
-                    OpCode::LoadLocal(slot) => {
-                        let frame =
-                            self.call_frames.last().ok_or("LoadLocal requires call frame")?;
-                        let value = frame
-                            .local_slots
-                            .get(slot)
-                            .cloned()
-                            .ok_or_else(|| format!("Invalid local slot: {}", slot))?;
-                        self.stack.push(value);
-                    }
-                    OpCode::StoreVar(name) => {
-                        let value = self.stack.last().ok_or("Stack underflow")?.clone();
-                        let global_exists = self.globals.lock().unwrap().get(&name).is_some();
-                        let mut assign_global = false;
-                        if let Some(frame) = self.call_frames.last_mut() {
-                            if let Some(captured_ref) = frame.captured.get(&name) {
-                                if let Some(kind) = frame.captured_binding_kinds.get(&name).copied()
-                                {
-                                    if !matches!(kind, BytecodeBindingKind::Mutable) {
-                                        return Err(Self::local_reassignment_error(kind, &name));
-                                    }
-                                }
-                                *captured_ref.lock().unwrap() = value.clone();
-                            } else if frame.locals.contains_key(&name) {
-                                if let Some(kind) = frame.locals_binding_kinds.get(&name).copied() {
-                                    if !matches!(kind, BytecodeBindingKind::Mutable) {
-                                        return Err(Self::local_reassignment_error(kind, &name));
-                                    }
-                                }
-                                frame.locals.insert(name.clone(), value.clone());
-                            } else if global_exists {
-                                assign_global = true;
-                            } else {
-                                frame
-                                    .locals_binding_kinds
-                                    .entry(name.clone())
-                                    .or_insert(BytecodeBindingKind::Mutable);
-                                frame.locals.insert(name.clone(), value.clone());
-                            }
-                        } else {
-                            assign_global = true;
-                        }
+impl DecoratorHost for VM {
+    fn call_decorated_function(&mut self, function: &Value, args: &[Value]) -> Value {
+        self.call_vm_operator_method(function.clone(), args.to_vec()).unwrap_or_else(Value::Error)
+    }
 
-                        if assign_global {
-                            self.globals.lock().unwrap().assign_checked(name, value)?;
-                        }
-                    }
-                    OpCode::StoreLocal(slot) => {
-                        let value = self.stack.last().ok_or("Stack underflow")?.clone();
-                        let frame =
-                            self.call_frames.last_mut().ok_or("StoreLocal requires call frame")?;
-                        if let Some(target) = frame.local_slots.get_mut(slot) {
-                            let kind = frame
-                                .local_slot_binding_kinds
-                                .get(slot)
-                                .copied()
-                                .unwrap_or(BytecodeBindingKind::Mutable);
-                            let initialized =
-                                frame.local_slot_initialized.get(slot).copied().unwrap_or(false);
-                            if initialized && !matches!(kind, BytecodeBindingKind::Mutable) {
-                                return Err(Self::local_reassignment_error(
-                                    kind,
-                                    "<generator-local>",
-                                ));
-                            }
-                            *target = value;
-                            if let Some(initialized_flag) =
-                                frame.local_slot_initialized.get_mut(slot)
-                            {
-                                *initialized_flag = true;
-                            }
-                        } else {
-                            return Err(format!("Invalid local slot: {}", slot));
-                        }
-                    }
-                    OpCode::Pop => {
-                        self.stack.pop().ok_or("Stack underflow")?;
-                    }
-                    OpCode::PushScope => {
-                        self.globals.lock().unwrap().push_scope();
-                    }
-                    OpCode::PopScope => {
-                        self.globals.lock().unwrap().pop_scope();
-                    }
-
-                    // Arithmetic operations
-                    OpCode::Add => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.binary_op(&left, "+", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::Sub => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.binary_op(&left, "-", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::Mul => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.binary_op(&left, "*", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::Div => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.binary_op(&left, "/", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::Mod => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.binary_op(&left, "%", &right)?;
-                        self.stack.push(result);
-                    }
-
-                    // Comparison operations
-                    OpCode::Equal => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        self.stack.push(Value::Bool(self.values_equal(&left, &right)));
-                    }
-                    OpCode::NotEqual => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        self.stack.push(Value::Bool(!self.values_equal(&left, &right)));
-                    }
-                    OpCode::LessThan => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.compare_op(&left, "<", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::GreaterThan => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.compare_op(&left, ">", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::LessEqual => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.compare_op(&left, "<=", &right)?;
-                        self.stack.push(result);
-                    }
-                    OpCode::GreaterEqual => {
-                        let right = self.stack.pop().ok_or("Stack underflow")?;
-                        let left = self.stack.pop().ok_or("Stack underflow")?;
-                        let result = self.compare_op(&left, ">=", &right)?;
-                        self.stack.push(result);
-                    }
-
-                    // Control flow
-                    OpCode::Jump(target) => {
-                        self.ip = target;
-                    }
-                    OpCode::JumpIfFalse(target) => {
-                        let condition = self.stack.last().ok_or("Stack underflow")?;
-                        if !self.is_truthy(condition) {
-                            self.ip = target;
-                        }
-                    }
-                    OpCode::JumpIfTrue(target) => {
-                        let condition = self.stack.last().ok_or("Stack underflow")?;
-                        if self.is_truthy(condition) {
-                            self.ip = target;
-                        }
-                    }
-                    OpCode::JumpBack(target) => {
-                        self.ip = target;
-                    }
+    fn write_decorator_output(&mut self, line: &str) {
+        self.interpreter.write_error_output(line);
+    }
+}
 
-                    // For now, return error for other unhandled instructions
-                    // Full implementation would need to handle all opcodes
-                    _ => {
-                        return Err(format!(
-                            "Instruction {:?} not yet handled in generator execution",
-                            instruction
-                        ));
-                    }
-                }
-            };
+impl SequenceHost for VM {
+    fn call_sequence_callback(&mut self, func: &Value, args: Vec<Value>) -> Result<Value, String> {
+        self.call_function_from_jit(func.clone(), args)
+    }
 
-            result
-        } else {
-            Err("generator_next() requires a BytecodeGenerator".to_string())
+    fn step_generator(&mut self, generator: &mut Value) -> Result<Option<Value>, String> {
+        match self.generator_next(generator.clone())? {
+            Value::Option { is_some: true, value } => Ok(Some(*value)),
+            Value::Option { is_some: false, .. } => Ok(None),
+            other => Err(format!("Generator step returned {:?} instead of an Option", other)),
         }
     }
 }
@@ -8358,6 +9478,21 @@ mod tests {
         vm.execute(chunk)
     }
 
+    #[test]
+    fn test_error_stack_trace_reports_call_sites_after_void_calls_return() {
+        let code = "func note() {\n    x := 1\n}\nfunc fail(n) {\n    note()\n    return n / 0\n}\nfail(1)\n";
+        let tokens = lexer::tokenize(code).expect("test source should tokenize");
+        let ast = Parser::new(tokens).with_source_positions().parse();
+        let chunk = Compiler::new().compile(&ast).expect("compile should succeed");
+
+        let mut vm = VM::new();
+        assert!(vm.execute(chunk).is_err());
+        assert_eq!(
+            vm.error_stack_trace(),
+            vec![StackFrame::new("<main>", Some((8, 1))), StackFrame::new("fail", Some((6, 5)))]
+        );
+    }
+
     fn run_vm_code_with_natives(code: &str, native_names: &[&str]) -> Result<Value, String> {
         let tokens = lexer::tokenize(code).map_err(|diagnostics| {
             diagnostics
@@ -8480,7 +9615,7 @@ mod tests {
             .constants
             .iter()
             .find_map(|constant| match constant {
-                Constant::Function(function_chunk) => Some((**function_chunk).clone()),
+                Constant::Function(function_chunk) => Some(function_chunk.clone()),
                 _ => None,
             })
             .expect("expected compiled function constant");
@@ -8504,6 +9639,34 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_jit_hot_function_global_writes_invalidate_cached_reads() {
+        let chunk = compile_chunk(
+            r#"
+            mut counter := 0
+            func bump(step) {
+                counter = counter + step
+                return counter
+            }
+
+            mut last := 0
+            for i in range(0, 300) {
+                bump(1)
+                last = [counter][0]
+            }
+            return last * 1000 + counter
+            "#,
+        );
+
+        let mut vm = VM::new();
+        vm.set_jit_enabled(true);
+        match vm.execute(chunk) {
+            Ok(Value::Int(value)) => assert_eq!(value, 300_300),
+            Ok(other) => panic!("Expected int 300300, got {:?}", other),
+            Err(error) => panic!("Expected success, got VM error: {}", error),
+        }
+    }
+
     #[test]
     fn test_async_function_definition() {
         let code = r#"
@@ -8532,7 +9695,7 @@ mod tests {
 
         vm.ip = 7;
         vm.stack = vec![Value::Int(99), Value::Bool(true)];
-        vm.chunk = snapshot_chunk.clone();
+        vm.chunk = Arc::new(snapshot_chunk.clone());
         vm.upvalues =
             vec![Upvalue { value: Arc::new(Mutex::new(Value::Int(123))), is_closed: true }];
         vm.exception_handlers =
@@ -8562,7 +9725,8 @@ mod tests {
             local_slot_initialized: vec![true, true],
             captured: HashMap::new(),
             captured_binding_kinds: HashMap::new(),
-            prev_chunk: Some(frame_chunk),
+            pending_captured: HashMap::new(),
+            prev_chunk: Some(Arc::new(frame_chunk)),
             is_async: false,
         });
 
@@ -8576,7 +9740,7 @@ mod tests {
         vm.ip = 0;
         vm.stack.clear();
         vm.call_frames.clear();
-        vm.chunk = BytecodeChunk::new();
+        vm.chunk = Arc::new(BytecodeChunk::new());
         vm.upvalues.clear();
         vm.exception_handlers.clear();
         vm.function_call_stack.clear();
@@ -8650,7 +9814,7 @@ mod tests {
             ip: 0,
             stack: Vec::new(),
             call_frames: Vec::new(),
-            chunk: BytecodeChunk::new(),
+            chunk: Arc::new(BytecodeChunk::new()),
             upvalues: Vec::new(),
             exception_handlers: Vec::new(),
             function_call_stack: Vec::new(),
@@ -8865,7 +10029,7 @@ mod tests {
             ip: 0,
             stack: vec![Value::Int(11)],
             call_frames: Vec::new(),
-            chunk: chunk_a,
+            chunk: Arc::new(chunk_a),
             upvalues: Vec::new(),
             exception_handlers: Vec::new(),
             function_call_stack: Vec::new(),
@@ -8885,7 +10049,7 @@ mod tests {
             ip: 0,
             stack: vec![Value::Int(22)],
             call_frames: Vec::new(),
-            chunk: chunk_b,
+            chunk: Arc::new(chunk_b),
             upvalues: Vec::new(),
             exception_handlers: Vec::new(),
             function_call_stack: Vec::new(),
@@ -9483,6 +10647,95 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_vm_early_returns_unwind_block_scopes() {
+        let code = r#"
+            func fib(n) {
+                if n <= 1 {
+                    return n
+                }
+                return fib(n - 1) + fib(n - 2)
+            }
+
+            func first_over(limit) {
+                i := 0
+                while true {
+                    i := i + 1
+                    if i % 2 == 1 {
+                        if i > limit {
+                            return i
+                        }
+                    }
+                }
+            }
+
+            return fib(12) + first_over(9)
+        "#;
+
+        let tokens = lexer::tokenize(code).expect("test source should tokenize");
+        let mut parser = Parser::new(tokens);
+        let ast = parser.parse();
+        let chunk = Compiler::new().compile(&ast).expect("compile should succeed");
+
+        let mut vm = VM::new();
+        let scopes_before = vm.globals.lock().unwrap().depth();
+        let result = vm.execute(chunk).expect("VM should execute early-return program");
+
+        assert!(matches!(result, Value::Int(155)), "unexpected result: {:?}", result);
+        assert_eq!(
+            vm.globals.lock().unwrap().depth(),
+            scopes_before,
+            "returns inside blocks must not leak environment scopes"
+        );
+    }
+
+    #[test]
+    fn test_vm_break_and_continue_unwind_block_scopes() {
+        let code = r#"
+            total := 0
+            i := 0
+            outer: while i < 4 {
+                i := i + 1
+                j := 0
+                while j < 4 {
+                    j := j + 1
+                    if j == 2 {
+                        if i == 1 {
+                            continue
+                        }
+                    }
+                    if j == 3 {
+                        if i == 2 {
+                            continue outer
+                        }
+                        if i == 4 {
+                            break outer
+                        }
+                    }
+                    total := total + 1
+                }
+            }
+            return total
+        "#;
+
+        let tokens = lexer::tokenize(code).expect("test source should tokenize");
+        let mut parser = Parser::new(tokens);
+        let ast = parser.parse();
+        let chunk = Compiler::new().compile(&ast).expect("compile should succeed");
+
+        let mut vm = VM::new();
+        let scopes_before = vm.globals.lock().unwrap().depth();
+        let result = vm.execute(chunk).expect("VM should execute loop-control program");
+
+        // i=1: j=1,3,4; i=2: j=1,2; i=3: j=1..4; i=4: j=1,2
+        assert!(matches!(result, Value::Int(11)), "unexpected result: {:?}", result);
+        assert_eq!(
+            vm.globals.lock().unwrap().depth(),
+            scopes_before,
+            "break/continue inside blocks must not leak environment scopes"
+        );
+    }
+
     #[test]
     fn test_vm_recursion_exceeding_limit_errors() {
         let code = r#"
@@ -9502,6 +10755,80 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_vm_execution_budget_limits_call_depth_and_time_through_try() {
+        let compile = |code: &str| {
+            Compiler::new().compile(&Parser::new(lexer::tokenize(code).unwrap()).parse()).unwrap()
+        };
+        let budget = |limits| Some(runtime_limits::ExecutionBudget::start(limits));
+
+        let mut vm = VM::new();
+        vm.set_execution_budget(budget(runtime_limits::ExecutionLimits {
+            max_call_depth: Some(5),
+            ..Default::default()
+        }));
+        let error = vm
+            .execute(compile(
+                "func down(n) {\n if n == 0 { return 0 }\n return 1 + down(n - 1)\n}\nreturn down(10)",
+            ))
+            .expect_err("depth 10 exceeds the limit of 5");
+        assert!(error.contains("Maximum VM call stack depth of 5 exceeded"), "{}", error);
+
+        let mut vm = VM::new();
+        vm.set_execution_budget(budget(runtime_limits::ExecutionLimits {
+            timeout: Some(std::time::Duration::from_millis(50)),
+            ..Default::default()
+        }));
+        let error = vm
+            .execute(compile("while true {\n try {\n x := 1\n } except e {\n x := 2\n }\n}"))
+            .expect_err("the loop should be stopped");
+        assert_eq!(error, "Execution time limit of 50ms exceeded");
+    }
+
+    #[test]
+    fn test_vm_reuses_frame_storage_without_leaking_locals() {
+        let mut vm = VM::new();
+        let result = vm
+            .execute(compile_chunk(
+                r#"
+                func fib(n) {
+                    if n < 2 { return n }
+                    return fib(n - 1) + fib(n - 2)
+                }
+                func pair(a, b) {
+                    first := a * 10
+                    second := b
+                    return first + second
+                }
+                func fresh() {
+                    seen := 0
+                    seen := seen + 1
+                    return seen
+                }
+                total := 0
+                i := 0
+                while i < 50 {
+                    total := total + pair(i, 1) + fresh()
+                    i := i + 1
+                }
+                return [fib(15), total]
+                "#,
+            ))
+            .expect("program should run");
+        let Value::Array(items) = result else {
+            panic!("expected an array, got {:?}", result);
+        };
+        assert!(matches!(items.as_slice(), [Value::Int(610), Value::Int(12350)]), "{:?}", items);
+        assert!(!vm.frame_pool.is_empty(), "returned frames should be pooled");
+        assert!(vm.frame_pool.len() <= FRAME_POOL_LIMIT);
+        assert!(vm
+            .frame_pool
+            .iter()
+            .all(|storage| storage.locals.is_empty() && storage.local_slots.is_empty()));
+        // Function values share bytecode instead of embedding it.
+        assert!(std::mem::size_of::<Value>() <= 128, "{}", std::mem::size_of::<Value>());
+    }
+
     #[test]
     fn test_cooperative_suspend_enabled_by_default() {
         let vm = VM::new();
@@ -0,0 +1,214 @@
+// File: src/wasm.rs
+//
+// Browser runtime for the WebAssembly build.
+//
+// `BrowserSession` is the host side of `tools/ruff-wasm/ruff.js`: it evaluates
+// source in one persistent `Ruff` runtime and reports the result together with the
+// `print`/`eprint` output the evaluation produced, in order, so the shim can hand
+// each chunk to its stdout and stderr callbacks. Scripts may read the clock and
+// random numbers, and nothing else: a page has no filesystem, processes, or sockets,
+// so those natives fail with the usual capability error rather than an opaque
+// platform one.
+//
+// On `wasm32` targets the `exports` module exposes the session to JavaScript through
+// buffers that this module owns: JS copies UTF-8 source into the buffer returned by
+// `ruff_input_buffer(len)`, calls `ruff_eval()` for the byte length of the JSON
+// response, and reads the response from `ruff_output_buffer()`. No host pointers are
+// dereferenced on the Rust side.
+
+use crate::embed::Ruff;
+use crate::interpreter::{Interpreter, OutputSink, RuntimeCapabilityPolicy, Value};
+use serde::Serialize;
+use std::sync::{Arc, Mutex};
+
+/// One chunk of script output, tagged with the stream it was written to.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct OutputEvent {
+    pub stream: &'static str,
+    pub text: String,
+}
+
+/// Outcome of one `evalRuff` call.
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct EvalResponse {
+    pub events: Vec<OutputEvent>,
+    /// The final expression's value as `print` would show it; `None` for `null`.
+    pub value: Option<String>,
+    /// The error message, prefixed with `line N:` when the position is known.
+    pub error: Option<String>,
+}
+
+/// Output captured by a session, shared so a host can report it when a run is cut short.
+#[derive(Default)]
+pub struct EventLog {
+    events: Mutex<Vec<OutputEvent>>,
+}
+
+impl EventLog {
+    fn push(&self, stream: &'static str, text: &str) {
+        let mut events = self.events.lock().unwrap_or_else(|poisoned| poisoned.into_inner());
+        // Consecutive writes to one stream become a single callback
+        match events.last_mut() {
+            Some(last) if last.stream == stream => last.text.push_str(text),
+            _ => events.push(OutputEvent { stream, text: text.to_string() }),
+        }
+    }
+
+    /// Removes and returns everything written since the last call.
+    pub fn take(&self) -> Vec<OutputEvent> {
+        std::mem::take(&mut *self.events.lock().unwrap_or_else(|poisoned| poisoned.into_inner()))
+    }
+}
+
+impl OutputSink for EventLog {
+    fn write_stdout(&self, text: &str) {
+        self.push("stdout", text);
+    }
+
+    fn write_stderr(&self, text: &str) {
+        self.push("stderr", text);
+    }
+}
+
+/// A Ruff runtime whose globals persist across evaluations, like a REPL.
+pub struct BrowserSession {
+    ruff: Ruff,
+    log: Arc<EventLog>,
+}
+
+impl BrowserSession {
+    pub fn new() -> Self {
+        Self::with_capability_policy(RuntimeCapabilityPolicy {
+            clock: true,
+            random: true,
+            ..RuntimeCapabilityPolicy::restricted()
+        })
+    }
+
+    /// Session with a custom policy, e.g. for hosts that provide a virtual filesystem
+    pub fn with_capability_policy(policy: RuntimeCapabilityPolicy) -> Self {
+        let log = Arc::new(EventLog::default());
+        let mut ruff = Ruff::with_capability_policy(policy);
+        ruff.set_output_sink(log.clone());
+        Self { ruff, log }
+    }
+
+    pub fn output_log(&self) -> Arc<EventLog> {
+        self.log.clone()
+    }
+
+    pub fn eval(&mut self, source: &str) -> EvalResponse {
+        let result = self.ruff.eval(source);
+        let events = self.log.take();
+        match result {
+            Ok(Value::Null) => EvalResponse { events, value: None, error: None },
+            Ok(value) => EvalResponse {
+                events,
+                value: Some(Interpreter::stringify_value(&value)),
+                error: None,
+            },
+            Err(error) => {
+                let message = if error.location.line > 0 {
+                    format!("line {}: {}", error.location.line, error.message)
+                } else {
+                    error.message.clone()
+                };
+                EvalResponse { events, value: None, error: Some(message) }
+            }
+        }
+    }
+}
+
+impl Default for BrowserSession {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+#[cfg(target_arch = "wasm32")]
+mod exports {
+    use super::BrowserSession;
+    use std::cell::RefCell;
+
+    thread_local! {
+        static SESSION: RefCell<Option<BrowserSession>> = const { RefCell::new(None) };
+        static INPUT: RefCell<Vec<u8>> = const { RefCell::new(Vec::new()) };
+        static OUTPUT: RefCell<Vec<u8>> = const { RefCell::new(Vec::new()) };
+    }
+
+    /// Sizes the source buffer to `len` bytes and returns its address for JS to fill.
+    #[no_mangle]
+    pub extern "C" fn ruff_input_buffer(len: usize) -> *mut u8 {
+        INPUT.with(|input| {
+            let mut input = input.borrow_mut();
+            input.clear();
+            input.resize(len, 0);
+            input.as_mut_ptr()
+        })
+    }
+
+    /// Evaluates the source buffer and returns the length of the JSON response.
+    #[no_mangle]
+    pub extern "C" fn ruff_eval() -> usize {
+        let source = INPUT.with(|input| String::from_utf8_lossy(&input.borrow()).into_owned());
+        let response = SESSION.with(|session| {
+            session.borrow_mut().get_or_insert_with(BrowserSession::new).eval(&source)
+        });
+        let json = serde_json::to_vec(&response).unwrap_or_default();
+        OUTPUT.with(|output| {
+            *output.borrow_mut() = json;
+            output.borrow().len()
+        })
+    }
+
+    /// Address of the response written by the last `ruff_eval`.
+    #[no_mangle]
+    pub extern "C" fn ruff_output_buffer() -> *const u8 {
+        OUTPUT.with(|output| output.borrow().as_ptr())
+    }
+
+    /// Drops every global so the next evaluation starts from a fresh runtime.
+    #[no_mangle]
+    pub extern "C" fn ruff_reset() {
+        SESSION.with(|session| *session.borrow_mut() = None);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn sessions_keep_globals_and_report_output_in_order() {
+        let mut session = BrowserSession::new();
+        let defined =
+            session.eval("base := 40\nprint(\"a\")\nprint(\"b\")\neprint(\"warn\")\nprint(\"c\")");
+        assert_eq!(
+            defined.events,
+            vec![
+                OutputEvent { stream: "stdout", text: "a\nb\n".to_string() },
+                OutputEvent { stream: "stderr", text: "warn\n".to_string() },
+                OutputEvent { stream: "stdout", text: "c\n".to_string() },
+            ]
+        );
+        assert_eq!(defined.value, None);
+
+        let value = session.eval("[base + 2, \"x\"]");
+        assert_eq!(value.value.as_deref(), Some("[42, x]"));
+        assert!(value.events.is_empty(), "each response carries only its own output");
+    }
+
+    #[test]
+    fn errors_and_denied_capabilities_are_reported() {
+        let mut session = BrowserSession::new();
+        let failed = session.eval("print(\"before\")\nx := 1 / 0");
+        assert_eq!(failed.events[0].text, "before\n");
+        let error = failed.error.expect("division by zero should fail");
+        assert!(error.starts_with("line 2: "), "{}", error);
+
+        let denied = session.eval("read_file(\"/etc/hostname\")");
+        let error = denied.error.expect("filesystem access should be denied");
+        assert!(error.contains("--allow-fs-read"), "{}", error);
+        assert!(session.eval("now_unix() > 0").error.is_none(), "the clock stays available");
+    }
+}
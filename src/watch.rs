@@ -0,0 +1,218 @@
+// File: src/watch.rs
+//
+// `ruff run --watch`: re-run a script whenever its sources change.
+//
+// The watcher runs the script in a child `ruff run` process and polls the modification
+// time and size of the entry script and every `.ruff` file under its directory. A change
+// restarts the child, except that an HTTP server started under the watcher reloads changed
+// modules itself before its next request (see `ModuleLoader::reload_changed_modules`) and
+// tells the watcher so through a marker file; the watcher then keeps it running, with its
+// listener open, unless the entry script itself changed.
+
+use std::collections::BTreeMap;
+use std::ffi::OsString;
+use std::fs;
+use std::path::{Path, PathBuf};
+use std::process::{Child, Command, ExitStatus};
+use std::thread;
+use std::time::{Duration, SystemTime};
+
+/// Set for a script run under the watcher: the marker file an HTTP server creates once it
+/// reloads changed modules in place.
+pub const HOT_RELOAD_ENV: &str = "RUFF_WATCH_HOT_RELOAD";
+
+const POLL_INTERVAL: Duration = Duration::from_millis(250);
+/// Pause before restarting, so an editor's save of several files restarts once
+const SETTLE_DELAY: Duration = Duration::from_millis(50);
+const SKIPPED_DIRECTORIES: [&str; 2] = ["target", "node_modules"];
+
+type SourceSnapshot = BTreeMap<PathBuf, (Option<SystemTime>, u64)>;
+
+/// Whether this process runs under `ruff run --watch` and should reload changed modules in
+/// place. Tells the watcher so, which then stops restarting this process for module changes.
+pub fn enable_hot_reload() -> bool {
+    let Some(marker) = std::env::var_os(HOT_RELOAD_ENV) else {
+        return false;
+    };
+    if let Err(error) = fs::write(&marker, std::process::id().to_string()) {
+        eprintln!("[watch] cannot enable module reloading: {}", error);
+        return false;
+    }
+    eprintln!("[watch] changed modules are reloaded before the next request");
+    true
+}
+
+/// The command-line arguments for the watched `ruff run`: this process's arguments
+/// without the first `--watch`.
+pub fn child_arguments(args: impl IntoIterator<Item = OsString>) -> Vec<OsString> {
+    let mut removed = false;
+    args.into_iter()
+        .filter(|arg| {
+            let is_watch = !removed && arg == "--watch";
+            removed |= is_watch;
+            !is_watch
+        })
+        .collect()
+}
+
+/// Run `ruff` with `child_args` and restart it whenever `script` or a `.ruff` file under its
+/// directory changes. Returns only when the child cannot be started.
+pub fn run_watched(script: &Path, child_args: &[OsString]) -> Result<(), String> {
+    let executable = std::env::current_exe()
+        .map_err(|error| format!("Failed to locate the ruff executable: {}", error))?;
+    let script = fs::canonicalize(script)
+        .map_err(|error| format!("Failed to read '{}': {}", script.display(), error))?;
+    let root = script.parent().map(Path::to_path_buf).unwrap_or_else(|| PathBuf::from("."));
+    let marker = std::env::temp_dir().join(format!("ruff-watch-{}.hot-reload", std::process::id()));
+
+    let mut snapshot = source_snapshot(&script, &root);
+    loop {
+        let _ = fs::remove_file(&marker);
+        let mut child = Some(
+            Command::new(&executable)
+                .args(child_args)
+                .env(HOT_RELOAD_ENV, &marker)
+                .spawn()
+                .map_err(|error| format!("Failed to start '{}': {}", script.display(), error))?,
+        );
+
+        loop {
+            thread::sleep(POLL_INTERVAL);
+            if let Some(status) = child.as_mut().and_then(|child| child.try_wait().ok().flatten()) {
+                eprintln!("[watch] script exited with {}; waiting for changes", describe(status));
+                child = None;
+            }
+
+            let current = source_snapshot(&script, &root);
+            if current == snapshot {
+                continue;
+            }
+            thread::sleep(SETTLE_DELAY);
+            let current = source_snapshot(&script, &root);
+            let changed = changed_paths(&snapshot, &current);
+            snapshot = current;
+
+            let entry_changed = changed.iter().any(|path| *path == script);
+            if child.is_some() && !entry_changed && marker.exists() {
+                continue;
+            }
+            if let Some(child) = child.as_mut() {
+                stop(child);
+            }
+            let names: Vec<String> = changed
+                .iter()
+                .map(|path| path.strip_prefix(&root).unwrap_or(path).display().to_string())
+                .collect();
+            eprintln!("[watch] {} changed; restarting", names.join(", "));
+            break;
+        }
+    }
+}
+
+fn stop(child: &mut Child) {
+    let _ = child.kill();
+    let _ = child.wait();
+}
+
+fn describe(status: ExitStatus) -> String {
+    match status.code() {
+        Some(code) => format!("code {}", code),
+        None => "a signal".to_string(),
+    }
+}
+
+/// Modification time and size of `script` and of every `.ruff` file under `root`, skipping
+/// hidden and build directories.
+fn source_snapshot(script: &Path, root: &Path) -> SourceSnapshot {
+    let mut snapshot = SourceSnapshot::new();
+    record_source(script, &mut snapshot);
+    let mut pending = vec![root.to_path_buf()];
+    while let Some(directory) = pending.pop() {
+        let Ok(entries) = fs::read_dir(&directory) else {
+            continue;
+        };
+        for entry in entries.flatten() {
+            let path = entry.path();
+            let name = entry.file_name();
+            let name = name.to_string_lossy();
+            match entry.file_type() {
+                Ok(kind) if kind.is_dir() => {
+                    if !name.starts_with('.') && !SKIPPED_DIRECTORIES.contains(&name.as_ref()) {
+                        pending.push(path);
+                    }
+                }
+                Ok(_) if path.extension().is_some_and(|extension| extension == "ruff") => {
+                    record_source(&path, &mut snapshot)
+                }
+                _ => {}
+            }
+        }
+    }
+    snapshot
+}
+
+fn record_source(path: &Path, snapshot: &mut SourceSnapshot) {
+    if let Ok(metadata) = fs::metadata(path) {
+        snapshot.insert(path.to_path_buf(), (metadata.modified().ok(), metadata.len()));
+    }
+}
+
+/// Files added, removed, or modified between two snapshots.
+fn changed_paths(before: &SourceSnapshot, after: &SourceSnapshot) -> Vec<PathBuf> {
+    let mut changed: Vec<PathBuf> = after
+        .iter()
+        .filter(|(path, state)| before.get(*path) != Some(*state))
+        .map(|(path, _)| path.clone())
+        .collect();
+    changed.extend(before.keys().filter(|path| !after.contains_key(*path)).cloned());
+    changed.sort();
+    changed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::{changed_paths, child_arguments, source_snapshot};
+    use std::ffi::OsString;
+    use std::fs;
+    use std::time::{SystemTime, UNIX_EPOCH};
+
+    #[test]
+    fn child_arguments_drop_only_the_first_watch_flag() {
+        let args = ["run", "--watch", "server.ruff", "--watch"].map(OsString::from);
+        assert_eq!(
+            child_arguments(args),
+            ["run", "server.ruff", "--watch"].map(OsString::from).to_vec()
+        );
+    }
+
+    #[test]
+    fn snapshots_report_changed_added_and_removed_sources() {
+        let nanos = SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .expect("system time should be after epoch")
+            .as_nanos();
+        let root =
+            std::env::temp_dir().join(format!("ruff_watch_{}_{}", std::process::id(), nanos));
+        fs::create_dir_all(root.join("lib")).expect("failed to create watch fixture");
+        fs::create_dir_all(root.join(".cache")).expect("failed to create watch fixture");
+        let script = root.join("main.ruff");
+        fs::write(&script, "print(1)\n").expect("failed to write script");
+        fs::write(root.join("lib/util.ruff"), "export x := 1\n").expect("failed to write module");
+        fs::write(root.join("notes.txt"), "ignored\n").expect("failed to write note");
+
+        let before = source_snapshot(&script, &root);
+        assert_eq!(before.len(), 2);
+
+        fs::write(root.join("lib/util.ruff"), "export x := 22\n").expect("failed to edit module");
+        fs::write(root.join("lib/extra.ruff"), "export y := 2\n").expect("failed to add module");
+        fs::write(root.join(".cache/skip.ruff"), "x := 3\n").expect("failed to write hidden");
+        fs::remove_file(&script).expect("failed to remove script");
+        let after = source_snapshot(&script, &root);
+
+        assert_eq!(
+            changed_paths(&before, &after),
+            vec![root.join("lib/extra.ruff"), root.join("lib/util.ruff"), script.clone()]
+        );
+        fs::remove_dir_all(&root).expect("failed to clean up watch fixture");
+    }
+}
@@ -19,7 +19,7 @@ fn architecture_doc_matches_current_runtime_posture() {
         "### 3.5 Package workflow and lockfiles",
         "ruff package-install --frozen",
         "Supports `--runtime dual|vm|interpreter`.",
-        "Top-level generator iteration (`func*` + `yield`) is intentionally divergent",
+        "`Yield` suspends the generator being resumed",
         "docs/VM_INTERPRETER_PARITY_MATRIX.md",
     ] {
         assert!(content.contains(marker), "architecture doc should contain marker {:?}", marker);
@@ -30,6 +30,7 @@ fn architecture_doc_matches_current_runtime_posture() {
         "Bytecode VM** (experimental, not yet default)",
         "Version**: v0.8.0",
         "v0.9.0 modularization in progress",
+        "Top-level generator iteration (`func*` + `yield`) is intentionally divergent",
     ] {
         assert!(
             !content.contains(stale),
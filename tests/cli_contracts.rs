@@ -1,7 +1,8 @@
 use serde_json::Value;
 use std::fs;
+use std::io::Write;
 use std::path::{Path, PathBuf};
-use std::process::Command;
+use std::process::{Command, Stdio};
 use std::time::{SystemTime, UNIX_EPOCH};
 
 const EXIT_USAGE_ERROR: i32 = 2;
@@ -36,6 +37,23 @@ fn run_ruff_in_dir(args: &[&str], cwd: &Path) -> std::process::Output {
         .expect("failed to execute ruff binary")
 }
 
+fn run_ruff_with_stdin(args: &[&str], stdin: &str) -> std::process::Output {
+    let mut child = Command::new(ruff_binary())
+        .args(args)
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .expect("failed to execute ruff binary");
+    child
+        .stdin
+        .take()
+        .expect("child stdin should be piped")
+        .write_all(stdin.as_bytes())
+        .expect("failed to write child stdin");
+    child.wait_with_output().expect("failed to wait for ruff binary")
+}
+
 fn write_fixture(path: &Path, content: &str) {
     fs::write(path, content).expect("failed to write fixture file");
 }
@@ -99,6 +117,53 @@ fn cli_run_runtime_error_exits_with_runtime_code() {
     assert!(stderr.contains("Division by zero") || stderr.contains("divide by zero"));
 }
 
+#[test]
+fn cli_run_runtime_error_points_at_failing_statement_on_both_backends() {
+    let dir = unique_temp_dir("cli_run_runtime_location");
+    let file = dir.join("runtime_location.ruff");
+    write_fixture(
+        &file,
+        "func divide(n) {\n    if n > 1 {\n        return n / 0\n    }\n    return n\n}\nprint(divide(1))\nprint(divide(2))\n",
+    );
+    let path = file.to_str().expect("path should be utf-8");
+
+    for args in [vec!["run", path], vec!["run", "--interpreter", path]] {
+        let output = run_ruff(&args);
+        assert_eq!(output.status.code(), Some(EXIT_RUNTIME_ERROR));
+
+        let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+        assert!(
+            stderr.contains(&format!("--> {}:3:9", path)),
+            "expected the error at the division statement for {:?}, got: {}",
+            args,
+            stderr
+        );
+        assert!(stderr.contains("  3 |         return n / 0"), "{}", stderr);
+    }
+}
+
+#[test]
+fn cli_run_runtime_error_prints_stack_trace_with_call_sites_on_both_backends() {
+    let dir = unique_temp_dir("cli_run_runtime_stack_trace");
+    let file = dir.join("stack_trace.ruff");
+    write_fixture(
+        &file,
+        "func inner(n) {\n    return n / 0\n}\nfunc outer(n) {\n    x := inner(n)\n    return x\n}\nprint(outer(1))\n",
+    );
+    let path = file.to_str().expect("path should be utf-8");
+    let expected = format!(
+        "Call stack:\n  0 at inner ({path}:2:5)\n  1 at outer ({path}:5:5)\n  2 at <main> ({path}:8:1)\n"
+    );
+
+    for args in [vec!["run", path], vec!["run", "--interpreter", path]] {
+        let output = run_ruff(&args);
+        assert_eq!(output.status.code(), Some(EXIT_RUNTIME_ERROR));
+
+        let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+        assert!(stderr.contains(&expected), "unexpected trace for {:?}: {}", args, stderr);
+    }
+}
+
 #[test]
 fn cli_run_runtime_error_json_mode_emits_stdout_payload() {
     let dir = unique_temp_dir("cli_run_runtime_json_error");
@@ -279,6 +344,66 @@ fn cli_run_executes_program_output() {
     assert!(stdout.contains("run-ok"), "run should execute and print script output");
 }
 
+#[test]
+fn cli_run_backend_flags_select_vm_or_interpreter() {
+    let dir = unique_temp_dir("cli_run_backend_flags");
+    let file = dir.join("fib.ruff");
+    write_fixture(&file, "func fib(n) {\n    if n < 2 {\n        return n\n    }\n    return fib(n - 1) + fib(n - 2)\n}\nprint(fib(15))\n");
+    let file = file.to_str().expect("path should be utf-8");
+
+    for backend in ["--vm", "--interpreter"] {
+        let output = run_ruff(&["run", backend, file]);
+        assert_eq!(output.status.code(), Some(0), "{} run should succeed", backend);
+        let stdout = String::from_utf8(output.stdout).expect("stdout should be utf-8");
+        assert_eq!(stdout.trim(), "610", "{} should compute the same result", backend);
+    }
+
+    let conflicting = run_ruff(&["run", "--vm", "--interpreter", file]);
+    assert_eq!(conflicting.status.code(), Some(EXIT_USAGE_ERROR));
+}
+
+#[test]
+fn cli_fmt_check_and_write_modes_enforce_canonical_style() {
+    let dir = unique_temp_dir("cli_fmt_modes");
+    let file = dir.join("style.ruff");
+    write_fixture(&file, "func add(a,b){return a+b}\nif(add(1,2)>2){print( \"big\" )}\n");
+    let file_str = file.to_str().expect("path should be utf-8");
+
+    let check = run_ruff(&["fmt", "--check", file_str]);
+    assert_eq!(check.status.code(), Some(1));
+    assert_eq!(String::from_utf8_lossy(&check.stdout).trim(), "needs formatting");
+
+    let write = run_ruff(&["fmt", "--write", file_str]);
+    assert_eq!(write.status.code(), Some(0));
+    let formatted = fs::read_to_string(&file).expect("formatted file should be readable");
+    assert_eq!(
+        formatted,
+        "func add(a, b) {\n    return a + b\n}\nif add(1, 2) > 2 {\n    print(\"big\")\n}\n"
+    );
+
+    let recheck = run_ruff(&["fmt", "--check", file_str]);
+    assert_eq!(recheck.status.code(), Some(0));
+    assert_eq!(String::from_utf8_lossy(&recheck.stdout).trim(), "already formatted");
+
+    let preview = run_ruff(&["fmt", file_str]);
+    assert_eq!(preview.status.code(), Some(0));
+    assert_eq!(String::from_utf8_lossy(&preview.stdout), formatted);
+}
+
+#[test]
+fn cli_fmt_leaves_unparseable_files_untouched() {
+    let dir = unique_temp_dir("cli_fmt_parse_error");
+    let file = dir.join("broken.ruff");
+    let source = "func broken( {\n";
+    write_fixture(&file, source);
+
+    let output = run_ruff(&["fmt", "--write", file.to_str().expect("path should be utf-8")]);
+
+    assert_eq!(output.status.code(), Some(EXIT_LEX_PARSE_ERROR));
+    assert!(!output.stderr.is_empty(), "parse failures should be reported on stderr");
+    assert_eq!(fs::read_to_string(&file).expect("file should be readable"), source);
+}
+
 #[test]
 fn cli_test_discovers_and_runs_expected_fixtures() {
     let workspace = unique_temp_dir("cli_test_discovers_fixtures");
@@ -438,6 +563,607 @@ db_begin(db)\n",
     );
 }
 
+#[test]
+fn cli_test_runs_test_functions_from_test_files() {
+    let workspace = unique_temp_dir("cli_test_unit_functions");
+    let nested = workspace.join("lib");
+    fs::create_dir_all(&nested).expect("failed to create nested directory");
+
+    write_fixture(
+        &nested.join("math_test.ruff"),
+        "func add(a, b) {\n    return a + b\n}\n\n\
+func test_add() {\n    assert_eq(add(2, 3), 5)\n}\n\n\
+func test_raises() {\n    assert_raises(func() { fail(\"boom\") }, \"boom\")\n}\n\n\
+func test_wrong_sum() {\n    assert_eq(add(2, 2), 5)\n}\n\n\
+func helper() {\n    fail(\"helpers are not tests\")\n}\n",
+    );
+    write_fixture(
+        &workspace.join("notes.ruff"),
+        "func test_ignored() {\n    fail(\"not a test file\")\n}\n",
+    );
+
+    let output = run_ruff_in_dir(&["test"], &workspace);
+    assert_eq!(
+        output.status.code(),
+        Some(EXIT_VERIFICATION_ERROR),
+        "a failing test function should make ruff test exit nonzero"
+    );
+
+    let stdout = String::from_utf8(output.stdout).expect("stdout should be utf-8");
+    assert!(stdout.contains("✓ test_add ("), "passing tests should report timing: {}", stdout);
+    assert!(stdout.contains("✓ test_raises ("), "assert_raises should pass on a raised error");
+    assert!(stdout.contains("✗ test_wrong_sum ("), "failing assertions should be reported");
+    assert!(stdout.contains("expected Int(5), got Int(4)"), "failures should carry the message");
+    assert!(!stdout.contains("helper"), "only test_* functions should run");
+    assert!(!stdout.contains("test_ignored"), "only *_test.ruff files should be discovered");
+    assert!(stdout.contains("Tests: 3 total, 2 passed, 1 failed"), "stdout was: {}", stdout);
+
+    let output =
+        run_ruff_in_dir(&["test", nested.join("math_test.ruff").to_str().unwrap()], &workspace);
+    assert_eq!(output.status.code(), Some(EXIT_VERIFICATION_ERROR));
+
+    fs::remove_file(nested.join("math_test.ruff")).expect("failed to remove test file");
+    write_fixture(&nested.join("ok_test.ruff"), "func test_ok() {\n    assert_true(true)\n}\n");
+    let output = run_ruff_in_dir(&["test"], &workspace);
+    assert_eq!(output.status.code(), Some(0), "all-passing test files should exit zero");
+}
+
+#[test]
+fn cli_bench_json_reports_statistics_per_mode_without_script_output() {
+    let dir = unique_temp_dir("cli_bench_json");
+    let file = dir.join("loop.ruff");
+    write_fixture(
+        &file,
+        "print(\"script-noise\")\nmut x := 0\nfor i in range(50) {\n    x := x + i\n}\n",
+    );
+
+    let output = run_ruff(&[
+        "bench",
+        file.to_str().expect("path should be utf-8"),
+        "--iterations",
+        "2",
+        "--warmup",
+        "1",
+        "--json",
+    ]);
+    assert_eq!(output.status.code(), Some(0));
+
+    let stdout = String::from_utf8(output.stdout).expect("stdout should be utf-8");
+    assert!(!stdout.contains("script-noise"), "benchmarked script output should be discarded");
+    let report: Value =
+        serde_json::from_str(stdout.trim()).expect("bench --json should print one JSON document");
+    let entries = report["benchmarks"].as_array().expect("benchmarks should be an array");
+    assert_eq!(entries.len(), 3, "one entry per execution mode");
+    for entry in entries {
+        assert_eq!(entry["benchmark"], "loop");
+        assert_eq!(entry["success"], true);
+        assert_eq!(entry["samples"], 2);
+        for field in ["mean_ns", "median_ns", "stddev_ns", "min_ns", "max_ns", "ops_per_sec"] {
+            assert!(entry[field].is_number(), "{} should be numeric in {}", field, entry);
+        }
+    }
+}
+
+#[test]
+fn cli_run_profile_writes_pprof_cpu_and_allocation_profiles() {
+    let dir = unique_temp_dir("cli_run_profile");
+    let file = dir.join("hot.ruff");
+    write_fixture(
+        &file,
+        "func build_rows(n) {\n    mut rows := []\n    for i in range(n) {\n        rows := push(rows, [i, i * 2])\n    }\n    return rows\n}\n\nmut total := 0\nfor round in range(30) {\n    total := total + len(build_rows(200))\n}\nprint(total)\n",
+    );
+    let cpu = dir.join("cpu.pprof");
+    let alloc = dir.join("alloc.pprof");
+
+    let output = run_ruff(&[
+        "run",
+        "--profile",
+        &format!("cpu={}", cpu.display()),
+        "--profile",
+        &format!("alloc={}", alloc.display()),
+        file.to_str().expect("path should be utf-8"),
+    ]);
+    assert_eq!(output.status.code(), Some(0));
+    assert_eq!(String::from_utf8(output.stdout).expect("stdout should be utf-8"), "6000\n");
+
+    let contains = |bytes: &[u8], needle: &[u8]| bytes.windows(needle.len()).any(|w| w == needle);
+    let cpu_profile = fs::read(&cpu).expect("cpu profile should be written");
+    assert!(contains(&cpu_profile, b"nanoseconds"), "cpu profile should declare cpu sample type");
+    let alloc_profile = fs::read(&alloc).expect("allocation profile should be written");
+    assert!(contains(&alloc_profile, b"alloc_objects"));
+    assert!(contains(&alloc_profile, b"build_rows"), "allocations should name the Ruff function");
+    assert!(contains(&alloc_profile, b"array"), "allocations should be labelled by object kind");
+
+    let output = run_ruff(&["run", "--profile", "heap=x.pprof", file.to_str().unwrap()]);
+    assert_eq!(output.status.code(), Some(EXIT_USAGE_ERROR));
+}
+
+#[test]
+fn cli_debug_stops_at_breakpoints_and_evaluates_in_the_paused_frame() {
+    let workspace = unique_temp_dir("cli_debug_breakpoints");
+    let script_path = workspace.join("main.ruff");
+    write_fixture(
+        &script_path,
+        "func add(a, b) {\n    total := a + b\n    return total\n}\n\n\
+x := 2\ny := add(x, 3)\nprint(\"y = ${y}\")\n",
+    );
+    let script = script_path.to_string_lossy().to_string();
+
+    let output = run_ruff_with_stdin(
+        &["debug", &script, "--break", "main.ruff:2"],
+        "continue\nbacktrace\nprint a * 10\nprint missing\nfinish\nprint y\ncontinue\n",
+    );
+    assert!(output.status.success(), "debug session should finish the script: {:?}", output);
+
+    let stdout = String::from_utf8(output.stdout).expect("stdout should be utf-8");
+    assert!(stdout.contains("main.ruff:6 in <main>"), "should pause at entry: {}", stdout);
+    assert!(stdout.contains("main.ruff:2 in add"), "should stop at the breakpoint: {}", stdout);
+    assert!(stdout.contains("#0 add ("), "backtrace should list the innermost frame first");
+    assert!(stdout.contains("main.ruff:7:1)"), "backtrace should include the call site");
+    assert!(stdout.contains("(ruff-debug) 20\n"), "print should see function locals: {}", stdout);
+    assert!(stdout.contains("Error: Undefined variable: missing"), "print errors are reported");
+    assert!(stdout.contains("main.ruff:8 in <main>"), "finish should return to the caller");
+    assert!(stdout.contains("(ruff-debug) 5\n"), "print should see the returned value");
+    assert!(stdout.contains("y = 5"), "the program should keep running after the session");
+
+    let output = run_ruff(&["debug", &script, "--break", "main.ruff:zero"]);
+    assert_eq!(output.status.code(), Some(EXIT_USAGE_ERROR), "bad breakpoints are usage errors");
+
+    fs::remove_dir_all(workspace).expect("failed to clean temp dir");
+}
+
+fn send_dap_request(stream: &mut std::net::TcpStream, seq: u64, command: &str, arguments: Value) {
+    let body = serde_json::json!({
+        "seq": seq,
+        "type": "request",
+        "command": command,
+        "arguments": arguments,
+    })
+    .to_string();
+    write!(stream, "Content-Length: {}\r\n\r\n{}", body.len(), body)
+        .expect("failed to write DAP request");
+}
+
+/// Reads DAP messages until one is the response to `command` or the event `command`
+fn read_dap_message(reader: &mut impl std::io::BufRead, command: &str) -> Value {
+    loop {
+        let mut length = 0;
+        loop {
+            let mut header = String::new();
+            assert!(reader.read_line(&mut header).expect("failed to read DAP header") > 0);
+            match header.trim_end().strip_prefix("Content-Length:") {
+                Some(value) => length = value.trim().parse().expect("invalid Content-Length"),
+                None if header.trim_end().is_empty() => break,
+                None => {}
+            }
+        }
+        let mut body = vec![0; length];
+        reader.read_exact(&mut body).expect("failed to read DAP body");
+        let message: Value = serde_json::from_slice(&body).expect("DAP body should be JSON");
+        if message["command"] == command || message["event"] == command {
+            return message;
+        }
+    }
+}
+
+#[test]
+fn cli_run_dap_serves_breakpoints_stack_variables_and_evaluation() {
+    use std::io::BufRead;
+
+    let workspace = unique_temp_dir("cli_run_dap");
+    let script_path = workspace.join("main.ruff");
+    write_fixture(
+        &script_path,
+        "func scale(values, factor) {\n    scaled := len(values) * factor\n    return scaled\n}\n\n\
+items := [1, 2, 3]\nresult := scale(items, 10)\nprint(\"result ${result}\")\n",
+    );
+
+    let mut child = Command::new(ruff_binary())
+        .args(["run", "--dap", "127.0.0.1:0"])
+        .arg(&script_path)
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()
+        .expect("failed to execute ruff binary");
+    let mut stderr = std::io::BufReader::new(child.stderr.take().expect("stderr should be piped"));
+    let mut banner = String::new();
+    stderr.read_line(&mut banner).expect("failed to read listening address");
+    let address = banner
+        .trim()
+        .strip_prefix("Debug adapter listening on ")
+        .unwrap_or_else(|| panic!("unexpected banner: {}", banner))
+        .to_string();
+
+    let mut stream = std::net::TcpStream::connect(&address).expect("failed to connect");
+    let mut reader = std::io::BufReader::new(stream.try_clone().expect("failed to clone stream"));
+    let source = serde_json::json!({ "path": script_path.to_string_lossy() });
+
+    send_dap_request(&mut stream, 1, "initialize", serde_json::json!({ "adapterID": "ruff" }));
+    read_dap_message(&mut reader, "initialized");
+    send_dap_request(&mut stream, 2, "launch", serde_json::json!({}));
+    send_dap_request(
+        &mut stream,
+        3,
+        "setBreakpoints",
+        serde_json::json!({ "source": source, "breakpoints": [{ "line": 2 }] }),
+    );
+    let breakpoints = read_dap_message(&mut reader, "setBreakpoints");
+    assert_eq!(breakpoints["body"]["breakpoints"][0]["verified"], true);
+    send_dap_request(&mut stream, 4, "configurationDone", serde_json::json!({}));
+
+    let stopped = read_dap_message(&mut reader, "stopped");
+    assert_eq!(stopped["body"]["reason"], "breakpoint");
+
+    send_dap_request(&mut stream, 5, "stackTrace", serde_json::json!({ "threadId": 1 }));
+    let trace = read_dap_message(&mut reader, "stackTrace");
+    let frames = &trace["body"]["stackFrames"];
+    assert_eq!(frames[0]["name"], "scale");
+    assert_eq!(frames[0]["line"], 2);
+    assert_eq!(frames[1]["name"], "<main>");
+    assert_eq!(frames[1]["line"], 7);
+
+    send_dap_request(&mut stream, 6, "scopes", serde_json::json!({ "frameId": 0 }));
+    let scopes = read_dap_message(&mut reader, "scopes");
+    assert_eq!(scopes["body"]["scopes"][0]["name"], "Locals");
+    let locals_reference = scopes["body"]["scopes"][0]["variablesReference"].clone();
+    send_dap_request(
+        &mut stream,
+        7,
+        "variables",
+        serde_json::json!({ "variablesReference": locals_reference }),
+    );
+    let variables = read_dap_message(&mut reader, "variables");
+    let locals = variables["body"]["variables"].as_array().expect("variables array");
+    let factor = locals.iter().find(|v| v["name"] == "factor").expect("factor local");
+    assert_eq!(factor["value"], "10");
+    let values = locals.iter().find(|v| v["name"] == "values").expect("values local");
+    assert_eq!(values["value"], "[1, 2, 3]");
+    send_dap_request(
+        &mut stream,
+        8,
+        "variables",
+        serde_json::json!({ "variablesReference": values["variablesReference"] }),
+    );
+    let elements = read_dap_message(&mut reader, "variables");
+    assert_eq!(elements["body"]["variables"][2]["value"], "3");
+
+    send_dap_request(
+        &mut stream,
+        9,
+        "evaluate",
+        serde_json::json!({ "expression": "len(values) * factor", "frameId": 0 }),
+    );
+    let evaluated = read_dap_message(&mut reader, "evaluate");
+    assert_eq!(evaluated["body"]["result"], "30");
+
+    send_dap_request(&mut stream, 10, "continue", serde_json::json!({ "threadId": 1 }));
+    let exited = read_dap_message(&mut reader, "exited");
+    assert_eq!(exited["body"]["exitCode"], 0);
+    read_dap_message(&mut reader, "terminated");
+    send_dap_request(&mut stream, 11, "disconnect", serde_json::json!({}));
+
+    let output = child.wait_with_output().expect("failed to wait for ruff binary");
+    assert!(output.status.success(), "debuggee should exit cleanly: {:?}", output);
+    let stdout = String::from_utf8(output.stdout).expect("stdout should be utf-8");
+    assert!(stdout.contains("result 30"), "the program should run to completion: {}", stdout);
+
+    fs::remove_dir_all(workspace).expect("failed to clean temp dir");
+}
+
+#[test]
+fn cli_build_writes_a_standalone_executable_with_imported_modules() {
+    let temp_dir = unique_temp_dir("cli_build_bundle");
+    let project = temp_dir.join("project");
+    fs::create_dir_all(project.join("lib")).expect("failed to create project directory");
+    write_fixture(
+        &project.join("app.ruff"),
+        "import \"lib/text\"\nprint(text.shout(join(args(), \",\")))\n",
+    );
+    write_fixture(
+        &project.join("lib/text.ruff"),
+        "import \"pad\"\nexport func shout(s) { return pad.wrap(upper(s)) }\n",
+    );
+    write_fixture(
+        &project.join("lib/pad.ruff"),
+        "export func wrap(s) { return \"[\" + s + \"]\" }\n",
+    );
+
+    let tool = temp_dir.join("tool");
+    let build = run_ruff_in_dir(&["build", "app.ruff", "-o", tool.to_str().unwrap()], &project);
+    assert_eq!(build.status.code(), Some(0), "{}", String::from_utf8_lossy(&build.stderr));
+    assert!(String::from_utf8_lossy(&build.stdout).contains("2 bundled modules"));
+
+    // The sources are no longer needed once bundled
+    fs::remove_dir_all(&project).expect("failed to remove project directory");
+    let output = Command::new(&tool)
+        .args(["a", "--help", "run"])
+        .current_dir(&temp_dir)
+        .env("HOME", &temp_dir)
+        .output()
+        .expect("failed to execute bundled tool");
+    assert_eq!(output.status.code(), Some(0), "{}", String::from_utf8_lossy(&output.stderr));
+    assert_eq!(String::from_utf8_lossy(&output.stdout), "[A,--HELP,RUN]\n");
+
+    write_fixture(&temp_dir.join("broken.ruff"), "import missing_helper\n");
+    let missing = run_ruff_in_dir(&["build", "broken.ruff"], &temp_dir);
+    assert_eq!(missing.status.code(), Some(EXIT_IO_ERROR));
+    assert!(String::from_utf8_lossy(&missing.stderr).contains("module not found: missing_helper"));
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_caches_compiled_programs_until_the_source_changes() {
+    let temp_dir = unique_temp_dir("cli_bytecode_cache");
+    let cache_dir = temp_dir.join("cache");
+    write_fixture(&temp_dir.join("app.ruff"), "import \"greet\"\nprint(greet.hello(\"cache\"))\n");
+    write_fixture(
+        &temp_dir.join("greet.ruff"),
+        "export func hello(name) { return \"hello \" + name }\n",
+    );
+    let cache_entries = || match fs::read_dir(&cache_dir) {
+        Ok(entries) => entries
+            .filter_map(Result::ok)
+            .filter(|entry| entry.path().extension().is_some_and(|ext| ext == "ruffc"))
+            .count(),
+        Err(_) => 0,
+    };
+    let run = |args: &[&str]| {
+        Command::new(ruff_binary())
+            .args(args)
+            .current_dir(&temp_dir)
+            .env("RUFF_CACHE_DIR", &cache_dir)
+            .output()
+            .expect("failed to execute ruff binary")
+    };
+
+    let cold = run(&["run", "--no-cache", "app.ruff"]);
+    assert_eq!(String::from_utf8_lossy(&cold.stdout), "hello cache\n");
+    assert_eq!(cache_entries(), 0, "--no-cache neither reads nor writes entries");
+
+    for _ in 0..2 {
+        let output = run(&["run", "app.ruff"]);
+        assert_eq!(output.status.code(), Some(0), "{}", String::from_utf8_lossy(&output.stderr));
+        assert_eq!(String::from_utf8_lossy(&output.stdout), "hello cache\n");
+    }
+    assert_eq!(cache_entries(), 2, "one entry for the script and one for its module");
+
+    write_fixture(
+        &temp_dir.join("greet.ruff"),
+        "export func hello(name) { return \"hi \" + name }\n",
+    );
+    let edited = run(&["run", "app.ruff"]);
+    assert_eq!(String::from_utf8_lossy(&edited.stdout), "hi cache\n");
+    assert_eq!(cache_entries(), 3);
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_playground_worker_reports_output_and_enforces_the_sandbox() {
+    let output = run_ruff_with_stdin(
+        &["playground-worker", "--sandbox"],
+        "print(\"hi\")\nfunc square(n) { return n * n }\nsquare(12)",
+    );
+    assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+    let response: Value = serde_json::from_slice(&output.stdout).expect("worker should emit JSON");
+    assert_eq!(response["events"][0]["stream"], "stdout");
+    assert_eq!(response["events"][0]["text"], "hi\n");
+    assert_eq!(response["value"], "144");
+    assert!(response["error"].is_null());
+
+    let output =
+        run_ruff_with_stdin(&["playground-worker", "--sandbox"], "read_file(\"/etc/hostname\")");
+    let response: Value = serde_json::from_slice(&output.stdout).expect("worker should emit JSON");
+    let error = response["error"].as_str().expect("filesystem access should be denied");
+    assert!(error.contains("Capability denied"), "{}", error);
+
+    let started = std::time::Instant::now();
+    let output = run_ruff_with_stdin(
+        &["playground-worker", "--sandbox", "--time-limit-ms", "300"],
+        "print(\"start\")\nwhile true { }",
+    );
+    assert!(started.elapsed() < std::time::Duration::from_secs(10));
+    let response: Value = serde_json::from_slice(&output.stdout).expect("worker should emit JSON");
+    assert_eq!(response["events"][0]["text"], "start\n", "output before the limit is kept");
+    assert_eq!(response["error"], "Time limit of 300 ms exceeded");
+}
+
+#[test]
+fn cli_run_sandbox_enforces_timeouts_and_module_capabilities() {
+    let temp_dir = unique_temp_dir("cli_run_sandbox");
+    let spin = temp_dir.join("spin.ruff");
+    write_fixture(&spin, "print(\"start\")\nwhile true {\n    try {\n        x := 1\n    } except e {\n        print(\"caught\")\n    }\n}\n");
+    // Loops with empty bodies run no statements, so each iteration must count on its own
+    let empty_while = temp_dir.join("empty_while.ruff");
+    write_fixture(&empty_while, "print(\"start\")\nwhile true {}\n");
+    let empty_for = temp_dir.join("empty_for.ruff");
+    write_fixture(&empty_for, "print(\"start\")\nfor i in iter.range(1000000000000) {}\n");
+    let empty_loop = temp_dir.join("empty_loop.ruff");
+    write_fixture(&empty_loop, "print(\"start\")\nloop {}\n");
+    // A blocking native never reaches a budget check, so the deadline watchdog ends the run
+    let blocked = temp_dir.join("blocked.ruff");
+    write_fixture(&blocked, "print(\"start\")\nsleep(60000)\n");
+    for script in [&spin, &empty_while, &empty_for, &empty_loop, &blocked] {
+        for runtime in [&[][..], &["--interpreter"][..]] {
+            let script_path = script.to_str().unwrap();
+            let mut args = vec!["run"];
+            args.extend_from_slice(runtime);
+            if script == &blocked {
+                args.push("--allow-clock");
+            }
+            args.extend(["--sandbox", "--timeout", "300ms", script_path]);
+            let started = std::time::Instant::now();
+            let output = run_ruff(&args);
+            assert!(started.elapsed() < std::time::Duration::from_secs(20));
+            assert_eq!(
+                output.status.code(),
+                Some(EXIT_RUNTIME_ERROR),
+                "{} {:?}",
+                script_path,
+                runtime
+            );
+            assert_eq!(String::from_utf8_lossy(&output.stdout), "start\n");
+            let stderr = String::from_utf8_lossy(&output.stderr);
+            assert!(stderr.contains("Execution time limit of 300ms exceeded"), "{}", stderr);
+        }
+    }
+
+    // Module top-level code runs with the importing script's capabilities
+    write_fixture(&temp_dir.join("peek.ruff"), "export secret := read_file(\"spin.ruff\")\n");
+    write_fixture(&temp_dir.join("main.ruff"), "from peek import secret\nprint(secret)\n");
+    let output = run_ruff_in_dir(&["run", "--sandbox", "main.ruff"], &temp_dir);
+    assert_eq!(output.status.code(), Some(EXIT_RUNTIME_ERROR));
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("Capability denied: filesystem-read"), "{}", stderr);
+    let output = run_ruff_in_dir(&["run", "--sandbox", "--allow-fs-read", "main.ruff"], &temp_dir);
+    assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+
+    let output = run_ruff(&["run", "--timeout", "soon", spin.to_str().unwrap()]);
+    assert_eq!(output.status.code(), Some(EXIT_USAGE_ERROR));
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_iterates_dicts_in_key_order_and_ordmaps_in_insertion_order() {
+    let temp_dir = unique_temp_dir("cli_run_map_order");
+    let script = temp_dir.join("order.ruff");
+    write_fixture(
+        &script,
+        "d := {\"zeta\": 1, \"alpha\": 2, \"mid\": 3}\nd[\"beta\"] := 4\nfor k in d { print(k) }\nprint(keys(d))\n\
+         m := ordmap([[\"zeta\", 1], [\"alpha\", 2]])\nm[\"mid\"] := 3\nm[\"zeta\"] := 9\n\
+         for k in m { print(k) }\nprint(m)\nprint(to_json({\"m\": m, \"d\": d}))\n",
+    );
+    let expected = "alpha\nbeta\nmid\nzeta\n[alpha, beta, mid, zeta]\nzeta\nalpha\nmid\n\
+                    {\"zeta\": 9, \"alpha\": 2, \"mid\": 3}\n\
+                    {\"d\":{\"alpha\":2,\"beta\":4,\"mid\":3,\"zeta\":1},\"m\":{\"zeta\":9,\"alpha\":2,\"mid\":3}}\n";
+    for runtime in [&[][..], &["--interpreter"][..]] {
+        let mut args = vec!["run"];
+        args.extend_from_slice(runtime);
+        args.push(script.to_str().unwrap());
+        let output = run_ruff(&args);
+        assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+        assert_eq!(String::from_utf8_lossy(&output.stdout), expected, "{:?}", runtime);
+    }
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_supports_interpolation_raw_and_multi_line_strings() {
+    let temp_dir = unique_temp_dir("cli_run_string_literals");
+    let script = temp_dir.join("strings.ruff");
+    write_fixture(
+        &script,
+        "name := \"Ruff\"\ncount := 21\nprint(\"Hello, ${name}! You have ${count * 2} items\")\n\
+         print(\"${join([\"a\", \"b\"], \"}\")} ${[1, null]}\")\nprint(r\"C:\\new\\${name}\")\n\
+         print(\"\"\"one\n  \"two\" ${count}\"\"\")\n",
+    );
+    let expected =
+        "Hello, Ruff! You have 42 items\na}b [1, null]\nC:\\new\\${name}\none\n  \"two\" 21\n";
+    for runtime in [&[][..], &["--interpreter"][..]] {
+        let mut args = vec!["run"];
+        args.extend_from_slice(runtime);
+        args.push(script.to_str().unwrap());
+        let output = run_ruff(&args);
+        assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+        assert_eq!(String::from_utf8_lossy(&output.stdout), expected, "{:?}", runtime);
+    }
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_formats_printf_directives_and_fmt_namespace() {
+    let temp_dir = unique_temp_dir("cli_run_printf");
+    let script = temp_dir.join("report.ruff");
+    write_fixture(
+        &script,
+        "struct Item {\n    name: string\n    func to_string(self) {\n        return \"<\" + self.name + \">\"\n    }\n}\n\
+         print(format(\"%-6s|%6.2f|%v\", \"tea\", 3.5, [1, null]))\n\
+         fmt.printf(\"%v %+04d \", Item { name: \"x\" }, 7)\nprint_f(\"%s\\n\", fmt.sprintf(\"%#x\", 255))\n",
+    );
+    let expected = "tea   |  3.50|[1, null]\n<x> +007 0xff\n";
+    for runtime in [&[][..], &["--interpreter"][..]] {
+        let mut args = vec!["run"];
+        args.extend_from_slice(runtime);
+        args.push(script.to_str().unwrap());
+        let output = run_ruff(&args);
+        assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+        assert_eq!(String::from_utf8_lossy(&output.stdout), expected, "{:?}", runtime);
+    }
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_time_module_parses_formats_and_measures() {
+    let temp_dir = unique_temp_dir("cli_run_time_module");
+    let script = temp_dir.join("dates.ruff");
+    write_fixture(
+        &script,
+        "start := time.monotonic()\nt := time.parse(\"2026-03-31 08:00\", \"YYYY-MM-DD HH:mm\", \"+02:00\")\n\
+         print(time.format(t))\nprint(time.format(time.add_months(t, -1), \"ddd DD MMM YYYY HH:mm Z\", \"+02:00\"))\n\
+         print(time.diff(t, time.add_days(t, 7), \"days\"), time.duration(\"1m30s\"))\n\
+         print(time.monotonic() >= start, time.now() > 1700000000)\n",
+    );
+    let expected = "2026-03-31T06:00:00Z\nSat 28 Feb 2026 08:00 +02:00\n7.0 90.0\ntrue true\n";
+    for runtime in [&[][..], &["--interpreter"][..]] {
+        let mut args = vec!["run"];
+        args.extend_from_slice(runtime);
+        args.push(script.to_str().unwrap());
+        let output = run_ruff(&args);
+        assert!(output.status.success(), "stderr: {}", String::from_utf8_lossy(&output.stderr));
+        assert_eq!(String::from_utf8_lossy(&output.stdout), expected, "{:?}", runtime);
+    }
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
+#[test]
+fn cli_run_parses_script_flags_and_exits_with_os_exit_code() {
+    let temp_dir = unique_temp_dir("cli_run_os_flags");
+    let script = temp_dir.join("tool.ruff");
+    write_fixture(
+        &script,
+        "spec := {\"name\": \"tool\", \"description\": \"Counts things\", \"flags\": [\n\
+         {\"name\": \"count\", \"short\": \"c\", \"default\": 1, \"help\": \"How many\"},\n\
+         {\"name\": \"verbose\", \"type\": \"bool\"}]}\n\
+         print(os.args)\nopts := flags.parse(spec)\n\
+         print(opts[\"count\"], opts[\"verbose\"], opts[\"_positional\"])\nos.exit(opts[\"count\"])\n",
+    );
+    for runtime in [&[][..], &["--interpreter"][..]] {
+        let mut args = vec!["run"];
+        args.extend_from_slice(runtime);
+        args.extend_from_slice(&[
+            script.to_str().unwrap(),
+            "--",
+            "--count=4",
+            "in.txt",
+            "--verbose",
+        ]);
+        let output = run_ruff(&args);
+        assert_eq!(output.status.code(), Some(4), "{:?}", runtime);
+        assert_eq!(
+            String::from_utf8_lossy(&output.stdout),
+            "[--count=4, in.txt, --verbose]\n4 true [in.txt]\n",
+            "{:?}",
+            runtime
+        );
+    }
+
+    let help = run_ruff(&["run", script.to_str().unwrap(), "--", "-h"]);
+    assert_eq!(help.status.code(), Some(0));
+    assert_eq!(
+        String::from_utf8_lossy(&help.stdout),
+        "[-h]\ntool\n\nCounts things\n\nOptions:\n  -c, --count <int> [default: 1]\n        How many\n      \
+         --verbose\n  -h, --help\n        Show this help and exit\n"
+    );
+
+    let _ = fs::remove_dir_all(temp_dir);
+}
+
 #[test]
 fn cli_check_verbose_and_quiet_output_are_deterministic() {
     let dir = unique_temp_dir("cli_check_verbosity");
@@ -463,6 +1189,29 @@ fn cli_check_verbose_and_quiet_output_are_deterministic() {
     );
 }
 
+#[test]
+fn cli_check_reports_type_annotation_mismatches_with_locations() {
+    let dir = unique_temp_dir("cli_check_types");
+    let file = dir.join("typed.ruff");
+    write_fixture(
+        &file,
+        "func add(a: int, b: int) -> int {\n    return a + b\n}\n\nlet total: string := add(1, 2)\nprint(add(\"x\", 2))\n",
+    );
+
+    let output = run_ruff(&["check", file.to_str().expect("path should be utf-8"), "--quiet"]);
+    assert_eq!(output.status.code(), Some(4), "type mismatches should fail check");
+    let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+    assert!(stderr.contains("RUFTYPE001"), "type diagnostics should use the type code");
+    assert!(stderr.contains("variable 'total' declared as String but assigned Int"));
+    assert!(stderr.contains("typed.ruff:5:1"), "diagnostic should point at the statement");
+    assert!(stderr.contains("Function 'add' parameter 1 expects Int but got String"));
+    assert!(stderr.contains("typed.ruff:6:1"));
+
+    let skipped =
+        run_ruff(&["check", file.to_str().expect("path should be utf-8"), "--quiet", "--no-types"]);
+    assert_eq!(skipped.status.code(), Some(0), "--no-types should only lex/parse/compile");
+}
+
 #[test]
 fn cli_check_json_success_is_valid_json() {
     let dir = unique_temp_dir("cli_check_json");
@@ -572,3 +1321,49 @@ fn cli_doctor_rejects_unknown_profile() {
     let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
     assert!(stderr.contains("Unknown doctor profile"));
 }
+
+#[test]
+fn cli_line_mode_prints_expression_result_per_line() {
+    let output = run_ruff_with_stdin(&["-e", "to_upper(fields[0])"], "alpha one\nbeta two\n");
+    assert_eq!(output.status.code(), Some(0));
+    assert_eq!(String::from_utf8(output.stdout).expect("stdout should be utf-8"), "ALPHA\nBETA\n");
+}
+
+#[test]
+fn cli_line_mode_begin_end_and_no_print_share_state() {
+    let output = run_ruff_with_stdin(
+        &["-n", "--begin", "total := 0", "--end", "total", "-e", "total := total + len(fields)"],
+        "a b\nc\nd e f\n",
+    );
+    assert_eq!(output.status.code(), Some(0));
+    assert_eq!(String::from_utf8(output.stdout).expect("stdout should be utf-8"), "6\n");
+}
+
+#[test]
+fn cli_line_mode_error_policy_controls_exit_code() {
+    let output = run_ruff_with_stdin(&["-e", "parse_int(line) * 2"], "1\nnope\n3\n");
+    assert_eq!(output.status.code(), Some(EXIT_RUNTIME_ERROR));
+    assert_eq!(String::from_utf8(output.stdout).expect("stdout should be utf-8"), "2\n");
+    let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+    assert!(stderr.contains("line 2:"), "stderr should name the failing line: {}", stderr);
+
+    let output =
+        run_ruff_with_stdin(&["-e", "parse_int(line) * 2", "--on-error", "skip"], "1\nnope\n3\n");
+    assert_eq!(output.status.code(), Some(0));
+    assert_eq!(String::from_utf8(output.stdout).expect("stdout should be utf-8"), "2\n6\n");
+    let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+    assert!(stderr.contains("skipped line 2"), "stderr should report the skipped line: {}", stderr);
+}
+
+#[test]
+fn cli_line_mode_parse_error_exits_with_lex_parse_code() {
+    let output = run_ruff_with_stdin(&["-e", "(line"], "a\n");
+    assert_eq!(output.status.code(), Some(EXIT_LEX_PARSE_ERROR));
+    assert!(output.stdout.is_empty(), "parse failure should not write stdout");
+}
+
+#[test]
+fn cli_line_mode_flags_require_eval() {
+    let output = run_ruff(&["--begin", "x := 1"]);
+    assert_eq!(output.status.code(), Some(EXIT_USAGE_ERROR));
+}
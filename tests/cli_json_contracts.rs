@@ -114,6 +114,102 @@ fn lint_json_contract_is_stable() {
     assert!(first.get("fix").is_some());
 }
 
+#[test]
+fn lint_fix_rewrites_lines_and_ignore_skips_rules() {
+    let dir = unique_temp_dir("lint_fix_ignore");
+    let file = dir.join("lint_fix.ruff");
+    write_fixture(&file, "let len := 1\nif len > 0 {\n    print(2)\n} else {}\n");
+    let path = file.to_str().expect("path should be utf-8");
+
+    let output = run_ruff(&["lint", path, "--json", "--ignore", "shadowed-builtin"]);
+    assert!(output.status.success(), "lint --json should succeed for warning-only input");
+    let body = parse_stdout_json(&output);
+    let rules: Vec<&str> = body
+        .as_array()
+        .expect("lint output should be an array")
+        .iter()
+        .filter_map(|issue| issue["rule_id"].as_str())
+        .collect();
+    assert_eq!(rules, vec!["empty-block"]);
+
+    let fixed = run_ruff(&["lint", path, "--fix"]);
+    assert!(fixed.status.success());
+    let rewritten = fs::read_to_string(&file).expect("fixed file should be readable");
+    assert_eq!(rewritten, "let len := 1\nif len > 0 {\n    print(2)\n}\n");
+
+    let unknown = run_ruff(&["lint", path, "--ignore", "no-such-rule"]);
+    assert_eq!(unknown.status.code(), Some(2));
+}
+
+#[test]
+fn parse_json_contract_is_stable() {
+    let dir = unique_temp_dir("parse_json_contract");
+    let file = dir.join("parse_input.ruff");
+    write_fixture(&file, "func double(n) {\n    return n * 2\n}\nprint(3 |> double)\n");
+
+    let output = run_ruff(&["parse", file.to_str().expect("path should be utf-8"), "--json"]);
+
+    assert!(output.status.success(), "parse --json should succeed");
+    let body = parse_stdout_json(&output);
+
+    assert_eq!(body["schema"], "ruff-ast");
+    assert_eq!(body["schema_version"], 1);
+    assert!(body["file"].is_string());
+
+    let func = &body["body"][0];
+    assert_eq!(func["kind"], "FuncDef");
+    assert_eq!(func["params"][0]["name"], "n");
+    assert_eq!(func["span"]["start"]["line"], 1);
+    assert_eq!(func["body"][0]["kind"], "Return");
+
+    let call = &body["body"][1]["expr"];
+    assert_eq!(call["kind"], "Call");
+    assert_eq!(call["args"][0]["kind"], "Call");
+    assert_eq!(call["args"][0]["function"]["name"], "double");
+
+    write_fixture(&file, "let := 1\n");
+    let output = run_ruff(&["parse", file.to_str().expect("path should be utf-8"), "--json"]);
+    assert_eq!(output.status.code(), Some(3), "parse errors should exit with code 3");
+    assert!(output.stdout.is_empty(), "parse errors should not print a JSON payload");
+}
+
+#[test]
+fn bench_suite_json_reports_ruff_timings_and_gates_regressions() {
+    let dir = unique_temp_dir("bench_suite_json");
+    write_fixture(&dir.join("bench.ruff"), "print(\"FIB_MS=2.5\")\nprint(\"FIB_RESULT=55\")\n");
+    let dir_arg = dir.to_str().expect("path should be utf-8");
+    let report = dir.join("report.json");
+    let report_arg = report.to_str().expect("path should be utf-8");
+
+    let output = run_ruff(&[
+        "bench-suite",
+        "--dir",
+        dir_arg,
+        "--runs",
+        "2",
+        "--json",
+        "--json-out",
+        report_arg,
+    ]);
+    assert!(output.status.success(), "bench-suite should succeed with only bench.ruff present");
+    let body = parse_stdout_json(&output);
+    assert_eq!(body["schema_version"], 1);
+    assert_eq!(body["runs"], 2);
+    assert_eq!(body["languages"][0]["name"], "ruff");
+    assert_eq!(body["languages"][0]["status"], "ran");
+    assert_eq!(body["languages"][1]["status"], "skipped");
+    assert_eq!(body["benchmarks"][0]["id"], "fib");
+    assert_eq!(body["benchmarks"][0]["timings"]["ruff"]["median_ms"], 2.5);
+    assert_eq!(body["benchmarks"][0]["results"]["ruff"], "55");
+    assert_eq!(body["regressions"], serde_json::json!([]));
+
+    write_fixture(&dir.join("bench.ruff"), "print(\"FIB_MS=5\")\n");
+    let output =
+        run_ruff(&["bench-suite", "--dir", dir_arg, "--runs", "1", "--baseline", report_arg]);
+    assert_eq!(output.status.code(), Some(1), "a Ruff slowdown over the threshold fails the gate");
+    assert!(String::from_utf8_lossy(&output.stdout).contains("- fib: 2.50 ms -> 5.00 ms (+100.0%)"));
+}
+
 #[test]
 fn docgen_json_contract_is_stable() {
     let dir = unique_temp_dir("docgen_json_contract");
@@ -636,6 +732,8 @@ fn run_runtime_json_diagnostic_contract_is_stable() {
     assert_eq!(diagnostic["subsystem"], "vm");
     assert_eq!(diagnostic["severity"], "error");
     assert!(diagnostic["message"].as_str().is_some());
+    assert_eq!(diagnostic["line"], 2);
+    assert_eq!(diagnostic["column"], 1);
 }
 
 #[test]
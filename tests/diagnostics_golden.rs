@@ -53,14 +53,15 @@ fn read_fixture_source(name: &str) -> String {
 }
 
 fn run_runtime_json_diagnostic_fixture(fixture_file: &str, extra_args: &[&str]) -> String {
-    let fixture_path = fixtures_dir().join(fixture_file);
     let mut args = vec!["run"];
     args.extend_from_slice(extra_args);
-    args.push(fixture_path.to_str().expect("fixture path should be utf-8"));
+    args.push(fixture_file);
     args.push("--json-runtime-diagnostics");
 
+    // Run from the fixtures directory so reported file paths stay machine independent.
     let output = Command::new(env!("CARGO_BIN_EXE_ruff"))
         .args(args)
+        .current_dir(fixtures_dir())
         .env("NO_COLOR", "1")
         .output()
         .expect("failed to run ruff runtime diagnostic fixture");
@@ -74,7 +75,11 @@ fn first_lexer_diagnostic_from_fixture(fixture_file: &str) -> Diagnostic {
     let source = read_fixture_source(fixture_file);
     let diagnostics = tokenize_with_file(&source, Some(fixture_file))
         .expect_err("fixture should produce lexer diagnostics");
-    diagnostics.first().expect("lexer diagnostics should not be empty").to_diagnostic()
+    diagnostics
+        .first()
+        .expect("lexer diagnostics should not be empty")
+        .to_diagnostic()
+        .with_source_excerpt(&source)
 }
 
 fn first_parser_diagnostic_from_fixture(fixture_file: &str) -> Diagnostic {
@@ -88,6 +93,7 @@ fn first_parser_diagnostic_from_fixture(fixture_file: &str) -> Diagnostic {
         .first()
         .expect("parser diagnostics should not be empty")
         .to_diagnostic(Some(fixture_file))
+        .with_source_excerpt(&source)
 }
 
 fn to_human_snapshot(diagnostic: &Diagnostic) -> String {
@@ -7,6 +7,7 @@ use std::time::{SystemTime, UNIX_EPOCH};
 #[derive(Clone, Copy, Debug, PartialEq, Eq)]
 enum SmokeMode {
     Run,
+    /// `ruff check --no-types`: snippets and legacy examples are held to lex/parse/compile only
     ParseOnly,
     ExpectedFail,
 }
@@ -88,13 +89,9 @@ fn run_examples() -> HashSet<&'static str> {
     ])
 }
 
-fn expected_fail_examples_with_reason() -> [(&'static str, &'static str); 28] {
+fn expected_fail_examples_with_reason() -> [(&'static str, &'static str); 25] {
     [
         ("examples/benchmark_async.ruff", "legacy control-flow syntax drift"),
-        (
-            "examples/benchmarks/file_io.ruff",
-            "benchmark fixture contains parser-incompatible syntax",
-        ),
         (
             "examples/benchmarks/sorting_algorithms.ruff",
             "benchmark fixture kept as negative-coverage debt",
@@ -112,7 +109,6 @@ fn expected_fail_examples_with_reason() -> [(&'static str, &'static str); 28] {
         ("examples/http_streaming.ruff", "legacy loop syntax drift"),
         ("examples/io_module_demo.ruff", "legacy IO module example drift"),
         ("examples/math_module.ruff", "legacy math module example drift"),
-        ("examples/pattern_matching.ruff", "pattern-matching syntax drift in legacy example"),
         (
             "examples/project_api_tester.ruff",
             "named-argument style not supported by current parser",
@@ -143,7 +139,6 @@ fn expected_fail_examples_with_reason() -> [(&'static str, &'static str); 28] {
         ),
         ("examples/projects/log_parser.ruff", "project example has unresolved parse/runtime debt"),
         ("examples/projects/streaming_downloader.ruff", "legacy loop syntax drift"),
-        ("examples/spread_operator_demo.ruff", "spread/index syntax drift in legacy example"),
         ("examples/string_functions.ruff", "legacy single-quote argument syntax drift"),
         ("examples/stdlib_crypto.ruff", "legacy loop syntax drift"),
         ("examples/struct_self_methods.ruff", "struct method example has unresolved syntax debt"),
@@ -257,7 +252,12 @@ fn examples_smoke_parse_run_or_expected_fail() {
             }
             SmokeMode::ParseOnly => {
                 let output = run_ruff(
-                    &["check", file.to_str().expect("path should be utf-8"), "--quiet"],
+                    &[
+                        "check",
+                        file.to_str().expect("path should be utf-8"),
+                        "--quiet",
+                        "--no-types",
+                    ],
                     &root,
                 );
                 if !output.status.success() {
@@ -272,7 +272,12 @@ fn examples_smoke_parse_run_or_expected_fail() {
             }
             SmokeMode::ExpectedFail => {
                 let output = run_ruff(
-                    &["check", file.to_str().expect("path should be utf-8"), "--quiet"],
+                    &[
+                        "check",
+                        file.to_str().expect("path should be utf-8"),
+                        "--quiet",
+                        "--no-types",
+                    ],
                     &root,
                 );
                 if output.status.success() {
@@ -305,7 +310,12 @@ fn docs_ruff_snippets_parse_or_expected_fail() {
             fs::write(&snippet_file, snippet).expect("failed to write snippet file");
 
             let output = run_ruff(
-                &["check", snippet_file.to_str().expect("snippet path should be utf-8"), "--quiet"],
+                &[
+                    "check",
+                    snippet_file.to_str().expect("snippet path should be utf-8"),
+                    "--quiet",
+                    "--no-types",
+                ],
                 &root,
             );
 
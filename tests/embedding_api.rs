@@ -0,0 +1,157 @@
+use ruff::interpreter::Value;
+use ruff::{from_value, to_value, Ruff, Variadic};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::sync::{Arc, Mutex};
+
+#[derive(Debug, Serialize, Deserialize, PartialEq)]
+struct ServerConfig {
+    host: String,
+    port: u16,
+    tags: Vec<String>,
+    limits: HashMap<String, f64>,
+    proxy: Option<String>,
+}
+
+#[test]
+fn eval_returns_the_trailing_expression_and_keeps_globals() {
+    let mut ruff = Ruff::new();
+    assert!(matches!(ruff.eval("base := 40").expect("assignment should run"), Value::Null));
+    assert!(matches!(ruff.eval("base + 2").expect("expression should evaluate"), Value::Int(42)));
+
+    let output = Arc::new(Mutex::new(Vec::new()));
+    ruff.set_output(Arc::clone(&output));
+    ruff.eval("print(\"base is ${base}\")").expect("print should run");
+    assert_eq!(String::from_utf8(output.lock().unwrap().clone()).unwrap(), "base is 40\n");
+}
+
+#[test]
+fn call_invokes_ruff_functions_with_host_values() {
+    let mut ruff = Ruff::new();
+    ruff.eval(
+        "func describe(config) {\n    return config[\"host\"] + \":\" + to_string(config[\"port\"])\n}\n\
+         func double_all(items) {\n    return map(items, func(x) { return x * 2 })\n}",
+    )
+    .expect("definitions should load");
+
+    let config = ServerConfig {
+        host: "localhost".to_string(),
+        port: 8080,
+        tags: vec![],
+        limits: HashMap::new(),
+        proxy: None,
+    };
+    let described = ruff
+        .call("describe", &[to_value(&config).expect("config should convert")])
+        .expect("describe should run");
+    assert_eq!(from_value::<String>(&described).unwrap(), "localhost:8080");
+
+    let doubled =
+        ruff.call("double_all", &[to_value(&[1, 2, 3]).unwrap()]).expect("double_all should run");
+    assert_eq!(from_value::<Vec<i64>>(&doubled).unwrap(), vec![2, 4, 6]);
+}
+
+#[test]
+fn ruff_values_convert_into_host_structs() {
+    let mut ruff = Ruff::new();
+    let value = ruff
+        .eval(
+            "struct Limits { cpu, memory }\n\
+             limits := Limits { cpu: 1.5, memory: 512.0 }\n\
+             {\"host\": \"0.0.0.0\", \"port\": 9000, \"tags\": [\"a\", \"b\"], \
+             \"limits\": {\"cpu\": limits.cpu, \"memory\": limits.memory}, \"proxy\": null}",
+        )
+        .expect("config literal should evaluate");
+    let config: ServerConfig = from_value(&value).expect("dict should convert into the struct");
+    assert_eq!(config.host, "0.0.0.0");
+    assert_eq!(config.port, 9000);
+    assert_eq!(config.tags, vec!["a", "b"]);
+    assert_eq!(config.limits["memory"], 512.0);
+    assert_eq!(config.proxy, None);
+
+    let instance = ruff.eval("limits").expect("struct instance should evaluate");
+    let limits: HashMap<String, f64> = from_value(&instance).expect("structs convert like dicts");
+    assert_eq!(limits["cpu"], 1.5);
+}
+
+#[test]
+fn errors_report_messages_and_positions() {
+    let mut ruff = Ruff::new();
+
+    let parse_error = ruff.eval("x := (1 +").expect_err("incomplete source should fail");
+    assert!(matches!(parse_error.kind, ruff::errors::ErrorKind::ParseError));
+
+    let runtime_error =
+        ruff.eval("ok := 1\nmissing_function()").expect_err("undefined call should fail");
+    assert!(runtime_error.message.contains("missing_function"), "{}", runtime_error.message);
+
+    let statement_error = ruff.eval("a := 1\nb := a / 0\nc := 3").expect_err("should fail");
+    assert_eq!(statement_error.location.line, 2);
+
+    ruff.set("limit", to_value(&3).unwrap());
+    ruff.eval("func check(n) {\n    if n > limit { throw(\"too big\") }\n    return n\n}").unwrap();
+    assert!(matches!(ruff.call("check", &[Value::Int(2)]), Ok(Value::Int(2))));
+    let thrown = ruff.call("check", &[Value::Int(5)]).expect_err("throw should surface");
+    assert!(thrown.message.contains("too big"), "{}", thrown.message);
+    assert!(ruff.call("limit", &[]).is_err(), "non-functions cannot be called");
+    assert!(ruff.call("nope", &[]).is_err(), "unknown functions are reported");
+}
+
+#[test]
+fn registered_host_functions_marshal_arguments_and_results() {
+    let mut ruff = Ruff::new();
+    ruff.register("host_greet", |name: String, times: Option<usize>| {
+        vec![format!("hi {}", name); times.unwrap_or(1)]
+    });
+    ruff.register("host_sum", |Variadic(numbers): Variadic<f64>| numbers.iter().sum::<f64>());
+    ruff.register("host_port", |config: ServerConfig| config.port);
+    ruff.register("host_kind", |value: Value| match value {
+        Value::Int(_) => "int",
+        _ => "other",
+    });
+
+    let greetings = ruff.eval("host_greet(\"ada\", 2)").expect("host function should run");
+    assert_eq!(from_value::<Vec<String>>(&greetings).unwrap(), vec!["hi ada", "hi ada"]);
+    assert!(matches!(ruff.eval("len(host_greet(\"bob\"))"), Ok(Value::Int(1))));
+    assert!(matches!(ruff.eval("host_sum(1, 2.5, 3)"), Ok(Value::Float(total)) if total == 6.5));
+    assert!(matches!(ruff.eval("host_sum()"), Ok(Value::Float(total)) if total == 0.0));
+    let port = ruff
+        .eval("host_port({\"host\": \"h\", \"port\": 81, \"tags\": [], \"limits\": {}, \"proxy\": null})")
+        .expect("dict should convert into the host struct");
+    assert!(matches!(port, Value::Int(81)));
+    assert_eq!(from_value::<String>(&ruff.eval("host_kind(5)").unwrap()).unwrap(), "int");
+
+    // Scripts can pass host functions around like any other function
+    ruff.eval("func twice(f, x) { return f(f(x)) }").unwrap();
+    ruff.register("host_inc", |n: i64| n + 1);
+    let inc = ruff.get("host_inc").expect("registered functions are globals");
+    assert!(matches!(ruff.call("twice", &[inc, Value::Int(1)]), Ok(Value::Int(3))));
+}
+
+#[test]
+fn host_function_errors_become_ruff_errors() {
+    let mut ruff = Ruff::new();
+    ruff.register("host_fetch", |url: String| -> Result<String, String> {
+        match url.strip_prefix("mem://") {
+            Some(path) => Ok(format!("contents of {}", path)),
+            None => Err(format!("unsupported url '{}'", url)),
+        }
+    });
+
+    let caught = ruff
+        .eval(
+            "result := \"\"\ntry {\n    host_fetch(\"ftp://x\")\n} except err {\n    result = err.message\n}\nresult",
+        )
+        .expect("try/except should catch the host error");
+    assert_eq!(from_value::<String>(&caught).unwrap(), "unsupported url 'ftp://x'");
+
+    let uncaught = ruff.eval("host_fetch(\"ftp://y\")").expect_err("errors propagate");
+    assert!(uncaught.message.contains("unsupported url 'ftp://y'"), "{}", uncaught.message);
+
+    let wrong_type = ruff.eval("host_fetch(42)").expect_err("arguments are type checked");
+    assert!(wrong_type.message.contains("host_fetch() argument 1"), "{}", wrong_type.message);
+    let missing = ruff.eval("host_fetch()").expect_err("required arguments are checked");
+    assert!(missing.message.contains("argument 1: missing"), "{}", missing.message);
+    let extra = ruff.eval("host_fetch(\"mem://a\", 2)").expect_err("extra arguments fail");
+    assert!(extra.message.contains("expects 1 argument, got 2"), "{}", extra.message);
+}
@@ -323,6 +323,122 @@ fn test_read_file_lossy_and_bytes_slice_helpers() {
     }
 }
 
+#[test]
+fn test_fs_namespace_read_write_stat_glob_and_walk() {
+    let unique = unique_shared_key("fs_namespace");
+    let temp_dir = std::env::temp_dir().join(format!("ruff_{}", unique));
+    let root = temp_dir.to_string_lossy().to_string();
+    let code = format!(
+        r#"
+        fs.mkdir_all("{root}/src/nested")
+        fs.mkdir_all("{root}/.cache")
+        fs.write("{root}/main.ruff", "one")
+        fs.append("{root}/main.ruff", "+two")
+        fs.write("{root}/src/lib.ruff", "lib")
+        fs.write("{root}/src/nested/deep.ruff", "deep")
+        fs.write("{root}/src/notes.txt", "notes")
+        fs.write("{root}/.cache/skip.ruff", "hidden")
+        text := fs.read("{root}/main.ruff")
+        present := fs.exists("{root}/src")
+        absent := fs.exists("{root}/nope")
+        info := fs.stat("{root}/main.ruff")
+        sources := fs.glob("{root}/**/*.ruff")
+        top_level := fs.glob("{root}/src/*.[rt]*")
+        visited := fs.walk("{root}", func(entry) {{
+            return entry["name"] != "src"
+        }})
+        missing := fs.read("{root}/nope")
+    "#,
+        root = root
+    );
+
+    let interp = run_code(&code);
+
+    assert!(matches!(interp.env.get("text"), Some(Value::Str(text)) if text.as_ref() == "one+two"));
+    assert!(matches!(interp.env.get("present"), Some(Value::Bool(true))));
+    assert!(matches!(interp.env.get("absent"), Some(Value::Bool(false))));
+    match interp.env.get("info") {
+        Some(Value::Dict(info)) => {
+            assert!(matches!(info.get("size"), Some(Value::Int(7))));
+            assert!(matches!(info.get("is_file"), Some(Value::Bool(true))));
+            assert!(matches!(info.get("is_dir"), Some(Value::Bool(false))));
+            assert!(matches!(info.get("mtime"), Some(Value::Int(mtime)) if *mtime > 0));
+            assert!(matches!(info.get("mode"), Some(Value::Int(_))));
+        }
+        _ => panic!("Expected fs.stat to return a dict"),
+    }
+
+    let paths = |name: &str| match interp.env.get(name) {
+        Some(Value::Array(items)) => items
+            .iter()
+            .map(|item| match item {
+                Value::Str(path) => path.trim_start_matches(root.as_str()).to_string(),
+                other => panic!("Expected glob path string, got {:?}", other),
+            })
+            .collect::<Vec<_>>(),
+        _ => panic!("Expected fs.glob to return an array"),
+    };
+    assert_eq!(paths("sources"), vec!["/main.ruff", "/src/lib.ruff", "/src/nested/deep.ruff"]);
+    assert_eq!(paths("top_level"), vec!["/src/lib.ruff", "/src/notes.txt"]);
+
+    // .cache, .cache/skip.ruff, main.ruff, src (contents pruned by returning false)
+    assert!(matches!(interp.env.get("visited"), Some(Value::Int(4))));
+    match interp.env.get("missing") {
+        Some(Value::Error(message)) => assert!(message.starts_with("Cannot read file")),
+        _ => panic!("Expected fs.read of a missing file to return an error"),
+    }
+
+    let _ = std::fs::remove_dir_all(&temp_dir);
+}
+
+#[test]
+fn test_get_path_and_set_path_nested_data_access() {
+    let code = r#"
+        config := parse_json("{\"servers\": [{\"host\": \"a.example\", \"ports\": [80, 443]}], \"a.b\": 7}")
+        host := get_path(config, "servers[0].host")
+        port := get_path(config, "servers.0.ports[1]")
+        dotted := get_path(config, ["a.b"])
+        quoted := get_path(config, "[\"a.b\"]")
+        missing := get_path(config, "servers[3].host", "fallback")
+        through_scalar := get_path(config, "servers[0].host.name")
+        missing_null := get_path(config, "nope.deeper")
+
+        updated := set_path(config, "servers[0].ports[3]", 8080)
+        created := set_path({}, "db.replicas[1].name", "r1")
+        original_ports := len(get_path(config, "servers[0].ports"))
+        padded := get_path(updated, "servers[0].ports[2]", "unset")
+        grown := get_path(updated, "servers[0].ports[3]")
+        replica := get_path(created, "db.replicas[1].name")
+        replica_hole := get_path(created, "db.replicas[0]", "unset")
+    "#;
+
+    let interp = run_code(code);
+
+    assert!(matches!(interp.env.get("host"), Some(Value::Str(s)) if s.as_str() == "a.example"));
+    assert!(matches!(interp.env.get("port"), Some(Value::Int(443))));
+    assert!(matches!(interp.env.get("dotted"), Some(Value::Int(7))));
+    assert!(matches!(interp.env.get("quoted"), Some(Value::Int(7))));
+    assert!(matches!(interp.env.get("missing"), Some(Value::Str(s)) if s.as_str() == "fallback"));
+    assert!(matches!(interp.env.get("through_scalar"), Some(Value::Null)));
+    assert!(matches!(interp.env.get("missing_null"), Some(Value::Null)));
+
+    assert!(matches!(interp.env.get("original_ports"), Some(Value::Int(2))));
+    assert!(matches!(interp.env.get("padded"), Some(Value::Null)));
+    assert!(matches!(interp.env.get("grown"), Some(Value::Int(8080))));
+    assert!(matches!(interp.env.get("replica"), Some(Value::Str(s)) if s.as_str() == "r1"));
+    assert!(matches!(interp.env.get("replica_hole"), Some(Value::Null)));
+
+    let interp = run_code(r#"bad_path := get_path({"a": 1}, "a..b")"#);
+    assert!(
+        matches!(interp.env.get("bad_path"), Some(Value::Error(message)) if message.contains("get_path() invalid path"))
+    );
+
+    let interp = run_code(r#"set_scalar := set_path({"a": 1}, "a.b", 2)"#);
+    assert!(
+        matches!(interp.env.get("set_scalar"), Some(Value::Error(message)) if message.contains("non-container"))
+    );
+}
+
 #[test]
 fn test_bytes_indexing_and_interpolated_invalid_expression_are_rejected() {
     let code = r#"
@@ -980,6 +1096,64 @@ fn test_function_modifies_outer_variable() {
     }
 }
 
+#[test]
+fn test_tail_calls_run_in_constant_stack_space() {
+    // Self and mutual tail recursion far past the interpreter call-depth limit
+    let code = r#"
+        func count_down(n, acc) {
+            if n == 0 {
+                return acc
+            }
+            return count_down(n - 1, acc + 1)
+        }
+
+        func is_even(n) {
+            if n == 0 { return true }
+            return is_odd(n - 1)
+        }
+
+        func is_odd(n) {
+            if n == 0 { return false }
+            return is_even(n - 1)
+        }
+
+        counted := count_down(20000, 0)
+        even := is_even(10001)
+    "#;
+
+    let interp = run_code(code);
+
+    assert!(matches!(interp.env.get("counted"), Some(Value::Int(20000))));
+    assert!(matches!(interp.env.get("even"), Some(Value::Bool(false))));
+}
+
+#[test]
+fn test_tail_call_inside_try_keeps_errors_catchable() {
+    let code = r#"
+        func fail_at(n) {
+            if n == 0 { throw("bottom") }
+            return fail_at(n - 1)
+        }
+
+        func guarded() {
+            try {
+                return fail_at(3)
+            } except err {
+                return "caught " + err.message
+            }
+        }
+
+        message := guarded()
+    "#;
+
+    let interp = run_code(code);
+
+    match interp.env.get("message") {
+        Some(Value::Str(message)) => assert_eq!(message.as_str(), "caught bottom"),
+        other => panic!("Expected caught error message, got {:?}", other),
+    }
+}
+
 #[test]
 fn test_nested_for_loops_scoping() {
     // Nested loops should each have their own scope
@@ -1028,6 +1202,54 @@ fn test_scope_chain_lookup() {
     }
 }
 
+#[test]
+fn test_closure_creating_calls_free_their_frames() {
+    // A closure stored in its defining frame must not keep that frame alive once the
+    // call returns. Every leaked frame would hold another reference to `items`.
+    let code = r#"
+        items := [1, 2, 3]
+        func make_reader(source) {
+            keep := source
+            reader := func() { return len(keep) }
+            return reader()
+        }
+        func walk_nested(source) {
+            keep := source
+            func walk(n) {
+                if n == 0 {
+                    return len(keep)
+                }
+                return walk(n - 1)
+            }
+            return walk(3)
+        }
+        func make_counter(source) {
+            keep := source
+            mut count := 0
+            func bump() {
+                count = count + len(keep)
+                return count
+            }
+            return bump
+        }
+        mut total := 0
+        for i in range(50) {
+            total = total + make_reader(items) + walk_nested(items)
+            counter := make_counter(items)
+            counter()
+            total = total + counter()
+        }
+    "#;
+
+    let interp = run_code(code);
+    assert!(matches!(interp.env.get("total"), Some(Value::Int(600))));
+    let Some(Value::Array(items)) = interp.env.get("items") else {
+        panic!("Expected items to be an array");
+    };
+    // The global binding plus the clone returned by `get`
+    assert_eq!(std::sync::Arc::strong_count(&items), 2);
+}
+
 #[test]
 fn test_try_except_scoping() {
     // try/except should have proper scope isolation
@@ -1185,6 +1407,29 @@ fn test_environment_mutate_checked_respects_binding_mutability() {
     assert!(const_err.contains("Cannot mutate const binding: const_counts"));
 }
 
+#[test]
+fn test_environment_globals_version_tracks_global_writes() {
+    let mut env = Environment::new();
+    let start = env.globals_version().expect("no nested scopes yet");
+
+    env.define("x".to_string(), Value::Int(1));
+    let defined = env.globals_version().unwrap();
+    assert_ne!(defined, start);
+
+    assert!(env.assign_checked("x".to_string(), Value::Int(2)).is_ok());
+    let assigned = env.globals_version().unwrap();
+    assert_ne!(assigned, defined);
+
+    let _ = env.get("x");
+    assert_eq!(env.globals_version(), Some(assigned), "reads must not invalidate caches");
+
+    env.push_scope();
+    assert_eq!(env.globals_version(), None, "shadowing scopes disable global caching");
+    env.define("y".to_string(), Value::Int(3));
+    env.pop_scope();
+    assert_eq!(env.globals_version(), Some(assigned), "scoped writes leave globals alone");
+}
+
 // Input and type conversion function tests
 
 #[test]
@@ -3064,9 +3309,7 @@ fn test_channel_receive_blocks_until_value_is_sent() {
 
     std::thread::spawn(move || {
         std::thread::sleep(std::time::Duration::from_millis(15));
-        let chan_lock = channel.lock().expect("channel lock should succeed");
-        let (sender, _) = &*chan_lock;
-        sender.send(Value::Int(42)).expect("send should succeed");
+        channel.send(Value::Int(42)).expect("send should succeed");
     });
 
     let code = r#"
@@ -3114,6 +3357,96 @@ fn test_channel_receive_arity_error_via_expression_method_call() {
     );
 }
 
+#[test]
+fn test_channel_close_drains_buffered_values_then_yields_null() {
+    let code = r#"
+        ch := chan()
+        ch.send(1)
+        ch.close()
+
+        first := ch.receive()
+        drained := ch.receive()
+        closed := ch.is_closed()
+        send_after_close := ch.send(2)
+    "#;
+
+    let interp = run_code(code);
+    assert!(matches!(interp.env.get("first"), Some(Value::Int(1))));
+    assert!(matches!(interp.env.get("drained"), Some(Value::Null)));
+    assert!(matches!(interp.env.get("closed"), Some(Value::Bool(true))));
+    assert!(matches!(
+        interp.env.get("send_after_close"),
+        Some(Value::Error(message)) if message.contains("closed channel")
+    ));
+}
+
+#[test]
+fn test_spawn_call_form_joins_workers_with_wait_group() {
+    let code = r#"
+        func worker(id, results, wg) {
+            results.send(id * 10)
+            wg.done()
+        }
+
+        results := chan()
+        wg := wait_group()
+        for i in range(5) {
+            wg.add(1)
+            spawn worker(i, results, wg)
+        }
+        wg.wait()
+        results.close()
+
+        total := 0
+        loop {
+            value := results.receive()
+            if value == null {
+                break
+            }
+            total += value
+        }
+        pending := wg.pending()
+        negative := wg.done()
+    "#;
+
+    let interp = run_code(code);
+    assert!(matches!(interp.env.get("total"), Some(Value::Int(100))));
+    assert!(matches!(interp.env.get("pending"), Some(Value::Int(0))));
+    assert!(matches!(
+        interp.env.get("negative"),
+        Some(Value::Error(message)) if message.contains("cannot go negative")
+    ));
+}
+
+#[test]
+fn test_select_returns_first_ready_channel_or_times_out() {
+    let code = r#"
+        idle := chan()
+        busy := chan()
+        busy.send("ready")
+
+        picked := select([idle, busy])
+        timed_out := select([idle, busy], 5)
+        idle.close()
+        busy.close()
+        all_closed := select([idle, busy])
+    "#;
+
+    let interp = run_code(code);
+    let picked = match interp.env.get("picked") {
+        Some(Value::Array(values)) => values,
+        other => panic!("expected select result array, got {other:?}"),
+    };
+    assert!(matches!(picked[0], Value::Int(1)));
+    assert!(matches!(&picked[1], Value::Str(text) if text.as_str() == "ready"));
+    for name in ["timed_out", "all_closed"] {
+        assert!(
+            matches!(interp.env.get(name), Some(Value::Array(values)) if matches!(values[0], Value::Int(-1))),
+            "{name} should report no ready channel"
+        );
+    }
+}
+
 #[test]
 fn test_shared_value_lifecycle_operations() {
     let shared_key = unique_shared_key("shared_value_lifecycle");
@@ -3337,6 +3670,51 @@ fn test_promise_all_reuses_cached_polled_promise_results() {
     assert!(matches!(interp.env.get("ok"), Some(Value::Bool(true))));
 }
 
+#[test]
+fn test_promise_then_chains_values_and_flattens_returned_promises() {
+    let code = r#"
+        async func double(n) {
+            await async_sleep(1)
+            return n * 2
+        }
+
+        source := double(5)
+        incremented := await source.then(func(v) { return v + 1 })
+        original := await source
+        flattened := await double(1).then(func(v) { return double(v) }).then(func(v) { return "got " + to_string(v) })
+    "#;
+
+    let interp = run_code(code);
+    assert!(matches!(interp.env.get("incremented"), Some(Value::Int(11))));
+    assert!(matches!(interp.env.get("original"), Some(Value::Int(10))));
+    assert!(
+        matches!(interp.env.get("flattened"), Some(Value::Str(text)) if text.as_str() == "got 4")
+    );
+}
+
+#[test]
+fn test_promise_catch_and_finally_observe_async_function_rejection() {
+    let code = r#"
+        async func fail() {
+            await async_sleep(1)
+            throw("boom")
+        }
+
+        skipped := await fail().then(func(v) { return "unreachable" }).catch(func(e) { return "caught " + e.message })
+        passed := await fail().finally(func() { return "cleanup result is ignored" }).catch(func(e) { return e.message })
+        bad_arity := fail().then()
+    "#;
+
+    let interp = run_code(code);
+    assert!(
+        matches!(interp.env.get("skipped"), Some(Value::Str(text)) if text.as_str() == "caught boom")
+    );
+    assert!(matches!(interp.env.get("passed"), Some(Value::Str(text)) if text.as_str() == "boom"));
+    assert!(
+        matches!(interp.env.get("bad_arity"), Some(Value::Error(message)) if message.contains("Promise.then expects 1 argument"))
+    );
+}
+
 #[test]
 fn test_promise_all_rejects_zero_concurrency_limit() {
     let code = r#"
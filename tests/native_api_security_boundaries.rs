@@ -415,6 +415,33 @@ fn native_capability_untrusted_denies_process_exec() {
     );
 }
 
+#[test]
+fn native_capability_untrusted_denies_proc_namespace() {
+    assert_runtime_boundary_failure_with_args(
+        "proc.run(\"echo\", [\"ok\"])\n",
+        "Capability denied: process-exec required for proc.run",
+        &["--interpreter", "--untrusted"],
+    );
+}
+
+#[test]
+fn native_capability_untrusted_denies_os_env() {
+    assert_runtime_boundary_failure_with_args(
+        "os.env(\"HOME\")\n",
+        "Capability denied: env-read required for os.env",
+        &["--interpreter", "--untrusted"],
+    );
+}
+
+#[test]
+fn native_capability_untrusted_denies_net_dial() {
+    assert_runtime_boundary_failure_with_args(
+        "net.dial(\"tcp\", \"127.0.0.1:9\")\n",
+        "Capability denied: network-client required for net.dial",
+        &["--interpreter", "--untrusted"],
+    );
+}
+
 #[test]
 fn native_capability_untrusted_denies_shell_exec() {
     assert_runtime_boundary_failure_with_args(
@@ -494,6 +521,15 @@ fn native_capability_untrusted_denies_network_server() {
     );
 }
 
+#[test]
+fn native_capability_untrusted_denies_ffi() {
+    assert_runtime_boundary_failure_with_args(
+        "lib := ffi.load(\"libm.so.6\")\n",
+        "Capability denied: ffi required for ffi.load",
+        &["--interpreter", "--untrusted", "--allow-fs-read"],
+    );
+}
+
 #[test]
 fn network_http_get_rejects_oversized_response_body() {
     let body = vec![b'Z'; NETWORK_MAX_BODY_BYTES_FOR_TEST + 1];
@@ -813,6 +849,15 @@ fn native_capability_untrusted_denies_database() {
     );
 }
 
+#[test]
+fn native_capability_untrusted_denies_sqlite_open() {
+    assert_runtime_boundary_failure_with_args(
+        "sqlite.open(\":memory:\")\n",
+        "Capability denied: database required for sqlite.open",
+        &["--interpreter", "--untrusted"],
+    );
+}
+
 #[test]
 fn native_capability_untrusted_denies_clock() {
     assert_runtime_boundary_failure_with_args(
@@ -110,3 +110,19 @@ fn to_json_rejects_unsupported_value_types() {
         .expect_err("native function should not serialize to json");
     assert!(error.contains("Cannot convert"), "expected unsupported-type error, got: {}", error);
 }
+
+#[test]
+fn to_json_indented_uses_custom_indent_and_falls_back_to_compact() {
+    let mut nested = DictMap::default();
+    nested.insert(Arc::<str>::from("a"), Value::Array(Arc::new(vec![Value::Int(1)])));
+    let value = Value::Dict(Arc::new(nested));
+
+    assert_eq!(
+        builtins::to_json_indented(&value, "    ").expect("indented stringify should succeed"),
+        "{\n    \"a\": [\n        1\n    ]\n}"
+    );
+    assert_eq!(
+        builtins::to_json_indented(&value, "").expect("empty indent should stringify compactly"),
+        "{\"a\":[1]}"
+    );
+}
@@ -600,3 +600,92 @@ fn package_module_workflow_nested_layout_is_runtime_mode_consistent_and_keeps_fl
         );
     }
 }
+
+fn git(args: &[&str], current_dir: &Path) {
+    let output = Command::new("git")
+        .current_dir(current_dir)
+        .args(["-c", "user.name=ruff", "-c", "user.email=ruff@example.com"])
+        .args(args)
+        .output()
+        .expect("failed to execute git");
+    assert!(output.status.success(), "git {:?} failed: {}", args, stderr_text(&output));
+}
+
+#[test]
+fn package_get_fetches_git_dependency_at_pinned_rev_and_imports_resolve() {
+    let root = unique_temp_dir("package_get_git");
+    let dependency_repo = root.join("textlib");
+    fs::create_dir_all(dependency_repo.join("src")).expect("failed to create dependency repo");
+    git(&["init", "--quiet"], &dependency_repo);
+    fs::write(dependency_repo.join("src/lib.ruff"), "export version := \"v1\"\n")
+        .expect("failed to write dependency entry module");
+    fs::write(
+        dependency_repo.join("src/fmt.ruff"),
+        "export func pad(s) {\n    return \"[\" + s + \"]\"\n}\n",
+    )
+    .expect("failed to write dependency module");
+    git(&["add", "-A"], &dependency_repo);
+    git(&["commit", "--quiet", "-m", "v1"], &dependency_repo);
+    git(&["tag", "v1"], &dependency_repo);
+    fs::write(dependency_repo.join("src/lib.ruff"), "export version := \"v2\"\n")
+        .expect("failed to update dependency entry module");
+    git(&["commit", "--quiet", "-am", "v2"], &dependency_repo);
+
+    let project_root = root.join("app");
+    let project_root_str = project_root.to_str().expect("path should be utf-8");
+    let init = run_ruff(&["init", "--dir", project_root_str, "--name", "get_demo"], &root);
+    assert!(init.status.success(), "ruff init failed: {}", stderr_text(&init));
+
+    let manifest_path = project_root.join("ruff.toml");
+    let mut manifest = fs::read_to_string(&manifest_path).expect("failed to read manifest");
+    manifest.push_str(&format!(
+        "textlib = {{ git = {:?}, rev = \"v1\" }}\nhttp = \"1.2.3\"\n",
+        dependency_repo.to_str().expect("path should be utf-8")
+    ));
+    fs::write(&manifest_path, manifest).expect("failed to write manifest");
+
+    let get = run_ruff(&["get"], &project_root);
+    assert!(
+        get.status.success(),
+        "ruff get failed: stdout={} stderr={}",
+        stdout_text(&get),
+        stderr_text(&get)
+    );
+    assert!(stdout_text(&get).contains("fetched\ttextlib\tgit+"));
+    assert!(stdout_text(&get).contains("skipped\thttp\t1.2.3"));
+
+    let lockfile = fs::read_to_string(project_root.join("ruff.lock")).expect("missing ruff.lock");
+    assert!(lockfile.contains("[resolved.textlib]"), "lockfile should pin textlib: {}", lockfile);
+    assert!(project_root.join(".ruff/deps/textlib/src/lib.ruff").exists());
+
+    let script = project_root.join("src/main.ruff");
+    fs::write(
+        &script,
+        "import \"textlib\"\nfrom textlib.fmt import pad\nprint(pad(textlib.version))\n",
+    )
+    .expect("failed to write entry script");
+    for args in [vec!["run", "src/main.ruff"], vec!["run", "--interpreter", "src/main.ruff"]] {
+        let output = run_ruff(&args, &project_root);
+        assert!(
+            output.status.success(),
+            "dependency import failed: args={:?} stdout={} stderr={}",
+            args,
+            stdout_text(&output),
+            stderr_text(&output)
+        );
+        assert!(
+            stdout_text(&output).contains("[v1]"),
+            "expected pinned v1 checkout: args={:?} stdout={}",
+            args,
+            stdout_text(&output)
+        );
+    }
+
+    let frozen = run_ruff(&["get", "--frozen"], &project_root);
+    assert!(frozen.status.success(), "ruff get --frozen failed: {}", stderr_text(&frozen));
+    assert_eq!(
+        fs::read_to_string(project_root.join("ruff.lock")).expect("missing ruff.lock"),
+        lockfile,
+        "frozen get must not rewrite the lockfile"
+    );
+}
@@ -1,3 +1,4 @@
+use ruff::ast::Stmt;
 use ruff::lexer::tokenize;
 use ruff::parser::{ParseOutput, Parser, ParserLimits, DEFAULT_MAX_SOURCE_BYTES};
 use std::fs;
@@ -142,6 +143,31 @@ fn parser_reports_diagnostic_for_invalid_from_import_token_order() {
         .contains("Expected 'import' after module name in from-import statement")));
 }
 
+#[test]
+fn parser_accepts_path_import_and_derives_namespace_from_file_name() {
+    let output = parse_output("import \"lib/text_utils.ruff\"\n");
+    assert!(
+        output.diagnostics.is_empty(),
+        "expected path import to parse, got {:?}",
+        output.diagnostics
+    );
+    match &output.stmts[0] {
+        ruff::ast::Stmt::ImportPath { path, namespace } => {
+            assert_eq!(path, "lib/text_utils.ruff");
+            assert_eq!(namespace, "text_utils");
+        }
+        other => panic!("expected path import statement, got {:?}", other),
+    }
+}
+
+#[test]
+fn parser_reports_diagnostic_for_path_import_without_identifier_file_name() {
+    let output = parse_output("import \"lib/text-utils\"\n");
+    assert!(output.diagnostics.iter().any(|diagnostic| diagnostic
+        .message
+        .contains("does not end in a file name usable as a namespace")));
+}
+
 #[test]
 fn parser_keeps_existing_flat_import_forms_unchanged() {
     let output = parse_output("import math_helper\nfrom utils import helper, formatter\n");
@@ -403,3 +429,43 @@ fn cli_run_accepts_source_at_max_size_boundary() {
         String::from_utf8_lossy(&output.stderr)
     );
 }
+
+#[test]
+fn parser_with_source_positions_marks_each_statement_in_blocks() {
+    let source = "x := 1\nfunc f(a) {\n    return a\n}\n";
+    let tokens = tokenize(source).expect("test source should tokenize");
+    let output = Parser::new(tokens).with_source_positions().parse_with_diagnostics();
+
+    assert!(output.diagnostics.is_empty(), "unexpected diagnostics: {:?}", output.diagnostics);
+    assert_eq!(output.stmts.len(), 4);
+    assert!(matches!(output.stmts[0], Stmt::SourcePos { line: 1, column: 1 }));
+    assert!(matches!(output.stmts[2], Stmt::SourcePos { line: 2, column: 1 }));
+    match &output.stmts[3] {
+        Stmt::FuncDef { body, .. } => {
+            assert!(matches!(body[0], Stmt::SourcePos { line: 3, column: 5 }));
+            assert!(matches!(body[1], Stmt::Return(_)));
+        }
+        other => panic!("expected function definition, got {:?}", other),
+    }
+
+    // The default parser keeps the AST free of position markers.
+    assert_eq!(parse_output(source).stmts.len(), 2);
+}
+
+#[test]
+fn cli_run_parse_error_shows_source_excerpt_with_caret_and_hint() {
+    let dir = unique_temp_dir("cli_run_parse_excerpt");
+    let file = dir.join("unclosed.ruff");
+    write_fixture(&file, "values := [1, 2\nlet total := values + 3\n");
+
+    let output = run_ruff(&["run", file.to_str().expect("path should be utf-8")]);
+    assert_eq!(output.status.code(), Some(3));
+
+    let stderr = String::from_utf8(output.stderr).expect("stderr should be utf-8");
+    assert!(
+        stderr.contains(" 2 | let total := values + 3\n   | ^^^\n"),
+        "expected excerpt with caret, got: {}",
+        stderr
+    );
+    assert!(stderr.contains("= help: Insert ']' before the highlighted token."), "{}", stderr);
+}
@@ -48,6 +48,13 @@ fn expr_shape(expr: &Expr) -> String {
             let rendered_args = args.iter().map(expr_shape).collect::<Vec<_>>().join(" ");
             format!("(call {} {})", expr_shape(function), rendered_args)
         }
+        Expr::Ternary { condition, then_expr, else_expr } => format!(
+            "(? {} {} {})",
+            expr_shape(condition),
+            expr_shape(then_expr),
+            expr_shape(else_expr)
+        ),
+        Expr::Try(inner) => format!("(try {})", expr_shape(inner)),
         _ => format!("{:?}", expr),
     }
 }
@@ -96,6 +103,76 @@ fn parser_precedence_parentheses_override_default_order() {
     assert_eq!(shape, "(* (+ 1 2) 3)");
 }
 
+#[test]
+fn parser_precedence_ternary_below_comparison_and_boolean() {
+    let shape = parse_single_expr_shape("a < b || c ? x + 1 : y * 2\n");
+    assert_eq!(shape, "(? (|| (< a b) c) (+ x 1) (* y 2))");
+}
+
+#[test]
+fn parser_precedence_ternary_chains_right_associative() {
+    let shape = parse_single_expr_shape("a ? b : c ? d : e\n");
+    assert_eq!(shape, "(? a b (? c d e))");
+
+    let shape = parse_single_expr_shape("a ? b ? c : d : e\n");
+    assert_eq!(shape, "(? a (? b c d) e)");
+}
+
+#[test]
+fn parser_precedence_parenthesized_ternary_composes_in_arithmetic() {
+    let shape = parse_single_expr_shape("(n > 0 ? 1 : -1) * mag\n");
+    assert_eq!(shape, "(* (? (> n 0) 1 (- 1)) mag)");
+}
+
+#[test]
+fn parser_postfix_try_is_not_mistaken_for_ternary() {
+    assert_eq!(parse_single_expr_shape("f(r?)\n"), "(call f (try r))");
+    assert_eq!(parse_single_expr_shape("r? + 1\n"), "(+ (try r) 1)");
+    assert_eq!(parse_single_expr_shape("ok ? r? : d\n"), "(? ok (try r) d)");
+}
+
+#[test]
+fn parser_postfix_try_stops_at_the_end_of_its_line() {
+    let output = parse_output("x := f()?\nouter: for i in items {\n    break outer\n}\n");
+    assert!(output.diagnostics.is_empty(), "unexpected diagnostics: {:?}", output.diagnostics);
+    match output.stmts.as_slice() {
+        [Stmt::Let { value, .. }, Stmt::LabeledLoop { label, .. }] => {
+            assert_eq!(expr_shape(value), "(try (call f ))");
+            assert_eq!(label, "outer");
+        }
+        other => panic!("expected a let and a labeled loop, got {:?}", other),
+    }
+
+    // A ternary may still continue on the next line with its `:`.
+    assert_eq!(parse_single_expr_shape("ok ? a\n    : b\n"), "(? ok a b)");
+}
+
+#[test]
+fn parser_accepts_keywords_as_member_names() {
+    assert_eq!(parse_single_expr_shape("regex.match\n"), "(field regex .match)");
+    assert_eq!(parse_single_expr_shape("opts.type + 1\n"), "(+ (field opts .type) 1)");
+    match parse_single_statement("regex.match(s, p)\n") {
+        Stmt::ExprStmt(Expr::MethodCall { method, args, .. }) => {
+            assert_eq!(method, "match");
+            assert_eq!(args.len(), 2);
+        }
+        other => panic!("expected method call, got {:?}", other),
+    }
+}
+
+#[test]
+fn parser_treats_int_and_float_keywords_followed_by_paren_as_calls() {
+    assert_eq!(parse_single_expr_shape("int(x) + 1\n"), "(+ (call int x) 1)");
+    assert_eq!(parse_single_expr_shape("float(3) / 4\n"), "(/ (call float 3) 4)");
+    match parse_single_statement("let ratio: float := float(1)\n") {
+        Stmt::Let { type_annotation, value, .. } => {
+            assert!(type_annotation.is_some());
+            assert_eq!(expr_shape(&value), "(call float 1)");
+        }
+        other => panic!("expected typed let statement, got {:?}", other),
+    }
+}
+
 #[test]
 fn parser_assignment_rhs_preserves_expression_precedence() {
     match parse_single_statement("total := 1 + 2 * 3\n") {
@@ -129,6 +206,182 @@ fn parser_compound_assignment_supports_index_targets() {
     }
 }
 
+#[test]
+fn parser_multiple_assignment_keeps_targets_and_values_in_order() {
+    match parse_single_statement("a, b = b, a + b\n") {
+        Stmt::MultiAssign { targets, values } => {
+            let targets = targets.iter().map(expr_shape).collect::<Vec<_>>();
+            let values = values.iter().map(expr_shape).collect::<Vec<_>>();
+            assert_eq!(targets, vec!["a", "b"]);
+            assert_eq!(values, vec!["b", "(+ a b)"]);
+        }
+        other => panic!("expected multiple assignment statement, got {:?}", other),
+    }
+}
+
+#[test]
+fn parser_multiple_assignment_rejects_count_mismatch_and_duplicate_targets() {
+    let output = parse_output("a, b = 1, 2, 3\n");
+    assert!(output
+        .diagnostics
+        .iter()
+        .any(|diagnostic| diagnostic.message.contains("2 targets but 3 values")));
+
+    let output = parse_output("a, a = 1, 2\n");
+    assert!(output.diagnostics.iter().any(|diagnostic| diagnostic
+        .message
+        .contains("Variable 'a' is assigned more than once in a multiple assignment")));
+}
+
+#[test]
+fn parser_multiple_assignment_unpacks_single_value_and_multiple_returns() {
+    match parse_single_statement("q, r := divmod(7, 2)\n") {
+        Stmt::MultiAssign { targets, values } => {
+            assert_eq!(targets.iter().map(expr_shape).collect::<Vec<_>>(), vec!["q", "r"]);
+            assert_eq!(
+                values.iter().map(expr_shape).collect::<Vec<_>>(),
+                vec!["(call divmod 7 2)"]
+            );
+        }
+        other => panic!("expected multiple assignment statement, got {:?}", other),
+    }
+
+    match parse_single_statement("func divmod(a, b) { return a / b, a % b }\n") {
+        Stmt::FuncDef { body, .. } => {
+            let returned = body.iter().find_map(|stmt| match stmt {
+                Stmt::Return(Some(Expr::ArrayLiteral(elements))) => Some(elements.len()),
+                _ => None,
+            });
+            assert_eq!(returned, Some(2), "expected `return a, b` to return an array: {:?}", body);
+        }
+        other => panic!("expected function definition, got {:?}", other),
+    }
+
+    let output = parse_output("pair := [1, 2]\n[x, y] := pair\n");
+    assert!(output.diagnostics.is_empty(), "got {:?}", output.diagnostics);
+    assert_eq!(output.stmts.len(), 2, "a line-leading `[` must not index the previous line");
+}
+
+#[test]
+fn parser_keeps_parameter_defaults_rest_parameter_and_named_arguments() {
+    match parse_single_statement("func f(a, b = 2, ...rest) { return a }\n") {
+        Stmt::FuncDef { params, param_defaults, is_variadic, .. } => {
+            assert_eq!(params, vec!["a", "b", "rest"]);
+            assert_eq!(
+                param_defaults.iter().map(|d| d.as_ref().map(expr_shape)).collect::<Vec<_>>(),
+                vec![None, Some("2".to_string()), None]
+            );
+            assert!(is_variadic);
+        }
+        other => panic!("expected function definition, got {:?}", other),
+    }
+
+    match parse_single_statement("f(1, ...xs, b: 3)\n") {
+        Stmt::ExprStmt(Expr::Call { args, .. }) => {
+            assert!(matches!(args[0], Expr::Int(1)));
+            assert!(matches!(&args[1], Expr::Spread(inner) if expr_shape(inner) == "xs"));
+            assert!(matches!(&args[2], Expr::NamedArg { name, value }
+                if name == "b" && expr_shape(value) == "3"));
+        }
+        other => panic!("expected call statement, got {:?}", other),
+    }
+
+    for (source, expected) in [
+        ("func f(a = b) {}\n", "Default value of parameter 'a' must be a literal"),
+        (
+            "func f(a = 1, b) {}\n",
+            "Parameter 'b' without a default cannot follow one with a default",
+        ),
+        ("func f(...rest, a) {}\n", "Rest parameter '...rest' must be the last parameter"),
+        ("f(a: 1, 2)\n", "Positional argument cannot follow a named argument"),
+        ("f(a: 1, a: 2)\n", "Argument 'a' is passed more than once"),
+    ] {
+        let output = parse_output(source);
+        assert!(
+            output.diagnostics.iter().any(|diagnostic| diagnostic.message.contains(expected)),
+            "expected {:?} for {:?}, got {:?}",
+            expected,
+            source,
+            output.diagnostics
+        );
+    }
+}
+
+#[test]
+fn parser_desugars_pipes_into_calls() {
+    assert_eq!(parse_single_expr_shape("x |> f |> g\n"), "(call g (call f x))");
+    assert_eq!(parse_single_expr_shape("x |> f(1, 2)\n"), "(call f x 1 2)");
+    assert_eq!(parse_single_expr_shape("x |> f(1, _)\n"), "(call f 1 x)");
+    assert_eq!(parse_single_expr_shape("a ?? b |> f\n"), "(call f (?? a b))");
+    assert_eq!(parse_single_expr_shape("x |> f ? 1 : 2\n"), "(? (call f x) 1 2)");
+
+    let output = parse_output("x |> f(_, _)\n");
+    assert!(
+        output.diagnostics.iter().any(|diagnostic| diagnostic
+            .message
+            .contains("Pipe placeholder '_' can appear only once in a call")),
+        "expected placeholder diagnostic, got {:?}",
+        output.diagnostics
+    );
+}
+
+#[test]
+fn parser_keeps_decorators_on_function_definitions() {
+    let output = parse_output("@memo\n@retry(3)\nfunc fib(n) {\n    return n\n}\n");
+    assert!(
+        output.diagnostics.is_empty(),
+        "expected no parse diagnostics, got {:?}",
+        output.diagnostics
+    );
+    match output.stmts.as_slice() {
+        [Stmt::FuncDef { name, decorators, .. }] => {
+            assert_eq!(name, "fib");
+            let shapes: Vec<String> = decorators.iter().map(expr_shape).collect();
+            assert_eq!(shapes, ["memo", "(call retry 3)"]);
+        }
+        other => panic!("expected one decorated function definition, got {:?}", other),
+    }
+
+    let output = parse_output("@memo\nx := 1\n");
+    assert!(
+        output
+            .diagnostics
+            .iter()
+            .any(|diagnostic| diagnostic.message.contains("Expected 'func' after decorator")),
+        "expected decorator diagnostic, got {:?}",
+        output.diagnostics
+    );
+}
+
+#[test]
+fn parser_labeled_loop_wraps_loop_and_keeps_jump_labels() {
+    match parse_single_statement(
+        "outer: for i in 3 {\n    while true {\n        break outer\n    }\n}\n",
+    ) {
+        Stmt::LabeledLoop { label, loop_stmt } => {
+            assert_eq!(label, "outer");
+            let Stmt::For { body, .. } = *loop_stmt else {
+                panic!("expected labeled for loop, got {:?}", loop_stmt);
+            };
+            let Some(Stmt::While { body: inner, .. }) = body.first() else {
+                panic!("expected nested while loop, got {:?}", body);
+            };
+            assert!(matches!(inner.as_slice(), [Stmt::Break(Some(target))] if target == "outer"));
+        }
+        other => panic!("expected labeled loop statement, got {:?}", other),
+    }
+}
+
+#[test]
+fn parser_jump_label_must_be_on_the_same_line() {
+    match parse_single_statement("loop {\n    continue\n    total\n}\n") {
+        Stmt::Loop { body, .. } => {
+            assert!(matches!(body.as_slice(), [Stmt::Continue(None), Stmt::ExprStmt(_)]));
+        }
+        other => panic!("expected loop statement, got {:?}", other),
+    }
+}
+
 #[test]
 fn parser_rejects_chained_assignment() {
     let output = parse_output("a := b := 1\n");
@@ -152,3 +405,18 @@ fn runtime_compound_assignment_updates_bound_value() {
     );
     assert!(matches!(interpreter.env.get("total"), Some(Value::Int(10))));
 }
+
+#[test]
+fn runtime_ternary_evaluates_only_the_selected_branch() {
+    let interpreter = run_script(
+        "hits := 0\n\
+         func hit(v) {\n\
+         hits := hits + 1\n\
+         return v\n\
+         }\n\
+         n := 0\n\
+         label := n > 0 ? hit(\"pos\") : n < 0 ? hit(\"neg\") : \"zero\"\n",
+    );
+    assert!(matches!(interpreter.env.get("label"), Some(Value::Str(s)) if s.as_str() == "zero"));
+    assert!(matches!(interpreter.env.get("hits"), Some(Value::Int(0))));
+}
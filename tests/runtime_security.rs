@@ -166,7 +166,7 @@ fn runtime_security_enforces_interpreter_call_depth_limit() {
     let project_root = unique_temp_dir("runtime_security_call_depth");
     let depth = runtime_limits::DEFAULT_MAX_INTERPRETER_CALL_DEPTH + 8;
     let script_source = format!(
-        "func dive(n) {{\n    if n <= 0 {{ return 0 }}\n    return dive(n - 1)\n}}\nprint(dive({}))\n",
+        "func dive(n) {{\n    if n <= 0 {{ return 0 }}\n    return 1 + dive(n - 1)\n}}\nprint(dive({}))\n",
         depth
     );
     let script_path = write_script(&project_root, "call_depth_limit.ruff", &script_source);
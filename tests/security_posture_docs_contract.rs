@@ -10,7 +10,7 @@ fn ruff_binary() -> String {
     env!("CARGO_BIN_EXE_ruff").to_string()
 }
 
-fn expected_capability_flags() -> [&'static str; 14] {
+fn expected_capability_flags() -> [&'static str; 15] {
     [
         "--allow-fs-read",
         "--allow-fs-write",
@@ -25,6 +25,7 @@ fn expected_capability_flags() -> [&'static str; 14] {
         "--allow-database",
         "--allow-clock",
         "--allow-random",
+        "--allow-ffi",
         "--allow-all",
     ]
 }
@@ -210,6 +210,110 @@ fn vm_and_interpreter_match_top_level_function_hoisting_surface() {
     assert_interpreter_and_vm_bool(script, "hoist_ok");
 }
 
+#[test]
+fn vm_and_interpreter_match_benchmark_hot_path_kernels() {
+    let script = r#"
+        func fib(n) {
+            if n <= 1 {
+                return n
+            }
+            return fib(n - 1) + fib(n - 2)
+        }
+
+        func nested_loops(n) {
+            sum := 0
+            i := 0
+            while i < n {
+                j := 0
+                while j < n {
+                    sum := sum + 1
+                    j := j + 1
+                }
+                i := i + 1
+            }
+            return sum
+        }
+
+        func float_accumulate(n) {
+            total := 0.0
+            for i in range(n) {
+                if i % 3 != 0 {
+                    total := total + i * 0.5
+                }
+            }
+            return total
+        }
+
+        kernels_ok := fib(6) == 8 && nested_loops(40) == 1600 && float_accumulate(30) == 150.0
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "kernels_ok");
+
+    let vm_env = vm_env_with_builtins();
+    let scopes_before = vm_env.lock().expect("failed to lock vm globals").depth();
+    run_vm(script, vm_env.clone()).expect("vm execution should succeed");
+    assert_eq!(
+        vm_env.lock().expect("failed to lock vm globals").depth(),
+        scopes_before,
+        "VM hot-path kernels must not leak block scopes across calls"
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_multiple_assignment_and_strict_destructuring() {
+    let script = r#"
+        a := 0
+        b := 1
+        i := 0
+        while i < 10 {
+            a, b = b, a + b
+            i := i + 1
+        }
+        pair := [10, 20]
+        pair[0], pair[1] = pair[1], pair[0]
+        func swapped(m, n) {
+            m, n = n, m
+            return [m, n]
+        }
+
+        let [x, y] = [1, 2]
+        let {px, py} = {"px": 3, "py": 4, "pz": 5}
+        let [head, ...tail] = [1, 2, 3]
+        let [[n1, n2], n3] = [[1, 2], 3]
+
+        long_error := ""
+        try {
+            let [c, d] = [1, 2, 3]
+        } except err {
+            long_error := err.message
+        }
+        short_error := ""
+        try {
+            let [c, d, ...e] = [1]
+        } except err {
+            short_error := err.message
+        }
+        type_error := ""
+        try {
+            let {k} = 5
+        } except err {
+            type_error := err.message
+        }
+
+        destructure_ok := a == 55 && b == 89
+            && pair == [20, 10]
+            && swapped(1, 2) == [2, 1]
+            && x == 1 && y == 2 && px == 3 && py == 4
+            && head == 1 && tail == [2, 3]
+            && n1 == 1 && n2 == 2 && n3 == 3
+            && long_error == "Array destructuring expected 2 elements but got 3"
+            && short_error == "Array destructuring expected at least 2 elements but got 1"
+            && type_error == "Cannot destructure int with a dict pattern"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "destructure_ok");
+}
+
 #[test]
 fn vm_and_interpreter_match_spread_destructuring_surface() {
     let script = r#"
@@ -333,6 +437,43 @@ fn vm_and_interpreter_match_null_optional_and_pipe_operator_surface() {
     assert_interpreter_and_vm_bool(script, "ops_ok");
 }
 
+#[test]
+fn vm_and_interpreter_match_pipelines_with_arguments_and_placeholder() {
+    let script = r#"
+        func label(prefix, value) {
+            return prefix + value
+        }
+
+        struct Counter {
+            base: int,
+
+            func plus(self, n) {
+                return self.base + n
+            }
+        }
+
+        evens := [1, 2, 3, 4, 5, 6]
+            |> filter(func(n) { return n % 2 == 0 })
+            |> map(func(n) { return n * 10 })
+        joined := evens |> map(func(n) { return to_string(n) }) |> join(", ")
+        tagged := "ruff" |> label("lang: ", _)
+        named := "x" |> label(value: _, prefix: "id-")
+        counter := Counter { base: 10 }
+        counted := 5 |> counter.plus()
+        inline := 4 |> func(n) { return n * n }
+
+        pipes_ok :=
+            evens == [20, 40, 60] &&
+            joined == "20, 40, 60" &&
+            tagged == "lang: ruff" &&
+            named == "id-x" &&
+            counted == 15 &&
+            inline == 16
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "pipes_ok");
+}
+
 #[test]
 fn vm_and_interpreter_match_struct_unary_overload_surface() {
     let script = r#"
@@ -609,6 +750,98 @@ fn vm_and_interpreter_allow_break_and_continue_inside_loop() {
     assert!(matches!(vm_globals.get("loop_ok"), Some(Value::Bool(true))));
 }
 
+#[test]
+fn vm_and_interpreter_match_continue_and_labeled_loop_control() {
+    let script = r#"
+        odds := []
+        for n in 7 {
+            if n % 2 == 0 {
+                continue
+            }
+            odds := push(odds, n)
+        }
+
+        pairs := []
+        outer: for i in [1, 2, 3] {
+            for j in [1, 2, 3] {
+                if j == 2 {
+                    continue
+                }
+                if i == 2 {
+                    continue outer
+                }
+                if i * j == 9 {
+                    break outer
+                }
+                pairs := push(pairs, [i, j])
+            }
+        }
+
+        rows := 0
+        cells := 0
+        grid: while rows < 5 {
+            rows := rows + 1
+            col := 0
+            loop {
+                col := col + 1
+                if col > 3 { break }
+                if rows == 4 { break grid }
+                if col == 2 { continue grid }
+                cells := cells + 1
+            }
+        }
+
+        func first_negative(matrix) {
+            found := null
+            search: for row in matrix {
+                for value in row {
+                    if value < 0 {
+                        found := value
+                        break search
+                    }
+                }
+            }
+            return found
+        }
+
+        loop_ok := odds == [1, 3, 5]
+            && pairs == [[1, 1], [1, 3], [3, 1]]
+            && rows == 4
+            && cells == 3
+            && first_negative([[1, 2], [3, -4], [-5]]) == -4
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "loop_ok");
+}
+
+#[test]
+fn vm_and_interpreter_error_on_unknown_loop_label() {
+    let script = r#"
+        outer: for i in 2 {
+            break inner
+        }
+    "#;
+    assert_interpreter_and_vm_error_contains(
+        script,
+        "break label 'inner' does not name an enclosing loop",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_keep_break_in_called_function_from_exiting_caller_loop() {
+    let script = r#"
+        func bad() {
+            break
+        }
+
+        for i in 3 {
+            bad()
+        }
+    "#;
+
+    assert_interpreter_and_vm_error_contains(script, "break can only be used inside a loop");
+}
+
 #[test]
 fn vm_and_interpreter_error_on_break_outside_loop_inside_function() {
     let script = r#"
@@ -972,6 +1205,260 @@ fn vm_and_interpreter_match_generator_iteration_surface() {
     );
 }
 
+#[test]
+fn vm_and_interpreter_match_resumable_generator_bodies() {
+    let script = r#"
+        func naturals() {
+            n := 0
+            loop {
+                yield n
+                n := n + 1
+            }
+        }
+
+        func odd_items(items) {
+            for item in items {
+                if item % 2 == 0 {
+                    continue
+                }
+                yield item
+            }
+        }
+
+        func* guarded() {
+            try {
+                yield 1
+                yield 2
+            } except e {
+                yield -1
+            } finally {
+                yield 3
+            }
+        }
+
+        seen := []
+        for n in naturals() {
+            if n == 4 {
+                break
+            }
+            seen := push(seen, n)
+        }
+
+        stepper := naturals()
+        first := next(stepper)
+        second := stepper.next()
+        shared := stepper
+        third := next(shared)
+
+        once := guarded()
+        steps := [next(once), next(once), next(once), next(once), next(once)]
+
+        products := []
+        for a in odd_items([1, 2, 3]) {
+            for b in odd_items([5, 6, 7]) {
+                products := push(products, a * b)
+            }
+        }
+
+        generator_resume_ok := seen == [0, 1, 2, 3]
+            && first == Some(0)
+            && second == Some(1)
+            && next(stepper) == Some(3)
+            && third == Some(2)
+            && steps == [Some(1), Some(2), Some(3), None, None]
+            && iter.collect(iter.take(naturals(), 3)) == [0, 1, 2]
+            && products == [5, 7, 15, 21]
+            && type(naturals()) == "generator"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "generator_resume_ok");
+}
+
+#[test]
+fn vm_and_interpreter_propagate_errors_thrown_by_resumed_generators() {
+    let script = r#"
+        func fails_second() {
+            yield 1
+            throw "generator failed"
+        }
+
+        broken := fails_second()
+        first := next(broken)
+        message := ""
+        try {
+            next(broken)
+        } except e {
+            message := e.message
+        }
+
+        generator_error_ok := first == Some(1)
+            && message == "generator failed"
+            && next(broken) == None
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "generator_error_ok");
+    assert_interpreter_and_vm_error_contains(
+        "print(next([1, 2]))",
+        "next() expects a generator, got array",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_slice_syntax_and_array_methods() {
+    let script = r#"
+        values := [1, 2, 3, 4, 5, 6]
+        scores := [["b", 2], ["a", 2], ["c", 1]]
+        by_score := scores.sort(func(left, right) { return left[1] - right[1] })
+        stack := [3, 1, 2]
+        popped := stack.pop()
+
+        slice_methods_ok := values[1:3] == [2, 3]
+            && values[-2:] == [5, 6]
+            && values[:2] == [1, 2]
+            && values[1:-1] == [2, 3, 4, 5]
+            && values[4:2] == []
+            && "ruffle"[1:4] == "uff"
+            && values == [1, 2, 3, 4, 5, 6]
+            && stack.push(4) == [3, 1, 2, 4]
+            && popped == [[3, 1], 2]
+            && stack.insert(0, 9) == [9, 3, 1, 2]
+            && stack.remove(1) == [3, 2]
+            && stack.sort() == [1, 2, 3]
+            && stack.sort(func(a, b) { return b - a }) == [3, 2, 1]
+            && sort(stack, func(a, b) { return a - b }) == [1, 2, 3]
+            && by_score == [["c", 1], ["b", 2], ["a", 2]]
+            && stack.reverse() == [2, 1, 3]
+            && stack.index_of(1) == 1
+            && stack.contains(2)
+            && stack.join(", ") == "3, 1, 2"
+            && [[1, 2], [3]].flatten() == [1, 2, 3]
+            && [1, 1, 2].unique() == [1, 2]
+            && stack == [3, 1, 2]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "slice_methods_ok");
+    assert_interpreter_and_vm_error_contains(
+        "print([2, 1].sort(func(a, b) { return \"later\" }))",
+        "sort() comparator must return a number, got string",
+    );
+    assert_interpreter_and_vm_error_contains(
+        "print(sort([2, 1], 5))",
+        "sort() comparator must be a function, got int",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_multiple_returns_and_unpacking() {
+    let script = r#"
+        func divmod(a, b) {
+            return a / b, a % b
+        }
+
+        func fib(n) {
+            a := 0
+            b := 1
+            i := 0
+            while i < n {
+                a, b = b, a + b
+                i := i + 1
+            }
+            return a
+        }
+
+        q, r := divmod(17, 5)
+        pair := ["left", "right"]
+        [first, second] := pair
+        let {name, id} = {"name": "ruff", "id": 7}
+        slots := [0, 0]
+        slots[1], tail := [4, 5]
+
+        short_message := ""
+        try {
+            x, y, z := [1, 2]
+        } except e {
+            short_message := e.message
+        }
+        scalar_message := ""
+        try {
+            x, y := 3
+        } except e {
+            scalar_message := e.message
+        }
+
+        unpacking_ok := q == 3 && r == 2
+            && fib(10) == 55
+            && first == "left" && second == "right"
+            && name == "ruff" && id == 7
+            && slots == [0, 4] && tail == 5
+            && short_message == "Multiple assignment has 3 targets but the array has 2 elements"
+            && scalar_message == "Cannot unpack int into 2 assignment targets"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "unpacking_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_default_named_and_rest_parameters() {
+    let script = r#"
+        func greet(name, greeting = "Hello", punctuation = "!") {
+            return greeting + ", " + name + punctuation
+        }
+
+        func gather(label, ...items) {
+            return [label, len(items), items]
+        }
+
+        struct Point {
+            x: int,
+
+            func scaled(self, factor = 2, offset = 0) {
+                return self.x * factor + offset
+            }
+        }
+
+        p := Point { x: 5 }
+        scale := func(value, by = 10) {
+            return value * by
+        }
+        parts := ["Ann", "Hi"]
+
+        parameters_ok := greet("Bob") == "Hello, Bob!"
+            && greet("Bob", "Hey") == "Hey, Bob!"
+            && greet("Bob", punctuation: "?") == "Hello, Bob?"
+            && greet(punctuation: ".", name: "Cy") == "Hello, Cy."
+            && greet(...parts) == "Hi, Ann!"
+            && gather("none") == ["none", 0, []]
+            && gather("nums", 1, 2, 3) == ["nums", 3, [1, 2, 3]]
+            && gather(...["spread", 4], 5) == ["spread", 2, [4, 5]]
+            && p.scaled() == 10
+            && p.scaled(offset: 1) == 11
+            && scale(4) == 40
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parameters_ok");
+}
+
+#[test]
+fn vm_and_interpreter_error_on_unknown_named_argument_and_missing_parameter() {
+    let script = r#"
+        func greet(name, greeting = "Hello") {
+            return greeting + ", " + name
+        }
+
+        return greet("Bob", tone: "warm")
+    "#;
+    assert_interpreter_and_vm_error_contains(script, "greet has no parameter named 'tone'");
+
+    let script = r#"
+        func greet(name, greeting = "Hello") {
+            return greeting + ", " + name
+        }
+
+        return greet()
+    "#;
+    assert_interpreter_and_vm_error_contains(script, "greet expects 1 to 2 arguments, got 0");
+}
+
 #[test]
 fn vm_and_interpreter_error_on_native_function_arity_mismatch() {
     let script = r#"
@@ -1301,6 +1788,32 @@ export func add_one(x) {
     let _ = fs::remove_file(module_filename);
 }
 
+#[test]
+fn vm_and_interpreter_match_path_import_namespace_surface() {
+    let module_dir = format!("modules/{}", unique_module_name());
+    fs::create_dir_all(&module_dir).expect("failed to create parity module dir");
+    let module_source = r#"
+export scale := 10
+export func add_one(x) {
+    return x + 1
+}
+"#;
+    fs::write(format!("{}/ops.ruff", module_dir), module_source)
+        .expect("failed to write parity module");
+
+    let script = format!(
+        r#"
+        import "{}/ops"
+        bump := ops.add_one
+        path_import_ok := ops.add_one(41) == 42 && ops.scale == 10 && bump(1) == 2
+    "#,
+        module_dir
+    );
+
+    assert_interpreter_and_vm_bool(&script, "path_import_ok");
+    let _ = fs::remove_dir_all(module_dir);
+}
+
 #[test]
 fn vm_and_interpreter_match_imported_function_dict_index_surface() {
     let module_name = unique_module_name();
@@ -1398,41 +1911,33 @@ fn vm_and_interpreter_dotted_import_resolution_prefers_flat_module_before_nested
 }
 
 #[test]
-fn vm_and_interpreter_reject_integer_add_overflow() {
-    let script = r#"
-        return 9223372036854775807 + 1
-    "#;
-
-    assert_interpreter_and_vm_error_contains(script, "Integer overflow");
-}
-
-#[test]
-fn vm_and_interpreter_reject_integer_subtract_overflow() {
+fn vm_and_interpreter_promote_integer_overflow_to_big_int() {
     let script = r#"
+        maximum := 9223372036854775807
         minimum := parse_int("-9223372036854775808")
-        return minimum - 1
-    "#;
-
-    assert_interpreter_and_vm_error_contains(script, "Integer overflow");
-}
-
-#[test]
-fn vm_and_interpreter_reject_integer_multiply_overflow() {
-    let script = r#"
-        return 3037000500 * 3037000500
-    "#;
-
-    assert_interpreter_and_vm_error_contains(script, "Integer overflow");
-}
+        add_ok := to_string(maximum + 1) == "9223372036854775808"
+        sub_ok := to_string(minimum - 1) == "-9223372036854775809"
+        mul_ok := to_string(3037000500 * 3037000500) == "9223372037000250000"
+        div_ok := to_string(minimum / -1) == "9223372036854775808"
+        rem_ok := minimum % -1 == 0
+        neg_ok := to_string(-minimum) == "9223372036854775808"
+        demoted := (maximum + 1) - 1
+        demote_ok := demoted == maximum && type(demoted) == "int"
+
+        mut factorial := 1
+        for n in range(1, 31) {
+            factorial := factorial * n
+        }
+        factorial_ok := to_string(factorial) == "265252859812191058636308480000000"
+        big_div_ok := factorial / parse_int("265252859812191058636308480") == 1000000
+        big_rem_ok := factorial % 1000000007 == 109361473
+        big_type_ok := type(factorial) == "int"
 
-#[test]
-fn vm_and_interpreter_reject_integer_division_overflow() {
-    let script = r#"
-        minimum := parse_int("-9223372036854775808")
-        return minimum / -1
+        promotion_ok := add_ok && sub_ok && mul_ok && div_ok && rem_ok && neg_ok && demote_ok
+        promotion_ok := promotion_ok && factorial_ok && big_div_ok && big_rem_ok && big_type_ok
     "#;
 
-    assert_interpreter_and_vm_error_contains(script, "Integer overflow");
+    assert_interpreter_and_vm_bool(script, "promotion_ok");
 }
 
 #[test]
@@ -1545,14 +2050,85 @@ fn vm_and_interpreter_reject_cross_type_ordering_comparisons() {
 }
 
 #[test]
-fn vm_and_interpreter_reject_overflow_in_local_in_place_addition() {
+fn vm_and_interpreter_match_int_float_arithmetic_and_conversions() {
+    let script = r#"
+        int_ok := 7 / 2 == 3 && -7 / 2 == -3 && 7 % 3 == 1 && -7 % 3 == -1
+        int_ok := int_ok && type(7 / 2) == "int" && type(6 * 2) == "int"
+        mixed_ok := 7 / 2.0 == 3.5 && type(1 + 1.0) == "float" && type(2.0 * 3) == "float"
+        mixed_ok := mixed_ok && 7.5 % 2 == 1.5 && 1 == 1.0
+        convert_ok := int(3.9) == 3 && int(-3.9) == -3 && int("42") == 42 && int(true) == 1
+        convert_ok := convert_ok && type(int(2.0)) == "int" && type(float(2)) == "float"
+        convert_ok := convert_ok && float("2.5") == 2.5 && float(3) / 4 == 0.75
+        big_ok := to_string(int(100000000000000000000.0)) == "100000000000000000000"
+        print_ok := to_string(3.0) == "3.0" && to_string(float(2)) == "2.0" && "${1.5}" == "1.5"
+        print_ok := print_ok && to_string(3) == "3" && to_string([1, 2.0]) == "[1, 2.0]"
+        scores := [10, 20, 30]
+        labels := {1: "one", "two": 2}
+        index_ok := scores[int(1.9)] == 20 && labels[1] == "one" && labels["1"] == "one"
+        numeric_ok := int_ok && mixed_ok && convert_ok && big_ok && print_ok && index_ok
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "numeric_ok");
+}
+
+#[test]
+fn vm_and_interpreter_reject_float_array_index() {
+    let script = r#"
+        scores := [10, 20, 30]
+        return scores[1.0]
+    "#;
+
+    assert_interpreter_and_vm_error_contains(script, "index must be an int, got float");
+}
+
+#[test]
+fn vm_and_interpreter_reject_float_array_index_assignment() {
+    let script = r#"
+        mut scores := [10, 20, 30]
+        scores[1.0] := 5
+    "#;
+
+    assert_interpreter_and_vm_error_contains(script, "Invalid index assignment");
+}
+
+#[test]
+fn vm_and_interpreter_reject_float_dict_literal_keys() {
+    let script = r#"
+        return {1.5: "x"}
+    "#;
+
+    assert_interpreter_and_vm_error_contains(
+        script,
+        "Dict keys must be strings or ints, got float",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_promote_overflow_in_local_in_place_addition() {
     let script = r#"
         mut total := 9223372036854775807
         total := total + 1
-        return total
+        in_place_ok := to_string(total) == "9223372036854775808"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "in_place_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_big_int_comparison_and_json() {
+    let script = r#"
+        huge := parse_int("123456789012345678901234567890")
+        negative_huge := -huge
+        order_ok := huge > 9223372036854775807 && negative_huge < 0 && huge > negative_huge
+        order_ok := order_ok && huge >= huge && huge == parse_int("123456789012345678901234567890")
+        float_ok := huge * 1.0 > 100000000000000000000.0 && huge != 1
+        json_ok := to_json([huge, 1]) == "[123456789012345678901234567890,1]"
+        decoded := parse_json("{\"id\": 98765432109876543210}")
+        json_ok := json_ok && to_string(decoded["id"]) == "98765432109876543210"
+        big_int_ok := order_ok && float_ok && json_ok
     "#;
 
-    assert_interpreter_and_vm_error_contains(script, "Integer overflow");
+    assert_interpreter_and_vm_bool(script, "big_int_ok");
 }
 
 #[test]
@@ -1806,13 +2382,122 @@ fn vm_and_interpreter_closure_captures_nearest_lexical_binding() {
 }
 
 #[test]
-fn vm_and_interpreter_match_truthiness_semantics_across_conditionals() {
+fn vm_and_interpreter_closures_capture_enclosing_locals_by_reference() {
     let script = r#"
-        falsey_hits := 0
-        if false { falsey_hits += 1 }
-        if null { falsey_hits += 1 }
-        if 0 { falsey_hits += 1 }
-        if 0.0 { falsey_hits += 1 }
+        func make_counter() {
+            mut count := 0
+            return func() {
+                count = count + 1
+                return count
+            }
+        }
+
+        func make_account() {
+            mut balance := 0
+            deposit := func(amount) { balance = balance + amount }
+            read := func() { return balance }
+            return [deposit, read]
+        }
+
+        func bump_twice() {
+            mut total := 1
+            func bump() {
+                func inner() { total = total + 10 }
+                inner()
+            }
+            bump()
+            bump()
+            return total
+        }
+
+        func snapshot_per_iteration() {
+            readers := []
+            for i in [1, 2, 3] {
+                let seen := i
+                readers := push(readers, func() { return seen })
+            }
+            return readers
+        }
+
+        first := make_counter()
+        first()
+        first()
+        second := make_counter()
+        account := make_account()
+        deposit := account[0]
+        read := account[1]
+        deposit(5)
+        deposit(7)
+        readers := snapshot_per_iteration()
+        first_reader := readers[0]
+        last_reader := readers[2]
+
+        captured_ok := first() == 3
+            && second() == 1
+            && read() == 12
+            && bump_twice() == 21
+            && first_reader() == 1
+            && last_reader() == 3
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "captured_ok");
+}
+
+#[test]
+fn vm_and_interpreter_closures_reach_locals_declared_after_them() {
+    let script = r#"
+        func make_parity() {
+            func is_even(n) {
+                if n == 0 { return true }
+                return is_odd(n - 1)
+            }
+            func is_odd(n) {
+                if n == 0 { return false }
+                return is_even(n - 1)
+            }
+            return [is_even, is_odd]
+        }
+
+        func make_greeter() {
+            greet := func() { return greeting + "!" }
+            greeting := "hi"
+            return greet
+        }
+
+        func make_late_counter() {
+            bump := func() {
+                count = count + 1
+                return count
+            }
+            mut count := 10
+            return bump
+        }
+
+        parity := make_parity()
+        even := parity[0]
+        odd := parity[1]
+        greet := make_greeter()
+        bump := make_late_counter()
+        bump()
+
+        captured_ok := even(10)
+            && odd(7)
+            && !odd(4)
+            && greet() == "hi!"
+            && bump() == 12
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "captured_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_truthiness_semantics_across_conditionals() {
+    let script = r#"
+        falsey_hits := 0
+        if false { falsey_hits += 1 }
+        if null { falsey_hits += 1 }
+        if 0 { falsey_hits += 1 }
+        if 0.0 { falsey_hits += 1 }
         if "" { falsey_hits += 1 }
         if [] { falsey_hits += 1 }
         if {} { falsey_hits += 1 }
@@ -1980,6 +2665,304 @@ fn vm_and_interpreter_match_spawn_surface() {
     assert!(matches!(vm_globals.get("spawn_ok"), Some(Value::Bool(true))));
 }
 
+#[test]
+fn vm_and_interpreter_match_spawn_channel_and_wait_group_surface() {
+    let script = r#"
+        func produce(out, start, wg) {
+            for k in range(3) {
+                out.send(start + k)
+            }
+            wg.done()
+        }
+
+        func fan_in(count) {
+            out := chan()
+            wg := wait_group()
+            for n in range(count) {
+                wg.add(1)
+                spawn produce(out, n * 100, wg)
+            }
+            spawn {
+                wg.wait()
+                out.close()
+            }
+            return out
+        }
+
+        merged := fan_in(3)
+        total := 0
+        received := 0
+        loop {
+            pair := select([merged], 2000)
+            if pair[0] == -1 {
+                break
+            }
+            total += pair[1]
+            received += 1
+        }
+
+        parent_value := 7
+        spawn {
+            parent_value := 999
+        }
+        sleep(10)
+
+        parity_ok := received == 9 && total == 909 && merged.is_closed() && parent_value == 7
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_sync_mutex_atomic_and_map_surface() {
+    let script = r#"
+        func work(id, hits, totals, lock, wg) {
+            for k in range(50) {
+                sync.atomic_add(hits, 1)
+                totals.add("adds", 1)
+                lock.with_lock(func() {
+                    totals.set("sum", totals.get("sum", 0) + id)
+                })
+            }
+            totals.set([id, "done"], true)
+            wg.done()
+        }
+
+        hits := sync.atomic()
+        totals := sync.map()
+        lock := sync.mutex()
+        wg := wait_group()
+        for id in range(4) {
+            wg.add(1)
+            spawn work(id, hits, totals, lock, wg)
+        }
+        wg.wait()
+
+        counts := [sync.atomic_load(hits), totals.get("adds"), totals.get("sum"), totals.len()]
+        finished := totals.has([3, "done"]) && !totals.has([4, "done"])
+
+        lock.lock()
+        held := [lock.is_locked(), lock.try_lock()]
+        lock.unlock()
+
+        mut errors := []
+        try {
+            lock.unlock()
+        } except err {
+            errors := push(errors, err.message)
+        }
+        try {
+            lock.with_lock(func() { throw("inside") })
+        } except err {
+            errors := push(errors, err.message)
+        }
+        try {
+            totals.add("sum", "x")
+        } except err {
+            errors := push(errors, err.message)
+        }
+
+        sync.atomic_store(hits, 5)
+        snapshot := totals.to_dict()
+        sync_ok := [counts, finished, held, lock.is_locked(), errors, sync.atomic_load(hits)] == [
+            [200, 200, 300, 6],
+            true,
+            [true, false],
+            false,
+            [
+                "Mutex.unlock called on an unlocked mutex",
+                "inside",
+                "SyncMap.add delta must be an int",
+            ],
+            5,
+        ] && snapshot["adds"] == 200 && type(lock) == "mutex" && type(totals) == "sync_map"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "sync_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_function_decorators_surface() {
+    let script = r#"
+        calls := sync.atomic()
+
+        @memo
+        func fib(n) {
+            sync.atomic_add(calls, 1)
+            if n < 2 {
+                return n
+            }
+            return fib(n - 1) + fib(n - 2)
+        }
+
+        first := fib(60)
+        second := fib(60)
+
+        attempts := sync.atomic()
+
+        @retry(3)
+        func flaky(limit) {
+            n := sync.atomic_add(attempts, 1)
+            if n < limit {
+                throw("attempt " + to_string(n))
+            }
+            return n
+        }
+
+        recovered := flaky(3)
+        mut errors := []
+        try {
+            flaky(100)
+        } except err {
+            errors := push(errors, err.message)
+        }
+        try {
+            memo(func(value) { return 1 })(print)
+        } except err {
+            errors := push(errors, err.message)
+        }
+        try {
+            retry(0)
+        } except err {
+            errors := push(errors, err.message)
+        }
+
+        pair := memo(func(a, b) { return [a, b] })
+        keyed := pair([1, 2], {"k": 1}) == [[1, 2], {"k": 1}]
+
+        @timed
+        func double(x) {
+            return x * 2
+        }
+
+        decorators_ok := [first, second, sync.atomic_load(calls), recovered, keyed] == [
+            1548008755920,
+            1548008755920,
+            61,
+            3,
+            true,
+        ] && errors == [
+            "attempt 6",
+            "memo: native_function values cannot be hashed",
+            "retry expects a positive number of attempts",
+        ] && double(21) == 42 && type(fib) == "function"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "decorators_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_promise_chaining_surface() {
+    let script = r#"
+        calls := 0
+
+        async func double(n) {
+            await async_sleep(1)
+            return n * 2
+        }
+
+        async func fail(message) {
+            throw(message)
+        }
+
+        func tracked(n) {
+            calls += 1
+            return double(n)
+        }
+
+        chained := await tracked(1).then(func(v) { return double(v) }).then(func(v) { return v + 1 })
+        recovered := await fail("boom").then(func(v) { return "skipped" }).catch(func(e) { return "caught " + e.message })
+        kept := await double(4).finally(func() { return "ignored" })
+
+        rethrown := ""
+        try {
+            await fail("late").finally(func() { return null })
+        } except err {
+            rethrown = err.message
+        }
+
+        parity_ok := chained == 5 && calls == 1 && recovered == "caught boom" && kept == 8 && rethrown == "late"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_http_module_dispatch_surface() {
+    let script = r#"
+        options_error := ""
+        arity_error := ""
+        try {
+            http.get("https://example.com", 5)
+        } except err {
+            options_error = err.message
+        }
+        try {
+            http.post("https://example.com")
+        } except err {
+            arity_error = err.message
+        }
+        detached := http.get_json
+        detached_error := ""
+        try {
+            detached(1)
+        } except err {
+            detached_error = err.message
+        }
+
+        parity_ok := type(http) == "module" && options_error == "http.get() options must be a dictionary" && contains(arity_error, "expects 2 to 4 arguments") && detached_error == "http.get_json() requires a URL string"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_http_router_surface() {
+    let script = r#"
+        router := http.router()
+        router.get("/users/:id", func(req) {
+            return {"status": 200, "body": {"id": req["params"]["id"]}}
+        })
+        router.get("/users/me", func(req) { return "me" })
+        router.route("delete", "/users/:id", func(req) { return null })
+
+        user := router.handle({"method": "GET", "path": "/users/42"})
+        me := router.handle({"method": "GET", "path": "/users/me"})
+        deleted := router.handle({"method": "DELETE", "path": "/users/7"})
+        missing := router.handle({"method": "POST", "path": "/users/7"})
+
+        serve_error := ""
+        try {
+            http.serve("localhost", router)
+        } except err {
+            serve_error = err.message
+        }
+
+        parity_ok := type(router) == "router" && user["status"] == 200 && user["body"] == "{\"id\":\"42\"}" && user["headers"]["Content-Type"] == "application/json" && me["body"] == "me" && deleted["status"] == 204 && missing["status"] == 404 && contains(serve_error, "address must be")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_json_namespace_surface() {
+    let script = r#"
+        data := json.parse("{\"name\": \"ruff\", \"tags\": [\"a\", \"b\"], \"meta\": {\"n\": 1.5}}")
+        compact := json.stringify(data)
+        pretty := json.stringify([1], 2)
+        parse_error := ""
+        try {
+            json.parse("{\"name\": }")
+        } except err {
+            parse_error = err.message
+        }
+
+        parity_ok := type(json) == "module" && data["tags"][1] == "b" && data["meta"]["n"] == 1.5 && compact == "{\"meta\":{\"n\":1.5},\"name\":\"ruff\",\"tags\":[\"a\",\"b\"]}" && pretty == "[\n  1\n]" && contains(parse_error, "line 1 column 10")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
 #[test]
 fn vm_and_interpreter_match_throw_call_stack_surface() {
     let script = r#"
@@ -2005,6 +2988,169 @@ fn vm_and_interpreter_match_throw_call_stack_surface() {
     assert_interpreter_and_vm_bool(script, "parity_ok");
 }
 
+#[test]
+fn vm_and_interpreter_match_try_catch_finally_ordering_surface() {
+    let script = r#"
+        events := []
+
+        func guarded(fail) {
+            try {
+                events = push(events, "try")
+                if fail {
+                    throw "boom"
+                }
+                return "returned"
+            } catch (e) {
+                events = push(events, "catch:" + e.message)
+                return "caught"
+            } finally {
+                events = push(events, "finally")
+            }
+        }
+
+        first := guarded(false)
+        second := guarded(true)
+
+        loop_events := []
+        for n in [1, 2, 3] {
+            try {
+                if n == 2 {
+                    continue
+                }
+                if n == 3 {
+                    break
+                }
+            } except err {
+                loop_events = push(loop_events, "unreachable")
+            } finally {
+                loop_events = push(loop_events, "cleanup")
+            }
+        }
+
+        parity_ok := first == "returned" && second == "caught" && join(events, ",") == "try,finally,try,catch:boom,finally" && len(loop_events) == 3
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_run_tail_calls_in_except_before_finally() {
+    let script = r#"
+        events := []
+
+        func record(label) {
+            events = push(events, label)
+            return label
+        }
+
+        func recover() {
+            try {
+                throw "boom"
+            } except err {
+                return record("except")
+            } finally {
+                events = push(events, "finally")
+            }
+        }
+
+        func overridden() {
+            try {
+                throw "boom"
+            } except err {
+                return record("shadowed")
+            } finally {
+                return "finally wins"
+            }
+        }
+
+        recovered := recover()
+        overridden_result := overridden()
+
+        parity_ok := recovered == "except"
+            && overridden_result == "finally wins"
+            && join(events, ",") == "except,finally,shadowed"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_thrown_payload_and_rethrow_surface() {
+    let script = r#"
+        struct NotFound {
+            message: string,
+            path: string
+        }
+
+        struct_ok := false
+        try {
+            throw NotFound { message: "missing", path: "/tmp/x" }
+        } catch (e) {
+            struct_ok = e.message == "missing" && e.payload.path == "/tmp/x"
+        }
+
+        scalar_ok := false
+        try {
+            throw 42
+        } catch (e) {
+            scalar_ok = e.payload == 42 && e.message == "42"
+        }
+
+        plain_ok := false
+        try {
+            throw "plain"
+        } catch (e) {
+            plain_ok = e.message == "plain" && e.payload == null
+        }
+
+        rethrow_ok := false
+        try {
+            try {
+                throw error("inner", {"code": 7})
+            } catch (inner) {
+                throw inner
+            }
+        } catch (outer) {
+            rethrow_ok = outer.message == "inner" && outer.payload["code"] == 7
+        }
+
+        parity_ok := struct_ok && scalar_ok && plain_ok && rethrow_ok
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
+#[test]
+fn vm_and_interpreter_catch_builtin_runtime_errors() {
+    let script = r#"
+        caught := false
+        try {
+            read_file("/definitely/missing/ruff/file.txt")
+        } catch (e) {
+            caught = len(e.message) > 0
+        }
+
+        after_return_ok := false
+        func leave_early() {
+            try {
+                return 1
+            } except err {
+                return 2
+            }
+        }
+        leave_early()
+        try {
+            throw "after"
+        } except err {
+            after_return_ok = err.message == "after"
+        }
+
+        parity_ok := caught && after_return_ok
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "parity_ok");
+}
+
 #[test]
 fn vm_and_interpreter_execute_exception_fixture_without_runtime_arity_drift() {
     let script = fs::read_to_string("tests/test_exceptions_comprehensive.ruff")
@@ -2025,3 +3171,1155 @@ fn vm_and_interpreter_execute_exception_fixture_without_runtime_arity_drift() {
         vm_result
     );
 }
+
+#[test]
+fn vm_and_interpreter_match_conditional_expressions() {
+    let script = r#"
+        calls := []
+        func track(tag, value) {
+            calls := push(calls, tag)
+            return value
+        }
+        func sign_label(n) {
+            return n > 0 ? "pos" : n < 0 ? "neg" : "zero"
+        }
+        func bump(r) {
+            value := r?
+            return Ok(value + 1)
+        }
+
+        n := -3
+        mag := 5
+        scaled := (n > 0 ? 1 : -1) * mag
+        labels := [sign_label(4), sign_label(-4), sign_label(0)]
+        nested := true ? false ? 1 : 2 : 3
+        picked := false ? track("then", 1) : track("else", 2)
+        config := {"mode": n > 0 ? "up" : "down", "limit": 10}
+        tail_binds_low := n < 0 ? 1 : 2 + 10
+        try_value := bump(Ok(1))
+
+        ternary_ok := scaled == -5
+            && labels == ["pos", "neg", "zero"]
+            && nested == 2
+            && picked == 2
+            && calls == ["else"]
+            && config["mode"] == "down"
+            && tail_binds_low == 1
+            && try_value == Ok(2)
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "ternary_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_io_namespace_and_file_handles() {
+    let dir = std::env::temp_dir().join(unique_module_name());
+    fs::create_dir_all(&dir).expect("failed to create io parity dir");
+    let dir_literal = dir.to_string_lossy().replace('\\', "/");
+    let script = r#"
+        base := "__DIR__"
+        notes := base + "/notes.txt"
+        out := base + "/out.txt"
+
+        io.write_file(notes, "alpha\nbeta\n", true)
+        io.append_file(notes, "gamma\n")
+        whole := io.read_file(notes)
+        lines := io.read_lines(notes)
+
+        missing_message := ""
+        try {
+            io.read_file(base + "/missing.txt")
+        } except err {
+            missing_message := err.message
+        }
+
+        streamed := []
+        reader := io.open(notes, "r")
+        line := reader.read_line()
+        while line != null {
+            streamed := push(streamed, line)
+            line := reader.read_line()
+        }
+        rest := reader.read()
+        reader.close()
+        reader.close()
+
+        closed_message := ""
+        try {
+            reader.read_line()
+        } except err {
+            closed_message := err.message
+        }
+
+        writer := io.open(out, "w", true)
+        written := writer.write("one ")
+        writer.write("two")
+        writer.close()
+        appender := io.open(out, "a")
+        appender.write("\nthree")
+        appender.close()
+
+        exists_message := ""
+        try {
+            io.open(out, "w")
+        } except err {
+            exists_message := err.message
+        }
+
+        mode_message := ""
+        try {
+            io.open(out, "r").write("nope")
+        } except err {
+            mode_message := err.message
+        }
+
+        io_ok := whole == "alpha\nbeta\ngamma\n"
+            && lines == ["alpha", "beta", "gamma"]
+            && contains(missing_message, "No such file or directory")
+            && streamed == ["alpha", "beta", "gamma"]
+            && rest == ""
+            && contains(closed_message, "file handle is closed")
+            && written == 4
+            && io.read_file(out) == "one two\nthree"
+            && contains(exists_message, "file already exists")
+            && contains(mode_message, "opened with mode \"r\"")
+            && type(reader) == "filehandle"
+    "#
+    .replace("__DIR__", &dir_literal);
+
+    assert_interpreter_and_vm_bool(&script, "io_ok");
+    let _ = fs::remove_dir_all(dir);
+}
+
+#[test]
+fn vm_and_interpreter_match_fs_namespace_surface() {
+    let dir = std::env::temp_dir().join(unique_module_name());
+    let dir_literal = dir.to_string_lossy().replace('\\', "/");
+    let script = r#"
+        base := "__DIR__"
+        fs.mkdir_all(base + "/src/nested")
+        fs.write(base + "/main.ruff", "one")
+        fs.append(base + "/main.ruff", "+two")
+        fs.write(base + "/src/lib.ruff", "lib")
+        fs.write(base + "/src/nested/deep.ruff", "deep")
+        info := fs.stat(base + "/main.ruff")
+        sources := fs.glob(base + "/**/*.ruff")
+        visited := fs.walk(base, func(entry) {
+            return entry["name"] != "nested"
+        })
+
+        missing_message := ""
+        try {
+            fs.read(base + "/missing.txt")
+        } except err {
+            missing_message := err.message
+        }
+
+        fs_ok := type(fs) == "module"
+            && fs.read(base + "/main.ruff") == "one+two"
+            && fs.exists(base + "/src") && !fs.exists(base + "/nope")
+            && info["size"] == 7 && info["is_file"] && !info["is_dir"]
+            && sources == [base + "/main.ruff", base + "/src/lib.ruff", base + "/src/nested/deep.ruff"]
+            && visited == 4
+            && contains(missing_message, "Cannot read file")
+    "#
+    .replace("__DIR__", &dir_literal);
+
+    assert_interpreter_and_vm_bool(&script, "fs_ok");
+    let _ = fs::remove_dir_all(dir);
+}
+
+#[test]
+fn vm_and_interpreter_match_regex_namespace_surface() {
+    let script = r#"
+        date := regex.named_captures("due 2026-10-14", "(?P<year>\\d{4})-(?P<month>\\d{2})-(?P<day>\\d{2})")
+        pairs := regex.captures_all("a=1, b=2", "(\\w)=(\\d)")
+        hits := 0
+        for line in ["id-1", "id-x", "id-22"] {
+            if regex.match(line, "^id-\\d+$") {
+                hits := hits + 1
+            }
+        }
+        pattern_error := ""
+        try {
+            regex.find("x", "[")
+        } except err {
+            pattern_error = err.message
+        }
+
+        regex_ok := type(regex) == "module"
+            && date["year"] == "2026" && date["day"] == "14"
+            && pairs == [["a=1", "a", "1"], ["b=2", "b", "2"]]
+            && hits == 2
+            && regex.find("abc", "\\d") == null
+            && regex.captures("k=v", "(\\w)=(\\w)(!)?") == ["k=v", "k", "v", null]
+            && regex.replace("John Smith", "(\\w+) (\\w+)", "$2, $1") == "Smith, John"
+            && regex.split("a, b;c", "[,;]\\s*") == ["a", "b", "c"]
+            && regex.escape("a.b") == "a\\.b"
+            && contains(pattern_error, "regex.find() invalid pattern '['")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "regex_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_string_builder_surface() {
+    let script = r#"
+        func render(rows) {
+            sb := strings.builder()
+            for row in rows {
+                sb.append(row).append(",")
+            }
+            return sb.to_string()
+        }
+
+        shared := strings.builder("n=")
+        alias := shared
+        i := 0
+        while i < 3 {
+            alias.append(i)
+            i := i + 1
+        }
+        seen_len := shared.len()
+        shared.clear()
+
+        arity_message := ""
+        try {
+            shared.append()
+        } except err {
+            arity_message = err.message
+        }
+
+        builder_ok := render(["a", "b"]) == "a,b,"
+            && seen_len == 5
+            && shared.to_string() == ""
+            && type(shared) == "stringbuilder"
+            && contains(arity_message, "StringBuilder.append() expects 1 argument")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "builder_ok");
+}
+
+#[cfg(all(target_os = "linux", any(target_arch = "x86_64", target_arch = "aarch64")))]
+#[test]
+fn vm_and_interpreter_match_ffi_namespace_surface() {
+    let script = r#"
+        libm := ffi.load("libm.so.6").declare("cos", ["double"], "double")
+        libm.declare("powf", ["float", "float"], "float")
+        libc := ffi.load("libc.so.6")
+        libc.declare("strlen", ["string"], "size_t")
+        libc.declare("time", ["pointer"], "long")
+        libc.declare("realpath", ["string", "pointer"], "pointer")
+        libc.declare("getenv", ["string"], "string")
+
+        out := ffi.alloc(8)
+        now := libc.call("time", out)
+        stored := ffi.read_pointer(out)
+        ffi.free(out)
+        resolved := libc.call("realpath", "/", null)
+        root := ffi.read_string(resolved)
+        ffi.free(resolved)
+
+        undeclared := ""
+        try {
+            libm.call("tan", 1.0)
+        } except err {
+            undeclared := err.message
+        }
+        wrong_type := ""
+        try {
+            libm.call("cos", "zero")
+        } except err {
+            wrong_type := err.message
+        }
+
+        ffi_ok := type(ffi) == "module" && type(libm) == "native_library"
+            && libm.call("cos", 0) == 1.0
+            && libm.call("powf", 2.0, 10) == 1024.0
+            && libc.call("strlen", "hello") == 5
+            && libc.call("getenv", "RUFF_FFI_PARITY_UNSET") == null
+            && now > 0 && stored == now && root == "/"
+            && contains(undeclared, "'tan' is not declared")
+            && contains(wrong_type, "cos() argument 1 expects double, got string")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "ffi_ok");
+}
+
+#[cfg(unix)]
+#[test]
+fn vm_and_interpreter_match_proc_namespace_surface() {
+    let script = r#"
+        failed := proc.run("sh", ["-c", "echo out; echo err >&2; exit 3"])
+        echoed := proc.run("cat", {"stdin": "fed"})
+        slow := proc.run("sleep", ["5"], {"timeout": 0.1})
+        piped := proc.pipeline([["printf", "b\na\n"], ["sort"]])
+
+        worker := proc.spawn("sh", ["-c", "while read l; do echo got $l; done"])
+        worker.write("one\n")
+        first := worker.read_line()
+        finished := worker.wait()
+
+        missing := ""
+        try {
+            proc.run("ruff-definitely-missing-program")
+        } except err {
+            missing = err.message
+        }
+
+        proc_ok := failed.exitcode == 3 && !failed.success
+            && failed.stdout == "out\n" && failed.stderr == "err\n"
+            && echoed.stdout == "fed"
+            && slow.timed_out
+            && piped.stdout == "a\nb\n" && piped.success && len(piped.exitcodes) == 2
+            && first == "got one" && finished.exitcode == 0 && worker.read_line() == null
+            && type(worker) == "process"
+            && contains(missing, "Failed to spawn process")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "proc_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_net_namespace_surface() {
+    let script = r#"
+        server := net.listen("tcp", "127.0.0.1:0")
+        client := net.dial("tcp", server.local_addr(), {"line_ending": "\r\n"})
+        peer := server.accept()
+        client.write_line("PING")
+        client.write("$5\r\nhello\r\ntail")
+        client.close()
+
+        first := peer.read_line()
+        header := peer.read_line()
+        body := peer.read_exact(5)
+        crlf := peer.read_exact(2)
+        rest := peer.read()
+        eof := peer.read_line()
+
+        peer.set_read_deadline(time.now() - 1)
+        timed := ""
+        try {
+            peer.read()
+        } except err {
+            timed = err.message
+        }
+        closed := ""
+        try {
+            client.write("late")
+        } except err {
+            closed = err.message
+        }
+
+        packets := net.listen("udp", "127.0.0.1:0")
+        sender := net.dial("udp", packets.local_addr())
+        sender.write("datagram")
+        packet := packets.read_from()
+        packets.write_to("reply", packet["from"])
+        reply := sender.read()
+
+        net_ok := first == "PING" && header == "$5" && body == "hello" && crlf == "\r\n"
+            && rest == "tail" && eof == null
+            && contains(timed, "deadline exceeded") && contains(closed, "socket is closed")
+            && packet["data"] == "datagram" && reply == "reply"
+            && type(server) == "net_listener" && type(peer) == "net_conn"
+            && type(packets) == "net_packet_conn"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "net_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_sqlite_namespace_surface() {
+    let script = r#"
+        db := sqlite.open(":memory:")
+        db.exec("CREATE TABLE notes (id INTEGER PRIMARY KEY, title TEXT, score REAL)")
+        inserted := db.exec("INSERT INTO notes (title, score) VALUES (?, ?)", ["first", 1.5])
+        first_id := db.last_insert_id()
+
+        db.transaction(func(tx) {
+            tx.exec("INSERT INTO notes (title, score) VALUES (?, ?)", ["second", 2])
+        })
+        failed := ""
+        try {
+            db.transaction(func(tx) {
+                tx.exec("INSERT INTO notes (title, score) VALUES (?, ?)", ["dropped", 3])
+                tx.exec("INSERT INTO missing_table VALUES (1)")
+            })
+        } except err {
+            failed = err.message
+        }
+
+        db.begin()
+        db.exec("DELETE FROM notes")
+        db.rollback()
+
+        rows := db.query("SELECT title, score FROM notes WHERE score > ? ORDER BY id", [0])
+        one := db.query_one("SELECT title FROM notes WHERE id = ?", [first_id])
+        none := db.query_one("SELECT title FROM notes WHERE id = ?", [99])
+        db.close()
+
+        sqlite_ok := inserted == 1 && first_id == 1 && len(rows) == 2
+            && rows[0]["title"] == "first" && rows[0]["score"] == 1.5
+            && rows[1]["title"] == "second" && one["title"] == "first" && none == null
+            && contains(failed, "Database.exec()") && contains(failed, "missing_table")
+            && type(db) == "database"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "sqlite_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_math_namespace() {
+    let script = r#"
+        domain_message := ""
+        try {
+            math.sqrt(-1)
+        } except err {
+            domain_message := err.message
+        }
+
+        PI := 3
+        circle := math.PI * math.pow(2, 2)
+
+        math_ok := math.sqrt(16) == 4.0
+            && math.sqrt(2.25) == 1.5
+            && math.pow(2, 10) == 1024.0
+            && math.abs(-7) == 7.0
+            && math.abs(-2.5) == 2.5
+            && math.floor(2.7) == 2.0
+            && math.ceil(2.1) == 3.0
+            && math.round(2.5) == 3.0
+            && math.min(3, 1.5) == 1.5
+            && math.max(3, 1.5) == 3.0
+            && type(math.sqrt(4)) == "float"
+            && math.E > 2.718 && math.E < 2.719
+            && circle > 12.566 && circle < 12.567
+            && contains(domain_message, "sqrt() domain error")
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "math_ok");
+}
+
+#[test]
+fn vm_and_interpreter_math_seed_makes_random_reproducible() {
+    let script = r#"
+        math.seed(2024)
+        first := [math.random(), math.random(), math.random()]
+        math.seed(2024)
+        second := [math.random(), math.random(), math.random()]
+        seeded_ok := first == second && first[0] >= 0.0 && first[0] < 1.0
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "seeded_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_lazy_iter_pipelines() {
+    let script = r#"
+        func square(x) { return x * x }
+        func is_even(x) { return x % 2 == 0 }
+        func add(acc, x) { return acc + x }
+
+        func* three() {
+            yield "a"
+            yield "b"
+            yield "c"
+        }
+
+        evens := iter.filter(iter.range(1, 1000000000), is_even)
+        first_squares := iter.collect(iter.take(iter.map(evens, square), 4))
+        total := iter.reduce(iter.map(iter.range(101), square), 0, add)
+        countdown := iter.collect(iter.range(10, 0, -3))
+        pairs := iter.collect(iter.zip(three(), iter.range(5)))
+        numbered := iter.collect(iter.enumerate("hi"))
+
+        looped := []
+        for n in iter.take(evens, 6) {
+            if n == 4 {
+                continue
+            }
+            if n == 10 {
+                break
+            }
+            looped := push(looped, n)
+        }
+
+        iter_ok := first_squares == [4, 16, 36, 64]
+            && total == 338350
+            && countdown == [10, 7, 4, 1]
+            && pairs == [["a", 0], ["b", 1], ["c", 2]]
+            && numbered == [[0, "h"], [1, "i"]]
+            && looped == [2, 6, 8]
+            && iter.collect(iter.from({"k": 1})) == ["k"]
+            && type(evens) == "sequence"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "iter_ok");
+}
+
+#[test]
+fn vm_and_interpreter_reject_zero_step_iter_range() {
+    assert_interpreter_and_vm_error_contains(
+        "print(iter.range(0, 10, 0))",
+        "iter.range() step must not be zero",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_reject_non_iterable_iter_source() {
+    assert_interpreter_and_vm_error_contains(
+        "print(iter.collect(iter.map(1.5, to_string)))",
+        "Expected an iterable (array, string, dict, int range, generator, or sequence), got float",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_structural_match_patterns() {
+    let script = r#"
+        enum Shape { Circle, Square }
+
+        func describe(value) {
+            return match value {
+                case 0: "zero"
+                case -1.5: "negative"
+                case "hi": "greeting"
+                case null: "nothing"
+                case [x, y] if x > y: "desc ${x} ${y}"
+                case [x, y]: "pair ${x} ${y}"
+                case [head, ...tail]: "head ${head} +${len(tail)}"
+                case {"status": 200, body}: "ok ${body}"
+                case {"status": code, ...others}: "status ${code} +${len(keys(others))}"
+                case Ok(n) if n > 10: "big"
+                case Err(e): "err ${e}"
+                case Some([a, b]): "some ${a + b}"
+                case Shape::Circle(radius): "circle ${radius}"
+                case Shape::Square: "square"
+                default: "other"
+            }
+        }
+
+        circle := Shape::Circle(2)
+        square := Shape::Square
+        labels := []
+        for value in [0, -1.5, "hi", null, [3, 1], [1, 3], [1, 2, 3], [], {"status": 200, "body": "x"}, {"status": 404, "a": 1}, Ok(11), Ok(2), Err("bad"), Some([1, 2]), circle, square] {
+            labels := push(labels, describe(value))
+        }
+
+        match 4 {
+            case n if n % 2 == 0: {
+                parity := "even"
+            }
+            default: parity := "odd"
+        }
+        unmatched := match 5 { case 1: "one" }
+
+        match_ok := labels == ["zero", "negative", "greeting", "nothing", "desc 3 1", "pair 1 3", "head 1 +2", "other", "ok x", "status 404 +1", "big", "other", "err bad", "some 3", "circle 2", "square"]
+            && parity == "even"
+            && unmatched == null
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "match_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_struct_constructors_and_to_string_hook() {
+    let script = r#"
+        struct Point {
+            x: int,
+            y: int,
+
+            func dist2(self) {
+                return self.x * self.x + self.y * self.y
+            }
+
+            func to_string(self) {
+                return "(${self.x}, ${self.y})"
+            }
+        }
+
+        struct Plain { label }
+
+        make := func(x, y) { return Point(x, y) }
+        p := Point(3, 4)
+        q := make(1, 2)
+        literal := Point { x: 5, y: 6 }
+        plain := Plain("a")
+
+        struct_ok := p.dist2() == 25
+            && q.y == 2
+            && literal.dist2() == 61
+            && "${p}" == "(3, 4)"
+            && to_string(q) == "(1, 2)"
+            && str(literal) == "(5, 6)"
+            && plain.label == "a"
+            && "${plain}" == "Plain { label: a }"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "struct_ok");
+    assert_interpreter_and_vm_error_contains(
+        "struct Point { x, y }\np := Point(1)",
+        "Point expects 2 arguments, got 1",
+    );
+    assert_interpreter_and_vm_error_contains(
+        "struct Point { x, y }\np := Point { x: 1, z: 2 }",
+        "Struct 'Point' has no field 'z'",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_interface_implements_checks() {
+    let script = r#"
+        interface Shape {
+            func area(self) -> float
+            func scale(self, factor)
+        }
+
+        struct Square implements Shape {
+            side: float,
+
+            func area(self) {
+                return self.side * self.side
+            }
+
+            func scale(self, factor) {
+                return Square(self.side * factor)
+            }
+        }
+
+        struct Label { text }
+
+        square := Square(2.0)
+        missing := ""
+        try {
+            struct Circle implements Shape {
+                radius,
+                func area(self) { return 3.0 * self.radius * self.radius }
+            }
+        } except err {
+            missing := err.message
+        }
+
+        interface_ok := square.scale(2.0).area() == 16.0
+            && implements(square, Shape)
+            && implements(Square, Shape)
+            && !implements(Label("x"), Shape)
+            && !implements(3, Shape)
+            && type(Shape) == "interface"
+            && missing == "Struct 'Circle' does not implement interface 'Shape': missing method 'scale'"
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "interface_ok");
+    assert_interpreter_and_vm_error_contains(
+        "interface Shape { func scale(self, factor) }\nstruct Square implements Shape { func scale(self) { return 0 } }",
+        "method 'scale' takes 0 arguments, interface requires 1",
+    );
+    assert_interpreter_and_vm_error_contains(
+        "struct Square implements Missing { side }",
+        "Undefined interface: Missing",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_special_method_operator_overloading() {
+    let script = r#"
+        struct Vec2 {
+            x: int,
+            y: int,
+
+            func __add__(self, other) {
+                return Vec2(self.x + other.x, self.y + other.y)
+            }
+
+            func __eq__(self, other) {
+                return self.x == other.x && self.y == other.y
+            }
+
+            func __index__(self, i) {
+                if i == 0 {
+                    return self.x
+                }
+                return self.y
+            }
+
+            func __str__(self) {
+                return "Vec2(${self.x}, ${self.y})"
+            }
+        }
+
+        struct Matrix {
+            rows,
+
+            func __index__(self, i) {
+                return self.rows[i]
+            }
+
+            func __mul__(self, k) {
+                out := []
+                for row in self.rows {
+                    scaled := []
+                    for cell in row {
+                        scaled := push(scaled, cell * k)
+                    }
+                    out := push(out, scaled)
+                }
+                return Matrix(out)
+            }
+        }
+
+        struct Money {
+            cents: int,
+
+            func __lt__(self, other) {
+                return self.cents < other.cents
+            }
+
+            func __eq__(self, other) {
+                return self.cents - self.cents % 100 == other.cents - other.cents % 100
+            }
+        }
+
+        struct Plain { a }
+
+        v := Vec2(1, 2) + Vec2(3, 4)
+        m := Matrix([[1, 2], [3, 4]]) * 2
+
+        overload_ok := v == Vec2(4, 6)
+            && v != Vec2(0, 0)
+            && v[0] == 4 && v[1] == 6
+            && to_string(v) == "Vec2(4, 6)"
+            && "${v}" == "Vec2(4, 6)"
+            && m[1][0] == 6
+            && Money(100) < Money(250)
+            && Money(150) == Money(120)
+            && !(Money(150) != Money(120))
+            && Plain { a: 1 } == Plain { a: 1 }
+            && Plain { a: 1 } != Plain { a: 2 }
+            && 1 + 2 == 3
+            && [10, 20][1] == 20
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "overload_ok");
+    assert_interpreter_and_vm_error_contains(
+        "struct Plain { a }\nx := Plain { a: 1 } + Plain { a: 2 }",
+        "Invalid binary operation",
+    );
+}
+
+#[test]
+fn vm_and_interpreter_match_constant_folded_loops_and_branches() {
+    let script = r#"
+        mut total := 0
+        mut i := 0
+        while i < 5 {
+            total := total + 2 * 3 + 4
+            i := i + 1
+        }
+
+        mut branch := "unset"
+        if false {
+            branch := "then"
+        } else {
+            branch := "else"
+        }
+        while false {
+            branch := "loop"
+        }
+
+        mut calls := 0
+        func bump() {
+            calls := calls + 1
+            return 7
+        }
+        c1 := true && bump()
+        c2 := false && bump()
+        c3 := true || bump()
+        c4 := false || bump()
+        c5 := bump() && false
+        c6 := bump() || true
+        c7 := 0 || false
+        collapsed := [c1, c2, c3, c4, c5, c6, c7]
+
+        mut n := 0
+        while true {
+            n := n + 1
+            if n > 3 {
+                break
+            }
+        }
+
+        # Compared as one array so no `&&` keeps the optimizer off for this chunk
+        folded_ok := [total, branch, collapsed, calls, "a" + "b" + "c", -(3 + 4), n]
+            == [50, "else", [true, false, true, true, false, true, false], 4, "abc", -7, 4]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "folded_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_cached_global_and_method_lookups() {
+    let script = r#"
+        mut scale := 2
+        func scaled(x) {
+            return x * scale
+        }
+        func read_all() {
+            mut seen := []
+            mut i := 0
+            while i < 3 {
+                seen := push(seen, scaled(i))
+                i := i + 1
+            }
+            return seen
+        }
+
+        func double(x) {
+            return x * 2
+        }
+        mut op := double
+        func apply(x) {
+            return op(x)
+        }
+
+        struct Counter {
+            n: int,
+
+            func get(self) {
+                return self.n
+            }
+        }
+
+        mut bag := [1, 2]
+        func set_first(v) {
+            bag[0] := v
+        }
+        func first_of() {
+            return bag[0]
+        }
+
+        first := read_all()
+        scale := 10
+        second := read_all()
+
+        before_op := apply(3)
+        op := func(x) { return x + 100 }
+        after_op := apply(3)
+
+        mut got := []
+        mut k := 0
+        while k < 3 {
+            got := push(got, Counter(k).get())
+            k := k + 1
+        }
+
+        before_set := first_of()
+        set_first(9)
+        after_set := first_of()
+
+        cache_ok := [first, second, before_op, after_op, got, before_set, after_set]
+            == [[0, 2, 4], [0, 10, 20], 6, 103, [0, 1, 2], 1, 9]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "cache_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_test_assertion_builtins() {
+    let script = r#"
+        func boom() {
+            throw("kaboom")
+        }
+        func calm() {
+            return 1
+        }
+
+        raised := assert_raises(boom)
+        matched := assert_raises(func() { fail("custom failure") }, "custom")
+        eq_ok := assert_eq([1, 2], [1, 2])
+
+        mut failures := []
+        try {
+            assert_raises(calm)
+        } except err {
+            failures := push(failures, err.message)
+        }
+        try {
+            assert_raises(boom, "other")
+        } except err {
+            failures := push(failures, err.message)
+        }
+        try {
+            assert_eq(1, 2)
+        } except err {
+            failures := push(failures, err.message)
+        }
+        try {
+            fail()
+        } except err {
+            failures := push(failures, err.message)
+        }
+
+        assertions_ok := [raised, matched, eq_ok, failures] == [
+            "kaboom",
+            "custom failure",
+            true,
+            [
+                "Assertion failed: expected an error to be raised",
+                "Assertion failed: expected error containing \"other\", got \"kaboom\"",
+                "Assertion failed: expected Int(2), got Int(1)",
+                "Test failed",
+            ],
+        ]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "assertions_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_bench_builtin() {
+    let script = r#"
+        mut calls := 0
+        func work() {
+            calls := calls + 1
+            return calls
+        }
+
+        stats := bench("work", work, {"iterations": 4, "warmup": 3})
+        shape := [stats["name"], stats["iterations"], stats["warmup"], calls]
+        ordered := [stats["min_ns"] <= stats["median_ns"], stats["median_ns"] <= stats["max_ns"]]
+        rate_ok := stats["ops_per_sec"] > 0.0
+
+        mut errors := []
+        try {
+            bench("broken", func() { fail("bad input") })
+        } except err {
+            errors := push(errors, err.message)
+        }
+        try {
+            bench("none", work, {"iterations": 0})
+        } except err {
+            errors := push(errors, err.message)
+        }
+
+        bench_ok := [shape, ordered, rate_ok, errors] == [
+            ["work", 4, 3, 7],
+            [true, true],
+            true,
+            [
+                "bench 'broken' failed during warmup: bad input",
+                "bench option 'iterations' must be at least 1",
+            ],
+        ]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "bench_ok");
+}
+
+#[test]
+fn vm_and_interpreter_match_structural_equality_and_composite_map_keys() {
+    let script = r#"
+        equal := [[1, 2] == [1, 2], {"a": [1]} == {"a": [1.0]}, [1, 2] == [2, 1]]
+        sets := Set([1, 2]) == Set([2, 1])
+
+        mut grid := {}
+        grid[[0, 1]] := "a"
+        mut key := [0]
+        key := push(key, 1)
+        grid[key] := grid[key] + "b"
+        grid[{"x": 1, "y": 2}] := "point"
+        lookups := [
+            grid[[0, 1]],
+            grid[{"y": 2, "x": 1}],
+            has_key(grid, [0, 1]),
+            get(grid, [9], "missing"),
+            len(grid),
+        ]
+        literal := {[1, 2]: "pair"}[[1, 2]]
+
+        nested := [[1, 2], {"k": 3}, [1, 2]]
+        searches := [
+            contains(nested, [1, 2]),
+            index_of(nested, {"k": 3}),
+            len(unique(nested)),
+            len(unique([1, 1.0, "1"])),
+        ]
+
+        sorted := sort([[1, 2], "b", 3, null, [1], true, 1.5, "a", false])
+
+        equality_ok := [equal, sets, lookups, literal, searches, sorted] == [
+            [true, true, false],
+            true,
+            ["ab", "point", 1, "missing", 2],
+            "pair",
+            [true, 1, 2, 2],
+            [null, false, true, 1.5, 3, "a", "b", [1], [1, 2]],
+        ]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "equality_ok");
+}
+
+#[test]
+fn vm_and_interpreter_keep_composite_dict_keys_apart_from_strings() {
+    let script = r#"
+        mut grid := {}
+        grid[[1, 2]] := "list"
+        grid["[1,2]"] := "text"
+        grid[{"x": 1}] := "dict"
+
+        lookups := [grid[[1, 2]], grid["[1,2]"], grid[{"x": 1}], len(grid)]
+        stored := keys(grid)
+        pairs := items({[3, 4]: "pair"})
+        mut visited := []
+        for key in {[5]: 1} {
+            visited = push(visited, key)
+        }
+
+        keys_ok := lookups == ["list", "text", "dict", 3]
+            && contains(stored, [1, 2])
+            && contains(stored, "[1,2]")
+            && contains(stored, {"x": 1})
+            && pairs == [[[3, 4], "pair"]]
+            && visited == [[5]]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "keys_ok");
+}
+
+#[test]
+fn vm_and_interpreter_reject_unhashable_composite_dict_keys() {
+    let script = r#"
+        mut handlers := {}
+        handlers[[print]] := 1
+    "#;
+
+    assert_interpreter_and_vm_error_contains(script, "native_function values cannot be hashed");
+}
+
+#[test]
+fn vm_and_interpreter_let_user_bindings_shadow_the_fmt_namespace() {
+    let script = r#"
+        struct Printer {
+            prefix: string,
+
+            func sprintf(self, text) {
+                return self.prefix + text
+            }
+        }
+
+        builtin := fmt.sprintf("%03d", 7)
+        func shadowed() {
+            fmt := Printer { prefix: "> " }
+            return fmt.sprintf("hi")
+        }
+        sprintf := fmt.sprintf
+
+        fmt_ok := [builtin, shadowed(), sprintf("%x", 255)] == ["007", "> hi", "ff"]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "fmt_ok");
+}
+
+#[test]
+fn vm_and_interpreter_let_user_bindings_shadow_the_time_namespace() {
+    let script = r#"
+        struct Clock {
+            offset: int,
+
+            func now(self) {
+                return 40 + self.offset
+            }
+        }
+
+        func shadowed() {
+            time := Clock { offset: 2 }
+            return time.now()
+        }
+        now := time.now
+
+        time_ok := [type(time.now()), type(now()), time() > 0, shadowed()]
+            == ["float", "float", true, 42]
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "time_ok");
+}
+
+#[test]
+fn vm_and_interpreter_let_user_bindings_shadow_the_math_namespace() {
+    let script = r#"
+        func sqrt(x) {
+            return "user sqrt"
+        }
+        func min(a, b) {
+            return "user min"
+        }
+
+        func shadowed() {
+            PI := 3
+            math := {"sqrt": 5}
+            return [PI, math["sqrt"]]
+        }
+        m := math
+
+        math_ok := [math.sqrt(16), math.min(3, 1.5), m.max(2, 4.5), sqrt(16), min(1, 2)]
+            == [4.0, 1.5, 4.5, "user sqrt", "user min"]
+            && shadowed() == [3, 5] && math.PI > 3.14 && math.E > 2.71
+    "#;
+
+    assert_interpreter_and_vm_bool(script, "math_ok");
+}
+
+#[test]
+fn vm_and_interpreter_let_an_imported_math_module_shadow_the_math_namespace() {
+    let root_dir = format!("modules/{}", unique_module_name());
+    let module_dir = format!("{}/lib", root_dir);
+    fs::create_dir_all(&module_dir).expect("failed to create parity module dir");
+    let module_source = r#"
+export func sqrt(x) {
+    return x * 10
+}
+"#;
+    fs::write(format!("{}/math.ruff", module_dir), module_source)
+        .expect("failed to write parity module");
+
+    let script = format!(
+        r#"
+        import "{}/math"
+        imported_math_ok := math.sqrt(2) == 20
+    "#,
+        module_dir
+    );
+
+    assert_interpreter_and_vm_bool(&script, "imported_math_ok");
+    let _ = fs::remove_dir_all(root_dir);
+}
+
+#[test]
+fn vm_and_interpreter_let_user_bindings_shadow_the_io_namespace() {
+    let dir = std::env::temp_dir().join(unique_module_name());
+    fs::create_dir_all(&dir).expect("failed to create io parity dir");
+    let dir_literal = dir.to_string_lossy().replace('\\', "/");
+    let script = r#"
+        notes := "__DIR__/notes.txt"
+        io.write_file(notes, "alpha", true)
+
+        func read_file(path) {
+            return "user read_file"
+        }
+        func shadowed() {
+            io := {"open": "user open"}
+            return io["open"]
+        }
+        reader := io.read_file
+        m := io
+
+        io_ok := [io.read_file(notes), reader(notes), m.open(notes, "r").read()]
+            == ["alpha", "alpha", "alpha"]
+            && read_file(notes) == "user read_file"
+            && shadowed() == "user open"
+    "#
+    .replace("__DIR__", &dir_literal);
+
+    assert_interpreter_and_vm_bool(&script, "io_ok");
+    let _ = fs::remove_dir_all(dir);
+}
@@ -0,0 +1,62 @@
+// Browser side of `ruff playground`: posts the editor contents to /run and
+// renders the response (`{ events: [{ stream, text }], value, error }`).
+
+const source = document.getElementById("source");
+const output = document.getElementById("output");
+const runButton = document.getElementById("run");
+
+function append(text, className) {
+  const span = document.createElement("span");
+  span.className = className;
+  span.textContent = text;
+  output.appendChild(span);
+}
+
+async function run() {
+  runButton.disabled = true;
+  output.textContent = "";
+  try {
+    const response = await fetch("/run", {
+      method: "POST",
+      headers: { "Content-Type": "text/plain; charset=utf-8", "X-Ruff-Playground": "1" },
+      body: source.value,
+    });
+    const result = await response.json();
+    for (const event of result.events || []) {
+      append(event.text, event.stream);
+    }
+    if (result.value != null) {
+      append(result.value + "\n", "value");
+    }
+    if (result.error != null) {
+      append("Error: " + result.error + "\n", "error");
+    }
+  } catch (error) {
+    append("Request failed: " + error + "\n", "error");
+  } finally {
+    runButton.disabled = false;
+  }
+}
+
+async function showMode() {
+  try {
+    const info = await (await fetch("/info")).json();
+    document.getElementById("mode").textContent = info.sandbox
+      ? `sandboxed · ${info.time_limit_ms} ms · ${info.memory_limit_mb} MB · no filesystem or network`
+      : `local · ${info.time_limit_ms} ms time limit`;
+  } catch (_) {
+    // The mode line is informational only
+  }
+}
+
+runButton.addEventListener("click", run);
+source.addEventListener("keydown", (event) => {
+  if (event.key === "Enter" && (event.ctrlKey || event.metaKey)) {
+    event.preventDefault();
+    run();
+  } else if (event.key === "Tab") {
+    event.preventDefault();
+    source.setRangeText("    ", source.selectionStart, source.selectionEnd, "end");
+  }
+});
+showMode();
@@ -0,0 +1,65 @@
+// Browser bindings for Ruff's WebAssembly build (see src/wasm.rs).
+//
+//   import { loadRuff } from './ruff.js';
+//   const ruff = await loadRuff('ruff.wasm', { stdout: (text) => term.write(text) });
+//   ruff.evalRuff('total := 40');
+//   ruff.evalRuff('print("hi")\ntotal + 2'); // writes "hi\n", returns "42"
+
+const encoder = new TextEncoder();
+const decoder = new TextDecoder();
+
+export class RuffError extends Error {
+	constructor(message) {
+		super(message);
+		this.name = 'RuffError';
+	}
+}
+
+function stripTrailingNewline(text) {
+	return text.endsWith('\n') ? text.slice(0, -1) : text;
+}
+
+async function instantiate(module) {
+	if (module instanceof ArrayBuffer || ArrayBuffer.isView(module)) {
+		return (await WebAssembly.instantiate(module, {})).instance;
+	}
+	const response = module instanceof Response ? module : fetch(module);
+	return (await WebAssembly.instantiateStreaming(response, {})).instance;
+}
+
+// `module` is a URL, a fetch Response, or the compiled bytes. Output is delivered
+// per chunk, including trailing newlines, to the `stdout` and `stderr` callbacks.
+export async function loadRuff(module, options = {}) {
+	const stdout = options.stdout || ((text) => console.log(stripTrailingNewline(text)));
+	const stderr = options.stderr || ((text) => console.error(stripTrailingNewline(text)));
+	const exports = (await instantiate(module)).exports;
+
+	// Runs `source` in the shared session and returns the final expression's value as
+	// a string (`null` when there is none). Errors throw `RuffError` after the output
+	// written before the failure has been delivered.
+	function evalRuff(source) {
+		const bytes = encoder.encode(source);
+		const input = exports.ruff_input_buffer(bytes.length);
+		new Uint8Array(exports.memory.buffer, input, bytes.length).set(bytes);
+
+		const length = exports.ruff_eval();
+		// Evaluation can grow memory, so view the buffer only after it returns.
+		const view = new Uint8Array(exports.memory.buffer, exports.ruff_output_buffer(), length);
+		const response = JSON.parse(decoder.decode(view));
+
+		for (const event of response.events) {
+			('stderr' === event.stream ? stderr : stdout)(event.text);
+		}
+		if (null !== response.error) {
+			throw new RuffError(response.error);
+		}
+		return response.value;
+	}
+
+	// Discards every global defined by earlier evaluations.
+	function reset() {
+		exports.ruff_reset();
+	}
+
+	return { evalRuff, reset };
+}
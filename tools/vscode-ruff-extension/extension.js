@@ -40,7 +40,20 @@ function createServerOptions(command_config) {
 	};
 }
 
+function registerDebugAdapter(context) {
+	const factory = {
+		createDebugAdapterDescriptor(session) {
+			const host = session.configuration.host || '127.0.0.1';
+			const port = session.configuration.port || 4711;
+			return new vscode.DebugAdapterServer(port, host);
+		},
+	};
+	context.subscriptions.push(vscode.debug.registerDebugAdapterDescriptorFactory('ruff', factory));
+}
+
 function activate(context) {
+	registerDebugAdapter(context);
+
 	const config = vscode.workspace.getConfiguration('ruff');
 	if (!config.get('lsp.enabled', true)) {
 		return;